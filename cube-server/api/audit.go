@@ -0,0 +1,296 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/audit"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	store "github.com/tronicum/punchbag-cube-testsuite/store"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CorrelationHeader carries the correlation ID of a request: the client's, or one
+// generated by the server, echoed on every /api/v1 response and kept in the audit log
+const CorrelationHeader = "X-Request-ID"
+
+// maxAuditBody is the largest request or response body kept in an audit entry
+const maxAuditBody = 64 << 10
+
+// anonymousActor is the actor of requests without an authenticated principal
+const anonymousActor = "anonymous"
+
+// auditTrail records every POST, PUT, PATCH and DELETE in the audit log: the caller,
+// the route and resource, the redacted request body, the resource before and after,
+// and the answer. It runs before authentication, so refused changes are recorded too.
+// Before images come straight from st (see auditReaders), so they neither count in
+// the metrics nor need the caller's credentials; other resources (simulated buckets,
+// proxied resources) have none.
+func auditTrail(log *audit.Log, st store.Store, logger *zap.Logger) gin.HandlerFunc {
+	readers := auditReaders()
+	return func(c *gin.Context) {
+		correlationID := c.GetHeader(CorrelationHeader)
+		if correlationID == "" || len(correlationID) > 128 {
+			correlationID = uuid.NewString()
+		}
+		c.Header(CorrelationHeader, correlationID)
+
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		entry := audit.Entry{
+			Time:          time.Now().UTC(),
+			CorrelationID: correlationID,
+			Method:        c.Request.Method,
+			Route:         route,
+			Path:          requestPath(c.Request),
+			Resource:      auditResource(route),
+			Request:       auditRequestBody(c),
+		}
+		if read, ok := readers[route]; ok && st != nil && c.Request.Method != http.MethodPost {
+			project := c.GetHeader(ProjectHeader)
+			if project == "" {
+				project = sharedmodels.DefaultProject
+			}
+			if resource, err := read(st, st.Project(project), c); err == nil {
+				entry.Before = auditJSON(resource)
+			}
+		}
+
+		w := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		entry.Status = w.Status()
+		if principal := auth.FromContext(c); principal != nil {
+			entry.Actor, entry.Role = principal.Subject, string(principal.Role)
+		} else {
+			entry.Actor = anonymousActor
+		}
+		entry.Project = projectName(c)
+		if _, scoped := c.Get(projectKey); !scoped && c.GetHeader(ProjectHeader) != "" {
+			// Refused before the project was resolved
+			entry.Project = c.GetHeader(ProjectHeader)
+		}
+		response := w.json()
+		switch {
+		case entry.Status == http.StatusUnauthorized, entry.Status == http.StatusForbidden && c.IsAborted():
+			// Refused by the token or role check, which abort; quota answers do not
+			entry.Result = audit.ResultDenied
+		case entry.Status >= http.StatusBadRequest:
+			entry.Result = audit.ResultFailure
+		default:
+			entry.Result = audit.ResultSuccess
+		}
+		if entry.Result == audit.ResultSuccess {
+			if c.Request.Method != http.MethodDelete {
+				entry.After = response
+			}
+			entry.Changes = audit.Diff(entry.Before, entry.After)
+		} else if body, ok := response.(map[string]interface{}); ok {
+			entry.Error, _ = body["error"].(string)
+		}
+		entry.ResourceID = auditResourceID(c, route, response)
+		if err := log.Append(entry); err != nil {
+			logger.Error("Failed to write audit entry", zap.String("route", route), zap.Error(err))
+		}
+	}
+}
+
+// auditResource names the resource of a route: the route without /api/v1 and its
+// parameters, e.g. clusters/nodepools for /api/v1/clusters/:id/nodepools/:pool
+func auditResource(route string) string {
+	var parts []string
+	for _, segment := range strings.Split(strings.TrimPrefix(route, "/api/v1/"), "/") {
+		if segment != "" && segment[0] != ':' && segment[0] != '*' {
+			parts = append(parts, segment)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// auditResourceID is the last route parameter when the route ends with one, and the
+// id (or name) the handler answered with otherwise, e.g. for creates
+func auditResourceID(c *gin.Context, route string, response interface{}) string {
+	last := route[strings.LastIndex(route, "/")+1:]
+	if strings.HasPrefix(last, ":") || strings.HasPrefix(last, "*") {
+		return strings.TrimPrefix(c.Param(last[1:]), "/")
+	}
+	if body, ok := response.(map[string]interface{}); ok {
+		for _, key := range []string{"id", "name"} {
+			if id, ok := body[key].(string); ok && id != "" {
+				return id
+			}
+		}
+	}
+	if len(c.Params) > 0 {
+		return strings.TrimPrefix(c.Params[len(c.Params)-1].Value, "/")
+	}
+	return ""
+}
+
+// auditRequestBody returns the redacted JSON body of the request and puts the body
+// back for the handler; other bodies (S3 object data) are not kept
+func auditRequestBody(c *gin.Context) interface{} {
+	if c.Request.Body == nil || c.Request.ContentLength > maxAuditBody || !isJSONContent(c.GetHeader("Content-Type")) {
+		return nil
+	}
+	raw, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBody+1))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(raw), c.Request.Body))
+	if err != nil || len(raw) > maxAuditBody {
+		return nil
+	}
+	return decodeAuditJSON(raw)
+}
+
+// auditReader reads the current state of the resource a route changes; st is the
+// whole store, projectStore the request's project
+type auditReader func(st, projectStore store.Store, c *gin.Context) (interface{}, error)
+
+// auditReaders returns the readers of the routes whose resources are in the store
+func auditReaders() map[string]auditReader {
+	readers := map[string]auditReader{
+		"/api/v1/clusters/:id": func(_, ps store.Store, c *gin.Context) (interface{}, error) {
+			return ps.GetCluster(c.Param("id"))
+		},
+		"/api/v1/clusters/:id/nodepools/:pool": func(_, ps store.Store, c *gin.Context) (interface{}, error) {
+			return ps.GetNodePool(c.Param("pool"))
+		},
+		"/api/v1/tests/:id": func(_, ps store.Store, c *gin.Context) (interface{}, error) {
+			return ps.GetTestResult(c.Param("id"))
+		},
+		"/api/v1/projects/:project": func(st, _ store.Store, c *gin.Context) (interface{}, error) {
+			return st.GetProject(c.Param("project"))
+		},
+	}
+	azure := map[string]func(store.Store, string) (interface{}, error){
+		"loganalytics": func(s store.Store, id string) (interface{}, error) { return s.GetLogAnalyticsWorkspace(id) },
+		"appinsights":  func(s store.Store, id string) (interface{}, error) { return s.GetAppInsights(id) },
+		"budget":       func(s store.Store, id string) (interface{}, error) { return s.GetAzureBudget(id) },
+		"monitor":      func(s store.Store, id string) (interface{}, error) { return s.GetAzureMonitoring(id) },
+		"kubernetes":   func(s store.Store, id string) (interface{}, error) { return s.GetAzureKubernetes(id) },
+	}
+	for kind, get := range azure {
+		readers["/api/v1/azure/"+kind+"/:id"] = func(_, ps store.Store, c *gin.Context) (interface{}, error) {
+			return get(ps, c.Param("id"))
+		}
+	}
+	return readers
+}
+
+// auditJSON returns a value as redacted decoded JSON, the way the API answers it
+func auditJSON(value interface{}) interface{} {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return decodeAuditJSON(raw)
+}
+
+func decodeAuditJSON(raw []byte) interface{} {
+	var value interface{}
+	if len(bytes.TrimSpace(raw)) == 0 || json.Unmarshal(raw, &value) != nil {
+		return nil
+	}
+	return audit.Redact(value)
+}
+
+func isJSONContent(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// auditWriter keeps a copy of the response body, up to maxAuditBody
+type auditWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	tooLarge bool
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	if !w.tooLarge {
+		if w.body.Len()+len(data) > maxAuditBody {
+			w.tooLarge = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// json returns the redacted JSON response body, or nil
+func (w *auditWriter) json() interface{} {
+	if w.tooLarge || !isJSONContent(w.Header().Get("Content-Type")) {
+		return nil
+	}
+	return decodeAuditJSON(w.body.Bytes())
+}
+
+// AuditHandlers serves GET /api/v1/audit
+type AuditHandlers struct {
+	log    *audit.Log
+	logger *zap.Logger
+}
+
+// NewAuditHandlers creates the audit query handler
+func NewAuditHandlers(log *audit.Log, logger *zap.Logger) *AuditHandlers {
+	return &AuditHandlers{log: log, logger: logger}
+}
+
+// QueryAudit handles GET /audit: the entries of the request's project, newest first,
+// filtered by since/until (RFC 3339), actor, resource, resource_id and result and paged
+// like other lists (see listing.go)
+func (h *AuditHandlers) QueryAudit(c *gin.Context) {
+	filter := audit.Filter{
+		Project:    projectName(c),
+		Actor:      c.Query("actor"),
+		Resource:   c.Query("resource"),
+		ResourceID: c.Query("resource_id"),
+		Result:     c.Query("result"),
+	}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := c.Query(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name + ": want an RFC 3339 time such as 2026-01-02T15:04:05Z"})
+				return
+			}
+			*t = parsed
+		}
+	}
+	entries, err := h.log.Query(filter)
+	if err != nil {
+		h.logger.Error("Failed to query the audit log", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	writeListPage(c, "entries", entries, auditListFields)
+}
+
+var auditListFields = listFields[audit.Entry]{
+	id:           func(e audit.Entry) string { return e.ID },
+	createdAt:    func(e audit.Entry) time.Time { return e.Time },
+	defaultOrder: "desc",
+	sort: map[string]func(audit.Entry) string{
+		"actor": func(e audit.Entry) string { return e.Actor },
+	},
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/audit"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+func TestAuditLog(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{Enabled: true, Tokens: []auth.StaticToken{
+		{Name: "root", Token: "admin-token", Role: auth.RoleAdmin},
+		{Name: "ci", Token: "operator-token", Role: auth.RoleOperator},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLog, err := audit.Open(audit.Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+	r := newTestRouter(t, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: NewTestSimulationService(), Auth: authenticator, Audit: auditLog})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	base := srv.URL + "/api/v1"
+
+	cluster := map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}
+	if status := doAuthJSON(t, http.MethodPost, base+"/clusters", "operator-token", cluster, nil); status != http.StatusCreated {
+		t.Fatalf("create cluster: status %d", status)
+	}
+	cluster["location"] = "nbg1"
+	if status := doAuthJSON(t, http.MethodPut, base+"/clusters/hz-1", "operator-token", cluster, nil); status != http.StatusOK {
+		t.Fatalf("update cluster: status %d", status)
+	}
+	if status := doAuthJSON(t, http.MethodDelete, base+"/clusters/hz-1", "", nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("delete without token: status %d", status)
+	}
+	if status := doAuthJSON(t, http.MethodDelete, base+"/clusters/hz-1", "operator-token", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete cluster: status %d", status)
+	}
+	if status := doAuthJSON(t, http.MethodPost, base+"/auth/tokens", "admin-token", map[string]string{"name": "dash", "role": "viewer"}, nil); status != http.StatusCreated {
+		t.Fatalf("issue token: status %d", status)
+	}
+
+	// The correlation ID of the client is kept; others get one
+	req, _ := http.NewRequest(http.MethodPost, base+"/clusters", bytes.NewReader([]byte(`{"name": "bad"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer operator-token")
+	req.Header.Set(CorrelationHeader, "req-42")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get(CorrelationHeader) != "req-42" {
+		t.Errorf("invalid cluster: status %d, correlation ID %q", resp.StatusCode, resp.Header.Get(CorrelationHeader))
+	}
+
+	var page struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/audit", "operator-token", nil, nil); status != http.StatusForbidden {
+		t.Errorf("operator reads the audit log: status %d, want 403", status)
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/audit?resource=clusters&resource_id=hz-1", "admin-token", nil, &page); status != http.StatusOK {
+		t.Fatalf("query audit log: status %d", status)
+	}
+	if len(page.Entries) != 4 {
+		t.Fatalf("hz-1 entries = %+v", page.Entries)
+	}
+	deleted, denied, updated, created := page.Entries[0], page.Entries[1], page.Entries[2], page.Entries[3]
+	if created.Method != http.MethodPost || created.Actor != "ci" || created.Role != "operator" || created.Result != audit.ResultSuccess ||
+		created.Project != "default" || created.Route != "/api/v1/clusters" || created.Before != nil || created.After == nil || created.CorrelationID == "" {
+		t.Errorf("create entry = %+v", created)
+	}
+	if len(updated.Changes) == 0 {
+		t.Fatalf("update entry has no changes: %+v", updated)
+	}
+	var location *audit.Change
+	for i, change := range updated.Changes {
+		if change.Path == "location" {
+			location = &updated.Changes[i]
+		}
+	}
+	if location == nil || location.Before != "fsn1" || location.After != "nbg1" {
+		t.Errorf("update changes = %+v", updated.Changes)
+	}
+	if denied.Result != audit.ResultDenied || denied.Actor != "anonymous" || denied.Status != http.StatusUnauthorized {
+		t.Errorf("denied entry = %+v", denied)
+	}
+	if deleted.Result != audit.ResultSuccess || deleted.Before == nil || deleted.After != nil || deleted.Status != http.StatusNoContent {
+		t.Errorf("delete entry = %+v", deleted)
+	}
+
+	if status := doAuthJSON(t, http.MethodGet, base+"/audit?resource=auth", "admin-token", nil, &page); status != http.StatusOK || len(page.Entries) != 1 {
+		t.Fatalf("token entries: status %d, %+v", status, page.Entries)
+	}
+	issued, _ := page.Entries[0].After.(map[string]interface{})
+	if issued["token"] != audit.Redacted || issued["name"] != "dash" {
+		t.Errorf("issued token not redacted: %v", page.Entries[0].After)
+	}
+
+	if status := doAuthJSON(t, http.MethodGet, base+"/audit?result=failure&actor=ci", "admin-token", nil, &page); status != http.StatusOK ||
+		len(page.Entries) != 1 || page.Entries[0].CorrelationID != "req-42" || page.Entries[0].Error == "" {
+		t.Errorf("failures of ci: status %d, %+v", status, page.Entries)
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/audit?since=2099-01-01T00:00:00Z", "admin-token", nil, &page); status != http.StatusOK || len(page.Entries) != 0 {
+		t.Errorf("entries since 2099: status %d, %+v", status, page.Entries)
+	}
+	var body map[string]string
+	if status := doAuthJSON(t, http.MethodGet, base+"/audit?since=yesterday", "admin-token", nil, &body); status != http.StatusBadRequest {
+		t.Errorf("invalid since: status %d, %v", status, body)
+	}
+	// Other projects have their own trail
+	if status := doAuthJSON(t, http.MethodPost, base+"/projects", "admin-token", map[string]string{"name": "audit-team"}, nil); status != http.StatusCreated {
+		t.Fatalf("create project: status %d", status)
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/projects/audit-team/audit", "admin-token", nil, &page); status != http.StatusOK || len(page.Entries) != 0 {
+		t.Errorf("audit-team entries: status %d, %+v", status, page.Entries)
+	}
+	raw, _ := json.Marshal(page)
+	if !bytes.Contains(raw, []byte(`"entries":[]`)) {
+		t.Errorf("empty page = %s, want an empty list", raw)
+	}
+}
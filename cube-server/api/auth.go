@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	"go.uber.org/zap"
+)
+
+// AuthHandlers serves /api/v1/auth: sessions for the caller and token administration.
+type AuthHandlers struct {
+	auth   *auth.Authenticator
+	logger *zap.Logger
+}
+
+// NewAuthHandlers creates the /api/v1/auth handlers.
+func NewAuthHandlers(a *auth.Authenticator, logger *zap.Logger) *AuthHandlers {
+	return &AuthHandlers{auth: a, logger: logger}
+}
+
+// TokenRequest is the body of POST /auth/tokens; ttl is a duration such as "720h".
+type TokenRequest struct {
+	Name string `json:"name" binding:"required"`
+	Role string `json:"role" binding:"required"`
+	TTL  string `json:"ttl,omitempty"`
+	// Projects limits the token to these projects; empty allows every project
+	Projects []string `json:"projects,omitempty"`
+}
+
+// WhoAmI handles GET /auth/whoami
+func (h *AuthHandlers) WhoAmI(c *gin.Context) {
+	c.JSON(http.StatusOK, auth.FromContext(c))
+}
+
+// Login handles POST /auth/login: the credential of the request (a token or an OIDC
+// JWT) is exchanged for a session token.
+func (h *AuthHandlers) Login(c *gin.Context) {
+	h.session(c, h.auth.Login)
+}
+
+// Refresh handles POST /auth/refresh: a session token is replaced by a new one.
+func (h *AuthHandlers) Refresh(c *gin.Context) {
+	h.session(c, h.auth.Refresh)
+}
+
+func (h *AuthHandlers) session(c *gin.Context, issue func(*auth.Principal) (*auth.IssuedToken, error)) {
+	token, err := issue(auth.FromContext(c))
+	if err != nil {
+		h.logger.Error("Failed to issue session token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+// CreateToken handles POST /auth/tokens
+func (h *AuthHandlers) CreateToken(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role, err := auth.ParseRole(req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "roles": auth.Roles()})
+		return
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ttl: " + req.TTL})
+			return
+		}
+	}
+	token, err := h.auth.Issue(req.Name, role, req.Projects, ttl, auth.FromContext(c).Subject)
+	if err != nil {
+		h.logger.Error("Failed to issue token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	h.logger.Info("Token issued", zap.String("id", token.ID), zap.String("name", token.Name), zap.String("role", string(token.Role)))
+	c.JSON(http.StatusCreated, token)
+}
+
+// ListTokens handles GET /auth/tokens; see listing.go for the query parameters
+func (h *AuthHandlers) ListTokens(c *gin.Context) {
+	writeListPage(c, "tokens", h.auth.Tokens(), tokenListFields)
+}
+
+var tokenListFields = listFields[auth.IssuedToken]{
+	id:          func(t auth.IssuedToken) string { return t.ID },
+	name:        func(t auth.IssuedToken) string { return t.Name },
+	createdAt:   func(t auth.IssuedToken) time.Time { return t.CreatedAt },
+	defaultSort: "created_at",
+	sort: map[string]func(auth.IssuedToken) string{
+		"subject":    func(t auth.IssuedToken) string { return t.Subject },
+		"role":       func(t auth.IssuedToken) string { return string(t.Role) },
+		"expires_at": func(t auth.IssuedToken) string { return timeSortKey(t.ExpiresAt) },
+	},
+}
+
+// RevokeToken handles DELETE /auth/tokens/:id
+func (h *AuthHandlers) RevokeToken(c *gin.Context) {
+	if err := h.auth.Revoke(c.Param("id")); err != nil {
+		if errors.Is(err, auth.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	h.logger.Info("Token revoked", zap.String("id", c.Param("id")))
+	c.JSON(http.StatusNoContent, nil)
+}
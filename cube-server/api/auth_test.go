@@ -0,0 +1,192 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+// doAuthJSON is doJSON with a bearer token (none if empty).
+func doAuthJSON(t *testing.T, method, url, token string, body, out interface{}) int {
+	t.Helper()
+	var raw []byte
+	if body != nil {
+		raw, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, url, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		_ = json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+func TestAuthentication(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{Enabled: true, Tokens: []auth.StaticToken{
+		{Name: "root", Token: "admin-token", Role: auth.RoleAdmin},
+		{Name: "ci", Token: "operator-token", Role: auth.RoleOperator},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouter(t, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: NewTestSimulationService(), Auth: authenticator})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	base := srv.URL + "/api/v1"
+
+	if status := doAuthJSON(t, http.MethodGet, base+"/clusters", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", status)
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/clusters", "bogus", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("unknown token: status %d, want 401", status)
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/metrics/health", "", nil, nil); status != http.StatusOK {
+		t.Errorf("health check without token: status %d, want 200", status)
+	}
+
+	var viewer auth.IssuedToken
+	if status := doAuthJSON(t, http.MethodPost, base+"/auth/tokens", "operator-token", map[string]string{"name": "dash", "role": "viewer"}, nil); status != http.StatusForbidden {
+		t.Errorf("operator issues token: status %d, want 403", status)
+	}
+	if status := doAuthJSON(t, http.MethodPost, base+"/auth/tokens", "admin-token", map[string]string{"name": "dash", "role": "viewer", "ttl": "1h"}, &viewer); status != http.StatusCreated || viewer.Token == "" {
+		t.Fatalf("issue token: status %d, %+v", status, viewer)
+	}
+	if status := doAuthJSON(t, http.MethodPost, base+"/auth/tokens", "admin-token", map[string]string{"name": "x", "role": "root"}, nil); status != http.StatusBadRequest {
+		t.Errorf("issue token with unknown role: status %d, want 400", status)
+	}
+
+	cluster := map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}
+	if status := doAuthJSON(t, http.MethodPost, base+"/clusters", viewer.Token, cluster, nil); status != http.StatusForbidden {
+		t.Errorf("viewer creates cluster: status %d, want 403", status)
+	}
+	if status := doAuthJSON(t, http.MethodPost, base+"/clusters", "operator-token", cluster, nil); status != http.StatusCreated {
+		t.Errorf("operator creates cluster: status %d, want 201", status)
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/clusters/hz-1", viewer.Token, nil, nil); status != http.StatusOK {
+		t.Errorf("viewer reads cluster: status %d, want 200", status)
+	}
+	if status := doAuthJSON(t, http.MethodPost, base+"/executor/azure/aks", "operator-token", map[string]interface{}{"dryrun": true}, nil); status != http.StatusForbidden {
+		t.Errorf("operator executes: status %d, want 403", status)
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/executor/executions", viewer.Token, nil, nil); status != http.StatusForbidden {
+		t.Errorf("viewer lists executions: status %d, want 403", status)
+	}
+	if status := doAuthJSON(t, http.MethodPost, base+"/projects", "operator-token", map[string]string{"name": "team-a"}, nil); status != http.StatusForbidden {
+		t.Errorf("operator creates project: status %d, want 403", status)
+	}
+	if status := doAuthJSON(t, http.MethodPost, base+"/projects", "admin-token", map[string]string{"name": "team-a"}, nil); status != http.StatusCreated {
+		t.Errorf("admin creates project: status %d, want 201", status)
+	}
+	// Requests through the project prefix are checked against the route they name
+	if status := doAuthJSON(t, http.MethodGet, base+"/projects/team-a/clusters", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("project prefix without token: status %d, want 401", status)
+	}
+	if status := doAuthJSON(t, http.MethodPost, base+"/projects/team-a/clusters", viewer.Token, cluster, nil); status != http.StatusForbidden {
+		t.Errorf("viewer creates cluster in project: status %d, want 403", status)
+	}
+	if status := doAuthJSON(t, http.MethodPost, base+"/projects/team-a/clusters", "operator-token", cluster, nil); status != http.StatusCreated {
+		t.Errorf("operator creates cluster in project: status %d, want 201", status)
+	}
+
+	var session auth.IssuedToken
+	if status := doAuthJSON(t, http.MethodPost, base+"/auth/login", "operator-token", nil, &session); status != http.StatusOK || !session.Session || session.Role != auth.RoleOperator {
+		t.Fatalf("login: status %d, %+v", status, session)
+	}
+	var refreshed auth.IssuedToken
+	doAuthJSON(t, http.MethodPost, base+"/auth/refresh", session.Token, nil, &refreshed)
+	var me auth.Principal
+	if status := doAuthJSON(t, http.MethodGet, base+"/auth/whoami", refreshed.Token, nil, &me); status != http.StatusOK || me.Subject != "ci" || me.Role != auth.RoleOperator {
+		t.Errorf("whoami: status %d, %+v", status, me)
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/auth/whoami", session.Token, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("session token after refresh: status %d, want 401", status)
+	}
+
+	if status := doAuthJSON(t, http.MethodDelete, base+"/auth/tokens/"+viewer.ID, "admin-token", nil, nil); status != http.StatusNoContent {
+		t.Errorf("revoke token: status %d", status)
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/clusters", viewer.Token, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("revoked token: status %d, want 401", status)
+	}
+}
+
+func TestProjectLimitedTokens(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{Enabled: true, Tokens: []auth.StaticToken{
+		{Name: "root", Token: "admin-token", Role: auth.RoleAdmin},
+		{Name: "team-a", Token: "team-a-token", Role: auth.RoleAdmin, Projects: []string{"team-a"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouter(t, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: NewTestSimulationService(), Auth: authenticator})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	base := srv.URL + "/api/v1"
+	for _, name := range []string{"team-a", "team-b"} {
+		if status := doAuthJSON(t, http.MethodPost, base+"/projects", "admin-token", map[string]string{"name": name}, nil); status != http.StatusCreated {
+			t.Fatalf("create project %s: status %d", name, status)
+		}
+	}
+	var teamB auth.IssuedToken
+	if status := doAuthJSON(t, http.MethodPost, base+"/auth/tokens", "admin-token", map[string]interface{}{"name": "b", "role": "operator", "projects": []string{"team-b"}}, &teamB); status != http.StatusCreated {
+		t.Fatalf("issue token for team-b: status %d", status)
+	}
+	var session auth.IssuedToken
+	if status := doAuthJSON(t, http.MethodPost, base+"/auth/login", "team-a-token", nil, &session); status != http.StatusOK {
+		t.Fatalf("login: status %d", status)
+	}
+
+	cluster := map[string]interface{}{"id": "c-1", "name": "c-1", "provider": "hetzner", "location": "fsn1"}
+	for _, tc := range []struct {
+		name         string
+		method, path string
+		token        string
+		body         interface{}
+		want         int
+	}{
+		{"own project", http.MethodPost, "/projects/team-a/clusters", "team-a-token", cluster, http.StatusCreated},
+		{"other project", http.MethodGet, "/projects/team-b/clusters", "team-a-token", nil, http.StatusForbidden},
+		{"other project by header", http.MethodGet, "/clusters", "team-a-token", nil, http.StatusForbidden},
+		{"unknown project", http.MethodGet, "/projects/team-x/clusters", "team-a-token", nil, http.StatusForbidden},
+		{"other project record", http.MethodGet, "/projects/team-b", "team-a-token", nil, http.StatusForbidden},
+		{"own project record", http.MethodGet, "/projects/team-a", "team-a-token", nil, http.StatusOK},
+		{"session", http.MethodGet, "/projects/team-b/clusters", session.Token, nil, http.StatusForbidden},
+		{"issued token", http.MethodPost, "/projects/team-b/clusters", teamB.Token, cluster, http.StatusCreated},
+		{"issued token, other project", http.MethodGet, "/projects/team-a/clusters/c-1", teamB.Token, nil, http.StatusForbidden},
+		{"creating projects", http.MethodPost, "/projects", "team-a-token", map[string]string{"name": "team-c"}, http.StatusForbidden},
+		{"issuing tokens", http.MethodPost, "/auth/tokens", "team-a-token", map[string]string{"name": "x", "role": "admin"}, http.StatusForbidden},
+		{"restoring", http.MethodPost, "/simulate/admin/restore", "team-a-token", map[string]interface{}{"version": 1}, http.StatusForbidden},
+		{"unlimited token", http.MethodGet, "/projects/team-b/clusters/c-1", "admin-token", nil, http.StatusOK},
+	} {
+		if status := doAuthJSON(t, tc.method, base+tc.path, tc.token, tc.body, nil); status != tc.want {
+			t.Errorf("%s: %s %s: status %d, want %d", tc.name, tc.method, tc.path, status, tc.want)
+		}
+	}
+
+	var list struct {
+		Projects []struct {
+			Name string `json:"name"`
+		} `json:"projects"`
+	}
+	if status := doAuthJSON(t, http.MethodGet, base+"/projects", "team-a-token", nil, &list); status != http.StatusOK || len(list.Projects) != 1 || list.Projects[0].Name != "team-a" {
+		t.Errorf("GET /projects with a project-limited token: status %d, %+v", status, list.Projects)
+	}
+	var me auth.Principal
+	if status := doAuthJSON(t, http.MethodGet, base+"/auth/whoami", session.Token, nil, &me); status != http.StatusOK || len(me.Projects) != 1 || me.Projects[0] != "team-a" {
+		t.Errorf("whoami of a session: status %d, %+v", status, me)
+	}
+}
@@ -0,0 +1,394 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	store "github.com/tronicum/punchbag-cube-testsuite/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Azure monitoring, Kubernetes and budget resources, kept in the store and served
+// below /api/v1/azure:
+//
+//	/loganalytics   Log Analytics workspaces
+//	/appinsights    Application Insights
+//	/budget         budgets
+//	/monitor        monitors
+//	/kubernetes     AKS clusters
+//
+// Every path supports POST and GET on the collection and GET, PUT and DELETE on /:id.
+
+// azureResource serves the routes of one Azure resource type from the store of the
+// request's project
+type azureResource[T any] struct {
+	kind    string // singular name used in errors and logs
+	listKey string // key of the list in GET answers
+	store   store.Store
+	logger  *zap.Logger
+
+	create func(s store.Store, v *T) (*T, error)
+	get    func(s store.Store, id string) (*T, error)
+	update func(s store.Store, id string, v *T) (*T, error)
+	remove func(s store.Store, id string) error
+	list   func(s store.Store) ([]*T, error)
+	// name names the resource in logs; it is also the default order of lists
+	name func(*T) string
+	// fields filters and sorts lists (see listing.go); name is filled in from above
+	fields listFields[*T]
+	// prepare fills in defaults and validates; existing is nil on create
+	prepare func(v, existing *T) error
+	// version is the ResourceVersion of a resource (see preconditions.go)
+	version func(*T) int64
+	locks   resourceLocks
+}
+
+func (r *azureResource[T]) register(group *gin.RouterGroup, path string) {
+	r.fields.name = r.name
+	group.POST(path, r.handleCreate)
+	group.GET(path, r.handleList)
+	group.GET(path+"/:id", r.handleGet)
+	group.PUT(path+"/:id", r.handleUpdate)
+	group.DELETE(path+"/:id", r.handleDelete)
+}
+
+func (r *azureResource[T]) handleCreate(c *gin.Context) {
+	v := new(T)
+	if err := c.ShouldBindJSON(v); err != nil {
+		r.logger.Error("Failed to bind "+r.kind+" data", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := r.prepare(v, nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := r.create(r.projectStore(c), v)
+	if err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": r.kind + " already exists"})
+			return
+		}
+		r.logger.Error("Failed to create "+r.kind, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	r.logger.Info("Azure resource created", zap.String("kind", r.kind), zap.String("name", r.name(created)))
+	setETag(c, r.version(created))
+	c.JSON(http.StatusCreated, created)
+}
+
+func (r *azureResource[T]) handleList(c *gin.Context) {
+	items, err := r.list(r.projectStore(c))
+	if err != nil {
+		r.logger.Error("Failed to list "+r.kind, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	writeListPage(c, r.listKey, items, r.fields)
+}
+
+func (r *azureResource[T]) handleGet(c *gin.Context) {
+	if v, ok := r.lookup(c); ok {
+		setETag(c, r.version(v))
+		c.JSON(http.StatusOK, v)
+	}
+}
+
+func (r *azureResource[T]) handleUpdate(c *gin.Context) {
+	v := new(T)
+	if err := c.ShouldBindJSON(v); err != nil {
+		r.logger.Error("Failed to bind "+r.kind+" data", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer r.locks.lock(resourceKey(c, c.Param("id")))()
+	existing, ok := r.lookup(c)
+	if !ok || !precondition(c, r.version(existing)) {
+		return
+	}
+	if err := r.prepare(v, existing); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := r.update(r.projectStore(c), c.Param("id"), v)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": r.kind + " not found"})
+			return
+		}
+		r.logger.Error("Failed to update "+r.kind, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	r.logger.Info("Azure resource updated", zap.String("kind", r.kind), zap.String("id", c.Param("id")))
+	setETag(c, r.version(updated))
+	c.JSON(http.StatusOK, updated)
+}
+
+func (r *azureResource[T]) handleDelete(c *gin.Context) {
+	defer r.locks.lock(resourceKey(c, c.Param("id")))()
+	existing, ok := r.lookup(c)
+	if !ok || !precondition(c, r.version(existing)) {
+		return
+	}
+	if err := r.remove(r.projectStore(c), c.Param("id")); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": r.kind + " not found"})
+			return
+		}
+		r.logger.Error("Failed to delete "+r.kind, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	r.logger.Info("Azure resource deleted", zap.String("kind", r.kind), zap.String("id", c.Param("id")))
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (r *azureResource[T]) projectStore(c *gin.Context) store.Store {
+	return r.store.Project(projectName(c))
+}
+
+// lookup answers 404 when the resource of the request does not exist
+func (r *azureResource[T]) lookup(c *gin.Context) (*T, bool) {
+	v, err := r.get(r.projectStore(c), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": r.kind + " not found"})
+			return nil, false
+		}
+		r.logger.Error("Failed to get "+r.kind, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	return v, true
+}
+
+// registerAzureResources adds the Azure resource routes to group. Servers without a
+// store (simulation-only tests) do not serve them.
+func registerAzureResources(group *gin.RouterGroup, s store.Store, logger *zap.Logger) {
+	if s == nil {
+		return
+	}
+	(&azureResource[sharedmodels.LogAnalyticsWorkspace]{
+		kind: "log analytics workspace", listKey: "workspaces", store: s, logger: logger,
+		create: store.Store.CreateLogAnalyticsWorkspace, get: store.Store.GetLogAnalyticsWorkspace, update: store.Store.UpdateLogAnalyticsWorkspace,
+		remove: store.Store.DeleteLogAnalyticsWorkspace, list: store.Store.ListLogAnalyticsWorkspaces,
+		name:    func(w *sharedmodels.LogAnalyticsWorkspace) string { return w.Name },
+		version: func(w *sharedmodels.LogAnalyticsWorkspace) int64 { return w.ResourceVersion },
+		fields: listFields[*sharedmodels.LogAnalyticsWorkspace]{
+			id:        func(w *sharedmodels.LogAnalyticsWorkspace) string { return w.ID },
+			region:    func(w *sharedmodels.LogAnalyticsWorkspace) string { return w.Location },
+			createdAt: func(w *sharedmodels.LogAnalyticsWorkspace) time.Time { return w.CreatedAt },
+		},
+		prepare: prepareLogAnalyticsWorkspace,
+	}).register(group, "/loganalytics")
+
+	(&azureResource[sharedmodels.AppInsightsResource]{
+		kind: "application insights", listKey: "app_insights", store: s, logger: logger,
+		create: store.Store.CreateAppInsights, get: store.Store.GetAppInsights, update: store.Store.UpdateAppInsights,
+		remove: store.Store.DeleteAppInsights, list: store.Store.ListAppInsights,
+		name:    func(a *sharedmodels.AppInsightsResource) string { return a.Name },
+		version: func(a *sharedmodels.AppInsightsResource) int64 { return a.ResourceVersion },
+		fields: listFields[*sharedmodels.AppInsightsResource]{
+			id:        func(a *sharedmodels.AppInsightsResource) string { return a.ID },
+			region:    func(a *sharedmodels.AppInsightsResource) string { return a.Location },
+			createdAt: func(a *sharedmodels.AppInsightsResource) time.Time { return a.CreatedAt },
+		},
+		prepare: prepareAppInsights,
+	}).register(group, "/appinsights")
+
+	(&azureResource[sharedmodels.AzureBudget]{
+		kind: "budget", listKey: "budgets", store: s, logger: logger,
+		create: store.Store.CreateAzureBudget, get: store.Store.GetAzureBudget, update: store.Store.UpdateAzureBudget,
+		remove: store.Store.DeleteAzureBudget, list: store.Store.ListAzureBudgets,
+		name:    func(b *sharedmodels.AzureBudget) string { return b.Name },
+		version: func(b *sharedmodels.AzureBudget) int64 { return b.ResourceVersion },
+		fields: listFields[*sharedmodels.AzureBudget]{
+			id:        func(b *sharedmodels.AzureBudget) string { return b.ID },
+			createdAt: func(b *sharedmodels.AzureBudget) time.Time { return b.CreatedAt },
+			sort: map[string]func(*sharedmodels.AzureBudget) string{
+				"amount": func(b *sharedmodels.AzureBudget) string { return fmt.Sprintf("%020.2f", b.Amount) },
+			},
+		},
+		prepare: prepareAzureBudget,
+	}).register(group, "/budget")
+
+	(&azureResource[sharedmodels.AzureMonitoring]{
+		kind: "monitor", listKey: "monitors", store: s, logger: logger,
+		create: store.Store.CreateAzureMonitoring, get: store.Store.GetAzureMonitoring, update: store.Store.UpdateAzureMonitoring,
+		remove: store.Store.DeleteAzureMonitoring, list: store.Store.ListAzureMonitorings,
+		name:    func(m *sharedmodels.AzureMonitoring) string { return m.Name },
+		version: func(m *sharedmodels.AzureMonitoring) int64 { return m.ResourceVersion },
+		fields: listFields[*sharedmodels.AzureMonitoring]{
+			id:        func(m *sharedmodels.AzureMonitoring) string { return m.ID },
+			createdAt: func(m *sharedmodels.AzureMonitoring) time.Time { return m.CreatedAt },
+		},
+		prepare: func(m, _ *sharedmodels.AzureMonitoring) error {
+			if m.Name == "" {
+				return errors.New("name is required")
+			}
+			return nil
+		},
+	}).register(group, "/monitor")
+
+	(&azureResource[sharedmodels.AzureKubernetes]{
+		kind: "kubernetes cluster", listKey: "kubernetes", store: s, logger: logger,
+		create: store.Store.CreateAzureKubernetes, get: store.Store.GetAzureKubernetes, update: store.Store.UpdateAzureKubernetes,
+		remove: store.Store.DeleteAzureKubernetes, list: store.Store.ListAzureKubernetes,
+		name:    func(k *sharedmodels.AzureKubernetes) string { return k.Name },
+		version: func(k *sharedmodels.AzureKubernetes) int64 { return k.ResourceVersion },
+		fields: listFields[*sharedmodels.AzureKubernetes]{
+			id:        func(k *sharedmodels.AzureKubernetes) string { return k.ID },
+			createdAt: func(k *sharedmodels.AzureKubernetes) time.Time { return k.CreatedAt },
+			sort: map[string]func(*sharedmodels.AzureKubernetes) string{
+				"cluster_size": func(k *sharedmodels.AzureKubernetes) string { return intSortKey(k.ClusterSize) },
+			},
+		},
+		prepare: func(k, _ *sharedmodels.AzureKubernetes) error {
+			if k.Name == "" {
+				return errors.New("name is required")
+			}
+			if k.ClusterSize == 0 {
+				k.ClusterSize = 1
+			}
+			if k.ClusterSize < 1 {
+				return errors.New("cluster_size must be at least 1")
+			}
+			return nil
+		},
+	}).register(group, "/kubernetes")
+}
+
+// Log Analytics pricing tiers and the retention they allow
+var logAnalyticsSkus = []string{"PerGB2018", "Free", "Standalone", "PerNode", "Standard", "Premium", "CapacityReservation"}
+
+const (
+	minLogAnalyticsRetention = 30
+	maxLogAnalyticsRetention = 730
+)
+
+func prepareLogAnalyticsWorkspace(w, existing *sharedmodels.LogAnalyticsWorkspace) error {
+	if err := requireAzureLocation(w.Name, w.ResourceGroup, w.Location); err != nil {
+		return err
+	}
+	if w.Sku == "" {
+		w.Sku = logAnalyticsSkus[0]
+	}
+	if !containsString(logAnalyticsSkus, w.Sku) {
+		return fmt.Errorf("sku must be one of %v", logAnalyticsSkus)
+	}
+	if w.RetentionDays == 0 {
+		w.RetentionDays = minLogAnalyticsRetention
+	}
+	if w.RetentionDays < minLogAnalyticsRetention || w.RetentionDays > maxLogAnalyticsRetention {
+		return fmt.Errorf("retention_days must be between %d and %d", minLogAnalyticsRetention, maxLogAnalyticsRetention)
+	}
+	// The customer ID identifies the workspace to agents; it never changes
+	switch {
+	case existing != nil:
+		w.CustomerID = existing.CustomerID
+	case w.CustomerID == "":
+		w.CustomerID = uuid.New().String()
+	}
+	return nil
+}
+
+// Application Insights application types and retention periods
+var (
+	appInsightsTypes     = []string{"web", "other"}
+	appInsightsRetention = []int{30, 60, 90, 120, 180, 270, 365, 550, 730}
+)
+
+func prepareAppInsights(a, _ *sharedmodels.AppInsightsResource) error {
+	if err := requireAzureLocation(a.Name, a.ResourceGroup, a.Location); err != nil {
+		return err
+	}
+	if a.AppType == "" {
+		a.AppType = appInsightsTypes[0]
+	}
+	if !containsString(appInsightsTypes, a.AppType) {
+		return fmt.Errorf("app_type must be one of %v", appInsightsTypes)
+	}
+	if a.RetentionDays == 0 {
+		a.RetentionDays = 90
+	}
+	for _, days := range appInsightsRetention {
+		if a.RetentionDays == days {
+			return nil
+		}
+	}
+	return fmt.Errorf("retention_days must be one of %v", appInsightsRetention)
+}
+
+var budgetTimeGrains = []string{"Monthly", "Quarterly", "Annually"}
+
+const budgetDateLayout = "2006-01-02"
+
+func prepareAzureBudget(b, _ *sharedmodels.AzureBudget) error {
+	switch {
+	case b.Name == "":
+		return errors.New("name is required")
+	case b.ResourceGroup == "":
+		return errors.New("resource_group is required")
+	case b.Amount <= 0:
+		return errors.New("amount must be positive")
+	}
+	if b.TimeGrain == "" {
+		b.TimeGrain = budgetTimeGrains[0]
+	}
+	if !containsString(budgetTimeGrains, b.TimeGrain) {
+		return fmt.Errorf("time_grain must be one of %v", budgetTimeGrains)
+	}
+	// Budgets start on the first of a month, by default the current one
+	if b.StartDate == "" {
+		now := time.Now().UTC()
+		b.StartDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format(budgetDateLayout)
+	}
+	start, err := time.Parse(budgetDateLayout, b.StartDate)
+	if err != nil || start.Day() != 1 {
+		return fmt.Errorf("start_date must be the first day of a month (YYYY-MM-01)")
+	}
+	if b.EndDate != "" {
+		end, err := time.Parse(budgetDateLayout, b.EndDate)
+		if err != nil {
+			return fmt.Errorf("end_date must be a date (YYYY-MM-DD)")
+		}
+		if !end.After(start) {
+			return errors.New("end_date must be after start_date")
+		}
+	}
+	return nil
+}
+
+func requireAzureLocation(name, resourceGroup, location string) error {
+	switch {
+	case name == "":
+		return errors.New("name is required")
+	case resourceGroup == "":
+		return errors.New("resource_group is required")
+	case location == "":
+		return errors.New("location is required")
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+func TestAzureLogAnalyticsWorkspaces(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1/azure/loganalytics"
+
+	var created sharedmodels.LogAnalyticsWorkspace
+	status := doJSON(t, http.MethodPost, base, map[string]interface{}{"name": "logs", "resource_group": "rg-1", "location": "westeurope"}, &created)
+	if status != http.StatusCreated || created.ID == "" || created.CustomerID == "" || created.Sku != "PerGB2018" || created.RetentionDays != 30 {
+		t.Fatalf("create workspace: status %d, %+v", status, created)
+	}
+	for name, body := range map[string]map[string]interface{}{
+		"missing location": {"name": "logs", "resource_group": "rg-1"},
+		"unknown sku":      {"name": "logs", "resource_group": "rg-1", "location": "westeurope", "sku": "Gold"},
+		"short retention":  {"name": "logs", "resource_group": "rg-1", "location": "westeurope", "retention_days": 7},
+	} {
+		if status := doJSON(t, http.MethodPost, base, body, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, status)
+		}
+	}
+	if status := doJSON(t, http.MethodPost, base, map[string]interface{}{"id": created.ID, "name": "logs", "resource_group": "rg-1", "location": "westeurope"}, nil); status != http.StatusConflict {
+		t.Errorf("duplicate ID: status %d, want 409", status)
+	}
+
+	var updated sharedmodels.LogAnalyticsWorkspace
+	status = doJSON(t, http.MethodPut, base+"/"+created.ID, map[string]interface{}{"name": "logs", "resource_group": "rg-1", "location": "westeurope", "retention_days": 90}, &updated)
+	if status != http.StatusOK || updated.RetentionDays != 90 || updated.CustomerID != created.CustomerID || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("update workspace: status %d, %+v", status, updated)
+	}
+
+	var list struct {
+		Workspaces []sharedmodels.LogAnalyticsWorkspace `json:"workspaces"`
+	}
+	if status := doJSON(t, http.MethodGet, base, nil, &list); status != http.StatusOK || len(list.Workspaces) != 1 || list.Workspaces[0].RetentionDays != 90 {
+		t.Errorf("list workspaces: status %d, %+v", status, list)
+	}
+	if status := doJSON(t, http.MethodDelete, base+"/"+created.ID, nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete workspace: status %d", status)
+	}
+	if status := doJSON(t, http.MethodGet, base+"/"+created.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("get deleted workspace: status %d, want 404", status)
+	}
+	if status := doJSON(t, http.MethodPut, base+"/"+created.ID, map[string]interface{}{"name": "logs", "resource_group": "rg-1", "location": "westeurope"}, nil); status != http.StatusNotFound {
+		t.Errorf("update deleted workspace: status %d, want 404", status)
+	}
+}
+
+func TestAzureResources(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1/azure"
+
+	var budget sharedmodels.AzureBudget
+	if status := doJSON(t, http.MethodPost, base+"/budget", map[string]interface{}{"name": "team", "resource_group": "rg-1", "amount": 500}, &budget); status != http.StatusCreated ||
+		budget.TimeGrain != "Monthly" || budget.StartDate == "" {
+		t.Errorf("create budget: status %d, %+v", status, budget)
+	}
+	for name, body := range map[string]map[string]interface{}{
+		"no amount":        {"name": "team", "resource_group": "rg-1"},
+		"unknown grain":    {"name": "team", "resource_group": "rg-1", "amount": 1, "time_grain": "Weekly"},
+		"mid-month start":  {"name": "team", "resource_group": "rg-1", "amount": 1, "start_date": "2025-01-15"},
+		"end before start": {"name": "team", "resource_group": "rg-1", "amount": 1, "start_date": "2025-02-01", "end_date": "2025-01-31"},
+	} {
+		if status := doJSON(t, http.MethodPost, base+"/budget", body, nil); status != http.StatusBadRequest {
+			t.Errorf("budget %s: status %d, want 400", name, status)
+		}
+	}
+
+	var app sharedmodels.AppInsightsResource
+	if status := doJSON(t, http.MethodPost, base+"/appinsights", map[string]interface{}{"name": "web", "resource_group": "rg-1", "location": "westeurope"}, &app); status != http.StatusCreated ||
+		app.AppType != "web" || app.RetentionDays != 90 {
+		t.Errorf("create app insights: status %d, %+v", status, app)
+	}
+	if status := doJSON(t, http.MethodPost, base+"/appinsights", map[string]interface{}{"name": "web", "resource_group": "rg-1", "location": "westeurope", "retention_days": 45}, nil); status != http.StatusBadRequest {
+		t.Errorf("app insights with 45 days retention: status %d, want 400", status)
+	}
+
+	if status := doJSON(t, http.MethodPost, base+"/monitor", map[string]interface{}{"name": "alerts", "config": map[string]interface{}{"severity": 2}}, nil); status != http.StatusCreated {
+		t.Errorf("create monitor: status %d", status)
+	}
+	var aks sharedmodels.AzureKubernetes
+	if status := doJSON(t, http.MethodPost, base+"/kubernetes", map[string]interface{}{"name": "aks"}, &aks); status != http.StatusCreated || aks.ClusterSize != 1 {
+		t.Errorf("create kubernetes: status %d, %+v", status, aks)
+	}
+
+	for path, key := range map[string]string{"budget": "budgets", "appinsights": "app_insights", "monitor": "monitors", "kubernetes": "kubernetes"} {
+		var list map[string][]map[string]interface{}
+		if status := doJSON(t, http.MethodGet, base+"/"+path, nil, &list); status != http.StatusOK || len(list[key]) != 1 {
+			t.Errorf("list %s: status %d, %v", path, status, list)
+		}
+	}
+	if status := doJSON(t, http.MethodDelete, base+"/budget/"+budget.ID, nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete budget: status %d", status)
+	}
+	if status := doJSON(t, http.MethodDelete, base+"/budget/"+budget.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("delete budget twice: status %d, want 404", status)
+	}
+}
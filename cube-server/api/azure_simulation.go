@@ -17,9 +17,9 @@ type AzureHandlers struct {
 	logger    *zap.Logger
 }
 
-func NewAzureHandlers(logger *zap.Logger) *AzureHandlers {
+func NewAzureHandlers(logger *zap.Logger, sim *simulation.SimulationService) *AzureHandlers {
 	return &AzureHandlers{
-		simulator: simulation.NewSimulationService(),
+		simulator: sim,
 		logger:    logger,
 	}
 }
@@ -36,8 +36,10 @@ func (h *AzureHandlers) SimulateAKS(c *gin.Context) {
 	if req.Operation == "" {
 		req.Operation = "create_cluster"
 	}
-	result := h.simulator.SimulateOperation(&req)
-	c.JSON(http.StatusOK, result)
+	result := projectSimulation(c, h.simulator).SimulateOperationContext(c.Request.Context(), &req)
+	if !writeSimulationFault(c, result) {
+		c.JSON(http.StatusOK, result)
+	}
 }
 
 // SimulateAzureBudget handles POST /api/v1/azure/budget/simulate
@@ -51,6 +53,8 @@ func (h *AzureHandlers) SimulateAzureBudget(c *gin.Context) {
 	if req.Operation == "" {
 		req.Operation = "create_budget"
 	}
-	result := h.simulator.SimulateOperation(&req)
-	c.JSON(http.StatusOK, result)
+	result := projectSimulation(c, h.simulator).SimulateOperationContext(c.Request.Context(), &req)
+	if !writeSimulationFault(c, result) {
+		c.JSON(http.StatusOK, result)
+	}
 }
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Requests may reach the server through reverse proxies, which report the client in
+// X-Forwarded-For and X-Forwarded-Proto. Anyone can send those headers, so they are
+// only believed on connections from the proxies configured as api.trusted_proxies;
+// everything else is judged by the connection itself.
+
+// trustedProxies are the addresses of the reverse proxies in front of the server
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies parses IP addresses and CIDR ranges
+func parseTrustedProxies(entries []string) (trustedProxies, error) {
+	proxies := make(trustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy %q is not an IP address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is not an IP address or CIDR range", entry)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// trusts reports whether ip is one of the proxies
+func (p trustedProxies) trusts(ip string) bool {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the address of the connection's peer
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// clientIP returns the address of the client: the connection's peer, or behind trusted
+// proxies the last X-Forwarded-For address that is not one of them
+func (p trustedProxies) clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !p.trusts(ip) {
+		return ip
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !p.trusts(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// secureTransport reports whether the client connected with TLS, to the server or to
+// a trusted proxy
+func (p trustedProxies) secureTransport(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return p.trusts(remoteIP(r)) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"os"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.yaml
+var openapiYAML []byte
+
+// apiSpec is the API contract: the routes are checked against it at startup and every
+// request and response to a documented route is validated against it
+var apiSpec = openapi.MustParse(openapiYAML)
+
+// ValidationMode resolves how strictly requests and responses are checked against the
+// spec: CUBE_SERVER_API_VALIDATION, then api.validation, then strict under gin's test
+// mode and log-only otherwise
+func ValidationMode(cfg *internal.ServerConfig) (openapi.Mode, error) {
+	value := os.Getenv("CUBE_SERVER_API_VALIDATION")
+	if value == "" && cfg != nil {
+		value = cfg.API.Validation
+	}
+	mode, err := openapi.ParseMode(value)
+	if err != nil || mode != "" {
+		return mode, err
+	}
+	if gin.Mode() == gin.TestMode {
+		return openapi.Strict, nil
+	}
+	return openapi.LogOnly, nil
+}
+
+// CheckRoutes fails if a route registered on router has no operation in the spec
+func CheckRoutes(router *gin.Engine) error {
+	return apiSpec.CheckRoutes(router.Routes())
+}
+
+// registerDocs serves the spec and its HTML rendering
+func registerDocs(router *gin.Engine) {
+	specJSON, err := apiSpec.JSON()
+	if err != nil {
+		panic("api: converting openapi.yaml to JSON: " + err.Error())
+	}
+	router.GET("/docs", func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := apiSpec.WriteHTML(c.Writer); err != nil {
+			_ = c.Error(err)
+		}
+	})
+	router.GET("/docs/openapi.yaml", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/yaml", openapiYAML)
+	})
+	router.GET("/docs/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", specJSON)
+	})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/openapi"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+// TestSpecCoversRoutes keeps api/openapi.yaml and the routes in sync both ways: every
+// route is documented and every documented operation is served
+func TestSpecCoversRoutes(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{Enabled: true, Tokens: []auth.StaticToken{{Name: "root", Token: "admin-token", Role: auth.RoleAdmin}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// main.go registers /health before the API routes
+	r.GET("/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "healthy"}) })
+	routes := SetupRoutesWithOptions(r, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: NewTestSimulationService(), Auth: authenticator})
+	t.Cleanup(func() { routes.Close() })
+
+	if missing := apiSpec.Undocumented(r.Routes()); len(missing) > 0 {
+		t.Errorf("routes missing from openapi.yaml:\n%s", strings.Join(missing, "\n"))
+	}
+	if extra := apiSpec.Unrouted(r.Routes()); len(extra) > 0 {
+		t.Errorf("operations in openapi.yaml without a route:\n%s", strings.Join(extra, "\n"))
+	}
+	if err := CheckRoutes(r); err != nil {
+		t.Errorf("CheckRoutes: %v", err)
+	}
+}
+
+func TestDocsEndpoints(t *testing.T) {
+	srv := newClusterTestServer(t)
+
+	for _, tc := range []struct{ path, contentType, contains string }{
+		{"/docs", "text/html", "/api/v1/clusters/{id}/nodepools"},
+		{"/docs/openapi.yaml", "application/yaml", "openapi: 3.0.3"},
+		{"/docs/openapi.json", "application/json", `"openapi":"3.0.3"`},
+	} {
+		resp, err := http.Get(srv.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), tc.contentType) {
+			t.Errorf("GET %s: status %d, content type %q", tc.path, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if !strings.Contains(string(body), tc.contains) {
+			t.Errorf("GET %s does not contain %q", tc.path, tc.contains)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/docs/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var doc struct {
+		Paths map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil || doc.Paths["/api/v1/projects/{project}"] == nil {
+		t.Errorf("openapi.json paths = %v, %v", doc.Paths, err)
+	}
+}
+
+func TestRequestsValidatedAgainstSpec(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1"
+
+	var body map[string]string
+	if status := doJSON(t, http.MethodPost, base+"/executor/azure/aks?dryrun=maybe", map[string]interface{}{}, &body); status != http.StatusBadRequest ||
+		!strings.Contains(body["error"], "query.dryrun") {
+		t.Errorf("invalid query parameter: status %d, %v", status, body)
+	}
+	if status := doJSON(t, http.MethodPost, base+"/clusters", map[string]interface{}{"name": 42}, &body); status != http.StatusBadRequest ||
+		!strings.Contains(body["error"], "body.name") {
+		t.Errorf("invalid body: status %d, %v", status, body)
+	}
+	// The project prefix validates the request under the route it dispatches to
+	if status := doJSON(t, http.MethodPost, base+"/projects/default/clusters", map[string]interface{}{"name": 42}, &body); status != http.StatusBadRequest ||
+		!strings.Contains(body["error"], "body.name") {
+		t.Errorf("invalid body through the project prefix: status %d, %v", status, body)
+	}
+}
+
+func TestValidationMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CUBE_SERVER_API_VALIDATION", "")
+	if mode, err := ValidationMode(nil); mode != openapi.Strict || err != nil {
+		t.Errorf("test mode default = %q, %v", mode, err)
+	}
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.TestMode)
+	if mode, err := ValidationMode(nil); mode != openapi.LogOnly || err != nil {
+		t.Errorf("release mode default = %q, %v", mode, err)
+	}
+	t.Setenv("CUBE_SERVER_API_VALIDATION", "off")
+	if mode, err := ValidationMode(nil); mode != openapi.Off || err != nil {
+		t.Errorf("env override = %q, %v", mode, err)
+	}
+	t.Setenv("CUBE_SERVER_API_VALIDATION", "loud")
+	if _, err := ValidationMode(nil); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
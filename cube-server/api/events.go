@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/events"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// Event stream. GET /api/v1/events pushes test and cluster changes, and those of the
+// simulated buckets and clusters, as Server-Sent Events; the same URL serves JSON
+// messages when the request is a WebSocket upgrade.
+
+const (
+	// eventsKeepAlive is the interval of SSE comments that keep idle proxies from
+	// closing the stream
+	eventsKeepAlive = 15 * time.Second
+	// eventsRetry is the reconnect delay suggested to SSE clients
+	eventsRetry = 2 * time.Second
+)
+
+// StreamEvents handles GET /events?resource=tests&id=<test-id>. Both query parameters
+// are optional; resource is tests, clusters, buckets or simulated_clusters. A client
+// resumes with the Last-Event-ID header (or the last_event_id query parameter for
+// WebSocket clients) and first gets the kept events it missed; Last-Event-ID 0 replays
+// everything still kept. Only events of the request's project are streamed.
+func (h *Handlers) StreamEvents(c *gin.Context) {
+	filter := events.Filter{Project: projectName(c), Resource: c.Query("resource"), ID: c.Query("id")}
+	switch filter.Resource {
+	case "", sharedmodels.EventResourceTests, sharedmodels.EventResourceClusters,
+		sharedmodels.EventResourceBuckets, sharedmodels.EventResourceSimulatedClusters:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown resource %q, expected %s, %s, %s or %s",
+			filter.Resource, sharedmodels.EventResourceTests, sharedmodels.EventResourceClusters,
+			sharedmodels.EventResourceBuckets, sharedmodels.EventResourceSimulatedClusters)})
+		return
+	}
+	if filter.ID != "" && filter.Resource == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id requires resource"})
+		return
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var after uint64
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	sub, replay := h.events.Subscribe(filter, after, lastEventID != "")
+	defer sub.Close()
+	h.logger.Debug("Event subscriber connected",
+		zap.String("resource", filter.Resource),
+		zap.String("id", filter.ID),
+		zap.Uint64("last_event_id", after))
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		streamEventsWebSocket(c, sub, replay)
+		return
+	}
+	streamEventsSSE(c, sub, replay)
+}
+
+func streamEventsSSE(c *gin.Context, sub *events.Subscription, replay []sharedmodels.Event) {
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds())
+	for _, event := range replay {
+		writeSSEEvent(w, event)
+	}
+	w.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and replays
+				return
+			}
+			writeSSEEvent(w, event)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		w.Flush()
+	}
+}
+
+func writeSSEEvent(w io.Writer, event sharedmodels.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+// streamEventsWebSocket sends every event as a JSON text message. Messages from the
+// client are ignored; reading them only detects when it goes away.
+func streamEventsWebSocket(c *gin.Context, sub *events.Subscription, replay []sharedmodels.Event) {
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		gone := make(chan struct{})
+		go func() {
+			_, _ = io.Copy(io.Discard, ws)
+			close(gone)
+		}()
+		for _, event := range replay {
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				return
+			}
+		}
+		for {
+			select {
+			case <-gone:
+				return
+			case event, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if err := websocket.JSON.Send(ws, event); err != nil {
+					return
+				}
+			}
+		}
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// publishClusterEvent reports a cluster change on the event stream.
+func (h *Handlers) publishClusterEvent(c *gin.Context, eventType string, cluster *sharedmodels.Cluster) {
+	data := map[string]interface{}{}
+	if cluster.Status != "" {
+		data["status"] = string(cluster.Status)
+	}
+	if cluster.Provider != "" {
+		data["provider"] = string(cluster.Provider)
+	}
+	if cluster.Name != "" {
+		data["name"] = cluster.Name
+	}
+	h.events.Publish(sharedmodels.Event{
+		Project:    projectName(c),
+		Resource:   sharedmodels.EventResourceClusters,
+		ResourceID: cluster.ID,
+		Type:       eventType,
+		Data:       data,
+	})
+}
+
+// simulationEvents reports the buckets and simulated clusters of sim on the event
+// stream, and completes due cluster transitions every interval so their status events
+// arrive without a client reading the cluster, until stop is called
+func simulationEvents(sim *simulation.SimulationService, broker *events.Broker, interval time.Duration) (stop func()) {
+	sim.SetObserver(func(change simulation.Change) {
+		resource := sharedmodels.EventResourceBuckets
+		if change.Kind == simulation.ChangeCluster {
+			resource = sharedmodels.EventResourceSimulatedClusters
+		}
+		data := map[string]interface{}{"provider": change.Provider, "name": change.Name}
+		if change.Status != "" {
+			data["status"] = change.Status
+		}
+		if change.Region != "" {
+			data["region"] = change.Region
+		}
+		// The change actions are the event types created, status and deleted
+		broker.Publish(sharedmodels.Event{
+			Project:    change.Project,
+			Resource:   resource,
+			ResourceID: change.ID,
+			Type:       change.Action,
+			Data:       data,
+		})
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sim.AdvanceClusters()
+			}
+		}
+	}()
+	return cancel
+}
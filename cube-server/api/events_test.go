@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/events"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"golang.org/x/net/websocket"
+)
+
+func TestEventStream(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Last-Event-ID 0 replays kept events, so the cluster may be created before the stream connects
+	clusterEvents := make(chan sharedmodels.Event, 8)
+	go events.Subscribe(ctx, nil, srv.URL, events.Options{Resource: sharedmodels.EventResourceClusters, LastEventID: "0"}, func(event sharedmodels.Event) error {
+		clusterEvents <- event
+		return nil
+	})
+	if status := doJSON(t, http.MethodPost, base+"/clusters", map[string]interface{}{"id": "hz-ev", "name": "hz-ev", "provider": "hetzner", "location": "fsn1"}, nil); status != http.StatusCreated {
+		t.Fatalf("create cluster: status %d", status)
+	}
+	var created sharedmodels.Event
+	select {
+	case created = <-clusterEvents:
+	case <-ctx.Done():
+		t.Fatal("no cluster event received")
+	}
+	clusterID := created.ResourceID
+	if clusterID != "hz-ev" || created.Type != sharedmodels.EventCreated || created.Data["provider"] != "hetzner" {
+		t.Errorf("cluster created event = %+v", created)
+	}
+
+	var queued sharedmodels.TestResult
+	doJSON(t, http.MethodPost, base+"/clusters/"+clusterID+"/tests", map[string]interface{}{"cluster_id": clusterID, "test_type": "load", "config": map[string]interface{}{"duration": "80ms"}}, &queued)
+
+	var seen []sharedmodels.Event
+	lastID, err := events.Subscribe(ctx, nil, srv.URL, events.Options{Resource: sharedmodels.EventResourceTests, ID: queued.ID, LastEventID: "0"}, func(event sharedmodels.Event) error {
+		seen = append(seen, event)
+		if event.Type == sharedmodels.EventStatus && event.Data["status"] == string(sharedmodels.TestStatusPassed) {
+			return events.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	var types []string
+	for _, event := range seen {
+		if event.ResourceID != queued.ID {
+			t.Errorf("event for another resource: %+v", event)
+		}
+		types = append(types, event.Type)
+		if event.Type == sharedmodels.EventStatus {
+			types[len(types)-1] += ":" + event.Data["status"].(string)
+		}
+	}
+	got := strings.Join(types, " ")
+	for _, want := range []string{"created", "status:running", "log", "progress", "status:passed"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("event types %q lack %q", got, want)
+		}
+	}
+	if lastID != strconv.FormatUint(seen[len(seen)-1].ID, 10) {
+		t.Errorf("Subscribe returned last ID %s, want %d", lastID, seen[len(seen)-1].ID)
+	}
+
+	// Resuming from the middle replays exactly the rest
+	middle := seen[len(seen)/2]
+	var rest []sharedmodels.Event
+	_, err = events.Subscribe(ctx, nil, srv.URL, events.Options{Resource: sharedmodels.EventResourceTests, ID: queued.ID, LastEventID: strconv.FormatUint(middle.ID, 10)}, func(event sharedmodels.Event) error {
+		rest = append(rest, event)
+		if event.ID == seen[len(seen)-1].ID {
+			return events.ErrStop
+		}
+		return nil
+	})
+	if err != nil || len(rest) != len(seen)-len(seen)/2-1 || rest[0].ID <= middle.ID {
+		t.Errorf("resume after %d = %d events, %v; want %d", middle.ID, len(rest), err, len(seen)-len(seen)/2-1)
+	}
+
+	// The same stream over WebSocket
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(base, "http")+"/events?resource=tests&id="+queued.ID+"&last_event_id=0", "", srv.URL)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	defer ws.Close()
+	var first sharedmodels.Event
+	if err := websocket.JSON.Receive(ws, &first); err != nil || first.ID != seen[0].ID || first.Type != sharedmodels.EventCreated {
+		t.Errorf("first websocket event = %+v, %v; want %+v", first, err, seen[0])
+	}
+
+	for _, query := range []string{"?resource=nodes", "?id=x", "?resource=tests&last_event_id=abc"} {
+		if status := doJSON(t, http.MethodGet, base+"/events"+query, nil, nil); status != http.StatusBadRequest {
+			t.Errorf("GET /events%s: status %d, want 400", query, status)
+		}
+	}
+}
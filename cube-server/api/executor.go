@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/executor"
+	"go.uber.org/zap"
+)
+
+// ExecutorHandlers serves /api/v1/executor: real provider operations that only run
+// after their simulation succeeded (see package executor)
+type ExecutorHandlers struct {
+	logger  *zap.Logger
+	service *executor.Service
+}
+
+// NewExecutorHandlers creates the executor handlers on service
+func NewExecutorHandlers(logger *zap.Logger, service *executor.Service) *ExecutorHandlers {
+	return &ExecutorHandlers{logger: logger, service: service}
+}
+
+// executionRequest is the body of the executor endpoints; dryrun and force may also
+// be given as query parameters
+type executionRequest struct {
+	Parameters map[string]interface{} `json:"parameters"`
+	DryRun     bool                   `json:"dryrun"`
+	Force      bool                   `json:"force"`
+}
+
+// ExecuteAKS handles POST /executor/azure/aks
+func (h *ExecutorHandlers) ExecuteAKS(c *gin.Context) {
+	h.execute(c, "azure", executor.OperationCreateCluster)
+}
+
+// ExecuteAzureBudget handles POST /executor/azure/budget
+func (h *ExecutorHandlers) ExecuteAzureBudget(c *gin.Context) {
+	h.execute(c, "azure", executor.OperationCreateBudget)
+}
+
+// ExecuteOperation handles POST /executor/providers/:provider/operations/:operation
+func (h *ExecutorHandlers) ExecuteOperation(c *gin.Context) {
+	h.execute(c, c.Param("provider"), c.Param("operation"))
+}
+
+// ListExecutions handles GET /executor/executions, oldest first unless asked
+// otherwise; see listing.go for the query parameters
+func (h *ExecutorHandlers) ListExecutions(c *gin.Context) {
+	writeListPage(c, "executions", h.service.List(projectName(c)), executionListFields)
+}
+
+var executionListFields = listFields[*executor.Record]{
+	id:        func(r *executor.Record) string { return r.ID },
+	provider:  func(r *executor.Record) string { return r.Provider },
+	status:    func(r *executor.Record) string { return string(r.Status) },
+	createdAt: func(r *executor.Record) time.Time { return r.StartedAt },
+	sort: map[string]func(*executor.Record) string{
+		"operation": func(r *executor.Record) string { return r.Operation },
+	},
+}
+
+// GetExecution handles GET /executor/executions/:id
+func (h *ExecutorHandlers) GetExecution(c *gin.Context) {
+	record, err := h.service.Get(projectName(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// execute answers with the execution record: 200 for dry runs, 201 once the real
+// calls succeeded, 422 when the simulation blocked the request and 502 when the
+// provider failed
+func (h *ExecutorHandlers) execute(c *gin.Context, provider, operation string) {
+	var body executionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body: " + err.Error()})
+			return
+		}
+	}
+	for name, flag := range map[string]*bool{"dryrun": &body.DryRun, "force": &body.Force} {
+		if v := c.Query(name); v != "" {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name + " parameter"})
+				return
+			}
+			*flag = *flag || enabled
+		}
+	}
+
+	record, err := h.service.Execute(c.Request.Context(), executor.Request{
+		Project:    projectName(c),
+		Provider:   provider,
+		Operation:  operation,
+		Parameters: body.Parameters,
+		DryRun:     body.DryRun,
+		Force:      body.Force,
+	})
+	switch {
+	case errors.Is(err, executor.ErrUnknownOperation):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, executor.ErrInvalidRequest):
+		c.JSON(http.StatusBadRequest, record)
+	case errors.Is(err, executor.ErrSimulationFailed):
+		c.JSON(http.StatusUnprocessableEntity, record)
+	case err != nil:
+		h.logger.Warn("Execution failed", zap.String("id", record.ID), zap.String("provider", provider),
+			zap.String("operation", operation), zap.Error(err))
+		c.JSON(http.StatusBadGateway, record)
+	case record.Status == executor.StatusPlanned:
+		c.JSON(http.StatusOK, record)
+	default:
+		h.logger.Info("Execution succeeded", zap.String("id", record.ID), zap.String("provider", provider),
+			zap.String("operation", operation), zap.Bool("forced", record.Forced))
+		c.JSON(http.StatusCreated, record)
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/executor"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+	"go.uber.org/zap"
+)
+
+// fakeClusterBackend stands in for a real provider
+type fakeClusterBackend struct {
+	executed int
+}
+
+func (f *fakeClusterBackend) Plan(req executor.Request) ([]executor.Call, error) {
+	return []executor.Call{{Service: "fake", Method: "create", Target: "clusters/" + req.Parameters["name"].(string)}}, nil
+}
+
+func (f *fakeClusterBackend) Execute(ctx context.Context, req executor.Request) (map[string]interface{}, error) {
+	f.executed++
+	return map[string]interface{}{"cluster_id": "real-1"}, nil
+}
+
+func TestExecutorEndpoints(t *testing.T) {
+	service := executor.New(NewTestSimulationService(), 0)
+	service.RegisterAzure(newAzureProvider(internal.ProxyProvider{}))
+	fake := &fakeClusterBackend{}
+	service.Register("aws", executor.OperationCreateCluster, fake)
+	handlers := NewExecutorHandlers(zap.NewNop(), service)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	exec := r.Group("/api/v1/executor")
+	exec.POST("/azure/aks", handlers.ExecuteAKS)
+	exec.POST("/providers/:provider/operations/:operation", handlers.ExecuteOperation)
+	exec.GET("/executions", handlers.ListExecutions)
+	exec.GET("/executions/:id", handlers.GetExecution)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	base := srv.URL + "/api/v1/executor"
+	execute := func(path string, body interface{}) (int, executor.Record) {
+		var record executor.Record
+		status := doJSON(t, http.MethodPost, base+path, body, &record)
+		return status, record
+	}
+
+	status, record := execute("/providers/aws/operations/create_cluster",
+		map[string]interface{}{"parameters": map[string]interface{}{"name": "eks-1", "node_count": 2}})
+	if status != http.StatusCreated || record.Status != executor.StatusSucceeded || record.Result["cluster_id"] != "real-1" || !record.Simulation.Success {
+		t.Errorf("execute = %d %+v", status, record)
+	}
+
+	// The simulation rejects node_count 0, so the backend is not called without force
+	invalid := map[string]interface{}{"parameters": map[string]interface{}{"name": "eks-2", "node_count": 0}}
+	status, record = execute("/providers/aws/operations/create_cluster", invalid)
+	if status != http.StatusUnprocessableEntity || record.Status != executor.StatusBlocked || fake.executed != 1 {
+		t.Errorf("blocked execute = %d %+v, backend calls %d", status, record, fake.executed)
+	}
+	blockedID := record.ID
+	status, record = execute("/providers/aws/operations/create_cluster?force=true", invalid)
+	if status != http.StatusCreated || !record.Forced || fake.executed != 2 {
+		t.Errorf("forced execute = %d %+v", status, record)
+	}
+
+	// The default Azure backend plans the ARM call; executing it fails because the
+	// shared provider only implements simulation mode
+	aks := map[string]interface{}{"parameters": map[string]interface{}{"name": "aks-1", "resource_group": "rg-test"}, "dryrun": true}
+	status, record = execute("/azure/aks", aks)
+	if status != http.StatusOK || record.Status != executor.StatusPlanned || len(record.Plan) != 1 ||
+		record.Plan[0].Target != "resourceGroups/rg-test/providers/Microsoft.ContainerService/managedClusters/aks-1" {
+		t.Errorf("dry run = %d %+v", status, record)
+	}
+	aks["dryrun"] = false
+	status, record = execute("/azure/aks", aks)
+	if status != http.StatusBadGateway || record.Status != executor.StatusFailed || !strings.Contains(record.Error, "not implemented") {
+		t.Errorf("real AKS execute = %d %+v", status, record)
+	}
+
+	var blocked executor.Record
+	if status := doJSON(t, http.MethodGet, base+"/executions/"+blockedID, nil, &blocked); status != http.StatusOK || blocked.Status != executor.StatusBlocked || blocked.Simulation.Error == "" {
+		t.Errorf("GET blocked execution = %d %+v", status, blocked)
+	}
+	var records struct {
+		Executions []executor.Record `json:"executions"`
+	}
+	if doJSON(t, http.MethodGet, base+"/executions", nil, &records); len(records.Executions) != 5 {
+		t.Errorf("listed %d executions, want 5", len(records.Executions))
+	}
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/providers/gcp/operations/create_cluster", http.StatusNotFound},
+		{http.MethodPost, "/azure/aks?dryrun=maybe", http.StatusBadRequest},
+		{http.MethodGet, "/executions/exec-999", http.StatusNotFound},
+	} {
+		if status := doJSON(t, tc.method, base+tc.path, nil, nil); status != tc.want {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.path, status, tc.want)
+		}
+	}
+}
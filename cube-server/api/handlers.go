@@ -1,11 +1,14 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/events"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/testrunner"
 	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
 	store "github.com/tronicum/punchbag-cube-testsuite/store"
 
@@ -15,18 +18,31 @@ import (
 
 // Handlers contains the HTTP handlers for the API
 type Handlers struct {
-	store  store.Store
-	logger *zap.Logger
+	store    store.Store
+	logger   *zap.Logger
+	executor *testrunner.Executor
+	events   *events.Broker
+	// locks serializes the writes of clusters and node pools, and each project's quota
+	// checks with the creations they allow (see preconditions.go)
+	locks resourceLocks
 }
 
-// NewHandlers creates a new Handlers instance
-func NewHandlers(store store.Store, logger *zap.Logger) *Handlers {
+// NewHandlers creates a new Handlers instance; tests run on executor and resource
+// changes are published to broker
+func NewHandlers(store store.Store, logger *zap.Logger, executor *testrunner.Executor, broker *events.Broker) *Handlers {
 	return &Handlers{
-		store:  store,
-		logger: logger,
+		store:    store,
+		logger:   logger,
+		executor: executor,
+		events:   broker,
 	}
 }
 
+// projectStore returns the store of the request's project
+func (h *Handlers) projectStore(c *gin.Context) store.Store {
+	return h.store.Project(projectName(c))
+}
+
 // CreateCluster handles POST /clusters
 func (h *Handlers) CreateCluster(c *gin.Context) {
 	var cluster sharedmodels.Cluster
@@ -53,9 +69,20 @@ func (h *Handlers) CreateCluster(c *gin.Context) {
 		return
 	}
 
-	_, err := h.store.CreateCluster(&cluster)
+	defer h.locks.lock(resourceKey(c, "quota"))()
+	existing, err := h.projectStore(c).ListClusters()
 	if err != nil {
-		if err != nil && (err.Error() == "cluster already exists" || err.Error() == "already exists") {
+		h.logger.Error("Failed to list clusters", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if overQuota(c, "clusters", currentProject(c).Quota.Clusters, len(existing)) {
+		return
+	}
+
+	_, err = h.projectStore(c).CreateCluster(&cluster)
+	if err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
 			c.JSON(http.StatusConflict, gin.H{"error": "cluster already exists"})
 			return
 		}
@@ -64,44 +91,67 @@ func (h *Handlers) CreateCluster(c *gin.Context) {
 		return
 	}
 
+	h.publishClusterEvent(c, sharedmodels.EventCreated, &cluster)
 	h.logger.Info("Cluster created", zap.String("id", cluster.ID), zap.String("provider", string(cluster.Provider)))
+	setETag(c, cluster.ResourceVersion)
 	c.JSON(http.StatusCreated, cluster)
 }
 
 // GetCluster handles GET /clusters/:id
 func (h *Handlers) GetCluster(c *gin.Context) {
 	id := c.Param("id")
-	cluster, err := h.store.GetCluster(id)
+	cluster, ok := h.lookupCluster(c, id)
+	if !ok {
+		return
+	}
+
+	setETag(c, cluster.ResourceVersion)
+	c.JSON(http.StatusOK, cluster)
+}
+
+// lookupCluster answers 404 when the cluster does not exist
+func (h *Handlers) lookupCluster(c *gin.Context, id string) (*sharedmodels.Cluster, bool) {
+	cluster, err := h.projectStore(c).GetCluster(id)
 	if err != nil {
-		if err != nil && (err.Error() == "cluster not found" || err.Error() == "not found") {
+		if errors.Is(err, store.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
-			return
+			return nil, false
 		}
 		h.logger.Error("Failed to get cluster", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-		return
+		return nil, false
 	}
-
-	c.JSON(http.StatusOK, cluster)
+	return cluster, true
 }
 
-// ListClusters handles GET /clusters
+// ListClusters handles GET /clusters; see listing.go for the query parameters
 func (h *Handlers) ListClusters(c *gin.Context) {
-	provider := c.Query("provider")
-	var clusters []*sharedmodels.Cluster
-	var err error
-	if provider != "" {
-		cloudProvider := sharedmodels.CloudProvider(provider)
-		clusters, err = h.store.ListClustersByProvider(cloudProvider)
-	} else {
-		clusters, err = h.store.ListClusters()
-	}
+	clusters, err := h.projectStore(c).ListClusters()
 	if err != nil {
 		h.logger.Error("Failed to list clusters", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"clusters": clusters})
+	writeListPage(c, "clusters", clusters, clusterListFields)
+}
+
+// clusterListFields filters and sorts clusters; region falls back to the location
+var clusterListFields = listFields[*sharedmodels.Cluster]{
+	id:       func(c *sharedmodels.Cluster) string { return c.ID },
+	name:     func(c *sharedmodels.Cluster) string { return c.Name },
+	provider: func(c *sharedmodels.Cluster) string { return string(c.Provider) },
+	status:   func(c *sharedmodels.Cluster) string { return string(c.Status) },
+	region: func(c *sharedmodels.Cluster) string {
+		if c.Region != "" {
+			return c.Region
+		}
+		return c.Location
+	},
+	labels:    func(c *sharedmodels.Cluster) map[string]string { return c.Labels },
+	createdAt: func(c *sharedmodels.Cluster) time.Time { return c.CreatedAt },
+	sort: map[string]func(*sharedmodels.Cluster) string{
+		"updated_at": func(c *sharedmodels.Cluster) string { return timeSortKey(c.UpdatedAt) },
+	},
 }
 
 // UpdateCluster handles PUT /clusters/:id
@@ -121,9 +171,15 @@ func (h *Handlers) UpdateCluster(c *gin.Context) {
 		return
 	}
 
-	_, err := h.store.UpdateCluster(id, &cluster)
+	defer h.locks.lock(resourceKey(c, "clusters/"+id))()
+	current, ok := h.lookupCluster(c, id)
+	if !ok || !precondition(c, current.ResourceVersion) {
+		return
+	}
+
+	_, err := h.projectStore(c).UpdateCluster(id, &cluster)
 	if err != nil {
-		if err != nil && (err.Error() == "cluster not found" || err.Error() == "not found") {
+		if errors.Is(err, store.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
 			return
 		}
@@ -132,16 +188,24 @@ func (h *Handlers) UpdateCluster(c *gin.Context) {
 		return
 	}
 
+	h.publishClusterEvent(c, sharedmodels.EventUpdated, &cluster)
 	h.logger.Info("Cluster updated", zap.String("id", id), zap.String("provider", string(cluster.Provider)))
+	setETag(c, cluster.ResourceVersion)
 	c.JSON(http.StatusOK, cluster)
 }
 
 // DeleteCluster handles DELETE /clusters/:id
 func (h *Handlers) DeleteCluster(c *gin.Context) {
 	id := c.Param("id")
-	err := h.store.DeleteCluster(id)
+	defer h.locks.lock(resourceKey(c, "clusters/"+id))()
+	current, ok := h.lookupCluster(c, id)
+	if !ok || !precondition(c, current.ResourceVersion) {
+		return
+	}
+
+	err := h.projectStore(c).DeleteCluster(id)
 	if err != nil {
-		if err != nil && (err.Error() == "cluster not found" || err.Error() == "not found") {
+		if errors.Is(err, store.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
 			return
 		}
@@ -150,11 +214,13 @@ func (h *Handlers) DeleteCluster(c *gin.Context) {
 		return
 	}
 
+	h.publishClusterEvent(c, sharedmodels.EventDeleted, &sharedmodels.Cluster{ID: id})
 	h.logger.Info("Cluster deleted", zap.String("id", id))
 	c.JSON(http.StatusNoContent, nil)
 }
 
-// RunTest handles POST /clusters/:id/tests
+// RunTest handles POST /clusters/:id/tests. The test is queued on the executor and
+// answered with its pending result; poll GET /tests/:id for progress.
 func (h *Handlers) RunTest(c *gin.Context) {
 	clusterID := c.Param("id")
 	var testReq sharedmodels.TestRequest
@@ -165,9 +231,9 @@ func (h *Handlers) RunTest(c *gin.Context) {
 	}
 
 	// Verify cluster exists
-	cluster, err := h.store.GetCluster(clusterID)
+	cluster, err := h.projectStore(c).GetCluster(clusterID)
 	if err != nil {
-		if err != nil && (err.Error() == "cluster not found" || err.Error() == "not found") {
+		if errors.Is(err, store.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
 			return
 		}
@@ -176,46 +242,37 @@ func (h *Handlers) RunTest(c *gin.Context) {
 		return
 	}
 
-	// Create test result
-	testResult := &sharedmodels.TestResult{
-		ID:        generateID(),
-		ClusterID: clusterID,
-		TestType:  testReq.TestType,
-		Status:    "running",
-		Details:   testReq.Config,
-	}
-
-	// Add provider information to test details
-	if testResult.Details == nil {
-		testResult.Details = make(map[string]interface{})
-	}
-	testResult.Details["provider"] = string(cluster.Provider)
-	testResult.Details["cluster_name"] = cluster.Name
-
-	_, err = h.store.CreateTestResult(testResult)
-	if err != nil {
+	testResult, err := h.executor.Submit(projectName(c), cluster, testReq.TestType, testReq.Config)
+	switch {
+	case errors.Is(err, testrunner.ErrUnknownTestType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "test_types": h.executor.TestTypes()})
+		return
+	case errors.Is(err, testrunner.ErrInvalidConfig):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, testrunner.ErrQueueFull):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		h.logger.Error("Failed to create test result", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
 
-	// In a real implementation, you would start the test asynchronously
-	// For this example, we'll simulate test completion
-	go h.simulateTest(testResult)
-
-	h.logger.Info("Test started",
+	h.logger.Info("Test queued",
 		zap.String("test_id", testResult.ID),
 		zap.String("cluster_id", clusterID),
 		zap.String("provider", string(cluster.Provider)))
+	setETag(c, testResult.ResourceVersion)
 	c.JSON(http.StatusAccepted, testResult)
 }
 
 // GetTestResult handles GET /tests/:id
 func (h *Handlers) GetTestResult(c *gin.Context) {
 	id := c.Param("id")
-	result, err := h.store.GetTestResult(id)
+	result, err := h.projectStore(c).GetTestResult(id)
 	if err != nil {
-		if err != nil && (err.Error() == "test result not found" || err.Error() == "not found") {
+		if errors.Is(err, store.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "test result not found"})
 			return
 		}
@@ -224,87 +281,68 @@ func (h *Handlers) GetTestResult(c *gin.Context) {
 		return
 	}
 
+	setETag(c, result.ResourceVersion)
 	c.JSON(http.StatusOK, result)
 }
 
-// ListTestResults handles GET /clusters/:id/tests
+// CancelTest handles DELETE /tests/:id. Pending and running tests are marked
+// cancelled; finished tests are left alone and answered with 409. If-Match is
+// checked before the cancel, but the executor keeps updating running tests, so it
+// does not hold them back meanwhile.
+func (h *Handlers) CancelTest(c *gin.Context) {
+	id := c.Param("id")
+	if c.GetHeader("If-Match") != "" {
+		current, err := h.projectStore(c).GetTestResult(id)
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "test result not found"})
+			return
+		}
+		if err != nil {
+			h.logger.Error("Failed to get test result", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !precondition(c, current.ResourceVersion) {
+			return
+		}
+	}
+	result, err := h.executor.Cancel(projectName(c), id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "test result not found"})
+	case errors.Is(err, testrunner.ErrFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		h.logger.Error("Failed to cancel test", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	default:
+		setETag(c, result.ResourceVersion)
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ListTestResults handles GET /clusters/:id/tests; see listing.go for the query
+// parameters
 func (h *Handlers) ListTestResults(c *gin.Context) {
 	clusterID := c.Param("id")
-	results, err := h.store.ListTestResults(clusterID)
+	results, err := h.projectStore(c).ListTestResults(clusterID)
 	if err != nil {
 		h.logger.Error("Failed to list test results", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"test_results": results})
-}
-
-// simulateTest simulates a test execution and updates the result
-func (h *Handlers) simulateTest(testResult *sharedmodels.TestResult) {
-	time.Sleep(5 * time.Second)
-	now := time.Now()
-	testResult.Status = "completed"
-	testResult.Duration = now.Sub(testResult.StartedAt)
-	testResult.CompletedAt = &now
-	provider := testResult.Details["provider"].(string)
-	testResult.Details["requests_sent"] = 1000
-	testResult.Details["successful_requests"] = 995
-	testResult.Details["failed_requests"] = 5
-	testResult.Details["average_latency_ms"] = 45.2
-	testResult.Details["p95_latency_ms"] = 89.7
-	testResult.Details["p99_latency_ms"] = 156.3
-	testResult.Details["provider_specific"] = h.getProviderSpecificMetrics(provider)
-	_, err := h.store.UpdateTestResult(testResult.ID, testResult)
-	if err != nil {
-		h.logger.Error("Failed to update test result", zap.Error(err))
-	} else {
-		h.logger.Info("Test completed", zap.String("test_id", testResult.ID))
-	}
+	writeListPage(c, "test_results", results, testResultListFields)
 }
 
-func (h *Handlers) getProviderSpecificMetrics(provider string) map[string]interface{} {
-	switch provider {
-	case string(sharedmodels.CloudProviderStackIT):
-		return map[string]interface{}{
-			"stackit_specific_metric": "sample_value",
-			"project_usage":           "normal",
-			"hibernation_supported":   true,
-		}
-	case string(sharedmodels.CloudProviderHetzner):
-		return map[string]interface{}{
-			"hetzner_specific_metric": "sample_value",
-			"network_zone":            "eu-central",
-			"load_balancer_type":      "lb11",
-			"server_type":             "cx21",
-		}
-	case string(sharedmodels.CloudProviderIONOS):
-		return map[string]interface{}{
-			"ionos_specific_metric": "sample_value",
-			"datacenter_location":   "de/fra",
-			"k8s_cluster_type":      "managed",
-			"maintenance_window":    "automatic",
-		}
-	case string(sharedmodels.CloudProviderAzure):
-		return map[string]interface{}{
-			"azure_specific_metric":   "sample_value",
-			"aks_version":             "1.28.0",
-			"resource_group_location": "eastus",
-		}
-	case string(sharedmodels.CloudProviderAWS):
-		return map[string]interface{}{
-			"aws_specific_metric": "sample_value",
-			"eks_version":         "1.28",
-			"vpc_configuration":   "standard",
-		}
-	case string(sharedmodels.CloudProviderGCP):
-		return map[string]interface{}{
-			"gcp_specific_metric": "sample_value",
-			"gke_version":         "1.28.0",
-			"autopilot_enabled":   false,
-		}
-	default:
-		return map[string]interface{}{}
-	}
+// testResultListFields filters and sorts test results; they were created when started
+var testResultListFields = listFields[*sharedmodels.TestResult]{
+	id:        func(r *sharedmodels.TestResult) string { return r.ID },
+	status:    func(r *sharedmodels.TestResult) string { return string(r.Status) },
+	createdAt: func(r *sharedmodels.TestResult) time.Time { return r.StartedAt },
+	sort: map[string]func(*sharedmodels.TestResult) string{
+		"test_type":  func(r *sharedmodels.TestResult) string { return r.TestType },
+		"started_at": func(r *sharedmodels.TestResult) string { return timeSortKey(r.StartedAt) },
+	},
 }
 
 func (h *Handlers) validateClusterByProvider(cluster *sharedmodels.Cluster) error {
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Idempotent POSTs. A POST with an Idempotency-Key header is answered once; repeats
+// with the same key, caller and project within the window get the first answer
+// again, marked with Idempotent-Replayed: true, without running the handler. A
+// repeat with another method, path or body gets 422, one while the first is still
+// running 409. Server errors (5xx) and answers over maxAuditBody are not kept, so
+// retrying them runs the request again; requests over maxIdempotentBody run as if
+// they had no key.
+
+const (
+	// IdempotencyHeader carries the client's key of a POST
+	IdempotencyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader marks replayed answers
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	// DefaultIdempotencyWindow is how long answers are kept without api.idempotency_window
+	DefaultIdempotencyWindow = 24 * time.Hour
+
+	maxIdempotencyKey  = 255
+	maxIdempotentBody  = 8 << 20
+	idempotencySweepAt = time.Minute
+)
+
+// replayedHeaders are the response headers replays carry besides the body
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// IdempotencyWindow resolves how long idempotent answers are kept:
+// CUBE_SERVER_IDEMPOTENCY_WINDOW, then api.idempotency_window, then
+// DefaultIdempotencyWindow. A negative window turns Idempotency-Key off.
+func IdempotencyWindow(cfg *internal.ServerConfig) (time.Duration, error) {
+	if value := os.Getenv("CUBE_SERVER_IDEMPOTENCY_WINDOW"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil {
+			return DefaultIdempotencyWindow, fmt.Errorf("invalid CUBE_SERVER_IDEMPOTENCY_WINDOW %q: %w", value, err)
+		}
+		return window, nil
+	}
+	if cfg != nil && cfg.API.IdempotencyWindow != 0 {
+		return cfg.API.IdempotencyWindow, nil
+	}
+	return DefaultIdempotencyWindow, nil
+}
+
+// idempotentAnswer is the first answer to a key, or a placeholder while it runs
+type idempotentAnswer struct {
+	// fingerprint identifies the method, path and body of the request
+	fingerprint string
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyCache keeps the answers by project, caller and key
+type idempotencyCache struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	answers   map[string]*idempotentAnswer
+	lastSweep time.Time
+}
+
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	return &idempotencyCache{window: window, now: time.Now, answers: make(map[string]*idempotentAnswer)}
+}
+
+// begin returns the kept answer of scope, or nil after reserving scope for the
+// request; expired answers are dropped on the way
+func (ic *idempotencyCache) begin(scope, fingerprint string) *idempotentAnswer {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	now := ic.now()
+	if now.Sub(ic.lastSweep) >= idempotencySweepAt {
+		for key, answer := range ic.answers {
+			if answer.done && now.After(answer.expires) {
+				delete(ic.answers, key)
+			}
+		}
+		ic.lastSweep = now
+	}
+	if answer := ic.answers[scope]; answer != nil && (!answer.done || !now.After(answer.expires)) {
+		return answer
+	}
+	ic.answers[scope] = &idempotentAnswer{fingerprint: fingerprint}
+	return nil
+}
+
+// finish keeps the answer of scope, or forgets scope when the answer is not kept
+func (ic *idempotencyCache) finish(scope string, answer *idempotentAnswer) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	if answer == nil {
+		delete(ic.answers, scope)
+		return
+	}
+	answer.done = true
+	answer.expires = ic.now().Add(ic.window)
+	ic.answers[scope] = answer
+}
+
+func (ic *idempotencyCache) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" || c.Request.Method != http.MethodPost || ic.window < 0 {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is longer than %d characters", IdempotencyHeader, maxIdempotencyKey)})
+			return
+		}
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentBody+1))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "reading the request body: " + err.Error()})
+				return
+			}
+			if len(body) > maxIdempotentBody {
+				// Too big to keep a fingerprint of (e.g. a restore); runs as without the key
+				c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+				c.Next()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		actor := anonymousActor
+		if principal := auth.FromContext(c); principal != nil {
+			actor = principal.Subject
+		}
+		scope := projectName(c) + "\x00" + actor + "\x00" + key
+		sum := sha256.Sum256([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\x00" + string(body)))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		if kept := ic.begin(scope, fingerprint); kept != nil {
+			switch {
+			case kept.fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": IdempotencyHeader + " was already used for another request"})
+			case !kept.done:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this " + IdempotencyHeader + " is still running"})
+			default:
+				for name, values := range kept.header {
+					for _, value := range values {
+						c.Writer.Header().Add(name, value)
+					}
+				}
+				c.Header(IdempotencyReplayedHeader, "true")
+				c.Status(kept.status)
+				c.Writer.Write(kept.body)
+				c.Abort()
+			}
+			return
+		}
+
+		w := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			// A panicking handler has no answer to keep
+			if r := recover(); r != nil {
+				ic.finish(scope, nil)
+				panic(r)
+			}
+		}()
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.Status() >= http.StatusInternalServerError || w.tooLarge {
+			ic.finish(scope, nil)
+			return
+		}
+		answer := &idempotentAnswer{fingerprint: fingerprint, status: w.Status(), header: http.Header{}, body: w.body.Bytes()}
+		for _, name := range replayedHeaders {
+			if value := w.Header().Get(name); value != "" {
+				answer.header.Set(name, value)
+			}
+		}
+		ic.finish(scope, answer)
+	}
+}
+
+// readCloser reads from Reader and closes Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,385 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pagination, filtering and sorting of list routes. Every list answers
+//
+//	{"<items>": [...], "next_page_token": "..."}
+//
+// and takes these query parameters:
+//
+//	limit           items per page (default 100, at most 1000)
+//	page_token      next_page_token of the previous page; empty on the last page
+//	provider        comma-separated values, any of which matches
+//	status          comma-separated values, any of which matches
+//	region          comma-separated values, any of which matches
+//	label_selector  env=prod,tier!=db,team,!legacy: all requirements match
+//	created_after   RFC 3339 time; only items created after it
+//	sort_by         a field of the list (default: its name, or when it was created)
+//	order           asc (default) or desc
+//
+// Filters a list cannot apply are refused with 400, so a typo does not silently
+// return everything. Pages are cut with a cursor on the sort key and ID of the last
+// item, so items created or deleted meanwhile do not shift the following pages.
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listFields describes the items of a list for filters and sorting. Nil fields can
+// neither be filtered nor sorted by; id is required.
+type listFields[T any] struct {
+	id        func(T) string
+	name      func(T) string
+	provider  func(T) string
+	status    func(T) string
+	region    func(T) string
+	labels    func(T) map[string]string
+	createdAt func(T) time.Time
+	// sort adds sort_by fields beyond id, name, provider, status, region and created_at
+	sort map[string]func(T) string
+	// defaultSort and defaultOrder apply without sort_by and order (default: name if
+	// the items have one, else created_at; ascending)
+	defaultSort  string
+	defaultOrder string
+}
+
+// listQuery is a parsed list request
+type listQuery struct {
+	limit        int
+	pageToken    string
+	provider     []string
+	status       []string
+	region       []string
+	labels       []labelRequirement
+	createdAfter time.Time
+	sortBy       string
+	desc         bool
+}
+
+// pageCursor is the decoded page_token: the sort key and ID of the last item of the
+// previous page, and a digest of the filters and sort order it was issued for
+type pageCursor struct {
+	Key   string `json:"k"`
+	ID    string `json:"id"`
+	Query string `json:"q"`
+}
+
+// labelRequirement is one term of a label selector
+type labelRequirement struct {
+	key    string
+	value  string
+	op     string // "=", "!=", "exists" or "!exists"
+	source string
+}
+
+func (r labelRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+	switch r.op {
+	case "=":
+		return ok && value == r.value
+	case "!=":
+		return !ok || value != r.value
+	case "exists":
+		return ok
+	default:
+		return !ok
+	}
+}
+
+// parseLabelSelector reads env=prod,tier!=db,team,!legacy
+func parseLabelSelector(selector string) ([]labelRequirement, error) {
+	var requirements []labelRequirement
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		r := labelRequirement{source: term}
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2)
+			r.key, r.value, r.op = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), "!="
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(strings.Replace(term, "==", "=", 1), "=", 2)
+			r.key, r.value, r.op = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), "="
+		case strings.HasPrefix(term, "!"):
+			r.key, r.op = strings.TrimSpace(term[1:]), "!exists"
+		default:
+			r.key, r.op = term, "exists"
+		}
+		if r.key == "" {
+			return nil, fmt.Errorf("invalid label_selector term %q: want key=value, key!=value, key or !key", term)
+		}
+		requirements = append(requirements, r)
+	}
+	return requirements, nil
+}
+
+// splitValues reads a comma-separated filter
+func splitValues(v string) []string {
+	var values []string
+	for _, value := range strings.Split(v, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func matchesAny(value string, values []string) bool {
+	for _, v := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortKeys returns the sort_by fields of a list and the key each sorts by
+func (f listFields[T]) sortKeys() map[string]func(T) string {
+	keys := map[string]func(T) string{"id": f.id}
+	for name, value := range map[string]func(T) string{"name": f.name, "provider": f.provider, "status": f.status, "region": f.region} {
+		if value != nil {
+			keys[name] = value
+		}
+	}
+	if f.createdAt != nil {
+		createdAt := f.createdAt
+		keys["created_at"] = func(item T) string { return timeSortKey(createdAt(item)) }
+	}
+	for name, value := range f.sort {
+		keys[name] = value
+	}
+	return keys
+}
+
+// timeSortKey formats t so that keys sort like the times
+func timeSortKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// intSortKey formats n so that keys sort like the numbers (n >= 0)
+func intSortKey(n int) string {
+	return fmt.Sprintf("%020d", n)
+}
+
+// parseListQuery reads the list parameters of c, answering 400 itself
+func parseListQuery[T any](c *gin.Context, fields listFields[T]) (*listQuery, bool) {
+	q := &listQuery{limit: defaultListLimit, pageToken: c.Query("page_token")}
+	fail := func(format string, args ...interface{}) (*listQuery, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf(format, args...)})
+		return nil, false
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return fail("invalid limit: want 1 to %d", maxListLimit)
+		}
+		q.limit = limit
+	}
+	filters := []struct {
+		name      string
+		supported bool
+		values    *[]string
+	}{
+		{"provider", fields.provider != nil, &q.provider},
+		{"status", fields.status != nil, &q.status},
+		{"region", fields.region != nil, &q.region},
+	}
+	for _, filter := range filters {
+		v := c.Query(filter.name)
+		if v == "" {
+			continue
+		}
+		if !filter.supported {
+			return fail("this list cannot be filtered by %s", filter.name)
+		}
+		*filter.values = splitValues(v)
+	}
+	if v := c.Query("label_selector"); v != "" {
+		if fields.labels == nil {
+			return fail("this list cannot be filtered by label_selector")
+		}
+		requirements, err := parseLabelSelector(v)
+		if err != nil {
+			return fail("%s", err.Error())
+		}
+		q.labels = requirements
+	}
+	if v := c.Query("created_after"); v != "" {
+		if fields.createdAt == nil {
+			return fail("this list cannot be filtered by created_after")
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return fail("invalid created_after: want an RFC 3339 time such as 2026-01-02T15:04:05Z")
+		}
+		q.createdAfter = t
+	}
+
+	keys := fields.sortKeys()
+	q.sortBy = c.Query("sort_by")
+	if q.sortBy == "" {
+		q.sortBy = fields.defaultSort
+	}
+	if q.sortBy == "" {
+		q.sortBy = "created_at"
+		if fields.name != nil {
+			q.sortBy = "name"
+		}
+	}
+	if keys[q.sortBy] == nil {
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fail("invalid sort_by %q: want one of %s", q.sortBy, strings.Join(names, ", "))
+	}
+	order := c.Query("order")
+	if order == "" {
+		order = fields.defaultOrder
+	}
+	switch order {
+	case "", "asc":
+	case "desc":
+		q.desc = true
+	default:
+		return fail("invalid order %q: want asc or desc", order)
+	}
+	return q, true
+}
+
+// digest identifies the filters and sort order of q, so a page token is only
+// accepted for the query it was issued for
+func (q *listQuery) digest(c *gin.Context) string {
+	var b strings.Builder
+	b.WriteString(c.FullPath())
+	for _, v := range [][]string{q.provider, q.status, q.region} {
+		b.WriteString("|" + strings.ToLower(strings.Join(v, ",")))
+	}
+	for _, r := range q.labels {
+		b.WriteString("|" + r.source)
+	}
+	if !q.createdAfter.IsZero() {
+		b.WriteString("|" + timeSortKey(q.createdAfter))
+	}
+	fmt.Fprintf(&b, "|%s|%t", q.sortBy, q.desc)
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// listPage filters, sorts and pages items by the query of c. It returns the page and
+// the token of the next one, or answers 400 itself and returns false.
+func listPage[T any](c *gin.Context, items []T, fields listFields[T]) ([]T, string, bool) {
+	q, ok := parseListQuery(c, fields)
+	if !ok {
+		return nil, "", false
+	}
+	digest := q.digest(c)
+	var cursor *pageCursor
+	if q.pageToken != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(q.pageToken)
+		cursor = new(pageCursor)
+		if err == nil {
+			err = json.Unmarshal(raw, cursor)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page_token"})
+			return nil, "", false
+		}
+		if cursor.Query != digest {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page_token belongs to a query with other filters or sort order"})
+			return nil, "", false
+		}
+	}
+
+	type entry struct {
+		item    T
+		key, id string
+	}
+	sortKey := fields.sortKeys()[q.sortBy]
+	entries := make([]entry, 0, len(items))
+	for _, item := range items {
+		if !matchesQuery(q, item, fields) {
+			continue
+		}
+		entries = append(entries, entry{item: item, key: sortKey(item), id: fields.id(item)})
+	}
+	// before reports whether a comes before b in the requested order
+	before := func(aKey, aID, bKey, bID string) bool {
+		if aKey != bKey {
+			return (aKey < bKey) != q.desc
+		}
+		return (aID < bID) != q.desc
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return before(entries[i].key, entries[i].id, entries[j].key, entries[j].id)
+	})
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(entries), func(i int) bool {
+			return before(cursor.Key, cursor.ID, entries[i].key, entries[i].id)
+		})
+	}
+	end := start + q.limit
+	if end > len(entries) {
+		end = len(entries)
+	}
+	page := make([]T, 0, end-start)
+	for _, e := range entries[start:end] {
+		page = append(page, e.item)
+	}
+	next := ""
+	if end < len(entries) {
+		last := entries[end-1]
+		raw, _ := json.Marshal(pageCursor{Key: last.key, ID: last.id, Query: digest})
+		next = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return page, next, true
+}
+
+// matchesQuery applies the filters of q to item
+func matchesQuery[T any](q *listQuery, item T, fields listFields[T]) bool {
+	if len(q.provider) > 0 && !matchesAny(fields.provider(item), q.provider) {
+		return false
+	}
+	if len(q.status) > 0 && !matchesAny(fields.status(item), q.status) {
+		return false
+	}
+	if len(q.region) > 0 && !matchesAny(fields.region(item), q.region) {
+		return false
+	}
+	if len(q.labels) > 0 {
+		labels := fields.labels(item)
+		for _, r := range q.labels {
+			if !r.matches(labels) {
+				return false
+			}
+		}
+	}
+	return q.createdAfter.IsZero() || fields.createdAt(item).After(q.createdAfter)
+}
+
+// writeListPage answers with the page of items the query of c selects, under key
+func writeListPage[T any](c *gin.Context, key string, items []T, fields listFields[T]) {
+	page, next, ok := listPage(c, items, fields)
+	if ok {
+		c.JSON(http.StatusOK, gin.H{key: page, "next_page_token": next})
+	}
+}
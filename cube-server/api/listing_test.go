@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// clusterPage is the answer of GET /clusters
+type clusterPage struct {
+	Clusters []struct {
+		ID string `json:"id"`
+	} `json:"clusters"`
+	NextPageToken string `json:"next_page_token"`
+}
+
+func (p clusterPage) ids() string {
+	ids := make([]string, 0, len(p.Clusters))
+	for _, c := range p.Clusters {
+		ids = append(ids, c.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestListPagingFilteringSorting(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1"
+	for _, cluster := range []map[string]interface{}{
+		{"id": "c-3", "name": "delta", "provider": "hetzner", "location": "fsn1", "status": "running", "labels": map[string]string{"env": "prod", "team": "web"}},
+		{"id": "c-1", "name": "alpha", "provider": "hetzner", "location": "nbg1", "status": "creating", "labels": map[string]string{"env": "dev"}},
+		{"id": "c-5", "name": "echo", "provider": "aws", "region": "eu-west-1", "status": "running", "labels": map[string]string{"env": "prod", "legacy": "yes"}},
+		{"id": "c-2", "name": "bravo", "provider": "aws", "region": "us-east-1", "status": "failed"},
+		{"id": "c-4", "name": "charlie", "provider": "hetzner", "location": "fsn1", "status": "running", "labels": map[string]string{"env": "prod"}},
+	} {
+		if status := doJSON(t, http.MethodPost, base+"/clusters", cluster, nil); status != http.StatusCreated {
+			t.Fatalf("create cluster %v: status %d", cluster["id"], status)
+		}
+	}
+	list := func(query string) (clusterPage, int) {
+		t.Helper()
+		var page clusterPage
+		status := doJSON(t, http.MethodGet, base+"/clusters?"+query, nil, &page)
+		return page, status
+	}
+
+	// Pages of two, by name, until the token runs out
+	var pages []string
+	query := "limit=2"
+	for {
+		page, status := list(query)
+		if status != http.StatusOK {
+			t.Fatalf("list %s: status %d", query, status)
+		}
+		pages = append(pages, page.ids())
+		if page.NextPageToken == "" {
+			break
+		}
+		query = "limit=2&page_token=" + url.QueryEscape(page.NextPageToken)
+	}
+	if got := strings.Join(pages, " | "); got != "c-1,c-2 | c-4,c-3 | c-5" {
+		t.Errorf("pages = %s", got)
+	}
+
+	for _, tc := range []struct{ query, want string }{
+		{"provider=aws", "c-2,c-5"},
+		{"provider=AWS,hetzner&status=running", "c-4,c-3,c-5"},
+		{"region=fsn1", "c-4,c-3"},
+		{"label_selector=" + url.QueryEscape("env=prod,!legacy"), "c-4,c-3"},
+		{"label_selector=" + url.QueryEscape("env!=prod"), "c-1,c-2"},
+		{"label_selector=team", "c-3"},
+		{"created_after=2000-01-01T00:00:00Z&sort_by=id&order=desc", "c-5,c-4,c-3,c-2,c-1"},
+		{"created_after=2999-01-01T00:00:00Z", ""},
+		{"sort_by=status&limit=3", "c-1,c-2,c-3"},
+	} {
+		if page, status := list(tc.query); status != http.StatusOK || page.ids() != tc.want {
+			t.Errorf("%s: status %d, %s, want %s", tc.query, status, page.ids(), tc.want)
+		}
+	}
+
+	// Deleting an item of an earlier page does not shift the following ones
+	first, _ := list("limit=2&sort_by=id")
+	if status := doJSON(t, http.MethodDelete, base+"/clusters/c-1", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete c-1: status %d", status)
+	}
+	if second, status := list("limit=2&sort_by=id&page_token=" + url.QueryEscape(first.NextPageToken)); status != http.StatusOK || second.ids() != "c-3,c-4" {
+		t.Errorf("page after deleting c-1: status %d, %s", status, second.ids())
+	}
+
+	var body map[string]string
+	for _, query := range []string{
+		"limit=0",
+		"limit=1001",
+		"sort_by=color",
+		"order=sideways",
+		"created_after=yesterday",
+		"label_selector=" + url.QueryEscape("=prod"),
+		"page_token=not-a-token",
+		"sort_by=name&page_token=" + url.QueryEscape(first.NextPageToken),
+	} {
+		if status := doJSON(t, http.MethodGet, base+"/clusters?"+query, nil, &body); status != http.StatusBadRequest || body["error"] == "" {
+			t.Errorf("%s: status %d, %v", query, status, body)
+		}
+	}
+	// Filters a list has no field for are refused
+	if status := doJSON(t, http.MethodGet, base+"/clusters/c-3/nodepools?provider=aws", nil, &body); status != http.StatusBadRequest {
+		t.Errorf("node pools by provider: status %d, %v", status, body)
+	}
+	var pools map[string]interface{}
+	if status := doJSON(t, http.MethodGet, base+"/clusters/c-3/nodepools", nil, &pools); status != http.StatusOK || pools["next_page_token"] != "" {
+		t.Errorf("node pools: status %d, %v", status, pools)
+	}
+}
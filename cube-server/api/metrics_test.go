@@ -0,0 +1,83 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/metrics"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+	r := newTestRouter(t, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: NewTestSimulationService()})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	base := srv.URL + "/api/v1"
+
+	if status := doJSON(t, http.MethodPost, base+"/clusters", map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}, nil); status != http.StatusCreated {
+		t.Fatalf("create cluster: status %d", status)
+	}
+	doJSON(t, http.MethodDelete, base+"/tests/missing", nil, nil)
+	var queued sharedmodels.TestResult
+	doJSON(t, http.MethodPost, base+"/clusters/hz-1/tests", map[string]interface{}{"cluster_id": "hz-1", "test_type": "load", "config": map[string]interface{}{"duration": "10ms"}}, &queued)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		var result sharedmodels.TestResult
+		if doJSON(t, http.MethodGet, base+"/tests/"+queued.ID, nil, &result); result.Status == sharedmodels.TestStatusPassed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("test did not pass")
+		}
+	}
+	doJSON(t, http.MethodPost, base+"/simulate/providers/aws/operations/create_bucket", map[string]interface{}{"provider": "aws", "operation": "create_bucket", "parameters": map[string]interface{}{"name": "b1"}}, nil)
+	for _, put := range []struct{ path, data string }{
+		{"/simulate/aws-s3/metrics-bucket", ""},
+		{"/simulate/aws-s3/metrics-bucket/hello.txt", "hello"},
+	} {
+		path := put.path
+		req, _ := http.NewRequest(http.MethodPut, base+path, strings.NewReader(put.data))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("PUT %s: %v %v", path, err, resp)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	body := string(raw)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != metrics.ContentType {
+		t.Fatalf("GET /metrics: status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	for _, want := range []string{
+		`cube_http_requests_total{method="POST",route="/api/v1/clusters",status="201"} 1`,
+		`cube_http_requests_total{method="DELETE",route="/api/v1/tests/:id",status="404"} 1`,
+		`cube_http_request_duration_seconds_count{method="POST",route="/api/v1/clusters",status="201"} 1`,
+		`cube_test_executions_total{type="load",status="pending"} 1`,
+		`cube_test_executions_total{type="load",status="passed"} 1`,
+		`cube_simulation_operations_total{provider="aws",operation="create_bucket",outcome="success"} 1`,
+		`cube_simulation_objects{project="default",provider="aws"} 1`,
+		`cube_simulation_object_bytes{project="default",provider="aws"} 5`,
+		`cube_store_operation_duration_seconds_count{operation="CreateCluster",result="ok"} 1`,
+		`cube_store_operation_duration_seconds_count{operation="GetTestResult",result="error"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	if t.Failed() {
+		t.Log(body)
+	}
+}
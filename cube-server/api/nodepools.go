@@ -0,0 +1,283 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	store "github.com/tronicum/punchbag-cube-testsuite/store"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Node pools belong to a cluster and are managed below /clusters/:id/nodepools.
+// Deleting a cluster deletes its node pools.
+
+// Node pool operating systems
+const (
+	NodePoolOSLinux   = "Linux"
+	NodePoolOSWindows = "Windows"
+)
+
+// NodePoolScaleRequest is the body of PUT /clusters/:id/nodepools/:pool; fields left
+// out keep their current value
+type NodePoolScaleRequest struct {
+	NodeCount   *int  `json:"node_count"`
+	MinNodes    *int  `json:"min_nodes"`
+	MaxNodes    *int  `json:"max_nodes"`
+	AutoScaling *bool `json:"auto_scaling"`
+}
+
+// CreateNodePool handles POST /clusters/:id/nodepools
+func (h *Handlers) CreateNodePool(c *gin.Context) {
+	cluster, ok := h.nodePoolCluster(c)
+	if !ok {
+		return
+	}
+	var pool sharedmodels.NodePool
+	if err := c.ShouldBindJSON(&pool); err != nil {
+		h.logger.Error("Failed to bind node pool data", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pool.ID = ""
+	pool.ClusterID = cluster.ID
+	if pool.OSType == "" {
+		pool.OSType = NodePoolOSLinux
+	}
+	if pool.InstanceType == "" {
+		if types := simulation.InstanceTypes(string(cluster.Provider)); len(types) > 0 {
+			pool.InstanceType = types[0]
+		}
+	}
+	if pool.MinNodes == 0 && pool.MaxNodes == 0 {
+		pool.MinNodes, pool.MaxNodes = pool.NodeCount, pool.NodeCount
+	}
+	if err := validateNodePool(cluster, &pool); err != nil {
+		c.JSON(http.StatusBadRequest, nodePoolError(cluster, err))
+		return
+	}
+
+	defer h.locks.lock(resourceKey(c, "quota"))()
+	existing, err := h.projectStore(c).ListNodePools(cluster.ID)
+	if err != nil {
+		h.logger.Error("Failed to list node pools", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	for _, other := range existing {
+		if other.Name == pool.Name {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("node pool %q already exists", pool.Name)})
+			return
+		}
+	}
+
+	pools, err := nodePoolCount(h.projectStore(c))
+	if err != nil {
+		h.logger.Error("Failed to count node pools", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if overQuota(c, "node_pools", currentProject(c).Quota.NodePools, pools) {
+		return
+	}
+
+	// The store checks the cluster again, since it may have been deleted meanwhile
+	created, err := h.projectStore(c).CreateNodePool(&pool)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to create node pool", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.logger.Info("Node pool created", zap.String("id", created.ID), zap.String("cluster_id", cluster.ID))
+	setETag(c, created.ResourceVersion)
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListNodePools handles GET /clusters/:id/nodepools; see listing.go for the query
+// parameters
+func (h *Handlers) ListNodePools(c *gin.Context) {
+	cluster, ok := h.nodePoolCluster(c)
+	if !ok {
+		return
+	}
+	pools, err := h.projectStore(c).ListNodePools(cluster.ID)
+	if err != nil {
+		h.logger.Error("Failed to list node pools", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	writeListPage(c, "node_pools", pools, nodePoolListFields)
+}
+
+// nodePoolListFields sorts node pools, by name unless asked otherwise
+var nodePoolListFields = listFields[*sharedmodels.NodePool]{
+	id:        func(p *sharedmodels.NodePool) string { return p.ID },
+	name:      func(p *sharedmodels.NodePool) string { return p.Name },
+	createdAt: func(p *sharedmodels.NodePool) time.Time { return p.CreatedAt },
+	sort: map[string]func(*sharedmodels.NodePool) string{
+		"node_count":    func(p *sharedmodels.NodePool) string { return intSortKey(p.NodeCount) },
+		"instance_type": func(p *sharedmodels.NodePool) string { return p.InstanceType },
+		"updated_at":    func(p *sharedmodels.NodePool) string { return timeSortKey(p.UpdatedAt) },
+	},
+}
+
+// GetNodePool handles GET /clusters/:id/nodepools/:pool
+func (h *Handlers) GetNodePool(c *gin.Context) {
+	if _, ok := h.nodePoolCluster(c); !ok {
+		return
+	}
+	if pool, ok := h.nodePool(c); ok {
+		setETag(c, pool.ResourceVersion)
+		c.JSON(http.StatusOK, pool)
+	}
+}
+
+// UpdateNodePool handles PUT /clusters/:id/nodepools/:pool. It scales the pool: only
+// the node counts and autoscaling can change.
+func (h *Handlers) UpdateNodePool(c *gin.Context) {
+	cluster, ok := h.nodePoolCluster(c)
+	if !ok {
+		return
+	}
+	var req NodePoolScaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Failed to bind node pool data", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer h.locks.lock(resourceKey(c, "nodepools/"+c.Param("pool")))()
+	current, ok := h.nodePool(c)
+	if !ok || !precondition(c, current.ResourceVersion) {
+		return
+	}
+
+	// the store may hand out its own copy, so validate a new one
+	pool := *current
+	if req.NodeCount != nil {
+		pool.NodeCount = *req.NodeCount
+	}
+	if req.MinNodes != nil {
+		pool.MinNodes = *req.MinNodes
+	}
+	if req.MaxNodes != nil {
+		pool.MaxNodes = *req.MaxNodes
+	}
+	if req.AutoScaling != nil {
+		pool.AutoScaling = *req.AutoScaling
+	}
+	if err := validateNodePool(cluster, &pool); err != nil {
+		c.JSON(http.StatusBadRequest, nodePoolError(cluster, err))
+		return
+	}
+
+	updated, err := h.projectStore(c).UpdateNodePool(pool.ID, &pool)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node pool not found"})
+			return
+		}
+		h.logger.Error("Failed to update node pool", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.logger.Info("Node pool scaled", zap.String("id", updated.ID), zap.Int("node_count", updated.NodeCount))
+	setETag(c, updated.ResourceVersion)
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteNodePool handles DELETE /clusters/:id/nodepools/:pool
+func (h *Handlers) DeleteNodePool(c *gin.Context) {
+	if _, ok := h.nodePoolCluster(c); !ok {
+		return
+	}
+	defer h.locks.lock(resourceKey(c, "nodepools/"+c.Param("pool")))()
+	pool, ok := h.nodePool(c)
+	if !ok || !precondition(c, pool.ResourceVersion) {
+		return
+	}
+	if err := h.projectStore(c).DeleteNodePool(pool.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.logger.Error("Failed to delete node pool", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.logger.Info("Node pool deleted", zap.String("id", pool.ID), zap.String("cluster_id", pool.ClusterID))
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// nodePoolCluster looks up the cluster of a node pool request and answers 404 when it
+// does not exist
+func (h *Handlers) nodePoolCluster(c *gin.Context) (*sharedmodels.Cluster, bool) {
+	cluster, err := h.projectStore(c).GetCluster(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+			return nil, false
+		}
+		h.logger.Error("Failed to get cluster", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	return cluster, true
+}
+
+// nodePool looks up the node pool of the request; pools of other clusters are not found
+func (h *Handlers) nodePool(c *gin.Context) (*sharedmodels.NodePool, bool) {
+	pool, err := h.projectStore(c).GetNodePool(c.Param("pool"))
+	if err == nil && pool.ClusterID != c.Param("id") {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node pool not found"})
+			return nil, false
+		}
+		h.logger.Error("Failed to get node pool", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	return pool, true
+}
+
+var errInvalidInstanceType = errors.New("invalid instance type")
+
+// validateNodePool checks the sizes of a node pool against each other and the
+// instance type against the catalog of the cluster's provider
+func validateNodePool(cluster *sharedmodels.Cluster, pool *sharedmodels.NodePool) error {
+	switch {
+	case pool.Name == "":
+		return errors.New("name is required")
+	case pool.MinNodes < 0:
+		return errors.New("min_nodes must not be negative")
+	case pool.MaxNodes < 1:
+		return errors.New("max_nodes must be at least 1")
+	case pool.NodeCount < pool.MinNodes || pool.NodeCount > pool.MaxNodes:
+		return fmt.Errorf("node_count %d must be between min_nodes %d and max_nodes %d", pool.NodeCount, pool.MinNodes, pool.MaxNodes)
+	case pool.OSType != NodePoolOSLinux && pool.OSType != NodePoolOSWindows:
+		return fmt.Errorf("os_type must be %s or %s", NodePoolOSLinux, NodePoolOSWindows)
+	case !simulation.ValidInstanceType(string(cluster.Provider), pool.InstanceType):
+		return fmt.Errorf("%w %q for provider %s", errInvalidInstanceType, pool.InstanceType, cluster.Provider)
+	}
+	return nil
+}
+
+// nodePoolError is the 400 body for an invalid node pool; unknown instance types list
+// the valid ones
+func nodePoolError(cluster *sharedmodels.Cluster, err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	if errors.Is(err, errInvalidInstanceType) {
+		body["instance_types"] = simulation.InstanceTypes(string(cluster.Provider))
+	}
+	return body
+}
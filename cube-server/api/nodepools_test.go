@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+func TestNodePools(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1/clusters/hz-1/nodepools"
+	cluster := map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/clusters", cluster, nil); status != http.StatusCreated {
+		t.Fatalf("create cluster: status %d", status)
+	}
+
+	var workers sharedmodels.NodePool
+	status := doJSON(t, http.MethodPost, base, map[string]interface{}{
+		"name": "workers", "node_count": 3, "min_nodes": 1, "max_nodes": 5, "auto_scaling": true, "instance_type": "cx31",
+	}, &workers)
+	if status != http.StatusCreated || workers.ID == "" || workers.ClusterID != "hz-1" || workers.OSType != "Linux" {
+		t.Fatalf("create node pool: status %d, pool %+v", status, workers)
+	}
+	// min and max default to the node count, the instance type to the provider's first
+	var system sharedmodels.NodePool
+	if status := doJSON(t, http.MethodPost, base, map[string]interface{}{"name": "system", "node_count": 2}, &system); status != http.StatusCreated ||
+		system.MinNodes != 2 || system.MaxNodes != 2 || system.InstanceType != "cx11" {
+		t.Errorf("create node pool with defaults: status %d, pool %+v", status, system)
+	}
+
+	for name, body := range map[string]map[string]interface{}{
+		"missing name":          {"node_count": 1},
+		"count above max":       {"name": "big", "node_count": 6, "min_nodes": 1, "max_nodes": 5},
+		"count below min":       {"name": "small", "node_count": 1, "min_nodes": 2, "max_nodes": 5},
+		"no nodes":              {"name": "empty"},
+		"unknown instance type": {"name": "aws-sized", "node_count": 1, "instance_type": "m5.large"},
+		"unknown os":            {"name": "mac", "node_count": 1, "os_type": "Darwin"},
+	} {
+		var body400 map[string]interface{}
+		if status := doJSON(t, http.MethodPost, base, body, &body400); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, status)
+		} else if name == "unknown instance type" && body400["instance_types"] == nil {
+			t.Errorf("%s: answer %v does not list the instance types", name, body400)
+		}
+	}
+	if status := doJSON(t, http.MethodPost, base, map[string]interface{}{"name": "workers", "node_count": 1}, nil); status != http.StatusConflict {
+		t.Errorf("duplicate name: status %d, want 409", status)
+	}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/clusters/missing/nodepools", map[string]interface{}{"name": "x", "node_count": 1}, nil); status != http.StatusNotFound {
+		t.Errorf("node pool of unknown cluster: status %d, want 404", status)
+	}
+
+	var list struct {
+		NodePools []sharedmodels.NodePool `json:"node_pools"`
+	}
+	if status := doJSON(t, http.MethodGet, base, nil, &list); status != http.StatusOK || len(list.NodePools) != 2 || list.NodePools[0].Name != "system" {
+		t.Errorf("list node pools: status %d, %+v", status, list)
+	}
+
+	var scaled sharedmodels.NodePool
+	if status := doJSON(t, http.MethodPut, base+"/"+workers.ID, map[string]interface{}{"node_count": 5}, &scaled); status != http.StatusOK ||
+		scaled.NodeCount != 5 || scaled.MinNodes != 1 || scaled.InstanceType != "cx31" {
+		t.Errorf("scale node pool: status %d, pool %+v", status, scaled)
+	}
+	if status := doJSON(t, http.MethodPut, base+"/"+workers.ID, map[string]interface{}{"node_count": 6}, nil); status != http.StatusBadRequest {
+		t.Errorf("scale above max: status %d, want 400", status)
+	}
+	var fetched sharedmodels.NodePool
+	if status := doJSON(t, http.MethodGet, base+"/"+workers.ID, nil, &fetched); status != http.StatusOK || fetched.NodeCount != 5 {
+		t.Errorf("get node pool after rejected scale: status %d, pool %+v", status, fetched)
+	}
+
+	if status := doJSON(t, http.MethodDelete, base+"/"+system.ID, nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete node pool: status %d", status)
+	}
+	if status := doJSON(t, http.MethodGet, base+"/"+system.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("get deleted node pool: status %d, want 404", status)
+	}
+
+	// Deleting the cluster takes its node pools along
+	if status := doJSON(t, http.MethodDelete, srv.URL+"/api/v1/clusters/hz-1", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete cluster: status %d", status)
+	}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/clusters", cluster, nil); status != http.StatusCreated {
+		t.Fatalf("recreate cluster: status %d", status)
+	}
+	if status := doJSON(t, http.MethodGet, base+"/"+workers.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("node pool of deleted cluster: status %d, want 404", status)
+	}
+}
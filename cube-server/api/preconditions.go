@@ -0,0 +1,99 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Optimistic concurrency. Every stored resource carries a version (ResourceVersion),
+// answered as a strong ETag ("3") by GET, POST and PUT. PUT and DELETE take
+// If-Match with one or more ETags or *: when none matches the current version the
+// request is answered 412 and nothing changes. The writes of a resource are
+// serialized (resourceLocks), so no other request changes it between the check and
+// the write.
+
+// etag formats a resource version as an entity tag
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setETag answers the version of the resource in the ETag header
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", etag(version))
+}
+
+// matchesIfMatch reports whether the If-Match header admits version; weak tags
+// (W/"3") compare by their value
+func matchesIfMatch(header string, version int64) bool {
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// precondition answers 412 itself unless the If-Match header of c admits version;
+// requests without If-Match always pass
+func precondition(c *gin.Context, version int64) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" || matchesIfMatch(header, version) {
+		return true
+	}
+	setETag(c, version)
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error": fmt.Sprintf("resource version is %s, not %s", etag(version), header),
+	})
+	return false
+}
+
+// resourceLocks serializes the writes of each resource, keyed e.g. by project and ID
+// (resourceKey). The zero value is ready to use.
+type resourceLocks struct {
+	mu   sync.Mutex
+	held map[string]*resourceLock
+}
+
+type resourceLock struct {
+	sync.Mutex
+	// users counts the requests holding or waiting for the lock
+	users int
+}
+
+// resourceKey identifies a resource of the request's project
+func resourceKey(c *gin.Context, id string) string {
+	return projectName(c) + "/" + id
+}
+
+// lock takes the lock of key and returns its release; locks nobody uses any more
+// are dropped
+func (l *resourceLocks) lock(key string) func() {
+	l.mu.Lock()
+	if l.held == nil {
+		l.held = make(map[string]*resourceLock)
+	}
+	lock := l.held[key]
+	if lock == nil {
+		lock = &resourceLock{}
+		l.held[key] = lock
+	}
+	lock.users++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(l.held, key)
+		}
+		l.mu.Unlock()
+	}
+}
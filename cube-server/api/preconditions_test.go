@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// doHeaderJSON sends body as JSON with the given request headers and returns the
+// status, the response headers and the raw response body.
+func doHeaderJSON(t *testing.T, method, url string, header map[string]string, body interface{}) (int, http.Header, []byte) {
+	t.Helper()
+	var raw []byte
+	if body != nil {
+		raw, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, url, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header, data
+}
+
+func TestMatchesIfMatch(t *testing.T) {
+	for header, want := range map[string]bool{
+		`"3"`:          true,
+		`W/"3"`:        true,
+		`"1", "3"`:     true,
+		`*`:            true,
+		`"2"`:          false,
+		`3`:            false,
+		`"2",W/"4"`:    false,
+		`"1" , W/"3" `: true,
+	} {
+		if got := matchesIfMatch(header, 3); got != want {
+			t.Errorf("matchesIfMatch(%q, 3) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestClusterPreconditions(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1/clusters"
+	cluster := map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}
+
+	status, header, _ := doHeaderJSON(t, http.MethodPost, base, nil, cluster)
+	if status != http.StatusCreated || header.Get("ETag") != `"1"` {
+		t.Fatalf("create: status %d, ETag %q", status, header.Get("ETag"))
+	}
+	if status, header, _ := doHeaderJSON(t, http.MethodGet, base+"/hz-1", nil, nil); status != http.StatusOK || header.Get("ETag") != `"1"` {
+		t.Errorf("get: status %d, ETag %q", status, header.Get("ETag"))
+	}
+
+	// A stale version changes nothing and tells the current one
+	cluster["location"] = "nbg1"
+	status, header, _ = doHeaderJSON(t, http.MethodPut, base+"/hz-1", map[string]string{"If-Match": `"5"`}, cluster)
+	if status != http.StatusPreconditionFailed || header.Get("ETag") != `"1"` {
+		t.Errorf("stale update: status %d, ETag %q", status, header.Get("ETag"))
+	}
+	var stored sharedmodels.Cluster
+	if doJSON(t, http.MethodGet, base+"/hz-1", nil, &stored); stored.Location != "fsn1" || stored.ResourceVersion != 1 {
+		t.Errorf("after stale update: %+v", stored)
+	}
+
+	var updated sharedmodels.Cluster
+	status, header, raw := doHeaderJSON(t, http.MethodPut, base+"/hz-1", map[string]string{"If-Match": `"1"`}, cluster)
+	if err := json.Unmarshal(raw, &updated); err != nil || status != http.StatusOK || header.Get("ETag") != `"2"` || updated.ResourceVersion != 2 || updated.Location != "nbg1" {
+		t.Errorf("update: status %d, ETag %q, %+v", status, header.Get("ETag"), updated)
+	}
+	if status, header, _ := doHeaderJSON(t, http.MethodPut, base+"/hz-1", map[string]string{"If-Match": "*"}, cluster); status != http.StatusOK || header.Get("ETag") != `"3"` {
+		t.Errorf("update with *: status %d, ETag %q", status, header.Get("ETag"))
+	}
+	if status, header, _ := doHeaderJSON(t, http.MethodPut, base+"/hz-1", nil, cluster); status != http.StatusOK || header.Get("ETag") != `"4"` {
+		t.Errorf("update without If-Match: status %d, ETag %q", status, header.Get("ETag"))
+	}
+
+	if status, _, _ := doHeaderJSON(t, http.MethodDelete, base+"/hz-1", map[string]string{"If-Match": `"3"`}, nil); status != http.StatusPreconditionFailed {
+		t.Errorf("stale delete: status %d, want 412", status)
+	}
+	if status, _, _ := doHeaderJSON(t, http.MethodDelete, base+"/hz-1", map[string]string{"If-Match": `"4"`}, nil); status != http.StatusNoContent {
+		t.Errorf("delete: status %d, want 204", status)
+	}
+	if status, _, _ := doHeaderJSON(t, http.MethodDelete, base+"/hz-1", map[string]string{"If-Match": "*"}, nil); status != http.StatusNotFound {
+		t.Errorf("delete missing cluster: status %d, want 404", status)
+	}
+}
+
+func TestAzurePreconditions(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1/azure/budget"
+	body := map[string]interface{}{"name": "team", "resource_group": "rg-1", "amount": 500}
+
+	var budget sharedmodels.AzureBudget
+	status, header, raw := doHeaderJSON(t, http.MethodPost, base, nil, body)
+	if err := json.Unmarshal(raw, &budget); err != nil || status != http.StatusCreated || header.Get("ETag") != `"1"` {
+		t.Fatalf("create budget: status %d, ETag %q", status, header.Get("ETag"))
+	}
+	body["amount"] = 800
+	if status, _, _ := doHeaderJSON(t, http.MethodPut, base+"/"+budget.ID, map[string]string{"If-Match": `"2"`}, body); status != http.StatusPreconditionFailed {
+		t.Errorf("stale update: status %d, want 412", status)
+	}
+	if status, header, _ := doHeaderJSON(t, http.MethodPut, base+"/"+budget.ID, map[string]string{"If-Match": `W/"1"`}, body); status != http.StatusOK || header.Get("ETag") != `"2"` {
+		t.Errorf("update: status %d, ETag %q", status, header.Get("ETag"))
+	}
+	if status, _, _ := doHeaderJSON(t, http.MethodDelete, base+"/"+budget.ID, map[string]string{"If-Match": `"1"`}, nil); status != http.StatusPreconditionFailed {
+		t.Errorf("stale delete: status %d, want 412", status)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1/clusters"
+	cluster := map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}
+	key := map[string]string{IdempotencyHeader: "create-hz-1"}
+
+	status, header, first := doHeaderJSON(t, http.MethodPost, base, key, cluster)
+	if status != http.StatusCreated || header.Get(IdempotencyReplayedHeader) != "" {
+		t.Fatalf("create: status %d, %s", status, first)
+	}
+	// The retry gets the first answer instead of 409 for the existing cluster
+	status, header, again := doHeaderJSON(t, http.MethodPost, base, key, cluster)
+	if status != http.StatusCreated || header.Get(IdempotencyReplayedHeader) != "true" || header.Get("ETag") != `"1"` || !bytes.Equal(first, again) {
+		t.Errorf("retry: status %d, headers %v, %s", status, header, again)
+	}
+	var list struct {
+		Clusters []sharedmodels.Cluster `json:"clusters"`
+	}
+	if doJSON(t, http.MethodGet, base, nil, &list); len(list.Clusters) != 1 {
+		t.Errorf("clusters after retry: %+v", list.Clusters)
+	}
+
+	cluster["location"] = "nbg1"
+	if status, _, _ := doHeaderJSON(t, http.MethodPost, base, key, cluster); status != http.StatusUnprocessableEntity {
+		t.Errorf("reused key: status %d, want 422", status)
+	}
+	if status, _, _ := doHeaderJSON(t, http.MethodPost, base, map[string]string{IdempotencyHeader: "other"}, cluster); status != http.StatusConflict {
+		t.Errorf("new key for an existing cluster: status %d, want 409", status)
+	}
+	if status, _, _ := doHeaderJSON(t, http.MethodPost, srv.URL+"/api/v1/projects", key, map[string]interface{}{"name": "team-a"}); status != http.StatusUnprocessableEntity {
+		t.Errorf("reused key on another route: status %d, want 422", status)
+	}
+	// Keys are per project
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/projects", map[string]interface{}{"name": "team-a"}, nil); status != http.StatusCreated {
+		t.Fatalf("create project: status %d", status)
+	}
+	cluster["location"] = "fsn1"
+	status, header, _ = doHeaderJSON(t, http.MethodPost, srv.URL+"/api/v1/projects/team-a/clusters", key, cluster)
+	if status != http.StatusCreated || header.Get(IdempotencyReplayedHeader) != "" {
+		t.Errorf("same key in another project: status %d, headers %v", status, header)
+	}
+}
+
+func TestIdempotencyCacheWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newIdempotencyCache(time.Hour)
+	cache.now = func() time.Time { return now }
+
+	if kept := cache.begin("scope", "a"); kept != nil {
+		t.Fatalf("first begin: %+v", kept)
+	}
+	if kept := cache.begin("scope", "a"); kept == nil || kept.done {
+		t.Errorf("begin while running: %+v", kept)
+	}
+	cache.finish("scope", &idempotentAnswer{fingerprint: "a", status: http.StatusCreated})
+	now = now.Add(59 * time.Minute)
+	if kept := cache.begin("scope", "a"); kept == nil || !kept.done || kept.status != http.StatusCreated {
+		t.Errorf("begin within the window: %+v", kept)
+	}
+	now = now.Add(2 * time.Minute)
+	if kept := cache.begin("scope", "a"); kept != nil {
+		t.Errorf("begin after the window: %+v", kept)
+	}
+	cache.finish("scope", nil)
+	if kept := cache.begin("scope", "b"); kept != nil {
+		t.Errorf("begin after a forgotten answer: %+v", kept)
+	}
+}
+
+func TestIdempotencyWindow(t *testing.T) {
+	t.Setenv("CUBE_SERVER_IDEMPOTENCY_WINDOW", "")
+	if window, err := IdempotencyWindow(nil); err != nil || window != DefaultIdempotencyWindow {
+		t.Errorf("default: %v, %v", window, err)
+	}
+	t.Setenv("CUBE_SERVER_IDEMPOTENCY_WINDOW", "10m")
+	if window, err := IdempotencyWindow(nil); err != nil || window != 10*time.Minute {
+		t.Errorf("from the environment: %v, %v", window, err)
+	}
+	t.Setenv("CUBE_SERVER_IDEMPOTENCY_WINDOW", "soon")
+	if _, err := IdempotencyWindow(nil); err == nil {
+		t.Error("invalid window accepted")
+	}
+
+	// A negative window turns the header off
+	t.Setenv("CUBE_SERVER_IDEMPOTENCY_WINDOW", "-1s")
+	srv := newClusterTestServer(t)
+	cluster := map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}
+	key := map[string]string{IdempotencyHeader: "create-hz-1"}
+	doHeaderJSON(t, http.MethodPost, srv.URL+"/api/v1/clusters", key, cluster)
+	if status, _, _ := doHeaderJSON(t, http.MethodPost, srv.URL+"/api/v1/clusters", key, cluster); status != http.StatusConflict {
+		t.Errorf("retry without idempotency: status %d, want 409", status)
+	}
+}
@@ -0,0 +1,414 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/webhooks"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	store "github.com/tronicum/punchbag-cube-testsuite/store"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Projects (tenants). Every /api/v1 request acts on one project, selected by the
+// X-Cube-Project header or the /api/v1/projects/<project>/... URL prefix, and only
+// sees that project's clusters, test results, node pools, Azure resources and
+// simulated buckets. Without either the request acts on the default project, which
+// always exists and holds everything created before projects did.
+
+// ProjectHeader selects the project of a request
+const ProjectHeader = "X-Cube-Project"
+
+// projectPrefixRoute serves every /api/v1 route under a project's URL prefix
+const projectPrefixRoute = "/api/v1/projects/:project/*path"
+
+const projectKey = "cube.project"
+
+// originalPathKey keeps the path of a request served through the project prefix,
+// which S3 clients signed
+type originalPathKey struct{}
+
+// projectScope resolves the project of every request; projects the caller's token
+// does not allow get 403, unknown ones 404.
+func projectScope(st store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.GetHeader(ProjectHeader)
+		named := name != ""
+		if !named {
+			name = sharedmodels.DefaultProject
+		}
+		if (named || !projectFreeRoutes[c.FullPath()]) && !auth.FromContext(c).AllowsProject(name) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token does not allow project " + name})
+			return
+		}
+		project, err := lookupProject(st, name)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "project not found: " + name})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		c.Set(projectKey, project)
+		c.Next()
+	}
+}
+
+// projectFreeRoutes act on no project, so a token limited to projects may call them
+// without naming one; the project routes check the project they name themselves
+var projectFreeRoutes = map[string]bool{
+	"/api/v1/auth/whoami":       true,
+	"/api/v1/auth/login":        true,
+	"/api/v1/auth/refresh":      true,
+	"/api/v1/projects":          true,
+	"/api/v1/projects/:project": true,
+}
+
+// lookupProject returns the record of a project; the default project exists without
+// one. Without a store there are no other projects.
+func lookupProject(st store.Store, name string) (*sharedmodels.Project, error) {
+	if st == nil {
+		if name == sharedmodels.DefaultProject {
+			return &sharedmodels.Project{Name: name}, nil
+		}
+		return nil, store.ErrNotFound
+	}
+	project, err := st.GetProject(name)
+	if errors.Is(err, store.ErrNotFound) && name == sharedmodels.DefaultProject {
+		return &sharedmodels.Project{Name: name}, nil
+	}
+	return project, err
+}
+
+// currentProject returns the project of the request
+func currentProject(c *gin.Context) *sharedmodels.Project {
+	if v, ok := c.Get(projectKey); ok {
+		return v.(*sharedmodels.Project)
+	}
+	return &sharedmodels.Project{Name: sharedmodels.DefaultProject}
+}
+
+// projectName returns the name of the request's project
+func projectName(c *gin.Context) string {
+	return currentProject(c).Name
+}
+
+// projectSimulation returns the simulation of the request's project
+func projectSimulation(c *gin.Context, sim *simulation.SimulationService) *simulation.SimulationService {
+	if sim == nil {
+		return nil
+	}
+	return sim.Project(projectName(c))
+}
+
+// requestPath returns the path the client sent, before the project prefix was removed
+func requestPath(r *http.Request) string {
+	if path, ok := r.Context().Value(originalPathKey{}).(string); ok {
+		return path
+	}
+	return r.URL.Path
+}
+
+// routeProjectPrefix serves /api/v1/projects/<project>/<path> as /api/v1/<path> with
+// the X-Cube-Project header set. The rewritten request runs through the whole router
+// again, so authentication and role checks apply to the route it names.
+func routeProjectPrefix(router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		project, path := c.Param("project"), c.Param("path")
+		if path == "/" || c.Request.Context().Value(originalPathKey{}) != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		prefix := "/api/v1/projects/" + project
+		r := c.Request.WithContext(context.WithValue(c.Request.Context(), originalPathKey{}, c.Request.URL.Path))
+		r.URL.Path = "/api/v1" + path
+		if raw, ok := strings.CutPrefix(r.URL.RawPath, prefix); ok {
+			r.URL.RawPath = "/api/v1" + raw
+		}
+		r.Header.Set(ProjectHeader, project)
+		c.Request = r
+		router.HandleContext(c)
+		c.Abort()
+	}
+}
+
+// overQuota answers 403 when the project of the request already has limit resources
+// of a kind; a zero limit is unlimited
+func overQuota(c *gin.Context, kind string, limit, used int) bool {
+	if limit <= 0 || used < limit {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   "project quota exceeded",
+		"project": projectName(c),
+		"quota":   kind,
+		"limit":   limit,
+	})
+	return true
+}
+
+// bucketCount returns the number of simulated buckets of a project, all providers
+func bucketCount(sim *simulation.SimulationService) int {
+	count := 0
+	for _, stats := range sim.BucketStore().Stats() {
+		count += stats.Buckets
+	}
+	return count
+}
+
+// nodePoolCount returns the number of node pools over all clusters of a project
+func nodePoolCount(st store.Store) (int, error) {
+	clusters, err := st.ListClusters()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, cluster := range clusters {
+		pools, err := st.ListNodePools(cluster.ID)
+		if err != nil {
+			return 0, err
+		}
+		count += len(pools)
+	}
+	return count, nil
+}
+
+// ProjectHandlers serves /api/v1/projects
+type ProjectHandlers struct {
+	store  store.Store
+	sim    *simulation.SimulationService
+	logger *zap.Logger
+	// webhooks loses the subscriptions of deleted projects; may be nil
+	webhooks *webhooks.Dispatcher
+	// locks serializes the writes of each project record (see preconditions.go)
+	locks resourceLocks
+}
+
+// NewProjectHandlers creates the /api/v1/projects handlers
+func NewProjectHandlers(st store.Store, sim *simulation.SimulationService, logger *zap.Logger) *ProjectHandlers {
+	return &ProjectHandlers{store: st, sim: sim, logger: logger}
+}
+
+// ProjectUsage counts the resources of a project that quotas apply to
+type ProjectUsage struct {
+	Clusters  int `json:"clusters"`
+	NodePools int `json:"node_pools"`
+	Buckets   int `json:"buckets"`
+}
+
+// ProjectStatus is a project with its current usage
+type ProjectStatus struct {
+	*sharedmodels.Project
+	Usage ProjectUsage `json:"usage"`
+}
+
+// CreateProject handles POST /projects
+func (h *ProjectHandlers) CreateProject(c *gin.Context) {
+	var project sharedmodels.Project
+	if err := c.ShouldBindJSON(&project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate(&project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if project.Name == sharedmodels.DefaultProject {
+		c.JSON(http.StatusConflict, gin.H{"error": "project already exists"})
+		return
+	}
+	created, err := h.store.CreateProject(&project)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "project already exists"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to create project", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	h.logger.Info("Project created", zap.String("name", created.Name))
+	setETag(c, created.ResourceVersion)
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListProjects handles GET /projects: the projects the caller's token allows, the
+// default project included even without a record. See listing.go for the query
+// parameters.
+func (h *ProjectHandlers) ListProjects(c *gin.Context) {
+	projects, err := h.store.ListProjects()
+	if err != nil {
+		h.logger.Error("Failed to list projects", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	hasDefault := false
+	for _, project := range projects {
+		hasDefault = hasDefault || project.Name == sharedmodels.DefaultProject
+	}
+	if !hasDefault {
+		projects = append([]*sharedmodels.Project{{Name: sharedmodels.DefaultProject}}, projects...)
+	}
+	principal := auth.FromContext(c)
+	allowed := projects[:0]
+	for _, project := range projects {
+		if principal.AllowsProject(project.Name) {
+			allowed = append(allowed, project)
+		}
+	}
+	projects = allowed
+	writeListPage(c, "projects", projects, projectListFields)
+}
+
+var projectListFields = listFields[*sharedmodels.Project]{
+	id:        func(p *sharedmodels.Project) string { return p.Name },
+	name:      func(p *sharedmodels.Project) string { return p.Name },
+	createdAt: func(p *sharedmodels.Project) time.Time { return p.CreatedAt },
+}
+
+// GetProject handles GET /projects/:project; the project comes with its usage
+func (h *ProjectHandlers) GetProject(c *gin.Context) {
+	if !auth.FromContext(c).AllowsProject(c.Param("project")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "token does not allow project " + c.Param("project")})
+		return
+	}
+	project, err := lookupProject(h.store, c.Param("project"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to get project", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	usage, err := h.usage(project.Name)
+	if err != nil {
+		h.logger.Error("Failed to count project resources", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	setETag(c, project.ResourceVersion)
+	c.JSON(http.StatusOK, ProjectStatus{Project: project, Usage: usage})
+}
+
+// UpdateProject handles PUT /projects/:project: the description and quotas. The
+// default project gets a record on its first update.
+func (h *ProjectHandlers) UpdateProject(c *gin.Context) {
+	var project sharedmodels.Project
+	if err := c.ShouldBindJSON(&project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	project.Name = c.Param("project")
+	if err := h.validate(&project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer h.locks.lock(project.Name)()
+	if !h.projectPrecondition(c, project.Name) {
+		return
+	}
+	updated, err := h.store.UpdateProject(project.Name, &project)
+	if errors.Is(err, store.ErrNotFound) && project.Name == sharedmodels.DefaultProject {
+		updated, err = h.store.CreateProject(&project)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to update project", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	setETag(c, updated.ResourceVersion)
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteProject handles DELETE /projects/:project: the project and all its resources,
+// simulated buckets and webhook subscriptions included. The default project cannot be deleted.
+func (h *ProjectHandlers) DeleteProject(c *gin.Context) {
+	name := c.Param("project")
+	if name == sharedmodels.DefaultProject {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the default project cannot be deleted"})
+		return
+	}
+	defer h.locks.lock(name)()
+	if !h.projectPrecondition(c, name) {
+		return
+	}
+	if err := h.store.DeleteProject(name); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+			return
+		}
+		h.logger.Error("Failed to delete project", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if h.sim != nil {
+		h.sim.DeleteProject(name)
+	}
+	if h.webhooks != nil {
+		h.webhooks.DeleteProject(name)
+	}
+	h.logger.Info("Project deleted", zap.String("name", name))
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// projectPrecondition checks If-Match against the version of a project, answering
+// 404 and 412 itself; the default project has version 0 until its first update
+func (h *ProjectHandlers) projectPrecondition(c *gin.Context, name string) bool {
+	if c.GetHeader("If-Match") == "" {
+		return true
+	}
+	project, err := lookupProject(h.store, name)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return false
+	}
+	if err != nil {
+		h.logger.Error("Failed to get project", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+	return precondition(c, project.ResourceVersion)
+}
+
+func (h *ProjectHandlers) validate(project *sharedmodels.Project) error {
+	if err := sharedmodels.ValidateProjectName(project.Name); err != nil {
+		return err
+	}
+	q := project.Quota
+	if q.Clusters < 0 || q.NodePools < 0 || q.Buckets < 0 {
+		return fmt.Errorf("quotas must not be negative")
+	}
+	return nil
+}
+
+func (h *ProjectHandlers) usage(name string) (ProjectUsage, error) {
+	st := h.store.Project(name)
+	clusters, err := st.ListClusters()
+	if err != nil {
+		return ProjectUsage{}, err
+	}
+	pools, err := nodePoolCount(st)
+	if err != nil {
+		return ProjectUsage{}, err
+	}
+	usage := ProjectUsage{Clusters: len(clusters), NodePools: pools}
+	if h.sim != nil {
+		usage.Buckets = bucketCount(h.sim.Project(name))
+	}
+	return usage, nil
+}
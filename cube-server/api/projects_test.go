@@ -0,0 +1,346 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+func TestProjectCRUD(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1/projects"
+
+	var created sharedmodels.Project
+	status := doJSON(t, http.MethodPost, base, map[string]interface{}{"name": "team-a", "description": "Team A", "quota": map[string]int{"clusters": 2}}, &created)
+	if status != http.StatusCreated || created.Name != "team-a" || created.Quota.Clusters != 2 || created.CreatedAt.IsZero() {
+		t.Fatalf("create project: status %d, %+v", status, created)
+	}
+	for name, body := range map[string]map[string]interface{}{
+		"missing name":   {"description": "x"},
+		"invalid name":   {"name": "Team_A"},
+		"negative quota": {"name": "team-b", "quota": map[string]int{"buckets": -1}},
+	} {
+		if status := doJSON(t, http.MethodPost, base, body, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, status)
+		}
+	}
+	for _, name := range []string{"team-a", sharedmodels.DefaultProject} {
+		if status := doJSON(t, http.MethodPost, base, map[string]interface{}{"name": name}, nil); status != http.StatusConflict {
+			t.Errorf("create %s again: status %d, want 409", name, status)
+		}
+	}
+
+	var list struct {
+		Projects []sharedmodels.Project `json:"projects"`
+	}
+	if status := doJSON(t, http.MethodGet, base, nil, &list); status != http.StatusOK || len(list.Projects) != 2 ||
+		list.Projects[0].Name != sharedmodels.DefaultProject || list.Projects[1].Name != "team-a" {
+		t.Errorf("list projects: status %d, %+v", status, list)
+	}
+
+	var updated sharedmodels.Project
+	if status := doJSON(t, http.MethodPut, base+"/team-a", map[string]interface{}{"description": "Team A, Berlin", "quota": map[string]int{"buckets": 5}}, &updated); status != http.StatusOK ||
+		updated.Description != "Team A, Berlin" || updated.Quota.Buckets != 5 || updated.Quota.Clusters != 0 {
+		t.Errorf("update project: status %d, %+v", status, updated)
+	}
+	if status := doJSON(t, http.MethodPut, base+"/"+sharedmodels.DefaultProject, map[string]interface{}{"quota": map[string]int{"clusters": 10}}, &updated); status != http.StatusOK ||
+		updated.Quota.Clusters != 10 {
+		t.Errorf("update default project: status %d, %+v", status, updated)
+	}
+	if status := doJSON(t, http.MethodPut, base+"/missing", map[string]interface{}{}, nil); status != http.StatusNotFound {
+		t.Errorf("update unknown project: status %d, want 404", status)
+	}
+
+	var got ProjectStatus
+	if status := doJSON(t, http.MethodGet, base+"/team-a", nil, &got); status != http.StatusOK || got.Project == nil || got.Name != "team-a" || got.Usage != (ProjectUsage{}) {
+		t.Errorf("get project: status %d, %+v", status, got)
+	}
+
+	if status := doJSON(t, http.MethodDelete, base+"/"+sharedmodels.DefaultProject, nil, nil); status != http.StatusBadRequest {
+		t.Errorf("delete default project: status %d, want 400", status)
+	}
+	if status := doJSON(t, http.MethodDelete, base+"/team-a", nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete project: status %d, want 204", status)
+	}
+	for _, url := range []string{base + "/team-a", base + "/team-a/clusters"} {
+		if status := doJSON(t, http.MethodGet, url, nil, nil); status != http.StatusNotFound {
+			t.Errorf("GET %s after delete: status %d, want 404", url, status)
+		}
+	}
+}
+
+func TestProjectIsolation(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1"
+	if status := doJSON(t, http.MethodPost, base+"/projects", map[string]interface{}{"name": "team-a"}, nil); status != http.StatusCreated {
+		t.Fatalf("create project: status %d", status)
+	}
+	teamA := base + "/projects/team-a"
+
+	cluster := map[string]interface{}{"id": "hz-1", "name": "shared-id", "provider": "hetzner", "location": "fsn1"}
+	for _, prefix := range []string{base, teamA} {
+		if status := doJSON(t, http.MethodPost, prefix+"/clusters", cluster, nil); status != http.StatusCreated {
+			t.Fatalf("create cluster under %s: status %d", prefix, status)
+		}
+	}
+	if status := doJSON(t, http.MethodPost, teamA+"/clusters", map[string]interface{}{"name": "team-only", "provider": "hetzner", "location": "nbg1"}, nil); status != http.StatusCreated {
+		t.Fatalf("create team cluster: status %d", status)
+	}
+	if status := doJSON(t, http.MethodPost, teamA+"/simulate/providers/aws/buckets", map[string]interface{}{"name": "team-bucket", "region": "eu-west-1"}, nil); status != http.StatusCreated {
+		t.Fatalf("create team bucket: status %d", status)
+	}
+
+	var clusters struct {
+		Clusters []sharedmodels.Cluster `json:"clusters"`
+	}
+	if status := doJSON(t, http.MethodGet, base+"/clusters", nil, &clusters); status != http.StatusOK || len(clusters.Clusters) != 1 {
+		t.Errorf("default clusters: status %d, %+v", status, clusters)
+	}
+	if status := doJSON(t, http.MethodGet, teamA+"/clusters", nil, &clusters); status != http.StatusOK || len(clusters.Clusters) != 2 {
+		t.Errorf("team-a clusters: status %d, %+v", status, clusters)
+	}
+
+	// The header selects the project as well as the prefix does
+	req, _ := http.NewRequest(http.MethodGet, base+"/clusters", nil)
+	req.Header.Set(ProjectHeader, "team-a")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&clusters)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(clusters.Clusters) != 2 {
+		t.Errorf("team-a clusters by header: status %d, %+v", resp.StatusCode, clusters)
+	}
+	req.Header.Set(ProjectHeader, "team-b")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown project header: status %d, want 404", resp.StatusCode)
+	}
+
+	var buckets struct {
+		Buckets []sharedmodels.ObjectStorageBucket `json:"buckets"`
+	}
+	if status := doJSON(t, http.MethodGet, base+"/simulate/providers/aws/buckets", nil, &buckets); status != http.StatusOK || len(buckets.Buckets) != 0 {
+		t.Errorf("default buckets: status %d, %v", status, buckets)
+	}
+	var got ProjectStatus
+	if status := doJSON(t, http.MethodGet, base+"/projects/team-a", nil, &got); status != http.StatusOK ||
+		got.Usage != (ProjectUsage{Clusters: 2, Buckets: 1}) {
+		t.Errorf("team-a usage: status %d, %+v", status, got.Usage)
+	}
+}
+
+func TestProjectQuotas(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1"
+	quota := map[string]int{"clusters": 1, "node_pools": 1, "buckets": 1}
+	if status := doJSON(t, http.MethodPost, base+"/projects", map[string]interface{}{"name": "small", "quota": quota}, nil); status != http.StatusCreated {
+		t.Fatalf("create project: status %d", status)
+	}
+	small := base + "/projects/small"
+
+	for _, step := range []struct {
+		url  string
+		body map[string]interface{}
+	}{
+		{small + "/clusters", map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}},
+		{small + "/clusters/hz-1/nodepools", map[string]interface{}{"name": "workers", "node_count": 1}},
+		{small + "/simulate/providers/aws/buckets", map[string]interface{}{"name": "first-bucket"}},
+	} {
+		if status := doJSON(t, http.MethodPost, step.url, step.body, nil); status != http.StatusCreated {
+			t.Fatalf("POST %s: status %d", step.url, status)
+		}
+		step.body["id"], step.body["name"] = "second", "second"
+		var answer map[string]interface{}
+		if status := doJSON(t, http.MethodPost, step.url, step.body, &answer); status != http.StatusForbidden || answer["error"] != "project quota exceeded" {
+			t.Errorf("POST %s over quota: status %d, %v", step.url, status, answer)
+		}
+	}
+	operation := map[string]interface{}{"provider": "aws", "operation": "create_bucket", "parameters": map[string]interface{}{"name": "third"}}
+	if status := doJSON(t, http.MethodPost, small+"/simulate/providers/aws/operations/create_bucket", operation, nil); status != http.StatusForbidden {
+		t.Errorf("create_bucket operation over quota: status %d, want 403", status)
+	}
+
+	// Other projects are not limited
+	if status := doJSON(t, http.MethodPost, base+"/clusters", map[string]interface{}{"id": "hz-2", "name": "hz-2", "provider": "hetzner", "location": "fsn1"}, nil); status != http.StatusCreated {
+		t.Errorf("create default cluster: status %d", status)
+	}
+}
+
+func TestProjectQuotasConcurrentCreates(t *testing.T) {
+	t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1"
+	if status := doJSON(t, http.MethodPost, base+"/projects", map[string]interface{}{"name": "racy", "quota": map[string]int{"clusters": 1, "buckets": 1}}, nil); status != http.StatusCreated {
+		t.Fatalf("create project: status %d", status)
+	}
+	racy := base + "/projects/racy"
+
+	const parallel = 8
+	for _, target := range []struct {
+		url  string
+		body func(i int) map[string]interface{}
+	}{
+		{racy + "/clusters", func(i int) map[string]interface{} {
+			return map[string]interface{}{"id": fmt.Sprintf("hz-%d", i), "name": fmt.Sprintf("hz-%d", i), "provider": "hetzner", "location": "fsn1"}
+		}},
+		{racy + "/simulate/providers/aws/buckets", func(i int) map[string]interface{} {
+			return map[string]interface{}{"name": fmt.Sprintf("bucket-%d", i)}
+		}},
+	} {
+		statuses := make(chan int, parallel)
+		var wg sync.WaitGroup
+		for i := 0; i < parallel; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				statuses <- doJSON(t, http.MethodPost, target.url, target.body(i), nil)
+			}(i)
+		}
+		wg.Wait()
+		close(statuses)
+		created := 0
+		for status := range statuses {
+			if status == http.StatusCreated {
+				created++
+			} else if status != http.StatusForbidden {
+				t.Errorf("POST %s: status %d", target.url, status)
+			}
+		}
+		if created != 1 {
+			t.Errorf("POST %s: %d created in parallel with a quota of 1", target.url, created)
+		}
+	}
+}
+
+func TestProjectPrefixS3(t *testing.T) {
+	cfg := &internal.ServerConfig{}
+	cfg.Storage.S3.Auth.Enabled = true
+	cfg.Storage.S3.Auth.Credentials = []internal.S3Credential{
+		{AccessKey: testAccessKey, SecretKey: testSecretKey, Projects: []string{"s3-team"}},
+		{AccessKey: "AKIDUNBOUND", SecretKey: "unbound-secret"},
+	}
+	r := newTestRouter(t, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: NewTestSimulationService(), Config: cfg})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/projects", map[string]interface{}{"name": "s3-team", "quota": map[string]int{"buckets": 1}}, nil); status != http.StatusCreated {
+		t.Fatalf("create project: status %d", status)
+	}
+
+	ctx := context.Background()
+	s3Team := s3.New(newSignedS3Client(srv, testAccessKey, testSecretKey).Options(), func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(srv.URL + "/api/v1/projects/s3-team/simulate/aws-s3")
+	})
+	if _, err := s3Team.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("team-bucket")}); err != nil {
+		t.Fatalf("CreateBucket through the project prefix: %v", err)
+	}
+	if _, err := s3Team.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("team-bucket"), Key: aws.String("dir/a file.txt")}); err != nil {
+		t.Fatalf("PutObject through the project prefix: %v", err)
+	}
+	if _, err := s3Team.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("second-bucket")}); s3ErrorCode(err) != S3ErrTooManyBuckets {
+		t.Errorf("CreateBucket over quota: expected %s, got %v", S3ErrTooManyBuckets, err)
+	}
+
+	out, err := newSignedS3Client(srv, testAccessKey, testSecretKey).ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		t.Fatalf("ListBuckets: %v", err)
+	}
+	if len(out.Buckets) != 0 {
+		t.Errorf("default project sees %d buckets of s3-team", len(out.Buckets))
+	}
+
+	// A key not bound to the project only reaches the default project
+	unbound := newSignedS3Client(srv, "AKIDUNBOUND", "unbound-secret")
+	unboundTeam := s3.New(unbound.Options(), func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(srv.URL + "/api/v1/projects/s3-team/simulate/aws-s3")
+	})
+	if _, err := unboundTeam.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("team-bucket")}); s3ErrorCode(err) != S3ErrAccessDenied {
+		t.Errorf("ListObjectsV2 with an unbound key: expected %s, got %v", S3ErrAccessDenied, err)
+	}
+	if _, err := unbound.ListBuckets(ctx, &s3.ListBucketsInput{}); err != nil {
+		t.Errorf("ListBuckets in the default project with an unbound key: %v", err)
+	}
+}
+
+func TestProjectS3NeedsKeyWithAPIAuth(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{Enabled: true, Tokens: []auth.StaticToken{{Name: "root", Token: "admin-token", Role: auth.RoleAdmin}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouter(t, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: NewTestSimulationService(), Auth: authenticator})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	if status := doAuthJSON(t, http.MethodPost, srv.URL+"/api/v1/projects", "admin-token", map[string]string{"name": "s3-team"}, nil); status != http.StatusCreated {
+		t.Fatalf("create project: status %d", status)
+	}
+
+	// Without SigV4 keys no S3 request is bound to a project, so only the default one is open
+	ctx := context.Background()
+	team := s3.New(newS3TestClient(srv, true).Options(), func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(srv.URL + "/api/v1/projects/s3-team/simulate/aws-s3")
+	})
+	if _, err := team.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("team-bucket")}); s3ErrorCode(err) != S3ErrAccessDenied {
+		t.Errorf("CreateBucket in a project: expected %s, got %v", S3ErrAccessDenied, err)
+	}
+	if _, err := newS3TestClient(srv, true).CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("default-bucket")}); err != nil {
+		t.Errorf("CreateBucket in the default project: %v", err)
+	}
+}
+
+func TestProjectLifecycleOnSharedClock(t *testing.T) {
+	t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+	srv := newClusterTestServer(t)
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/projects", map[string]interface{}{"name": "lc-team"}, nil); status != http.StatusCreated {
+		t.Fatalf("create project: status %d", status)
+	}
+	ctx := context.Background()
+	team := s3.New(newS3TestClient(srv, true).Options(), func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(srv.URL + "/api/v1/projects/lc-team/simulate/aws-s3")
+	})
+	bucket := aws.String("team-logs")
+	if _, err := team.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: bucket}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := team.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: aws.String("old.log")}); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := team.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: bucket,
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: []types.LifecycleRule{{
+			ID:         aws.String("expire"),
+			Status:     types.ExpirationStatusEnabled,
+			Filter:     &types.LifecycleRuleFilter{Prefix: aws.String("")},
+			Expiration: &types.LifecycleExpiration{Days: aws.Int32(1)},
+		}}},
+	}); err != nil {
+		t.Fatalf("PutBucketLifecycleConfiguration: %v", err)
+	}
+
+	// the clock is advanced through the default project
+	actions := advanceSimClock(t, srv, 2)
+	if len(actions) != 1 || actions[0]["project"] != "lc-team" || actions[0]["action"] != simulation.LifecycleActionExpiration {
+		t.Fatalf("lifecycle actions = %v", actions)
+	}
+	if _, err := team.HeadObject(ctx, &s3.HeadObjectInput{Bucket: bucket, Key: aws.String("old.log")}); err == nil {
+		t.Error("object of lc-team survived its expiration")
+	}
+}
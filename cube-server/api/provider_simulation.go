@@ -11,15 +11,6 @@ import (
 	"net/http"
 )
 
-// GenericAWSS3SimHandler simulates AWS S3 SDK-compatible endpoints for the provider 'generic-aws-s3'
-func (h *ProviderSimulationHandlers) GenericAWSS3SimHandler(c *gin.Context) {
-	// This is a stub for now. Log the request and return a generic simulated response.
-	path := c.Param("path")
-	method := c.Request.Method
-	h.logger.Info("[GENERIC AWS S3 SIM] Simulating AWS S3 SDK endpoint", zap.String("method", method), zap.String("path", path))
-	c.JSON(200, gin.H{"message": "Simulated AWS S3 SDK endpoint", "method": method, "path": path})
-}
-
 // ...existing code...
 
 // ProviderSimulationHandlers contains simulation endpoints for cloud providers
@@ -27,6 +18,23 @@ type ProviderSimulationHandlers struct {
 	store     store.Store
 	logger    *zap.Logger
 	simulator *simulation.SimulationService
+
+	// S3 wire-protocol settings (see s3_protocol.go)
+	s3VirtualHostDomains []string
+	s3ProviderName       string
+	s3Auth               *s3SigV4Verifier // nil disables SigV4 verification
+	s3Owners             map[string]bool  // access keys exempt from the policy on its own management
+	// s3Projects are the projects other than the default one each access key is bound
+	// to; s3RequireProjectKey applies them even without SigV4, when API tokens are on
+	s3Projects          map[string]map[string]bool
+	s3RequireProjectKey bool
+
+	// trustedProxies may report the client address and TLS (see client_ip.go)
+	trustedProxies trustedProxies
+
+	// locks serializes each project's bucket quota check with the creation it allows
+	// (see preconditions.go)
+	locks resourceLocks
 }
 
 // DeleteSimulatedBucket handles DELETE /api/v1/simulate/providers/:provider/buckets/:bucket
@@ -39,10 +47,10 @@ func (h *ProviderSimulationHandlers) DeleteSimulatedBucket(c *gin.Context) {
 		Operation:  "delete_bucket",
 		Parameters: map[string]interface{}{"bucket": bucket},
 	}
-	result := h.simulator.SimulateOperation(simReq)
+	result := projectSimulation(c, h.simulator).SimulateOperationContext(c.Request.Context(), simReq)
 	if result.Success {
 		c.JSON(http.StatusOK, result.Result)
-	} else {
+	} else if !writeSimulationFault(c, result) {
 		c.JSON(http.StatusNotFound, result)
 	}
 }
@@ -69,24 +77,28 @@ func (h *ProviderSimulationHandlers) CreateSimulatedBucket(c *gin.Context) {
 		return
 	}
 	fmt.Printf("[SERVER DEBUG] Request body: %#v\n", req)
+	defer h.locks.lock(resourceKey(c, "quota"))()
+	if overQuota(c, "buckets", currentProject(c).Quota.Buckets, bucketCount(projectSimulation(c, h.simulator))) {
+		return
+	}
 	simReq := &simulation.SimulationRequest{
 		Provider:   provider,
 		Operation:  "create_bucket",
 		Parameters: req,
 	}
 	fmt.Printf("[SERVER DEBUG] Calling SimulateOperation with: provider=%s, op=%s, params=%#v\n", simReq.Provider, simReq.Operation, simReq.Parameters)
-	result := h.simulator.SimulateOperation(simReq)
+	result := projectSimulation(c, h.simulator).SimulateOperationContext(c.Request.Context(), simReq)
 	fmt.Printf("[SERVER DEBUG] SimulateOperation result: success=%v, result=%#v, error=%v\n", result.Success, result.Result, result.Error)
 	if result.Success {
 		c.JSON(http.StatusCreated, result.Result)
-	} else {
+	} else if !writeSimulationFault(c, result) {
 		c.JSON(http.StatusBadRequest, result)
 	}
 }
 
-// ListSimulatedBuckets handles GET /api/v1/simulate/providers/:provider/buckets
+// ListSimulatedBuckets handles GET /api/v1/simulate/providers/:provider/buckets; see
+// listing.go for the query parameters
 func (h *ProviderSimulationHandlers) ListSimulatedBuckets(c *gin.Context) {
-	fmt.Println("[DEBUG] Entered ListSimulatedBuckets handler")
 	provider := c.Param("provider")
 	fmt.Printf("[SERVER DEBUG] ListSimulatedBuckets called for provider=%s\n", provider)
 	simReq := &simulation.SimulationRequest{
@@ -94,40 +106,35 @@ func (h *ProviderSimulationHandlers) ListSimulatedBuckets(c *gin.Context) {
 		Operation:  "list_buckets",
 		Parameters: map[string]interface{}{},
 	}
-	fmt.Printf("[SERVER DEBUG] Calling SimulateOperation for list_buckets with: provider=%s\n", provider)
-	result := h.simulator.SimulateOperation(simReq)
-	fmt.Printf("[SERVER DEBUG] SimulateOperation list_buckets result: success=%v, result=%#v, error=%v\n", result.Success, result.Result, result.Error)
-	if result.Success {
-		// result.Result is a map with "buckets": []map[string]interface{}
-		if bucketsObj, ok := result.Result["buckets"]; ok {
-			if bucketsList, ok := bucketsObj.([]map[string]interface{}); ok {
-				// Convert simulation bucket format to ObjectStorageBucket format
-				var convertedBuckets []sharedmodels.ObjectStorageBucket
-				for _, simBucket := range bucketsList {
-					bucket := sharedmodels.ObjectStorageBucket{
-						Name:     getString(simBucket, "bucket"),
-						Provider: sharedmodels.CloudProvider(getString(simBucket, "provider")),
-						Region:   getString(simBucket, "region"),
-					}
-					// Set a default CreatedAt if not present
-					if bucket.Name != "" {
-						bucket.CreatedAt = time.Now().UTC()
-					}
-					convertedBuckets = append(convertedBuckets, bucket)
-				}
-				fmt.Printf("[SERVER DEBUG] Converted %d buckets to ObjectStorageBucket format\n", len(convertedBuckets))
-				c.JSON(http.StatusOK, convertedBuckets)
-			} else {
-				fmt.Printf("[SERVER DEBUG] bucketsObj is not []map[string]interface{}, type=%T\n", bucketsObj)
-				c.JSON(http.StatusOK, []interface{}{})
-			}
-		} else {
-			fmt.Printf("[SERVER DEBUG] No 'buckets' key in result\n")
-			c.JSON(http.StatusOK, []interface{}{})
+	result := projectSimulation(c, h.simulator).SimulateOperationContext(c.Request.Context(), simReq)
+	if !result.Success {
+		if !writeSimulationFault(c, result) {
+			c.JSON(http.StatusBadRequest, result)
 		}
-	} else {
-		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+	// result.Result is a map with "buckets": []map[string]interface{}; convert the
+	// simulation bucket format to ObjectStorageBucket
+	bucketsList, _ := result.Result["buckets"].([]map[string]interface{})
+	convertedBuckets := make([]sharedmodels.ObjectStorageBucket, 0, len(bucketsList))
+	for _, simBucket := range bucketsList {
+		bucket := sharedmodels.ObjectStorageBucket{
+			Name:     getString(simBucket, "bucket"),
+			Provider: sharedmodels.CloudProvider(getString(simBucket, "provider")),
+			Region:   getString(simBucket, "region"),
+		}
+		bucket.CreatedAt, _ = time.Parse(time.RFC3339, getString(simBucket, "created_at"))
+		convertedBuckets = append(convertedBuckets, bucket)
 	}
+	writeListPage(c, "buckets", convertedBuckets, bucketListFields)
+}
+
+// bucketListFields filters and sorts buckets, which are identified by their name
+var bucketListFields = listFields[sharedmodels.ObjectStorageBucket]{
+	id:        func(b sharedmodels.ObjectStorageBucket) string { return b.Name },
+	name:      func(b sharedmodels.ObjectStorageBucket) string { return b.Name },
+	region:    func(b sharedmodels.ObjectStorageBucket) string { return b.Region },
+	createdAt: func(b sharedmodels.ObjectStorageBucket) time.Time { return b.CreatedAt },
 }
 
 // ValidateProvider handles POST /api/v1/providers/validate
@@ -328,11 +335,17 @@ func (h *ProviderSimulationHandlers) SimulateProviderOperation(c *gin.Context) {
 		zap.String("provider", req.Provider),
 		zap.String("operation", req.Operation))
 
-	result := h.simulator.SimulateOperation(&req)
+	if req.Operation == "create_bucket" {
+		defer h.locks.lock(resourceKey(c, "quota"))()
+		if overQuota(c, "buckets", currentProject(c).Quota.Buckets, bucketCount(projectSimulation(c, h.simulator))) {
+			return
+		}
+	}
+	result := projectSimulation(c, h.simulator).SimulateOperationContext(c.Request.Context(), &req)
 
 	if result.Success {
 		c.JSON(http.StatusOK, result)
-	} else {
+	} else if !writeSimulationFault(c, result) {
 		c.JSON(http.StatusBadRequest, result)
 	}
 }
@@ -353,10 +366,10 @@ func (h *ProviderSimulationHandlers) CreateSimulatedCluster(c *gin.Context) {
 		zap.String("provider", string(req.Provider)))
 
 	// Generate simulated cluster using shared service
-	cluster := h.simulator.GenerateClusterFromSimulation(string(req.Provider), req.Name, req.Config)
+	cluster := projectSimulation(c, h.simulator).GenerateClusterFromSimulationContext(c.Request.Context(), string(req.Provider), req.Name, req.Config)
 
 	// Store the simulated cluster
-	_, err := h.store.CreateCluster(cluster)
+	_, err := h.store.Project(projectName(c)).CreateCluster(cluster)
 	if err != nil {
 		h.logger.Error("Failed to store simulated cluster", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -384,7 +397,7 @@ func (h *ProviderSimulationHandlers) RunSimulatedTest(c *gin.Context) {
 		zap.String("test_type", req.TestType))
 
 	// Check if cluster exists
-	_, err := h.store.GetCluster(req.ClusterID)
+	_, err := h.store.Project(projectName(c)).GetCluster(req.ClusterID)
 	if err != nil {
 		if err != nil && (err.Error() == "cluster not found" || err.Error() == "not found") {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -400,10 +413,10 @@ func (h *ProviderSimulationHandlers) RunSimulatedTest(c *gin.Context) {
 	}
 
 	// Generate simulated test result using shared service
-	testResult := h.simulator.GenerateTestResultFromSimulation(req.ClusterID, req.TestType)
+	testResult := projectSimulation(c, h.simulator).GenerateTestResultFromSimulationContext(c.Request.Context(), req.ClusterID, req.TestType)
 
 	// Store the test result
-	_, err = h.store.CreateTestResult(testResult)
+	_, err = h.store.Project(projectName(c)).CreateTestResult(testResult)
 	if err != nil {
 		h.logger.Error("Failed to store test result", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
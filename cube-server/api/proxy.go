@@ -0,0 +1,400 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+	sharederrors "github.com/tronicum/punchbag-cube-testsuite/shared/errors"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/providers/aws"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/providers/azure"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/providers/hetzner"
+	"go.uber.org/zap"
+)
+
+// Proxy endpoints. /api/v1/proxy/providers/:provider/... forwards to the real provider
+// through the shared provider clients, so credentials live in the server config
+// (proxy.providers) instead of on every client. Responses use the same models as the
+// simulation endpoints.
+
+// errProxyUnsupported marks operations the shared client of a provider does not offer
+var errProxyUnsupported = errors.New("not supported by the provider client")
+
+// proxyBucketClient is implemented by the shared object storage clients
+type proxyBucketClient interface {
+	CreateBucket(ctx context.Context, bucket *sharedmodels.ObjectStorageBucket) error
+	ListBuckets(ctx context.Context) ([]sharedmodels.ObjectStorageBucket, error)
+	DeleteBucket(ctx context.Context, name string) error
+}
+
+// proxyClusterClient adapts a shared provider client to cluster management
+type proxyClusterClient interface {
+	CreateCluster(ctx context.Context, cluster *sharedmodels.Cluster) (*sharedmodels.Cluster, error)
+	ListClusters(ctx context.Context) ([]sharedmodels.Cluster, error)
+	DeleteCluster(ctx context.Context, name string) error
+}
+
+// ProxyHandlers serves /api/v1/proxy
+type ProxyHandlers struct {
+	logger    *zap.Logger
+	providers map[string]internal.ProxyProvider
+
+	mu       sync.Mutex
+	buckets  map[string]proxyBucketClient
+	clusters map[string]proxyClusterClient
+}
+
+// NewProxyHandlers creates the proxy handlers for the given provider settings (may be nil,
+// providers without settings use the environment like the CLI clients do)
+func NewProxyHandlers(logger *zap.Logger, providers map[string]internal.ProxyProvider) *ProxyHandlers {
+	return &ProxyHandlers{
+		logger:    logger,
+		providers: providers,
+		buckets:   make(map[string]proxyBucketClient),
+		clusters:  make(map[string]proxyClusterClient),
+	}
+}
+
+// proxyProvider maps the provider names used by the CLIs to the provider whose client serves them
+func proxyProvider(name string) sharedmodels.CloudProvider {
+	switch strings.ToLower(name) {
+	case "aws", "aws-s3", "generic-aws-s3", "s3":
+		return sharedmodels.CloudProviderAWS
+	case "hetzner", "hetzner-s3":
+		return sharedmodels.CloudProviderHetzner
+	case "azure", "aks":
+		return sharedmodels.CloudProviderAzure
+	}
+	return ""
+}
+
+// bucketClient returns the cached object storage client of a provider, creating it on first use
+func (h *ProxyHandlers) bucketClient(ctx context.Context, provider sharedmodels.CloudProvider) (proxyBucketClient, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client, ok := h.buckets[string(provider)]; ok {
+		return client, nil
+	}
+	settings := h.providers[string(provider)]
+	var client proxyBucketClient
+	switch provider {
+	case sharedmodels.CloudProviderAWS:
+		region := settings.Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		s3Client, err := aws.NewS3ClientWithOptions(ctx, aws.S3Options{
+			Region:    region,
+			AccessKey: settings.AccessKey,
+			SecretKey: settings.SecretKey,
+			Endpoint:  settings.Endpoint,
+		})
+		if err != nil {
+			return nil, err
+		}
+		client = s3Client
+	case sharedmodels.CloudProviderHetzner:
+		var s3Client *hetzner.HetznerS3Client
+		if settings.AccessKey != "" {
+			s3Client = hetzner.NewHetznerS3ClientWithKeys(settings.AccessKey, settings.SecretKey, settings.Region)
+		} else {
+			s3Client = hetzner.NewHetznerS3Client()
+		}
+		s3Client.SetEndpoint(settings.Endpoint)
+		client = s3Client
+	default:
+		return nil, fmt.Errorf("object storage is %w for %s", errProxyUnsupported, provider)
+	}
+	h.buckets[string(provider)] = client
+	return client, nil
+}
+
+// clusterClient returns the cached cluster client of a provider, creating it on first use
+func (h *ProxyHandlers) clusterClient(provider sharedmodels.CloudProvider) (proxyClusterClient, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client, ok := h.clusters[string(provider)]; ok {
+		return client, nil
+	}
+	var client proxyClusterClient
+	switch provider {
+	case sharedmodels.CloudProviderAzure:
+		client = &azureClusterProxy{provider: newAzureProvider(h.providers[string(provider)])}
+	default:
+		return nil, fmt.Errorf("cluster management is %w for %s", errProxyUnsupported, provider)
+	}
+	h.clusters[string(provider)] = client
+	return client, nil
+}
+
+// CreateBucket handles POST /proxy/providers/:provider/buckets
+func (h *ProxyHandlers) CreateBucket(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	var bucket sharedmodels.ObjectStorageBucket
+	// The provider comes from the path, so the model's binding rules are not applied
+	if err := json.NewDecoder(c.Request.Body).Decode(&bucket); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body: " + err.Error()})
+		return
+	}
+	if bucket.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	client, err := h.bucketClient(c.Request.Context(), provider)
+	if err != nil {
+		h.writeError(c, provider, "create bucket", err)
+		return
+	}
+	if bucket.Region == "" {
+		bucket.Region = h.providers[string(provider)].Region
+	}
+	if err := client.CreateBucket(c.Request.Context(), &bucket); err != nil {
+		h.writeError(c, provider, "create bucket", err)
+		return
+	}
+	bucket.CreatedAt = time.Now().UTC()
+	h.logger.Info("Bucket created via proxy", zap.String("provider", string(provider)), zap.String("bucket", bucket.Name))
+	c.JSON(http.StatusCreated, h.normaliseBucket(provider, bucket))
+}
+
+// ListBuckets handles GET /proxy/providers/:provider/buckets; the provider's answer
+// is filtered and paged like other lists (see listing.go)
+func (h *ProxyHandlers) ListBuckets(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	client, err := h.bucketClient(c.Request.Context(), provider)
+	if err != nil {
+		h.writeError(c, provider, "list buckets", err)
+		return
+	}
+	buckets, err := client.ListBuckets(c.Request.Context())
+	if err != nil {
+		h.writeError(c, provider, "list buckets", err)
+		return
+	}
+	result := make([]sharedmodels.ObjectStorageBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		result = append(result, h.normaliseBucket(provider, bucket))
+	}
+	writeListPage(c, "buckets", result, bucketListFields)
+}
+
+// DeleteBucket handles DELETE /proxy/providers/:provider/buckets/:bucket
+func (h *ProxyHandlers) DeleteBucket(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	client, err := h.bucketClient(c.Request.Context(), provider)
+	if err != nil {
+		h.writeError(c, provider, "delete bucket", err)
+		return
+	}
+	if err := client.DeleteBucket(c.Request.Context(), c.Param("bucket")); err != nil {
+		h.writeError(c, provider, "delete bucket", err)
+		return
+	}
+	h.logger.Info("Bucket deleted via proxy", zap.String("provider", string(provider)), zap.String("bucket", c.Param("bucket")))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateCluster handles POST /proxy/providers/:provider/clusters
+func (h *ProxyHandlers) CreateCluster(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	var cluster sharedmodels.Cluster
+	if err := json.NewDecoder(c.Request.Body).Decode(&cluster); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body: " + err.Error()})
+		return
+	}
+	if cluster.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	client, err := h.clusterClient(provider)
+	if err != nil {
+		h.writeError(c, provider, "create cluster", err)
+		return
+	}
+	created, err := client.CreateCluster(c.Request.Context(), &cluster)
+	if err != nil {
+		h.writeError(c, provider, "create cluster", err)
+		return
+	}
+	h.logger.Info("Cluster created via proxy", zap.String("provider", string(provider)), zap.String("id", created.ID))
+	c.JSON(http.StatusCreated, normaliseCluster(provider, *created))
+}
+
+// ListClusters handles GET /proxy/providers/:provider/clusters; the provider's answer
+// is filtered and paged like other lists (see listing.go)
+func (h *ProxyHandlers) ListClusters(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	client, err := h.clusterClient(provider)
+	if err != nil {
+		h.writeError(c, provider, "list clusters", err)
+		return
+	}
+	clusters, err := client.ListClusters(c.Request.Context())
+	if err != nil {
+		h.writeError(c, provider, "list clusters", err)
+		return
+	}
+	result := make([]*sharedmodels.Cluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		normalised := normaliseCluster(provider, cluster)
+		result = append(result, &normalised)
+	}
+	writeListPage(c, "clusters", result, clusterListFields)
+}
+
+// DeleteCluster handles DELETE /proxy/providers/:provider/clusters/:cluster
+func (h *ProxyHandlers) DeleteCluster(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	client, err := h.clusterClient(provider)
+	if err != nil {
+		h.writeError(c, provider, "delete cluster", err)
+		return
+	}
+	if err := client.DeleteCluster(c.Request.Context(), c.Param("cluster")); err != nil {
+		h.writeError(c, provider, "delete cluster", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// provider resolves the :provider path parameter, answering 404 for unknown providers
+func (h *ProxyHandlers) provider(c *gin.Context) (sharedmodels.CloudProvider, bool) {
+	provider := proxyProvider(c.Param("provider"))
+	if provider == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown provider %q", c.Param("provider"))})
+		return "", false
+	}
+	return provider, true
+}
+
+// normaliseBucket fills in what the provider clients leave out: the ID is the bucket name,
+// the provider is the canonical one and the region defaults to the configured region
+func (h *ProxyHandlers) normaliseBucket(provider sharedmodels.CloudProvider, bucket sharedmodels.ObjectStorageBucket) sharedmodels.ObjectStorageBucket {
+	if bucket.ID == "" {
+		bucket.ID = bucket.Name
+	}
+	bucket.Provider = provider
+	if bucket.Region == "" {
+		bucket.Region = h.providers[string(provider)].Region
+	}
+	if bucket.UpdatedAt.IsZero() {
+		bucket.UpdatedAt = bucket.CreatedAt
+	}
+	return bucket
+}
+
+// normaliseCluster gives clusters from the provider clients the fields the cluster API returns
+func normaliseCluster(provider sharedmodels.CloudProvider, cluster sharedmodels.Cluster) sharedmodels.Cluster {
+	if cluster.ID == "" {
+		cluster.ID = cluster.Name
+	}
+	cluster.Provider = provider
+	if cluster.Status == "" {
+		cluster.Status = sharedmodels.ClusterStatusCreating
+	}
+	if cluster.CreatedAt.IsZero() {
+		cluster.CreatedAt = time.Now().UTC()
+	}
+	if cluster.UpdatedAt.IsZero() {
+		cluster.UpdatedAt = cluster.CreatedAt
+	}
+	return cluster
+}
+
+// writeError answers with the status that matches the provider error: the S3 error codes
+// of the object storage clients are mapped, anything else is a bad gateway
+func (h *ProxyHandlers) writeError(c *gin.Context, provider sharedmodels.CloudProvider, operation string, err error) {
+	status := http.StatusBadGateway
+	body := gin.H{"error": fmt.Sprintf("%s: %v", operation, err), "provider": provider}
+	var apiErr interface{ ErrorCode() string }
+	switch {
+	case errors.Is(err, errProxyUnsupported):
+		status = http.StatusNotImplemented
+	case errors.As(err, &apiErr):
+		body["code"] = apiErr.ErrorCode()
+		switch apiErr.ErrorCode() {
+		case "NoSuchBucket", "NotFound":
+			status = http.StatusNotFound
+		case "BucketAlreadyExists", "BucketAlreadyOwnedByYou", "BucketNotEmpty":
+			status = http.StatusConflict
+		case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+			status = http.StatusForbidden
+		}
+	}
+	h.logger.Warn("Proxy request failed", zap.String("provider", string(provider)), zap.String("operation", operation), zap.Error(err))
+	c.JSON(status, body)
+}
+
+// newAzureProvider creates the shared Azure provider with the configured credentials,
+// used by the proxy and the executor alike
+func newAzureProvider(settings internal.ProxyProvider) *azure.AzureProviderImpl {
+	p := azure.NewAzureProvider().(*azure.AzureProviderImpl)
+	p.SetCredentials(settings.Credentials)
+	p.SetConfig(map[string]interface{}{"region": settings.Region})
+	return p
+}
+
+// azureClusterProxy manages AKS clusters through the shared Azure provider, which only offers creation
+type azureClusterProxy struct {
+	provider *azure.AzureProviderImpl
+}
+
+func (a *azureClusterProxy) CreateCluster(ctx context.Context, cluster *sharedmodels.Cluster) (*sharedmodels.Cluster, error) {
+	nodeCount := 3
+	if n, ok := cluster.Config["node_count"].(float64); ok && n > 0 {
+		nodeCount = int(n)
+	}
+	location := cluster.Location
+	if location == "" {
+		location = a.provider.GetRegion()
+	}
+	result, err := a.provider.CreateAKSCluster(ctx, cluster.Name, cluster.ResourceGroup, location, nodeCount)
+	if errors.Is(err, sharederrors.ErrNotFound) {
+		// the provider reports direct mode as not implemented this way
+		return nil, fmt.Errorf("AKS creation is %w outside simulation mode", errProxyUnsupported)
+	}
+	if err != nil {
+		return nil, err
+	}
+	created := *cluster
+	created.ID = result.ID
+	created.Location = location
+	if result.Status == "created" {
+		created.Status = sharedmodels.ClusterStatusRunning
+	}
+	return &created, nil
+}
+
+func (a *azureClusterProxy) ListClusters(ctx context.Context) ([]sharedmodels.Cluster, error) {
+	return nil, fmt.Errorf("listing AKS clusters is %w", errProxyUnsupported)
+}
+
+func (a *azureClusterProxy) DeleteCluster(ctx context.Context, name string) error {
+	return fmt.Errorf("deleting AKS clusters is %w", errProxyUnsupported)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	store "github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+// newProxyTestServer starts a server whose proxy talks to the S3 simulation of sim
+func newProxyTestServer(t *testing.T, sim *httptest.Server) *httptest.Server {
+	t.Helper()
+	endpoint := sim.URL + "/api/v1/simulate/aws-s3"
+	cfg := &internal.ServerConfig{}
+	cfg.Proxy.Providers = map[string]internal.ProxyProvider{
+		"aws":     {Region: "eu-central-1", AccessKey: "AKIDPROXY", SecretKey: "proxy-secret", Endpoint: endpoint},
+		"hetzner": {Region: "fsn1", AccessKey: "HZPROXY", SecretKey: "proxy-secret", Endpoint: endpoint},
+	}
+	r := newTestRouter(t, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: NewTestSimulationService(), Config: cfg})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// bucketList is the answer of bucket list routes
+type bucketList struct {
+	Buckets []sharedmodels.ObjectStorageBucket `json:"buckets"`
+}
+
+func TestProxyBucketsAgainstS3Simulation(t *testing.T) {
+	sim := newS3TestServer(t)
+	base := newProxyTestServer(t, sim).URL + "/api/v1/proxy/providers"
+
+	var created sharedmodels.ObjectStorageBucket
+	if status := doJSON(t, http.MethodPost, base+"/aws-s3/buckets", map[string]interface{}{"name": "proxied-reports"}, &created); status != http.StatusCreated {
+		t.Fatalf("create bucket: status %d", status)
+	}
+	if created.ID != "proxied-reports" || created.Provider != sharedmodels.AWS || created.Region != "eu-central-1" || created.CreatedAt.IsZero() {
+		t.Errorf("created bucket = %+v", created)
+	}
+	if status := doJSON(t, http.MethodPost, base+"/aws/buckets", map[string]interface{}{"name": "proxied-reports"}, nil); status != http.StatusConflict {
+		t.Errorf("create existing bucket: status %d, want 409", status)
+	}
+
+	// The bucket went through the S3 API into the simulation
+	var simulated bucketList
+	doJSON(t, http.MethodGet, sim.URL+"/api/v1/simulate/providers/aws/buckets", nil, &simulated)
+	if len(simulated.Buckets) != 1 || simulated.Buckets[0].Name != "proxied-reports" || simulated.Buckets[0].Region != "eu-central-1" {
+		t.Errorf("simulated buckets = %+v", simulated.Buckets)
+	}
+
+	for _, provider := range []string{"generic-aws-s3", "hetzner"} {
+		var listed bucketList
+		if status := doJSON(t, http.MethodGet, base+"/"+provider+"/buckets", nil, &listed); status != http.StatusOK {
+			t.Fatalf("list %s buckets: status %d", provider, status)
+		}
+		want := proxyProvider(provider)
+		if len(listed.Buckets) != 1 || listed.Buckets[0].ID != "proxied-reports" || listed.Buckets[0].Provider != want {
+			t.Errorf("%s buckets = %+v", provider, listed.Buckets)
+		}
+	}
+
+	if status := doJSON(t, http.MethodDelete, base+"/aws-s3/buckets/proxied-reports", nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete bucket: status %d", status)
+	}
+	if status := doJSON(t, http.MethodDelete, base+"/aws-s3/buckets/proxied-reports", nil, nil); status != http.StatusNotFound {
+		t.Errorf("delete missing bucket: status %d, want 404", status)
+	}
+	var listed bucketList
+	if doJSON(t, http.MethodGet, base+"/aws-s3/buckets", nil, &listed); listed.Buckets == nil || len(listed.Buckets) != 0 {
+		t.Errorf("buckets after delete = %#v, want an empty list", listed.Buckets)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		body         interface{}
+		want         int
+	}{
+		{http.MethodGet, "/openstack/buckets", nil, http.StatusNotFound},
+		{http.MethodPost, "/aws/buckets", map[string]interface{}{"region": "eu-central-1"}, http.StatusBadRequest},
+		{http.MethodGet, "/azure/buckets", nil, http.StatusNotImplemented},
+		{http.MethodGet, "/hetzner/clusters", nil, http.StatusNotImplemented},
+		{http.MethodPost, "/azure/clusters", map[string]interface{}{"name": "aks-1", "resource_group": "rg"}, http.StatusNotImplemented},
+	} {
+		if status := doJSON(t, tc.method, base+tc.path, tc.body, nil); status != tc.want {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.path, status, tc.want)
+		}
+	}
+}
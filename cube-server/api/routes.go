@@ -3,22 +3,156 @@ package api
 import (
 	"time"
 
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/audit"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/events"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/executor"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/metrics"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/openapi"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/testrunner"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/webhooks"
 	store "github.com/tronicum/punchbag-cube-testsuite/store"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-// SetupRoutes configures all the API routes
 import (
 	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
 )
 
+// SetupRoutes configures all the API routes without a config, authentication or a
+// persistent audit log. Its background work runs until the process exits; use
+// SetupRoutesWithOptions to stop it.
 func SetupRoutes(router *gin.Engine, store store.Store, logger *zap.Logger, sim *simulation.SimulationService) {
-	handlers := NewHandlers(store, logger)
+	SetupRoutesWithOptions(router, RouteOptions{Store: store, Logger: logger, Sim: sim})
+}
+
+// RouteOptions are what the API routes are served from; Logger is required
+type RouteOptions struct {
+	Store  store.Store
+	Logger *zap.Logger
+	Sim    *simulation.SimulationService
+	// Config is the server config; nil uses the defaults
+	Config *internal.ServerConfig
+	// Auth makes every /api/v1 request need a bearer token whose role allows the
+	// route (see auth/); nil lets everything through
+	Auth *auth.Authenticator
+	// Audit records every change through /api/v1 (see audit/); nil keeps the
+	// latest changes in memory
+	Audit *audit.Log
+}
+
+// Routes is the background work behind the API routes: the test runner, webhook
+// deliveries and the simulation event feed
+type Routes struct {
+	tests      *testrunner.Executor
+	dispatcher *webhooks.Dispatcher
+	stopEvents func()
+}
+
+// Close stops the background work; running tests are cancelled and marked failed,
+// deliveries waiting for a retry are dropped
+func (r *Routes) Close() error {
+	if r.stopEvents != nil {
+		r.stopEvents()
+	}
+	r.dispatcher.Close()
+	r.tests.Stop()
+	return nil
+}
+
+// SetupRoutesWithOptions configures all the API routes; the caller closes the returned
+// Routes on shutdown
+func SetupRoutesWithOptions(router *gin.Engine, opts RouteOptions) *Routes {
+	store, logger, sim, cfg, authenticator, auditLog := opts.Store, opts.Logger, opts.Sim, opts.Config, opts.Auth, opts.Audit
+	if auditLog == nil {
+		auditLog = audit.NewMemoryLog(0)
+	}
+	routes := &Routes{}
+	// Prometheus metrics of every request, the store, tests and the simulation (see metrics/)
+	// The audit log reads before images from the store itself, not the metered one
+	auditStore := store
+	serverMetrics := metrics.New()
+	router.Use(serverMetrics.Middleware())
+	router.GET("/metrics", serverMetrics.Handler())
+	if store != nil {
+		store = serverMetrics.Store(store)
+	}
+	if sim != nil {
+		serverMetrics.CollectSimulation(sim)
+	}
+
+	broker := events.NewBroker(events.DefaultHistorySize)
+	testCfg := testrunner.Config{Events: broker, StatusChanged: serverMetrics.TestStatusChanged}
+	if cfg != nil {
+		testCfg.Concurrency = cfg.Tests.Concurrency
+		testCfg.QueueSize = cfg.Tests.QueueSize
+		testCfg.Timeout = cfg.Tests.Timeout
+	}
+	routes.tests = testrunner.New(store, logger, testCfg)
+	handlers := NewHandlers(store, logger, routes.tests, broker)
+	// Simulated buckets and clusters are published like stored resources, and every
+	// event goes out to the matching webhook subscriptions (see webhooks.go)
+	if sim != nil {
+		routes.stopEvents = simulationEvents(sim, broker, time.Second)
+	}
+	var webhookCfg webhooks.Config
+	if cfg != nil {
+		webhookCfg = webhooks.Config{
+			MaxAttempts: cfg.Webhooks.MaxAttempts,
+			Backoff:     cfg.Webhooks.Backoff,
+			MaxBackoff:  cfg.Webhooks.MaxBackoff,
+			Timeout:     cfg.Webhooks.Timeout,
+			Workers:     cfg.Webhooks.Workers,
+			QueueSize:   cfg.Webhooks.QueueSize,
+		}
+	}
+	dispatcher := webhooks.NewDispatcher(webhookCfg, logger)
+	routes.dispatcher = dispatcher
+	go dispatcher.Run(broker)
+
+	var proxyProviders map[string]internal.ProxyProvider
+	if cfg != nil {
+		proxyProviders = cfg.Proxy.Providers
+	}
+	executions := executor.New(sim, executor.DefaultHistorySize)
+	executions.RegisterAzure(newAzureProvider(proxyProviders["azure"]))
+	executorHandlers := NewExecutorHandlers(logger, executions)
 
 	// API version prefix
 	v1 := router.Group("/api/v1")
+	// Every change is recorded with its caller, payload and result, refused ones
+	// included (see audit.go)
+	v1.Use(auditTrail(auditLog, auditStore, logger))
+	// Bearer token and role check; a nil authenticator lets everything through
+	v1.Use(authenticator.Middleware())
+	// Snapshots and restores of the whole server hold back other requests (see snapshot.go)
+	var snapshotHandlers *SnapshotHandlers
+	if store != nil && sim != nil {
+		snapshotHandlers = NewSnapshotHandlers(store, sim, routes.tests, fixturesDir(cfg), logger)
+		v1.Use(snapshotHandlers.gate.middleware())
+	}
+	// X-Cube-Sim-Seed seeds the simulation of a single request (see simulation_seed.go)
+	v1.Use(simulationSeed())
+	// X-Cube-Project selects the project; /api/v1/projects/<project>/... sets it for the
+	// route that follows (see projects.go)
+	v1.Use(projectScope(store))
+	// Requests and responses are checked against api/openapi.yaml (see openapi/)
+	mode, err := ValidationMode(cfg)
+	if err != nil {
+		logger.Warn("Invalid API validation mode, only logging", zap.Error(err))
+		mode = openapi.LogOnly
+	}
+	v1.Use(apiSpec.Middleware(mode, logger))
+	// POSTs with an Idempotency-Key are answered once per key (see idempotency.go)
+	window, err := IdempotencyWindow(cfg)
+	if err != nil {
+		logger.Warn("Invalid idempotency window, using the default", zap.Error(err))
+	}
+	v1.Use(newIdempotencyCache(window).middleware())
+	router.Any(projectPrefixRoute, routeProjectPrefix(router))
 	{
 		// Cluster management endpoints
 		clusters := v1.Group("/clusters")
@@ -32,14 +166,74 @@ func SetupRoutes(router *gin.Engine, store store.Store, logger *zap.Logger, sim
 			// Test endpoints for specific clusters
 			clusters.POST(":id/tests", handlers.RunTest)
 			clusters.GET(":id/tests", handlers.ListTestResults)
+
+			// Node pools of specific clusters
+			clusters.POST(":id/nodepools", handlers.CreateNodePool)
+			clusters.GET(":id/nodepools", handlers.ListNodePools)
+			clusters.GET(":id/nodepools/:pool", handlers.GetNodePool)
+			clusters.PUT(":id/nodepools/:pool", handlers.UpdateNodePool)
+			clusters.DELETE(":id/nodepools/:pool", handlers.DeleteNodePool)
 		}
 
 		// Test result endpoints
 		tests := v1.Group("/tests")
 		{
 			tests.GET(":id", handlers.GetTestResult)
+			tests.DELETE(":id", handlers.CancelTest)
+		}
+
+		// Projects (tenants) and their quotas (see projects.go)
+		if store != nil {
+			projectHandlers := NewProjectHandlers(store, sim, logger)
+			projectHandlers.webhooks = dispatcher
+			projects := v1.Group("/projects")
+			{
+				projects.POST("", projectHandlers.CreateProject)
+				projects.GET("", projectHandlers.ListProjects)
+				projects.GET(":project", projectHandlers.GetProject)
+				projects.PUT(":project", projectHandlers.UpdateProject)
+				projects.DELETE(":project", projectHandlers.DeleteProject)
+			}
+		}
+
+		// Azure monitoring, Kubernetes and budget resources (see azure_resources.go)
+		registerAzureResources(v1.Group("/azure"), store, logger)
+
+		// Sessions and API tokens (see auth.go)
+		if authenticator != nil {
+			authHandlers := NewAuthHandlers(authenticator, logger)
+			authGroup := v1.Group("/auth")
+			{
+				authGroup.GET("/whoami", authHandlers.WhoAmI)
+				authGroup.POST("/login", authHandlers.Login)
+				authGroup.POST("/refresh", authHandlers.Refresh)
+				authGroup.POST("/tokens", authHandlers.CreateToken)
+				authGroup.GET("/tokens", authHandlers.ListTokens)
+				authGroup.DELETE("/tokens/:id", authHandlers.RevokeToken)
+			}
+		}
+
+		// Live resource events as Server-Sent Events or WebSocket (see events.go)
+		v1.GET("/events", handlers.StreamEvents)
+
+		// Webhook subscriptions, their deliveries and dead letters (operator, see webhooks.go)
+		webhookHandlers := NewWebhookHandlers(dispatcher, logger)
+		hooks := v1.Group("/webhooks")
+		{
+			hooks.POST("", webhookHandlers.CreateWebhook)
+			hooks.GET("", webhookHandlers.ListWebhooks)
+			hooks.GET("/dead-letters", webhookHandlers.ListDeadLetters)
+			hooks.POST("/dead-letters/:id/redeliver", webhookHandlers.RedeliverDeadLetter)
+			hooks.GET("/:id", webhookHandlers.GetWebhook)
+			hooks.PUT("/:id", webhookHandlers.UpdateWebhook)
+			hooks.DELETE("/:id", webhookHandlers.DeleteWebhook)
+			hooks.GET("/:id/deliveries", webhookHandlers.ListWebhookDeliveries)
+			hooks.POST("/:id/ping", webhookHandlers.PingWebhook)
 		}
 
+		// Audit log of the project's changes (admin, see audit.go)
+		v1.GET("/audit", NewAuditHandlers(auditLog, logger).QueryAudit)
+
 		// Metrics and monitoring endpoints
 		metrics := v1.Group("/metrics")
 		{
@@ -51,10 +245,12 @@ func SetupRoutes(router *gin.Engine, store store.Store, logger *zap.Logger, sim
 			})
 
 			metrics.GET("/status", func(c *gin.Context) {
-				clusters, _ := store.ListClusters()
-				testResults, _ := store.ListTestResults("")
+				projectStore := store.Project(projectName(c))
+				clusters, _ := projectStore.ListClusters()
+				testResults, _ := projectStore.ListTestResults("")
 
 				c.JSON(200, gin.H{
+					"project":      projectName(c),
 					"clusters":     len(clusters),
 					"test_results": len(testResults),
 					"version":      "1.0.0",
@@ -63,6 +259,30 @@ func SetupRoutes(router *gin.Engine, store store.Store, logger *zap.Logger, sim
 		}
 
 		providerSimHandlers := NewProviderSimulationHandlers(store, logger, sim)
+		providerSimHandlers.s3RequireProjectKey = authenticator != nil
+		if cfg != nil {
+			providerSimHandlers.s3ProviderName = cfg.Storage.S3.Provider
+			providerSimHandlers.s3VirtualHostDomains = cfg.Storage.S3.VirtualHostDomains
+			if cfg.Storage.S3.Auth.Enabled {
+				secrets := make(map[string]string, len(cfg.Storage.S3.Auth.Credentials))
+				providerSimHandlers.s3Owners = make(map[string]bool)
+				providerSimHandlers.s3Projects = make(map[string]map[string]bool)
+				for _, cred := range cfg.Storage.S3.Auth.Credentials {
+					secrets[cred.AccessKey] = cred.SecretKey
+					providerSimHandlers.s3Owners[cred.AccessKey] = cred.Owner
+					providerSimHandlers.s3Projects[cred.AccessKey] = make(map[string]bool, len(cred.Projects))
+					for _, project := range cred.Projects {
+						providerSimHandlers.s3Projects[cred.AccessKey][project] = true
+					}
+				}
+				providerSimHandlers.s3Auth = newS3SigV4Verifier(secrets)
+			}
+			proxies, err := parseTrustedProxies(cfg.API.TrustedProxies)
+			if err != nil {
+				logger.Error("Ignoring api.trusted_proxies", zap.Error(err))
+			}
+			providerSimHandlers.trustedProxies = proxies
+		}
 
 		// Simulation endpoints
 		simulate := v1.Group("/simulate")
@@ -71,18 +291,45 @@ func SetupRoutes(router *gin.Engine, store store.Store, logger *zap.Logger, sim
 			simulate.POST("/providers/:provider/buckets", providerSimHandlers.CreateSimulatedBucket)
 			simulate.GET("/providers/:provider/buckets", providerSimHandlers.ListSimulatedBuckets)
 			simulate.DELETE("/providers/:provider/buckets/:bucket", providerSimHandlers.DeleteSimulatedBucket)
-			// Generic AWS S3 simulation endpoint for SDK compatibility
+			// Generic AWS S3 simulation endpoint for SDK compatibility (S3 REST dialect, see s3_protocol.go)
+			simulate.Any("/aws-s3", providerSimHandlers.GenericAWSS3SimHandler)
 			simulate.Any("/aws-s3/*path", providerSimHandlers.GenericAWSS3SimHandler)
+			// Simulation clock and lifecycle engine control (see simulation_admin.go)
+			simulate.GET("/admin/clock", providerSimHandlers.GetSimulationClock)
+			simulate.POST("/admin/clock/advance", providerSimHandlers.AdvanceSimulationClock)
+			simulate.POST("/admin/clock/reset", providerSimHandlers.ResetSimulationClock)
+			simulate.POST("/admin/lifecycle/run", providerSimHandlers.RunLifecycle)
+			// Fault and latency injection (see simulation_faults.go)
+			simulate.GET("/admin/faults", providerSimHandlers.GetFaultProfiles)
+			simulate.PUT("/admin/faults", providerSimHandlers.SetFaultProfiles)
+			simulate.DELETE("/admin/faults", providerSimHandlers.ClearFaultProfiles)
+			// Snapshot and restore of the whole server, named fixtures (see snapshot.go)
+			if snapshotHandlers != nil {
+				simulate.GET("/admin/snapshot", snapshotHandlers.GetSnapshot)
+				simulate.POST("/admin/restore", snapshotHandlers.RestoreSnapshot)
+				simulate.GET("/admin/fixtures", snapshotHandlers.ListFixtures)
+				simulate.GET("/admin/fixtures/:name", snapshotHandlers.GetFixture)
+				simulate.POST("/admin/fixtures/:name", snapshotHandlers.SaveFixture)
+				simulate.PUT("/admin/fixtures/:name", snapshotHandlers.PutFixture)
+				simulate.DELETE("/admin/fixtures/:name", snapshotHandlers.DeleteFixture)
+				simulate.POST("/admin/fixtures/:name/restore", snapshotHandlers.RestoreFixture)
+			}
+			// Dry-run bucket policy evaluation (see s3_policy.go)
+			simulate.POST("/policy/explain", providerSimHandlers.ExplainBucketPolicy)
 			// Add more simulation endpoints as needed
 		}
 
-		// Proxy endpoints (real provider, via server)
-		// proxy := v1.Group("/proxy")
-		// {
-		//     // TODO: Register proxy handlers here
-		//     // proxy.POST("/providers/:provider/operations/:operation", ...)
-		//     // proxy.DELETE("/providers/:provider/buckets/:bucket", ...)
-		// }
+		// Proxy endpoints (real provider, via server; see proxy.go)
+		proxyHandlers := NewProxyHandlers(logger, proxyProviders)
+		proxy := v1.Group("/proxy")
+		{
+			proxy.POST("/providers/:provider/buckets", proxyHandlers.CreateBucket)
+			proxy.GET("/providers/:provider/buckets", proxyHandlers.ListBuckets)
+			proxy.DELETE("/providers/:provider/buckets/:bucket", proxyHandlers.DeleteBucket)
+			proxy.POST("/providers/:provider/clusters", proxyHandlers.CreateCluster)
+			proxy.GET("/providers/:provider/clusters", proxyHandlers.ListClusters)
+			proxy.DELETE("/providers/:provider/clusters/:cluster", proxyHandlers.DeleteCluster)
+		}
 
 		// direct := v1.Group("/direct")
 		// {
@@ -96,51 +343,26 @@ func SetupRoutes(router *gin.Engine, store store.Store, logger *zap.Logger, sim
 		}
 
 		// Azure simulation endpoints (legacy, to be migrated)
-		simulator := NewAzureHandlers(logger)
+		simulator := NewAzureHandlers(logger, sim)
 		sim := v1.Group("/simulator")
 		{
 			sim.POST("/azure/aks", simulator.SimulateAKS)
 			sim.POST("/azure/budget", simulator.SimulateAzureBudget)
 		}
 
-		// Executor endpoints (under /executor)
-		// exec := v1.Group("/executor")
-		// These handlers should forward to the real cloud provider if simulation succeeded, or if --force is set
-		// (Handlers to be implemented)
-		// exec.POST("/azure/aks", executor.ExecuteAKS) // --dryrun, --force supported
-		// exec.POST("/azure/budget", executor.ExecuteAzureBudget)
+		// Executor endpoints: forward to the real cloud provider if the simulation succeeded,
+		// or if force is set; dryrun only plans (see executor.go)
+		exec := v1.Group("/executor")
+		{
+			exec.POST("/azure/aks", executorHandlers.ExecuteAKS)
+			exec.POST("/azure/budget", executorHandlers.ExecuteAzureBudget)
+			exec.POST("/providers/:provider/operations/:operation", executorHandlers.ExecuteOperation)
+			exec.GET("/executions", executorHandlers.ListExecutions)
+			exec.GET("/executions/:id", executorHandlers.GetExecution)
+		}
 	}
 
-	// Documentation endpoint
-	router.GET("/docs", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"message": "API Documentation",
-			"version": "v1",
-			"endpoints": gin.H{
-				"clusters": gin.H{
-					"POST /api/v1/clusters": "Create a new AKS cluster",
-				},
-				"metrics": gin.H{
-					"GET /api/v1/metrics/health": "Health check",
-					"GET /api/v1/metrics/status": "Service status",
-				},
-				"providers": gin.H{
-					"GET /api/v1/providers/:provider/info":                   "Get provider information",
-					"GET /api/v1/providers/:provider/clusters":               "List clusters for provider",
-					"POST /api/v1/providers/:provider/operations/:operation": "Simulate provider operation",
-				},
-				"validate": gin.H{
-					"GET /api/v1/validate/:provider": "Validate provider configuration",
-				},
-				"simulator": gin.H{
-					"POST /api/v1/simulator/azure/aks":    "Simulate AKS cluster creation",
-					"POST /api/v1/simulator/azure/budget": "Simulate Azure budget",
-				},
-				"executor": gin.H{
-					"POST /api/v1/executor/azure/aks":    "Execute AKS cluster creation (real cloud)",
-					"POST /api/v1/executor/azure/budget": "Execute Azure budget (real cloud)",
-				},
-			},
-		})
-	})
+	// The spec as YAML, JSON and an HTML reference (see docs.go)
+	registerDocs(router)
+	return routes
 }
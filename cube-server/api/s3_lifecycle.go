@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	simulation "github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+// S3 bucket lifecycle configuration (GET/PUT/DELETE ?lifecycle). The XML document is
+// translated to models.ObjectStorageRule and evaluated by the simulation lifecycle
+// engine whenever the simulation clock is advanced.
+
+const s3TaggingHeader = "X-Amz-Tagging"
+
+type s3LifecycleTag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type s3LifecycleAnd struct {
+	Prefix string           `xml:"Prefix,omitempty"`
+	Tags   []s3LifecycleTag `xml:"Tag"`
+}
+
+type s3LifecycleFilter struct {
+	Prefix *string         `xml:"Prefix"`
+	Tag    *s3LifecycleTag `xml:"Tag"`
+	And    *s3LifecycleAnd `xml:"And"`
+}
+
+type s3LifecycleExpiration struct {
+	Days                      int    `xml:"Days,omitempty"`
+	Date                      string `xml:"Date,omitempty"`
+	ExpiredObjectDeleteMarker bool   `xml:"ExpiredObjectDeleteMarker,omitempty"`
+}
+
+type s3LifecycleTransition struct {
+	Days         int    `xml:"Days,omitempty"`
+	Date         string `xml:"Date,omitempty"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3LifecycleNoncurrentExpiration struct {
+	NoncurrentDays          int `xml:"NoncurrentDays"`
+	NewerNoncurrentVersions int `xml:"NewerNoncurrentVersions,omitempty"`
+}
+
+type s3LifecycleAbortMultipart struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+type s3LifecycleRule struct {
+	ID                             string                           `xml:"ID,omitempty"`
+	Prefix                         *string                          `xml:"Prefix"`
+	Filter                         *s3LifecycleFilter               `xml:"Filter"`
+	Status                         string                           `xml:"Status"`
+	Expiration                     *s3LifecycleExpiration           `xml:"Expiration"`
+	Transitions                    []s3LifecycleTransition          `xml:"Transition"`
+	NoncurrentVersionExpiration    *s3LifecycleNoncurrentExpiration `xml:"NoncurrentVersionExpiration"`
+	AbortIncompleteMultipartUpload *s3LifecycleAbortMultipart       `xml:"AbortIncompleteMultipartUpload"`
+}
+
+type s3LifecycleConfiguration struct {
+	XMLName xml.Name          `xml:"LifecycleConfiguration"`
+	Xmlns   string            `xml:"xmlns,attr,omitempty"`
+	Rules   []s3LifecycleRule `xml:"Rule"`
+}
+
+func (h *ProviderSimulationHandlers) s3GetBucketLifecycle(c *gin.Context, bucket string) {
+	rules, err := projectSimulation(c, h.simulator).BucketStore().Lifecycle(h.s3Provider(c), bucket)
+	if errors.Is(err, simulation.ErrNoSuchLifecycleConfiguration) {
+		h.writeS3Error(c, http.StatusNotFound, S3ErrNoSuchLifecycleConfiguration, "The lifecycle configuration does not exist", bucket, "")
+		return
+	}
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+	cfg := s3LifecycleConfiguration{Xmlns: s3XMLNamespace}
+	for _, rule := range rules {
+		cfg.Rules = append(cfg.Rules, s3LifecycleRuleFromModel(rule))
+	}
+	h.writeS3XML(c, http.StatusOK, cfg)
+}
+
+func (h *ProviderSimulationHandlers) s3PutBucketLifecycle(c *gin.Context, bucket string) {
+	body, err := h.s3ReadBody(c)
+	if err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, err.Error(), bucket, "")
+		return
+	}
+	var cfg s3LifecycleConfiguration
+	if err := xml.Unmarshal(body, &cfg); err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema.", bucket, "")
+		return
+	}
+	rules := make([]models.ObjectStorageRule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rule, err := r.toModel()
+		if err != nil {
+			h.writeS3Error(c, http.StatusBadRequest, S3ErrMalformedXML, err.Error(), bucket, "")
+			return
+		}
+		rules = append(rules, rule)
+	}
+	if err := projectSimulation(c, h.simulator).BucketStore().SetLifecycle(h.s3Provider(c), bucket, rules); err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *ProviderSimulationHandlers) s3DeleteBucketLifecycle(c *gin.Context, bucket string) {
+	if err := projectSimulation(c, h.simulator).BucketStore().DeleteLifecycle(h.s3Provider(c), bucket); err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (r s3LifecycleRule) toModel() (models.ObjectStorageRule, error) {
+	rule := models.ObjectStorageRule{ID: r.ID, Status: r.Status}
+	filter := &models.ObjectStorageRuleFilter{}
+	if r.Prefix != nil {
+		filter.Prefix = *r.Prefix
+	}
+	if f := r.Filter; f != nil {
+		if f.Prefix != nil {
+			filter.Prefix = *f.Prefix
+		}
+		if f.Tag != nil {
+			filter.Tags = map[string]string{f.Tag.Key: f.Tag.Value}
+		}
+		if f.And != nil {
+			filter.Prefix = f.And.Prefix
+			filter.Tags = make(map[string]string, len(f.And.Tags))
+			for _, t := range f.And.Tags {
+				filter.Tags[t.Key] = t.Value
+			}
+		}
+	}
+	if filter.Prefix != "" || len(filter.Tags) > 0 {
+		rule.Filter = filter
+	}
+	if e := r.Expiration; e != nil {
+		date, err := parseS3LifecycleDate(e.Date)
+		if err != nil {
+			return rule, err
+		}
+		rule.Expiration = &models.ObjectStorageExpiration{Days: e.Days, Date: date, ExpiredObjectDeleteMarker: e.ExpiredObjectDeleteMarker}
+	}
+	for _, t := range r.Transitions {
+		date, err := parseS3LifecycleDate(t.Date)
+		if err != nil {
+			return rule, err
+		}
+		rule.Transitions = append(rule.Transitions, models.ObjectStorageTransition{Days: t.Days, Date: date, StorageClass: t.StorageClass})
+	}
+	if n := r.NoncurrentVersionExpiration; n != nil {
+		rule.NoncurrentVersionExpiration = &models.ObjectStorageNoncurrentVersionExpiration{NoncurrentDays: n.NoncurrentDays, NewerNoncurrentVersions: n.NewerNoncurrentVersions}
+	}
+	if a := r.AbortIncompleteMultipartUpload; a != nil {
+		rule.AbortIncompleteMultipartUpload = &models.ObjectStorageAbortIncompleteMultipartUpload{DaysAfterInitiation: a.DaysAfterInitiation}
+	}
+	return rule, nil
+}
+
+func s3LifecycleRuleFromModel(rule models.ObjectStorageRule) s3LifecycleRule {
+	r := s3LifecycleRule{ID: rule.ID, Status: rule.Status, Filter: &s3LifecycleFilter{}}
+	if f := rule.Filter; f != nil {
+		switch {
+		case len(f.Tags) == 0:
+			r.Filter.Prefix = &f.Prefix
+		case len(f.Tags) == 1 && f.Prefix == "":
+			for k, v := range f.Tags {
+				r.Filter.Tag = &s3LifecycleTag{Key: k, Value: v}
+			}
+		default:
+			r.Filter.And = &s3LifecycleAnd{Prefix: f.Prefix}
+			for _, k := range sortedStringKeys(f.Tags) {
+				r.Filter.And.Tags = append(r.Filter.And.Tags, s3LifecycleTag{Key: k, Value: f.Tags[k]})
+			}
+		}
+	} else {
+		empty := ""
+		r.Filter.Prefix = &empty
+	}
+	if e := rule.Expiration; e != nil {
+		r.Expiration = &s3LifecycleExpiration{Days: e.Days, Date: formatS3LifecycleDate(e.Date), ExpiredObjectDeleteMarker: e.ExpiredObjectDeleteMarker}
+	}
+	for _, t := range rule.Transitions {
+		r.Transitions = append(r.Transitions, s3LifecycleTransition{Days: t.Days, Date: formatS3LifecycleDate(t.Date), StorageClass: t.StorageClass})
+	}
+	if n := rule.NoncurrentVersionExpiration; n != nil {
+		r.NoncurrentVersionExpiration = &s3LifecycleNoncurrentExpiration{NoncurrentDays: n.NoncurrentDays, NewerNoncurrentVersions: n.NewerNoncurrentVersions}
+	}
+	if a := rule.AbortIncompleteMultipartUpload; a != nil {
+		r.AbortIncompleteMultipartUpload = &s3LifecycleAbortMultipart{DaysAfterInitiation: a.DaysAfterInitiation}
+	}
+	return r
+}
+
+func parseS3LifecycleDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New("lifecycle dates must be ISO 8601 timestamps at midnight UTC")
+	}
+	return &t, nil
+}
+
+func formatS3LifecycleDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(s3TimeFormat)
+}
+
+// s3ObjectTags parses the x-amz-tagging header ("k1=v1&k2=v2").
+func s3ObjectTags(header string) (map[string]string, error) {
+	if header == "" {
+		return nil, nil
+	}
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(values))
+	for k, v := range values {
+		tags[k] = v[0]
+	}
+	return tags, nil
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// advanceSimClock moves the simulation clock forward and returns the lifecycle actions taken.
+func advanceSimClock(t *testing.T, srv *httptest.Server, days int) []map[string]interface{} {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"days": days})
+	resp, err := http.Post(srv.URL+"/api/v1/simulate/admin/clock/advance", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("advance clock: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("advance clock: status %d", resp.StatusCode)
+	}
+	var out struct {
+		Actions []map[string]interface{} `json:"lifecycle_actions"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return out.Actions
+}
+
+func TestS3ProtocolLifecycleRules(t *testing.T) {
+	srv := newS3TestServer(t)
+	client := newS3TestClient(srv, true)
+	ctx := context.Background()
+	bucket := aws.String("lifecycle")
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: bucket}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	_, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: bucket})
+	if code := s3ErrorCode(err); code != S3ErrNoSuchLifecycleConfiguration {
+		t.Fatalf("GetBucketLifecycleConfiguration without rules: expected %s, got %v", S3ErrNoSuchLifecycleConfiguration, err)
+	}
+	if _, err := client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: bucket,
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: []types.LifecycleRule{
+			{
+				ID:         aws.String("expire-logs"),
+				Status:     types.ExpirationStatusEnabled,
+				Filter:     &types.LifecycleRuleFilter{Prefix: aws.String("logs/")},
+				Expiration: &types.LifecycleExpiration{Days: aws.Int32(30)},
+			},
+			{
+				ID:          aws.String("archive-cold"),
+				Status:      types.ExpirationStatusEnabled,
+				Filter:      &types.LifecycleRuleFilter{Tag: &types.Tag{Key: aws.String("class"), Value: aws.String("cold")}},
+				Transitions: []types.Transition{{Days: aws.Int32(10), StorageClass: types.TransitionStorageClassGlacier}},
+			},
+			{
+				ID:                             aws.String("abort-uploads"),
+				Status:                         types.ExpirationStatusEnabled,
+				Filter:                         &types.LifecycleRuleFilter{Prefix: aws.String("")},
+				AbortIncompleteMultipartUpload: &types.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int32(7)},
+			},
+		}},
+	}); err != nil {
+		t.Fatalf("PutBucketLifecycleConfiguration: %v", err)
+	}
+	got, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: bucket})
+	if err != nil || len(got.Rules) != 3 || aws.ToInt32(got.Rules[0].Expiration.Days) != 30 || aws.ToString(got.Rules[1].Filter.Tag.Key) != "class" {
+		t.Fatalf("GetBucketLifecycleConfiguration = %+v, %v", got, err)
+	}
+
+	for key, tagging := range map[string]string{"logs/app.log": "", "data/archive.bin": "class=cold", "data/hot.bin": "class=hot"} {
+		input := &s3.PutObjectInput{Bucket: bucket, Key: aws.String(key), Body: strings.NewReader(key)}
+		if tagging != "" {
+			input.Tagging = aws.String(tagging)
+		}
+		if _, err := client.PutObject(ctx, input); err != nil {
+			t.Fatalf("PutObject(%s): %v", key, err)
+		}
+	}
+	if _, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: bucket, Key: aws.String("stale-upload")}); err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+
+	actions := advanceSimClock(t, srv, 8)
+	if len(actions) != 1 || actions[0]["action"] != "abort_incomplete_multipart_upload" {
+		t.Errorf("day 8 actions = %v, want one aborted upload", actions)
+	}
+	uploads, _ := client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: bucket})
+	if len(uploads.Uploads) != 0 {
+		t.Errorf("stale upload not aborted: %+v", uploads.Uploads)
+	}
+
+	actions = advanceSimClock(t, srv, 3)
+	if len(actions) != 1 || actions[0]["key"] != "data/archive.bin" || actions[0]["storage_class"] != "GLACIER" {
+		t.Errorf("day 11 actions = %v, want data/archive.bin transitioned", actions)
+	}
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: bucket, Key: aws.String("data/archive.bin")})
+	if err != nil || head.StorageClass != types.StorageClassGlacier {
+		t.Errorf("HeadObject storage class = %q, %v", head.StorageClass, err)
+	}
+
+	if actions := advanceSimClock(t, srv, 18); len(actions) != 0 {
+		t.Errorf("day 29 actions = %v, want none before the 30 day expiration", actions)
+	}
+	actions = advanceSimClock(t, srv, 2)
+	if len(actions) != 1 || actions[0]["key"] != "logs/app.log" || actions[0]["action"] != "expiration" {
+		t.Errorf("day 31 actions = %v, want logs/app.log expired", actions)
+	}
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: bucket, Key: aws.String("logs/app.log")})
+	if err == nil {
+		t.Error("logs/app.log still exists after expiration")
+	}
+	listed, _ := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: bucket})
+	if len(listed.Contents) != 2 {
+		t.Errorf("remaining objects = %d, want 2", len(listed.Contents))
+	}
+
+	if _, err := client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{Bucket: bucket}); err != nil {
+		t.Fatalf("DeleteBucketLifecycle: %v", err)
+	}
+}
+
+func TestSimulateLifecycleNoncurrentVersions(t *testing.T) {
+	srv := newS3TestServer(t)
+	client := newS3TestClient(srv, true)
+	ctx := context.Background()
+	bucket := aws.String("lifecycle-versions")
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: bucket}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  bucket,
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	}); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+
+	// Rules in the model's JSON form through the simulation operation API
+	body, _ := json.Marshal(map[string]interface{}{
+		"provider":  "aws",
+		"operation": "set_bucket_lifecycle",
+		"parameters": map[string]interface{}{
+			"bucket": "lifecycle-versions",
+			"lifecycle": []map[string]interface{}{
+				{"id": "noncurrent", "status": "Enabled", "noncurrent_version_expiration": map[string]interface{}{"noncurrent_days": 5}},
+				{"id": "markers", "status": "Enabled", "expiration": map[string]interface{}{"expired_object_delete_marker": true}},
+			},
+		},
+	})
+	resp, err := http.Post(srv.URL+"/api/v1/simulate/providers/aws/operations/set_bucket_lifecycle", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("set_bucket_lifecycle: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("set_bucket_lifecycle: status %d", resp.StatusCode)
+	}
+
+	for _, v := range []string{"v1", "v2"} {
+		if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: aws.String("db.dump"), Body: strings.NewReader(v)}); err != nil {
+			t.Fatalf("PutObject(%s): %v", v, err)
+		}
+	}
+	actions := advanceSimClock(t, srv, 6)
+	if len(actions) != 1 || actions[0]["action"] != "noncurrent_version_expiration" {
+		t.Fatalf("actions after 6 days = %v, want v1 expired", actions)
+	}
+
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: bucket, Key: aws.String("db.dump")}); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	actions = advanceSimClock(t, srv, 6)
+	if len(actions) != 2 || actions[0]["action"] != "noncurrent_version_expiration" || actions[1]["action"] != "expired_object_delete_marker" {
+		t.Fatalf("actions after 12 days = %v, want v2 expired and the marker removed", actions)
+	}
+	versions, _ := client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: bucket})
+	if len(versions.Versions)+len(versions.DeleteMarkers) != 0 {
+		t.Errorf("versions left = %+v / %+v", versions.Versions, versions.DeleteMarkers)
+	}
+	if _, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: bucket}); err != nil {
+		t.Errorf("DeleteBucket after lifecycle cleanup: %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	simulation "github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+// S3 multipart upload operations. Parts are validated and assembled by
+// simulation.MultipartUploads, which enforces the S3 part-number range, the 5 MiB
+// minimum for non-final parts and computes "<md5 of part md5s>-<count>" ETags.
+
+const (
+	s3DefaultMaxParts   = 1000
+	s3DefaultMaxUploads = 1000
+	s3CopySourceHeader  = "X-Amz-Copy-Source"
+	s3CopyRangeHeader   = "X-Amz-Copy-Source-Range"
+)
+
+type s3InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type s3CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+type s3CopyPartResult struct {
+	XMLName      xml.Name `xml:"CopyPartResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+type s3PartEntry struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+type s3ListPartsResult struct {
+	XMLName              xml.Name      `xml:"ListPartsResult"`
+	Xmlns                string        `xml:"xmlns,attr"`
+	Bucket               string        `xml:"Bucket"`
+	Key                  string        `xml:"Key"`
+	UploadID             string        `xml:"UploadId"`
+	Initiator            s3Owner       `xml:"Initiator"`
+	Owner                s3Owner       `xml:"Owner"`
+	StorageClass         string        `xml:"StorageClass"`
+	PartNumberMarker     int           `xml:"PartNumberMarker"`
+	NextPartNumberMarker int           `xml:"NextPartNumberMarker"`
+	MaxParts             int           `xml:"MaxParts"`
+	IsTruncated          bool          `xml:"IsTruncated"`
+	Parts                []s3PartEntry `xml:"Part"`
+}
+
+type s3UploadEntry struct {
+	Key          string  `xml:"Key"`
+	UploadID     string  `xml:"UploadId"`
+	Initiator    s3Owner `xml:"Initiator"`
+	Owner        s3Owner `xml:"Owner"`
+	StorageClass string  `xml:"StorageClass"`
+	Initiated    string  `xml:"Initiated"`
+}
+
+type s3ListMultipartUploadsResult struct {
+	XMLName            xml.Name        `xml:"ListMultipartUploadsResult"`
+	Xmlns              string          `xml:"xmlns,attr"`
+	Bucket             string          `xml:"Bucket"`
+	KeyMarker          string          `xml:"KeyMarker"`
+	UploadIDMarker     string          `xml:"UploadIdMarker"`
+	NextKeyMarker      string          `xml:"NextKeyMarker,omitempty"`
+	NextUploadIDMarker string          `xml:"NextUploadIdMarker,omitempty"`
+	Prefix             string          `xml:"Prefix"`
+	MaxUploads         int             `xml:"MaxUploads"`
+	IsTruncated        bool            `xml:"IsTruncated"`
+	Uploads            []s3UploadEntry `xml:"Upload"`
+}
+
+func (h *ProviderSimulationHandlers) s3CreateMultipartUpload(c *gin.Context, bucket, key string) {
+	contentType := c.GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = s3DefaultContentType
+	}
+	upload, err := projectSimulation(c, h.simulator).BucketStore().CreateMultipartUpload(h.s3Provider(c), bucket, key, contentType, s3UserMetadata(c.Request.Header))
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+	h.writeS3XML(c, http.StatusOK, s3InitiateMultipartUploadResult{
+		Xmlns:    s3XMLNamespace,
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: upload.UploadID,
+	})
+}
+
+// s3UploadPart handles UploadPart and, when x-amz-copy-source is set, UploadPartCopy.
+func (h *ProviderSimulationHandlers) s3UploadPart(c *gin.Context, bucket, key string) {
+	query := c.Request.URL.Query()
+	uploadID := query.Get("uploadId")
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "Part number must be an integer between 1 and 10000, inclusive", bucket, key)
+		return
+	}
+
+	if source := c.GetHeader(s3CopySourceHeader); source != "" {
+		h.s3UploadPartCopy(c, bucket, key, uploadID, partNumber, source)
+		return
+	}
+
+	body, err := h.s3ReadBody(c)
+	if err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, err.Error(), bucket, key)
+		return
+	}
+	part, err := projectSimulation(c, h.simulator).BucketStore().UploadPart(h.s3Provider(c), bucket, key, uploadID, partNumber, body)
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+	c.Header("ETag", quoteETag(part.ETag))
+	c.Status(http.StatusOK)
+}
+
+func (h *ProviderSimulationHandlers) s3UploadPartCopy(c *gin.Context, bucket, key, uploadID string, partNumber int, source string) {
+	srcBucket, srcKey, ok := parseS3CopySource(source)
+	if !ok {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "Copy Source must mention the source bucket and key: sourcebucket/sourcekey", bucket, key)
+		return
+	}
+	start, end := int64(0), int64(-1)
+	if copyRange := c.GetHeader(s3CopyRangeHeader); copyRange != "" {
+		if start, end, ok = parseS3CopyRange(copyRange); !ok {
+			h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy", bucket, key)
+			return
+		}
+	}
+	part, err := projectSimulation(c, h.simulator).BucketStore().UploadPartCopy(h.s3Provider(c), bucket, key, uploadID, partNumber, srcBucket, srcKey, start, end)
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+	h.writeS3XML(c, http.StatusOK, s3CopyPartResult{
+		Xmlns:        s3XMLNamespace,
+		LastModified: part.LastModified.UTC().Format(s3TimeFormat),
+		ETag:         quoteETag(part.ETag),
+	})
+}
+
+func (h *ProviderSimulationHandlers) s3CompleteMultipartUpload(c *gin.Context, bucket, key string) {
+	body, err := h.s3ReadBody(c)
+	if err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, err.Error(), bucket, key)
+		return
+	}
+	var req s3CompleteMultipartUpload
+	if err := xml.Unmarshal(body, &req); err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema.", bucket, key)
+		return
+	}
+	parts := make([]simulation.CompletedPart, 0, len(req.Parts))
+	for _, p := range req.Parts {
+		parts = append(parts, simulation.CompletedPart{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+	obj, err := projectSimulation(c, h.simulator).BucketStore().CompleteMultipartUpload(h.s3Provider(c), bucket, key, c.Query("uploadId"), parts)
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+	setS3VersionHeaders(c, obj)
+	h.writeS3XML(c, http.StatusOK, s3CompleteMultipartUploadResult{
+		Xmlns:    s3XMLNamespace,
+		Location: "http://" + c.Request.Host + c.Request.URL.Path,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     quoteETag(obj.ETag),
+	})
+}
+
+func (h *ProviderSimulationHandlers) s3AbortMultipartUpload(c *gin.Context, bucket, key string) {
+	if err := projectSimulation(c, h.simulator).BucketStore().AbortMultipartUpload(h.s3Provider(c), bucket, key, c.Query("uploadId")); err != nil {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ProviderSimulationHandlers) s3ListParts(c *gin.Context, bucket, key string) {
+	query := c.Request.URL.Query()
+	maxParts, ok := s3QueryLimit(query.Get("max-parts"), s3DefaultMaxParts)
+	if !ok {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "Provided max-parts not an integer or within integer range", bucket, key)
+		return
+	}
+	marker, _ := strconv.Atoi(query.Get("part-number-marker"))
+
+	upload, parts, err := projectSimulation(c, h.simulator).BucketStore().ListParts(h.s3Provider(c), bucket, key, query.Get("uploadId"))
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+	owner := s3Owner{ID: s3SimOwnerID, DisplayName: s3SimOwnerID}
+	result := s3ListPartsResult{
+		Xmlns:            s3XMLNamespace,
+		Bucket:           bucket,
+		Key:              key,
+		UploadID:         upload.UploadID,
+		Initiator:        owner,
+		Owner:            owner,
+		StorageClass:     "STANDARD",
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+	}
+	for _, p := range parts {
+		if p.PartNumber <= marker {
+			continue
+		}
+		if len(result.Parts) >= maxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, s3PartEntry{
+			PartNumber:   p.PartNumber,
+			LastModified: p.LastModified.UTC().Format(s3TimeFormat),
+			ETag:         quoteETag(p.ETag),
+			Size:         p.Size(),
+		})
+		result.NextPartNumberMarker = p.PartNumber
+	}
+	h.writeS3XML(c, http.StatusOK, result)
+}
+
+func (h *ProviderSimulationHandlers) s3ListMultipartUploads(c *gin.Context, bucket string) {
+	query := c.Request.URL.Query()
+	maxUploads, ok := s3QueryLimit(query.Get("max-uploads"), s3DefaultMaxUploads)
+	if !ok {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "Provided max-uploads not an integer or within integer range", bucket, "")
+		return
+	}
+	prefix := query.Get("prefix")
+	keyMarker := query.Get("key-marker")
+	uploadIDMarker := query.Get("upload-id-marker")
+
+	uploads, err := projectSimulation(c, h.simulator).BucketStore().ListMultipartUploads(h.s3Provider(c), bucket, prefix)
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+	owner := s3Owner{ID: s3SimOwnerID, DisplayName: s3SimOwnerID}
+	result := s3ListMultipartUploadsResult{
+		Xmlns:          s3XMLNamespace,
+		Bucket:         bucket,
+		KeyMarker:      keyMarker,
+		UploadIDMarker: uploadIDMarker,
+		Prefix:         prefix,
+		MaxUploads:     maxUploads,
+	}
+	// Uploads are ordered by key. Everything up to key-marker is skipped; within the
+	// marker key, only uploads listed after upload-id-marker are returned.
+	skipping := keyMarker != ""
+	for _, u := range uploads {
+		if skipping {
+			if u.Key < keyMarker {
+				continue
+			}
+			if u.Key == keyMarker {
+				if u.UploadID == uploadIDMarker {
+					skipping = false
+				}
+				continue
+			}
+			skipping = false
+		}
+		if len(result.Uploads) >= maxUploads {
+			result.IsTruncated = true
+			break
+		}
+		result.Uploads = append(result.Uploads, s3UploadEntry{
+			Key:          u.Key,
+			UploadID:     u.UploadID,
+			Initiator:    owner,
+			Owner:        owner,
+			StorageClass: "STANDARD",
+			Initiated:    u.Initiated.UTC().Format(s3TimeFormat),
+		})
+		result.NextKeyMarker = u.Key
+		result.NextUploadIDMarker = u.UploadID
+	}
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextUploadIDMarker = "", ""
+	}
+	h.writeS3XML(c, http.StatusOK, result)
+}
+
+// parseS3CopySource splits an x-amz-copy-source value ("[/]bucket/key[?versionId=...]").
+func parseS3CopySource(source string) (string, string, bool) {
+	source, _, _ = strings.Cut(source, "?")
+	decoded, err := url.PathUnescape(source)
+	if err != nil {
+		return "", "", false
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(decoded, "/"), "/")
+	if !ok || bucket == "" || key == "" {
+		return "", "", false
+	}
+	return bucket, key, true
+}
+
+// parseS3CopyRange parses "bytes=first-last" (both offsets required and inclusive).
+func parseS3CopyRange(header string) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return 0, 0, false
+	}
+	startStr, endStr, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err1 := strconv.ParseInt(startStr, 10, 64)
+	end, err2 := strconv.ParseInt(endStr, 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// s3QueryLimit parses a max-* query parameter, capping it at def.
+func s3QueryLimit(value string, def int) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	if n > def {
+		n = def
+	}
+	return n, true
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// expectedMultipartETag mirrors the S3 algorithm for a payload split into partSize chunks.
+func expectedMultipartETag(data []byte, partSize int) string {
+	var digests []byte
+	parts := 0
+	for off := 0; off < len(data); off += partSize {
+		end := off + partSize
+		if end > len(data) {
+			end = len(data)
+		}
+		sum := md5.Sum(data[off:end])
+		digests = append(digests, sum[:]...)
+		parts++
+	}
+	sum := md5.Sum(digests)
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), parts)
+}
+
+func TestS3ProtocolUploadManager(t *testing.T) {
+	srv := newS3TestServer(t)
+	client := newS3TestClient(srv, true)
+	ctx := context.Background()
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("mp-bucket")}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 12*1024*1024/16) // 12 MiB -> 3 parts
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = manager.MinUploadPartSize
+		u.Concurrency = 2
+	})
+	out, err := uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("mp-bucket"),
+		Key:    aws.String("large/blob.bin"),
+		Body:   bytes.NewReader(payload),
+	})
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if want := expectedMultipartETag(payload, int(manager.MinUploadPartSize)); aws.ToString(out.ETag) != want {
+		t.Errorf("multipart ETag = %s, want %s", aws.ToString(out.ETag), want)
+	}
+
+	buf := manager.NewWriteAtBuffer(nil)
+	if _, err := manager.NewDownloader(client).Download(ctx, buf, &s3.GetObjectInput{Bucket: aws.String("mp-bucket"), Key: aws.String("large/blob.bin")}); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), payload) {
+		t.Errorf("downloaded %d bytes, content does not match uploaded %d bytes", len(buf.Bytes()), len(payload))
+	}
+
+	uploads, err := client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: aws.String("mp-bucket")})
+	if err != nil {
+		t.Fatalf("ListMultipartUploads: %v", err)
+	}
+	if len(uploads.Uploads) != 0 {
+		t.Errorf("completed upload still listed: %+v", uploads.Uploads)
+	}
+}
+
+func TestS3ProtocolMultipartErrorsAndAbort(t *testing.T) {
+	srv := newS3TestServer(t)
+	client := newS3TestClient(srv, true)
+	ctx := context.Background()
+	bucket := aws.String("mp-errors")
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: bucket}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: aws.String("source"), Body: strings.NewReader("copy-me-please")}); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: bucket, Key: aws.String("small")})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	uploadID := created.UploadId
+
+	_, err = client.UploadPart(ctx, &s3.UploadPartInput{Bucket: bucket, Key: aws.String("small"), UploadId: uploadID, PartNumber: aws.Int32(10001), Body: strings.NewReader("x")})
+	if code := s3ErrorCode(err); code != S3ErrInvalidArgument {
+		t.Errorf("part 10001: expected %s, got %v", S3ErrInvalidArgument, err)
+	}
+	part1, err := client.UploadPart(ctx, &s3.UploadPartInput{Bucket: bucket, Key: aws.String("small"), UploadId: uploadID, PartNumber: aws.Int32(1), Body: strings.NewReader("too small")})
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	part2, err := client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+		Bucket: bucket, Key: aws.String("small"), UploadId: uploadID, PartNumber: aws.Int32(2),
+		CopySource: aws.String("mp-errors/source"), CopySourceRange: aws.String("bytes=0-6"),
+	})
+	if err != nil {
+		t.Fatalf("UploadPartCopy: %v", err)
+	}
+	if want := `"` + hex.EncodeToString(md5Sum([]byte("copy-me"))) + `"`; aws.ToString(part2.CopyPartResult.ETag) != want {
+		t.Errorf("UploadPartCopy ETag = %s, want %s", aws.ToString(part2.CopyPartResult.ETag), want)
+	}
+
+	parts, err := client.ListParts(ctx, &s3.ListPartsInput{Bucket: bucket, Key: aws.String("small"), UploadId: uploadID})
+	if err != nil {
+		t.Fatalf("ListParts: %v", err)
+	}
+	if len(parts.Parts) != 2 || aws.ToInt64(parts.Parts[1].Size) != 7 {
+		t.Errorf("ListParts = %+v", parts.Parts)
+	}
+
+	complete := func(ps ...types.CompletedPart) error {
+		_, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket: bucket, Key: aws.String("small"), UploadId: uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: ps},
+		})
+		return err
+	}
+	p1 := types.CompletedPart{PartNumber: aws.Int32(1), ETag: part1.ETag}
+	p2 := types.CompletedPart{PartNumber: aws.Int32(2), ETag: part2.CopyPartResult.ETag}
+	if code := s3ErrorCode(complete(p2, p1)); code != S3ErrInvalidPartOrder {
+		t.Errorf("unordered parts: expected %s, got %s", S3ErrInvalidPartOrder, code)
+	}
+	if code := s3ErrorCode(complete(types.CompletedPart{PartNumber: aws.Int32(1), ETag: aws.String(`"deadbeef"`)})); code != S3ErrInvalidPart {
+		t.Errorf("wrong ETag: expected %s, got %s", S3ErrInvalidPart, code)
+	}
+	if code := s3ErrorCode(complete(p1, p2)); code != S3ErrEntityTooSmall {
+		t.Errorf("small non-final part: expected %s, got %s", S3ErrEntityTooSmall, code)
+	}
+
+	listed, err := client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: bucket})
+	if err != nil || len(listed.Uploads) != 1 || aws.ToString(listed.Uploads[0].UploadId) != aws.ToString(uploadID) {
+		t.Fatalf("ListMultipartUploads = %+v, %v", listed, err)
+	}
+	if _, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: bucket, Key: aws.String("small"), UploadId: uploadID}); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	_, err = client.ListParts(ctx, &s3.ListPartsInput{Bucket: bucket, Key: aws.String("small"), UploadId: uploadID})
+	if code := s3ErrorCode(err); code != S3ErrNoSuchUpload {
+		t.Errorf("ListParts after abort: expected %s, got %v", S3ErrNoSuchUpload, err)
+	}
+
+	// A single small part is fine: only non-final parts have a minimum size
+	single, _ := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: bucket, Key: aws.String("single")})
+	only, err := client.UploadPart(ctx, &s3.UploadPartInput{Bucket: bucket, Key: aws.String("single"), UploadId: single.UploadId, PartNumber: aws.Int32(1), Body: strings.NewReader("tiny")})
+	if err != nil {
+		t.Fatalf("UploadPart single: %v", err)
+	}
+	if _, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket: bucket, Key: aws.String("single"), UploadId: single.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: []types.CompletedPart{{PartNumber: aws.Int32(1), ETag: only.ETag}}},
+	}); err != nil {
+		t.Fatalf("CompleteMultipartUpload single: %v", err)
+	}
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: bucket, Key: aws.String("single")})
+	if err != nil {
+		t.Fatalf("GetObject single: %v", err)
+	}
+	body, _ := io.ReadAll(get.Body)
+	get.Body.Close()
+	if string(body) != "tiny" || !strings.HasSuffix(aws.ToString(get.ETag), `-1"`) {
+		t.Errorf("GetObject single = %q, ETag %s", body, aws.ToString(get.ETag))
+	}
+}
+
+func md5Sum(data []byte) []byte {
+	sum := md5.Sum(data)
+	return sum[:]
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	simulation "github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	"go.uber.org/zap"
+)
+
+// S3 bucket policies (GET/PUT/DELETE ?policy) and their enforcement. Every S3 request
+// against a bucket that has a policy is evaluated by the simulation policy engine
+// before it is dispatched; denied requests get AccessDenied. The principal is the
+// access key of a verified SigV4 signature; without SigV4 verification every request
+// is anonymous.
+
+// s3AccessKeyKey holds the access key a request's SigV4 signature was verified with
+const s3AccessKeyKey = "cube.s3.access_key"
+
+// s3ProjectAllowed reports whether an S3 request may act on its project. The S3
+// routes take no API token, so once SigV4 or API authentication is on, projects other
+// than the default one need a verified access key bound to them.
+func (h *ProviderSimulationHandlers) s3ProjectAllowed(c *gin.Context) bool {
+	project := projectName(c)
+	if project == models.DefaultProject || (h.s3Auth == nil && !h.s3RequireProjectKey) {
+		return true
+	}
+	return h.s3Projects[c.GetString(s3AccessKeyKey)][project]
+}
+
+// s3PolicyManagementActions are not subject to the bucket policy when the request is
+// signed with a verified owner key. On AWS the bucket owner can always read, replace
+// and remove the policy, so a policy cannot lock the bucket for good.
+var s3PolicyManagementActions = map[string]bool{
+	"s3:GetBucketPolicy":    true,
+	"s3:PutBucketPolicy":    true,
+	"s3:DeleteBucketPolicy": true,
+}
+
+// s3Authorize checks the request against the bucket policy and writes AccessDenied
+// when it is not allowed. Copies are also checked for s3:GetObject on their source.
+func (h *ProviderSimulationHandlers) s3Authorize(c *gin.Context, bucket, key string) bool {
+	req := h.s3PolicyRequest(c, bucket, key)
+	if s3PolicyManagementActions[req.Action] && h.s3Owners[req.Principal] {
+		return true
+	}
+	requests := []simulation.PolicyRequest{req}
+	if source := c.GetHeader(s3CopySourceHeader); source != "" && c.Request.Method == http.MethodPut {
+		if srcBucket, srcKey, ok := parseS3CopySource(source); ok {
+			src := req
+			src.Action, src.Bucket, src.Key = "s3:GetObject", srcBucket, srcKey
+			requests = append(requests, src)
+		}
+	}
+	for _, r := range requests {
+		decision, err := projectSimulation(c, h.simulator).BucketStore().AuthorizeRequest(h.s3Provider(c), r)
+		if err != nil || decision.Allowed {
+			// Missing buckets are reported by the operation itself
+			continue
+		}
+		h.logger.Info("S3 request denied by bucket policy",
+			zap.String("action", r.Action),
+			zap.String("resource", decision.Resource),
+			zap.String("principal", r.Principal),
+			zap.String("reason", decision.Reason))
+		h.writeS3Error(c, http.StatusForbidden, S3ErrAccessDenied, "Access Denied", bucket, key)
+		return false
+	}
+	return true
+}
+
+// s3PolicyRequest describes an S3 request for policy evaluation.
+func (h *ProviderSimulationHandlers) s3PolicyRequest(c *gin.Context, bucket, key string) simulation.PolicyRequest {
+	query := c.Request.URL.Query()
+	req := simulation.PolicyRequest{
+		Action:          s3RequestAction(c.Request.Method, query, key != ""),
+		Bucket:          bucket,
+		Key:             key,
+		Principal:       c.GetString(s3AccessKeyKey),
+		SourceIP:        h.trustedProxies.clientIP(c.Request),
+		SecureTransport: h.trustedProxies.secureTransport(c.Request),
+		Context:         map[string]string{},
+	}
+	for param, conditionKey := range map[string]string{"prefix": "s3:prefix", "delimiter": "s3:delimiter", "max-keys": "s3:max-keys", "versionId": "s3:VersionId"} {
+		if _, ok := query[param]; ok {
+			req.Context[conditionKey] = query.Get(param)
+		}
+	}
+	if class := c.GetHeader(s3StorageClassHeader); class != "" {
+		req.Context["s3:x-amz-storage-class"] = class
+	}
+	if ua := c.GetHeader("User-Agent"); ua != "" {
+		req.Context["aws:UserAgent"] = ua
+	}
+	return req
+}
+
+// s3RequestAction names the IAM action of an S3 request, following the same routing
+// as s3BucketOperation and s3ObjectOperation.
+func s3RequestAction(method string, query url.Values, object bool) string {
+	has := func(param string) bool {
+		_, ok := query[param]
+		return ok
+	}
+	if object {
+		switch method {
+		case http.MethodPut, http.MethodPost:
+			return "s3:PutObject"
+		case http.MethodGet, http.MethodHead:
+			switch {
+			case has("uploadId"):
+				return "s3:ListMultipartUploadParts"
+			case query.Get("versionId") != "":
+				return "s3:GetObjectVersion"
+			}
+			return "s3:GetObject"
+		case http.MethodDelete:
+			switch {
+			case has("uploadId"):
+				return "s3:AbortMultipartUpload"
+			case query.Get("versionId") != "":
+				return "s3:DeleteObjectVersion"
+			}
+			return "s3:DeleteObject"
+		}
+		return ""
+	}
+	switch method {
+	case http.MethodPut:
+		switch {
+		case has("versioning"):
+			return "s3:PutBucketVersioning"
+		case has("lifecycle"):
+			return "s3:PutLifecycleConfiguration"
+		case has("policy"):
+			return "s3:PutBucketPolicy"
+		}
+		return "s3:CreateBucket"
+	case http.MethodDelete:
+		switch {
+		case has("lifecycle"):
+			// AWS has no separate action for removing a lifecycle configuration
+			return "s3:PutLifecycleConfiguration"
+		case has("policy"):
+			return "s3:DeleteBucketPolicy"
+		}
+		return "s3:DeleteBucket"
+	case http.MethodGet, http.MethodHead:
+		switch {
+		case has("location"):
+			return "s3:GetBucketLocation"
+		case has("uploads"):
+			return "s3:ListBucketMultipartUploads"
+		case has("versioning"):
+			return "s3:GetBucketVersioning"
+		case has("versions"):
+			return "s3:ListBucketVersions"
+		case has("lifecycle"):
+			return "s3:GetLifecycleConfiguration"
+		case has("policy"):
+			return "s3:GetBucketPolicy"
+		}
+		return "s3:ListBucket"
+	}
+	return ""
+}
+
+func (h *ProviderSimulationHandlers) s3GetBucketPolicy(c *gin.Context, bucket string) {
+	policy, err := projectSimulation(c, h.simulator).BucketStore().Policy(h.s3Provider(c), bucket)
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+	c.JSON(http.StatusOK, s3PolicyDocument(policy))
+}
+
+func (h *ProviderSimulationHandlers) s3PutBucketPolicy(c *gin.Context, bucket string) {
+	body, err := h.s3ReadBody(c)
+	if err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, err.Error(), bucket, "")
+		return
+	}
+	policy, err := simulation.ParseBucketPolicy(body)
+	if err == nil {
+		err = projectSimulation(c, h.simulator).BucketStore().SetPolicy(h.s3Provider(c), bucket, policy)
+	}
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ProviderSimulationHandlers) s3DeleteBucketPolicy(c *gin.Context, bucket string) {
+	if err := projectSimulation(c, h.simulator).BucketStore().DeletePolicy(h.s3Provider(c), bucket); err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// s3PolicyDocument renders a policy in the AWS document format returned by GetBucketPolicy.
+func s3PolicyDocument(policy *models.ObjectStoragePolicy) map[string]interface{} {
+	statements := make([]map[string]interface{}, 0, len(policy.Statement))
+	for _, st := range policy.Statement {
+		doc := map[string]interface{}{
+			"Effect":   st.Effect,
+			"Action":   st.Action,
+			"Resource": st.Resource,
+		}
+		if st.Sid != "" {
+			doc["Sid"] = st.Sid
+		}
+		if len(st.Principal) > 0 {
+			doc["Principal"] = st.Principal
+		}
+		if len(st.Condition) > 0 {
+			doc["Condition"] = st.Condition
+		}
+		statements = append(statements, doc)
+	}
+	return map[string]interface{}{"Version": policy.Version, "Statement": statements}
+}
+
+// ExplainBucketPolicy handles POST /api/v1/simulate/policy/explain. It evaluates a
+// request against a bucket policy without performing it and reports which statement
+// allowed or denied it. A "policy" in the body is evaluated instead of the bucket's
+// stored policy, so policies can be tested before they are applied.
+func (h *ProviderSimulationHandlers) ExplainBucketPolicy(c *gin.Context) {
+	var params map[string]interface{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	provider, _ := params["provider"].(string)
+	if provider == "" {
+		provider = h.s3ProviderName
+	}
+	if provider == "" {
+		provider = s3DefaultProvider
+	}
+	decision, err := projectSimulation(c, h.simulator).ExplainPolicy(provider, params)
+	switch {
+	case errors.Is(err, simulation.ErrNoSuchBucket):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, decision)
+	}
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	"go.uber.org/zap"
+)
+
+const testBucketPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "PublicRead", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::policy-bucket/public/*"},
+    {"Sid": "OwnerFullAccess", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::123456789012:user/AKIDSIMULATED"},
+     "Action": "s3:*", "Resource": ["arn:aws:s3:::policy-bucket", "arn:aws:s3:::policy-bucket/*"]},
+    {"Sid": "NoDeletesFromOutside", "Effect": "Deny", "Principal": "*", "Action": "s3:Delete*", "Resource": "arn:aws:s3:::policy-bucket/*",
+     "Condition": {"NotIpAddress": {"aws:SourceIp": "10.0.0.0/8"}}}
+  ]
+}`
+
+// newPolicyS3TestServer starts the S3 simulation with SigV4 verification for the owner
+// key AKIDSIMULATED and the key AKIDREADER.
+func newPolicyS3TestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+	cfg := &internal.ServerConfig{}
+	cfg.Storage.S3.Auth.Enabled = true
+	cfg.Storage.S3.Auth.Credentials = []internal.S3Credential{
+		{AccessKey: "AKIDSIMULATED", SecretKey: "simulated-secret", Owner: true},
+		{AccessKey: "AKIDREADER", SecretKey: "reader-secret"},
+	}
+	r := newTestRouter(t, RouteOptions{Logger: zap.NewNop(), Sim: NewTestSimulationService(), Config: cfg})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestS3ProtocolBucketPolicyEnforcement(t *testing.T) {
+	srv := newPolicyS3TestServer(t)
+	owner := newSignedS3Client(srv, "AKIDSIMULATED", "simulated-secret")
+	reader := newSignedS3Client(srv, "AKIDREADER", "reader-secret")
+	ctx := context.Background()
+	bucket := aws.String("policy-bucket")
+	if _, err := owner.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: bucket}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	for _, key := range []string{"public/readme.txt", "private/secret.txt"} {
+		if _, err := owner.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: aws.String(key), Body: strings.NewReader(key)}); err != nil {
+			t.Fatalf("PutObject(%s): %v", key, err)
+		}
+	}
+
+	_, err := owner.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: bucket})
+	if code := s3ErrorCode(err); code != S3ErrNoSuchBucketPolicy {
+		t.Fatalf("GetBucketPolicy without policy: expected %s, got %v", S3ErrNoSuchBucketPolicy, err)
+	}
+	_, err = owner.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{Bucket: bucket, Policy: aws.String(`{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::other-bucket/*"}]}`)})
+	if code := s3ErrorCode(err); code != S3ErrMalformedPolicy {
+		t.Fatalf("PutBucketPolicy for another bucket: expected %s, got %v", S3ErrMalformedPolicy, err)
+	}
+	if _, err := owner.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{Bucket: bucket, Policy: aws.String(testBucketPolicy)}); err != nil {
+		t.Fatalf("PutBucketPolicy: %v", err)
+	}
+	got, err := owner.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: bucket})
+	if err != nil || !strings.Contains(aws.ToString(got.Policy), `"Sid":"OwnerFullAccess"`) {
+		t.Fatalf("GetBucketPolicy = %v, %v", aws.ToString(got.Policy), err)
+	}
+
+	checks := []struct {
+		name     string
+		call     func() error
+		wantCode string
+	}{
+		{"owner reads private", func() error {
+			_, err := owner.GetObject(ctx, &s3.GetObjectInput{Bucket: bucket, Key: aws.String("private/secret.txt")})
+			return err
+		}, ""},
+		{"owner lists", func() error {
+			_, err := owner.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: bucket})
+			return err
+		}, ""},
+		{"owner deletes from outside 10/8", func() error {
+			_, err := owner.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: bucket, Key: aws.String("private/secret.txt")})
+			return err
+		}, S3ErrAccessDenied},
+		{"reader reads public", func() error {
+			_, err := reader.GetObject(ctx, &s3.GetObjectInput{Bucket: bucket, Key: aws.String("public/readme.txt")})
+			return err
+		}, ""},
+		{"reader reads private", func() error {
+			_, err := reader.GetObject(ctx, &s3.GetObjectInput{Bucket: bucket, Key: aws.String("private/secret.txt")})
+			return err
+		}, S3ErrAccessDenied},
+		{"reader writes", func() error {
+			_, err := reader.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: aws.String("public/upload.txt"), Body: strings.NewReader("x")})
+			return err
+		}, S3ErrAccessDenied},
+		{"reader lists", func() error {
+			_, err := reader.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: bucket})
+			return err
+		}, S3ErrAccessDenied},
+		{"reader reads the policy", func() error {
+			_, err := reader.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: bucket})
+			return err
+		}, S3ErrAccessDenied},
+		{"reader removes the policy", func() error {
+			_, err := reader.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{Bucket: bucket})
+			return err
+		}, S3ErrAccessDenied},
+	}
+	for _, check := range checks {
+		err := check.call()
+		if check.wantCode == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", check.name, err)
+		}
+		if check.wantCode != "" && s3ErrorCode(err) != check.wantCode {
+			t.Errorf("%s: expected %s, got %v", check.name, check.wantCode, err)
+		}
+	}
+
+	// A policy denying everything still leaves its management to the owner key
+	denyAll := `{"Statement": [{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": ["arn:aws:s3:::policy-bucket", "arn:aws:s3:::policy-bucket/*"]}]}`
+	if _, err := owner.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{Bucket: bucket, Policy: aws.String(denyAll)}); err != nil {
+		t.Fatalf("PutBucketPolicy: %v", err)
+	}
+	if _, err := owner.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: bucket}); s3ErrorCode(err) != S3ErrAccessDenied {
+		t.Errorf("owner lists under a deny-all policy: expected %s, got %v", S3ErrAccessDenied, err)
+	}
+	if _, err := owner.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{Bucket: bucket}); err != nil {
+		t.Fatalf("DeleteBucketPolicy: %v", err)
+	}
+	if _, err := reader.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: aws.String("public/upload.txt"), Body: strings.NewReader("x")}); err != nil {
+		t.Errorf("PutObject after DeleteBucketPolicy: %v", err)
+	}
+}
+
+func TestS3ProtocolBucketPolicyRequestContext(t *testing.T) {
+	policy := `{"Statement": [
+    {"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::context-bucket/internal/*",
+     "Condition": {"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}},
+    {"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::context-bucket/tls/*",
+     "Condition": {"Bool": {"aws:SecureTransport": "true"}}},
+    {"Effect": "Allow", "Principal": {"AWS": "AKIDSIMULATED"}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::context-bucket/owner/*"}
+  ]}`
+	newServer := func(t *testing.T, trustedProxies []string) *httptest.Server {
+		t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+		cfg := &internal.ServerConfig{}
+		cfg.API.TrustedProxies = trustedProxies
+		r := newTestRouter(t, RouteOptions{Logger: zap.NewNop(), Sim: NewTestSimulationService(), Config: cfg})
+		srv := httptest.NewServer(r)
+		t.Cleanup(srv.Close)
+		client := newS3TestClient(srv, true)
+		ctx := context.Background()
+		bucket := aws.String("context-bucket")
+		if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: bucket}); err != nil {
+			t.Fatalf("CreateBucket: %v", err)
+		}
+		for _, key := range []string{"internal/a.txt", "tls/a.txt", "owner/a.txt"} {
+			if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: aws.String(key), Body: strings.NewReader(key)}); err != nil {
+				t.Fatalf("PutObject(%s): %v", key, err)
+			}
+		}
+		if _, err := client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{Bucket: bucket, Policy: aws.String(policy)}); err != nil {
+			t.Fatalf("PutBucketPolicy: %v", err)
+		}
+		return srv
+	}
+
+	cases := []struct {
+		name    string
+		trusted []string
+		key     string
+		headers map[string]string
+		want    int
+	}{
+		{"forwarded address from the client", nil, "internal/a.txt", map[string]string{"X-Forwarded-For": "10.1.2.3"}, http.StatusForbidden},
+		{"forwarded address from a trusted proxy", []string{"127.0.0.1"}, "internal/a.txt", map[string]string{"X-Forwarded-For": "10.1.2.3"}, http.StatusOK},
+		{"forwarded address through an untrusted hop", []string{"127.0.0.0/8"}, "internal/a.txt", map[string]string{"X-Forwarded-For": "10.1.2.3, 192.0.2.1"}, http.StatusForbidden},
+		{"forwarded TLS from the client", nil, "tls/a.txt", map[string]string{"X-Forwarded-Proto": "https"}, http.StatusForbidden},
+		{"forwarded TLS from a trusted proxy", []string{"127.0.0.1"}, "tls/a.txt", map[string]string{"X-Forwarded-Proto": "https"}, http.StatusOK},
+		{"unverified credential", nil, "owner/a.txt", map[string]string{"Authorization": "AWS4-HMAC-SHA256 Credential=AKIDSIMULATED/20260101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=00"}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newServer(t, tc.trusted)
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/simulate/aws-s3/context-bucket/"+tc.key, nil)
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Errorf("status %d, want %d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+}
+
+func TestSimulatePolicyExplain(t *testing.T) {
+	srv := newS3TestServer(t)
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{"Sid": "RequireTLS", "Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::reports/*",
+				"Condition": map[string]interface{}{"Bool": map[string]interface{}{"aws:SecureTransport": "false"}}},
+			{"Sid": "ListPublic", "Effect": "Allow", "Principal": "*", "Action": "s3:ListBucket", "Resource": "arn:aws:s3:::reports",
+				"Condition": map[string]interface{}{"StringEquals": map[string]interface{}{"s3:prefix": []string{"public/", "shared/"}}}},
+			{"Sid": "AnalystsRead", "Effect": "Allow", "Principal": map[string]interface{}{"AWS": []string{"AKIDANALYST*"}}, "Action": "s3:Get*", "Resource": "arn:aws:s3:::reports/*"},
+		},
+	}
+	explain := func(req map[string]interface{}) (int, simulation.PolicyDecision) {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		resp, err := http.Post(srv.URL+"/api/v1/simulate/policy/explain", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("explain: %v", err)
+		}
+		defer resp.Body.Close()
+		var decision simulation.PolicyDecision
+		_ = json.NewDecoder(resp.Body).Decode(&decision)
+		return resp.StatusCode, decision
+	}
+
+	cases := []struct {
+		name      string
+		req       map[string]interface{}
+		decision  string
+		statement int
+	}{
+		{"list allowed prefix", map[string]interface{}{"action": "s3:ListBucket", "bucket": "reports", "context": map[string]string{"s3:prefix": "shared/"}}, simulation.PolicyDecisionAllow, 1},
+		{"list other prefix", map[string]interface{}{"action": "s3:ListBucket", "bucket": "reports", "context": map[string]string{"s3:prefix": "private/"}}, simulation.PolicyDecisionImplicitDeny, -1},
+		{"analyst over TLS", map[string]interface{}{"action": "s3:GetObject", "bucket": "reports", "key": "q3.csv", "principal": "AKIDANALYST01", "secure_transport": true}, simulation.PolicyDecisionAllow, 2},
+		{"analyst over plain HTTP", map[string]interface{}{"action": "s3:GetObject", "bucket": "reports", "key": "q3.csv", "principal": "AKIDANALYST01"}, simulation.PolicyDecisionExplicitDeny, 0},
+		{"anonymous read", map[string]interface{}{"action": "s3:GetObject", "bucket": "reports", "key": "q3.csv", "secure_transport": true}, simulation.PolicyDecisionImplicitDeny, -1},
+	}
+	for _, tc := range cases {
+		tc.req["policy"] = policy
+		status, decision := explain(tc.req)
+		if status != http.StatusOK || decision.Decision != tc.decision || decision.Statement != tc.statement {
+			t.Errorf("%s: got %d %s by statement %d (%s), want %s by statement %d", tc.name, status, decision.Decision, decision.Statement, decision.Reason, tc.decision, tc.statement)
+		}
+	}
+	if _, decision := explain(cases[3].req); len(decision.Statements) != 3 || decision.Statements[1].Reason != "action does not match" {
+		t.Errorf("statement evaluations = %+v", decision.Statements)
+	}
+
+	if status, _ := explain(map[string]interface{}{"action": "s3:GetObject", "bucket": "missing-bucket"}); status != http.StatusNotFound {
+		t.Errorf("explain against a missing bucket: status %d, want 404", status)
+	}
+	bad := map[string]interface{}{"action": "s3:GetObject", "bucket": "reports", "policy": map[string]interface{}{
+		"Statement": map[string]interface{}{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::reports/*",
+			"Condition": map[string]interface{}{"DateGreaterThan": map[string]interface{}{"aws:CurrentTime": "2020-01-01T00:00:00Z"}}},
+	}}
+	if status, _ := explain(bad); status != http.StatusBadRequest {
+		t.Errorf("explain with an unsupported condition operator: status %d, want 400", status)
+	}
+}
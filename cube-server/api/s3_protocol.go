@@ -0,0 +1,889 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	simulation "github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	"go.uber.org/zap"
+)
+
+// S3 wire-protocol simulation served under /api/v1/simulate/aws-s3/*path.
+//
+// Both path-style (/aws-s3/<bucket>/<key>) and virtual-host-style
+// (Host: <bucket>.<domain>, /aws-s3/<key>) addressing are supported. All state
+// lives in the shared simulation.BucketStore, so buckets created through the
+// JSON simulation endpoints are visible here as well.
+
+const (
+	s3XMLNamespace       = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3DefaultProvider    = "aws"
+	s3DefaultRegion      = "us-east-1"
+	s3SimOwnerID         = "cube-server-sim"
+	s3ProviderHeader     = "X-Cube-Sim-Provider"
+	s3DefaultMaxKeys     = 1000
+	s3StreamingPrefix    = "STREAMING-"
+	s3ContentSHA256      = "X-Amz-Content-Sha256"
+	s3MetaHeaderPrefix   = "X-Amz-Meta-"
+	s3DefaultContentType = "binary/octet-stream"
+	s3StorageClassHeader = "X-Amz-Storage-Class"
+)
+
+// S3 error codes returned by the simulator
+const (
+	S3ErrNoSuchBucket            = "NoSuchBucket"
+	S3ErrNoSuchKey               = "NoSuchKey"
+	S3ErrBucketAlreadyOwnedByYou = "BucketAlreadyOwnedByYou"
+	S3ErrBucketNotEmpty          = "BucketNotEmpty"
+	S3ErrTooManyBuckets          = "TooManyBuckets"
+	S3ErrInvalidRange            = "InvalidRange"
+	S3ErrInvalidArgument         = "InvalidArgument"
+	S3ErrInvalidBucketName       = "InvalidBucketName"
+	S3ErrMethodNotAllowed        = "MethodNotAllowed"
+	S3ErrMalformedXML            = "MalformedXML"
+	S3ErrInternalError           = "InternalError"
+	S3ErrNoSuchUpload            = "NoSuchUpload"
+	S3ErrInvalidPart             = "InvalidPart"
+	S3ErrInvalidPartOrder        = "InvalidPartOrder"
+	S3ErrEntityTooSmall          = "EntityTooSmall"
+	S3ErrNoSuchVersion           = "NoSuchVersion"
+	S3ErrNoSuchBucketPolicy      = "NoSuchBucketPolicy"
+	S3ErrMalformedPolicy         = "MalformedPolicy"
+
+	S3ErrNoSuchLifecycleConfiguration = "NoSuchLifecycleConfiguration"
+
+	S3ErrIllegalVersioningConfiguration = "IllegalVersioningConfigurationException"
+)
+
+type s3Error struct {
+	XMLName    xml.Name `xml:"Error"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	BucketName string   `xml:"BucketName,omitempty"`
+	Key        string   `xml:"Key,omitempty"`
+	Resource   string   `xml:"Resource,omitempty"`
+	RequestID  string   `xml:"RequestId"`
+
+	// Authentication details, mirroring what S3 returns for SigV4 failures
+	AWSAccessKeyID              string `xml:"AWSAccessKeyId,omitempty"`
+	StringToSign                string `xml:"StringToSign,omitempty"`
+	CanonicalRequest            string `xml:"CanonicalRequest,omitempty"`
+	SignatureProvided           string `xml:"SignatureProvided,omitempty"`
+	RequestTime                 string `xml:"RequestTime,omitempty"`
+	ServerTime                  string `xml:"ServerTime,omitempty"`
+	MaxAllowedSkewMilliseconds  string `xml:"MaxAllowedSkewMilliseconds,omitempty"`
+	ClientComputedContentSHA256 string `xml:"ClientComputedContentSHA256,omitempty"`
+	S3ComputedContentSHA256     string `xml:"S3ComputedContentSHA256,omitempty"`
+}
+
+type s3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type s3BucketEntry struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type s3ListAllMyBucketsResult struct {
+	XMLName xml.Name        `xml:"ListAllMyBucketsResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Owner   s3Owner         `xml:"Owner"`
+	Buckets []s3BucketEntry `xml:"Buckets>Bucket"`
+}
+
+type s3CreateBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	LocationConstraint string   `xml:"LocationConstraint"`
+}
+
+type s3LocationConstraint struct {
+	XMLName xml.Name `xml:"LocationConstraint"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Value   string   `xml:",chardata"`
+}
+
+type s3ObjectEntry struct {
+	Key          string   `xml:"Key"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+	Size         int64    `xml:"Size"`
+	StorageClass string   `xml:"StorageClass"`
+	Owner        *s3Owner `xml:"Owner,omitempty"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3ListBucketResult struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	Xmlns                 string           `xml:"xmlns,attr"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	EncodingType          string           `xml:"EncodingType,omitempty"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	Marker                *string          `xml:"Marker,omitempty"`
+	NextMarker            string           `xml:"NextMarker,omitempty"`
+	KeyCount              *int             `xml:"KeyCount,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	Contents              []s3ObjectEntry  `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+// GenericAWSS3SimHandler simulates AWS S3 SDK-compatible endpoints for the provider 'generic-aws-s3'
+func (h *ProviderSimulationHandlers) GenericAWSS3SimHandler(c *gin.Context) {
+	bucket, key := h.s3BucketAndKey(c)
+	h.logger.Debug("[GENERIC AWS S3 SIM] request",
+		zap.String("method", c.Request.Method),
+		zap.String("bucket", bucket),
+		zap.String("key", key))
+
+	if h.s3Auth != nil {
+		raw, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, err.Error(), bucket, key)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+		accessKey, aerr := h.s3Auth.Verify(c.Request, raw)
+		if aerr != nil {
+			h.logger.Info("S3 request rejected by SigV4 verification", zap.String("code", aerr.Code), zap.String("access_key", aerr.AccessKeyID))
+			h.writeS3AuthError(c, aerr)
+			return
+		}
+		c.Set(s3AccessKeyKey, accessKey)
+	}
+	if !h.s3ProjectAllowed(c) {
+		h.logger.Info("S3 request rejected for its project", zap.String("project", projectName(c)), zap.String("access_key", c.GetString(s3AccessKeyKey)))
+		h.writeS3Error(c, http.StatusForbidden, S3ErrAccessDenied, "Access Denied", bucket, key)
+		return
+	}
+	if bucket != "" && !h.s3Authorize(c, bucket, key) {
+		return
+	}
+	if !h.s3InjectFault(c, bucket, key) {
+		return
+	}
+
+	switch {
+	case bucket == "":
+		if c.Request.Method != http.MethodGet {
+			h.writeS3Error(c, http.StatusMethodNotAllowed, S3ErrMethodNotAllowed, "The specified method is not allowed against this resource.", "", "")
+			return
+		}
+		h.s3ListBuckets(c)
+	case key == "":
+		h.s3BucketOperation(c, bucket)
+	default:
+		h.s3ObjectOperation(c, bucket, key)
+	}
+}
+
+func (h *ProviderSimulationHandlers) s3BucketOperation(c *gin.Context, bucket string) {
+	query := c.Request.URL.Query()
+	switch c.Request.Method {
+	case http.MethodPut:
+		if _, ok := query["versioning"]; ok {
+			h.s3PutBucketVersioning(c, bucket)
+			return
+		}
+		if _, ok := query["lifecycle"]; ok {
+			h.s3PutBucketLifecycle(c, bucket)
+			return
+		}
+		if _, ok := query["policy"]; ok {
+			h.s3PutBucketPolicy(c, bucket)
+			return
+		}
+		h.s3CreateBucket(c, bucket)
+	case http.MethodHead:
+		h.s3HeadBucket(c, bucket)
+	case http.MethodDelete:
+		if _, ok := query["lifecycle"]; ok {
+			h.s3DeleteBucketLifecycle(c, bucket)
+			return
+		}
+		if _, ok := query["policy"]; ok {
+			h.s3DeleteBucketPolicy(c, bucket)
+			return
+		}
+		h.s3DeleteBucket(c, bucket)
+	case http.MethodGet:
+		if _, ok := query["location"]; ok {
+			h.s3GetBucketLocation(c, bucket)
+			return
+		}
+		if _, ok := query["uploads"]; ok {
+			h.s3ListMultipartUploads(c, bucket)
+			return
+		}
+		if _, ok := query["versioning"]; ok {
+			h.s3GetBucketVersioning(c, bucket)
+			return
+		}
+		if _, ok := query["versions"]; ok {
+			h.s3ListObjectVersions(c, bucket)
+			return
+		}
+		if _, ok := query["lifecycle"]; ok {
+			h.s3GetBucketLifecycle(c, bucket)
+			return
+		}
+		if _, ok := query["policy"]; ok {
+			h.s3GetBucketPolicy(c, bucket)
+			return
+		}
+		h.s3ListObjects(c, bucket)
+	default:
+		h.writeS3Error(c, http.StatusMethodNotAllowed, S3ErrMethodNotAllowed, "The specified method is not allowed against this resource.", bucket, "")
+	}
+}
+
+func (h *ProviderSimulationHandlers) s3ObjectOperation(c *gin.Context, bucket, key string) {
+	query := c.Request.URL.Query()
+	_, multipart := query["uploadId"]
+	switch c.Request.Method {
+	case http.MethodPut:
+		if multipart {
+			h.s3UploadPart(c, bucket, key)
+			return
+		}
+		h.s3PutObject(c, bucket, key)
+	case http.MethodPost:
+		if _, ok := query["uploads"]; ok {
+			h.s3CreateMultipartUpload(c, bucket, key)
+			return
+		}
+		if multipart {
+			h.s3CompleteMultipartUpload(c, bucket, key)
+			return
+		}
+		h.writeS3Error(c, http.StatusMethodNotAllowed, S3ErrMethodNotAllowed, "The specified method is not allowed against this resource.", bucket, key)
+	case http.MethodGet:
+		if multipart {
+			h.s3ListParts(c, bucket, key)
+			return
+		}
+		h.s3GetObject(c, bucket, key, false)
+	case http.MethodHead:
+		h.s3GetObject(c, bucket, key, true)
+	case http.MethodDelete:
+		if multipart {
+			h.s3AbortMultipartUpload(c, bucket, key)
+			return
+		}
+		if versionID := query.Get("versionId"); versionID != "" {
+			h.s3DeleteObjectVersion(c, bucket, key, versionID)
+			return
+		}
+		h.s3DeleteObject(c, bucket, key)
+	default:
+		h.writeS3Error(c, http.StatusMethodNotAllowed, S3ErrMethodNotAllowed, "The specified method is not allowed against this resource.", bucket, key)
+	}
+}
+
+// s3BucketAndKey resolves bucket and key from either the virtual host or the path.
+func (h *ProviderSimulationHandlers) s3BucketAndKey(c *gin.Context) (string, string) {
+	path := strings.TrimPrefix(c.Param("path"), "/")
+	if bucket := h.s3VirtualHostBucket(c.Request.Host); bucket != "" {
+		return bucket, path
+	}
+	bucket, key, _ := strings.Cut(path, "/")
+	return bucket, key
+}
+
+// s3VirtualHostBucket returns the bucket encoded in a virtual-host-style Host header,
+// e.g. "my-bucket.localhost:8080" -> "my-bucket".
+func (h *ProviderSimulationHandlers) s3VirtualHostBucket(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(host)
+	domains := h.s3VirtualHostDomains
+	if len(domains) == 0 {
+		domains = []string{"localhost"}
+	}
+	for _, domain := range domains {
+		suffix := "." + strings.ToLower(domain)
+		if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return strings.TrimSuffix(host, suffix)
+		}
+	}
+	return ""
+}
+
+// s3Provider returns the BucketStore provider the S3 API operates on.
+func (h *ProviderSimulationHandlers) s3Provider(c *gin.Context) string {
+	if p := c.GetHeader(s3ProviderHeader); p != "" {
+		return p
+	}
+	if h.s3ProviderName != "" {
+		return h.s3ProviderName
+	}
+	return s3DefaultProvider
+}
+
+func (h *ProviderSimulationHandlers) s3ListBuckets(c *gin.Context) {
+	buckets := projectSimulation(c, h.simulator).BucketStore().List(h.s3Provider(c))
+	sort.Slice(buckets, func(i, j int) bool { return getString(buckets[i], "bucket") < getString(buckets[j], "bucket") })
+	result := s3ListAllMyBucketsResult{
+		Xmlns: s3XMLNamespace,
+		Owner: s3Owner{ID: s3SimOwnerID, DisplayName: s3SimOwnerID},
+	}
+	for _, b := range buckets {
+		result.Buckets = append(result.Buckets, s3BucketEntry{
+			Name:         getString(b, "bucket"),
+			CreationDate: s3BucketCreationDate(b),
+		})
+	}
+	h.writeS3XML(c, http.StatusOK, result)
+}
+
+func (h *ProviderSimulationHandlers) s3CreateBucket(c *gin.Context, bucket string) {
+	if !validS3BucketName(bucket) {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidBucketName, "The specified bucket is not valid.", bucket, "")
+		return
+	}
+	provider := h.s3Provider(c)
+	defer h.locks.lock(resourceKey(c, "quota"))()
+	if _, exists := projectSimulation(c, h.simulator).BucketStore().Get(provider, bucket); exists {
+		h.writeS3Error(c, http.StatusConflict, S3ErrBucketAlreadyOwnedByYou, "Your previous request to create the named bucket succeeded and you already own it.", bucket, "")
+		return
+	}
+	if limit := currentProject(c).Quota.Buckets; limit > 0 && bucketCount(projectSimulation(c, h.simulator)) >= limit {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrTooManyBuckets, "You have attempted to create more buckets than allowed.", bucket, "")
+		return
+	}
+	region := s3DefaultRegion
+	body, err := h.s3ReadBody(c)
+	if err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, err.Error(), bucket, "")
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		var cfg s3CreateBucketConfiguration
+		if err := xml.Unmarshal(body, &cfg); err != nil {
+			h.writeS3Error(c, http.StatusBadRequest, S3ErrMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema.", bucket, "")
+			return
+		}
+		if cfg.LocationConstraint != "" {
+			region = cfg.LocationConstraint
+		}
+	}
+	projectSimulation(c, h.simulator).BucketStore().Create(provider, bucket, region)
+	c.Header("Location", "/"+bucket)
+	c.Status(http.StatusOK)
+}
+
+func (h *ProviderSimulationHandlers) s3HeadBucket(c *gin.Context, bucket string) {
+	info, exists := projectSimulation(c, h.simulator).BucketStore().Get(h.s3Provider(c), bucket)
+	if !exists {
+		h.writeS3Error(c, http.StatusNotFound, S3ErrNoSuchBucket, "The specified bucket does not exist", bucket, "")
+		return
+	}
+	c.Header("X-Amz-Bucket-Region", s3BucketRegion(info))
+	c.Status(http.StatusOK)
+}
+
+func (h *ProviderSimulationHandlers) s3GetBucketLocation(c *gin.Context, bucket string) {
+	info, exists := projectSimulation(c, h.simulator).BucketStore().Get(h.s3Provider(c), bucket)
+	if !exists {
+		h.writeS3Error(c, http.StatusNotFound, S3ErrNoSuchBucket, "The specified bucket does not exist", bucket, "")
+		return
+	}
+	region := s3BucketRegion(info)
+	if region == s3DefaultRegion {
+		// S3 reports the classic region as an empty constraint
+		region = ""
+	}
+	h.writeS3XML(c, http.StatusOK, s3LocationConstraint{Xmlns: s3XMLNamespace, Value: region})
+}
+
+func (h *ProviderSimulationHandlers) s3DeleteBucket(c *gin.Context, bucket string) {
+	err := projectSimulation(c, h.simulator).BucketStore().DeleteIfEmpty(h.s3Provider(c), bucket)
+	switch {
+	case errors.Is(err, simulation.ErrNoSuchBucket):
+		h.writeS3Error(c, http.StatusNotFound, S3ErrNoSuchBucket, "The specified bucket does not exist", bucket, "")
+	case errors.Is(err, simulation.ErrBucketNotEmpty):
+		h.writeS3Error(c, http.StatusConflict, S3ErrBucketNotEmpty, "The bucket you tried to delete is not empty", bucket, "")
+	case err != nil:
+		h.writeS3Error(c, http.StatusInternalServerError, S3ErrInternalError, err.Error(), bucket, "")
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *ProviderSimulationHandlers) s3ListObjects(c *gin.Context, bucket string) {
+	query := c.Request.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	encodingType := query.Get("encoding-type")
+	v2 := query.Get("list-type") == "2"
+
+	maxKeys := s3DefaultMaxKeys
+	if mk := query.Get("max-keys"); mk != "" {
+		n, err := strconv.Atoi(mk)
+		if err != nil || n < 0 {
+			h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "Provided max-keys not an integer or within integer range", bucket, "")
+			return
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	// Everything strictly greater than startAfter is returned
+	startAfter := ""
+	if v2 {
+		startAfter = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.StdEncoding.DecodeString(token)
+			if err != nil {
+				h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "The continuation token provided is incorrect", bucket, "")
+				return
+			}
+			startAfter = string(decoded)
+		}
+	} else {
+		startAfter = query.Get("marker")
+	}
+
+	objects, err := projectSimulation(c, h.simulator).BucketStore().ListObjects(h.s3Provider(c), bucket, prefix)
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+
+	result := s3ListBucketResult{
+		Xmlns:        s3XMLNamespace,
+		Name:         bucket,
+		Prefix:       s3EncodeKey(prefix, encodingType),
+		Delimiter:    s3EncodeKey(delimiter, encodingType),
+		MaxKeys:      maxKeys,
+		EncodingType: encodingType,
+	}
+	seenPrefixes := map[string]bool{}
+	lastEntry := ""
+	count := 0
+	for _, obj := range objects {
+		entry := obj.Key
+		isPrefix := false
+		if delimiter != "" {
+			if idx := strings.Index(obj.Key[len(prefix):], delimiter); idx >= 0 {
+				entry = obj.Key[:len(prefix)+idx+len(delimiter)]
+				isPrefix = true
+			}
+		}
+		if entry <= startAfter || (isPrefix && seenPrefixes[entry]) {
+			continue
+		}
+		if count >= maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		if isPrefix {
+			seenPrefixes[entry] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: s3EncodeKey(entry, encodingType)})
+		} else {
+			e := s3ObjectEntry{
+				Key:          s3EncodeKey(obj.Key, encodingType),
+				LastModified: obj.LastModified.UTC().Format(s3TimeFormat),
+				ETag:         quoteETag(obj.ETag),
+				Size:         obj.Size(),
+				StorageClass: obj.StorageClass,
+			}
+			if !v2 || query.Get("fetch-owner") == "true" {
+				e.Owner = &s3Owner{ID: s3SimOwnerID, DisplayName: s3SimOwnerID}
+			}
+			result.Contents = append(result.Contents, e)
+		}
+		lastEntry = entry
+		count++
+	}
+
+	if v2 {
+		result.KeyCount = &count
+		result.StartAfter = s3EncodeKey(query.Get("start-after"), encodingType)
+		result.ContinuationToken = query.Get("continuation-token")
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(lastEntry))
+		}
+	} else {
+		marker := s3EncodeKey(query.Get("marker"), encodingType)
+		result.Marker = &marker
+		if result.IsTruncated && delimiter != "" {
+			result.NextMarker = s3EncodeKey(lastEntry, encodingType)
+		}
+	}
+	h.writeS3XML(c, http.StatusOK, result)
+}
+
+func (h *ProviderSimulationHandlers) s3PutObject(c *gin.Context, bucket, key string) {
+	body, err := h.s3ReadBody(c)
+	if err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, err.Error(), bucket, key)
+		return
+	}
+	contentType := c.GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = s3DefaultContentType
+	}
+	tags, err := s3ObjectTags(c.GetHeader(s3TaggingHeader))
+	if err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.", bucket, key)
+		return
+	}
+	obj, err := projectSimulation(c, h.simulator).BucketStore().PutObjectWithAttributes(h.s3Provider(c), bucket, key, body, simulation.ObjectAttributes{
+		ContentType:  contentType,
+		Metadata:     s3UserMetadata(c.Request.Header),
+		Tags:         tags,
+		StorageClass: c.GetHeader(s3StorageClassHeader),
+	})
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+	c.Header("ETag", quoteETag(obj.ETag))
+	setS3VersionHeaders(c, obj)
+	c.Status(http.StatusOK)
+}
+
+func (h *ProviderSimulationHandlers) s3GetObject(c *gin.Context, bucket, key string, headOnly bool) {
+	var (
+		obj *simulation.Object
+		err error
+	)
+	if versionID := c.Query("versionId"); versionID != "" {
+		obj, err = projectSimulation(c, h.simulator).BucketStore().GetObjectVersion(h.s3Provider(c), bucket, key, versionID)
+	} else {
+		obj, err = projectSimulation(c, h.simulator).BucketStore().GetObject(h.s3Provider(c), bucket, key)
+	}
+	if errors.Is(err, simulation.ErrVersionIsDeleteMarker) {
+		setS3VersionHeaders(c, obj)
+		h.writeS3Error(c, http.StatusMethodNotAllowed, S3ErrMethodNotAllowed, "The specified method is not allowed against this resource.", bucket, key)
+		return
+	}
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+
+	c.Header("ETag", quoteETag(obj.ETag))
+	setS3VersionHeaders(c, obj)
+	c.Header("Last-Modified", obj.LastModified.UTC().Format(http.TimeFormat))
+	c.Header("Accept-Ranges", "bytes")
+	if obj.StorageClass != "" && obj.StorageClass != simulation.DefaultStorageClass {
+		c.Header(s3StorageClassHeader, obj.StorageClass)
+	}
+	if len(obj.Tags) > 0 {
+		c.Header("X-Amz-Tagging-Count", strconv.Itoa(len(obj.Tags)))
+	}
+	for k, v := range obj.Metadata {
+		c.Header(s3MetaHeaderPrefix+k, v)
+	}
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = s3DefaultContentType
+	}
+
+	data := obj.Data
+	status := http.StatusOK
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" {
+		start, end, ok := parseS3Range(rangeHeader, obj.Size())
+		if !ok {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", obj.Size()))
+			h.writeS3Error(c, http.StatusRequestedRangeNotSatisfiable, S3ErrInvalidRange, "The requested range is not satisfiable", bucket, key)
+			return
+		}
+		data = obj.Data[start : end+1]
+		status = http.StatusPartialContent
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, obj.Size()))
+	}
+
+	c.Header("Content-Length", strconv.Itoa(len(data)))
+	if headOnly {
+		c.Header("Content-Type", contentType)
+		c.Status(status)
+		return
+	}
+	c.Data(status, contentType, data)
+}
+
+func (h *ProviderSimulationHandlers) s3DeleteObject(c *gin.Context, bucket, key string) {
+	marker, err := projectSimulation(c, h.simulator).BucketStore().DeleteObject(h.s3Provider(c), bucket, key)
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+	setS3VersionHeaders(c, marker)
+	c.Status(http.StatusNoContent)
+}
+
+// s3ReadBody reads the request payload, decoding aws-chunked streaming uploads.
+func (h *ProviderSimulationHandlers) s3ReadBody(c *gin.Context) ([]byte, error) {
+	if c.Request.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	if isS3StreamingPayload(c.Request.Header) {
+		return decodeAWSChunked(body)
+	}
+	return body, nil
+}
+
+func isS3StreamingPayload(header http.Header) bool {
+	if strings.HasPrefix(header.Get(s3ContentSHA256), s3StreamingPrefix) {
+		return true
+	}
+	for _, enc := range strings.Split(header.Get("Content-Encoding"), ",") {
+		if strings.TrimSpace(enc) == "aws-chunked" {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeAWSChunked strips the aws-chunked framing
+// ("<hex-size>[;chunk-signature=...]\r\n<data>\r\n" ... "0\r\n[trailers]\r\n").
+func decodeAWSChunked(body []byte) ([]byte, error) {
+	reader := bufio.NewReader(bytes.NewReader(body))
+	var out bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("malformed aws-chunked payload: %w", err)
+		}
+		sizeField, _, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ";")
+		size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed aws-chunked chunk size %q", sizeField)
+		}
+		if size == 0 {
+			// Trailing headers (e.g. x-amz-checksum-crc32) are ignored
+			return out.Bytes(), nil
+		}
+		if _, err := io.CopyN(&out, reader, size); err != nil {
+			return nil, fmt.Errorf("malformed aws-chunked payload: %w", err)
+		}
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, fmt.Errorf("malformed aws-chunked payload: %w", err)
+		}
+	}
+}
+
+// parseS3Range parses a single HTTP byte range against an object of the given size.
+// It returns inclusive start/end offsets.
+func parseS3Range(header string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	startStr, endStr, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	if startStr == "" {
+		// Suffix range: last N bytes
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+// s3UserMetadata collects x-amz-meta-* headers (keys lower-cased, prefix stripped).
+func s3UserMetadata(header http.Header) map[string]string {
+	var meta map[string]string
+	for k, v := range header {
+		if name, ok := strings.CutPrefix(http.CanonicalHeaderKey(k), s3MetaHeaderPrefix); ok && len(v) > 0 {
+			if meta == nil {
+				meta = make(map[string]string)
+			}
+			meta[strings.ToLower(name)] = v[0]
+		}
+	}
+	return meta
+}
+
+// validS3BucketName applies the basic S3 bucket naming rules.
+func validS3BucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case (r == '-' || r == '.') && i > 0 && i < len(name)-1:
+		default:
+			return false
+		}
+	}
+	return !strings.Contains(name, "..")
+}
+
+const s3TimeFormat = "2006-01-02T15:04:05.000Z"
+
+func s3BucketCreationDate(bucket map[string]interface{}) string {
+	if created, err := time.Parse(time.RFC3339, getString(bucket, "created_at")); err == nil {
+		return created.UTC().Format(s3TimeFormat)
+	}
+	return time.Unix(0, 0).UTC().Format(s3TimeFormat)
+}
+
+func s3BucketRegion(bucket map[string]interface{}) string {
+	if region := getString(bucket, "region"); region != "" {
+		return region
+	}
+	return s3DefaultRegion
+}
+
+func s3EncodeKey(key, encodingType string) string {
+	if encodingType == "url" {
+		return strings.ReplaceAll(url.QueryEscape(key), "+", "%20")
+	}
+	return key
+}
+
+func quoteETag(etag string) string {
+	return `"` + etag + `"`
+}
+
+func (h *ProviderSimulationHandlers) writeS3XML(c *gin.Context, status int, v interface{}) {
+	out, err := xml.Marshal(v)
+	if err != nil {
+		h.logger.Error("Failed to marshal S3 XML response", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Header("X-Amz-Request-Id", generateID())
+	c.Data(status, "application/xml", append([]byte(xml.Header), out...))
+}
+
+// writeS3Error writes an S3 XML error document. HEAD responses carry no body.
+func (h *ProviderSimulationHandlers) writeS3Error(c *gin.Context, status int, code, message, bucket, key string) {
+	requestID := generateID()
+	c.Header("X-Amz-Request-Id", requestID)
+	if c.Request.Method == http.MethodHead {
+		c.Status(status)
+		c.Abort()
+		return
+	}
+	resource := "/"
+	if bucket != "" {
+		resource += bucket
+		if key != "" {
+			resource += "/" + key
+		}
+	}
+	out, _ := xml.Marshal(s3Error{
+		Code:       code,
+		Message:    message,
+		BucketName: bucket,
+		Key:        key,
+		Resource:   resource,
+		RequestID:  requestID,
+	})
+	c.Data(status, "application/xml", append([]byte(xml.Header), out...))
+	c.Abort()
+}
+
+// writeS3AuthError writes a SigV4 verification failure as an S3 XML error document.
+func (h *ProviderSimulationHandlers) writeS3AuthError(c *gin.Context, aerr *s3AuthError) {
+	requestID := generateID()
+	c.Header("X-Amz-Request-Id", requestID)
+	if c.Request.Method == http.MethodHead {
+		c.Status(aerr.Status)
+		c.Abort()
+		return
+	}
+	out, _ := xml.Marshal(s3Error{
+		Code:                        aerr.Code,
+		Message:                     aerr.Message,
+		RequestID:                   requestID,
+		AWSAccessKeyID:              aerr.AccessKeyID,
+		StringToSign:                aerr.StringToSign,
+		CanonicalRequest:            aerr.CanonicalReq,
+		SignatureProvided:           aerr.SignatureGiven,
+		RequestTime:                 aerr.RequestTime,
+		ServerTime:                  aerr.ServerTime,
+		MaxAllowedSkewMilliseconds:  aerr.MaxAllowedSkew,
+		ClientComputedContentSHA256: aerr.ContentSHA256,
+		S3ComputedContentSHA256:     aerr.CalculatedSHA256,
+	})
+	c.Data(aerr.Status, "application/xml", append([]byte(xml.Header), out...))
+	c.Abort()
+}
+
+// writeS3StoreError maps BucketStore errors to S3 error responses.
+func (h *ProviderSimulationHandlers) writeS3StoreError(c *gin.Context, err error, bucket, key string) {
+	switch {
+	case errors.Is(err, simulation.ErrNoSuchBucket):
+		h.writeS3Error(c, http.StatusNotFound, S3ErrNoSuchBucket, "The specified bucket does not exist", bucket, "")
+	case errors.Is(err, simulation.ErrNoSuchKey):
+		h.writeS3Error(c, http.StatusNotFound, S3ErrNoSuchKey, "The specified key does not exist.", bucket, key)
+	case errors.Is(err, simulation.ErrNoSuchVersion):
+		h.writeS3Error(c, http.StatusNotFound, S3ErrNoSuchVersion, "The specified version does not exist.", bucket, key)
+	case errors.Is(err, simulation.ErrInvalidVersioningStatus), errors.Is(err, simulation.ErrVersioningCannotBeDisabled):
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrIllegalVersioningConfiguration, err.Error(), bucket, "")
+	case errors.Is(err, simulation.ErrNoSuchBucketPolicy):
+		h.writeS3Error(c, http.StatusNotFound, S3ErrNoSuchBucketPolicy, "The bucket policy does not exist", bucket, "")
+	case errors.Is(err, simulation.ErrMalformedPolicy):
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrMalformedPolicy, err.Error(), bucket, "")
+	case errors.Is(err, simulation.ErrInvalidLifecycleRule):
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, err.Error(), bucket, "")
+	case errors.Is(err, simulation.ErrNoSuchUpload):
+		h.writeS3Error(c, http.StatusNotFound, S3ErrNoSuchUpload, "The specified upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.", bucket, key)
+	case errors.Is(err, simulation.ErrInvalidPartNumber):
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "Part number must be an integer between 1 and 10000, inclusive", bucket, key)
+	case errors.Is(err, simulation.ErrInvalidPartOrder):
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidPartOrder, "The list of parts was not in ascending order. The parts list must be specified in order by part number.", bucket, key)
+	case errors.Is(err, simulation.ErrEntityTooSmall):
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrEntityTooSmall, "Your proposed upload is smaller than the minimum allowed object size.", bucket, key)
+	case errors.Is(err, simulation.ErrInvalidPart):
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidPart, "One or more of the specified parts could not be found. The part may not have been uploaded, or the specified entity tag may not match the part's entity tag.", bucket, key)
+	default:
+		h.logger.Error("S3 simulation failure", zap.Error(err))
+		h.writeS3Error(c, http.StatusInternalServerError, S3ErrInternalError, "We encountered an internal error. Please try again.", bucket, key)
+	}
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	"go.uber.org/zap"
+)
+
+// newS3TestServer starts cube-server routes on an httptest server with an isolated bucket store.
+func newS3TestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+	r := newTestRouter(t, RouteOptions{Logger: zap.NewNop(), Sim: NewTestSimulationService()})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newS3TestClient returns an SDK client for the simulator. With pathStyle=false the
+// client uses <bucket>.localhost hosts, which are all dialed to the test server.
+func newS3TestClient(srv *httptest.Server, pathStyle bool) *s3.Client {
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	dialer := &net.Dialer{}
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, strings.TrimPrefix(srv.URL, "http://"))
+		},
+	}}
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String("http://localhost:" + port + "/api/v1/simulate/aws-s3"),
+		UsePathStyle: pathStyle,
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIDSIMULATED", "simulated-secret", ""),
+		HTTPClient:   httpClient,
+	})
+}
+
+func s3ErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+func TestS3ProtocolBucketAndObjectLifecycle(t *testing.T) {
+	srv := newS3TestServer(t)
+	ctx := context.Background()
+
+	for _, pathStyle := range []bool{true, false} {
+		client := newS3TestClient(srv, pathStyle)
+		bucket := "sdk-bucket-virtual"
+		if pathStyle {
+			bucket = "sdk-bucket-path"
+		}
+
+		if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{
+			Bucket:                    aws.String(bucket),
+			CreateBucketConfiguration: &types.CreateBucketConfiguration{LocationConstraint: "eu-central-1"},
+		}); err != nil {
+			t.Fatalf("CreateBucket(%s): %v", bucket, err)
+		}
+		_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+		if code := s3ErrorCode(err); code != S3ErrBucketAlreadyOwnedByYou {
+			t.Fatalf("second CreateBucket: expected %s, got %v", S3ErrBucketAlreadyOwnedByYou, err)
+		}
+		if _, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}); err != nil {
+			t.Fatalf("HeadBucket: %v", err)
+		}
+
+		body := []byte("hello from the s3 simulator")
+		put, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String("dir/hello.txt"),
+			Body:        bytes.NewReader(body),
+			ContentType: aws.String("text/plain"),
+			Metadata:    map[string]string{"owner": "tests"},
+		})
+		if err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+		if want := `"` + simulation.ComputeETag(body) + `"`; aws.ToString(put.ETag) != want {
+			t.Errorf("PutObject ETag = %s, want %s", aws.ToString(put.ETag), want)
+		}
+
+		get, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("dir/hello.txt")})
+		if err != nil {
+			t.Fatalf("GetObject: %v", err)
+		}
+		got, _ := io.ReadAll(get.Body)
+		get.Body.Close()
+		if !bytes.Equal(got, body) {
+			t.Errorf("GetObject body = %q, want %q", got, body)
+		}
+		if get.Metadata["owner"] != "tests" || aws.ToString(get.ContentType) != "text/plain" {
+			t.Errorf("GetObject metadata/content-type not preserved: %v %s", get.Metadata, aws.ToString(get.ContentType))
+		}
+
+		ranged, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("dir/hello.txt"), Range: aws.String("bytes=0-4")})
+		if err != nil {
+			t.Fatalf("ranged GetObject: %v", err)
+		}
+		got, _ = io.ReadAll(ranged.Body)
+		ranged.Body.Close()
+		if string(got) != "hello" || aws.ToString(ranged.ContentRange) != "bytes 0-4/27" {
+			t.Errorf("ranged GetObject = %q (%s)", got, aws.ToString(ranged.ContentRange))
+		}
+
+		head, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String("dir/hello.txt")})
+		if err != nil {
+			t.Fatalf("HeadObject: %v", err)
+		}
+		if aws.ToInt64(head.ContentLength) != int64(len(body)) {
+			t.Errorf("HeadObject ContentLength = %d", aws.ToInt64(head.ContentLength))
+		}
+
+		_, err = client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("missing")})
+		if code := s3ErrorCode(err); code != S3ErrNoSuchKey {
+			t.Errorf("GetObject missing key: expected %s, got %v", S3ErrNoSuchKey, err)
+		}
+
+		_, err = client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+		if code := s3ErrorCode(err); code != S3ErrBucketNotEmpty {
+			t.Errorf("DeleteBucket non-empty: expected %s, got %v", S3ErrBucketNotEmpty, err)
+		}
+		if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String("dir/hello.txt")}); err != nil {
+			t.Fatalf("DeleteObject: %v", err)
+		}
+		if _, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucket)}); err != nil {
+			t.Fatalf("DeleteBucket: %v", err)
+		}
+		_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+		var notFound *types.NotFound
+		if !errors.As(err, &notFound) {
+			t.Errorf("HeadBucket after delete: expected NotFound, got %v", err)
+		}
+	}
+}
+
+func TestS3ProtocolListObjectsV2(t *testing.T) {
+	srv := newS3TestServer(t)
+	client := newS3TestClient(srv, true)
+	ctx := context.Background()
+
+	_, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("nope")})
+	if code := s3ErrorCode(err); code != S3ErrNoSuchBucket {
+		t.Fatalf("ListObjectsV2 missing bucket: expected %s, got %v", S3ErrNoSuchBucket, err)
+	}
+
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("list-bucket")}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	for _, key := range []string{"a.txt", "logs/1.log", "logs/2.log", "logs/3.log", "z.txt"} {
+		if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("list-bucket"), Key: aws.String(key), Body: strings.NewReader(key)}); err != nil {
+			t.Fatalf("PutObject(%s): %v", key, err)
+		}
+	}
+
+	delimited, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("list-bucket"), Delimiter: aws.String("/")})
+	if err != nil {
+		t.Fatalf("ListObjectsV2: %v", err)
+	}
+	if len(delimited.Contents) != 2 || len(delimited.CommonPrefixes) != 1 || aws.ToString(delimited.CommonPrefixes[0].Prefix) != "logs/" {
+		t.Errorf("delimited listing: %d contents, prefixes %v", len(delimited.Contents), delimited.CommonPrefixes)
+	}
+
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: aws.String("list-bucket"), MaxKeys: aws.Int32(2)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			t.Fatalf("ListObjectsV2 page: %v", err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	if strings.Join(keys, ",") != "a.txt,logs/1.log,logs/2.log,logs/3.log,z.txt" {
+		t.Errorf("paginated keys = %v", keys)
+	}
+}
+
+func TestS3ProtocolSharesBucketStoreWithJSONAPI(t *testing.T) {
+	srv := newS3TestServer(t)
+	body, _ := json.Marshal(map[string]interface{}{"name": "json-created", "region": "eu-west-1"})
+	resp, err := http.Post(srv.URL+"/api/v1/simulate/providers/aws/buckets", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("create bucket via JSON API: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create bucket via JSON API: status %d", resp.StatusCode)
+	}
+
+	out, err := newS3TestClient(srv, true).ListBuckets(context.Background(), &s3.ListBucketsInput{})
+	if err != nil {
+		t.Fatalf("ListBuckets: %v", err)
+	}
+	if len(out.Buckets) != 1 || aws.ToString(out.Buckets[0].Name) != "json-created" {
+		t.Errorf("ListBuckets = %+v, want json-created", out.Buckets)
+	}
+}
+
+func TestDecodeAWSChunked(t *testing.T) {
+	payload := "5;chunk-signature=abc\r\nhello\r\n6\r\n world\r\n0\r\nx-amz-checksum-crc32:AAAAAA==\r\n\r\n"
+	got, err := decodeAWSChunked([]byte(payload))
+	if err != nil || string(got) != "hello world" {
+		t.Fatalf("decodeAWSChunked = %q, %v", got, err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	// Keep the simulated bucket persistence file out of the shared /tmp default
+	dir, _ := os.MkdirTemp("", "cube-server-api-test")
+	os.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(dir, "buckets.json"))
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,377 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4 verification for the S3 simulation.
+//
+// Both header authentication (Authorization: AWS4-HMAC-SHA256 ...) and presigned
+// query authentication (X-Amz-Signature=...) are checked. For streaming uploads
+// (STREAMING-* payload hashes) only the seed signature is verified; individual
+// chunk signatures are not.
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4DateFormat      = "20060102"
+	sigV4Terminator      = "aws4_request"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+	sigV4MaxSkew         = 15 * time.Minute
+	sigV4MaxPresignAge   = 7 * 24 * time.Hour
+)
+
+// S3 authentication error codes
+const (
+	S3ErrAccessDenied                 = "AccessDenied"
+	S3ErrInvalidAccessKeyID           = "InvalidAccessKeyId"
+	S3ErrSignatureDoesNotMatch        = "SignatureDoesNotMatch"
+	S3ErrRequestTimeTooSkewed         = "RequestTimeTooSkewed"
+	S3ErrAuthorizationHeaderMalformed = "AuthorizationHeaderMalformed"
+	S3ErrAuthorizationQueryError      = "AuthorizationQueryParametersError"
+	S3ErrContentSHA256Mismatch        = "XAmzContentSHA256Mismatch"
+)
+
+// s3AuthError describes why a request failed SigV4 verification.
+type s3AuthError struct {
+	Status           int
+	Code             string
+	Message          string
+	AccessKeyID      string
+	StringToSign     string
+	CanonicalReq     string
+	SignatureGiven   string
+	RequestTime      string
+	ServerTime       string
+	MaxAllowedSkew   string
+	ContentSHA256    string
+	CalculatedSHA256 string
+}
+
+// s3SigV4Verifier checks SigV4 signatures against a fixed set of access key/secret pairs.
+type s3SigV4Verifier struct {
+	secrets map[string]string // access key -> secret key
+	now     func() time.Time
+}
+
+// newS3SigV4Verifier creates a verifier for the given access key -> secret key map.
+func newS3SigV4Verifier(secrets map[string]string) *s3SigV4Verifier {
+	return &s3SigV4Verifier{secrets: secrets, now: time.Now}
+}
+
+// sigV4Request holds the parsed authentication parameters of a request.
+type sigV4Request struct {
+	accessKey     string
+	date          string // yyyymmdd from the credential scope
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	amzDate       time.Time
+	amzDateRaw    string
+	payloadHash   string
+	presigned     bool
+	expires       time.Duration
+}
+
+// Verify authenticates r, whose (raw) body has already been read into body, and
+// returns the access key ID it was signed with.
+func (v *s3SigV4Verifier) Verify(r *http.Request, body []byte) (string, *s3AuthError) {
+	var (
+		req  *sigV4Request
+		aerr *s3AuthError
+	)
+	switch {
+	case r.URL.Query().Get("X-Amz-Signature") != "":
+		req, aerr = parseSigV4Query(r)
+	case r.Header.Get("Authorization") != "":
+		req, aerr = parseSigV4Header(r)
+	default:
+		return "", &s3AuthError{Status: http.StatusForbidden, Code: S3ErrAccessDenied, Message: "Access Denied"}
+	}
+	if aerr != nil {
+		return "", aerr
+	}
+
+	secret, ok := v.secrets[req.accessKey]
+	if !ok {
+		return "", &s3AuthError{
+			Status:      http.StatusForbidden,
+			Code:        S3ErrInvalidAccessKeyID,
+			Message:     "The AWS Access Key Id you provided does not exist in our records.",
+			AccessKeyID: req.accessKey,
+		}
+	}
+
+	now := v.now().UTC()
+	if req.presigned {
+		if now.After(req.amzDate.Add(req.expires)) {
+			return "", &s3AuthError{Status: http.StatusForbidden, Code: S3ErrAccessDenied, Message: "Request has expired"}
+		}
+		if req.amzDate.Sub(now) > sigV4MaxSkew {
+			return "", skewError(req, now)
+		}
+	} else if d := now.Sub(req.amzDate); d > sigV4MaxSkew || d < -sigV4MaxSkew {
+		return "", skewError(req, now)
+	}
+
+	if !req.presigned && !strings.HasPrefix(req.payloadHash, s3StreamingPrefix) && req.payloadHash != sigV4UnsignedPayload {
+		sum := sha256.Sum256(body)
+		calculated := hex.EncodeToString(sum[:])
+		if !strings.EqualFold(calculated, req.payloadHash) {
+			return "", &s3AuthError{
+				Status:           http.StatusBadRequest,
+				Code:             S3ErrContentSHA256Mismatch,
+				Message:          "The provided 'x-amz-content-sha256' header does not match what was computed.",
+				ContentSHA256:    req.payloadHash,
+				CalculatedSHA256: calculated,
+			}
+		}
+	}
+
+	canonical := sigV4CanonicalRequest(r, req)
+	scope := strings.Join([]string{req.date, req.region, req.service, sigV4Terminator}, "/")
+	hashed := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{sigV4Algorithm, req.amzDateRaw, scope, hex.EncodeToString(hashed[:])}, "\n")
+	key := sigV4SigningKey(secret, req.date, req.region, req.service)
+	expected := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(req.signature))) {
+		return "", &s3AuthError{
+			Status:         http.StatusForbidden,
+			Code:           S3ErrSignatureDoesNotMatch,
+			Message:        "The request signature we calculated does not match the signature you provided. Check your key and signing method.",
+			AccessKeyID:    req.accessKey,
+			StringToSign:   stringToSign,
+			CanonicalReq:   canonical,
+			SignatureGiven: req.signature,
+		}
+	}
+	return req.accessKey, nil
+}
+
+func skewError(req *sigV4Request, now time.Time) *s3AuthError {
+	return &s3AuthError{
+		Status:         http.StatusForbidden,
+		Code:           S3ErrRequestTimeTooSkewed,
+		Message:        "The difference between the request time and the current time is too large.",
+		RequestTime:    req.amzDateRaw,
+		ServerTime:     now.Format(time.RFC3339),
+		MaxAllowedSkew: strconv.Itoa(int(sigV4MaxSkew / time.Millisecond)),
+	}
+}
+
+// parseSigV4Header parses "AWS4-HMAC-SHA256 Credential=..., SignedHeaders=..., Signature=...".
+func parseSigV4Header(r *http.Request) (*sigV4Request, *s3AuthError) {
+	malformed := func(msg string) *s3AuthError {
+		return &s3AuthError{Status: http.StatusBadRequest, Code: S3ErrAuthorizationHeaderMalformed, Message: msg}
+	}
+	auth := r.Header.Get("Authorization")
+	rest, ok := strings.CutPrefix(auth, sigV4Algorithm+" ")
+	if !ok {
+		return nil, malformed("Only the AWS4-HMAC-SHA256 signing algorithm is supported.")
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(rest, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			fields[k] = val
+		}
+	}
+	req := &sigV4Request{
+		signature:   fields["Signature"],
+		payloadHash: r.Header.Get(s3ContentSHA256),
+	}
+	if fields["Credential"] == "" || fields["SignedHeaders"] == "" || req.signature == "" {
+		return nil, malformed("The authorization header is malformed; Credential, SignedHeaders and Signature are required.")
+	}
+	if err := req.parseCredential(fields["Credential"]); err != "" {
+		return nil, malformed(err)
+	}
+	req.signedHeaders = strings.Split(fields["SignedHeaders"], ";")
+	if req.payloadHash == "" {
+		return nil, &s3AuthError{Status: http.StatusBadRequest, Code: S3ErrInvalidArgument, Message: "x-amz-content-sha256 must be provided for SigV4 requests."}
+	}
+
+	req.amzDateRaw = r.Header.Get("X-Amz-Date")
+	t, err := time.Parse(sigV4TimeFormat, req.amzDateRaw)
+	if err != nil {
+		if t, err = http.ParseTime(r.Header.Get("Date")); err != nil {
+			return nil, &s3AuthError{Status: http.StatusForbidden, Code: S3ErrAccessDenied, Message: "AWS authentication requires a valid Date or x-amz-date header"}
+		}
+		req.amzDateRaw = t.UTC().Format(sigV4TimeFormat)
+	}
+	req.amzDate = t
+	if err := req.checkScopeDate(); err != "" {
+		return nil, malformed(err)
+	}
+	return req, nil
+}
+
+// parseSigV4Query parses presigned URL query authentication parameters.
+func parseSigV4Query(r *http.Request) (*sigV4Request, *s3AuthError) {
+	q := r.URL.Query()
+	queryErr := func(msg string) *s3AuthError {
+		return &s3AuthError{Status: http.StatusBadRequest, Code: S3ErrAuthorizationQueryError, Message: msg}
+	}
+	if q.Get("X-Amz-Algorithm") != sigV4Algorithm {
+		return nil, queryErr("X-Amz-Algorithm only supports \"AWS4-HMAC-SHA256\"")
+	}
+	req := &sigV4Request{
+		presigned:   true,
+		signature:   q.Get("X-Amz-Signature"),
+		amzDateRaw:  q.Get("X-Amz-Date"),
+		payloadHash: sigV4UnsignedPayload,
+	}
+	if err := req.parseCredential(q.Get("X-Amz-Credential")); err != "" {
+		return nil, queryErr(err)
+	}
+	if q.Get("X-Amz-SignedHeaders") == "" {
+		return nil, queryErr("X-Amz-SignedHeaders is required")
+	}
+	req.signedHeaders = strings.Split(q.Get("X-Amz-SignedHeaders"), ";")
+	t, err := time.Parse(sigV4TimeFormat, req.amzDateRaw)
+	if err != nil {
+		return nil, queryErr("X-Amz-Date must be in the ISO8601 Long Format \"yyyyMMdd'T'HHmmss'Z'\"")
+	}
+	req.amzDate = t
+	if err := req.checkScopeDate(); err != "" {
+		return nil, queryErr(err)
+	}
+	secs, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+	if err != nil || secs < 0 {
+		return nil, queryErr("X-Amz-Expires should be a number")
+	}
+	req.expires = time.Duration(secs) * time.Second
+	if req.expires > sigV4MaxPresignAge {
+		return nil, queryErr("X-Amz-Expires must be less than a week (in seconds) that is 604800")
+	}
+	if h := q.Get("X-Amz-Content-Sha256"); h != "" {
+		req.payloadHash = h
+	}
+	return req, nil
+}
+
+// parseCredential parses "<access-key>/<date>/<region>/<service>/aws4_request".
+func (req *sigV4Request) parseCredential(credential string) string {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != sigV4Terminator {
+		return fmt.Sprintf("Error parsing the X-Amz-Credential parameter; the Credential is mal-formed; expecting \"<YOUR-AKID>/YYYYMMDD/REGION/SERVICE/aws4_request\". (%s)", credential)
+	}
+	if _, err := time.Parse(sigV4DateFormat, parts[1]); err != nil {
+		return fmt.Sprintf("Invalid credential date %q. This date is not the same as X-Amz-Date", parts[1])
+	}
+	req.accessKey, req.date, req.region, req.service = parts[0], parts[1], parts[2], parts[3]
+	return ""
+}
+
+// checkScopeDate refuses a credential scope dated other than the request time, as S3 does
+func (req *sigV4Request) checkScopeDate() string {
+	if day := req.amzDate.UTC().Format(sigV4DateFormat); req.date != day {
+		return fmt.Sprintf("Invalid credential date %q. This date is not the same as X-Amz-Date: %q.", req.date, day)
+	}
+	return ""
+}
+
+// sigV4CanonicalRequest builds the SigV4 canonical request for S3 (no double path encoding).
+func sigV4CanonicalRequest(r *http.Request, req *sigV4Request) string {
+	var headers strings.Builder
+	for _, name := range req.signedHeaders {
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(sigV4HeaderValue(r, name))
+		headers.WriteByte('\n')
+	}
+	return strings.Join([]string{
+		r.Method,
+		sigV4URIEncode(requestPath(r), false),
+		sigV4CanonicalQuery(r.URL.Query()),
+		headers.String(),
+		strings.Join(req.signedHeaders, ";"),
+		req.payloadHash,
+	}, "\n")
+}
+
+func sigV4HeaderValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		return r.Host
+	case "content-length":
+		if v := r.Header.Get("Content-Length"); v != "" {
+			return v
+		}
+		return strconv.FormatInt(r.ContentLength, 10)
+	}
+	values := r.Header.Values(name)
+	trimmed := make([]string, 0, len(values))
+	for _, v := range values {
+		trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+	}
+	return strings.Join(trimmed, ",")
+}
+
+// sigV4CanonicalQuery sorts the encoded parameters by key, then by value. Sorting the
+// joined "k=v" strings instead would put "a-b=1" before "a=1" ('-' < '='), unlike the SDKs.
+func sigV4CanonicalQuery(query url.Values) string {
+	type pair struct{ key, value string }
+	pairs := make([]pair, 0, len(query))
+	for k, values := range query {
+		if k == "X-Amz-Signature" {
+			continue
+		}
+		for _, v := range values {
+			pairs = append(pairs, pair{sigV4URIEncode(k, true), sigV4URIEncode(v, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].key != pairs[j].key {
+			return pairs[i].key < pairs[j].key
+		}
+		return pairs[i].value < pairs[j].value
+	})
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p.key + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// sigV4URIEncode percent-encodes everything except RFC 3986 unreserved characters
+// (and '/' unless encodeSlash is set).
+func sigV4URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sigV4SigningKey(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	k = hmacSHA256(k, []byte(region))
+	k = hmacSHA256(k, []byte(service))
+	return hmacSHA256(k, []byte(sigV4Terminator))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
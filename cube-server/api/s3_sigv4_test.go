@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+	"go.uber.org/zap"
+)
+
+const (
+	testAccessKey = "AKIDCUBESIM"
+	testSecretKey = "cube-sim-secret"
+	emptySHA256   = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// newAuthS3TestServer starts the S3 simulation with SigV4 verification enabled.
+func newAuthS3TestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	cfg := &internal.ServerConfig{}
+	cfg.Storage.S3.Auth.Enabled = true
+	cfg.Storage.S3.Auth.Credentials = []internal.S3Credential{{AccessKey: testAccessKey, SecretKey: testSecretKey}}
+	r := newTestRouter(t, RouteOptions{Logger: zap.NewNop(), Sim: NewTestSimulationService(), Config: cfg})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newSignedS3Client(srv *httptest.Server, accessKey, secretKey string) *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL + "/api/v1/simulate/aws-s3"),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
+		Retryer:      aws.NopRetryer{},
+	})
+}
+
+func TestS3SigV4AcceptsValidSignatures(t *testing.T) {
+	srv := newAuthS3TestServer(t)
+	client := newSignedS3Client(srv, testAccessKey, testSecretKey)
+	ctx := context.Background()
+
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("signed-bucket")}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("signed-bucket"),
+		Key:    aws.String("path with spaces/obj+1.txt"),
+		Body:   strings.NewReader("signed payload"),
+	}); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("signed-bucket"), Prefix: aws.String("path with")}); err != nil {
+		t.Fatalf("ListObjectsV2: %v", err)
+	}
+
+	presigned, err := s3.NewPresignClient(client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String("signed-bucket"),
+		Key:    aws.String("path with spaces/obj+1.txt"),
+	}, s3.WithPresignExpires(5*time.Minute))
+	if err != nil {
+		t.Fatalf("PresignGetObject: %v", err)
+	}
+	resp, err := http.Get(presigned.URL)
+	if err != nil {
+		t.Fatalf("GET presigned URL: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "signed payload" {
+		t.Fatalf("presigned GET: status %d body %q", resp.StatusCode, body)
+	}
+}
+
+func TestS3SigV4RejectsBadCredentials(t *testing.T) {
+	srv := newAuthS3TestServer(t)
+	ctx := context.Background()
+
+	_, err := newSignedS3Client(srv, testAccessKey, "wrong-secret").ListBuckets(ctx, &s3.ListBucketsInput{})
+	if code := s3ErrorCode(err); code != S3ErrSignatureDoesNotMatch {
+		t.Errorf("wrong secret: expected %s, got %v", S3ErrSignatureDoesNotMatch, err)
+	}
+	_, err = newSignedS3Client(srv, "AKIDUNKNOWN", testSecretKey).ListBuckets(ctx, &s3.ListBucketsInput{})
+	if code := s3ErrorCode(err); code != S3ErrInvalidAccessKeyID {
+		t.Errorf("unknown key: expected %s, got %v", S3ErrInvalidAccessKeyID, err)
+	}
+
+	resp, err := http.Get(srv.URL + "/api/v1/simulate/aws-s3/")
+	if err != nil {
+		t.Fatalf("anonymous GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("anonymous request: expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestS3SigV4RejectsSkewedAndExpiredRequests(t *testing.T) {
+	srv := newAuthS3TestServer(t)
+	ctx := context.Background()
+	creds := aws.Credentials{AccessKeyID: testAccessKey, SecretAccessKey: testSecretKey}
+	signer := v4.NewSigner()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/simulate/aws-s3/", nil)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if err := signer.SignHTTP(ctx, creds, req, emptySHA256, "s3", "us-east-1", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("SignHTTP: %v", err)
+	}
+	if code := doS3ErrorRequest(t, req); code != S3ErrRequestTimeTooSkewed {
+		t.Errorf("skewed request: expected %s, got %s", S3ErrRequestTimeTooSkewed, code)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/api/v1/simulate/aws-s3/?X-Amz-Expires=60", nil)
+	url, _, err := signer.PresignHTTP(ctx, creds, req, "UNSIGNED-PAYLOAD", "s3", "us-east-1", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("PresignHTTP: %v", err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, url, nil)
+	if code := doS3ErrorRequest(t, req); code != S3ErrAccessDenied {
+		t.Errorf("expired presigned URL: expected %s, got %s", S3ErrAccessDenied, code)
+	}
+
+	// A tampered payload must not pass the x-amz-content-sha256 check
+	req, _ = http.NewRequest(http.MethodPut, srv.URL+"/api/v1/simulate/aws-s3/tampered", bytes.NewReader([]byte("tampered")))
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if err := signer.SignHTTP(ctx, creds, req, emptySHA256, "s3", "us-east-1", time.Now()); err != nil {
+		t.Fatalf("SignHTTP: %v", err)
+	}
+	if code := doS3ErrorRequest(t, req); code != S3ErrContentSHA256Mismatch {
+		t.Errorf("tampered payload: expected %s, got %s", S3ErrContentSHA256Mismatch, code)
+	}
+}
+
+func TestS3SigV4CanonicalQueryAndScopeDate(t *testing.T) {
+	srv := newAuthS3TestServer(t)
+	ctx := context.Background()
+	creds := aws.Credentials{AccessKeyID: testAccessKey, SecretAccessKey: testSecretKey}
+	signer := v4.NewSigner()
+
+	// "a" is a prefix of "a-b": parameters sort by key, so a=... comes first
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/simulate/aws-s3/?a-b=2&a=1", nil)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if err := signer.SignHTTP(ctx, creds, req, emptySHA256, "s3", "us-east-1", time.Now()); err != nil {
+		t.Fatalf("SignHTTP: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("request with prefixed query keys: status %d, want 200", resp.StatusCode)
+	}
+	if got := sigV4CanonicalQuery(req.URL.Query()); got != "a=1&a-b=2" {
+		t.Errorf("sigV4CanonicalQuery = %q", got)
+	}
+
+	// The credential scope must be dated the day of X-Amz-Date
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/api/v1/simulate/aws-s3/", nil)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	now := time.Now().UTC()
+	if err := signer.SignHTTP(ctx, creds, req, emptySHA256, "s3", "us-east-1", now); err != nil {
+		t.Fatalf("SignHTTP: %v", err)
+	}
+	day := now.Format("20060102")
+	req.Header.Set("Authorization", strings.Replace(req.Header.Get("Authorization"), "/"+day+"/", "/20000101/", 1))
+	if code := doS3ErrorRequest(t, req); code != S3ErrAuthorizationHeaderMalformed {
+		t.Errorf("scope dated another day: expected %s, got %s", S3ErrAuthorizationHeaderMalformed, code)
+	}
+}
+
+func doS3ErrorRequest(t *testing.T, req *http.Request) string {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+	}
+	defer resp.Body.Close()
+	var out s3Error
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode S3 error (status %d): %v", resp.StatusCode, err)
+	}
+	return out.Code
+}
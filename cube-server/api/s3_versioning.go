@@ -0,0 +1,227 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	simulation "github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+// S3 bucket versioning: GET/PUT ?versioning, GET ?versions and the versionId
+// parameter on object reads and deletes. Versioning state and object versions are
+// kept by simulation.BucketStore.
+
+const (
+	s3VersionIDHeader    = "X-Amz-Version-Id"
+	s3DeleteMarkerHeader = "X-Amz-Delete-Marker"
+)
+
+type s3VersioningConfiguration struct {
+	XMLName   xml.Name `xml:"VersioningConfiguration"`
+	Xmlns     string   `xml:"xmlns,attr,omitempty"`
+	Status    string   `xml:"Status,omitempty"`
+	MfaDelete string   `xml:"MfaDelete,omitempty"`
+}
+
+type s3VersionEntry struct {
+	Key          string  `xml:"Key"`
+	VersionID    string  `xml:"VersionId"`
+	IsLatest     bool    `xml:"IsLatest"`
+	LastModified string  `xml:"LastModified"`
+	ETag         string  `xml:"ETag"`
+	Size         int64   `xml:"Size"`
+	StorageClass string  `xml:"StorageClass"`
+	Owner        s3Owner `xml:"Owner"`
+}
+
+type s3DeleteMarkerEntry struct {
+	Key          string  `xml:"Key"`
+	VersionID    string  `xml:"VersionId"`
+	IsLatest     bool    `xml:"IsLatest"`
+	LastModified string  `xml:"LastModified"`
+	Owner        s3Owner `xml:"Owner"`
+}
+
+type s3ListVersionsResult struct {
+	XMLName             xml.Name              `xml:"ListVersionsResult"`
+	Xmlns               string                `xml:"xmlns,attr"`
+	Name                string                `xml:"Name"`
+	Prefix              string                `xml:"Prefix"`
+	KeyMarker           string                `xml:"KeyMarker"`
+	VersionIDMarker     string                `xml:"VersionIdMarker"`
+	NextKeyMarker       string                `xml:"NextKeyMarker,omitempty"`
+	NextVersionIDMarker string                `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int                   `xml:"MaxKeys"`
+	Delimiter           string                `xml:"Delimiter,omitempty"`
+	EncodingType        string                `xml:"EncodingType,omitempty"`
+	IsTruncated         bool                  `xml:"IsTruncated"`
+	Versions            []s3VersionEntry      `xml:"Version"`
+	DeleteMarkers       []s3DeleteMarkerEntry `xml:"DeleteMarker"`
+	CommonPrefixes      []s3CommonPrefix      `xml:"CommonPrefixes"`
+}
+
+func (h *ProviderSimulationHandlers) s3GetBucketVersioning(c *gin.Context, bucket string) {
+	status, err := projectSimulation(c, h.simulator).BucketStore().Versioning(h.s3Provider(c), bucket)
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+	cfg := s3VersioningConfiguration{Xmlns: s3XMLNamespace}
+	if status != simulation.VersioningUnversioned {
+		// Never-versioned buckets return an empty configuration
+		cfg.Status = status
+	}
+	h.writeS3XML(c, http.StatusOK, cfg)
+}
+
+func (h *ProviderSimulationHandlers) s3PutBucketVersioning(c *gin.Context, bucket string) {
+	body, err := h.s3ReadBody(c)
+	if err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, err.Error(), bucket, "")
+		return
+	}
+	var cfg s3VersioningConfiguration
+	if err := xml.Unmarshal(bytes.TrimSpace(body), &cfg); err != nil {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema.", bucket, "")
+		return
+	}
+	if cfg.Status != simulation.VersioningEnabled && cfg.Status != simulation.VersioningSuspended {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrIllegalVersioningConfiguration, "The Versioning element must be specified", bucket, "")
+		return
+	}
+	if err := projectSimulation(c, h.simulator).BucketStore().SetVersioning(h.s3Provider(c), bucket, cfg.Status); err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *ProviderSimulationHandlers) s3ListObjectVersions(c *gin.Context, bucket string) {
+	query := c.Request.URL.Query()
+	maxKeys, ok := s3QueryLimit(query.Get("max-keys"), s3DefaultMaxKeys)
+	if !ok {
+		h.writeS3Error(c, http.StatusBadRequest, S3ErrInvalidArgument, "Provided max-keys not an integer or within integer range", bucket, "")
+		return
+	}
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	encodingType := query.Get("encoding-type")
+	keyMarker := query.Get("key-marker")
+	versionIDMarker := query.Get("version-id-marker")
+
+	versions, err := projectSimulation(c, h.simulator).BucketStore().ListObjectVersions(h.s3Provider(c), bucket, prefix)
+	if err != nil {
+		h.writeS3StoreError(c, err, bucket, "")
+		return
+	}
+
+	owner := s3Owner{ID: s3SimOwnerID, DisplayName: s3SimOwnerID}
+	result := s3ListVersionsResult{
+		Xmlns:           s3XMLNamespace,
+		Name:            bucket,
+		Prefix:          s3EncodeKey(prefix, encodingType),
+		KeyMarker:       s3EncodeKey(keyMarker, encodingType),
+		VersionIDMarker: versionIDMarker,
+		MaxKeys:         maxKeys,
+		Delimiter:       s3EncodeKey(delimiter, encodingType),
+		EncodingType:    encodingType,
+	}
+	// Entries up to key-marker are skipped; within the marker key, only versions
+	// listed after version-id-marker are returned.
+	skipping := keyMarker != ""
+	seenPrefixes := map[string]bool{}
+	count := 0
+	for _, v := range versions {
+		if skipping {
+			if v.Key < keyMarker {
+				continue
+			}
+			if v.Key == keyMarker {
+				if v.VersionID == versionIDMarker {
+					skipping = false
+				}
+				continue
+			}
+			skipping = false
+		}
+		if delimiter != "" {
+			if idx := strings.Index(v.Key[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix := v.Key[:len(prefix)+idx+len(delimiter)]
+				if seenPrefixes[commonPrefix] {
+					continue
+				}
+				if count >= maxKeys {
+					result.IsTruncated = true
+					break
+				}
+				seenPrefixes[commonPrefix] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: s3EncodeKey(commonPrefix, encodingType)})
+				count++
+				continue
+			}
+		}
+		if count >= maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		if v.IsDeleteMarker {
+			result.DeleteMarkers = append(result.DeleteMarkers, s3DeleteMarkerEntry{
+				Key:          s3EncodeKey(v.Key, encodingType),
+				VersionID:    v.VersionID,
+				IsLatest:     v.IsLatest,
+				LastModified: v.LastModified.UTC().Format(s3TimeFormat),
+				Owner:        owner,
+			})
+		} else {
+			result.Versions = append(result.Versions, s3VersionEntry{
+				Key:          s3EncodeKey(v.Key, encodingType),
+				VersionID:    v.VersionID,
+				IsLatest:     v.IsLatest,
+				LastModified: v.LastModified.UTC().Format(s3TimeFormat),
+				ETag:         quoteETag(v.ETag),
+				Size:         v.Size(),
+				StorageClass: "STANDARD",
+				Owner:        owner,
+			})
+		}
+		result.NextKeyMarker = s3EncodeKey(v.Key, encodingType)
+		result.NextVersionIDMarker = v.VersionID
+		count++
+	}
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIDMarker = "", ""
+	}
+	h.writeS3XML(c, http.StatusOK, result)
+}
+
+func (h *ProviderSimulationHandlers) s3DeleteObjectVersion(c *gin.Context, bucket, key, versionID string) {
+	obj, err := projectSimulation(c, h.simulator).BucketStore().DeleteObjectVersion(h.s3Provider(c), bucket, key, versionID)
+	if err != nil && !errors.Is(err, simulation.ErrNoSuchVersion) {
+		h.writeS3StoreError(c, err, bucket, key)
+		return
+	}
+	// Like S3, deleting a version that does not exist succeeds
+	c.Header(s3VersionIDHeader, versionID)
+	if obj != nil && obj.IsDeleteMarker {
+		c.Header(s3DeleteMarkerHeader, "true")
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// setS3VersionHeaders adds x-amz-version-id for objects written while versioning was
+// enabled or suspended.
+func setS3VersionHeaders(c *gin.Context, obj *simulation.Object) {
+	if obj == nil {
+		return
+	}
+	if obj.VersionID != "" && obj.VersionID != simulation.NullVersionID {
+		c.Header(s3VersionIDHeader, obj.VersionID)
+	}
+	if obj.IsDeleteMarker {
+		c.Header(s3DeleteMarkerHeader, "true")
+	}
+}
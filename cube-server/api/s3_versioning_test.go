@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func getS3ObjectBody(t *testing.T, client *s3.Client, input *s3.GetObjectInput) string {
+	t.Helper()
+	out, err := client.GetObject(context.Background(), input)
+	if err != nil {
+		t.Fatalf("GetObject(%s, version %s): %v", aws.ToString(input.Key), aws.ToString(input.VersionId), err)
+	}
+	defer out.Body.Close()
+	body, _ := io.ReadAll(out.Body)
+	return string(body)
+}
+
+func TestS3ProtocolBucketVersioning(t *testing.T) {
+	srv := newS3TestServer(t)
+	client := newS3TestClient(srv, true)
+	ctx := context.Background()
+	bucket := aws.String("versioned")
+	key := aws.String("backup.tar")
+
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: bucket}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	status, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: bucket})
+	if err != nil || status.Status != "" {
+		t.Fatalf("GetBucketVersioning on new bucket = %q, %v", status.Status, err)
+	}
+	unversioned, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: key, Body: strings.NewReader("v0")})
+	if err != nil || unversioned.VersionId != nil {
+		t.Fatalf("PutObject unversioned: version %v, %v", aws.ToString(unversioned.VersionId), err)
+	}
+
+	if _, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  bucket,
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	}); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	status, _ = client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: bucket})
+	if status.Status != types.BucketVersioningStatusEnabled {
+		t.Fatalf("GetBucketVersioning = %q, want Enabled", status.Status)
+	}
+
+	v1, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: key, Body: strings.NewReader("v1")})
+	if err != nil || aws.ToString(v1.VersionId) == "" {
+		t.Fatalf("PutObject v1: version %q, %v", aws.ToString(v1.VersionId), err)
+	}
+	v2, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: key, Body: strings.NewReader("v2")})
+	if err != nil || aws.ToString(v2.VersionId) == aws.ToString(v1.VersionId) {
+		t.Fatalf("PutObject v2: version %q, %v", aws.ToString(v2.VersionId), err)
+	}
+	if got := getS3ObjectBody(t, client, &s3.GetObjectInput{Bucket: bucket, Key: key}); got != "v2" {
+		t.Errorf("GetObject latest = %q, want v2", got)
+	}
+	if got := getS3ObjectBody(t, client, &s3.GetObjectInput{Bucket: bucket, Key: key, VersionId: v1.VersionId}); got != "v1" {
+		t.Errorf("GetObject v1 = %q", got)
+	}
+	if got := getS3ObjectBody(t, client, &s3.GetObjectInput{Bucket: bucket, Key: key, VersionId: aws.String("null")}); got != "v0" {
+		t.Errorf("GetObject null version = %q, want the pre-versioning object", got)
+	}
+
+	// Deleting without a version ID adds a delete marker
+	del, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: bucket, Key: key})
+	if err != nil || !aws.ToBool(del.DeleteMarker) || aws.ToString(del.VersionId) == "" {
+		t.Fatalf("DeleteObject: marker=%v version=%q err=%v", aws.ToBool(del.DeleteMarker), aws.ToString(del.VersionId), err)
+	}
+	_, err = client.GetObject(ctx, &s3.GetObjectInput{Bucket: bucket, Key: key})
+	if code := s3ErrorCode(err); code != S3ErrNoSuchKey {
+		t.Errorf("GetObject after delete: expected %s, got %v", S3ErrNoSuchKey, err)
+	}
+	_, err = client.GetObject(ctx, &s3.GetObjectInput{Bucket: bucket, Key: key, VersionId: del.VersionId})
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != S3ErrMethodNotAllowed {
+		t.Errorf("GetObject on delete marker: expected %s, got %v", S3ErrMethodNotAllowed, err)
+	}
+	listed, _ := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: bucket})
+	if len(listed.Contents) != 0 {
+		t.Errorf("ListObjectsV2 after delete = %d objects, want 0", len(listed.Contents))
+	}
+
+	versions, err := client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: bucket})
+	if err != nil {
+		t.Fatalf("ListObjectVersions: %v", err)
+	}
+	if len(versions.Versions) != 3 || len(versions.DeleteMarkers) != 1 || !aws.ToBool(versions.DeleteMarkers[0].IsLatest) {
+		t.Fatalf("ListObjectVersions = %d versions, %d markers", len(versions.Versions), len(versions.DeleteMarkers))
+	}
+	if aws.ToString(versions.Versions[0].VersionId) != aws.ToString(v2.VersionId) || aws.ToBool(versions.Versions[0].IsLatest) {
+		t.Errorf("newest version should be v2 and noncurrent, got %s latest=%v", aws.ToString(versions.Versions[0].VersionId), aws.ToBool(versions.Versions[0].IsLatest))
+	}
+	page, err := client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: bucket, MaxKeys: aws.Int32(2)})
+	if err != nil || !aws.ToBool(page.IsTruncated) {
+		t.Fatalf("ListObjectVersions max-keys=2: truncated=%v, %v", aws.ToBool(page.IsTruncated), err)
+	}
+	rest, err := client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: bucket, KeyMarker: page.NextKeyMarker, VersionIdMarker: page.NextVersionIdMarker})
+	if err != nil || len(rest.Versions)+len(rest.DeleteMarkers) != 2 {
+		t.Errorf("ListObjectVersions second page = %d entries, %v", len(rest.Versions)+len(rest.DeleteMarkers), err)
+	}
+
+	// Removing the delete marker restores v2; permanently deleting v2 makes v1 current
+	undo, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: bucket, Key: key, VersionId: del.VersionId})
+	if err != nil || !aws.ToBool(undo.DeleteMarker) {
+		t.Fatalf("DeleteObject(marker): marker=%v, %v", aws.ToBool(undo.DeleteMarker), err)
+	}
+	if got := getS3ObjectBody(t, client, &s3.GetObjectInput{Bucket: bucket, Key: key}); got != "v2" {
+		t.Errorf("GetObject after removing marker = %q, want v2", got)
+	}
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: bucket, Key: key, VersionId: v2.VersionId}); err != nil {
+		t.Fatalf("DeleteObject(v2): %v", err)
+	}
+	if got := getS3ObjectBody(t, client, &s3.GetObjectInput{Bucket: bucket, Key: key}); got != "v1" {
+		t.Errorf("GetObject after deleting v2 = %q, want v1", got)
+	}
+	_, err = client.GetObject(ctx, &s3.GetObjectInput{Bucket: bucket, Key: key, VersionId: v2.VersionId})
+	if code := s3ErrorCode(err); code != S3ErrNoSuchVersion {
+		t.Errorf("GetObject deleted version: expected %s, got %v", S3ErrNoSuchVersion, err)
+	}
+
+	// Suspended buckets write "null" versions and keep the existing history
+	if _, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  bucket,
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusSuspended},
+	}); err != nil {
+		t.Fatalf("PutBucketVersioning(Suspended): %v", err)
+	}
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: bucket, Key: key, Body: strings.NewReader("v3")}); err != nil {
+		t.Fatalf("PutObject suspended: %v", err)
+	}
+	versions, _ = client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: bucket})
+	if len(versions.Versions) != 2 || aws.ToString(versions.Versions[0].VersionId) != "null" || aws.ToString(versions.Versions[1].VersionId) != aws.ToString(v1.VersionId) {
+		t.Errorf("versions after suspended put = %+v", versions.Versions)
+	}
+
+	_, err = client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: bucket})
+	if code := s3ErrorCode(err); code != S3ErrBucketNotEmpty {
+		t.Errorf("DeleteBucket with versions: expected %s, got %v", S3ErrBucketNotEmpty, err)
+	}
+}
+
+func TestSimulateBucketVersioningOperations(t *testing.T) {
+	srv := newS3TestServer(t)
+	operation := func(op string, params map[string]interface{}) (int, map[string]interface{}) {
+		body, _ := json.Marshal(map[string]interface{}{"provider": "hetzner", "operation": op, "parameters": params})
+		resp, err := http.Post(srv.URL+"/api/v1/simulate/providers/hetzner/operations/"+op, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%s: %v", op, err)
+		}
+		defer resp.Body.Close()
+		var out map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	if code, _ := operation("set_bucket_versioning", map[string]interface{}{"bucket": "missing", "enabled": true}); code != http.StatusBadRequest {
+		t.Errorf("set_bucket_versioning on missing bucket: status %d", code)
+	}
+	if code, _ := operation("create_bucket", map[string]interface{}{"name": "hz-versioned"}); code != http.StatusOK {
+		t.Fatalf("create_bucket: status %d", code)
+	}
+	if code, _ := operation("set_bucket_versioning", map[string]interface{}{"bucket": "hz-versioned", "enabled": true}); code != http.StatusOK {
+		t.Fatalf("set_bucket_versioning: status %d", code)
+	}
+	_, out := operation("get_bucket_versioning", map[string]interface{}{"bucket": "hz-versioned"})
+	if result, _ := out["result"].(map[string]interface{}); result["versioning"] != "Enabled" {
+		t.Errorf("get_bucket_versioning = %v", out)
+	}
+	if code, _ := operation("set_bucket_versioning", map[string]interface{}{"bucket": "hz-versioned", "status": "Unversioned"}); code != http.StatusBadRequest {
+		t.Errorf("returning to Unversioned should fail, got status %d", code)
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Simulation admin endpoints under /api/v1/simulate/admin. They control the
+// simulation clock, so time-based behaviour (lifecycle expiration, transitions,
+// aborting stale multipart uploads) can be tested without waiting.
+
+// ClockAdvanceRequest moves the simulation clock forward by Days plus Duration.
+type ClockAdvanceRequest struct {
+	Days     int    `json:"days,omitempty"`
+	Duration string `json:"duration,omitempty"` // Go duration, e.g. "36h"
+	// ApplyLifecycle runs the lifecycle engine after advancing (default true)
+	ApplyLifecycle *bool `json:"apply_lifecycle,omitempty"`
+}
+
+func (h *ProviderSimulationHandlers) clockState() gin.H {
+	clock := h.simulator.Clock()
+	return gin.H{
+		"now":    clock.Now().Format(time.RFC3339),
+		"offset": clock.Offset().String(),
+	}
+}
+
+// GetSimulationClock handles GET /api/v1/simulate/admin/clock
+func (h *ProviderSimulationHandlers) GetSimulationClock(c *gin.Context) {
+	c.JSON(http.StatusOK, h.clockState())
+}
+
+// AdvanceSimulationClock handles POST /api/v1/simulate/admin/clock/advance
+func (h *ProviderSimulationHandlers) AdvanceSimulationClock(c *gin.Context) {
+	var req ClockAdvanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	d := time.Duration(req.Days) * 24 * time.Hour
+	if req.Duration != "" {
+		parsed, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration: " + err.Error()})
+			return
+		}
+		d += parsed
+	}
+	if _, err := h.simulator.Clock().Advance(d); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.logger.Info("Simulation clock advanced", zap.Duration("by", d), zap.Duration("offset", h.simulator.Clock().Offset()))
+
+	resp := h.clockState()
+	if req.ApplyLifecycle == nil || *req.ApplyLifecycle {
+		resp["lifecycle_actions"] = h.simulator.ApplyLifecycle()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ResetSimulationClock handles POST /api/v1/simulate/admin/clock/reset
+func (h *ProviderSimulationHandlers) ResetSimulationClock(c *gin.Context) {
+	h.simulator.Clock().Reset()
+	c.JSON(http.StatusOK, h.clockState())
+}
+
+// RunLifecycle handles POST /api/v1/simulate/admin/lifecycle/run; like the clock,
+// it concerns every project
+func (h *ProviderSimulationHandlers) RunLifecycle(c *gin.Context) {
+	resp := h.clockState()
+	resp["lifecycle_actions"] = h.simulator.ApplyLifecycle()
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+func TestSimulatedClusterLifecycle(t *testing.T) {
+	srv := newS3TestServer(t)
+	op := func(operation string, params map[string]interface{}) simulation.SimulationResult {
+		_, result := simulateWithSeed(t, srv.URL, "", operation, params)
+		return result
+	}
+	advance := func(duration string) {
+		if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/simulate/admin/clock/advance",
+			map[string]interface{}{"duration": duration, "apply_lifecycle": false}, nil); status != http.StatusOK {
+			t.Fatalf("advance clock = %d", status)
+		}
+	}
+
+	created := op("create_cluster", map[string]interface{}{"name": "eks-1", "node_count": 2})
+	id, _ := created.Result["cluster_id"].(string)
+	if !created.Success || created.Result["status"] != "creating" || created.Result["next_status"] != "running" {
+		t.Fatalf("create_cluster = %+v", created)
+	}
+	listed := op("list_clusters", nil)
+	if clusters, _ := listed.Result["clusters"].([]interface{}); len(clusters) != 1 || clusters[0].(map[string]interface{})["cluster_id"] != id {
+		t.Errorf("list_clusters after create = %+v", listed.Result)
+	}
+	if result := op("scale_cluster", map[string]interface{}{"cluster_id": id, "node_count": 5}); result.Success ||
+		!strings.Contains(result.Error, "while it is creating") {
+		t.Errorf("scaling a creating cluster = %+v", result)
+	}
+
+	// fast-simulate compresses the 30s creation to 300ms of simulation time
+	advance("1s")
+	if result := op("get_cluster", map[string]interface{}{"cluster_id": id}); result.Result["status"] != "running" || result.Result["next_status"] != nil {
+		t.Errorf("get_cluster after creation time = %+v", result)
+	}
+	if result := op("scale_cluster", map[string]interface{}{"cluster_id": id, "node_count": 5}); !result.Success || result.Result["node_count"] != float64(5) {
+		t.Errorf("scale_cluster = %+v", result)
+	}
+	if result := op("scale_cluster", map[string]interface{}{"cluster_id": id, "node_count": 0}); result.Success {
+		t.Errorf("scaling to 0 nodes = %+v", result)
+	}
+
+	if result := op("delete_cluster", map[string]interface{}{"cluster_id": id}); result.Result["status"] != "deleting" {
+		t.Errorf("delete_cluster = %+v", result)
+	}
+	for _, action := range []string{"scale_cluster", "delete_cluster"} {
+		if result := op(action, map[string]interface{}{"cluster_id": id, "node_count": 3}); result.Success ||
+			!strings.Contains(result.Error, "while it is deleting") {
+			t.Errorf("%s on a deleting cluster = %+v", action, result)
+		}
+	}
+
+	// The registry outlives the service
+	reopened := NewTestSimulationService()
+	if cluster, err := reopened.Clusters().Get("aws", id); err != nil || cluster.NodeCount != 5 {
+		t.Errorf("reloaded cluster = %+v, %v", cluster, err)
+	}
+
+	advance("1s")
+	if result := op("get_cluster", map[string]interface{}{"cluster_id": id}); result.Success || !strings.Contains(result.Error, "cluster not found") {
+		t.Errorf("get_cluster after deletion = %+v", result)
+	}
+	if result := op("list_clusters", nil); result.Result["total"] != float64(0) {
+		t.Errorf("list_clusters after deletion = %+v", result.Result)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	"go.uber.org/zap"
+)
+
+// Fault injection endpoints under /api/v1/simulate/admin/faults. The profiles start out
+// as configured in simulation.faults and can be replaced at runtime; see
+// simulation.FaultProfile for what they match and inject.
+
+// GetFaultProfiles handles GET /api/v1/simulate/admin/faults
+func (h *ProviderSimulationHandlers) GetFaultProfiles(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"profiles": h.simulator.Faults().Profiles()})
+}
+
+// SetFaultProfiles handles PUT /api/v1/simulate/admin/faults with {"profiles": [...]}
+func (h *ProviderSimulationHandlers) SetFaultProfiles(c *gin.Context) {
+	var req struct {
+		Profiles []simulation.FaultProfile `json:"profiles"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if err := h.simulator.Faults().SetProfiles(req.Profiles); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.logger.Info("Fault profiles replaced", zap.Int("profiles", len(req.Profiles)))
+	c.JSON(http.StatusOK, gin.H{"profiles": h.simulator.Faults().Profiles()})
+}
+
+// ClearFaultProfiles handles DELETE /api/v1/simulate/admin/faults
+func (h *ProviderSimulationHandlers) ClearFaultProfiles(c *gin.Context) {
+	_ = h.simulator.Faults().SetProfiles(nil)
+	h.logger.Info("Fault profiles cleared")
+	c.Status(http.StatusNoContent)
+}
+
+// writeSimulationFault answers with the status of the fault injected into result and
+// reports whether there was one
+func writeSimulationFault(c *gin.Context, result *simulation.SimulationResult) bool {
+	if result.Fault == nil {
+		return false
+	}
+	setRetryAfter(c, result.Fault)
+	c.JSON(result.Fault.Status, result)
+	return true
+}
+
+// s3InjectFault applies the fault profiles to an S3 request, matching the operation by
+// its IAM action (s3:PutObject, ...) and the resource by bucket name. It reports
+// whether the request may proceed.
+func (h *ProviderSimulationHandlers) s3InjectFault(c *gin.Context, bucket, key string) bool {
+	action := "s3:ListAllMyBuckets"
+	if bucket != "" {
+		action = s3RequestAction(c.Request.Method, c.Request.URL.Query(), key != "")
+	}
+	_, err := h.simulator.Faults().Inject(c.Request.Context(), h.s3Provider(c), action, bucket)
+	if err == nil {
+		return true
+	}
+	var fault *simulation.Fault
+	if errors.As(err, &fault) {
+		setRetryAfter(c, fault)
+		h.writeS3Error(c, fault.Status, fault.Code, fault.Message, bucket, key)
+	} else {
+		// the client went away while the injected latency was running
+		c.Status(http.StatusRequestTimeout)
+	}
+	return false
+}
+
+func setRetryAfter(c *gin.Context, fault *simulation.Fault) {
+	if fault.RetryAfter > 0 {
+		seconds := math.Ceil(time.Duration(fault.RetryAfter).Seconds())
+		c.Header("Retry-After", strconv.Itoa(int(seconds)))
+	}
+}
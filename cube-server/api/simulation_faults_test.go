@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+func setFaultProfiles(t *testing.T, srv string, profiles ...simulation.FaultProfile) int {
+	t.Helper()
+	return doJSON(t, http.MethodPut, srv+"/api/v1/simulate/admin/faults", map[string]interface{}{"profiles": profiles}, nil)
+}
+
+func TestFaultInjectionOnSimulatedOperations(t *testing.T) {
+	srv := newS3TestServer(t)
+	if status := setFaultProfiles(t, srv.URL, simulation.FaultProfile{
+		Name:      "throttle-first",
+		Provider:  "aws",
+		Operation: "create_*",
+		FailCalls: []int{1},
+		Error:     &simulation.FaultError{Code: simulation.FaultThrottling, RetryAfter: simulation.Duration(1500 * time.Millisecond)},
+	}); status != http.StatusOK {
+		t.Fatalf("PUT faults = %d", status)
+	}
+
+	op := func() (*http.Response, simulation.SimulationResult) {
+		body, _ := json.Marshal(map[string]interface{}{
+			"provider": "aws", "operation": "create_cluster",
+			"parameters": map[string]interface{}{"name": "eks-1", "node_count": 2},
+		})
+		resp, err := http.Post(srv.URL+"/api/v1/simulate/providers/aws/operations/create_cluster", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var result simulation.SimulationResult
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	resp, result := op()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "2" ||
+		result.Fault == nil || result.Fault.Code != simulation.FaultThrottling {
+		t.Errorf("first call = %d (Retry-After %q) %+v", resp.StatusCode, resp.Header.Get("Retry-After"), result)
+	}
+	if resp, result = op(); resp.StatusCode != http.StatusOK || !result.Success {
+		t.Errorf("retried call = %d %+v", resp.StatusCode, result)
+	}
+
+	var listed struct {
+		Profiles []simulation.FaultProfileStatus `json:"profiles"`
+	}
+	doJSON(t, http.MethodGet, srv.URL+"/api/v1/simulate/admin/faults", nil, &listed)
+	if len(listed.Profiles) != 1 || listed.Profiles[0].Calls != 2 || listed.Profiles[0].Failures != 1 {
+		t.Errorf("profiles = %+v", listed.Profiles)
+	}
+
+	// A timeout holds the call and answers 504; latency delays it
+	setFaultProfiles(t, srv.URL,
+		simulation.FaultProfile{Operation: "create_cluster", Timeout: simulation.Duration(50 * time.Millisecond)},
+		simulation.FaultProfile{Latency: &simulation.Latency{Value: simulation.Duration(80 * time.Millisecond)}},
+	)
+	start := time.Now()
+	if resp, result = op(); resp.StatusCode != http.StatusGatewayTimeout || result.Fault.Code != simulation.FaultRequestTimeout || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("timeout call = %d %+v after %s", resp.StatusCode, result, time.Since(start))
+	}
+	start = time.Now()
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/simulate/providers/aws/buckets", nil, nil); status != http.StatusOK || time.Since(start) < 80*time.Millisecond {
+		t.Errorf("delayed list = %d after %s", status, time.Since(start))
+	}
+
+	if status := setFaultProfiles(t, srv.URL, simulation.FaultProfile{ErrorRate: 2}); status != http.StatusBadRequest {
+		t.Errorf("invalid profile accepted: %d", status)
+	}
+	if status := doJSON(t, http.MethodDelete, srv.URL+"/api/v1/simulate/admin/faults", nil, nil); status != http.StatusNoContent {
+		t.Errorf("DELETE faults = %d", status)
+	}
+	if resp, _ = op(); resp.StatusCode != http.StatusOK {
+		t.Errorf("call after clearing profiles = %d", resp.StatusCode)
+	}
+}
+
+func TestFaultInjectionS3SDKRetries(t *testing.T) {
+	srv := newS3TestServer(t)
+	client := newS3TestClient(srv, true)
+	ctx := context.Background()
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("retry-bucket")}); err != nil {
+		t.Fatal(err)
+	}
+	setFaultProfiles(t, srv.URL, simulation.FaultProfile{
+		Operation: "s3:PutObject",
+		Resource:  "retry-*",
+		FailCalls: []int{1, 2},
+		Error:     &simulation.FaultError{Code: "SlowDown", Status: http.StatusServiceUnavailable},
+	})
+
+	noBackoff := func(o *s3.Options) {
+		o.Retryer = retry.NewStandard(func(so *retry.StandardOptions) {
+			so.MaxAttempts = 3
+			so.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+		})
+	}
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("retry-bucket"), Key: aws.String("a.txt"),
+		Body: bytes.NewReader([]byte("hello"))}, noBackoff); err != nil {
+		t.Fatalf("PutObject was not retried past the injected faults: %v", err)
+	}
+
+	setFaultProfiles(t, srv.URL, simulation.FaultProfile{
+		Operation: "s3:GetObject",
+		FailEvery: 1,
+		Error:     &simulation.FaultError{Code: "SlowDown", Status: http.StatusServiceUnavailable},
+	})
+	_, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("retry-bucket"), Key: aws.String("a.txt")}, noBackoff)
+	if code := s3ErrorCode(err); code != "SlowDown" {
+		t.Errorf("GetObject error = %v, want SlowDown", err)
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+// SimulationSeedHeader makes one request's simulation deterministic: the same request
+// with the same seed always gets the same IDs, outcomes and metrics
+const SimulationSeedHeader = "X-Cube-Sim-Seed"
+
+// simulationSeed passes the X-Cube-Sim-Seed header on to the simulation through the
+// request context
+func simulationSeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if v := c.GetHeader(SimulationSeedHeader); v != "" {
+			seed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid " + SimulationSeedHeader + " header: " + v})
+				return
+			}
+			c.Request = c.Request.WithContext(simulation.WithSeed(c.Request.Context(), seed))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	"go.uber.org/zap"
+)
+
+func simulateWithSeed(t *testing.T, srv, seed, operation string, params map[string]interface{}) (int, simulation.SimulationResult) {
+	t.Helper()
+	body, _ := json.Marshal(simulation.SimulationRequest{Provider: "aws", Operation: operation, Parameters: params})
+	req, _ := http.NewRequest(http.MethodPost, srv+"/api/v1/simulate/providers/aws/operations/"+operation, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if seed != "" {
+		req.Header.Set(SimulationSeedHeader, seed)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result simulation.SimulationResult
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+// deterministic drops the fields that follow the wall clock
+func deterministic(result simulation.SimulationResult) simulation.SimulationResult {
+	result.Timestamp, result.Duration = "", 0
+	for _, field := range []string{"created_at", "updated_at", "started_at", "transition_at"} {
+		delete(result.Result, field)
+	}
+	return result
+}
+
+func TestSimulationSeedHeader(t *testing.T) {
+	srv := newS3TestServer(t)
+	params := map[string]interface{}{"cluster_id": "sim-aws-1", "test_type": "performance"}
+
+	_, first := simulateWithSeed(t, srv.URL, "42", "run_test", params)
+	_, second := simulateWithSeed(t, srv.URL, "42", "run_test", params)
+	if !first.Success || !reflect.DeepEqual(deterministic(first), deterministic(second)) {
+		t.Errorf("same seed gave different results:\n%+v\n%+v", first, second)
+	}
+	_, other := simulateWithSeed(t, srv.URL, "43", "run_test", params)
+	if reflect.DeepEqual(deterministic(first), deterministic(other)) {
+		t.Errorf("seeds 42 and 43 gave the same result %+v", other)
+	}
+	if status, _ := simulateWithSeed(t, srv.URL, "not-a-number", "run_test", params); status != http.StatusBadRequest {
+		t.Errorf("invalid seed: status %d, want 400", status)
+	}
+}
+
+func TestSimulationServiceSeed(t *testing.T) {
+	run := func() *simulation.SimulationResult {
+		// each service starts with an empty cluster registry
+		t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+		sim := NewTestSimulationService()
+		sim.SetSeed(7)
+		sim.SimulateOperation(&simulation.SimulationRequest{Provider: "gcp", Operation: "list_clusters"})
+		return sim.SimulateOperation(&simulation.SimulationRequest{Provider: "gcp", Operation: "create_cluster",
+			Parameters: map[string]interface{}{"name": "gke-1", "node_count": 1}})
+	}
+	first, second := deterministic(*run()), deterministic(*run())
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("seeded services diverged:\n%+v\n%+v", first, second)
+	}
+}
+
+func TestSimulationServiceSeedBucketIDs(t *testing.T) {
+	run := func() (string, string) {
+		t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+		sim := NewTestSimulationService()
+		sim.SetSeed(7)
+		store := sim.BucketStore()
+		store.Create("aws", "seeded", "us-east-1")
+		if err := store.SetVersioning("aws", "seeded", simulation.VersioningEnabled); err != nil {
+			t.Fatal(err)
+		}
+		obj, err := store.PutObject("aws", "seeded", "key", []byte("data"), "text/plain", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		upload, err := store.CreateMultipartUpload("aws", "seeded", "big", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj.VersionID, upload.UploadID
+	}
+	version, upload := run()
+	if againVersion, againUpload := run(); version != againVersion || upload != againUpload {
+		t.Errorf("seeded services gave IDs %s/%s, then %s/%s", version, upload, againVersion, againUpload)
+	}
+}
+
+func TestSimulationCassetteRecordReplay(t *testing.T) {
+	t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	serve := func(cassette *simulation.Cassette) *httptest.Server {
+		sim := NewTestSimulationService()
+		sim.SetCassette(cassette)
+		r := newTestRouter(t, RouteOptions{Logger: zap.NewNop(), Sim: sim})
+		srv := httptest.NewServer(r)
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	calls := []struct {
+		operation string
+		params    map[string]interface{}
+	}{
+		{"create_cluster", map[string]interface{}{"name": "eks-1", "node_count": 3}},
+		{"run_test", map[string]interface{}{"cluster_id": "eks-1", "test_type": "security"}},
+		{"run_test", map[string]interface{}{"cluster_id": "eks-1", "test_type": "security"}},
+	}
+
+	recorder, err := simulation.NewRecordingCassette(cassettePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := serve(recorder)
+	var recorded []simulation.SimulationResult
+	for _, call := range calls {
+		_, result := simulateWithSeed(t, srv.URL, "", call.operation, call.params)
+		recorded = append(recorded, result)
+	}
+
+	player, err := simulation.LoadCassette(cassettePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(player.Interactions()) != len(calls) {
+		t.Fatalf("cassette holds %d interactions, want %d", len(player.Interactions()), len(calls))
+	}
+	srv = serve(player)
+	for i, call := range calls {
+		if _, result := simulateWithSeed(t, srv.URL, "", call.operation, call.params); !reflect.DeepEqual(result, recorded[i]) {
+			t.Errorf("replay %d = %+v, recorded %+v", i, result, recorded[i])
+		}
+	}
+	// Every recorded interaction is replayed once; unknown requests fail
+	if status, result := simulateWithSeed(t, srv.URL, "", "run_test", calls[1].params); status != http.StatusBadRequest || result.Success {
+		t.Errorf("exhausted replay = %d %+v", status, result)
+	}
+	if status, _ := simulateWithSeed(t, srv.URL, "", "list_clusters", nil); status != http.StatusBadRequest {
+		t.Errorf("unrecorded replay = %d", status)
+	}
+}
@@ -0,0 +1,535 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/internal"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/testrunner"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+	store "github.com/tronicum/punchbag-cube-testsuite/store"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Snapshot and restore of the whole server under /api/v1/simulate/admin: the store
+// (projects, clusters, node pools, test results, Azure resources) and the simulation
+// (buckets, objects, multipart uploads, simulated clusters, clock) of every project go
+// into one versioned archive. Named fixtures keep archives on the server, so test
+// suites can start from one and reset to it between cases.
+
+// SnapshotVersion is the archive format written by the server. Archives of a newer
+// version are refused.
+const SnapshotVersion = 1
+
+// SnapshotArchive is the state of the server at CreatedAt. A missing section restores
+// as empty.
+type SnapshotArchive struct {
+	Version    int                  `json:"version"`
+	CreatedAt  time.Time            `json:"created_at"`
+	Store      *store.Snapshot      `json:"store"`
+	Simulation *simulation.Snapshot `json:"simulation"`
+}
+
+var errUnsupportedSnapshot = errors.New("unsupported snapshot version")
+
+// Validate checks the version and both sections, so a restore can only fail on the
+// store afterwards
+func (a *SnapshotArchive) Validate() error {
+	if a.Version < 1 || a.Version > SnapshotVersion {
+		return fmt.Errorf("%w %d (this server reads 1 to %d)", errUnsupportedSnapshot, a.Version, SnapshotVersion)
+	}
+	if a.Store != nil {
+		if err := a.Store.Validate(); err != nil {
+			return err
+		}
+	}
+	if a.Simulation != nil {
+		return a.Simulation.Validate()
+	}
+	return nil
+}
+
+// isInvalidSnapshot tells archives the server refuses from failures to load them
+func isInvalidSnapshot(err error) bool {
+	return errors.Is(err, errUnsupportedSnapshot) || errors.Is(err, store.ErrInvalidSnapshot) || errors.Is(err, simulation.ErrInvalidSnapshot)
+}
+
+// requestGate holds back /api/v1 requests while a snapshot is taken or restored, so
+// the archive is consistent and no request sees a half-restored server. Event streams
+// are let through, since they would hold it up for as long as they run.
+type requestGate struct {
+	mu sync.RWMutex
+	// exclusive are the routes whose handlers take the gate themselves
+	exclusive map[string]bool
+}
+
+func (g *requestGate) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if g.exclusive[route] || route == "/api/v1/events" {
+			c.Next()
+			return
+		}
+		g.mu.RLock()
+		defer g.mu.RUnlock()
+		c.Next()
+	}
+}
+
+// SnapshotHandlers serves the snapshot, restore and fixture endpoints
+type SnapshotHandlers struct {
+	store    store.Store
+	sim      *simulation.SimulationService
+	tests    *testrunner.Executor // restarted around restores; may be nil
+	fixtures *fixtureStore
+	gate     *requestGate
+	logger   *zap.Logger
+}
+
+// NewSnapshotHandlers creates the snapshot handlers; fixtures are kept in fixturesDir,
+// or in memory when it is empty. Restores stop the tests of the executor.
+func NewSnapshotHandlers(st store.Store, sim *simulation.SimulationService, tests *testrunner.Executor, fixturesDir string, logger *zap.Logger) *SnapshotHandlers {
+	return &SnapshotHandlers{
+		store:    st,
+		sim:      sim,
+		tests:    tests,
+		fixtures: newFixtureStore(fixturesDir),
+		gate: &requestGate{exclusive: map[string]bool{
+			"/api/v1/simulate/admin/snapshot":               true,
+			"/api/v1/simulate/admin/restore":                true,
+			"/api/v1/simulate/admin/fixtures/:name":         true,
+			"/api/v1/simulate/admin/fixtures/:name/restore": true,
+		}},
+		logger: logger,
+	}
+}
+
+// fixturesDir is simulation.fixtures of the config, or CUBE_SERVER_SIM_FIXTURES without one
+func fixturesDir(cfg *internal.ServerConfig) string {
+	if cfg != nil {
+		return cfg.Simulation.Fixtures
+	}
+	return os.Getenv("CUBE_SERVER_SIM_FIXTURES")
+}
+
+// loadSimulationProjects loads the simulation of every project in the store, so a
+// snapshot includes projects without a request since the start and a restore also
+// clears their persisted state. The caller holds the gate.
+func (h *SnapshotHandlers) loadSimulationProjects() error {
+	projects, err := h.store.ListProjects()
+	for _, project := range projects {
+		h.sim.Project(project.Name)
+	}
+	return err
+}
+
+// snapshot takes the archive of the server, holding back other requests meanwhile
+func (h *SnapshotHandlers) snapshot() (*SnapshotArchive, error) {
+	h.gate.mu.Lock()
+	defer h.gate.mu.Unlock()
+	if err := h.loadSimulationProjects(); err != nil {
+		return nil, err
+	}
+	storeSnapshot, err := h.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	simSnapshot, err := h.sim.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotArchive{Version: SnapshotVersion, CreatedAt: time.Now().UTC(), Store: storeSnapshot, Simulation: simSnapshot}, nil
+}
+
+// restore validates an archive and replaces the state of the server with it, holding
+// back other requests meanwhile. The store is replaced first: it is the only part
+// that can still fail, and leaves everything unchanged when it does. No test runs
+// during the restore, so none writes into the restored store; running tests are
+// cancelled, and the pending tests of the archive are queued once it is in place.
+func (h *SnapshotHandlers) restore(archive *SnapshotArchive) error {
+	if err := archive.Validate(); err != nil {
+		return err
+	}
+	storeSnapshot, simSnapshot := archive.Store, archive.Simulation
+	if storeSnapshot == nil {
+		storeSnapshot = &store.Snapshot{}
+	}
+	if simSnapshot == nil {
+		simSnapshot = &simulation.Snapshot{}
+	}
+	h.gate.mu.Lock()
+	defer h.gate.mu.Unlock()
+	replace := func() error {
+		if err := h.loadSimulationProjects(); err != nil {
+			return err
+		}
+		if err := h.store.Restore(storeSnapshot); err != nil {
+			return err
+		}
+		return h.sim.Restore(simSnapshot)
+	}
+	if h.tests == nil {
+		return replace()
+	}
+	return h.tests.Restart(replace)
+}
+
+// writeRestoreError answers a failed restore: 400 for archives the server refuses
+func (h *SnapshotHandlers) writeRestoreError(c *gin.Context, err error) {
+	if isInvalidSnapshot(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.logger.Error("Failed to restore snapshot", zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+}
+
+// snapshotSummary counts what an archive holds
+func snapshotSummary(archive *SnapshotArchive) gin.H {
+	summary := gin.H{"version": archive.Version, "created_at": archive.CreatedAt}
+	var projects, clusters, nodePools, testResults, azureResources int
+	if s := archive.Store; s != nil {
+		projects = len(s.Projects)
+		for _, r := range s.Resources {
+			if r == nil {
+				continue
+			}
+			clusters += len(r.Clusters)
+			nodePools += len(r.NodePools)
+			testResults += len(r.TestResults)
+			azureResources += len(r.LogAnalyticsWorkspaces) + len(r.AppInsights) + len(r.AzureBudgets) + len(r.AzureMonitorings) + len(r.AzureKubernetes)
+		}
+	}
+	var buckets, objects, simulatedClusters int
+	if s := archive.Simulation; s != nil {
+		summary["simulation_time"] = s.Now
+		for _, p := range s.Projects {
+			if p == nil {
+				continue
+			}
+			for _, providerBuckets := range p.Buckets {
+				buckets += len(providerBuckets)
+			}
+			for _, providerObjects := range p.Objects {
+				for _, keys := range providerObjects {
+					objects += len(keys)
+				}
+			}
+			for _, providerClusters := range p.Clusters {
+				simulatedClusters += len(providerClusters)
+			}
+		}
+	}
+	summary["projects"] = projects
+	summary["clusters"] = clusters
+	summary["node_pools"] = nodePools
+	summary["test_results"] = testResults
+	summary["azure_resources"] = azureResources
+	summary["buckets"] = buckets
+	summary["objects"] = objects
+	summary["simulated_clusters"] = simulatedClusters
+	return summary
+}
+
+// GetSnapshot handles GET /simulate/admin/snapshot: the archive of the whole server,
+// whichever project the request is in
+func (h *SnapshotHandlers) GetSnapshot(c *gin.Context) {
+	archive, err := h.snapshot()
+	if err != nil {
+		h.logger.Error("Failed to take snapshot", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="cube-snapshot-`+archive.CreatedAt.Format("20060102-150405")+`.json"`)
+	c.JSON(http.StatusOK, archive)
+}
+
+// RestoreSnapshot handles POST /simulate/admin/restore with an archive as the body
+func (h *SnapshotHandlers) RestoreSnapshot(c *gin.Context) {
+	var archive SnapshotArchive
+	if err := c.ShouldBindJSON(&archive); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid snapshot: " + err.Error()})
+		return
+	}
+	if err := h.restore(&archive); err != nil {
+		h.writeRestoreError(c, err)
+		return
+	}
+	h.logger.Info("Snapshot restored", zap.Time("created_at", archive.CreatedAt))
+	c.JSON(http.StatusOK, snapshotSummary(&archive))
+}
+
+// ListFixtures handles GET /simulate/admin/fixtures
+func (h *SnapshotHandlers) ListFixtures(c *gin.Context) {
+	fixtures, err := h.fixtures.list()
+	if err != nil {
+		h.logger.Error("Failed to list fixtures", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	writeListPage(c, "fixtures", fixtures, fixtureListFields)
+}
+
+// GetFixture handles GET /simulate/admin/fixtures/:name: the archive of a fixture
+func (h *SnapshotHandlers) GetFixture(c *gin.Context) {
+	data, ok := h.readFixture(c)
+	if ok {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+	}
+}
+
+// SaveFixture handles POST /simulate/admin/fixtures/:name: the current state of the
+// server becomes the fixture, replacing one of the same name
+func (h *SnapshotHandlers) SaveFixture(c *gin.Context) {
+	name := c.Param("name")
+	if err := validateFixtureName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	archive, err := h.snapshot()
+	if err != nil {
+		h.logger.Error("Failed to take snapshot", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	h.putFixture(c, name, archive)
+}
+
+// PutFixture handles PUT /simulate/admin/fixtures/:name with an archive as the body,
+// e.g. one kept with a test suite
+func (h *SnapshotHandlers) PutFixture(c *gin.Context) {
+	name := c.Param("name")
+	if err := validateFixtureName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var archive SnapshotArchive
+	if err := c.ShouldBindJSON(&archive); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid snapshot: " + err.Error()})
+		return
+	}
+	if err := archive.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.putFixture(c, name, &archive)
+}
+
+func (h *SnapshotHandlers) putFixture(c *gin.Context, name string, archive *SnapshotArchive) {
+	data, err := json.Marshal(archive)
+	if err == nil {
+		err = h.fixtures.put(name, data)
+	}
+	if err != nil {
+		h.logger.Error("Failed to save fixture", zap.String("fixture", name), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	h.logger.Info("Fixture saved", zap.String("fixture", name))
+	summary := snapshotSummary(archive)
+	summary["name"] = name
+	c.JSON(http.StatusCreated, summary)
+}
+
+// RestoreFixture handles POST /simulate/admin/fixtures/:name/restore
+func (h *SnapshotHandlers) RestoreFixture(c *gin.Context) {
+	data, ok := h.readFixture(c)
+	if !ok {
+		return
+	}
+	var archive SnapshotArchive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid snapshot in fixture: " + err.Error()})
+		return
+	}
+	if err := h.restore(&archive); err != nil {
+		h.writeRestoreError(c, err)
+		return
+	}
+	h.logger.Info("Fixture restored", zap.String("fixture", c.Param("name")))
+	summary := snapshotSummary(&archive)
+	summary["name"] = c.Param("name")
+	c.JSON(http.StatusOK, summary)
+}
+
+// DeleteFixture handles DELETE /simulate/admin/fixtures/:name
+func (h *SnapshotHandlers) DeleteFixture(c *gin.Context) {
+	if _, ok := h.readFixture(c); !ok {
+		return
+	}
+	if err := h.fixtures.delete(c.Param("name")); err != nil {
+		h.logger.Error("Failed to delete fixture", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// readFixture returns the archive of the fixture named in the route, answering 400
+// or 404 itself
+func (h *SnapshotHandlers) readFixture(c *gin.Context) ([]byte, bool) {
+	name := c.Param("name")
+	if err := validateFixtureName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	data, err := h.fixtures.get(name)
+	switch {
+	case errors.Is(err, errFixtureNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "fixture not found: " + name})
+		return nil, false
+	case err != nil:
+		h.logger.Error("Failed to read fixture", zap.String("fixture", name), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	return data, true
+}
+
+// fixtureNamePattern keeps fixture names usable in URLs and file names
+var fixtureNamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,62}[A-Za-z0-9])?$`)
+
+func validateFixtureName(name string) error {
+	if !fixtureNamePattern.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid fixture name %q: use 1-64 letters, digits, dots, dashes and underscores", name)
+	}
+	return nil
+}
+
+var errFixtureNotFound = errors.New("fixture not found")
+
+// fixtureListFields sorts fixtures; they were created when saved
+var fixtureListFields = listFields[FixtureInfo]{
+	id:        func(f FixtureInfo) string { return f.Name },
+	name:      func(f FixtureInfo) string { return f.Name },
+	createdAt: func(f FixtureInfo) time.Time { return f.SavedAt },
+	sort: map[string]func(FixtureInfo) string{
+		"size": func(f FixtureInfo) string { return intSortKey(int(f.Size)) },
+	},
+}
+
+// FixtureInfo describes a saved fixture
+type FixtureInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// fixtureStore keeps the archives of named fixtures as <name>.json in dir, or in
+// memory when dir is empty
+type fixtureStore struct {
+	mu     sync.Mutex
+	dir    string
+	memory map[string]fixture
+}
+
+type fixture struct {
+	data    []byte
+	savedAt time.Time
+}
+
+func newFixtureStore(dir string) *fixtureStore {
+	return &fixtureStore{dir: dir, memory: make(map[string]fixture)}
+}
+
+func (f *fixtureStore) path(name string) string {
+	return filepath.Join(f.dir, name+".json")
+}
+
+// list returns the fixtures by name
+func (f *fixtureStore) list() ([]FixtureInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fixtures := []FixtureInfo{}
+	if f.dir == "" {
+		for name, fx := range f.memory {
+			fixtures = append(fixtures, FixtureInfo{Name: name, Size: int64(len(fx.data)), SavedAt: fx.savedAt})
+		}
+	} else {
+		entries, err := os.ReadDir(f.dir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name, isJSON := strings.CutSuffix(entry.Name(), ".json")
+			info, err := entry.Info()
+			if !isJSON || entry.IsDir() || err != nil || validateFixtureName(name) != nil {
+				continue
+			}
+			fixtures = append(fixtures, FixtureInfo{Name: name, Size: info.Size(), SavedAt: info.ModTime().UTC()})
+		}
+	}
+	sort.Slice(fixtures, func(i, j int) bool { return fixtures[i].Name < fixtures[j].Name })
+	return fixtures, nil
+}
+
+func (f *fixtureStore) get(name string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dir == "" {
+		fx, ok := f.memory[name]
+		if !ok {
+			return nil, errFixtureNotFound
+		}
+		return fx.data, nil
+	}
+	data, err := os.ReadFile(f.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errFixtureNotFound
+	}
+	return data, err
+}
+
+// put saves a fixture; files are written next to it and renamed, so a fixture is
+// never read half-written
+func (f *fixtureStore) put(name string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dir == "" {
+		f.memory[name] = fixture{data: data, savedAt: time.Now().UTC()}
+		return nil
+	}
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path(name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (f *fixtureStore) delete(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dir == "" {
+		delete(f.memory, name)
+		return nil
+	}
+	err := os.Remove(f.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+	sim := NewTestSimulationService()
+	r := newTestRouter(t, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: sim})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	base := srv.URL + "/api/v1"
+	admin := base + "/simulate/admin"
+	ctx := context.Background()
+	client := newS3TestClient(srv, true)
+
+	cluster := map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}
+	if status := doJSON(t, http.MethodPost, base+"/clusters", cluster, nil); status != http.StatusCreated {
+		t.Fatalf("create cluster: status %d", status)
+	}
+	if status := doJSON(t, http.MethodPost, base+"/projects", map[string]string{"name": "team-a"}, nil); status != http.StatusCreated {
+		t.Fatalf("create project: status %d", status)
+	}
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("fixtures")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("fixtures"), Key: aws.String("a.txt"), Body: bytes.NewReader([]byte("kept"))}); err != nil {
+		t.Fatal(err)
+	}
+	if status := doJSON(t, http.MethodPost, admin+"/clock/advance", map[string]interface{}{"days": 2}, nil); status != http.StatusOK {
+		t.Fatalf("advance clock: status %d", status)
+	}
+
+	var summary map[string]interface{}
+	if status := doJSON(t, http.MethodPost, admin+"/fixtures/base", nil, &summary); status != http.StatusCreated {
+		t.Fatalf("save fixture: status %d, %v", status, summary)
+	}
+	if summary["name"] != "base" || summary["clusters"] != 1.0 || summary["projects"] != 1.0 || summary["buckets"] != 1.0 || summary["objects"] != 1.0 {
+		t.Errorf("saved fixture = %v", summary)
+	}
+	var archive SnapshotArchive
+	if status := doJSON(t, http.MethodGet, admin+"/snapshot", nil, &archive); status != http.StatusOK || archive.Version != SnapshotVersion {
+		t.Fatalf("snapshot: status %d, version %d", status, archive.Version)
+	}
+
+	// Changes after the snapshot are undone by restoring it
+	if status := doJSON(t, http.MethodDelete, base+"/clusters/hz-1", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete cluster: status %d", status)
+	}
+	if status := doJSON(t, http.MethodDelete, base+"/projects/team-a", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete project: status %d", status)
+	}
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("fixtures"), Key: aws.String("b.txt"), Body: bytes.NewReader([]byte("later"))}); err != nil {
+		t.Fatal(err)
+	}
+	sim.Clock().Reset()
+	if status := doJSON(t, http.MethodPost, admin+"/restore", archive, &summary); status != http.StatusOK {
+		t.Fatalf("restore: status %d, %v", status, summary)
+	}
+	if status := doJSON(t, http.MethodGet, base+"/clusters/hz-1", nil, nil); status != http.StatusOK {
+		t.Errorf("cluster after restore: status %d", status)
+	}
+	if status := doJSON(t, http.MethodGet, base+"/projects/team-a", nil, nil); status != http.StatusOK {
+		t.Errorf("project after restore: status %d", status)
+	}
+	if _, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("fixtures"), Key: aws.String("b.txt")}); err == nil {
+		t.Error("object written after the snapshot still exists")
+	}
+	obj, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("fixtures"), Key: aws.String("a.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if string(data) != "kept" {
+		t.Errorf("a.txt after restore = %q", data)
+	}
+	if offset := sim.Clock().Offset(); offset < 47*time.Hour || offset > 49*time.Hour {
+		t.Errorf("clock offset after restore = %v, want about 48h", offset)
+	}
+
+	// The fixture resets the server between cases as well
+	cluster["id"], cluster["name"] = "hz-2", "hz-2"
+	if status := doJSON(t, http.MethodPost, base+"/clusters", cluster, nil); status != http.StatusCreated {
+		t.Fatalf("create cluster hz-2: status %d", status)
+	}
+	if status := doJSON(t, http.MethodPost, admin+"/fixtures/base/restore", nil, &summary); status != http.StatusOK || summary["name"] != "base" {
+		t.Fatalf("restore fixture: status %d, %v", status, summary)
+	}
+	var list struct {
+		Clusters []map[string]interface{} `json:"clusters"`
+	}
+	if status := doJSON(t, http.MethodGet, base+"/clusters", nil, &list); status != http.StatusOK || len(list.Clusters) != 1 || list.Clusters[0]["id"] != "hz-1" {
+		t.Errorf("clusters after restoring the fixture: status %d, %v", status, list.Clusters)
+	}
+	var fixtures struct {
+		Fixtures []FixtureInfo `json:"fixtures"`
+	}
+	if status := doJSON(t, http.MethodGet, admin+"/fixtures", nil, &fixtures); status != http.StatusOK || len(fixtures.Fixtures) != 1 || fixtures.Fixtures[0].Name != "base" {
+		t.Errorf("fixtures: status %d, %+v", status, fixtures.Fixtures)
+	}
+
+	// Refused archives change nothing
+	if status := doJSON(t, http.MethodPost, admin+"/restore", map[string]interface{}{"version": 99}, &summary); status != http.StatusBadRequest {
+		t.Errorf("restore of version 99: status %d, %v", status, summary)
+	}
+	invalid := map[string]interface{}{"version": 1, "store": map[string]interface{}{
+		"resources": map[string]interface{}{"default": map[string]interface{}{"node_pools": []map[string]string{{"id": "p-1", "cluster_id": "missing"}}}},
+	}}
+	if status := doJSON(t, http.MethodPost, admin+"/restore", invalid, &summary); status != http.StatusBadRequest {
+		t.Errorf("restore of a node pool without cluster: status %d, %v", status, summary)
+	}
+	if status := doJSON(t, http.MethodGet, base+"/clusters/hz-1", nil, nil); status != http.StatusOK {
+		t.Errorf("cluster after refused restores: status %d", status)
+	}
+
+	if status := doJSON(t, http.MethodPost, admin+"/fixtures/..%2Fescape", nil, nil); status != http.StatusBadRequest && status != http.StatusNotFound {
+		t.Errorf("fixture name with a path: status %d", status)
+	}
+	if status := doJSON(t, http.MethodDelete, admin+"/fixtures/base", nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete fixture: status %d", status)
+	}
+	if status := doJSON(t, http.MethodPost, admin+"/fixtures/base/restore", nil, nil); status != http.StatusNotFound {
+		t.Errorf("restore of a deleted fixture: status %d", status)
+	}
+}
+
+func TestFixtureDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "fixtures")
+	fixtures := newFixtureStore(dir)
+	if list, err := fixtures.list(); err != nil || len(list) != 0 {
+		t.Fatalf("list of a missing directory = %v, %v", list, err)
+	}
+	if err := fixtures.put("empty-cluster", []byte(`{"version":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "empty-cluster.json")); err != nil || string(data) != `{"version":1}` {
+		t.Errorf("fixture file = %q, %v", data, err)
+	}
+	// Fixtures shipped with a test suite are picked up from the directory
+	if err := os.WriteFile(filepath.Join(dir, "shipped.json"), []byte(`{"version":1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := fixtures.list(); err != nil || len(list) != 2 || list[0].Name != "empty-cluster" || list[1].Name != "shipped" {
+		t.Errorf("list = %+v, %v", list, err)
+	}
+	if err := fixtures.delete("shipped"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fixtures.get("shipped"); err != errFixtureNotFound {
+		t.Errorf("get of a deleted fixture: %v", err)
+	}
+}
+
+func TestRestoreRequeuesPendingTests(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1"
+	cluster := map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}
+	if status := doJSON(t, http.MethodPost, base+"/clusters", cluster, nil); status != http.StatusCreated {
+		t.Fatalf("create cluster: status %d", status)
+	}
+	var long sharedmodels.TestResult
+	if status := doJSON(t, http.MethodPost, base+"/clusters/hz-1/tests", map[string]interface{}{"cluster_id": "hz-1", "test_type": "performance", "config": map[string]interface{}{"duration": "1m"}}, &long); status != http.StatusAccepted {
+		t.Fatalf("run test: status %d", status)
+	}
+
+	// The archive holds a test that has yet to run
+	source := store.NewMemoryStore()
+	source.CreateCluster(&sharedmodels.Cluster{ID: "hz-1", Name: "hz-1", Provider: sharedmodels.Hetzner})
+	pending, _ := source.CreateTestResult(&sharedmodels.TestResult{ClusterID: "hz-1", TestType: "performance", Status: sharedmodels.TestStatusPending,
+		Details: map[string]interface{}{"duration": "20ms"}})
+	storeSnapshot, err := source.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := SnapshotArchive{Version: SnapshotVersion, Store: storeSnapshot}
+	if status := doJSON(t, http.MethodPost, base+"/simulate/admin/restore", archive, nil); status != http.StatusOK {
+		t.Fatalf("restore: status %d", status)
+	}
+
+	var result sharedmodels.TestResult
+	for deadline := time.Now().Add(5 * time.Second); result.Status != sharedmodels.TestStatusPassed; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("restored pending test did not pass, last seen %+v", result)
+		}
+		doJSON(t, http.MethodGet, base+"/tests/"+pending.ID, nil, &result)
+	}
+	if long.ID != pending.ID {
+		if status := doJSON(t, http.MethodGet, base+"/tests/"+long.ID, nil, nil); status != http.StatusNotFound {
+			t.Errorf("test running before the restore: status %d, want 404", status)
+		}
+	}
+}
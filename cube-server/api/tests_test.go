@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+// newTestRouter returns a router with the API routes of opts, whose background work
+// stops when the test ends
+func newTestRouter(t *testing.T, opts RouteOptions) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	routes := SetupRoutesWithOptions(r, opts)
+	t.Cleanup(func() { routes.Close() })
+	return r
+}
+
+// newClusterTestServer serves the API on a fresh memory store.
+func newClusterTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	r := newTestRouter(t, RouteOptions{Store: store.NewMemoryStore(), Logger: zap.NewNop(), Sim: NewTestSimulationService()})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// doJSON sends body as JSON and decodes the response into out (may be nil).
+func doJSON(t *testing.T, method, url string, body, out interface{}) int {
+	t.Helper()
+	var raw []byte
+	if body != nil {
+		raw, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, url, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		_ = json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+func TestClusterTestExecution(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1"
+	cluster := map[string]interface{}{"id": "hz-1", "name": "hz-1", "provider": "hetzner", "location": "fsn1"}
+	if status := doJSON(t, http.MethodPost, base+"/clusters", cluster, nil); status != http.StatusCreated {
+		t.Fatalf("create cluster: status %d", status)
+	}
+	runTest := func(testType string, config map[string]interface{}) (int, sharedmodels.TestResult) {
+		var result sharedmodels.TestResult
+		status := doJSON(t, http.MethodPost, base+"/clusters/hz-1/tests", map[string]interface{}{"cluster_id": "hz-1", "test_type": testType, "config": config}, &result)
+		return status, result
+	}
+
+	status, queued := runTest("performance", map[string]interface{}{"duration": "40ms"})
+	if status != http.StatusAccepted || queued.Status != sharedmodels.TestStatusPending {
+		t.Fatalf("run test: status %d, result %+v", status, queued)
+	}
+	var result sharedmodels.TestResult
+	for deadline := time.Now().Add(5 * time.Second); result.Status != sharedmodels.TestStatusPassed; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("test did not pass, last seen %+v", result)
+		}
+		doJSON(t, http.MethodGet, base+"/tests/"+queued.ID, nil, &result)
+	}
+	if result.CompletedAt == nil || result.Duration <= 0 || result.Details["requests_per_second"] == nil {
+		t.Errorf("finished test = %+v", result)
+	}
+	if status := doJSON(t, http.MethodDelete, base+"/tests/"+queued.ID, nil, nil); status != http.StatusConflict {
+		t.Errorf("cancel finished test: status %d, want 409", status)
+	}
+
+	_, long := runTest("load", map[string]interface{}{"duration": "1h"})
+	var cancelled sharedmodels.TestResult
+	if status := doJSON(t, http.MethodDelete, base+"/tests/"+long.ID, nil, &cancelled); status != http.StatusOK || cancelled.Status != sharedmodels.TestStatusCancelled {
+		t.Errorf("cancel test: status %d, result %+v", status, cancelled)
+	}
+
+	if status, _ := runTest("chaos", nil); status != http.StatusBadRequest {
+		t.Errorf("unknown test type: status %d, want 400", status)
+	}
+	if status, _ := runTest("load", map[string]interface{}{"timeout": "soon"}); status != http.StatusBadRequest {
+		t.Errorf("invalid timeout: status %d, want 400", status)
+	}
+	if status := doJSON(t, http.MethodPost, base+"/clusters/missing/tests", map[string]interface{}{"cluster_id": "missing", "test_type": "load"}, nil); status != http.StatusNotFound {
+		t.Errorf("run test on a missing cluster: status %d, want 404", status)
+	}
+	if status := doJSON(t, http.MethodDelete, base+"/tests/missing", nil, nil); status != http.StatusNotFound {
+		t.Errorf("cancel missing test: status %d, want 404", status)
+	}
+}
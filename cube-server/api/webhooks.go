@@ -0,0 +1,205 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/webhooks"
+	"go.uber.org/zap"
+)
+
+// WebhookHandlers serves /api/v1/webhooks: subscriptions to the events of the
+// request's project, their deliveries and the dead letters (see webhooks/)
+type WebhookHandlers struct {
+	dispatcher *webhooks.Dispatcher
+	logger     *zap.Logger
+	// locks serializes the writes of each subscription (see preconditions.go)
+	locks resourceLocks
+}
+
+// NewWebhookHandlers creates the /api/v1/webhooks handlers
+func NewWebhookHandlers(dispatcher *webhooks.Dispatcher, logger *zap.Logger) *WebhookHandlers {
+	return &WebhookHandlers{dispatcher: dispatcher, logger: logger}
+}
+
+// WebhookRequest is the body of POST /webhooks and PUT /webhooks/:id. Without a
+// secret, POST generates one and PUT keeps the current one.
+type WebhookRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required"`
+	Description string   `json:"description,omitempty"`
+	Secret      string   `json:"secret,omitempty"`
+	Disabled    bool     `json:"disabled,omitempty"`
+}
+
+func (r WebhookRequest) subscription(project string) webhooks.Subscription {
+	return webhooks.Subscription{
+		Project:     project,
+		URL:         r.URL,
+		Events:      r.Events,
+		Description: r.Description,
+		Secret:      r.Secret,
+		Disabled:    r.Disabled,
+	}
+}
+
+// CreateWebhook handles POST /webhooks; the answer is the only one with the secret
+func (h *WebhookHandlers) CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub, err := h.dispatcher.Subscribe(req.subscription(projectName(c)))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	h.logger.Info("Webhook created", zap.String("id", sub.ID), zap.String("url", sub.URL), zap.Strings("events", sub.Events))
+	setETag(c, sub.ResourceVersion)
+	c.JSON(http.StatusCreated, sub)
+}
+
+// ListWebhooks handles GET /webhooks; see listing.go for the query parameters
+func (h *WebhookHandlers) ListWebhooks(c *gin.Context) {
+	writeListPage(c, "webhooks", h.dispatcher.Subscriptions(projectName(c)), webhookListFields)
+}
+
+var webhookListFields = listFields[webhooks.Subscription]{
+	id:          func(s webhooks.Subscription) string { return s.ID },
+	createdAt:   func(s webhooks.Subscription) time.Time { return s.CreatedAt },
+	defaultSort: "created_at",
+	sort: map[string]func(webhooks.Subscription) string{
+		"url": func(s webhooks.Subscription) string { return s.URL },
+	},
+}
+
+// GetWebhook handles GET /webhooks/:id
+func (h *WebhookHandlers) GetWebhook(c *gin.Context) {
+	sub, err := h.dispatcher.Subscription(projectName(c), c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	setETag(c, sub.ResourceVersion)
+	c.JSON(http.StatusOK, sub)
+}
+
+// UpdateWebhook handles PUT /webhooks/:id
+func (h *WebhookHandlers) UpdateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	project, id := projectName(c), c.Param("id")
+	defer h.locks.lock(resourceKey(c, "webhooks/"+id))()
+	current, err := h.dispatcher.Subscription(project, id)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	if !precondition(c, current.ResourceVersion) {
+		return
+	}
+	sub, err := h.dispatcher.Update(project, id, req.subscription(project))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	h.logger.Info("Webhook updated", zap.String("id", sub.ID), zap.Bool("disabled", sub.Disabled))
+	setETag(c, sub.ResourceVersion)
+	c.JSON(http.StatusOK, sub)
+}
+
+// DeleteWebhook handles DELETE /webhooks/:id; deliveries waiting for a retry are
+// cancelled
+func (h *WebhookHandlers) DeleteWebhook(c *gin.Context) {
+	project, id := projectName(c), c.Param("id")
+	defer h.locks.lock(resourceKey(c, "webhooks/"+id))()
+	current, err := h.dispatcher.Subscription(project, id)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	if !precondition(c, current.ResourceVersion) {
+		return
+	}
+	if err := h.dispatcher.Unsubscribe(project, id); err != nil {
+		h.fail(c, err)
+		return
+	}
+	h.logger.Info("Webhook deleted", zap.String("id", id))
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// PingWebhook handles POST /webhooks/:id/ping: a ping event is sent to the
+// subscription, and the answer is the delivery, before its first attempt completes
+func (h *WebhookHandlers) PingWebhook(c *gin.Context) {
+	delivery, err := h.dispatcher.Ping(projectName(c), c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// ListWebhookDeliveries handles GET /webhooks/:id/deliveries: the kept deliveries of a
+// subscription, newest first unless sorted otherwise; see listing.go for the query
+// parameters
+func (h *WebhookHandlers) ListWebhookDeliveries(c *gin.Context) {
+	project, id := projectName(c), c.Param("id")
+	if _, err := h.dispatcher.Subscription(project, id); err != nil {
+		h.fail(c, err)
+		return
+	}
+	writeListPage(c, "deliveries", h.dispatcher.Deliveries(project, id), deliveryListFields)
+}
+
+// ListDeadLetters handles GET /webhooks/dead-letters: the deliveries of the project
+// that ran out of attempts
+func (h *WebhookHandlers) ListDeadLetters(c *gin.Context) {
+	writeListPage(c, "deliveries", h.dispatcher.DeadLetters(projectName(c)), deliveryListFields)
+}
+
+var deliveryListFields = listFields[webhooks.Delivery]{
+	id:           func(d webhooks.Delivery) string { return d.ID },
+	status:       func(d webhooks.Delivery) string { return d.Status },
+	createdAt:    func(d webhooks.Delivery) time.Time { return d.CreatedAt },
+	defaultOrder: "desc",
+	sort: map[string]func(webhooks.Delivery) string{
+		"event_type": func(d webhooks.Delivery) string { return d.EventType },
+	},
+}
+
+// RedeliverDeadLetter handles POST /webhooks/dead-letters/:id/redeliver: the dead
+// letter is sent again as a new delivery with fresh attempts
+func (h *WebhookHandlers) RedeliverDeadLetter(c *gin.Context) {
+	delivery, err := h.dispatcher.Redeliver(projectName(c), c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	h.logger.Info("Webhook delivery redelivered", zap.String("id", delivery.ID), zap.String("redelivery_of", delivery.RedeliveryOf))
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// fail answers the errors of the dispatcher
+func (h *WebhookHandlers) fail(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, webhooks.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, webhooks.ErrNotFound):
+		what := "webhook"
+		if strings.HasPrefix(c.FullPath(), "/api/v1/webhooks/dead-letters") {
+			what = "dead letter"
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": what + " not found"})
+	default:
+		h.logger.Error("Webhook request failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
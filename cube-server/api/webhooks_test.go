@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/webhooks"
+)
+
+// webhookReceiver collects the verified payloads POSTed to it
+type webhookReceiver struct {
+	*httptest.Server
+	mu     sync.Mutex
+	secret string
+	got    chan webhooks.Payload
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	r := &webhookReceiver{got: make(chan webhooks.Payload, 64)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		secret := r.secret
+		r.mu.Unlock()
+		if err := webhooks.Verify(secret, req.Header, body, 0); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+		var payload webhooks.Payload
+		_ = json.Unmarshal(body, &payload)
+		r.got <- payload
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// waitType returns the first payload of eventType, skipping others
+func (r *webhookReceiver) waitType(t *testing.T, eventType string) webhooks.Payload {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case payload := <-r.got:
+			if payload.Type == eventType {
+				return payload
+			}
+		case <-timeout:
+			t.Fatalf("no %s delivery received", eventType)
+		}
+	}
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	srv := newClusterTestServer(t)
+	base := srv.URL + "/api/v1/webhooks"
+
+	if status := doJSON(t, http.MethodPost, base, map[string]interface{}{"url": "https://example.com/hook", "events": []string{"cluster.exploded"}}, nil); status != http.StatusBadRequest {
+		t.Errorf("unknown event type: status %d, want 400", status)
+	}
+	if status := doJSON(t, http.MethodPost, base, map[string]interface{}{"url": "not a url", "events": []string{"*"}}, nil); status != http.StatusBadRequest {
+		t.Errorf("invalid url: status %d, want 400", status)
+	}
+
+	var created webhooks.Subscription
+	if status := doJSON(t, http.MethodPost, base, map[string]interface{}{"url": "https://example.com/hook", "events": []string{"cluster.*"}, "description": "ci"}, &created); status != http.StatusCreated {
+		t.Fatalf("create webhook: status %d", status)
+	}
+	if created.ID == "" || created.Secret == "" || created.Project != "default" {
+		t.Fatalf("created webhook = %+v", created)
+	}
+	status, header, body := doHeaderJSON(t, http.MethodGet, base+"/"+created.ID, nil, nil)
+	var got webhooks.Subscription
+	_ = json.Unmarshal(body, &got)
+	if status != http.StatusOK || got.Secret != "" || header.Get("ETag") != `"1"` {
+		t.Errorf("get webhook: status %d, ETag %s, %+v", status, header.Get("ETag"), got)
+	}
+
+	update := map[string]interface{}{"url": "https://example.com/v2", "events": []string{"test.completed"}, "disabled": true}
+	if status, _, _ := doHeaderJSON(t, http.MethodPut, base+"/"+created.ID, map[string]string{"If-Match": `"7"`}, update); status != http.StatusPreconditionFailed {
+		t.Errorf("update with a stale If-Match: status %d, want 412", status)
+	}
+	var updated webhooks.Subscription
+	if status := doJSON(t, http.MethodPut, base+"/"+created.ID, update, &updated); status != http.StatusOK || !updated.Disabled || updated.ResourceVersion != 2 || updated.Secret != "" {
+		t.Errorf("update webhook: status %d, %+v", status, updated)
+	}
+
+	var list struct {
+		Webhooks []webhooks.Subscription `json:"webhooks"`
+	}
+	if status := doJSON(t, http.MethodGet, base, nil, &list); status != http.StatusOK || len(list.Webhooks) != 1 || list.Webhooks[0].Secret != "" {
+		t.Errorf("list webhooks: status %d, %+v", status, list)
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/projects/other/webhooks/"+created.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("webhook of another project: status %d, want 404", status)
+	}
+	if status := doJSON(t, http.MethodDelete, base+"/"+created.ID, nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete webhook: status %d", status)
+	}
+	if status := doJSON(t, http.MethodGet, base+"/"+created.ID+"/deliveries", nil, nil); status != http.StatusNotFound {
+		t.Errorf("deliveries of a deleted webhook: status %d, want 404", status)
+	}
+	if status := doJSON(t, http.MethodPost, base+"/dead-letters/missing/redeliver", nil, nil); status != http.StatusNotFound {
+		t.Errorf("redeliver a missing dead letter: status %d, want 404", status)
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	t.Setenv("CUBE_SERVER_SIM_PERSIST", filepath.Join(t.TempDir(), "buckets.json"))
+	srv := newClusterTestServer(t)
+	v1 := srv.URL + "/api/v1"
+	receiver := newWebhookReceiver(t)
+
+	var sub webhooks.Subscription
+	if status := doJSON(t, http.MethodPost, v1+"/webhooks", map[string]interface{}{"url": receiver.URL, "events": []string{"cluster.created", "cluster.running", "test.completed", "bucket.*"}}, &sub); status != http.StatusCreated {
+		t.Fatalf("create webhook: status %d", status)
+	}
+	receiver.mu.Lock()
+	receiver.secret = sub.Secret
+	receiver.mu.Unlock()
+
+	var delivery webhooks.Delivery
+	if status := doJSON(t, http.MethodPost, v1+"/webhooks/"+sub.ID+"/ping", nil, &delivery); status != http.StatusAccepted || delivery.EventType != webhooks.EventPing {
+		t.Fatalf("ping: status %d, %+v", status, delivery)
+	}
+	receiver.waitType(t, webhooks.EventPing)
+
+	if status := doJSON(t, http.MethodPost, v1+"/clusters", map[string]interface{}{"id": "hz-hook", "name": "hz-hook", "provider": "hetzner", "location": "fsn1"}, nil); status != http.StatusCreated {
+		t.Fatalf("create cluster: status %d", status)
+	}
+	if payload := receiver.waitType(t, webhooks.EventClusterCreated); payload.ResourceID != "hz-hook" || payload.Data["provider"] != "hetzner" {
+		t.Errorf("cluster.created payload = %+v", payload)
+	}
+
+	var queued struct {
+		ID string `json:"id"`
+	}
+	doJSON(t, http.MethodPost, v1+"/clusters/hz-hook/tests", map[string]interface{}{"cluster_id": "hz-hook", "test_type": "load", "config": map[string]interface{}{"duration": "50ms"}}, &queued)
+	if payload := receiver.waitType(t, webhooks.EventTestCompleted); payload.ResourceID != queued.ID || payload.Data["status"] != "passed" {
+		t.Errorf("test.completed payload = %+v", payload)
+	}
+
+	buckets := v1 + "/simulate/providers/aws/buckets"
+	if status := doJSON(t, http.MethodPost, buckets, map[string]interface{}{"name": "hook-bucket", "region": "eu-west-1"}, nil); status != http.StatusCreated {
+		t.Fatalf("create bucket: status %d", status)
+	}
+	if payload := receiver.waitType(t, webhooks.EventBucketCreated); payload.ResourceID != "hook-bucket" || payload.Data["region"] != "eu-west-1" {
+		t.Errorf("bucket.created payload = %+v", payload)
+	}
+	if status := doJSON(t, http.MethodDelete, buckets+"/hook-bucket", nil, nil); status != http.StatusOK && status != http.StatusNoContent {
+		t.Fatalf("delete bucket: status %d", status)
+	}
+	if payload := receiver.waitType(t, webhooks.EventBucketDeleted); payload.ResourceID != "hook-bucket" || payload.Data["provider"] != "aws" {
+		t.Errorf("bucket.deleted payload = %+v", payload)
+	}
+
+	// Simulated clusters become running on their own, without anyone reading them
+	_, created := simulateWithSeed(t, srv.URL, "", "create_cluster", map[string]interface{}{"name": "eks-hook", "node_count": 1})
+	clusterID, _ := created.Result["cluster_id"].(string)
+	if payload := receiver.waitType(t, webhooks.EventClusterCreated); payload.ResourceID != clusterID || payload.Resource != "simulated_clusters" {
+		t.Errorf("simulated cluster.created payload = %+v", payload)
+	}
+	if payload := receiver.waitType(t, "cluster.running"); payload.ResourceID != clusterID || payload.Data["name"] != "eks-hook" {
+		t.Errorf("cluster.running payload = %+v", payload)
+	}
+
+	var deliveries struct {
+		Deliveries []webhooks.Delivery `json:"deliveries"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		doJSON(t, http.MethodGet, v1+"/webhooks/"+sub.ID+"/deliveries?status=succeeded", nil, &deliveries)
+		if len(deliveries.Deliveries) >= 7 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(deliveries.Deliveries) != 7 {
+		t.Errorf("succeeded deliveries = %+v, want 7", deliveries.Deliveries)
+	}
+}
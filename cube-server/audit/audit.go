@@ -0,0 +1,69 @@
+// Package audit keeps an append-only record of the mutating cube-server API calls:
+// who changed which resource, with what payload, what it looked like before and
+// after, and how the call ended. Entries go to a rotating JSONL file, or a bounded
+// in-memory log without one, and are queried through GET /api/v1/audit.
+package audit
+
+import (
+	"strings"
+	"time"
+)
+
+// Results of an audited call
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	// ResultDenied is a call refused for missing or insufficient credentials
+	ResultDenied = "denied"
+)
+
+// Entry is one audited call. Request, Before and After hold decoded JSON with the
+// secrets redacted (see Redact).
+type Entry struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// CorrelationID is the X-Request-ID of the call, sent by the client or generated
+	CorrelationID string `json:"correlation_id"`
+	Actor         string `json:"actor"`
+	Role          string `json:"role,omitempty"`
+	Project       string `json:"project"`
+	Method        string `json:"method"`
+	// Route is the route pattern (/api/v1/clusters/:id), Path the path the client sent
+	Route string `json:"route"`
+	Path  string `json:"path"`
+	// Resource is the route without /api/v1 and its parameters (clusters/nodepools)
+	Resource   string      `json:"resource"`
+	ResourceID string      `json:"resource_id,omitempty"`
+	Status     int         `json:"status"`
+	Result     string      `json:"result"`
+	Error      string      `json:"error,omitempty"`
+	Request    interface{} `json:"request,omitempty"`
+	Before     interface{} `json:"before,omitempty"`
+	After      interface{} `json:"after,omitempty"`
+	Changes    []Change    `json:"changes,omitempty"`
+}
+
+// Filter selects entries; zero fields match everything. Resource also matches the
+// resources below it: clusters selects clusters/nodepools and clusters/tests.
+type Filter struct {
+	Since      time.Time
+	Until      time.Time
+	Project    string
+	Actor      string
+	Resource   string
+	ResourceID string
+	Result     string
+	// Limit caps the number of entries returned, newest first (0: no limit)
+	Limit int
+}
+
+// Match reports whether the filter selects entry.
+func (f Filter) Match(entry Entry) bool {
+	return (f.Since.IsZero() || !entry.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || entry.Time.Before(f.Until)) &&
+		(f.Project == "" || f.Project == entry.Project) &&
+		(f.Actor == "" || f.Actor == entry.Actor) &&
+		(f.Resource == "" || f.Resource == entry.Resource || strings.HasPrefix(entry.Resource, f.Resource+"/")) &&
+		(f.ResourceID == "" || f.ResourceID == entry.ResourceID) &&
+		(f.Result == "" || f.Result == entry.Result)
+}
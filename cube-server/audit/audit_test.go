@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRotatesAndQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(Config{Path: path, MaxSize: 400, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		actor := "alice"
+		if i%2 == 1 {
+			actor = "bob"
+		}
+		entry := Entry{Time: start.Add(time.Duration(i) * time.Hour), Actor: actor, Project: "default", Resource: "clusters", ResourceID: string(rune('a' + i))}
+		if err := l.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Fatalf("no second rotated file: %v", err)
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Fatal("more rotated files than MaxFiles")
+	}
+
+	all, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 || len(all) >= 12 || all[0].ResourceID != "l" || all[0].ID == "" {
+		t.Fatalf("Query = %d entries starting with %+v; want the newest, oldest rotated away", len(all), all[0])
+	}
+	for i := 1; i < len(all); i++ {
+		if !all[i].Time.Before(all[i-1].Time) {
+			t.Fatalf("entries not newest first: %v after %v", all[i].Time, all[i-1].Time)
+		}
+	}
+
+	bob, _ := l.Query(Filter{Actor: "bob", Since: start.Add(9 * time.Hour), Limit: 1})
+	if len(bob) != 1 || bob[0].ResourceID != "l" {
+		t.Errorf("bob since 9h, limit 1 = %+v", bob)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening appends to the same file and still finds the older entries
+	l, err = Open(Config{Path: path, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := l.Append(Entry{Actor: "carol", Resource: "clusters/nodepools"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := l.Query(Filter{Resource: "clusters"}); len(got) != len(all)+1 || got[0].Actor != "carol" {
+		t.Errorf("after reopening: %d entries, newest %+v", len(got), got[0])
+	}
+}
+
+func TestMemoryLogKeepsLastEntries(t *testing.T) {
+	l := NewMemoryLog(3)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		l.Append(Entry{ResourceID: id})
+	}
+	got, _ := l.Query(Filter{})
+	if len(got) != 3 || got[0].ResourceID != "d" || got[2].ResourceID != "b" {
+		t.Errorf("Query = %+v", got)
+	}
+	var nilLog *Log
+	if err := nilLog.Append(Entry{}); err != nil {
+		t.Errorf("nil log Append: %v", err)
+	}
+}
+
+func TestFilterResource(t *testing.T) {
+	for resource, want := range map[string]bool{"clusters": true, "clusters/nodepools": true, "clusters/node": false, "cluster": false} {
+		if got := (Filter{Resource: resource}).Match(Entry{Resource: "clusters/nodepools"}); got != want {
+			t.Errorf("resource %q matches clusters/nodepools: %v, want %v", resource, got, want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	in := map[string]interface{}{
+		"name":        "ci",
+		"token":       "cube_abc",
+		"token_id":    "t-1",
+		"credentials": map[string]interface{}{"subscription_id": "s"},
+		"providers": []interface{}{
+			map[string]interface{}{"access_key": "AKIA", "region": "eu", "Client-Secret": "x"},
+		},
+		"password": nil,
+	}
+	want := map[string]interface{}{
+		"name":        "ci",
+		"token":       Redacted,
+		"token_id":    "t-1",
+		"credentials": Redacted,
+		"providers": []interface{}{
+			map[string]interface{}{"access_key": Redacted, "region": "eu", "Client-Secret": Redacted},
+		},
+		"password": nil,
+	}
+	if got := Redact(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("Redact = %v", got)
+	}
+	if in["token"] != "cube_abc" {
+		t.Error("Redact changed its input")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := map[string]interface{}{"name": "c1", "status": "running", "config": map[string]interface{}{"nodes": 3.0, "zone": "a"}, "tags": []interface{}{"x"}}
+	after := map[string]interface{}{"name": "c1", "status": "stopped", "config": map[string]interface{}{"nodes": 5.0}, "tags": []interface{}{"x", "y"}, "region": "eu"}
+	got := Diff(before, after)
+	var paths []string
+	for _, change := range got {
+		paths = append(paths, change.Path)
+	}
+	if strings.Join(paths, ",") != "config.nodes,config.zone,region,status,tags" {
+		t.Fatalf("Diff paths = %v", paths)
+	}
+	if got[0].Before != 3.0 || got[0].After != 5.0 || got[1].After != nil || got[2].Before != nil {
+		t.Errorf("Diff = %+v", got)
+	}
+
+	created := Diff(nil, map[string]interface{}{"id": "c1", "replicas": 0.0})
+	if len(created) != 2 || created[1].Path != "replicas" || created[1].After != 0.0 {
+		t.Errorf("Diff of a created resource = %+v", created)
+	}
+	if changes := Diff(before, before); len(changes) != 0 {
+		t.Errorf("Diff of equal values = %+v", changes)
+	}
+}
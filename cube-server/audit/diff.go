@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Change is one field that differs between the before and after image of a resource.
+// Path names nested fields with dots (config.node_count); a missing Before is an
+// added field, a missing After a removed one.
+type Change struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Diff compares two decoded JSON values field by field. Objects are compared per
+// field at any depth, everything else (arrays included) as a whole; nil compares as
+// an empty object, so a created resource lists all its fields as added.
+func Diff(before, after interface{}) []Change {
+	var changes []Change
+	diff("", before, after, &changes)
+	return changes
+}
+
+func diff(path string, before, after interface{}, changes *[]Change) {
+	beforeObj, beforeIsObj := before.(map[string]interface{})
+	afterObj, afterIsObj := after.(map[string]interface{})
+	if (beforeIsObj || before == nil) && (afterIsObj || after == nil) && (beforeIsObj || afterIsObj) {
+		keys := make(map[string]bool, len(beforeObj)+len(afterObj))
+		for key := range beforeObj {
+			keys[key] = true
+		}
+		for key := range afterObj {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			diff(child, beforeObj[key], afterObj[key], changes)
+		}
+		return
+	}
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, Change{Path: path, Before: before, After: after})
+	}
+}
@@ -0,0 +1,227 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Log defaults
+const (
+	DefaultMaxSize       = 10 << 20
+	DefaultMaxFiles      = 5
+	DefaultMemoryEntries = 10000
+)
+
+// Config selects where the log is kept
+type Config struct {
+	// Path is the JSONL file; empty keeps the last DefaultMemoryEntries entries in memory
+	Path string
+	// MaxSize rotates the file once it would grow beyond this many bytes (DefaultMaxSize if <= 0)
+	MaxSize int64
+	// MaxFiles is the number of rotated files kept next to Path as Path.1 (newest)
+	// to Path.N (DefaultMaxFiles if <= 0)
+	MaxFiles int
+}
+
+// Log appends entries to a JSONL file or memory. A nil *Log is valid and discards
+// everything, so callers need no checks.
+type Log struct {
+	mu  sync.Mutex
+	cfg Config
+
+	file *os.File
+	size int64
+
+	memory []Entry
+	limit  int
+}
+
+// NewMemoryLog returns a log that keeps the last limit entries (DefaultMemoryEntries
+// if limit <= 0).
+func NewMemoryLog(limit int) *Log {
+	if limit <= 0 {
+		limit = DefaultMemoryEntries
+	}
+	return &Log{limit: limit}
+}
+
+// Open returns the log for cfg, creating its file if needed.
+func Open(cfg Config) (*Log, error) {
+	if cfg.Path == "" {
+		return NewMemoryLog(0), nil
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = DefaultMaxFiles
+	}
+	l := &Log{cfg: cfg}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	// Entries hold request payloads, so only the server user may read them
+	file, err := os.OpenFile(l.cfg.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// Append adds entry, giving it an ID and time if it has none.
+func (l *Log) Append(entry Entry) error {
+	if l == nil {
+		return nil
+	}
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cfg.Path == "" {
+		if len(l.memory) >= l.limit {
+			l.memory = append(l.memory[:0], l.memory[len(l.memory)-l.limit+1:]...)
+		}
+		l.memory = append(l.memory, entry)
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if l.file == nil {
+		// Closed, or a failed rotation left no file open
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	if l.size > 0 && l.size+int64(len(line)) > l.cfg.MaxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// rotate moves Path to Path.1, Path.1 to Path.2 and so on, dropping the oldest file
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+	if err := os.Remove(l.rotated(l.cfg.MaxFiles)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := l.cfg.MaxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(l.rotated(i), l.rotated(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(l.cfg.Path, l.rotated(1)); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+func (l *Log) rotated(i int) string {
+	return fmt.Sprintf("%s.%d", l.cfg.Path, i)
+}
+
+// Query returns the entries matching filter, newest first.
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	matches := []Entry{}
+	if l == nil {
+		return matches, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	collect := func(entry Entry) {
+		if filter.Match(entry) {
+			matches = append(matches, entry)
+		}
+	}
+	if l.cfg.Path == "" {
+		for _, entry := range l.memory {
+			collect(entry)
+		}
+	} else {
+		// Oldest file first, so matches end up in the order they were written
+		for i := l.cfg.MaxFiles; i >= 0; i-- {
+			path := l.cfg.Path
+			if i > 0 {
+				path = l.rotated(i)
+			}
+			if err := readEntries(path, collect); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	if filter.Limit > 0 && len(matches) > filter.Limit {
+		matches = matches[:filter.Limit]
+	}
+	return matches, nil
+}
+
+// readEntries decodes a JSONL file; a missing file has no entries and lines that do
+// not decode (a write cut short by a crash) are skipped
+func readEntries(path string, fn func(Entry)) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			fn(entry)
+		}
+	}
+	return scanner.Err()
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,53 @@
+package audit
+
+import "strings"
+
+// Redacted replaces the values of secret fields
+const Redacted = "[REDACTED]"
+
+// secretWords mark a field as secret anywhere in its name (client_secret, db_password,
+// credentials); secretSuffixes only at the end (token and session_token, not token_id)
+var (
+	secretWords    = []string{"secret", "password", "credential", "private_key"}
+	secretSuffixes = []string{"token", "access_key", "api_key", "authorization"}
+)
+
+// IsSecret reports whether a field of that name holds a secret.
+func IsSecret(name string) bool {
+	name = strings.ReplaceAll(strings.ToLower(name), "-", "_")
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of a decoded JSON value with the values of secret fields
+// (see IsSecret), at any depth, replaced by Redacted.
+func Redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			if IsSecret(key) && field != nil {
+				redacted[key] = Redacted
+				continue
+			}
+			redacted[key] = Redact(field)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = Redact(item)
+		}
+		return redacted
+	}
+	return value
+}
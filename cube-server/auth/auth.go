@@ -0,0 +1,300 @@
+// Package auth authenticates cube-server API requests and maps the caller's role to
+// the routes it may use. Callers present a bearer token: a static token from the
+// config, a token issued through /api/v1/auth/tokens or /api/v1/auth/login, or an
+// OIDC JWT verified against a JWKS file.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role grants access to a set of routes; every role includes the ones below it.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// Roles returns the known roles, lowest first.
+func Roles() []Role {
+	return []Role{RoleViewer, RoleOperator, RoleAdmin}
+}
+
+// ParseRole returns the role of a name.
+func ParseRole(name string) (Role, error) {
+	if _, ok := roleRank[Role(name)]; !ok {
+		return "", fmt.Errorf("%w %q (valid: viewer, operator, admin)", ErrUnknownRole, name)
+	}
+	return Role(name), nil
+}
+
+// Allows reports whether r includes required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] > 0 && roleRank[r] >= roleRank[required]
+}
+
+// Ways a principal authenticated
+const (
+	MethodStatic = "static"
+	MethodIssued = "issued"
+	MethodOIDC   = "oidc"
+)
+
+// Default lifetimes of issued tokens
+const (
+	DefaultTokenTTL   = 30 * 24 * time.Hour
+	DefaultSessionTTL = time.Hour
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrInvalidToken    = errors.New("invalid or expired token")
+	ErrNoRole          = errors.New("token grants no cube-server role")
+	ErrUnknownRole     = errors.New("unknown role")
+	ErrNotFound        = errors.New("token not found")
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string `json:"subject"`
+	Role    Role   `json:"role"`
+	Method  string `json:"method"`
+	// Projects limits the principal to these projects; empty allows every project
+	Projects []string `json:"projects,omitempty"`
+	// TokenID is the ID of an issued token; it is empty for static tokens and JWTs
+	TokenID   string     `json:"token_id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// AllowsProject reports whether p may act on project. A nil principal (authentication
+// disabled) allows every project.
+func (p *Principal) AllowsProject(project string) bool {
+	if p == nil || len(p.Projects) == 0 {
+		return true
+	}
+	for _, allowed := range p.Projects {
+		if allowed == project {
+			return true
+		}
+	}
+	return false
+}
+
+// StaticToken is a long-lived token from the config.
+type StaticToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	Role  Role   `yaml:"role"`
+	// Projects limits the token to these projects; empty allows every project
+	Projects []string `yaml:"projects"`
+}
+
+// Config selects the accepted credentials. Without Enabled every request is allowed.
+type Config struct {
+	Enabled bool          `yaml:"enabled"`
+	Tokens  []StaticToken `yaml:"tokens"`
+	OIDC    OIDCConfig    `yaml:"oidc"`
+	// TokenTTL is the default lifetime of tokens issued through /api/v1/auth/tokens
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	// SessionTTL is the lifetime of tokens from /api/v1/auth/login and /refresh
+	SessionTTL time.Duration `yaml:"session_ttl"`
+	// CORSOrigins replaces Access-Control-Allow-Origin: * with these origins
+	CORSOrigins []string `yaml:"cors_origins"`
+}
+
+// IssuedToken is a token created by the server. Token, the secret, is only set in
+// the answer that created it; the server keeps its hash.
+type IssuedToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Subject   string    `json:"subject"`
+	Role      Role      `json:"role"`
+	Projects  []string  `json:"projects,omitempty"`
+	Session   bool      `json:"session"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Token     string    `json:"token,omitempty"`
+}
+
+// Authenticator checks bearer tokens and keeps the issued ones in memory, so they
+// end with the server process.
+type Authenticator struct {
+	static     map[string]StaticToken // by token
+	oidc       *jwtVerifier
+	tokenTTL   time.Duration
+	sessionTTL time.Duration
+	now        func() time.Time
+
+	mu     sync.Mutex
+	issued map[string]*IssuedToken // by token hash
+}
+
+// New returns an authenticator for cfg, loading the JWKS file if OIDC is configured.
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{
+		static:     make(map[string]StaticToken, len(cfg.Tokens)),
+		tokenTTL:   cfg.TokenTTL,
+		sessionTTL: cfg.SessionTTL,
+		now:        time.Now,
+		issued:     make(map[string]*IssuedToken),
+	}
+	if a.tokenTTL <= 0 {
+		a.tokenTTL = DefaultTokenTTL
+	}
+	if a.sessionTTL <= 0 {
+		a.sessionTTL = DefaultSessionTTL
+	}
+	for i, t := range cfg.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("auth: token %d (%s) is empty", i, t.Name)
+		}
+		if _, err := ParseRole(string(t.Role)); err != nil {
+			return nil, fmt.Errorf("auth: token %d (%s): %w", i, t.Name, err)
+		}
+		a.static[t.Token] = t
+	}
+	if cfg.OIDC.JWKSFile != "" {
+		v, err := newJWTVerifier(cfg.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		a.oidc = v
+	}
+	return a, nil
+}
+
+// Authenticate returns the principal of a bearer token.
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	for secret, t := range a.static {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1 {
+			return &Principal{Subject: t.Name, Role: t.Role, Method: MethodStatic, Projects: t.Projects}, nil
+		}
+	}
+	if p, ok := a.lookupIssued(token); ok {
+		return p, nil
+	}
+	if a.oidc != nil && looksLikeJWT(token) {
+		return a.oidc.verify(token, a.now())
+	}
+	return nil, ErrInvalidToken
+}
+
+func (a *Authenticator) lookupIssued(token string) (*Principal, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := hashToken(token)
+	t, ok := a.issued[key]
+	if !ok {
+		return nil, false
+	}
+	if !a.now().Before(t.ExpiresAt) {
+		delete(a.issued, key)
+		return nil, false
+	}
+	expires := t.ExpiresAt
+	return &Principal{Subject: t.Subject, Role: t.Role, Method: MethodIssued, Projects: t.Projects, TokenID: t.ID, ExpiresAt: &expires}, true
+}
+
+// Issue creates a named token for role, limited to projects unless that is empty;
+// ttl <= 0 selects the configured default.
+func (a *Authenticator) Issue(name string, role Role, projects []string, ttl time.Duration, createdBy string) (*IssuedToken, error) {
+	if _, err := ParseRole(string(role)); err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = a.tokenTTL
+	}
+	return a.issue(&IssuedToken{Name: name, Subject: name, Role: role, Projects: projects, CreatedBy: createdBy}, ttl)
+}
+
+// Login exchanges the credential p authenticated with (a static token, issued token
+// or JWT) for a session token with the same subject, role and projects.
+func (a *Authenticator) Login(p *Principal) (*IssuedToken, error) {
+	return a.issue(&IssuedToken{Name: "session", Subject: p.Subject, Role: p.Role, Projects: p.Projects, Session: true, CreatedBy: p.Subject}, a.sessionTTL)
+}
+
+// Refresh replaces the session token p authenticated with by a new one. Other
+// credentials get a new session, as with Login.
+func (a *Authenticator) Refresh(p *Principal) (*IssuedToken, error) {
+	if p.Method == MethodIssued {
+		a.mu.Lock()
+		for key, t := range a.issued {
+			if t.ID == p.TokenID && t.Session {
+				delete(a.issued, key)
+			}
+		}
+		a.mu.Unlock()
+	}
+	return a.Login(p)
+}
+
+func (a *Authenticator) issue(t *IssuedToken, ttl time.Duration) (*IssuedToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	t.ID = uuid.New().String()
+	t.CreatedAt = a.now()
+	t.ExpiresAt = t.CreatedAt.Add(ttl)
+	t.Token = "cube_" + hex.EncodeToString(secret)
+
+	kept := *t
+	kept.Token = ""
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.issued[hashToken(t.Token)] = &kept
+	return t, nil
+}
+
+// Tokens returns the unexpired issued tokens, without their secrets, oldest first.
+func (a *Authenticator) Tokens() []IssuedToken {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	tokens := make([]IssuedToken, 0, len(a.issued))
+	for key, t := range a.issued {
+		if !now.Before(t.ExpiresAt) {
+			delete(a.issued, key)
+			continue
+		}
+		tokens = append(tokens, *t)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens
+}
+
+// Revoke deletes an issued token by ID.
+func (a *Authenticator) Revoke(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, t := range a.issued {
+		if t.ID == id {
+			delete(a.issued, key)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
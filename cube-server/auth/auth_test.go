@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticAndIssuedTokens(t *testing.T) {
+	a, err := New(Config{Tokens: []StaticToken{{Name: "ci", Token: "ci-secret", Role: RoleOperator}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.Authenticate("ci-secret")
+	if err != nil || p.Subject != "ci" || p.Role != RoleOperator || p.Method != MethodStatic {
+		t.Fatalf("static token: %+v, %v", p, err)
+	}
+	if _, err := a.Authenticate("wrong"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("wrong token: %v, want ErrInvalidToken", err)
+	}
+	if _, err := a.Authenticate(""); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("no token: %v, want ErrUnauthenticated", err)
+	}
+
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	issued, err := a.Issue("dashboard", RoleViewer, nil, time.Hour, "ci")
+	if err != nil || issued.Token == "" {
+		t.Fatalf("issue: %+v, %v", issued, err)
+	}
+	if p, err := a.Authenticate(issued.Token); err != nil || p.Role != RoleViewer || p.TokenID != issued.ID {
+		t.Errorf("issued token: %+v, %v", p, err)
+	}
+	if tokens := a.Tokens(); len(tokens) != 1 || tokens[0].Token != "" || tokens[0].CreatedBy != "ci" {
+		t.Errorf("Tokens() = %+v", tokens)
+	}
+
+	session, _ := a.Login(p)
+	sessionPrincipal, err := a.Authenticate(session.Token)
+	if err != nil || sessionPrincipal.Subject != "ci" || sessionPrincipal.Role != RoleOperator {
+		t.Fatalf("session: %+v, %v", sessionPrincipal, err)
+	}
+	refreshed, _ := a.Refresh(sessionPrincipal)
+	if _, err := a.Authenticate(session.Token); err == nil {
+		t.Error("refreshed session token still valid")
+	}
+	if _, err := a.Authenticate(refreshed.Token); err != nil {
+		t.Errorf("new session token: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := a.Authenticate(issued.Token); err == nil {
+		t.Error("expired token accepted")
+	}
+	if err := a.Revoke(issued.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoke expired token: %v, want ErrNotFound", err)
+	}
+	if _, err := a.Issue("x", "root", nil, 0, "ci"); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("unknown role: %v", err)
+	}
+	if _, err := New(Config{Tokens: []StaticToken{{Name: "bad", Token: "t", Role: "root"}}}); err == nil {
+		t.Error("config with unknown role accepted")
+	}
+}
+
+func TestProjectLimits(t *testing.T) {
+	a, err := New(Config{Tokens: []StaticToken{
+		{Name: "root", Token: "root-secret", Role: RoleAdmin},
+		{Name: "team-a", Token: "team-secret", Role: RoleOperator, Projects: []string{"team-a"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := a.Authenticate("root-secret")
+	team, _ := a.Authenticate("team-secret")
+	issued, err := a.Issue("dashboard", RoleViewer, []string{"team-b", "team-c"}, time.Hour, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dashboard, _ := a.Authenticate(issued.Token)
+	session, _ := a.Login(team)
+	teamSession, _ := a.Authenticate(session.Token)
+
+	for _, tc := range []struct {
+		name      string
+		principal *Principal
+		project   string
+		want      bool
+	}{
+		{"authentication disabled", nil, "team-a", true},
+		{"unlimited token", root, "team-a", true},
+		{"unlimited token, default project", root, "default", true},
+		{"static token, its project", team, "team-a", true},
+		{"static token, other project", team, "team-b", false},
+		{"static token, default project", team, "default", false},
+		{"issued token", dashboard, "team-c", true},
+		{"issued token, other project", dashboard, "team-a", false},
+		{"session keeps the projects", teamSession, "team-b", false},
+		{"session, its project", teamSession, "team-a", true},
+	} {
+		if got := tc.principal.AllowsProject(tc.project); got != tc.want {
+			t.Errorf("%s: AllowsProject(%s) = %v, want %v", tc.name, tc.project, got, tc.want)
+		}
+	}
+}
+
+func TestServerWide(t *testing.T) {
+	for _, tc := range []struct {
+		method, route string
+		want          bool
+	}{
+		{"GET", "/api/v1/clusters", false},
+		{"GET", "/api/v1/audit", false},
+		{"GET", "/api/v1/projects", false},
+		{"GET", "/api/v1/projects/:project", false},
+		{"PUT", "/api/v1/projects/:project", true},
+		{"POST", "/api/v1/projects", true},
+		{"GET", "/api/v1/auth/tokens", true},
+		{"POST", "/api/v1/auth/login", false},
+		{"POST", "/api/v1/simulate/admin/restore", true},
+		{"GET", "/api/v1/simulate/admin/clock", true},
+	} {
+		if got := ServerWide(tc.method, tc.route); got != tc.want {
+			t.Errorf("ServerWide(%s %s) = %v, want %v", tc.method, tc.route, got, tc.want)
+		}
+	}
+}
+
+func TestRequiredRole(t *testing.T) {
+	for _, tc := range []struct {
+		method, route string
+		want          Role
+	}{
+		{"GET", "/api/v1/clusters", RoleViewer},
+		{"POST", "/api/v1/clusters", RoleOperator},
+		{"DELETE", "/api/v1/tests/:id", RoleOperator},
+		{"GET", "/api/v1/executor/executions", RoleOperator},
+		{"POST", "/api/v1/executor/azure/aks", RoleAdmin},
+		{"GET", "/api/v1/proxy/providers/:provider/buckets", RoleOperator},
+		{"DELETE", "/api/v1/proxy/providers/:provider/buckets/:bucket", RoleAdmin},
+		{"PUT", "/api/v1/simulate/admin/faults", RoleAdmin},
+		{"GET", "/api/v1/auth/tokens", RoleAdmin},
+		{"POST", "/api/v1/auth/login", RoleViewer},
+		{"GET", "/api/v1/audit", RoleAdmin},
+		{"GET", "/api/v1/webhooks/:id", RoleOperator},
+		{"POST", "/api/v1/webhooks/dead-letters/:id/redeliver", RoleOperator},
+	} {
+		if got := RequiredRole(tc.method, tc.route); got != tc.want {
+			t.Errorf("RequiredRole(%s %s) = %s, want %s", tc.method, tc.route, got, tc.want)
+		}
+	}
+}
+
+func TestOIDCTokens(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	data, _ := json.Marshal(jwks)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(Config{OIDC: OIDCConfig{
+		JWKSFile:    path,
+		Issuer:      "https://idp.example.com",
+		Audience:    "cube-server",
+		RoleMapping: map[string]Role{"cube-admins": RoleAdmin},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(alg, kid string, claims map[string]interface{}) string {
+		header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+		payload, _ := json.Marshal(claims)
+		signed := b64(header) + "." + b64(payload)
+		digest := sha256.Sum256([]byte(signed))
+		var sig []byte
+		if alg == "RS256" {
+			sig, _ = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		} else {
+			r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+		return signed + "." + b64(sig)
+	}
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "alice", "iss": "https://idp.example.com", "aud": []string{"cube-server"},
+			"exp": time.Now().Add(time.Hour).Unix(), "roles": []string{"viewer", "cube-admins"},
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	p, err := a.Authenticate(sign("RS256", "rsa-1", claims(nil)))
+	if err != nil || p.Subject != "alice" || p.Role != RoleAdmin || p.Method != MethodOIDC {
+		t.Fatalf("RS256 token: %+v, %v", p, err)
+	}
+	if p, err := a.Authenticate(sign("ES256", "ec-1", claims(map[string]interface{}{"roles": "operator"}))); err != nil || p.Role != RoleOperator {
+		t.Errorf("ES256 token: %+v, %v", p, err)
+	}
+	for name, token := range map[string]string{
+		"expired":       sign("RS256", "rsa-1", claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"wrong issuer":  sign("RS256", "rsa-1", claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+		"wrong aud":     sign("RS256", "rsa-1", claims(map[string]interface{}{"aud": "other"})),
+		"unknown kid":   sign("RS256", "rsa-2", claims(nil)),
+		"wrong key":     sign("ES256", "rsa-1", claims(nil)),
+		"tampered body": sign("RS256", "rsa-1", claims(nil))[:40] + "x" + sign("RS256", "rsa-1", claims(nil))[41:],
+	} {
+		if _, err := a.Authenticate(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: %v, want ErrInvalidToken", name, err)
+		}
+	}
+	if _, err := a.Authenticate(sign("RS256", "rsa-1", claims(map[string]interface{}{"roles": []string{"developers"}}))); !errors.Is(err, ErrNoRole) {
+		t.Errorf("token without a role: %v, want ErrNoRole", err)
+	}
+
+	limited, err := a.Authenticate(sign("RS256", "rsa-1", claims(map[string]interface{}{"projects": []string{"team-a", "team-b"}})))
+	if err != nil || len(limited.Projects) != 2 || !limited.AllowsProject("team-b") || limited.AllowsProject("default") {
+		t.Errorf("token with a project claim: %+v, %v", limited, err)
+	}
+	if p.Projects != nil {
+		t.Errorf("token without a project claim is limited to %v", p.Projects)
+	}
+	if _, err := a.Authenticate(sign("RS256", "rsa-1", claims(map[string]interface{}{"projects": []string{}}))); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token with an empty project claim: %v, want ErrInvalidToken", err)
+	}
+}
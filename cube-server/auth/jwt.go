@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// Default JWT claims read for roles and projects when OIDCConfig leaves them empty.
+const (
+	DefaultRoleClaim    = "roles"
+	DefaultProjectClaim = "projects"
+)
+
+// clockSkew is the tolerance for exp and nbf.
+const clockSkew = time.Minute
+
+// OIDCConfig verifies JWTs of an OIDC provider against its signing keys, exported
+// as a JWKS file (the provider's jwks_uri document).
+type OIDCConfig struct {
+	JWKSFile string `yaml:"jwks_file"`
+	// Issuer and Audience must match the iss and aud claims when set
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// RoleClaim holds a role name or a list of them (default "roles")
+	RoleClaim string `yaml:"role_claim"`
+	// RoleMapping maps claim values such as group names to roles; values that
+	// are role names themselves need no entry
+	RoleMapping map[string]Role `yaml:"role_mapping"`
+	// ProjectClaim holds the projects the token is limited to, a name or a list of
+	// them (default "projects"); without it the token allows every project
+	ProjectClaim string `yaml:"project_claim"`
+}
+
+type jwtVerifier struct {
+	cfg  OIDCConfig
+	keys map[string]crypto.PublicKey // by kid
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newJWTVerifier(cfg OIDCConfig) (*jwtVerifier, error) {
+	data, err := os.ReadFile(cfg.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("auth: read JWKS: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("auth: parse JWKS %s: %w", cfg.JWKSFile, err)
+	}
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = DefaultRoleClaim
+	}
+	if cfg.ProjectClaim == "" {
+		cfg.ProjectClaim = DefaultProjectClaim
+	}
+	for claim, role := range cfg.RoleMapping {
+		if _, err := ParseRole(string(role)); err != nil {
+			return nil, fmt.Errorf("auth: role_mapping %s: %w", claim, err)
+		}
+	}
+	v := &jwtVerifier{cfg: cfg, keys: make(map[string]crypto.PublicKey)}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("auth: JWKS key %q: %w", k.Kid, err)
+		}
+		v.keys[k.Kid] = key
+	}
+	if len(v.keys) == 0 {
+		return nil, fmt.Errorf("auth: JWKS %s has no signing keys", cfg.JWKSFile)
+	}
+	return v, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// verify checks the signature and the registered claims of a compact JWT and maps
+// its role claim.
+func (v *jwtVerifier) verify(token string, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, ok := v.keys[header.Kid]
+	if !ok && header.Kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			key, ok = k, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, header.Kid)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: no exp claim", ErrInvalidToken)
+	}
+	expires := time.Unix(int64(exp), 0)
+	if now.After(expires.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	if v.cfg.Issuer != "" && claims["iss"] != v.cfg.Issuer {
+		return nil, fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	}
+	if v.cfg.Audience != "" && !containsClaim(claims["aud"], v.cfg.Audience) {
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	}
+	subject, _ := claims["sub"].(string)
+	role := v.role(claims[v.cfg.RoleClaim])
+	if role == "" {
+		return nil, ErrNoRole
+	}
+	var projects []string
+	if claim, present := claims[v.cfg.ProjectClaim]; present {
+		projects = claimValues(claim)
+		if len(projects) == 0 {
+			// An empty list must not turn into every project
+			return nil, fmt.Errorf("%w: empty %s claim", ErrInvalidToken, v.cfg.ProjectClaim)
+		}
+	}
+	return &Principal{Subject: subject, Role: role, Method: MethodOIDC, Projects: projects, ExpiresAt: &expires}, nil
+}
+
+// claimValues returns the values of a claim holding a space-separated string or a
+// list of strings.
+func claimValues(claim interface{}) []string {
+	var values []string
+	switch c := claim.(type) {
+	case string:
+		values = strings.Fields(c)
+	case []interface{}:
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// role returns the highest role granted by a role claim value.
+func (v *jwtVerifier) role(claim interface{}) Role {
+	var best Role
+	for _, value := range claimValues(claim) {
+		role, ok := v.cfg.RoleMapping[value]
+		if !ok {
+			role = Role(value)
+		}
+		if roleRank[role] > roleRank[best] {
+			best = role
+		}
+	}
+	return best
+}
+
+func containsClaim(claim interface{}, want string) bool {
+	switch c := claim.(type) {
+	case string:
+		return c == want
+	case []interface{}:
+		for _, item := range c {
+			if item == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' {
+			return fmt.Errorf("alg %s does not match an RSA key", alg)
+		}
+		return rsa.VerifyPKCS1v15(k, hash, digest, sig)
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || len(sig) != 2*size {
+			return fmt.Errorf("alg %s does not match the EC key", alg)
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("bad signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key")
+}
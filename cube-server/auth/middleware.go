@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is the gin context key of the request's *Principal.
+const principalKey = "cube-auth-principal"
+
+// PublicRoutes need no token: the health check, and the S3 wire protocol whose SDK
+// clients sign requests instead (storage.s3.auth). Outside the default project the
+// S3 routes only take requests signed with a key bound to the project.
+var PublicRoutes = map[string]bool{
+	"/api/v1/metrics/health":        true,
+	"/api/v1/simulate/aws-s3":       true,
+	"/api/v1/simulate/aws-s3/*path": true,
+}
+
+// RequiredRole returns the role a request needs, by method and route pattern:
+//   - admin: issuing and revoking tokens, changing projects, the audit log, simulation
+//     admin (clock, faults, lifecycle), executor requests and changes through the proxy,
+//     which act on real providers
+//   - operator: reading executor and proxy routes, webhooks (whose secrets and payloads
+//     leave the server), and every other change
+//   - viewer: every other read, logging in and refreshing a session
+func RequiredRole(method, route string) Role {
+	read := method == http.MethodGet || method == http.MethodHead
+	switch {
+	case strings.HasPrefix(route, "/api/v1/auth/tokens"),
+		strings.HasPrefix(route, "/api/v1/simulate/admin/"),
+		route == "/api/v1/audit",
+		strings.HasPrefix(route, "/api/v1/projects") && !read:
+		return RoleAdmin
+	case strings.HasPrefix(route, "/api/v1/executor/"), strings.HasPrefix(route, "/api/v1/proxy/"):
+		if read {
+			return RoleOperator
+		}
+		return RoleAdmin
+	case strings.HasPrefix(route, "/api/v1/webhooks"):
+		return RoleOperator
+	case strings.HasPrefix(route, "/api/v1/auth/"), read:
+		return RoleViewer
+	}
+	return RoleOperator
+}
+
+// ServerWide reports whether a route acts on the whole server rather than on the
+// project of the request: token administration, simulation admin (the clock and
+// fault profiles are shared, snapshots and restores cover every project) and changes
+// to projects. Principals limited to projects may not use them.
+func ServerWide(method, route string) bool {
+	read := method == http.MethodGet || method == http.MethodHead
+	return strings.HasPrefix(route, "/api/v1/auth/tokens") ||
+		strings.HasPrefix(route, "/api/v1/simulate/admin/") ||
+		strings.HasPrefix(route, "/api/v1/projects") && !read
+}
+
+// Middleware authenticates the bearer token of every request outside PublicRoutes and
+// checks the caller's role against RequiredRole. It answers 401 without a valid token
+// and 403 when the role is too low or a principal limited to projects asks for a
+// ServerWide route; the project itself is checked once it is resolved (see
+// Principal.AllowsProject). A nil Authenticator allows everything.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a == nil || PublicRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+		token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		principal, err := a.Authenticate(strings.TrimSpace(token))
+		switch {
+		case errors.Is(err, ErrNoRole):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.Header("WWW-Authenticate", `Bearer realm="cube-server"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if required := RequiredRole(c.Request.Method, c.FullPath()); !principal.Role.Allows(required) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":         "role " + string(required) + " required",
+				"role":          principal.Role,
+				"required_role": required,
+			})
+			return
+		}
+		if len(principal.Projects) > 0 && ServerWide(c.Request.Method, c.FullPath()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":    "route acts on every project, the token is limited to some",
+				"projects": principal.Projects,
+			})
+			return
+		}
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// FromContext returns the principal the middleware authenticated, or nil when
+// authentication is disabled or the route is public.
+func FromContext(c *gin.Context) *Principal {
+	p, _ := c.Get(principalKey)
+	principal, _ := p.(*Principal)
+	return principal
+}
@@ -0,0 +1,129 @@
+// Package events fans out resource changes (test progress, cluster updates) to the
+// subscribers of GET /api/v1/events. Recent events are kept so a client that
+// reconnects with Last-Event-ID gets what it missed.
+package events
+
+import (
+	"sync"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// Broker defaults
+const (
+	DefaultHistorySize = 1000
+	// subscriberBuffer is the number of undelivered events a subscriber may fall
+	// behind before it is dropped; it then reconnects and catches up from history
+	subscriberBuffer = 256
+)
+
+// Filter selects events by project, resource and resource ID; empty fields match
+// everything.
+type Filter struct {
+	Project  string
+	Resource string
+	ID       string
+}
+
+// Match reports whether the filter selects event.
+func (f Filter) Match(event sharedmodels.Event) bool {
+	return (f.Project == "" || f.Project == event.Project) &&
+		(f.Resource == "" || f.Resource == event.Resource) && (f.ID == "" || f.ID == event.ResourceID)
+}
+
+// Broker assigns IDs to published events and delivers them to subscribers. A nil
+// *Broker is valid and discards everything, so publishers need no checks.
+type Broker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []sharedmodels.Event
+	size    int
+	subs    map[*Subscription]struct{}
+}
+
+// NewBroker returns a broker that keeps the last historySize events for replay
+// (DefaultHistorySize if historySize <= 0).
+func NewBroker(historySize int) *Broker {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Broker{
+		nextID: 1,
+		size:   historySize,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events matching its filter on C. C is closed when the
+// subscription is closed or the subscriber fell too far behind.
+type Subscription struct {
+	C <-chan sharedmodels.Event
+
+	c      chan sharedmodels.Event
+	filter Filter
+	broker *Broker
+}
+
+// Publish stamps event with the next ID and the current time, records it and
+// delivers it to matching subscribers.
+func (b *Broker) Publish(event sharedmodels.Event) sharedmodels.Event {
+	if b == nil {
+		return event
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	event.ID = b.nextID
+	b.nextID++
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if len(b.history) == b.size {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, event)
+	for sub := range b.subs {
+		if !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a subscriber. With replay it also returns the kept events after
+// lastEventID that match filter; those are not sent on C again.
+func (b *Broker) Subscribe(filter Filter, lastEventID uint64, replay bool) (*Subscription, []sharedmodels.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := make(chan sharedmodels.Event, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, filter: filter, broker: b}
+	b.subs[sub] = struct{}{}
+	var missed []sharedmodels.Event
+	if replay {
+		for _, event := range b.history {
+			if event.ID > lastEventID && filter.Match(event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+	return sub, missed
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
+
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
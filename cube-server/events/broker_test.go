@@ -0,0 +1,86 @@
+package events
+
+import (
+	"testing"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+func testEvent(resource, id, eventType string) sharedmodels.Event {
+	return sharedmodels.Event{Resource: resource, ResourceID: id, Type: eventType}
+}
+
+func TestBrokerFiltersAndReplays(t *testing.T) {
+	b := NewBroker(3)
+	first := b.Publish(testEvent(sharedmodels.EventResourceTests, "t-1", sharedmodels.EventCreated))
+	if first.ID != 1 || first.Time.IsZero() {
+		t.Fatalf("Publish = %+v, want ID 1 and a timestamp", first)
+	}
+
+	sub, replay := b.Subscribe(Filter{Resource: sharedmodels.EventResourceTests, ID: "t-1"}, 0, false)
+	defer sub.Close()
+	if len(replay) != 0 {
+		t.Errorf("replay without resume = %v", replay)
+	}
+	b.Publish(testEvent(sharedmodels.EventResourceTests, "t-2", sharedmodels.EventCreated))
+	b.Publish(testEvent(sharedmodels.EventResourceClusters, "t-1", sharedmodels.EventUpdated))
+	b.Publish(testEvent(sharedmodels.EventResourceTests, "t-1", sharedmodels.EventStatus))
+	if got := <-sub.C; got.ID != 4 || got.Type != sharedmodels.EventStatus {
+		t.Errorf("subscriber got %+v, want event 4", got)
+	}
+	select {
+	case extra := <-sub.C:
+		t.Errorf("unexpected event %+v", extra)
+	default:
+	}
+
+	// History keeps the last 3 events (2-4), so resuming after 1 replays the matching 4
+	resumed, replay := b.Subscribe(Filter{Resource: sharedmodels.EventResourceTests}, 1, true)
+	defer resumed.Close()
+	if len(replay) != 2 || replay[0].ID != 2 || replay[1].ID != 4 {
+		t.Errorf("replay after 1 = %+v, want events 2 and 4", replay)
+	}
+	_, replay = b.Subscribe(Filter{}, 0, true)
+	if len(replay) != 3 || replay[0].ID != 2 {
+		t.Errorf("replay from 0 = %+v, want events 2-4", replay)
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker(0)
+	sub, _ := b.Subscribe(Filter{}, 0, false)
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish(testEvent(sharedmodels.EventResourceTests, "t-1", sharedmodels.EventProgress))
+	}
+	received := 0
+	for range sub.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d events before the channel closed, want %d", received, subscriberBuffer)
+	}
+	sub.Close() // closing a dropped subscription is a no-op
+
+	var nilBroker *Broker
+	if event := nilBroker.Publish(testEvent(sharedmodels.EventResourceTests, "t-1", sharedmodels.EventLog)); event.ID != 0 {
+		t.Errorf("nil broker assigned ID %d", event.ID)
+	}
+}
+
+func TestBrokerFiltersByProject(t *testing.T) {
+	b := NewBroker(0)
+	sub, _ := b.Subscribe(Filter{Project: "team-a"}, 0, false)
+	defer sub.Close()
+	other := testEvent(sharedmodels.EventResourceClusters, "c-1", sharedmodels.EventCreated)
+	other.Project = sharedmodels.DefaultProject
+	b.Publish(other)
+	own := testEvent(sharedmodels.EventResourceClusters, "c-1", sharedmodels.EventCreated)
+	own.Project = "team-a"
+	b.Publish(own)
+	if got := <-sub.C; got.ID != 2 || got.Project != "team-a" {
+		t.Errorf("subscriber got %+v, want event 2 of team-a", got)
+	}
+	if _, replay := b.Subscribe(Filter{Project: "team-b"}, 0, true); len(replay) != 0 {
+		t.Errorf("replay for another project = %+v", replay)
+	}
+}
@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	sharederrors "github.com/tronicum/punchbag-cube-testsuite/shared/errors"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/providers/azure"
+)
+
+// Azure operations, named like their simulation counterparts
+const (
+	OperationCreateCluster = "create_cluster"
+	OperationCreateBudget  = "create_budget"
+)
+
+// RegisterAzure registers the AKS and budget backends on the shared Azure provider
+func (s *Service) RegisterAzure(provider azure.AzureProvider) {
+	s.Register("azure", OperationCreateCluster, &azureAKS{provider: provider})
+	s.Register("azure", OperationCreateBudget, &azureBudget{provider: provider})
+}
+
+// azureAKS creates AKS clusters
+type azureAKS struct {
+	provider azure.AzureProvider
+}
+
+func (b *azureAKS) Plan(req Request) ([]Call, error) {
+	name, resourceGroup, err := azureTarget(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return []Call{{
+		Service: "Microsoft.ContainerService",
+		Method:  "PUT managedClusters",
+		Target:  fmt.Sprintf("resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s", resourceGroup, name),
+		Parameters: map[string]interface{}{
+			"location":   stringParam(req.Parameters, "location", "eastus"),
+			"node_count": intParam(req.Parameters, "node_count", 3),
+		},
+	}}, nil
+}
+
+func (b *azureAKS) Execute(ctx context.Context, req Request) (map[string]interface{}, error) {
+	name, resourceGroup, err := azureTarget(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	result, err := b.provider.CreateAKSCluster(ctx, name, resourceGroup,
+		stringParam(req.Parameters, "location", "eastus"), intParam(req.Parameters, "node_count", 3))
+	if err != nil {
+		return nil, azureError("AKS cluster creation", err)
+	}
+	return map[string]interface{}{"cluster_id": result.ID, "status": result.Status, "url": result.URL}, nil
+}
+
+// azureBudget creates Consumption budgets
+type azureBudget struct {
+	provider azure.AzureProvider
+}
+
+func (b *azureBudget) Plan(req Request) ([]Call, error) {
+	name, resourceGroup, err := azureTarget(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	amount, _ := req.Parameters["amount"].(float64)
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be a positive number")
+	}
+	return []Call{{
+		Service: "Microsoft.Consumption",
+		Method:  "PUT budgets",
+		Target:  fmt.Sprintf("resourceGroups/%s/providers/Microsoft.Consumption/budgets/%s", resourceGroup, name),
+		Parameters: map[string]interface{}{
+			"amount":     amount,
+			"time_grain": stringParam(req.Parameters, "time_grain", "Monthly"),
+		},
+	}}, nil
+}
+
+func (b *azureBudget) Execute(ctx context.Context, req Request) (map[string]interface{}, error) {
+	name, resourceGroup, err := azureTarget(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	amount, _ := req.Parameters["amount"].(float64)
+	result, err := b.provider.CreateBudget(ctx, name, amount, resourceGroup, stringParam(req.Parameters, "time_grain", "Monthly"))
+	if err != nil {
+		return nil, azureError("budget creation", err)
+	}
+	return map[string]interface{}{"budget_id": result.ID, "status": result.Status}, nil
+}
+
+// azureTarget returns the resource name and group every Azure operation needs
+func azureTarget(params map[string]interface{}) (name, resourceGroup string, err error) {
+	name = stringParam(params, "name", "")
+	if name == "" {
+		return "", "", fmt.Errorf("name is required")
+	}
+	return name, stringParam(params, "resource_group", "rg-"+name), nil
+}
+
+// azureError explains the shared provider's "not found", which it returns for
+// operations it only supports in simulation mode
+func azureError(operation string, err error) error {
+	if errors.Is(err, sharederrors.ErrNotFound) {
+		return fmt.Errorf("%s is not implemented by the Azure provider outside simulation mode: %w", operation, err)
+	}
+	return err
+}
+
+func stringParam(params map[string]interface{}, key, def string) string {
+	if v, ok := params[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func intParam(params map[string]interface{}, key string, def int) int {
+	switch v := params[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return def
+}
@@ -0,0 +1,236 @@
+// Package executor performs provider operations against the real clouds behind a
+// simulation gate. Every request is first run through the SimulationService; the
+// backend registered for the provider and operation is only called when that
+// simulation passed or the request is forced. Dry runs stop after planning. Each
+// execution is kept as a Record linking the simulation to the real outcome.
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+// DefaultHistorySize is the number of records kept when New is given no size
+const DefaultHistorySize = 1000
+
+var (
+	ErrUnknownOperation = errors.New("no executor backend for operation")
+	ErrInvalidRequest   = errors.New("invalid execution request")
+	ErrSimulationFailed = errors.New("simulation failed")
+	ErrNotFound         = errors.New("execution not found")
+)
+
+// Status is the outcome of an execution
+type Status string
+
+const (
+	// StatusPlanned is a dry run: simulated and planned, nothing executed
+	StatusPlanned Status = "planned"
+	// StatusBlocked means the simulation failed and force was not set
+	StatusBlocked   Status = "blocked"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Request asks for an operation on a provider
+type Request struct {
+	// Project is the cube-server project whose simulation gates the request; empty
+	// means the default project
+	Project    string
+	Provider   string
+	Operation  string
+	Parameters map[string]interface{}
+	// DryRun returns the planned provider calls without making them
+	DryRun bool
+	// Force makes the real calls even though the simulation failed
+	Force bool
+}
+
+// Call is one provider API call of an execution
+type Call struct {
+	Service    string                 `json:"service"`
+	Method     string                 `json:"method"`
+	Target     string                 `json:"target,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Backend performs one operation against a real provider. Plan must not have side
+// effects; it also validates the parameters. Execute makes the planned calls.
+type Backend interface {
+	Plan(req Request) ([]Call, error)
+	Execute(ctx context.Context, req Request) (map[string]interface{}, error)
+}
+
+// Record is the stored outcome of an execution
+type Record struct {
+	ID         string                       `json:"id"`
+	Project    string                       `json:"project"`
+	Provider   string                       `json:"provider"`
+	Operation  string                       `json:"operation"`
+	Parameters map[string]interface{}       `json:"parameters,omitempty"`
+	DryRun     bool                         `json:"dryrun"`
+	Force      bool                         `json:"force"`
+	Status     Status                       `json:"status"`
+	Simulation *simulation.SimulationResult `json:"simulation"`
+	Plan       []Call                       `json:"plan,omitempty"`
+	// Forced is set when the real calls were made although the simulation failed
+	Forced      bool                   `json:"forced,omitempty"`
+	Result      map[string]interface{} `json:"result,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	StartedAt   time.Time              `json:"started_at"`
+	CompletedAt time.Time              `json:"completed_at"`
+}
+
+// Service gates backends behind the simulation and keeps the records
+type Service struct {
+	sim         *simulation.SimulationService
+	historySize int
+
+	mu       sync.Mutex
+	backends map[string]Backend
+	records  map[string]*Record
+	order    []string
+	nextID   uint64
+}
+
+// New creates a Service that simulates with sim and keeps the last historySize
+// records (DefaultHistorySize when not positive). Backends are added with Register.
+func New(sim *simulation.SimulationService, historySize int) *Service {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Service{
+		sim:         sim,
+		historySize: historySize,
+		backends:    make(map[string]Backend),
+		records:     make(map[string]*Record),
+	}
+}
+
+// Register makes backend perform operation on provider, replacing any earlier one.
+func (s *Service) Register(provider, operation string, backend Backend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backends[provider+"/"+operation] = backend
+}
+
+// Execute simulates req and, unless it is a dry run or the simulation failed without
+// force, makes the real calls. The returned record is also kept for Get and List.
+// A blocked execution returns ErrSimulationFailed, a rejected plan ErrInvalidRequest
+// and a failed real call the backend's error, each together with the record.
+func (s *Service) Execute(ctx context.Context, req Request) (*Record, error) {
+	s.mu.Lock()
+	backend, ok := s.backends[req.Provider+"/"+req.Operation]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %s/%s", ErrUnknownOperation, req.Provider, req.Operation)
+	}
+
+	if req.Project == "" {
+		req.Project = sharedmodels.DefaultProject
+	}
+	record := &Record{
+		Project:    req.Project,
+		Provider:   req.Provider,
+		Operation:  req.Operation,
+		Parameters: req.Parameters,
+		DryRun:     req.DryRun,
+		Force:      req.Force,
+		StartedAt:  time.Now().UTC(),
+	}
+	record.Simulation = s.sim.Project(req.Project).SimulateOperationContext(ctx, &simulation.SimulationRequest{
+		Provider:   req.Provider,
+		Operation:  req.Operation,
+		Parameters: req.Parameters,
+	})
+
+	var err error
+	if !record.Simulation.Success && !req.Force {
+		record.Status = StatusBlocked
+		err = fmt.Errorf("%w: %s", ErrSimulationFailed, record.Simulation.Error)
+	} else {
+		err = run(ctx, backend, req, record)
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	record.CompletedAt = time.Now().UTC()
+	return s.keep(record), err
+}
+
+// run plans req and, unless it is a dry run, executes it, filling in record
+func run(ctx context.Context, backend Backend, req Request, record *Record) error {
+	plan, err := backend.Plan(req)
+	if err != nil {
+		record.Status = StatusFailed
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	record.Plan = plan
+	if req.DryRun {
+		record.Status = StatusPlanned
+		return nil
+	}
+	record.Forced = !record.Simulation.Success
+	if record.Result, err = backend.Execute(ctx, req); err != nil {
+		record.Status = StatusFailed
+		return err
+	}
+	record.Status = StatusSucceeded
+	return nil
+}
+
+// keep stores record under a new ID, dropping the oldest records beyond the history size
+func (s *Service) keep(record *Record) *Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	record.ID = "exec-" + strconv.FormatUint(s.nextID, 10)
+	s.records[record.ID] = record
+	s.order = append(s.order, record.ID)
+	if len(s.order) > s.historySize {
+		delete(s.records, s.order[0])
+		s.order = s.order[1:]
+	}
+	copied := *record
+	return &copied
+}
+
+// Get returns the record of an execution in project ("" for the default project)
+func (s *Service) Get(project, id string) (*Record, error) {
+	if project == "" {
+		project = sharedmodels.DefaultProject
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[id]
+	if !ok || record.Project != project {
+		return nil, ErrNotFound
+	}
+	copied := *record
+	return &copied, nil
+}
+
+// List returns the kept records of project ("" for the default project), oldest first
+func (s *Service) List(project string) []*Record {
+	if project == "" {
+		project = sharedmodels.DefaultProject
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]*Record, 0, len(s.order))
+	for _, id := range s.order {
+		if s.records[id].Project != project {
+			continue
+		}
+		copied := *s.records[id]
+		records = append(records, &copied)
+	}
+	return records
+}
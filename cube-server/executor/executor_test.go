@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+// fakeBackend records the real calls it is asked to make
+type fakeBackend struct {
+	executed []Request
+	err      error
+}
+
+func (f *fakeBackend) Plan(req Request) ([]Call, error) {
+	if req.Parameters["name"] == nil {
+		return nil, errors.New("name is required")
+	}
+	return []Call{{Service: "fake", Method: "create", Target: req.Parameters["name"].(string)}}, nil
+}
+
+func (f *fakeBackend) Execute(ctx context.Context, req Request) (map[string]interface{}, error) {
+	f.executed = append(f.executed, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return map[string]interface{}{"id": "real-" + req.Parameters["name"].(string)}, nil
+}
+
+func newTestService(historySize int) (*Service, *fakeBackend) {
+	s := New(simulation.NewSimulationServiceWithOptions(true, false), historySize)
+	backend := &fakeBackend{}
+	s.Register("azure", OperationCreateBudget, backend)
+	return s, backend
+}
+
+func TestExecuteGatesOnSimulation(t *testing.T) {
+	s, backend := newTestService(0)
+	ctx := context.Background()
+	valid := map[string]interface{}{"name": "team", "amount": 100.0}
+	invalid := map[string]interface{}{"name": "team", "amount": -1.0} // rejected by the simulation only
+
+	record, err := s.Execute(ctx, Request{Provider: "azure", Operation: OperationCreateBudget, Parameters: valid})
+	if err != nil || record.Status != StatusSucceeded || !record.Simulation.Success || record.Result["id"] != "real-team" || len(record.Plan) != 1 {
+		t.Fatalf("Execute = %+v, %v; want a succeeded execution", record, err)
+	}
+
+	record, err = s.Execute(ctx, Request{Provider: "azure", Operation: OperationCreateBudget, Parameters: valid, DryRun: true})
+	if err != nil || record.Status != StatusPlanned || record.Plan[0].Target != "team" || record.Result != nil {
+		t.Errorf("dry run = %+v, %v; want a plan only", record, err)
+	}
+
+	record, err = s.Execute(ctx, Request{Provider: "azure", Operation: OperationCreateBudget, Parameters: invalid})
+	if !errors.Is(err, ErrSimulationFailed) || record.Status != StatusBlocked || record.Simulation.Error == "" || record.Plan != nil {
+		t.Errorf("failed simulation = %+v, %v; want blocked", record, err)
+	}
+	if len(backend.executed) != 1 {
+		t.Fatalf("backend executed %d times, want only the first request", len(backend.executed))
+	}
+
+	record, err = s.Execute(ctx, Request{Provider: "azure", Operation: OperationCreateBudget, Parameters: invalid, Force: true})
+	if err != nil || record.Status != StatusSucceeded || !record.Forced || record.Simulation.Success {
+		t.Errorf("forced execution = %+v, %v; want succeeded and forced", record, err)
+	}
+
+	backend.err = errors.New("quota exceeded")
+	record, err = s.Execute(ctx, Request{Provider: "azure", Operation: OperationCreateBudget, Parameters: valid})
+	if !errors.Is(err, backend.err) || record.Status != StatusFailed || record.Error != "quota exceeded" {
+		t.Errorf("failing backend = %+v, %v", record, err)
+	}
+
+	record, err = s.Execute(ctx, Request{Provider: "azure", Operation: OperationCreateBudget, Parameters: map[string]interface{}{"amount": 5.0}, Force: true})
+	if !errors.Is(err, ErrInvalidRequest) || record.Status != StatusFailed {
+		t.Errorf("rejected plan = %+v, %v", record, err)
+	}
+
+	if _, err := s.Execute(ctx, Request{Provider: "aws", Operation: OperationCreateBudget}); !errors.Is(err, ErrUnknownOperation) {
+		t.Errorf("unregistered backend: err = %v, want ErrUnknownOperation", err)
+	}
+}
+
+func TestRecordsAreKept(t *testing.T) {
+	s, _ := newTestService(2)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		record, _ := s.Execute(context.Background(), Request{Provider: "azure", Operation: OperationCreateBudget, Parameters: map[string]interface{}{"name": "team", "amount": 1.0}, DryRun: true})
+		ids = append(ids, record.ID)
+	}
+	if _, err := s.Get("", ids[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(%s) beyond the history: err = %v, want ErrNotFound", ids[0], err)
+	}
+	got, err := s.Get("", ids[2])
+	if err != nil || got.ID != ids[2] || got.Simulation == nil || got.Status != StatusPlanned {
+		t.Errorf("Get(%s) = %+v, %v", ids[2], got, err)
+	}
+	if records := s.List(""); len(records) != 2 || records[0].ID != ids[1] || records[1].ID != ids[2] {
+		t.Errorf("List = %+v, want the last two records", records)
+	}
+
+	other, _ := s.Execute(context.Background(), Request{Project: "team-a", Provider: "azure", Operation: OperationCreateBudget, Parameters: map[string]interface{}{"name": "team", "amount": 1.0}, DryRun: true})
+	if records := s.List("team-a"); len(records) != 1 || records[0].ID != other.ID {
+		t.Errorf("List(team-a) = %+v, want only its record", records)
+	}
+	if _, err := s.Get("", other.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of another project's record: err = %v, want ErrNotFound", err)
+	}
+}
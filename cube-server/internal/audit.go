@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"os"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/audit"
+)
+
+// OpenAuditLog opens the audit log selected in the config. Without a config file the
+// CUBE_SERVER_AUDIT_PATH environment variable still applies; without a path the log
+// is kept in memory.
+func OpenAuditLog(cfg *ServerConfig) (*audit.Log, error) {
+	settings := audit.Config{Path: os.Getenv("CUBE_SERVER_AUDIT_PATH")}
+	if cfg != nil {
+		settings = audit.Config{
+			Path:     cfg.Audit.Path,
+			MaxSize:  int64(cfg.Audit.MaxSizeMB) << 20,
+			MaxFiles: cfg.Audit.MaxFiles,
+		}
+	}
+	return audit.Open(settings)
+}
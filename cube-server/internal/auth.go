@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"os"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+)
+
+// NewAuthenticator returns the authenticator for the auth settings of the config, or
+// nil when authentication is disabled. Without a config file the CUBE_SERVER_AUTH and
+// CUBE_SERVER_AUTH_TOKEN environment variables still apply.
+func NewAuthenticator(cfg *ServerConfig) (*auth.Authenticator, error) {
+	var settings auth.Config
+	if cfg != nil {
+		settings = cfg.Auth
+	} else {
+		applyAuthEnv(&settings)
+	}
+	if !settings.Enabled {
+		return nil, nil
+	}
+	return auth.New(settings)
+}
+
+// applyAuthEnv adds CUBE_SERVER_AUTH_TOKEN as an admin token, which enables
+// authentication, and applies the CUBE_SERVER_AUTH=1/0 switch
+func applyAuthEnv(settings *auth.Config) {
+	if token := os.Getenv("CUBE_SERVER_AUTH_TOKEN"); token != "" {
+		settings.Enabled = true
+		settings.Tokens = append(settings.Tokens, auth.StaticToken{Name: "admin", Token: token, Role: auth.RoleAdmin})
+	}
+	switch os.Getenv("CUBE_SERVER_AUTH") {
+	case "1":
+		settings.Enabled = true
+	case "0":
+		settings.Enabled = false
+	}
+}
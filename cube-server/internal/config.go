@@ -1,9 +1,12 @@
 package internal
 
 import (
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/auth"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
 	"os"
+	"time"
 )
 
 // ServerConfig holds all server configuration, including storage backends and dummy buckets
@@ -17,6 +20,61 @@ import (
 //	  hetzner:
 //	    - name: test-bucket
 //	      region: fsn1
+//	s3:
+//	  provider: aws
+//	  virtual_host_domains: [localhost]
+//	  auth:
+//	    enabled: true
+//	    credentials:
+//	      - access_key: AKIDEXAMPLE
+//	        secret_key: wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY
+//
+// store:
+//
+//	backend: bolt
+//	path: data/cube-server.db
+//
+// tests:
+//
+//	concurrency: 4
+//	queue_size: 100
+//	timeout: 10m
+//
+// proxy:
+//
+//	providers:
+//	  aws:
+//	    region: eu-central-1
+//	    access_key: AKIA...
+//	    secret_key: ...
+//	  hetzner:
+//	    region: fsn1
+//	    access_key: ...
+//	    secret_key: ...
+//	    endpoint: http://localhost:8080/api/v1/simulate/aws-s3
+//
+// auth:
+//
+//	enabled: true
+//	tokens:
+//	  - name: ci
+//	    token: ...
+//	    role: operator
+//	oidc:
+//	  jwks_file: conf/jwks.json
+//	  issuer: https://login.example.com
+//	  audience: cube-server
+//
+// api:
+//
+//	validation: log
+//	idempotency_window: 24h
+//
+// audit:
+//
+//	path: data/audit.jsonl
+//	max_size_mb: 10
+//	max_files: 5
 //
 // ... other config fields ...
 type ServerConfig struct {
@@ -25,12 +83,126 @@ type ServerConfig struct {
 			Name   string `yaml:"name"`
 			Region string `yaml:"region"`
 		} `yaml:"dummy_buckets"`
+		// S3 configures the S3 wire-protocol simulation under /api/v1/simulate/aws-s3
+		S3 struct {
+			// Provider is the BucketStore provider served over the S3 API (default: aws)
+			Provider string `yaml:"provider"`
+			// VirtualHostDomains enables virtual-host-style addressing for <bucket>.<domain> (default: localhost)
+			VirtualHostDomains []string `yaml:"virtual_host_domains"`
+			// Auth enables AWS SigV4 verification of S3 requests against the configured credentials
+			Auth struct {
+				Enabled     bool           `yaml:"enabled"`
+				Credentials []S3Credential `yaml:"credentials"`
+			} `yaml:"auth"`
+		} `yaml:"s3"`
 	} `yaml:"storage"`
+	// Store selects where clusters and test results are kept
+	Store struct {
+		// Backend is "memory" (default, lost on restart) or "bolt" (embedded database file)
+		Backend string `yaml:"backend"`
+		// Path is the bolt database file (default: cube-server.db)
+		Path string `yaml:"path"`
+	} `yaml:"store"`
+	// Tests sizes the asynchronous test executor behind POST /clusters/:id/tests
+	Tests struct {
+		// Concurrency is the number of tests run in parallel (default: 4)
+		Concurrency int `yaml:"concurrency"`
+		// QueueSize is the number of pending tests accepted (default: 100)
+		QueueSize int `yaml:"queue_size"`
+		// Timeout applies to tests without their own "timeout" config value (default: 10m)
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"tests"`
+	// Proxy holds the provider credentials /api/v1/proxy uses to call the real providers,
+	// keyed by provider (aws, hetzner, azure)
+	Proxy struct {
+		Providers map[string]ProxyProvider `yaml:"providers"`
+	} `yaml:"proxy"`
+	// Simulation tunes the shared SimulationService (see ConfigureSimulation)
+	Simulation SimulationConfig `yaml:"simulation"`
+
+	// Auth enables API tokens, OIDC JWTs and roles for /api/v1 (see auth/)
+	Auth auth.Config `yaml:"auth"`
+	// Audit keeps every change made through /api/v1 for GET /api/v1/audit
+	Audit struct {
+		// Path is the JSONL file (default: none, the last 10000 entries are kept in memory)
+		Path string `yaml:"path"`
+		// MaxSizeMB rotates the file at this size (default: 10)
+		MaxSizeMB int `yaml:"max_size_mb"`
+		// MaxFiles is the number of rotated files kept (default: 5)
+		MaxFiles int `yaml:"max_files"`
+	} `yaml:"audit"`
+	// API configures the checks against api/openapi.yaml and idempotent POSTs
+	API struct {
+		// Validation is strict, log or off (default: strict in gin test mode, log otherwise);
+		// CUBE_SERVER_API_VALIDATION overrides it
+		Validation string `yaml:"validation"`
+		// IdempotencyWindow is how long answers to POSTs with an Idempotency-Key are
+		// replayed (default: 24h, negative: off); CUBE_SERVER_IDEMPOTENCY_WINDOW overrides it
+		IdempotencyWindow time.Duration `yaml:"idempotency_window"`
+		// TrustedProxies are the addresses or CIDR ranges of the reverse proxies whose
+		// X-Forwarded-For and X-Forwarded-Proto headers are believed (default: none)
+		TrustedProxies []string `yaml:"trusted_proxies"`
+	} `yaml:"api"`
+	// Webhooks tunes the deliveries of /api/v1/webhooks subscriptions (see webhooks/)
+	Webhooks struct {
+		// MaxAttempts is how often a delivery is tried before it is a dead letter (default: 6)
+		MaxAttempts int `yaml:"max_attempts"`
+		// Backoff is the wait before the first retry, doubled for every further one up to
+		// MaxBackoff (defaults: 1s and 5m)
+		Backoff    time.Duration `yaml:"backoff"`
+		MaxBackoff time.Duration `yaml:"max_backoff"`
+		// Timeout limits every attempt (default: 10s)
+		Timeout time.Duration `yaml:"timeout"`
+		// Workers send the deliveries, at most QueueSize of them waiting; the ones that
+		// find the queue full are dead letters (defaults: 4 and 1000)
+		Workers   int `yaml:"workers"`
+		QueueSize int `yaml:"queue_size"`
+	} `yaml:"webhooks"`
 	// Add other config fields as needed
 	FastSimulate bool `yaml:"fast_simulate"`
 	Debug        bool `yaml:"debug"`
 }
 
+// S3Credential is an access key/secret key pair accepted by the S3 simulation
+type S3Credential struct {
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	// Owner marks the bucket owner's key, which can always read, replace and remove
+	// bucket policies
+	Owner bool `yaml:"owner"`
+	// Projects are the projects other than the default one the key may use; S3
+	// requests outside the default project need a key bound to it
+	Projects []string `yaml:"projects"`
+}
+
+// SimulationConfig configures the shared SimulationService
+type SimulationConfig struct {
+	// Faults are the fault injection profiles active at startup; they can be
+	// replaced at runtime via /api/v1/simulate/admin/faults
+	Faults []simulation.FaultProfile `yaml:"faults"`
+	// Seed makes the simulated IDs, outcomes and metrics repeatable (default: random)
+	Seed *int64 `yaml:"seed"`
+	// Record writes every simulated operation to this cassette file; Replay answers
+	// operations from one instead of simulating them. Only one of them may be set.
+	Record string `yaml:"record"`
+	Replay string `yaml:"replay"`
+	// Fixtures is the directory of the named snapshots saved and restored via
+	// /api/v1/simulate/admin/fixtures (default: kept in memory)
+	Fixtures string `yaml:"fixtures"`
+}
+
+// ProxyProvider configures the client the proxy uses for one provider
+type ProxyProvider struct {
+	Region    string `yaml:"region"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	// Endpoint overrides the provider's S3 endpoint, e.g. to aim the proxy at the S3 simulation
+	Endpoint string `yaml:"endpoint"`
+	// Credentials are passed to providers configured by key/value, such as azure
+	// (subscription_id, tenant_id, client_id, client_secret)
+	Credentials map[string]string `yaml:"credentials"`
+}
+
 // LoadServerConfig loads config from conf/config.yaml or path in CUBE_SERVER_CONFIG
 func LoadServerConfig() (*ServerConfig, error) {
 	path := os.Getenv("CUBE_SERVER_CONFIG")
@@ -52,5 +224,28 @@ func LoadServerConfig() (*ServerConfig, error) {
 	if os.Getenv("CUBE_SERVER_DEBUG") == "1" {
 		cfg.Debug = true
 	}
+	// ENV override for the store backend
+	if backend := os.Getenv("CUBE_SERVER_STORE"); backend != "" {
+		cfg.Store.Backend = backend
+	}
+	if path := os.Getenv("CUBE_SERVER_STORE_PATH"); path != "" {
+		cfg.Store.Path = path
+	}
+	// ENV overrides for deterministic simulation
+	if err := cfg.Simulation.applyEnv(); err != nil {
+		return nil, err
+	}
+	if path := os.Getenv("CUBE_SERVER_AUDIT_PATH"); path != "" {
+		cfg.Audit.Path = path
+	}
+	// ENV token and switch for API authentication
+	applyAuthEnv(&cfg.Auth)
+	// ENV switch for S3 SigV4 verification
+	switch os.Getenv("CUBE_SERVER_S3_AUTH") {
+	case "1":
+		cfg.Storage.S3.Auth.Enabled = true
+	case "0":
+		cfg.Storage.S3.Auth.Enabled = false
+	}
 	return &cfg, nil
 }
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+// ConfigureSimulation applies the simulation settings of the config to sim: fault
+// profiles, seed and record/replay cassette. Without a config file the
+// CUBE_SERVER_SIM_SEED, CUBE_SERVER_SIM_RECORD and CUBE_SERVER_SIM_REPLAY environment
+// variables still apply.
+func ConfigureSimulation(sim *simulation.SimulationService, cfg *ServerConfig) error {
+	var settings SimulationConfig
+	if cfg != nil {
+		settings = cfg.Simulation
+	} else if err := settings.applyEnv(); err != nil {
+		return err
+	}
+	if err := sim.Faults().SetProfiles(settings.Faults); err != nil {
+		return err
+	}
+	if settings.Seed != nil {
+		sim.SetSeed(*settings.Seed)
+	}
+	switch {
+	case settings.Record != "" && settings.Replay != "":
+		return errors.New("simulation: record and replay are mutually exclusive")
+	case settings.Record != "":
+		cassette, err := simulation.NewRecordingCassette(settings.Record)
+		if err != nil {
+			return err
+		}
+		sim.SetCassette(cassette)
+	case settings.Replay != "":
+		cassette, err := simulation.LoadCassette(settings.Replay)
+		if err != nil {
+			return err
+		}
+		sim.SetCassette(cassette)
+	}
+	return nil
+}
+
+// applyEnv overrides the settings from CUBE_SERVER_SIM_SEED, CUBE_SERVER_SIM_RECORD
+// and CUBE_SERVER_SIM_REPLAY
+func (sc *SimulationConfig) applyEnv() error {
+	if v := os.Getenv("CUBE_SERVER_SIM_SEED"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid CUBE_SERVER_SIM_SEED %q: %w", v, err)
+		}
+		sc.Seed = &seed
+	}
+	if path := os.Getenv("CUBE_SERVER_SIM_RECORD"); path != "" {
+		sc.Record = path
+	}
+	if path := os.Getenv("CUBE_SERVER_SIM_REPLAY"); path != "" {
+		sc.Replay = path
+	}
+	if dir := os.Getenv("CUBE_SERVER_SIM_FIXTURES"); dir != "" {
+		sc.Fixtures = dir
+	}
+	return nil
+}
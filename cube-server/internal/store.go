@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"os"
+
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+)
+
+// DefaultStorePath is the bolt database file used when no path is configured
+const DefaultStorePath = "cube-server.db"
+
+// OpenStore opens the store backend selected in the config. Without a config file the
+// CUBE_SERVER_STORE and CUBE_SERVER_STORE_PATH environment variables still apply.
+func OpenStore(cfg *ServerConfig) (store.Store, error) {
+	backend, path := os.Getenv("CUBE_SERVER_STORE"), os.Getenv("CUBE_SERVER_STORE_PATH")
+	if cfg != nil {
+		backend, path = cfg.Store.Backend, cfg.Store.Path
+	}
+	if backend == store.BackendBolt && path == "" {
+		path = DefaultStorePath
+	}
+	return store.Open(backend, path)
+}
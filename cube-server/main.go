@@ -2,11 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -16,6 +20,10 @@ import (
 	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
 )
 
+// shutdownTimeout bounds how long in-flight requests may take once a signal asks the
+// server to stop
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	// Check for --debug flag
 	debugMode := false
@@ -55,7 +63,17 @@ func main() {
 	if debugMode {
 		logger.Info("Debug mode enabled")
 	}
+	if err := run(config, logger, debugMode); err != nil {
+		logger.Error("Cube Server failed", zap.Error(err))
+		_ = logger.Sync()
+		os.Exit(1)
+	}
+}
 
+// run serves the API until SIGINT or SIGTERM, then shuts the HTTP server down before
+// it closes the routes, the audit log and the store. Errors are returned rather than
+// fatal so those deferred closes always happen.
+func run(config *internal.ServerConfig, logger *zap.Logger, debugMode bool) error {
 	// Set up Gin router
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -65,27 +83,46 @@ func main() {
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
-	// Debug middleware: log all incoming requests (method, path, body)
-	router.Use(func(c *gin.Context) {
-		fmt.Printf("[GIN DEBUG] Incoming %s %s\n", c.Request.Method, c.Request.URL.Path)
-		// Log all registered routes for debugging
-		for _, ri := range router.Routes() {
-			logger.Info("Registered route", zap.String("method", ri.Method), zap.String("path", ri.Path))
-		}
-		if c.Request.Method == "POST" || c.Request.Method == "PUT" || c.Request.Method == "PATCH" {
-			bodyBytes, _ := c.GetRawData()
-			fmt.Printf("[GIN DEBUG] Request body: %s\n", string(bodyBytes))
-			// Restore body for downstream handlers
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-		}
-		c.Next()
-	})
+	// Debug middleware: log all incoming requests (method, path, body); request bodies
+	// may hold credentials, so only in debug mode (changes are in the audit log)
+	if debugMode {
+		router.Use(func(c *gin.Context) {
+			fmt.Printf("[GIN DEBUG] Incoming %s %s\n", c.Request.Method, c.Request.URL.Path)
+			// Log all registered routes for debugging
+			for _, ri := range router.Routes() {
+				logger.Info("Registered route", zap.String("method", ri.Method), zap.String("path", ri.Path))
+			}
+			if c.Request.Method == "POST" || c.Request.Method == "PUT" || c.Request.Method == "PATCH" {
+				bodyBytes, _ := c.GetRawData()
+				fmt.Printf("[GIN DEBUG] Request body: %s\n", string(bodyBytes))
+				// Restore body for downstream handlers
+				c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+			}
+			c.Next()
+		})
+	}
 
-	// Add CORS middleware
+	// Add CORS middleware; auth.cors_origins replaces the wildcard with a list of origins
+	var corsOrigins []string
+	if config != nil {
+		corsOrigins = config.Auth.CORSOrigins
+	}
 	router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
+		if len(corsOrigins) == 0 {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Vary", "Origin")
+			for _, origin := range corsOrigins {
+				if origin == c.GetHeader("Origin") {
+					c.Header("Access-Control-Allow-Origin", origin)
+				}
+			}
+		}
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		// The request headers of preconditions, idempotency, projects and seeded
+		// simulations, and the response headers scripts may read
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, "+api.IdempotencyHeader+", "+api.ProjectHeader+", "+api.SimulationSeedHeader+", "+api.CorrelationHeader)
+		c.Header("Access-Control-Expose-Headers", "ETag, "+api.IdempotencyReplayedHeader+", Location, "+api.CorrelationHeader)
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -109,6 +146,12 @@ func main() {
 		fastSim = true
 	}
 	sim := simulation.NewSimulationServiceWithOptions(fastSim, debugMode)
+	if err := internal.ConfigureSimulation(sim, config); err != nil {
+		return fmt.Errorf("invalid simulation settings: %w", err)
+	}
+	if cassette := sim.Cassette(); cassette != nil {
+		logger.Info("Simulation cassette", zap.String("mode", cassette.Mode()), zap.String("path", cassette.Path()))
+	}
 
 	// 3. Inject dummy buckets if needed (from config or ENV)
 	if config != nil && config.Storage.DummyBuckets != nil {
@@ -141,7 +184,52 @@ func main() {
 			c.Next()
 		})
 	}
-	api.SetupRoutes(router, nil, logger, sim)
+	dataStore, err := internal.OpenStore(config)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	if closer, ok := dataStore.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				logger.Error("Failed to close store", zap.Error(err))
+			}
+		}()
+	}
+	logger.Info("Store opened", zap.String("type", fmt.Sprintf("%T", dataStore)))
+	authenticator, err := internal.NewAuthenticator(config)
+	if err != nil {
+		return fmt.Errorf("invalid auth settings: %w", err)
+	}
+	if authenticator != nil {
+		logger.Info("API authentication enabled")
+	}
+	validationMode, err := api.ValidationMode(config)
+	if err != nil {
+		return fmt.Errorf("invalid API validation mode: %w", err)
+	}
+	logger.Info("API spec validation", zap.String("mode", string(validationMode)))
+	auditLog, err := internal.OpenAuditLog(config)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() {
+		if err := auditLog.Close(); err != nil {
+			logger.Error("Failed to close audit log", zap.Error(err))
+		}
+	}()
+	routes := api.SetupRoutesWithOptions(router, api.RouteOptions{
+		Store:  dataStore,
+		Logger: logger,
+		Sim:    sim,
+		Config: config,
+		Auth:   authenticator,
+		Audit:  auditLog,
+	})
+	defer routes.Close()
+	// Every route must be in api/openapi.yaml, which is also what /docs serves
+	if err := api.CheckRoutes(router); err != nil {
+		return fmt.Errorf("API spec self-check failed: %w", err)
+	}
 
 	// Start server
 	// Get port from --port flag or environment variable
@@ -156,9 +244,24 @@ func main() {
 	if envPort := os.Getenv("CUBE_SERVER_PORT"); envPort != "" {
 		port = envPort
 	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	served := make(chan error, 1)
+	go func() { served <- server.ListenAndServe() }()
 	logger.Info("Starting Cube Server...", zap.String("port", port))
-	if err := router.Run(":" + port); err != nil {
-		logger.Error("Server failed to start", zap.Error(err))
-		os.Exit(1)
+	select {
+	case err := <-served:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+	// A second signal ends the process at once
+	stop()
+	logger.Info("Shutting down Cube Server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server shutdown: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+// UnmatchedRoute is the route label of requests no route matched, so unknown paths
+// cannot blow up the number of series.
+const UnmatchedRoute = "unmatched"
+
+// Metrics holds the cube-server metric families.
+type Metrics struct {
+	Registry *Registry
+
+	requests        *CounterVec
+	requestDuration *HistogramVec
+	tests           *CounterVec
+	storeDuration   *HistogramVec
+}
+
+// New registers the cube-server metric families on a fresh registry.
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		Registry: r,
+		requests: r.NewCounterVec("cube_http_requests_total",
+			"HTTP requests by method, route and status code.", "method", "route", "status"),
+		requestDuration: r.NewHistogramVec("cube_http_request_duration_seconds",
+			"HTTP request latency by method, route and status code.", nil, "method", "route", "status"),
+		tests: r.NewCounterVec("cube_test_executions_total",
+			"Test executions that entered a status, by test type and status.", "type", "status"),
+		storeDuration: r.NewHistogramVec("cube_store_operation_duration_seconds",
+			"Store operation latency by operation and result (ok or error).",
+			[]float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1}, "operation", "result"),
+	}
+}
+
+// Middleware counts and times every request by its route pattern (/api/v1/clusters/:id),
+// not the path, to keep the number of series bounded.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		route := c.FullPath()
+		c.Next()
+		if c.FullPath() != route {
+			// Dispatched again through Engine.HandleContext (the project URL prefix),
+			// which counted the request under its final route
+			return
+		}
+		if route == "" {
+			route = UnmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.requests.Inc(c.Request.Method, route, status)
+		m.requestDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, status)
+	}
+}
+
+// Handler serves the registry in the text exposition format.
+func (m *Metrics) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", ContentType)
+		if err := m.Registry.Write(c.Writer); err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// TestStatusChanged counts a test entering status; it is the testrunner StatusChanged hook.
+func (m *Metrics) TestStatusChanged(testType string, status sharedmodels.TestStatus) {
+	m.tests.Inc(testType, string(status))
+}
+
+// CollectSimulation reports the operation counts of sim and the object storage totals
+// of each of its projects, read on every scrape.
+func (m *Metrics) CollectSimulation(sim *simulation.SimulationService) {
+	m.Registry.NewCounterFunc("cube_simulation_operations_total",
+		"Simulated provider operations by provider, operation and outcome (success, failure or fault).",
+		func() []Sample {
+			var samples []Sample
+			for _, op := range sim.OperationCounts() {
+				samples = append(samples, Sample{Labels: []string{op.Provider, op.Operation, op.Outcome}, Value: float64(op.Count)})
+			}
+			return samples
+		}, "provider", "operation", "outcome")
+
+	bucketGauge := func(value func(simulation.BucketStats) float64) func() []Sample {
+		return func() []Sample {
+			var samples []Sample
+			for _, project := range sim.Projects() {
+				for _, st := range sim.Project(project).BucketStore().Stats() {
+					samples = append(samples, Sample{Labels: []string{project, st.Provider}, Value: value(st)})
+				}
+			}
+			return samples
+		}
+	}
+	m.Registry.NewGaugeFunc("cube_simulation_buckets",
+		"Simulated object storage buckets by project and provider.",
+		bucketGauge(func(st simulation.BucketStats) float64 { return float64(st.Buckets) }), "project", "provider")
+	m.Registry.NewGaugeFunc("cube_simulation_objects",
+		"Objects in simulated buckets by project and provider, without deleted keys.",
+		bucketGauge(func(st simulation.BucketStats) float64 { return float64(st.Objects) }), "project", "provider")
+	m.Registry.NewGaugeFunc("cube_simulation_object_bytes",
+		"Bytes stored in simulated buckets by project and provider, noncurrent versions included.",
+		bucketGauge(func(st simulation.BucketStats) float64 { return float64(st.Bytes) }), "project", "provider")
+}
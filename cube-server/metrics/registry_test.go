@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("requests_total", "Requests.", "path")
+	c.Inc(`/a"b`)
+	c.Add(2, "/c")
+	h := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v, "get")
+	}
+	r.NewGaugeFunc("things", "Things\nby kind.", func() []Sample {
+		return []Sample{{Labels: []string{"x"}, Value: 1.5}}
+	}, "kind")
+
+	var out strings.Builder
+	if err := r.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{path="/a\"b"} 1
+requests_total{path="/c"} 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="get",le="0.1"} 2
+latency_seconds_bucket{op="get",le="1"} 3
+latency_seconds_bucket{op="get",le="+Inf"} 4
+latency_seconds_sum{op="get"} 3.65
+latency_seconds_count{op="get"} 4
+# HELP things Things\nby kind.
+# TYPE things gauge
+things{kind="x"} 1.5
+`
+	if out.String() != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", out.String(), want)
+	}
+	if c.Value("/c") != 2 || h.Count("get") != 4 {
+		t.Errorf("Value = %v, Count = %v", c.Value("/c"), h.Count("get"))
+	}
+}
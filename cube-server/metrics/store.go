@@ -0,0 +1,244 @@
+package metrics
+
+import (
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+)
+
+// Store wraps s so every operation is timed in cube_store_operation_duration_seconds.
+func (m *Metrics) Store(s store.Store) store.Store {
+	return &instrumentedStore{Store: s, m: m}
+}
+
+// instrumentedStore times the operations of the store it wraps. Operations added to
+// store.Store later pass through the embedded Store untimed until they get a method here.
+type instrumentedStore struct {
+	store.Store
+	m *Metrics
+}
+
+// timeStore runs one store operation and records its duration and result.
+func (m *Metrics) timeStore(operation string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.storeDuration.Observe(time.Since(start).Seconds(), operation, result)
+	return err
+}
+
+func timed[T any](m *Metrics, operation string, fn func() (T, error)) (T, error) {
+	var v T
+	err := m.timeStore(operation, func() (err error) {
+		v, err = fn()
+		return err
+	})
+	return v, err
+}
+
+// Cluster operations
+func (s *instrumentedStore) CreateCluster(cluster *sharedmodels.Cluster) (*sharedmodels.Cluster, error) {
+	return timed(s.m, "CreateCluster", func() (*sharedmodels.Cluster, error) { return s.Store.CreateCluster(cluster) })
+}
+
+func (s *instrumentedStore) GetCluster(id string) (*sharedmodels.Cluster, error) {
+	return timed(s.m, "GetCluster", func() (*sharedmodels.Cluster, error) { return s.Store.GetCluster(id) })
+}
+
+func (s *instrumentedStore) UpdateCluster(id string, cluster *sharedmodels.Cluster) (*sharedmodels.Cluster, error) {
+	return timed(s.m, "UpdateCluster", func() (*sharedmodels.Cluster, error) { return s.Store.UpdateCluster(id, cluster) })
+}
+
+func (s *instrumentedStore) DeleteCluster(id string) error {
+	return s.m.timeStore("DeleteCluster", func() error { return s.Store.DeleteCluster(id) })
+}
+
+func (s *instrumentedStore) ListClusters() ([]*sharedmodels.Cluster, error) {
+	return timed(s.m, "ListClusters", func() ([]*sharedmodels.Cluster, error) { return s.Store.ListClusters() })
+}
+
+func (s *instrumentedStore) ListClustersByProvider(provider sharedmodels.CloudProvider) ([]*sharedmodels.Cluster, error) {
+	return timed(s.m, "ListClustersByProvider", func() ([]*sharedmodels.Cluster, error) { return s.Store.ListClustersByProvider(provider) })
+}
+
+// Test result operations
+func (s *instrumentedStore) CreateTestResult(result *sharedmodels.TestResult) (*sharedmodels.TestResult, error) {
+	return timed(s.m, "CreateTestResult", func() (*sharedmodels.TestResult, error) { return s.Store.CreateTestResult(result) })
+}
+
+func (s *instrumentedStore) GetTestResult(id string) (*sharedmodels.TestResult, error) {
+	return timed(s.m, "GetTestResult", func() (*sharedmodels.TestResult, error) { return s.Store.GetTestResult(id) })
+}
+
+func (s *instrumentedStore) UpdateTestResult(id string, result *sharedmodels.TestResult) (*sharedmodels.TestResult, error) {
+	return timed(s.m, "UpdateTestResult", func() (*sharedmodels.TestResult, error) { return s.Store.UpdateTestResult(id, result) })
+}
+
+func (s *instrumentedStore) ListTestResults(clusterID string) ([]*sharedmodels.TestResult, error) {
+	return timed(s.m, "ListTestResults", func() ([]*sharedmodels.TestResult, error) { return s.Store.ListTestResults(clusterID) })
+}
+
+// Node pool operations
+func (s *instrumentedStore) CreateNodePool(nodePool *sharedmodels.NodePool) (*sharedmodels.NodePool, error) {
+	return timed(s.m, "CreateNodePool", func() (*sharedmodels.NodePool, error) { return s.Store.CreateNodePool(nodePool) })
+}
+
+func (s *instrumentedStore) GetNodePool(id string) (*sharedmodels.NodePool, error) {
+	return timed(s.m, "GetNodePool", func() (*sharedmodels.NodePool, error) { return s.Store.GetNodePool(id) })
+}
+
+func (s *instrumentedStore) UpdateNodePool(id string, nodePool *sharedmodels.NodePool) (*sharedmodels.NodePool, error) {
+	return timed(s.m, "UpdateNodePool", func() (*sharedmodels.NodePool, error) { return s.Store.UpdateNodePool(id, nodePool) })
+}
+
+func (s *instrumentedStore) DeleteNodePool(id string) error {
+	return s.m.timeStore("DeleteNodePool", func() error { return s.Store.DeleteNodePool(id) })
+}
+
+func (s *instrumentedStore) ListNodePools(clusterID string) ([]*sharedmodels.NodePool, error) {
+	return timed(s.m, "ListNodePools", func() ([]*sharedmodels.NodePool, error) { return s.Store.ListNodePools(clusterID) })
+}
+
+// Azure resource operations
+func (s *instrumentedStore) CreateLogAnalyticsWorkspace(workspace *sharedmodels.LogAnalyticsWorkspace) (*sharedmodels.LogAnalyticsWorkspace, error) {
+	return timed(s.m, "CreateLogAnalyticsWorkspace", func() (*sharedmodels.LogAnalyticsWorkspace, error) {
+		return s.Store.CreateLogAnalyticsWorkspace(workspace)
+	})
+}
+
+func (s *instrumentedStore) GetLogAnalyticsWorkspace(id string) (*sharedmodels.LogAnalyticsWorkspace, error) {
+	return timed(s.m, "GetLogAnalyticsWorkspace", func() (*sharedmodels.LogAnalyticsWorkspace, error) { return s.Store.GetLogAnalyticsWorkspace(id) })
+}
+
+func (s *instrumentedStore) UpdateLogAnalyticsWorkspace(id string, workspace *sharedmodels.LogAnalyticsWorkspace) (*sharedmodels.LogAnalyticsWorkspace, error) {
+	return timed(s.m, "UpdateLogAnalyticsWorkspace", func() (*sharedmodels.LogAnalyticsWorkspace, error) {
+		return s.Store.UpdateLogAnalyticsWorkspace(id, workspace)
+	})
+}
+
+func (s *instrumentedStore) DeleteLogAnalyticsWorkspace(id string) error {
+	return s.m.timeStore("DeleteLogAnalyticsWorkspace", func() error { return s.Store.DeleteLogAnalyticsWorkspace(id) })
+}
+
+func (s *instrumentedStore) ListLogAnalyticsWorkspaces() ([]*sharedmodels.LogAnalyticsWorkspace, error) {
+	return timed(s.m, "ListLogAnalyticsWorkspaces", func() ([]*sharedmodels.LogAnalyticsWorkspace, error) { return s.Store.ListLogAnalyticsWorkspaces() })
+}
+
+func (s *instrumentedStore) CreateAppInsights(app *sharedmodels.AppInsightsResource) (*sharedmodels.AppInsightsResource, error) {
+	return timed(s.m, "CreateAppInsights", func() (*sharedmodels.AppInsightsResource, error) { return s.Store.CreateAppInsights(app) })
+}
+
+func (s *instrumentedStore) GetAppInsights(id string) (*sharedmodels.AppInsightsResource, error) {
+	return timed(s.m, "GetAppInsights", func() (*sharedmodels.AppInsightsResource, error) { return s.Store.GetAppInsights(id) })
+}
+
+func (s *instrumentedStore) UpdateAppInsights(id string, app *sharedmodels.AppInsightsResource) (*sharedmodels.AppInsightsResource, error) {
+	return timed(s.m, "UpdateAppInsights", func() (*sharedmodels.AppInsightsResource, error) { return s.Store.UpdateAppInsights(id, app) })
+}
+
+func (s *instrumentedStore) DeleteAppInsights(id string) error {
+	return s.m.timeStore("DeleteAppInsights", func() error { return s.Store.DeleteAppInsights(id) })
+}
+
+func (s *instrumentedStore) ListAppInsights() ([]*sharedmodels.AppInsightsResource, error) {
+	return timed(s.m, "ListAppInsights", func() ([]*sharedmodels.AppInsightsResource, error) { return s.Store.ListAppInsights() })
+}
+
+func (s *instrumentedStore) CreateAzureBudget(budget *sharedmodels.AzureBudget) (*sharedmodels.AzureBudget, error) {
+	return timed(s.m, "CreateAzureBudget", func() (*sharedmodels.AzureBudget, error) { return s.Store.CreateAzureBudget(budget) })
+}
+
+func (s *instrumentedStore) GetAzureBudget(id string) (*sharedmodels.AzureBudget, error) {
+	return timed(s.m, "GetAzureBudget", func() (*sharedmodels.AzureBudget, error) { return s.Store.GetAzureBudget(id) })
+}
+
+func (s *instrumentedStore) UpdateAzureBudget(id string, budget *sharedmodels.AzureBudget) (*sharedmodels.AzureBudget, error) {
+	return timed(s.m, "UpdateAzureBudget", func() (*sharedmodels.AzureBudget, error) { return s.Store.UpdateAzureBudget(id, budget) })
+}
+
+func (s *instrumentedStore) DeleteAzureBudget(id string) error {
+	return s.m.timeStore("DeleteAzureBudget", func() error { return s.Store.DeleteAzureBudget(id) })
+}
+
+func (s *instrumentedStore) ListAzureBudgets() ([]*sharedmodels.AzureBudget, error) {
+	return timed(s.m, "ListAzureBudgets", func() ([]*sharedmodels.AzureBudget, error) { return s.Store.ListAzureBudgets() })
+}
+
+func (s *instrumentedStore) CreateAzureMonitoring(monitoring *sharedmodels.AzureMonitoring) (*sharedmodels.AzureMonitoring, error) {
+	return timed(s.m, "CreateAzureMonitoring", func() (*sharedmodels.AzureMonitoring, error) { return s.Store.CreateAzureMonitoring(monitoring) })
+}
+
+func (s *instrumentedStore) GetAzureMonitoring(id string) (*sharedmodels.AzureMonitoring, error) {
+	return timed(s.m, "GetAzureMonitoring", func() (*sharedmodels.AzureMonitoring, error) { return s.Store.GetAzureMonitoring(id) })
+}
+
+func (s *instrumentedStore) UpdateAzureMonitoring(id string, monitoring *sharedmodels.AzureMonitoring) (*sharedmodels.AzureMonitoring, error) {
+	return timed(s.m, "UpdateAzureMonitoring", func() (*sharedmodels.AzureMonitoring, error) { return s.Store.UpdateAzureMonitoring(id, monitoring) })
+}
+
+func (s *instrumentedStore) DeleteAzureMonitoring(id string) error {
+	return s.m.timeStore("DeleteAzureMonitoring", func() error { return s.Store.DeleteAzureMonitoring(id) })
+}
+
+func (s *instrumentedStore) ListAzureMonitorings() ([]*sharedmodels.AzureMonitoring, error) {
+	return timed(s.m, "ListAzureMonitorings", func() ([]*sharedmodels.AzureMonitoring, error) { return s.Store.ListAzureMonitorings() })
+}
+
+func (s *instrumentedStore) CreateAzureKubernetes(kubernetes *sharedmodels.AzureKubernetes) (*sharedmodels.AzureKubernetes, error) {
+	return timed(s.m, "CreateAzureKubernetes", func() (*sharedmodels.AzureKubernetes, error) { return s.Store.CreateAzureKubernetes(kubernetes) })
+}
+
+func (s *instrumentedStore) GetAzureKubernetes(id string) (*sharedmodels.AzureKubernetes, error) {
+	return timed(s.m, "GetAzureKubernetes", func() (*sharedmodels.AzureKubernetes, error) { return s.Store.GetAzureKubernetes(id) })
+}
+
+func (s *instrumentedStore) UpdateAzureKubernetes(id string, kubernetes *sharedmodels.AzureKubernetes) (*sharedmodels.AzureKubernetes, error) {
+	return timed(s.m, "UpdateAzureKubernetes", func() (*sharedmodels.AzureKubernetes, error) { return s.Store.UpdateAzureKubernetes(id, kubernetes) })
+}
+
+func (s *instrumentedStore) DeleteAzureKubernetes(id string) error {
+	return s.m.timeStore("DeleteAzureKubernetes", func() error { return s.Store.DeleteAzureKubernetes(id) })
+}
+
+func (s *instrumentedStore) ListAzureKubernetes() ([]*sharedmodels.AzureKubernetes, error) {
+	return timed(s.m, "ListAzureKubernetes", func() ([]*sharedmodels.AzureKubernetes, error) { return s.Store.ListAzureKubernetes() })
+}
+
+// Project operations
+func (s *instrumentedStore) CreateProject(project *sharedmodels.Project) (*sharedmodels.Project, error) {
+	return timed(s.m, "CreateProject", func() (*sharedmodels.Project, error) { return s.Store.CreateProject(project) })
+}
+
+func (s *instrumentedStore) GetProject(name string) (*sharedmodels.Project, error) {
+	return timed(s.m, "GetProject", func() (*sharedmodels.Project, error) { return s.Store.GetProject(name) })
+}
+
+func (s *instrumentedStore) UpdateProject(name string, project *sharedmodels.Project) (*sharedmodels.Project, error) {
+	return timed(s.m, "UpdateProject", func() (*sharedmodels.Project, error) { return s.Store.UpdateProject(name, project) })
+}
+
+func (s *instrumentedStore) DeleteProject(name string) error {
+	return s.m.timeStore("DeleteProject", func() error { return s.Store.DeleteProject(name) })
+}
+
+func (s *instrumentedStore) ListProjects() ([]*sharedmodels.Project, error) {
+	return timed(s.m, "ListProjects", func() ([]*sharedmodels.Project, error) { return s.Store.ListProjects() })
+}
+
+// Project wraps the project's view, so its operations are timed as well
+func (s *instrumentedStore) Project(name string) store.Store {
+	return &instrumentedStore{Store: s.Store.Project(name), m: s.m}
+}
+
+// Snapshot operations
+func (s *instrumentedStore) Snapshot() (*store.Snapshot, error) {
+	return timed(s.m, "Snapshot", func() (*store.Snapshot, error) { return s.Store.Snapshot() })
+}
+
+func (s *instrumentedStore) Restore(snapshot *store.Snapshot) error {
+	return s.m.timeStore("Restore", func() error { return s.Store.Restore(snapshot) })
+}
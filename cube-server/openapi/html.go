@@ -0,0 +1,170 @@
+package openapi
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteHTML renders the spec as a single-page reference
+func (s *Spec) WriteHTML(w io.Writer) error {
+	return referenceTemplate.Execute(w, s.reference())
+}
+
+type reference struct {
+	Info    Info
+	Servers []Server
+	Groups  []group
+	Schemas []namedSchema
+}
+
+type group struct {
+	Tag
+	Operations []*Operation
+}
+
+type namedSchema struct {
+	Name string
+	*Schema
+}
+
+// reference groups the operations by their first tag, in the order of the spec's tags
+func (s *Spec) reference() reference {
+	byTag := make(map[string][]*Operation)
+	for _, op := range s.Operations() {
+		tag := "Other"
+		if len(op.Tags) > 0 {
+			tag = op.Tags[0]
+		}
+		byTag[tag] = append(byTag[tag], op)
+	}
+	ref := reference{Info: s.Info, Servers: s.Servers}
+	for _, tag := range s.Tags {
+		if ops, ok := byTag[tag.Name]; ok {
+			ref.Groups = append(ref.Groups, group{Tag: tag, Operations: ops})
+			delete(byTag, tag.Name)
+		}
+	}
+	var rest []string
+	for name := range byTag {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		ref.Groups = append(ref.Groups, group{Tag: Tag{Name: name}, Operations: byTag[name]})
+	}
+	for name, schema := range s.Components.Schemas {
+		ref.Schemas = append(ref.Schemas, namedSchema{Name: name, Schema: schema})
+	}
+	sort.Slice(ref.Schemas, func(i, j int) bool { return ref.Schemas[i].Name < ref.Schemas[j].Name })
+	return ref
+}
+
+// typeName describes a schema in one line: Cluster, array of Cluster, string (date-time)
+func typeName(s *Schema) string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Ref != "":
+		return s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+	case s.Type == "array":
+		return "array of " + typeName(s.Items)
+	case s.Type == "object" && s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+		return "map of " + typeName(s.AdditionalProperties.Schema)
+	case s.Type == "":
+		return "any"
+	case s.Format != "":
+		return s.Type + " (" + s.Format + ")"
+	}
+	return s.Type
+}
+
+func sortedProperties(s *Schema) []namedSchema {
+	props := make([]namedSchema, 0, len(s.Properties))
+	for name, prop := range s.Properties {
+		props = append(props, namedSchema{Name: name, Schema: prop})
+	}
+	sort.Slice(props, func(i, j int) bool { return props[i].Name < props[j].Name })
+	return props
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func anchor(s string) string {
+	return strings.NewReplacer("/", "-", "{", "", "}", "", " ", "-").Replace(strings.ToLower(s))
+}
+
+func required(s *Schema, name string) bool {
+	for _, r := range s.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+var referenceTemplate = template.Must(template.New("reference").Funcs(template.FuncMap{
+	"typeName":   typeName,
+	"properties": sortedProperties,
+	"required":   required,
+	"anchor":     anchor,
+	"lower":      strings.ToLower,
+	"mediaTypes": sortedKeys[*MediaType],
+	"codes":      sortedKeys[*Response],
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Info.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; color: #222; }
+code, .path { font-family: monospace; }
+.op { border: 1px solid #ddd; border-radius: 4px; margin: 1em 0; padding: 0.5em 1em; }
+.method { display: inline-block; min-width: 5em; font-weight: bold; font-family: monospace; }
+.get { color: #1a7f37; } .post { color: #0969da; } .put { color: #9a6700; } .delete { color: #cf222e; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th, td { text-align: left; padding: 0.2em 0.8em 0.2em 0; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>{{.Info.Title}} <small>{{.Info.Version}}</small></h1>
+<p>{{.Info.Description}}</p>
+<p>Machine-readable spec: <a href="/docs/openapi.yaml">openapi.yaml</a>, <a href="/docs/openapi.json">openapi.json</a></p>
+<ul>{{range .Groups}}<li><a href="#{{anchor .Name}}">{{.Name}}</a></li>{{end}}<li><a href="#schemas">Schemas</a></li></ul>
+{{range .Groups}}
+<h2 id="{{anchor .Name}}">{{.Name}}</h2>
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{range .Operations}}
+<div class="op" id="{{anchor .Method}}-{{anchor .Path}}">
+<p><span class="method {{lower .Method}}">{{.Method}}</span> <span class="path">{{.Path}}</span></p>
+{{with .Summary}}<p><strong>{{.}}</strong></p>{{end}}
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{with .Parameters}}<table><tr><th>Parameter</th><th>In</th><th>Type</th><th>Description</th></tr>
+{{range .}}<tr><td><code>{{.Name}}</code>{{if .Required}} *{{end}}</td><td>{{.In}}</td><td>{{typeName .Schema}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>{{end}}
+{{with .RequestBody}}{{$body := .}}<p>Request body{{if .Required}} (required){{end}}: {{range mediaTypes .Content}}<code>{{.}}</code> {{with index $body.Content .}}{{typeName .Schema}}{{end}} {{end}}</p>{{end}}
+<table><tr><th>Status</th><th>Description</th><th>Body</th></tr>
+{{$op := .}}{{range codes .Responses}}{{$r := index $op.Responses .}}<tr><td>{{.}}</td><td>{{$r.Description}}</td><td>{{range mediaTypes $r.Content}}{{with index $r.Content .}}{{typeName .Schema}}{{end}} {{end}}</td></tr>
+{{end}}</table>
+</div>
+{{end}}
+{{end}}
+<h2 id="schemas">Schemas</h2>
+{{range .Schemas}}
+<h3 id="schema-{{anchor .Name}}">{{.Name}}</h3>
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{$s := .Schema}}{{with properties .Schema}}<table><tr><th>Property</th><th>Type</th><th>Description</th></tr>
+{{range .}}<tr><td><code>{{.Name}}</code>{{if required $s .Name}} *{{end}}</td><td>{{typeName .Schema}}{{with .Enum}} {{.}}{{end}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>{{else}}<p>{{typeName .Schema}}</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,176 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Mode selects what the middleware does about requests and responses that do not
+// match the spec
+type Mode string
+
+const (
+	// Strict answers invalid requests with 400 and replaces invalid JSON responses
+	// with 500, so tests fail on any drift between handlers and spec
+	Strict Mode = "strict"
+	// LogOnly logs invalid requests and responses and lets them through
+	LogOnly Mode = "log"
+	// Off skips validation
+	Off Mode = "off"
+)
+
+// ParseMode reads a mode from config; an empty string stays empty, for the caller's default
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "", Strict, LogOnly, Off:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown API validation mode %q (want strict, log or off)", s)
+	}
+}
+
+// MaxBodySize is the largest JSON body validated; larger bodies pass unchecked
+const MaxBodySize = 1 << 20
+
+// Middleware validates every request to a documented route and its response.
+// Routes without an operation are left alone (see Undocumented for the startup check).
+func (s *Spec) Middleware(mode Mode, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		op := s.Operation(c.Request.Method, route)
+		if mode == Off || op == nil {
+			c.Next()
+			return
+		}
+
+		if err := s.validateRequest(c, op); err != nil {
+			if mode == Strict {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "request does not match the API spec: " + err.Error()})
+				return
+			}
+			logger.Warn("Request does not match the API spec", zap.String("method", op.Method),
+				zap.String("path", op.Path), zap.Error(err))
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer, buffer: mode == Strict}
+		c.Writer = w
+		c.Next()
+		if c.FullPath() != route {
+			// Dispatched again through Engine.HandleContext (the project URL prefix),
+			// which validated the request under its final route
+			return
+		}
+		c.Writer = w.ResponseWriter
+
+		var body []byte
+		if !w.tooLarge {
+			body = w.body.Bytes()
+		}
+		err := s.ValidateResponse(op, w.Status(), w.Header().Get("Content-Type"), body)
+		switch {
+		case err == nil:
+		case mode == Strict && (w.buffering || !w.ResponseWriter.Written()):
+			w.body.Reset()
+			w.Header().Del("Content-Length")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "response does not match the API spec: " + err.Error()})
+		default:
+			logger.Warn("Response does not match the API spec", zap.String("method", op.Method),
+				zap.String("path", op.Path), zap.Int("status", w.Status()), zap.Error(err))
+		}
+		if w.buffering && w.body.Len() > 0 {
+			_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		}
+	}
+}
+
+func (s *Spec) validateRequest(c *gin.Context, op *Operation) error {
+	params := make(map[string]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = strings.TrimPrefix(p.Value, "/")
+	}
+	var body []byte
+	if op.RequestBody != nil && c.Request.Body != nil && c.Request.ContentLength <= MaxBodySize {
+		var err error
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			return err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return s.ValidateRequest(op, params, c.Request.URL.Query(), c.GetHeader("Content-Type"), body)
+}
+
+// recordingWriter keeps a copy of JSON response bodies for validation. When buffer is
+// set it holds them back instead, so an invalid response can still be replaced.
+// Other bodies (S3 XML, event streams, WebSocket upgrades) go straight through.
+type recordingWriter struct {
+	gin.ResponseWriter
+	buffer bool
+
+	decided   bool
+	recording bool
+	buffering bool
+	tooLarge  bool
+	body      bytes.Buffer
+}
+
+func (w *recordingWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	w.recording = isJSON(mediaType)
+	w.buffering = w.recording && w.buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.recording && !w.tooLarge {
+		if w.body.Len()+len(data) > MaxBodySize && !w.buffering {
+			w.tooLarge = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
+	if w.buffering {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the header unless the response is held back
+func (w *recordingWriter) WriteHeaderNow() {
+	w.decide()
+	if !w.buffering {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *recordingWriter) Written() bool {
+	return w.ResponseWriter.Written() || w.body.Len() > 0
+}
+
+func (w *recordingWriter) Size() int {
+	if w.buffering {
+		return w.body.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *recordingWriter) Flush() {
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
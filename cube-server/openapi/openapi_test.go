@@ -0,0 +1,280 @@
+package openapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const testSpec = `
+openapi: 3.0.3
+info:
+  title: Test
+  version: "1"
+paths:
+  /things:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Thing'
+      responses:
+        '201':
+          description: Created
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Thing'
+  /things/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: string
+          pattern: '^t-[0-9]+$'
+    get:
+      parameters:
+        - name: verbose
+          in: query
+          schema:
+            type: boolean
+      responses:
+        '200':
+          description: The thing
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Thing'
+components:
+  schemas:
+    Error:
+      type: object
+      required: [error]
+      properties:
+        error:
+          type: string
+    Thing:
+      type: object
+      required: [name]
+      additionalProperties: false
+      properties:
+        name:
+          type: string
+          minLength: 1
+        size:
+          type: integer
+          minimum: 1
+        kind:
+          type: string
+          enum: [small, large]
+        created_at:
+          type: string
+          format: date-time
+        tags:
+          type: array
+          nullable: true
+          items:
+            type: string
+`
+
+func TestParse(t *testing.T) {
+	spec, err := Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := spec.Operation(http.MethodGet, "/things/:id")
+	if op == nil || op.Path != "/things/{id}" || len(op.Parameters) != 2 {
+		t.Fatalf("Operation(GET /things/:id) = %+v", op)
+	}
+	if got := spec.Operation(http.MethodDelete, "/things/:id"); got != nil {
+		t.Errorf("Operation(DELETE /things/:id) = %+v, want nil", got)
+	}
+
+	for name, doc := range map[string]string{
+		"swagger 2":       "swagger: '2.0'\npaths: {}",
+		"dangling ref":    strings.Replace(testSpec, "#/components/schemas/Thing'\n      responses", "#/components/schemas/Missing'\n      responses", 1),
+		"undeclared path": strings.Replace(testSpec, "name: id\n", "name: key\n", 1),
+		"invalid pattern": strings.Replace(testSpec, "'^t-[0-9]+$'", "'^t-[0-9+$'", 1),
+		"not yaml":        "openapi: [",
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: Parse accepted the spec", name)
+		}
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	spec := MustParse([]byte(testSpec))
+	create := spec.Operation(http.MethodPost, "/things")
+	get := spec.Operation(http.MethodGet, "/things/:id")
+
+	for _, tc := range []struct {
+		name    string
+		op      *Operation
+		params  map[string]string
+		query   string
+		body    string
+		problem string
+	}{
+		{name: "valid body", op: create, body: `{"name": "a", "size": 2, "kind": "small", "created_at": "2026-01-02T03:04:05Z", "tags": null}`},
+		{name: "missing body", op: create, problem: "body: is required"},
+		{name: "not json", op: create, body: `{`, problem: "body: is not valid JSON"},
+		{name: "missing property", op: create, body: `{"size": 2}`, problem: `body: missing required property "name"`},
+		{name: "wrong type", op: create, body: `{"name": "a", "size": "2"}`, problem: "body.size: must be a number"},
+		{name: "fraction", op: create, body: `{"name": "a", "size": 1.5}`, problem: "body.size: must be an integer"},
+		{name: "below minimum", op: create, body: `{"name": "a", "size": 0}`, problem: "body.size: must be at least 1"},
+		{name: "too short", op: create, body: `{"name": ""}`, problem: "body.name: must be at least 1 characters"},
+		{name: "enum", op: create, body: `{"name": "a", "kind": "huge"}`, problem: "body.kind: must be one of"},
+		{name: "date-time", op: create, body: `{"name": "a", "created_at": "yesterday"}`, problem: "body.created_at: must be an RFC 3339 date-time"},
+		{name: "array items", op: create, body: `{"name": "a", "tags": ["x", 1]}`, problem: "body.tags[1]: must be a string"},
+		{name: "additional property", op: create, body: `{"name": "a", "color": "red"}`, problem: `body: unknown property "color"`},
+		{name: "valid params", op: get, params: map[string]string{"id": "t-1"}, query: "verbose=true"},
+		{name: "path pattern", op: get, params: map[string]string{"id": "x"}, problem: "path.id: must match"},
+		{name: "query type", op: get, params: map[string]string{"id": "t-1"}, query: "verbose=sometimes", problem: "query.verbose: must be a boolean"},
+	} {
+		query, _ := url.ParseQuery(tc.query)
+		err := spec.ValidateRequest(tc.op, tc.params, query, "application/json", []byte(tc.body))
+		switch {
+		case tc.problem == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.problem != "" && (err == nil || !strings.Contains(err.Error(), tc.problem)):
+			t.Errorf("%s: error %v, want %q", tc.name, err, tc.problem)
+		}
+	}
+
+	if err := spec.ValidateRequest(create, nil, nil, "text/plain", []byte("name=a")); err == nil || !strings.Contains(err.Error(), "content type text/plain") {
+		t.Errorf("unlisted content type: %v", err)
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec := MustParse([]byte(testSpec))
+	get := spec.Operation(http.MethodGet, "/things/:id")
+
+	if err := spec.ValidateResponse(get, http.StatusOK, "application/json; charset=utf-8", []byte(`{"name": "a"}`)); err != nil {
+		t.Errorf("valid response: %v", err)
+	}
+	if err := spec.ValidateResponse(get, http.StatusOK, "application/json", []byte(`{"size": 1}`)); err == nil {
+		t.Error("invalid response accepted")
+	}
+	// Undocumented errors must look like the Error schema
+	if err := spec.ValidateResponse(get, http.StatusNotFound, "application/json", []byte(`{"error": "not found"}`)); err != nil {
+		t.Errorf("undocumented 404: %v", err)
+	}
+	if err := spec.ValidateResponse(get, http.StatusNotFound, "application/json", []byte(`{"message": "not found"}`)); err == nil {
+		t.Error("undocumented 404 without error accepted")
+	}
+	if err := spec.ValidateResponse(get, http.StatusAccepted, "application/json", nil); err == nil || !strings.Contains(err.Error(), "status 202 is not documented") {
+		t.Errorf("undocumented 202: %v", err)
+	}
+}
+
+func newTestRouter(spec *Spec, mode Mode, logger *zap.Logger, thing string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(spec.Middleware(mode, logger))
+	r.GET("/things/:id", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(thing))
+	})
+	r.POST("/things", func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, body)
+	})
+	r.GET("/other", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"anything": true})
+	})
+	return r
+}
+
+func serve(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddlewareStrict(t *testing.T) {
+	spec := MustParse([]byte(testSpec))
+	r := newTestRouter(spec, Strict, zap.NewNop(), `{"name": "a"}`)
+
+	if w := serve(r, http.MethodPost, "/things", `{"name": "a", "size": 3}`); w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"size":3`) {
+		t.Errorf("valid request: %d %s", w.Code, w.Body)
+	}
+	if w := serve(r, http.MethodPost, "/things", `{"size": 3}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "request does not match the API spec") {
+		t.Errorf("invalid request: %d %s", w.Code, w.Body)
+	}
+	if w := serve(r, http.MethodGet, "/other", ""); w.Code != http.StatusOK {
+		t.Errorf("undocumented route: %d %s", w.Code, w.Body)
+	}
+
+	bad := newTestRouter(spec, Strict, zap.NewNop(), `{"name": 7}`)
+	if w := serve(bad, http.MethodGet, "/things/t-1", ""); w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "response does not match the API spec") {
+		t.Errorf("invalid response: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestMiddlewareLogOnly(t *testing.T) {
+	spec := MustParse([]byte(testSpec))
+	var logs bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&logs), zap.WarnLevel)
+	r := newTestRouter(spec, LogOnly, zap.New(core), `{"name": 7}`)
+
+	if w := serve(r, http.MethodPost, "/things", `{"size": 3}`); w.Code != http.StatusCreated {
+		t.Errorf("invalid request in log mode: %d %s", w.Code, w.Body)
+	}
+	if w := serve(r, http.MethodGet, "/things/t-1", ""); w.Code != http.StatusOK || w.Body.String() != `{"name": 7}` {
+		t.Errorf("invalid response in log mode: %d %s", w.Code, w.Body)
+	}
+	if strings.Count(logs.String(), "Request does not match the API spec") != 1 || strings.Count(logs.String(), "Response does not match the API spec") != 2 {
+		t.Errorf("logged %s", logs.String())
+	}
+
+	off := newTestRouter(spec, Off, zap.NewNop(), `{"name": 7}`)
+	if w := serve(off, http.MethodPost, "/things", `{"size": 3}`); w.Code != http.StatusCreated {
+		t.Errorf("invalid request with validation off: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestRoutesCheck(t *testing.T) {
+	spec := MustParse([]byte(testSpec))
+	r := newTestRouter(spec, Off, zap.NewNop(), "")
+
+	if got := spec.Undocumented(r.Routes()); len(got) != 1 || got[0] != "GET /other" {
+		t.Errorf("Undocumented = %v", got)
+	}
+	if got := spec.Unrouted(r.Routes()); len(got) != 0 {
+		t.Errorf("Unrouted = %v", got)
+	}
+	if err := spec.CheckRoutes(r.Routes()); err == nil || !strings.Contains(err.Error(), "GET /other") {
+		t.Errorf("CheckRoutes = %v", err)
+	}
+	if got := PathOf("/files/:bucket/*path"); got != "/files/{bucket}/{path}" {
+		t.Errorf("PathOf = %s", got)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"": "", "strict": Strict, " LOG ": LogOnly, "off": Off} {
+		if got, err := ParseMode(in); got != want || err != nil {
+			t.Errorf("ParseMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseMode("warn"); err == nil {
+		t.Error("ParseMode(warn) accepted")
+	}
+}
@@ -0,0 +1,48 @@
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Undocumented returns the routes, as "METHOD /path", that have no operation in the
+// spec. CONNECT is skipped: gin registers it for Any routes, OpenAPI cannot describe it.
+func (s *Spec) Undocumented(routes gin.RoutesInfo) []string {
+	var missing []string
+	for _, route := range routes {
+		if route.Method == "CONNECT" {
+			continue
+		}
+		if s.Operation(route.Method, route.Path) == nil {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Unrouted returns the operations, as "METHOD /path", that no route serves
+func (s *Spec) Unrouted(routes gin.RoutesInfo) []string {
+	served := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		served[route.Method+" "+PathOf(route.Path)] = true
+	}
+	var missing []string
+	for _, op := range s.Operations() {
+		if !served[op.Method+" "+op.Path] {
+			missing = append(missing, op.Method+" "+op.Path)
+		}
+	}
+	return missing
+}
+
+// CheckRoutes fails if a route has no operation in the spec
+func (s *Spec) CheckRoutes(routes gin.RoutesInfo) error {
+	if missing := s.Undocumented(routes); len(missing) > 0 {
+		return fmt.Errorf("%d routes missing from the API spec: %s", len(missing), strings.Join(missing, ", "))
+	}
+	return nil
+}
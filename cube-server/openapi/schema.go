@@ -0,0 +1,239 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is the subset of the OpenAPI 3.0 schema object cube-server validates:
+// types, formats, enums, required and nested properties, items and bounds.
+// Keywords outside the subset are accepted and ignored.
+type Schema struct {
+	Ref                  string             `yaml:"$ref"`
+	Type                 string             `yaml:"type"`
+	Format               string             `yaml:"format"`
+	Description          string             `yaml:"description"`
+	Nullable             bool               `yaml:"nullable"`
+	Enum                 []interface{}      `yaml:"enum"`
+	Required             []string           `yaml:"required"`
+	Properties           map[string]*Schema `yaml:"properties"`
+	AdditionalProperties *Additional        `yaml:"additionalProperties"`
+	Items                *Schema            `yaml:"items"`
+	Minimum              *float64           `yaml:"minimum"`
+	Maximum              *float64           `yaml:"maximum"`
+	MinLength            *int               `yaml:"minLength"`
+	MaxLength            *int               `yaml:"maxLength"`
+	Pattern              string             `yaml:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+// Additional is additionalProperties: either false (no other properties) or the
+// schema of the other properties. true and a missing keyword allow anything.
+type Additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+// UnmarshalYAML accepts both the boolean and the schema form
+func (a *Additional) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		a.Schema = nil
+		return node.Decode(&a.Allowed)
+	}
+	a.Allowed = true
+	return node.Decode(&a.Schema)
+}
+
+// ValidationError lists where a value does not match its schema
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// validator walks a value along a schema, resolving $refs in the spec's components
+type validator struct {
+	spec     *Spec
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "$"
+	}
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// validate checks value, as decoded by encoding/json, against s. nullable next to a
+// $ref allows null for that use of the referenced schema.
+func (v *validator) validate(path string, s *Schema, value interface{}) {
+	if value == nil && s != nil && s.Nullable {
+		return
+	}
+	s = v.spec.resolve(s)
+	if s == nil {
+		return
+	}
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			v.fail(path, "must be %s, not null", s.Type)
+		}
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		v.fail(path, "must be one of %v", s.Enum)
+		return
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(path, "must be an object")
+			return
+		}
+		v.validateObject(path, s, obj)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "must be an array")
+			return
+		}
+		for i, item := range items {
+			v.validate(fmt.Sprintf("%s[%d]", path, i), s.Items, item)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			v.fail(path, "must be a string")
+			return
+		}
+		v.validateString(path, s, str)
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			v.fail(path, "must be a number")
+			return
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			v.fail(path, "must be an integer")
+			return
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			v.fail(path, "must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			v.fail(path, "must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "must be a boolean")
+		}
+	case "":
+		// any value; objects are still checked against their properties
+		if obj, ok := value.(map[string]interface{}); ok && (len(s.Properties) > 0 || len(s.Required) > 0) {
+			v.validateObject(path, s, obj)
+		}
+	}
+}
+
+func (v *validator) validateObject(path string, s *Schema, obj map[string]interface{}) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			v.fail(path, "missing required property %q", name)
+		}
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if prop, ok := s.Properties[name]; ok {
+			v.validate(path+"."+name, prop, obj[name])
+			continue
+		}
+		switch {
+		case s.AdditionalProperties == nil:
+		case s.AdditionalProperties.Schema != nil:
+			v.validate(path+"."+name, s.AdditionalProperties.Schema, obj[name])
+		case !s.AdditionalProperties.Allowed:
+			v.fail(path, "unknown property %q", name)
+		}
+	}
+}
+
+func (v *validator) validateString(path string, s *Schema, str string) {
+	if s.MinLength != nil && len(str) < *s.MinLength {
+		v.fail(path, "must be at least %d characters", *s.MinLength)
+	}
+	if s.MaxLength != nil && len(str) > *s.MaxLength {
+		v.fail(path, "must be at most %d characters", *s.MaxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		v.fail(path, "must match %s", s.Pattern)
+	}
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+			v.fail(path, "must be an RFC 3339 date-time")
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", str); err != nil {
+			v.fail(path, "must be a date (YYYY-MM-DD)")
+		}
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		// YAML integers decode as int, JSON numbers as float64
+		if n, ok := e.(int); ok {
+			e = float64(n)
+		}
+		if e == value {
+			return true
+		}
+	}
+	return false
+}
+
+// compile prepares the patterns of s and every schema below it
+func (s *Schema) compile() error {
+	if s == nil {
+		return nil
+	}
+	if s.Pattern != "" && s.pattern == nil {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+	for _, prop := range s.Properties {
+		if err := prop.compile(); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalProperties != nil {
+		if err := s.AdditionalProperties.Schema.compile(); err != nil {
+			return err
+		}
+	}
+	return s.Items.compile()
+}
@@ -0,0 +1,333 @@
+// Package openapi loads the cube-server OpenAPI 3.0 spec and checks requests,
+// responses and the registered gin routes against it. It implements the subset of
+// OpenAPI the spec uses (see Schema) rather than the whole standard.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Methods are the HTTP methods a path item can describe, in the order the
+// reference lists them
+var Methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "TRACE"}
+
+// Spec is a parsed OpenAPI document
+type Spec struct {
+	OpenAPI    string               `yaml:"openapi"`
+	Info       Info                 `yaml:"info"`
+	Servers    []Server             `yaml:"servers"`
+	Tags       []Tag                `yaml:"tags"`
+	Paths      map[string]*PathItem `yaml:"paths"`
+	Components Components           `yaml:"components"`
+
+	raw        []byte
+	operations map[string]*Operation // by "METHOD /path"
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Version     string `yaml:"version"`
+}
+
+// Server is a base URL of the API
+type Server struct {
+	URL         string `yaml:"url"`
+	Description string `yaml:"description"`
+}
+
+// Tag groups operations in the reference
+type Tag struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+// PathItem holds the operations of one path
+type PathItem struct {
+	Parameters []*Parameter `yaml:"parameters"`
+	Get        *Operation   `yaml:"get"`
+	Head       *Operation   `yaml:"head"`
+	Post       *Operation   `yaml:"post"`
+	Put        *Operation   `yaml:"put"`
+	Patch      *Operation   `yaml:"patch"`
+	Delete     *Operation   `yaml:"delete"`
+	Options    *Operation   `yaml:"options"`
+	Trace      *Operation   `yaml:"trace"`
+}
+
+func (p *PathItem) operation(method string) *Operation {
+	switch method {
+	case "GET":
+		return p.Get
+	case "HEAD":
+		return p.Head
+	case "POST":
+		return p.Post
+	case "PUT":
+		return p.Put
+	case "PATCH":
+		return p.Patch
+	case "DELETE":
+		return p.Delete
+	case "OPTIONS":
+		return p.Options
+	case "TRACE":
+		return p.Trace
+	}
+	return nil
+}
+
+// Operation is one method of a path
+type Operation struct {
+	Summary     string               `yaml:"summary"`
+	Description string               `yaml:"description"`
+	Tags        []string             `yaml:"tags"`
+	Parameters  []*Parameter         `yaml:"parameters"`
+	RequestBody *RequestBody         `yaml:"requestBody"`
+	Responses   map[string]*Response `yaml:"responses"`
+
+	// Method and Path identify the operation; set by Parse
+	Method string `yaml:"-"`
+	Path   string `yaml:"-"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Ref         string  `yaml:"$ref"`
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description"`
+	Required    bool    `yaml:"required"`
+	Schema      *Schema `yaml:"schema"`
+}
+
+// RequestBody describes the body of a request by media type
+type RequestBody struct {
+	Description string                `yaml:"description"`
+	Required    bool                  `yaml:"required"`
+	Content     map[string]*MediaType `yaml:"content"`
+}
+
+// Response describes one status code of an operation
+type Response struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Content     map[string]*MediaType `yaml:"content"`
+}
+
+// MediaType is the schema of a body in one media type
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+// Components holds the definitions $refs point to
+type Components struct {
+	Schemas    map[string]*Schema    `yaml:"schemas"`
+	Parameters map[string]*Parameter `yaml:"parameters"`
+	Responses  map[string]*Response  `yaml:"responses"`
+}
+
+// Parse reads an OpenAPI document and checks that its $refs resolve
+func Parse(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("parse OpenAPI spec: %w", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", spec.OpenAPI)
+	}
+	spec.raw = data
+	spec.operations = make(map[string]*Operation)
+	for path, item := range spec.Paths {
+		for _, method := range Methods {
+			op := item.operation(method)
+			if op == nil {
+				continue
+			}
+			op.Method, op.Path = method, path
+			op.Parameters = mergeParameters(item.Parameters, op.Parameters)
+			spec.operations[method+" "+path] = op
+			if err := spec.check(op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+		}
+	}
+	for name, schema := range spec.Components.Schemas {
+		if err := spec.checkSchema(schema); err != nil {
+			return nil, fmt.Errorf("components.schemas.%s: %w", name, err)
+		}
+	}
+	return &spec, nil
+}
+
+// MustParse is Parse for the spec compiled into the binary; it panics on errors
+func MustParse(data []byte) *Spec {
+	spec, err := Parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+// mergeParameters adds the path item's parameters the operation does not override
+func mergeParameters(shared, own []*Parameter) []*Parameter {
+	merged := append([]*Parameter(nil), own...)
+	for _, p := range shared {
+		overridden := false
+		for _, o := range own {
+			overridden = overridden || (o.Ref == "" && o.Name == p.Name && o.In == p.In)
+		}
+		if !overridden {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// check resolves every $ref of an operation and compiles its schemas
+func (s *Spec) check(op *Operation) error {
+	if len(op.Responses) == 0 {
+		return fmt.Errorf("no responses")
+	}
+	for i, p := range op.Parameters {
+		resolved := s.parameter(p)
+		if resolved == nil {
+			return fmt.Errorf("unknown parameter %s", p.Ref)
+		}
+		if resolved.In == "path" && !strings.Contains(op.Path, "{"+resolved.Name+"}") {
+			return fmt.Errorf("path parameter %q is not in the path", resolved.Name)
+		}
+		if err := s.checkSchema(resolved.Schema); err != nil {
+			return fmt.Errorf("parameter %s: %w", resolved.Name, err)
+		}
+		op.Parameters[i] = resolved
+	}
+	if op.RequestBody != nil {
+		for mediaType, content := range op.RequestBody.Content {
+			if err := s.checkSchema(content.Schema); err != nil {
+				return fmt.Errorf("request body %s: %w", mediaType, err)
+			}
+		}
+	}
+	for code, response := range op.Responses {
+		resolved := s.response(response)
+		if resolved == nil {
+			return fmt.Errorf("response %s: unknown response %s", code, response.Ref)
+		}
+		for mediaType, content := range resolved.Content {
+			if err := s.checkSchema(content.Schema); err != nil {
+				return fmt.Errorf("response %s %s: %w", code, mediaType, err)
+			}
+		}
+		op.Responses[code] = resolved
+	}
+	return nil
+}
+
+func (s *Spec) checkSchema(schema *Schema) error {
+	if schema == nil {
+		return nil
+	}
+	if schema.Ref != "" {
+		if s.resolve(schema) == nil {
+			return fmt.Errorf("unknown schema %s", schema.Ref)
+		}
+		return nil
+	}
+	for name, prop := range schema.Properties {
+		if err := s.checkSchema(prop); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if schema.AdditionalProperties != nil {
+		if err := s.checkSchema(schema.AdditionalProperties.Schema); err != nil {
+			return err
+		}
+	}
+	if err := s.checkSchema(schema.Items); err != nil {
+		return err
+	}
+	return schema.compile()
+}
+
+// resolve follows a schema's $ref into components.schemas
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for depth := 0; schema != nil && schema.Ref != "" && depth < 10; depth++ {
+		schema = s.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (s *Spec) parameter(p *Parameter) *Parameter {
+	if p.Ref == "" {
+		return p
+	}
+	return s.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+}
+
+func (s *Spec) response(r *Response) *Response {
+	if r.Ref == "" {
+		return r
+	}
+	return s.Components.Responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]
+}
+
+// Operation returns the operation of a gin route (/api/v1/clusters/:id), or nil
+func (s *Spec) Operation(method, route string) *Operation {
+	return s.operations[method+" "+PathOf(route)]
+}
+
+// Operations returns every operation, sorted by path and method
+func (s *Spec) Operations() []*Operation {
+	ops := make([]*Operation, 0, len(s.operations))
+	for _, op := range s.operations {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return methodIndex(ops[i].Method) < methodIndex(ops[j].Method)
+	})
+	return ops
+}
+
+func methodIndex(method string) int {
+	for i, m := range Methods {
+		if m == method {
+			return i
+		}
+	}
+	return len(Methods)
+}
+
+// PathOf turns a gin route into an OpenAPI path: :id and *path become {id} and {path}
+func PathOf(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// YAML returns the document as it was parsed
+func (s *Spec) YAML() []byte {
+	return s.raw
+}
+
+// JSON returns the document converted to JSON
+func (s *Spec) JSON() ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(s.raw, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
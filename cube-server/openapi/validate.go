@@ -0,0 +1,146 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrorSchema is the schema undocumented 4xx and 5xx responses are checked against
+const ErrorSchema = "Error"
+
+// ValidateRequest checks the parameters and the body of a request to op. pathParams
+// holds the values of the path parameters by name.
+func (s *Spec) ValidateRequest(op *Operation, pathParams map[string]string, query url.Values, contentType string, body []byte) error {
+	v := &validator{spec: s}
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			if value, ok := pathParams[p.Name]; ok {
+				v.validateParameter(p, []string{value})
+			}
+		case "query":
+			values, ok := query[p.Name]
+			if !ok {
+				if p.Required {
+					v.fail("query."+p.Name, "is required")
+				}
+				continue
+			}
+			v.validateParameter(p, values)
+		}
+	}
+	if op.RequestBody != nil {
+		v.validateBody("body", op.RequestBody.Content, op.RequestBody.Required, contentType, body)
+	}
+	return v.err()
+}
+
+// ValidateResponse checks the status code and the JSON body of a response of op.
+// Status codes the operation does not list are accepted for errors (4xx and 5xx)
+// shaped like the Error schema.
+func (s *Spec) ValidateResponse(op *Operation, status int, contentType string, body []byte) error {
+	v := &validator{spec: s}
+	response := op.response(status)
+	if response == nil {
+		errorSchema, ok := s.Components.Schemas[ErrorSchema]
+		if status < 400 || !ok {
+			return &ValidationError{Problems: []string{fmt.Sprintf("status %d is not documented", status)}}
+		}
+		response = &Response{Content: map[string]*MediaType{"application/json": {Schema: errorSchema}}}
+	}
+	if len(body) > 0 {
+		v.validateBody("response", response.Content, false, contentType, body)
+	}
+	return v.err()
+}
+
+// response returns the response of op for status: the exact code, its range (4XX)
+// or the default
+func (op *Operation) response(status int) *Response {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", "default"} {
+		if r, ok := op.Responses[key]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateParameter(p *Parameter, values []string) {
+	path := p.In + "." + p.Name
+	schema := v.spec.resolve(p.Schema)
+	if schema == nil {
+		return
+	}
+	if schema.Type != "array" {
+		v.validate(path, schema, parameterValue(schema, values[0]))
+		return
+	}
+	for i, value := range values {
+		v.validate(fmt.Sprintf("%s[%d]", path, i), schema.Items, parameterValue(v.spec.resolve(schema.Items), value))
+	}
+}
+
+// parameterValue converts a parameter to the type of its schema, so it can be
+// validated like a JSON value; values that do not convert stay strings and fail
+func parameterValue(schema *Schema, value string) interface{} {
+	if schema == nil {
+		return value
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// validateBody checks a body against the schema of its media type. Only JSON is
+// validated; other media types just need to be listed.
+func (v *validator) validateBody(path string, content map[string]*MediaType, required bool, contentType string, body []byte) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if required {
+			v.fail(path, "is required")
+		}
+		return
+	}
+	mediaType := "application/json"
+	if contentType != "" {
+		if parsed, _, err := mime.ParseMediaType(contentType); err == nil {
+			mediaType = parsed
+		}
+	}
+	media, ok := content[mediaType]
+	if !ok {
+		media, ok = content["*/*"]
+	}
+	if !ok {
+		if len(content) > 0 {
+			v.fail(path, "content type %s is not accepted", mediaType)
+		}
+		return
+	}
+	if !isJSON(mediaType) || media == nil || media.Schema == nil {
+		return
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		v.fail(path, "is not valid JSON: %v", err)
+		return
+	}
+	v.validate(path, media.Schema, value)
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
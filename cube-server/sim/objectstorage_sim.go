@@ -10,6 +10,7 @@ import (
 
 	sharederrors "github.com/tronicum/punchbag-cube-testsuite/shared/errors"
 	"github.com/tronicum/punchbag-cube-testsuite/shared/log"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
 )
 
 // ObjectStorageSim defines a generic interface for object storage simulation
@@ -26,11 +27,13 @@ type ObjectStorageSim interface {
 type S3Object struct {
 	Key   string
 	Value []byte
+	ETag  string
 }
 
 // HetznerS3Mock simulates a minimal Hetzner S3 API in-memory.
 type HetznerS3Mock struct {
 	buckets map[string]map[string]S3Object // bucket -> key -> object
+	uploads *simulation.MultipartUploads
 	mu      sync.RWMutex
 }
 
@@ -38,6 +41,7 @@ type HetznerS3Mock struct {
 func NewHetznerS3Mock() *HetznerS3Mock {
 	return &HetznerS3Mock{
 		buckets: make(map[string]map[string]S3Object),
+		uploads: simulation.NewMultipartUploads(),
 	}
 }
 
@@ -49,6 +53,7 @@ func (m *HetznerS3Mock) DeleteBucket(name string) error {
 		return sharederrors.ErrNotFound
 	}
 	delete(m.buckets, name)
+	m.uploads.AbortBucket(name)
 	return nil
 }
 
@@ -193,7 +198,7 @@ func (m *HetznerS3Mock) PutObject(bucket, key string, value []byte) error {
 	if !ok {
 		return sharederrors.ErrNotFound
 	}
-	b[key] = S3Object{Key: key, Value: value}
+	b[key] = S3Object{Key: key, Value: value, ETag: simulation.ComputeETag(value)}
 	return nil
 }
 
@@ -212,6 +217,87 @@ func (m *HetznerS3Mock) GetObject(bucket, key string) ([]byte, error) {
 	return obj.Value, nil
 }
 
+// SetMinPartSize overrides the minimum size of non-final multipart parts (5 MiB by default),
+// so tests can exercise multipart uploads with small payloads.
+func (m *HetznerS3Mock) SetMinPartSize(size int64) {
+	m.uploads.SetMinPartSize(size)
+}
+
+func (m *HetznerS3Mock) hasBucket(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.buckets[name]
+	return ok
+}
+
+// CreateMultipartUpload starts a multipart upload and returns its upload ID.
+func (m *HetznerS3Mock) CreateMultipartUpload(bucket, key string) (string, error) {
+	if !m.hasBucket(bucket) {
+		return "", sharederrors.ErrNotFound
+	}
+	return m.uploads.Create(bucket, key, "", nil).UploadID, nil
+}
+
+// UploadPart stores one part of a multipart upload and returns the part ETag.
+func (m *HetznerS3Mock) UploadPart(bucket, key, uploadID string, partNumber int, value []byte) (string, error) {
+	if !m.hasBucket(bucket) {
+		return "", sharederrors.ErrNotFound
+	}
+	part, err := m.uploads.UploadPart(bucket, key, uploadID, partNumber, value)
+	if err != nil {
+		return "", err
+	}
+	return part.ETag, nil
+}
+
+// UploadPartCopy stores a part copied from an existing object and returns the part ETag.
+func (m *HetznerS3Mock) UploadPartCopy(bucket, key, uploadID string, partNumber int, srcBucket, srcKey string) (string, error) {
+	value, err := m.GetObject(srcBucket, srcKey)
+	if err != nil {
+		return "", err
+	}
+	return m.UploadPart(bucket, key, uploadID, partNumber, value)
+}
+
+// CompleteMultipartUpload assembles the listed parts into an object and returns its
+// multipart ETag ("<md5 of part md5s>-<part count>").
+func (m *HetznerS3Mock) CompleteMultipartUpload(bucket, key, uploadID string, parts []simulation.CompletedPart) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[bucket]
+	if !ok {
+		return "", sharederrors.ErrNotFound
+	}
+	_, value, etag, err := m.uploads.Complete(bucket, key, uploadID, parts)
+	if err != nil {
+		return "", err
+	}
+	b[key] = S3Object{Key: key, Value: value, ETag: etag}
+	return etag, nil
+}
+
+// AbortMultipartUpload discards an in-progress upload and its parts.
+func (m *HetznerS3Mock) AbortMultipartUpload(bucket, key, uploadID string) error {
+	return m.uploads.Abort(bucket, key, uploadID)
+}
+
+// ListParts returns the uploaded parts of an upload ordered by part number.
+func (m *HetznerS3Mock) ListParts(bucket, key, uploadID string) ([]*simulation.Part, error) {
+	upload, err := m.uploads.Get(bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	return upload.SortedParts(), nil
+}
+
+// ListMultipartUploads returns the in-progress uploads of a bucket.
+func (m *HetznerS3Mock) ListMultipartUploads(bucket string) ([]*simulation.MultipartUpload, error) {
+	if !m.hasBucket(bucket) {
+		return nil, sharederrors.ErrNotFound
+	}
+	return m.uploads.List(bucket, ""), nil
+}
+
 // HetznerS3MockHandler exposes the HetznerS3Mock as an HTTP handler for simulation
 func HetznerS3MockHandler(mock *HetznerS3Mock) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
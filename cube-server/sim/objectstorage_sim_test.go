@@ -0,0 +1,113 @@
+package sim
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/simulation"
+)
+
+func TestHetznerS3MockMultipartUpload(t *testing.T) {
+	mock := NewHetznerS3Mock()
+	mock.SetMinPartSize(4)
+	if err := mock.CreateBucket("mp"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := mock.PutObject("mp", "src", []byte("0123456789")); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	uploadID, err := mock.CreateMultipartUpload("mp", "big")
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	if _, err := mock.UploadPart("mp", "big", uploadID, 0, []byte("x")); !errors.Is(err, simulation.ErrInvalidPartNumber) {
+		t.Errorf("part 0: expected ErrInvalidPartNumber, got %v", err)
+	}
+	etag1, err := mock.UploadPart("mp", "big", uploadID, 1, []byte("aaaa"))
+	if err != nil {
+		t.Fatalf("UploadPart 1: %v", err)
+	}
+	etag2, err := mock.UploadPartCopy("mp", "big", uploadID, 2, "mp", "src")
+	if err != nil {
+		t.Fatalf("UploadPartCopy 2: %v", err)
+	}
+	etag3, err := mock.UploadPart("mp", "big", uploadID, 3, []byte("z"))
+	if err != nil {
+		t.Fatalf("UploadPart 3: %v", err)
+	}
+
+	parts, err := mock.ListParts("mp", "big", uploadID)
+	if err != nil || len(parts) != 3 {
+		t.Fatalf("ListParts = %d parts, %v", len(parts), err)
+	}
+	if uploads, _ := mock.ListMultipartUploads("mp"); len(uploads) != 1 {
+		t.Errorf("ListMultipartUploads = %d uploads, want 1", len(uploads))
+	}
+
+	_, err = mock.CompleteMultipartUpload("mp", "big", uploadID, []simulation.CompletedPart{{PartNumber: 2, ETag: etag2}, {PartNumber: 1, ETag: etag1}})
+	if !errors.Is(err, simulation.ErrInvalidPartOrder) {
+		t.Errorf("unordered parts: expected ErrInvalidPartOrder, got %v", err)
+	}
+	_, err = mock.CompleteMultipartUpload("mp", "big", uploadID, []simulation.CompletedPart{{PartNumber: 1, ETag: etag2}})
+	if !errors.Is(err, simulation.ErrInvalidPart) {
+		t.Errorf("wrong ETag: expected ErrInvalidPart, got %v", err)
+	}
+	_, err = mock.CompleteMultipartUpload("mp", "big", uploadID, []simulation.CompletedPart{{PartNumber: 2, ETag: etag2}, {PartNumber: 4}})
+	if !errors.Is(err, simulation.ErrInvalidPart) {
+		t.Errorf("missing part: expected ErrInvalidPart, got %v", err)
+	}
+
+	etag, err := mock.CompleteMultipartUpload("mp", "big", uploadID, []simulation.CompletedPart{
+		{PartNumber: 1, ETag: `"` + etag1 + `"`},
+		{PartNumber: 2, ETag: etag2},
+		{PartNumber: 3, ETag: etag3},
+	})
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	var digests []byte
+	for _, data := range []string{"aaaa", "0123456789", "z"} {
+		sum := md5.Sum([]byte(data))
+		digests = append(digests, sum[:]...)
+	}
+	sum := md5.Sum(digests)
+	if want := fmt.Sprintf("%s-3", hex.EncodeToString(sum[:])); etag != want {
+		t.Errorf("multipart ETag = %s, want %s", etag, want)
+	}
+	got, err := mock.GetObject("mp", "big")
+	if err != nil || !bytes.Equal(got, []byte("aaaa0123456789z")) {
+		t.Errorf("GetObject after complete = %q, %v", got, err)
+	}
+	if _, err := mock.ListParts("mp", "big", uploadID); !errors.Is(err, simulation.ErrNoSuchUpload) {
+		t.Errorf("ListParts after complete: expected ErrNoSuchUpload, got %v", err)
+	}
+}
+
+func TestHetznerS3MockMultipartEntityTooSmallAndAbort(t *testing.T) {
+	mock := NewHetznerS3Mock()
+	if err := mock.CreateBucket("mp"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	uploadID, _ := mock.CreateMultipartUpload("mp", "small")
+	etag1, _ := mock.UploadPart("mp", "small", uploadID, 1, []byte("tiny"))
+	etag2, _ := mock.UploadPart("mp", "small", uploadID, 2, []byte("last"))
+	_, err := mock.CompleteMultipartUpload("mp", "small", uploadID, []simulation.CompletedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 2, ETag: etag2}})
+	if !errors.Is(err, simulation.ErrEntityTooSmall) {
+		t.Errorf("expected ErrEntityTooSmall for a 4 byte non-final part, got %v", err)
+	}
+
+	if err := mock.AbortMultipartUpload("mp", "small", uploadID); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	if err := mock.AbortMultipartUpload("mp", "small", uploadID); !errors.Is(err, simulation.ErrNoSuchUpload) {
+		t.Errorf("second abort: expected ErrNoSuchUpload, got %v", err)
+	}
+	if uploads, _ := mock.ListMultipartUploads("mp"); len(uploads) != 0 {
+		t.Errorf("ListMultipartUploads after abort = %d uploads", len(uploads))
+	}
+}
@@ -0,0 +1,576 @@
+// Package testrunner executes cluster tests asynchronously. Tests submitted through
+// POST /clusters/:id/tests are stored as pending, picked up by a fixed pool of workers
+// and moved to running and finally to passed, failed or cancelled.
+package testrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/events"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+// Executor defaults, used for zero Config fields
+const (
+	DefaultConcurrency = 4
+	DefaultQueueSize   = 100
+	DefaultTimeout     = 10 * time.Minute
+)
+
+// Test config keys read by the executor itself; everything else is passed to the test
+const (
+	ConfigTimeout = "timeout"
+)
+
+var (
+	ErrUnknownTestType = errors.New("unknown test type")
+	ErrInvalidConfig   = errors.New("invalid test config")
+	ErrQueueFull       = errors.New("test queue is full")
+	ErrFinished        = errors.New("test has already finished")
+
+	errCancelled = errors.New("cancelled")
+	errTimedOut  = errors.New("timed out")
+)
+
+// interruptedMsg is the error message of tests found running by Recover: the worker
+// that ran them is gone
+const interruptedMsg = "interrupted by restart"
+
+// TestFunc runs one test. It must return once ctx is done; the returned details are
+// merged into the result's Details. A non-nil error fails the test.
+type TestFunc func(ctx context.Context, run *Run) (map[string]interface{}, error)
+
+// Run describes the test a TestFunc executes.
+type Run struct {
+	ID       string
+	TestType string
+	Cluster  *sharedmodels.Cluster
+	Config   map[string]interface{}
+
+	progress func(details map[string]interface{})
+	log      func(message string)
+}
+
+// Progress merges details into the stored result while the test is still running, so
+// clients polling GET /tests/:id can follow it. Subscribers of the event stream get
+// them as a progress event.
+func (r *Run) Progress(details map[string]interface{}) {
+	if r.progress != nil {
+		r.progress(details)
+	}
+}
+
+// Logf publishes a log line of the test to the event stream.
+func (r *Run) Logf(format string, args ...interface{}) {
+	if r.log != nil {
+		r.log(fmt.Sprintf(format, args...))
+	}
+}
+
+// activeTest is a test a worker is currently running.
+type activeTest struct {
+	cancel  context.CancelCauseFunc
+	started time.Time
+}
+
+// Config sizes the executor.
+type Config struct {
+	// Concurrency is the number of tests run in parallel
+	Concurrency int
+	// QueueSize is the number of pending tests accepted before Submit fails with ErrQueueFull
+	QueueSize int
+	// Timeout applies to tests that do not set their own "timeout" config value
+	Timeout time.Duration
+	// Events receives status changes, progress and log lines of every test (may be nil)
+	Events *events.Broker
+	// StatusChanged is called with every status a test enters, pending included (may be nil)
+	StatusChanged func(testType string, status sharedmodels.TestStatus)
+}
+
+// Executor runs submitted tests on a pool of workers and records their progress in the store.
+type Executor struct {
+	store   store.Store
+	logger  *zap.Logger
+	timeout time.Duration
+	queue   chan queuedTest
+	events  *events.Broker
+	changed func(testType string, status sharedmodels.TestStatus)
+
+	// mu serialises status changes of a result, so a cancellation cannot be
+	// overwritten by a worker finishing at the same time
+	mu      sync.Mutex
+	tests   map[string]TestFunc
+	running map[string]activeTest // by test result ID
+
+	// lifecycle serialises Stop and Restart; ctx and stop are only replaced while no
+	// worker runs
+	lifecycle   sync.Mutex
+	concurrency int
+	ctx         context.Context
+	stop        context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// New starts an executor with the simulated test types registered (see simulated.go)
+// and recovers the tests left pending or running in st.
+func New(st store.Store, logger *zap.Logger, cfg Config) *Executor {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	e := &Executor{
+		store:       st,
+		logger:      logger,
+		timeout:     cfg.Timeout,
+		queue:       make(chan queuedTest, cfg.QueueSize),
+		events:      cfg.Events,
+		changed:     cfg.StatusChanged,
+		tests:       make(map[string]TestFunc),
+		running:     make(map[string]activeTest),
+		concurrency: cfg.Concurrency,
+	}
+	for testType, fn := range simulatedTests() {
+		e.tests[testType] = fn
+	}
+	e.start()
+	if err := e.Recover(); err != nil {
+		logger.Error("Failed to recover tests", zap.Error(err))
+	}
+	return e
+}
+
+// start runs the workers under a new context
+func (e *Executor) start() {
+	e.ctx, e.stop = context.WithCancel(context.Background())
+	for i := 0; i < e.concurrency; i++ {
+		e.wg.Add(1)
+		go e.worker()
+	}
+}
+
+// Recover picks up the tests of every project that no worker of this executor knows
+// about, e.g. those a previous server left in a durable store: pending tests are queued
+// again (or failed when the queue is full), running ones are failed as interrupted.
+func (e *Executor) Recover() error {
+	if e.store == nil {
+		return nil
+	}
+	snapshot, err := e.store.Snapshot()
+	if err != nil {
+		return err
+	}
+	projects := make([]string, 0, len(snapshot.Resources))
+	for project := range snapshot.Resources {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	var requeued, interrupted int
+	for _, project := range projects {
+		resources := snapshot.Resources[project]
+		if resources == nil {
+			continue
+		}
+		for _, result := range resources.TestResults {
+			switch result.Status {
+			case sharedmodels.TestStatusPending:
+				if e.enqueue(project, result.ID) == nil {
+					requeued++
+				}
+			case sharedmodels.TestStatusRunning:
+				e.mu.Lock()
+				if _, active := e.running[result.ID]; !active {
+					e.fail(project, result.ID, interruptedMsg)
+					interrupted++
+				}
+				e.mu.Unlock()
+			}
+		}
+	}
+	if requeued > 0 || interrupted > 0 {
+		e.logger.Info("Recovered tests", zap.Int("requeued", requeued), zap.Int("interrupted", interrupted))
+	}
+	return nil
+}
+
+// Register adds or replaces the implementation of a test type.
+func (e *Executor) Register(testType string, fn TestFunc) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tests[testType] = fn
+}
+
+// TestTypes returns the registered test types in name order.
+func (e *Executor) TestTypes() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	types := make([]string, 0, len(e.tests))
+	for testType := range e.tests {
+		types = append(types, testType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Stop cancels running tests, marking them failed, and waits for the workers to exit.
+// Tests still queued stay pending; the next executor on the store queues them again.
+func (e *Executor) Stop() {
+	e.lifecycle.Lock()
+	defer e.lifecycle.Unlock()
+	e.stop()
+	e.wg.Wait()
+}
+
+// Restart stops the workers like Stop and drops the queue, runs fn while no test runs,
+// e.g. a restore of the store, and starts the workers again. The tests fn leaves
+// pending or running are then recovered (see Recover). It returns the error of fn.
+func (e *Executor) Restart(fn func() error) error {
+	e.lifecycle.Lock()
+	defer e.lifecycle.Unlock()
+	e.stop()
+	e.wg.Wait()
+	for drained := false; !drained; {
+		select {
+		case <-e.queue:
+		default:
+			drained = true
+		}
+	}
+	err := fn()
+	e.start()
+	if recoverErr := e.Recover(); recoverErr != nil {
+		e.logger.Error("Failed to recover tests", zap.Error(recoverErr))
+	}
+	return err
+}
+
+// queuedTest is a pending test result and the project it is stored in
+type queuedTest struct {
+	project string
+	id      string
+}
+
+// Submit stores a pending result for a test on cluster, in the store of project, and
+// queues it.
+func (e *Executor) Submit(project string, cluster *sharedmodels.Cluster, testType string, config map[string]interface{}) (*sharedmodels.TestResult, error) {
+	e.mu.Lock()
+	_, known := e.tests[testType]
+	e.mu.Unlock()
+	if !known {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTestType, testType)
+	}
+	if _, err := e.testTimeout(config); err != nil {
+		return nil, err
+	}
+
+	details := cloneDetails(config)
+	details["provider"] = string(cluster.Provider)
+	details["cluster_name"] = cluster.Name
+	st := e.store.Project(project)
+	created, err := st.CreateTestResult(&sharedmodels.TestResult{
+		ClusterID: cluster.ID,
+		TestType:  testType,
+		Status:    sharedmodels.TestStatusPending,
+		Details:   details,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := copyResult(created)
+	e.statusChanged(project, result, sharedmodels.EventCreated, "")
+
+	if err := e.enqueue(project, result.ID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// enqueue queues a pending test of project, failing it with ErrQueueFull when the
+// queue has no room.
+func (e *Executor) enqueue(project, id string) error {
+	select {
+	case e.queue <- queuedTest{project: project, id: id}:
+		return nil
+	default:
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fail(project, id, ErrQueueFull.Error())
+	return ErrQueueFull
+}
+
+// fail marks a pending or running test of project failed without running it any
+// further. The caller holds e.mu.
+func (e *Executor) fail(project, id, message string) {
+	st := e.store.Project(project)
+	stored, err := st.GetTestResult(id)
+	if err != nil || (stored.Status != sharedmodels.TestStatusPending && stored.Status != sharedmodels.TestStatusRunning) {
+		return
+	}
+	result := copyResult(stored)
+	now := time.Now()
+	result.Status = sharedmodels.TestStatusFailed
+	result.ErrorMsg = message
+	result.CompletedAt = &now
+	if _, err := st.UpdateTestResult(id, copyResult(result)); err != nil {
+		e.logger.Error("Failed to update test result", zap.String("test_id", id), zap.Error(err))
+		return
+	}
+	e.statusChanged(project, result, sharedmodels.EventStatus, result.ErrorMsg)
+}
+
+// Cancel stops a pending or running test of project and marks it cancelled. Finished
+// tests return ErrFinished, unknown IDs (and tests of other projects) store.ErrNotFound.
+func (e *Executor) Cancel(project, id string) (*sharedmodels.TestResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := e.store.Project(project)
+	stored, err := st.GetTestResult(id)
+	if err != nil {
+		return nil, err
+	}
+	result := copyResult(stored)
+	now := time.Now()
+	switch result.Status {
+	case sharedmodels.TestStatusPending:
+		result.ErrorMsg = "cancelled before it started"
+	case sharedmodels.TestStatusRunning:
+		result.ErrorMsg = "cancelled while running"
+		if active, ok := e.running[id]; ok {
+			active.cancel(errCancelled)
+			result.Duration = now.Sub(active.started)
+		}
+	default:
+		return nil, fmt.Errorf("%w with status %s", ErrFinished, result.Status)
+	}
+	result.Status = sharedmodels.TestStatusCancelled
+	result.CompletedAt = &now
+	if _, err := st.UpdateTestResult(id, copyResult(result)); err != nil {
+		return nil, err
+	}
+	e.statusChanged(project, result, sharedmodels.EventStatus, result.ErrorMsg)
+	e.logger.Info("Test cancelled", zap.String("test_id", id))
+	return result, nil
+}
+
+func (e *Executor) worker() {
+	defer e.wg.Done()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case queued := <-e.queue:
+			// Both cases may be ready at Stop; queued tests then stay pending
+			if e.ctx.Err() != nil {
+				return
+			}
+			e.run(queued.project, queued.id)
+		}
+	}
+}
+
+// run executes one queued test unless it was cancelled while pending.
+func (e *Executor) run(project, id string) {
+	st := e.store.Project(project)
+	e.mu.Lock()
+	stored, err := st.GetTestResult(id)
+	if err != nil || stored.Status != sharedmodels.TestStatusPending {
+		e.mu.Unlock()
+		return
+	}
+	result := copyResult(stored)
+	fn := e.tests[result.TestType]
+	timeout, _ := e.testTimeout(result.Details)
+	ctx, cancel := context.WithCancelCause(e.ctx)
+	ctx, cancelTimeout := context.WithTimeoutCause(ctx, timeout, errTimedOut)
+	defer cancelTimeout()
+	started := time.Now()
+	e.running[id] = activeTest{cancel: cancel, started: started}
+	result.Status = sharedmodels.TestStatusRunning
+	_, err = st.UpdateTestResult(id, copyResult(result))
+	e.statusChanged(project, result, sharedmodels.EventStatus, "")
+	e.mu.Unlock()
+	if err != nil {
+		e.logger.Error("Failed to update test result", zap.String("test_id", id), zap.Error(err))
+	}
+	e.logger.Info("Test running", zap.String("test_id", id), zap.String("test_type", result.TestType))
+
+	var details map[string]interface{}
+	cluster, err := st.GetCluster(result.ClusterID)
+	if err == nil {
+		run := &Run{
+			ID:       id,
+			TestType: result.TestType,
+			Cluster:  cluster,
+			Config:   cloneDetails(result.Details),
+			progress: func(progress map[string]interface{}) { e.progress(project, id, progress) },
+			log: func(message string) {
+				e.publish(project, id, sharedmodels.EventLog, message, nil)
+			},
+		}
+		details, err = fn(ctx, run)
+	}
+	cause := context.Cause(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.running, id)
+	cancel(nil)
+	stored, getErr := st.GetTestResult(id)
+	if getErr != nil || stored.Status != sharedmodels.TestStatusRunning {
+		// Cancelled; Cancel already recorded the outcome
+		return
+	}
+	result = copyResult(stored)
+	now := time.Now()
+	for k, v := range details {
+		result.Details[k] = v
+	}
+	result.Duration = now.Sub(started)
+	result.CompletedAt = &now
+	result.Status = sharedmodels.TestStatusPassed
+	switch {
+	case errors.Is(cause, errTimedOut):
+		result.Status = sharedmodels.TestStatusFailed
+		result.ErrorMsg = fmt.Sprintf("timed out after %s", timeout)
+	case cause != nil:
+		result.Status = sharedmodels.TestStatusFailed
+		result.ErrorMsg = "test executor stopped"
+	case err != nil:
+		result.Status = sharedmodels.TestStatusFailed
+		result.ErrorMsg = err.Error()
+	}
+	if _, err := st.UpdateTestResult(id, copyResult(result)); err != nil {
+		e.logger.Error("Failed to update test result", zap.String("test_id", id), zap.Error(err))
+		return
+	}
+	e.statusChanged(project, result, sharedmodels.EventStatus, result.ErrorMsg)
+	e.logger.Info("Test finished",
+		zap.String("test_id", id),
+		zap.String("status", string(result.Status)),
+		zap.Duration("duration", result.Duration))
+}
+
+// progress merges details into a running result.
+func (e *Executor) progress(project, id string, details map[string]interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := e.store.Project(project)
+	stored, err := st.GetTestResult(id)
+	if err != nil || stored.Status != sharedmodels.TestStatusRunning {
+		return
+	}
+	result := copyResult(stored)
+	for k, v := range details {
+		result.Details[k] = v
+	}
+	if _, err := st.UpdateTestResult(id, result); err != nil {
+		e.logger.Error("Failed to update test progress", zap.String("test_id", id), zap.Error(err))
+		return
+	}
+	e.publish(project, result.ID, sharedmodels.EventProgress, "", cloneDetails(details))
+}
+
+// publish sends an event about a test result of project to the event stream.
+func (e *Executor) publish(project, id, eventType, message string, data map[string]interface{}) {
+	if project == "" {
+		project = sharedmodels.DefaultProject
+	}
+	e.events.Publish(sharedmodels.Event{
+		Project:    project,
+		Resource:   sharedmodels.EventResourceTests,
+		ResourceID: id,
+		Type:       eventType,
+		Message:    message,
+		Data:       data,
+	})
+}
+
+// statusChanged publishes a created or status event for result and reports its
+// new status to the StatusChanged hook.
+func (e *Executor) statusChanged(project string, result *sharedmodels.TestResult, eventType, message string) {
+	e.publish(project, result.ID, eventType, message, statusData(result))
+	if e.changed != nil {
+		e.changed(result.TestType, result.Status)
+	}
+}
+
+// statusData is the payload of created and status events.
+func statusData(result *sharedmodels.TestResult) map[string]interface{} {
+	data := map[string]interface{}{
+		"status":     string(result.Status),
+		"cluster_id": result.ClusterID,
+		"test_type":  result.TestType,
+	}
+	if result.ErrorMsg != "" {
+		data["error_message"] = result.ErrorMsg
+	}
+	if result.CompletedAt != nil {
+		data["duration"] = result.Duration.String()
+		data["completed_at"] = *result.CompletedAt
+	}
+	return data
+}
+
+// testTimeout returns the timeout of a test: its "timeout" config value, either a
+// duration string ("90s") or a number of seconds, or the executor default.
+func (e *Executor) testTimeout(config map[string]interface{}) (time.Duration, error) {
+	d, ok, err := durationParam(config, ConfigTimeout)
+	switch {
+	case err != nil:
+		return 0, err
+	case !ok:
+		return e.timeout, nil
+	case d <= 0:
+		return 0, fmt.Errorf("%w: %s must be positive", ErrInvalidConfig, ConfigTimeout)
+	}
+	return d, nil
+}
+
+// durationParam reads a duration string or a number of seconds from a test config.
+func durationParam(config map[string]interface{}, key string) (time.Duration, bool, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return 0, false, nil
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, true, nil
+		}
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), true, nil
+		}
+	case float64:
+		return time.Duration(v * float64(time.Second)), true, nil
+	case int:
+		return time.Duration(v) * time.Second, true, nil
+	}
+	return 0, false, fmt.Errorf("%w: %s must be a duration such as \"30s\" or a number of seconds", ErrInvalidConfig, key)
+}
+
+// copyResult returns a copy that shares no Details map with the stored result. The
+// memory store hands out its own pointers, which must not be modified in place.
+func copyResult(result *sharedmodels.TestResult) *sharedmodels.TestResult {
+	c := *result
+	c.Details = cloneDetails(result.Details)
+	return &c
+}
+
+func cloneDetails(details map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(details)+2)
+	for k, v := range details {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,325 @@
+package testrunner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"go.uber.org/zap"
+)
+
+func newTestExecutor(t *testing.T, cfg Config) (*Executor, store.Store, *sharedmodels.Cluster) {
+	t.Helper()
+	st := store.NewMemoryStore()
+	cluster, err := st.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "primary", Provider: sharedmodels.Hetzner, Location: "fsn1"})
+	if err != nil {
+		t.Fatalf("CreateCluster: %v", err)
+	}
+	e := New(st, zap.NewNop(), cfg)
+	t.Cleanup(e.Stop)
+	return e, st, cluster
+}
+
+// waitForStatus polls the store until the result has one of the given statuses.
+func waitForStatus(t *testing.T, st store.Store, id string, statuses ...sharedmodels.TestStatus) *sharedmodels.TestResult {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result, err := st.GetTestResult(id)
+		if err != nil {
+			t.Fatalf("GetTestResult: %v", err)
+		}
+		for _, status := range statuses {
+			if result.Status == status {
+				return result
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("test %s stuck in status %s, want %v", id, result.Status, statuses)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// blockingTest runs until its context is done.
+func blockingTest(ctx context.Context, run *Run) (map[string]interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestExecutorRunsSimulatedTests(t *testing.T) {
+	e, st, cluster := newTestExecutor(t, Config{})
+	submitted, err := e.Submit(sharedmodels.DefaultProject, cluster, "load", map[string]interface{}{ConfigDuration: "40ms", "rps": 100})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if submitted.Status != sharedmodels.TestStatusPending || submitted.Details["provider"] != "hetzner" || submitted.Details["rps"] != 100 {
+		t.Errorf("Submit = %+v", submitted)
+	}
+
+	result := waitForStatus(t, st, submitted.ID, sharedmodels.TestStatusPassed, sharedmodels.TestStatusFailed)
+	if result.Status != sharedmodels.TestStatusPassed || result.ErrorMsg != "" {
+		t.Fatalf("load test = %s (%s), want passed", result.Status, result.ErrorMsg)
+	}
+	if result.CompletedAt == nil || result.Duration < 40*time.Millisecond {
+		t.Errorf("CompletedAt = %v, Duration = %v", result.CompletedAt, result.Duration)
+	}
+	if result.Details["requests_sent"] != 1000 || result.Details["progress_percent"] != 100 || result.Details["cluster_name"] != "primary" {
+		t.Errorf("Details = %v", result.Details)
+	}
+	if metrics, _ := result.Details["provider_specific"].(map[string]interface{}); metrics["network_zone"] != "eu-central" {
+		t.Errorf("provider_specific = %v", result.Details["provider_specific"])
+	}
+
+	failing, err := e.Submit(sharedmodels.DefaultProject, cluster, "security", map[string]interface{}{ConfigDuration: 0.01, ConfigFail: true})
+	if err != nil {
+		t.Fatalf("Submit(failing): %v", err)
+	}
+	result = waitForStatus(t, st, failing.ID, sharedmodels.TestStatusPassed, sharedmodels.TestStatusFailed)
+	if result.Status != sharedmodels.TestStatusFailed || result.ErrorMsg != "simulated test failure" || result.Details["security_score"] != 92 {
+		t.Errorf("failing test = %+v", result)
+	}
+}
+
+func TestExecutorProgress(t *testing.T) {
+	e, st, cluster := newTestExecutor(t, Config{})
+	release := make(chan struct{})
+	e.Register("stepped", func(ctx context.Context, run *Run) (map[string]interface{}, error) {
+		run.Progress(map[string]interface{}{"step": 1})
+		<-release
+		return map[string]interface{}{"step": 2}, nil
+	})
+	submitted, err := e.Submit(sharedmodels.DefaultProject, cluster, "stepped", nil)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result, _ := st.GetTestResult(submitted.ID)
+		if result.Status == sharedmodels.TestStatusRunning && result.Details["step"] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("progress not recorded: %+v", result)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	if result := waitForStatus(t, st, submitted.ID, sharedmodels.TestStatusPassed); result.Details["step"] != 2 {
+		t.Errorf("Details = %v", result.Details)
+	}
+}
+
+func TestExecutorTimeout(t *testing.T) {
+	e, st, cluster := newTestExecutor(t, Config{Timeout: 30 * time.Millisecond})
+	e.Register("blocking", blockingTest)
+
+	byDefault, err := e.Submit(sharedmodels.DefaultProject, cluster, "blocking", nil)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	perTest, err := e.Submit(sharedmodels.DefaultProject, cluster, "blocking", map[string]interface{}{ConfigTimeout: "50ms"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	for id, want := range map[string]string{byDefault.ID: "timed out after 30ms", perTest.ID: "timed out after 50ms"} {
+		result := waitForStatus(t, st, id, sharedmodels.TestStatusFailed, sharedmodels.TestStatusPassed)
+		if result.Status != sharedmodels.TestStatusFailed || result.ErrorMsg != want {
+			t.Errorf("test %s = %s (%s), want failed (%s)", id, result.Status, result.ErrorMsg, want)
+		}
+	}
+}
+
+func TestExecutorKeepsProjectsApart(t *testing.T) {
+	e, st, cluster := newTestExecutor(t, Config{})
+	teamA := st.Project("team-a")
+	if _, err := teamA.CreateCluster(&sharedmodels.Cluster{ID: cluster.ID, Name: "team", Provider: sharedmodels.AWS}); err != nil {
+		t.Fatalf("CreateCluster(team-a): %v", err)
+	}
+	submitted, err := e.Submit("team-a", cluster, "connectivity", map[string]interface{}{ConfigDuration: "10ms"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	result := waitForStatus(t, teamA, submitted.ID, sharedmodels.TestStatusPassed, sharedmodels.TestStatusFailed)
+	if result.Details["cluster_name"] != "primary" {
+		t.Errorf("Details = %v", result.Details)
+	}
+	if _, err := st.GetTestResult(submitted.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetTestResult in the default project: expected store.ErrNotFound, got %v", err)
+	}
+	if _, err := e.Cancel(sharedmodels.DefaultProject, submitted.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Cancel from another project: expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestExecutorCancel(t *testing.T) {
+	e, st, cluster := newTestExecutor(t, Config{Concurrency: 1})
+	e.Register("blocking", blockingTest)
+
+	running, err := e.Submit(sharedmodels.DefaultProject, cluster, "blocking", nil)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitForStatus(t, st, running.ID, sharedmodels.TestStatusRunning)
+	// The only worker is busy, so this one stays pending
+	pending, err := e.Submit(sharedmodels.DefaultProject, cluster, "load", map[string]interface{}{ConfigDuration: "10ms"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	cancelled, err := e.Cancel(sharedmodels.DefaultProject, pending.ID)
+	if err != nil || cancelled.Status != sharedmodels.TestStatusCancelled || cancelled.ErrorMsg != "cancelled before it started" {
+		t.Fatalf("Cancel(pending) = %+v, %v", cancelled, err)
+	}
+	cancelled, err = e.Cancel(sharedmodels.DefaultProject, running.ID)
+	if err != nil || cancelled.Status != sharedmodels.TestStatusCancelled || cancelled.CompletedAt == nil {
+		t.Fatalf("Cancel(running) = %+v, %v", cancelled, err)
+	}
+
+	// The worker moves on, skips the cancelled pending test and runs the next one
+	next, err := e.Submit(sharedmodels.DefaultProject, cluster, "connectivity", map[string]interface{}{ConfigDuration: "10ms"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitForStatus(t, st, next.ID, sharedmodels.TestStatusPassed)
+	for _, id := range []string{running.ID, pending.ID} {
+		if result, _ := st.GetTestResult(id); result.Status != sharedmodels.TestStatusCancelled {
+			t.Errorf("test %s = %s after the worker finished, want cancelled", id, result.Status)
+		}
+	}
+
+	if _, err := e.Cancel(sharedmodels.DefaultProject, next.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Cancel(finished): expected ErrFinished, got %v", err)
+	}
+	if _, err := e.Cancel(sharedmodels.DefaultProject, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Cancel(missing): expected store.ErrNotFound, got %v", err)
+	}
+}
+
+func TestExecutorRejectsSubmissions(t *testing.T) {
+	e, st, cluster := newTestExecutor(t, Config{Concurrency: 1, QueueSize: 1})
+	e.Register("blocking", blockingTest)
+
+	if _, err := e.Submit(sharedmodels.DefaultProject, cluster, "chaos", nil); !errors.Is(err, ErrUnknownTestType) {
+		t.Errorf("Submit(unknown type): expected ErrUnknownTestType, got %v", err)
+	}
+	if _, err := e.Submit(sharedmodels.DefaultProject, cluster, "load", map[string]interface{}{ConfigTimeout: "soon"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Submit(bad timeout): expected ErrInvalidConfig, got %v", err)
+	}
+
+	first, err := e.Submit(sharedmodels.DefaultProject, cluster, "blocking", nil)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitForStatus(t, st, first.ID, sharedmodels.TestStatusRunning)
+	if _, err := e.Submit(sharedmodels.DefaultProject, cluster, "blocking", nil); err != nil {
+		t.Fatalf("Submit(queued): %v", err)
+	}
+	if _, err := e.Submit(sharedmodels.DefaultProject, cluster, "blocking", nil); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit(queue full): expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestExecutorRecoversTests(t *testing.T) {
+	st := store.NewMemoryStore()
+	if _, err := st.CreateProject(&sharedmodels.Project{Name: "team-a"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	teamA := st.Project("team-a")
+	for _, s := range []store.Store{st, teamA} {
+		if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "primary", Provider: sharedmodels.Hetzner}); err != nil {
+			t.Fatalf("CreateCluster: %v", err)
+		}
+	}
+	// Left behind by a server that stopped without finishing them
+	pending, _ := teamA.CreateTestResult(&sharedmodels.TestResult{ClusterID: "c-1", TestType: "load", Status: sharedmodels.TestStatusPending,
+		Details: map[string]interface{}{ConfigDuration: "10ms"}})
+	running, _ := st.CreateTestResult(&sharedmodels.TestResult{ClusterID: "c-1", TestType: "load", Status: sharedmodels.TestStatusRunning})
+
+	e := New(st, zap.NewNop(), Config{})
+	t.Cleanup(e.Stop)
+	if result := waitForStatus(t, teamA, pending.ID, sharedmodels.TestStatusPassed, sharedmodels.TestStatusFailed); result.Status != sharedmodels.TestStatusPassed {
+		t.Errorf("pending test = %s (%s), want passed", result.Status, result.ErrorMsg)
+	}
+	result, _ := st.GetTestResult(running.ID)
+	if result.Status != sharedmodels.TestStatusFailed || result.ErrorMsg != "interrupted by restart" || result.CompletedAt == nil {
+		t.Errorf("running test = %+v, want failed as interrupted", result)
+	}
+}
+
+func TestExecutorRequeuesTestsLeftAtStop(t *testing.T) {
+	st := store.NewMemoryStore()
+	cluster, _ := st.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "primary", Provider: sharedmodels.Hetzner})
+	first := New(st, zap.NewNop(), Config{Concurrency: 1})
+	first.Register("blocking", blockingTest)
+	running, err := first.Submit(sharedmodels.DefaultProject, cluster, "blocking", nil)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitForStatus(t, st, running.ID, sharedmodels.TestStatusRunning)
+	queued, err := first.Submit(sharedmodels.DefaultProject, cluster, "load", map[string]interface{}{ConfigDuration: "10ms"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	first.Stop()
+	if result, _ := st.GetTestResult(queued.ID); result.Status != sharedmodels.TestStatusPending {
+		t.Fatalf("queued test = %s after Stop, want pending", result.Status)
+	}
+
+	second := New(st, zap.NewNop(), Config{})
+	t.Cleanup(second.Stop)
+	waitForStatus(t, st, queued.ID, sharedmodels.TestStatusPassed)
+	if result, _ := st.GetTestResult(running.ID); result.Status != sharedmodels.TestStatusFailed || result.ErrorMsg != "test executor stopped" {
+		t.Errorf("running test = %s (%s), want failed as stopped", result.Status, result.ErrorMsg)
+	}
+}
+
+func TestExecutorRestart(t *testing.T) {
+	st := store.NewMemoryStore()
+	cluster, _ := st.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "primary", Provider: sharedmodels.Hetzner})
+	e := New(st, zap.NewNop(), Config{Concurrency: 1})
+	t.Cleanup(e.Stop)
+	e.Register("blocking", blockingTest)
+	running, err := e.Submit(sharedmodels.DefaultProject, cluster, "blocking", nil)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitForStatus(t, st, running.ID, sharedmodels.TestStatusRunning)
+	if _, err := e.Submit(sharedmodels.DefaultProject, cluster, "blocking", nil); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	// The state to restore has a test waiting to run
+	source := store.NewMemoryStore()
+	source.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "primary", Provider: sharedmodels.Hetzner})
+	pending, _ := source.CreateTestResult(&sharedmodels.TestResult{ClusterID: "c-1", TestType: "load", Status: sharedmodels.TestStatusPending,
+		Details: map[string]interface{}{ConfigDuration: "10ms"}})
+	snapshot, err := source.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	restoreErr := errors.New("restore failed")
+	err = e.Restart(func() error {
+		e.mu.Lock()
+		active := len(e.running)
+		e.mu.Unlock()
+		if active != 0 {
+			t.Errorf("%d tests running during the restart", active)
+		}
+		if result, _ := st.GetTestResult(running.ID); result.Status != sharedmodels.TestStatusFailed {
+			t.Errorf("running test = %s during the restart, want failed", result.Status)
+		}
+		if err := st.Restore(snapshot); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		return restoreErr
+	})
+	if !errors.Is(err, restoreErr) {
+		t.Errorf("Restart = %v, want the error of its function", err)
+	}
+	waitForStatus(t, st, pending.ID, sharedmodels.TestStatusPassed)
+}
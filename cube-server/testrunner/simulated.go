@@ -0,0 +1,149 @@
+package testrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// Config keys understood by the simulated tests
+const (
+	// ConfigDuration is how long a simulated test runs (default: 5s)
+	ConfigDuration = "duration"
+	// ConfigFail makes a simulated test fail after it ran for its duration
+	ConfigFail = "fail"
+)
+
+const (
+	defaultSimulatedDuration = 5 * time.Second
+	simulatedSteps           = 4
+)
+
+// simulatedTests are the built-in test types. They do not touch a cluster; they run
+// for their configured duration, report progress in steps and return metrics shaped
+// like those of a real run.
+func simulatedTests() map[string]TestFunc {
+	tests := make(map[string]TestFunc)
+	for testType, metrics := range map[string]map[string]interface{}{
+		"load": {
+			"requests_sent":       1000,
+			"successful_requests": 995,
+			"failed_requests":     5,
+			"average_latency_ms":  45.2,
+			"p95_latency_ms":      89.7,
+			"p99_latency_ms":      156.3,
+		},
+		"connectivity": {
+			"endpoints_tested":       8,
+			"successful_connections": 8,
+			"avg_response_time_ms":   42,
+		},
+		"performance": {
+			"cpu_usage_percent":    55,
+			"memory_usage_percent": 48,
+			"requests_per_second":  1200,
+			"p95_latency_ms":       120,
+		},
+		"security": {
+			"vulnerabilities_found": 0,
+			"security_score":        92,
+			"compliant_policies":    28,
+		},
+		"compliance": {
+			"policies_checked":   40,
+			"compliant_policies": 38,
+			"compliance_score":   95,
+		},
+	} {
+		tests[testType] = simulatedTest(metrics)
+	}
+	return tests
+}
+
+func simulatedTest(metrics map[string]interface{}) TestFunc {
+	return func(ctx context.Context, run *Run) (map[string]interface{}, error) {
+		duration, ok, err := durationParam(run.Config, ConfigDuration)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			duration = defaultSimulatedDuration
+		}
+		if duration < 0 {
+			return nil, fmt.Errorf("%w: %s must not be negative", ErrInvalidConfig, ConfigDuration)
+		}
+		step := time.NewTicker(max(duration/simulatedSteps, time.Nanosecond))
+		defer step.Stop()
+		for i := 1; i <= simulatedSteps; i++ {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-step.C:
+			}
+			run.Logf("%s: step %d/%d done", run.TestType, i, simulatedSteps)
+			if i < simulatedSteps {
+				run.Progress(map[string]interface{}{"progress_percent": i * 100 / simulatedSteps})
+			}
+		}
+
+		details := map[string]interface{}{"progress_percent": 100}
+		for k, v := range metrics {
+			details[k] = v
+		}
+		if run.Cluster != nil {
+			details["provider_specific"] = providerSpecificMetrics(run.Cluster.Provider)
+		}
+		if fail, _ := run.Config[ConfigFail].(bool); fail {
+			return details, errors.New("simulated test failure")
+		}
+		return details, nil
+	}
+}
+
+func providerSpecificMetrics(provider sharedmodels.CloudProvider) map[string]interface{} {
+	switch provider {
+	case sharedmodels.CloudProviderStackIT:
+		return map[string]interface{}{
+			"stackit_specific_metric": "sample_value",
+			"project_usage":           "normal",
+			"hibernation_supported":   true,
+		}
+	case sharedmodels.CloudProviderHetzner:
+		return map[string]interface{}{
+			"hetzner_specific_metric": "sample_value",
+			"network_zone":            "eu-central",
+			"load_balancer_type":      "lb11",
+			"server_type":             "cx21",
+		}
+	case sharedmodels.CloudProviderIONOS:
+		return map[string]interface{}{
+			"ionos_specific_metric": "sample_value",
+			"datacenter_location":   "de/fra",
+			"k8s_cluster_type":      "managed",
+			"maintenance_window":    "automatic",
+		}
+	case sharedmodels.CloudProviderAzure:
+		return map[string]interface{}{
+			"azure_specific_metric":   "sample_value",
+			"aks_version":             "1.28.0",
+			"resource_group_location": "eastus",
+		}
+	case sharedmodels.CloudProviderAWS:
+		return map[string]interface{}{
+			"aws_specific_metric": "sample_value",
+			"eks_version":         "1.28",
+			"vpc_configuration":   "standard",
+		}
+	case sharedmodels.CloudProviderGCP:
+		return map[string]interface{}{
+			"gcp_specific_metric": "sample_value",
+			"gke_version":         "1.28.0",
+			"autopilot_enabled":   false,
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
@@ -0,0 +1,572 @@
+package webhooks
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/events"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"go.uber.org/zap"
+)
+
+// Dispatcher defaults
+const (
+	DefaultMaxAttempts = 6
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = 5 * time.Minute
+	DefaultTimeout     = 10 * time.Second
+	DefaultHistorySize = 1000
+	DefaultWorkers     = 4
+	DefaultQueueSize   = 1000
+)
+
+// errNotEncoded is returned when a payload cannot be encoded, which deliverLocked logs
+var errNotEncoded = errors.New("webhook payload could not be encoded")
+
+// Config tunes the deliveries; zero fields take the defaults
+type Config struct {
+	// MaxAttempts is how often a delivery is tried before it is dead
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled for every further one up
+	// to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout limits every attempt
+	Timeout time.Duration
+	// HistorySize is the number of deliveries, and of dead letters, kept
+	HistorySize int
+	// Workers is the number of deliveries sent at the same time
+	Workers int
+	// QueueSize is the number of deliveries waiting for a worker; a delivery that
+	// finds the queue full is a dead letter right away
+	QueueSize int
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = DefaultHistorySize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	return cfg
+}
+
+// Dispatcher keeps the subscriptions and sends them the events published to it.
+// Deliveries of one subscription are not ordered: a retry waits while later events
+// go out.
+type Dispatcher struct {
+	cfg    Config
+	client *http.Client
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu         sync.Mutex
+	subs       map[string]*Subscription
+	deliveries []*Delivery
+	dead       []*Delivery
+	// retries are the deliveries waiting for their next attempt, soonest first
+	retries retryHeap
+
+	// queue feeds the workers; wake tells the scheduler of a new retry; ctx stops
+	// Run, the workers and the scheduler
+	queue  chan *Delivery
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewDispatcher returns a dispatcher for cfg with its workers running
+func NewDispatcher(cfg Config, logger *zap.Logger) *Dispatcher {
+	cfg = cfg.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		cfg: cfg,
+		client: &http.Client{
+			// A redirect is a failed attempt; following it would turn the POST into a GET
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		logger: logger,
+		now:    time.Now,
+		subs:   make(map[string]*Subscription),
+		queue:  make(chan *Delivery, cfg.QueueSize),
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		go d.work()
+	}
+	go d.schedule()
+	return d
+}
+
+// Close stops Run, the workers and the retries; attempts in flight are aborted
+func (d *Dispatcher) Close() {
+	d.cancel()
+}
+
+// Run sends the events of broker until Close. When it falls behind and the broker
+// drops it, it subscribes again and catches up from the broker's history.
+func (d *Dispatcher) Run(broker *events.Broker) {
+	var last uint64
+	replay := false
+	for {
+		sub, missed := broker.Subscribe(events.Filter{}, last, replay)
+		for _, event := range missed {
+			d.Publish(event)
+			last = event.ID
+		}
+	stream:
+		for {
+			select {
+			case <-d.ctx.Done():
+				sub.Close()
+				return
+			case event, ok := <-sub.C:
+				if !ok {
+					break stream
+				}
+				d.Publish(event)
+				last = event.ID
+			}
+		}
+		replay = true
+	}
+}
+
+// Publish creates a delivery of event for every enabled subscription of its project
+// that wants its type
+func (d *Dispatcher) Publish(event sharedmodels.Event) {
+	eventType := EventType(event)
+	if eventType == "" {
+		return
+	}
+	project := event.Project
+	if project == "" {
+		project = sharedmodels.DefaultProject
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, sub := range d.subs {
+		if sub.Project != project || sub.Disabled || !sub.Matches(eventType) {
+			continue
+		}
+		d.deliverLocked(sub, Payload{
+			Type:       eventType,
+			Time:       event.Time,
+			Project:    project,
+			Resource:   event.Resource,
+			ResourceID: event.ResourceID,
+			Message:    event.Message,
+			Data:       event.Data,
+			EventID:    event.ID,
+		}, "")
+	}
+}
+
+// deliverLocked records a delivery of payload to sub and sends it; the caller holds d.mu
+func (d *Dispatcher) deliverLocked(sub *Subscription, payload Payload, redeliveryOf string) *Delivery {
+	payload.ID = uuid.New().String()
+	if payload.Time.IsZero() {
+		payload.Time = d.now().UTC()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		// Event data comes from the server itself, so this is a bug
+		d.logger.Error("Failed to encode webhook payload", zap.String("type", payload.Type), zap.Error(err))
+		return nil
+	}
+	delivery := &Delivery{
+		ID:             payload.ID,
+		SubscriptionID: sub.ID,
+		Project:        sub.Project,
+		EventType:      payload.Type,
+		Status:         StatusPending,
+		Attempts:       []Attempt{},
+		RedeliveryOf:   redeliveryOf,
+		Payload:        payload,
+		CreatedAt:      d.now().UTC(),
+		body:           body,
+	}
+	d.deliveries = append(d.deliveries, delivery)
+	if len(d.deliveries) > d.cfg.HistorySize {
+		d.deliveries = append(d.deliveries[:0], d.deliveries[len(d.deliveries)-d.cfg.HistorySize:]...)
+	}
+	d.enqueueLocked(delivery)
+	return delivery
+}
+
+// enqueueLocked hands delivery to the workers; when they are QueueSize deliveries
+// behind, it is a dead letter instead. The caller holds d.mu.
+func (d *Dispatcher) enqueueLocked(delivery *Delivery) {
+	select {
+	case d.queue <- delivery:
+	default:
+		delivery.Attempts = append(delivery.Attempts, Attempt{At: d.now().UTC(), Error: "delivery queue full"})
+		d.deadLocked(delivery)
+	}
+}
+
+// work sends the queued deliveries until Close
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case delivery := <-d.queue:
+			d.attempt(delivery)
+		}
+	}
+}
+
+// schedule queues the retries when they are due, until Close
+func (d *Dispatcher) schedule() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		// Since Go 1.23 Reset drops a value the timer sent but nobody received
+		timer.Reset(d.queueDueRetries())
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// queueDueRetries queues the retries that are due and returns the wait until the
+// next one, or an hour when none is left
+func (d *Dispatcher) queueDueRetries() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.retries) > 0 {
+		if wait := d.retries[0].at.Sub(d.now()); wait > 0 {
+			return wait
+		}
+		d.enqueueLocked(heap.Pop(&d.retries).(retry).delivery)
+	}
+	return time.Hour
+}
+
+// attempt sends delivery once and schedules the retry if it failed
+func (d *Dispatcher) attempt(delivery *Delivery) {
+	d.mu.Lock()
+	sub := d.subs[delivery.SubscriptionID]
+	if sub == nil || sub.Disabled {
+		d.finishLocked(delivery, StatusCancelled)
+		d.mu.Unlock()
+		return
+	}
+	target, secret := sub.URL, sub.Secret
+	delivery.NextAttemptAt = nil
+	d.mu.Unlock()
+
+	started := d.now()
+	status, err := d.post(target, secret, delivery)
+	attempt := Attempt{At: started.UTC(), StatusCode: status, Duration: d.now().Sub(started)}
+	switch {
+	case err != nil:
+		attempt.Error = err.Error()
+	case status < 200 || status > 299:
+		attempt.Error = fmt.Sprintf("receiver answered %d %s", status, http.StatusText(status))
+	}
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	switch {
+	case attempt.Error == "":
+		d.finishLocked(delivery, StatusSucceeded)
+	case len(delivery.Attempts) >= d.cfg.MaxAttempts:
+		d.deadLocked(delivery)
+	default:
+		next := d.now().Add(d.backoff(len(delivery.Attempts))).UTC()
+		delivery.Status, delivery.NextAttemptAt = StatusRetrying, &next
+		heap.Push(&d.retries, retry{at: next, delivery: delivery})
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// deadLocked ends a delivery as a dead letter; the caller holds d.mu
+func (d *Dispatcher) deadLocked(delivery *Delivery) {
+	d.finishLocked(delivery, StatusDead)
+	d.dead = append(d.dead, delivery)
+	if len(d.dead) > d.cfg.HistorySize {
+		d.dead = append(d.dead[:0], d.dead[len(d.dead)-d.cfg.HistorySize:]...)
+	}
+	d.logger.Warn("Webhook delivery failed for good",
+		zap.String("delivery", delivery.ID),
+		zap.String("subscription", delivery.SubscriptionID),
+		zap.String("type", delivery.EventType),
+		zap.String("error", delivery.Attempts[len(delivery.Attempts)-1].Error))
+}
+
+// retry is a delivery waiting for its next attempt at at
+type retry struct {
+	at       time.Time
+	delivery *Delivery
+}
+
+// retryHeap orders the retries by due time for container/heap
+type retryHeap []retry
+
+func (h retryHeap) Len() int           { return len(h) }
+func (h retryHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h retryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *retryHeap) Push(x any)        { *h = append(*h, x.(retry)) }
+func (h *retryHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// backoff is the wait after the given number of failed attempts
+func (d *Dispatcher) backoff(failed int) time.Duration {
+	wait := d.cfg.Backoff
+	for i := 1; i < failed && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.cfg.MaxBackoff {
+		wait = d.cfg.MaxBackoff
+	}
+	return wait
+}
+
+// finishLocked ends a delivery with status; the caller holds d.mu
+func (d *Dispatcher) finishLocked(delivery *Delivery, status string) {
+	now := d.now().UTC()
+	delivery.Status, delivery.CompletedAt, delivery.NextAttemptAt = status, &now, nil
+}
+
+// post sends the signed payload of delivery and returns the receiver's status code
+func (d *Dispatcher) post(target, secret string, delivery *Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(delivery.body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cube-server-webhooks")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(TimestampHeader, fmt.Sprint(timestamp))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, delivery.body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the answer so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// Subscriptions returns the subscriptions of a project
+func (d *Dispatcher) Subscriptions(project string) []Subscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	subs := []Subscription{}
+	for _, sub := range d.subs {
+		if sub.Project == project {
+			subs = append(subs, sub.Redacted())
+		}
+	}
+	return subs
+}
+
+// Subscription returns a subscription of a project without its secret
+func (d *Dispatcher) Subscription(project, id string) (Subscription, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sub := d.subs[id]
+	if sub == nil || sub.Project != project {
+		return Subscription{}, ErrNotFound
+	}
+	return sub.Redacted(), nil
+}
+
+// Subscribe adds a subscription to the project of sub and returns it with its
+// secret, which is generated unless sub has one
+func (d *Dispatcher) Subscribe(sub Subscription) (Subscription, error) {
+	if err := sub.validate(); err != nil {
+		return Subscription{}, err
+	}
+	if sub.Secret == "" {
+		sub.Secret = newSecret()
+	}
+	now := d.now().UTC()
+	sub.ID = uuid.New().String()
+	sub.Events = append([]string(nil), sub.Events...)
+	sub.ResourceVersion, sub.CreatedAt, sub.UpdatedAt = 1, now, now
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subs[sub.ID] = &sub
+	return sub, nil
+}
+
+// Update replaces the URL, filters, description and state of a subscription; its
+// secret is kept unless sub has a new one
+func (d *Dispatcher) Update(project, id string, sub Subscription) (Subscription, error) {
+	if err := sub.validate(); err != nil {
+		return Subscription{}, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	existing := d.subs[id]
+	if existing == nil || existing.Project != project {
+		return Subscription{}, ErrNotFound
+	}
+	if sub.Secret == "" {
+		sub.Secret = existing.Secret
+	}
+	sub.ID, sub.Project, sub.CreatedAt = existing.ID, existing.Project, existing.CreatedAt
+	sub.Events = append([]string(nil), sub.Events...)
+	sub.ResourceVersion, sub.UpdatedAt = existing.ResourceVersion+1, d.now().UTC()
+	d.subs[id] = &sub
+	return sub.Redacted(), nil
+}
+
+// Unsubscribe removes a subscription; its pending retries are cancelled
+func (d *Dispatcher) Unsubscribe(project, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sub := d.subs[id]
+	if sub == nil || sub.Project != project {
+		return ErrNotFound
+	}
+	delete(d.subs, id)
+	return nil
+}
+
+// Ping sends a ping event to a subscription, whatever its filters
+func (d *Dispatcher) Ping(project, id string) (Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sub := d.subs[id]
+	if sub == nil || sub.Project != project {
+		return Delivery{}, ErrNotFound
+	}
+	delivery := d.deliverLocked(sub, Payload{Type: EventPing, Project: project, Message: "ping"}, "")
+	if delivery == nil {
+		return Delivery{}, errNotEncoded
+	}
+	return delivery.copy(), nil
+}
+
+// Deliveries returns the kept deliveries of a project, of one subscription unless
+// subscriptionID is empty
+func (d *Dispatcher) Deliveries(project, subscriptionID string) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	deliveries := []Delivery{}
+	for _, delivery := range d.deliveries {
+		if delivery.Project == project && (subscriptionID == "" || delivery.SubscriptionID == subscriptionID) {
+			deliveries = append(deliveries, delivery.copy())
+		}
+	}
+	return deliveries
+}
+
+// DeadLetters returns the deliveries of a project that ran out of attempts
+func (d *Dispatcher) DeadLetters(project string) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dead := []Delivery{}
+	for _, delivery := range d.dead {
+		if delivery.Project == project {
+			dead = append(dead, delivery.copy())
+		}
+	}
+	return dead
+}
+
+// Redeliver sends a dead letter again as a new delivery with a fresh set of attempts
+// and removes it from the dead letters
+func (d *Dispatcher) Redeliver(project, id string) (Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, dead := range d.dead {
+		if dead.ID != id || dead.Project != project {
+			continue
+		}
+		sub := d.subs[dead.SubscriptionID]
+		if sub == nil {
+			return Delivery{}, fmt.Errorf("%w: subscription %s was deleted", ErrInvalid, dead.SubscriptionID)
+		}
+		// Removed first: delivering may add dead letters and trim the list
+		d.dead = append(d.dead[:i], d.dead[i+1:]...)
+		delivery := d.deliverLocked(sub, dead.Payload, dead.ID)
+		if delivery == nil {
+			d.dead = append(d.dead, dead)
+			return Delivery{}, errNotEncoded
+		}
+		return delivery.copy(), nil
+	}
+	return Delivery{}, ErrNotFound
+}
+
+// DeleteProject removes the subscriptions and dead letters of a project
+func (d *Dispatcher) DeleteProject(project string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, sub := range d.subs {
+		if sub.Project == project {
+			delete(d.subs, id)
+		}
+	}
+	dead := d.dead[:0]
+	for _, delivery := range d.dead {
+		if delivery.Project != project {
+			dead = append(dead, delivery)
+		}
+	}
+	d.dead = dead
+}
+
+// newSecret returns a random signing secret
+func newSecret() string {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	return "whsec_" + hex.EncodeToString(secret)
+}
@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Delivery headers. The signature is the hex HMAC-SHA256 of "<timestamp>.<body>"
+// with the subscription's secret, prefixed with "sha256="; receivers check it with
+// Verify.
+const (
+	SignatureHeader = "X-Cube-Signature"
+	TimestampHeader = "X-Cube-Timestamp"
+	EventHeader     = "X-Cube-Event"
+	DeliveryHeader  = "X-Cube-Delivery"
+)
+
+// DefaultTolerance is how old a delivery's timestamp may be for Verify
+const DefaultTolerance = 5 * time.Minute
+
+const signatureScheme = "sha256="
+
+var (
+	ErrNoSignature    = errors.New("delivery is not signed")
+	ErrBadTimestamp   = errors.New("invalid delivery timestamp")
+	ErrBadSignature   = errors.New("delivery signature does not match")
+	ErrStaleTimestamp = errors.New("delivery timestamp is too old")
+)
+
+// Sign returns the signature header value of body sent at timestamp (Unix seconds)
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureScheme + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a delivery against secret.
+// Timestamps older than tolerance are refused so recorded deliveries cannot be
+// replayed later; tolerance <= 0 uses DefaultTolerance.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	signature := header.Get(SignatureHeader)
+	if signature == "" || !strings.HasPrefix(signature, signatureScheme) {
+		return ErrNoSignature
+	}
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrBadTimestamp
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrBadSignature
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return ErrStaleTimestamp
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+// Package webhooks delivers resource and test lifecycle events to the URLs of
+// subscriptions. Every delivery is a signed JSON POST (see signature.go); failed ones
+// are retried with exponential backoff and end up in the dead letters once they run
+// out of attempts. Subscriptions, deliveries and dead letters are kept in memory only:
+// they are lost when the server restarts, and snapshots do not include them.
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	// ErrInvalid wraps the reason a subscription is refused
+	ErrInvalid = errors.New("invalid subscription")
+)
+
+// Event types. Clusters of the store and of the simulation share the cluster types;
+// a simulated cluster reaching a status is cluster.<status>, e.g. cluster.running.
+const (
+	EventClusterCreated = "cluster.created"
+	EventClusterUpdated = "cluster.updated"
+	EventClusterDeleted = "cluster.deleted"
+	EventTestCreated    = "test.created"
+	EventTestStarted    = "test.started"
+	// EventTestCompleted is sent when a test passed, failed or was cancelled; the
+	// status is in the data
+	EventTestCompleted = "test.completed"
+	EventBucketCreated = "bucket.created"
+	EventBucketDeleted = "bucket.deleted"
+	// EventPing is only sent by Ping, whatever the subscription's filters
+	EventPing = "ping"
+)
+
+// EventTypes are the types subscriptions can filter on, besides * and <kind>.*
+var EventTypes = []string{
+	EventClusterCreated, EventClusterUpdated, EventClusterDeleted,
+	"cluster." + string(sharedmodels.ClusterStatusRunning),
+	"cluster." + string(sharedmodels.ClusterStatusStopping),
+	"cluster." + string(sharedmodels.ClusterStatusStopped),
+	"cluster." + string(sharedmodels.ClusterStatusDeleting),
+	"cluster." + string(sharedmodels.ClusterStatusFailed),
+	EventTestCreated, EventTestStarted, EventTestCompleted,
+	EventBucketCreated, EventBucketDeleted,
+}
+
+// Subscription sends the events of a project whose type matches one of Events to URL
+type Subscription struct {
+	ID      string `json:"id"`
+	Project string `json:"project"`
+	URL     string `json:"url"`
+	// Events are event types, <kind>.* (e.g. test.*) or * for everything
+	Events      []string `json:"events"`
+	Description string   `json:"description,omitempty"`
+	// Secret signs the deliveries. It is generated unless given and only answered
+	// when the subscription is created.
+	Secret string `json:"secret,omitempty"`
+	// Disabled subscriptions get no new deliveries
+	Disabled        bool      `json:"disabled,omitempty"`
+	ResourceVersion int64     `json:"resource_version,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Redacted returns the subscription without its secret
+func (s Subscription) Redacted() Subscription {
+	s.Secret = ""
+	s.Events = append([]string(nil), s.Events...)
+	return s
+}
+
+// Matches reports whether the subscription wants events of eventType
+func (s *Subscription) Matches(eventType string) bool {
+	kind, _, _ := strings.Cut(eventType, ".")
+	for _, filter := range s.Events {
+		if filter == "*" || filter == eventType || filter == kind+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the URL and filters of a subscription
+func (s *Subscription) validate() error {
+	target, err := url.Parse(s.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalid)
+	}
+	if len(s.Events) == 0 {
+		return fmt.Errorf("%w: events must name at least one event type, <kind>.* or *", ErrInvalid)
+	}
+	for _, filter := range s.Events {
+		if !validFilter(filter) {
+			return fmt.Errorf("%w: unknown event type %q, expected *, cluster.*, test.*, bucket.* or one of %s",
+				ErrInvalid, filter, strings.Join(EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+func validFilter(filter string) bool {
+	switch filter {
+	case "*", "cluster.*", "test.*", "bucket.*":
+		return true
+	}
+	for _, eventType := range EventTypes {
+		if filter == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// EventType returns the webhook event type of a broker event, or "" for events that
+// are not sent (test progress and logs)
+func EventType(event sharedmodels.Event) string {
+	status, _ := event.Data["status"].(string)
+	switch event.Resource {
+	case sharedmodels.EventResourceClusters, sharedmodels.EventResourceSimulatedClusters:
+		switch event.Type {
+		case sharedmodels.EventCreated:
+			return EventClusterCreated
+		case sharedmodels.EventUpdated:
+			return EventClusterUpdated
+		case sharedmodels.EventDeleted:
+			return EventClusterDeleted
+		case sharedmodels.EventStatus:
+			if status != "" {
+				return "cluster." + status
+			}
+		}
+	case sharedmodels.EventResourceTests:
+		switch event.Type {
+		case sharedmodels.EventCreated:
+			return EventTestCreated
+		case sharedmodels.EventStatus:
+			switch sharedmodels.TestStatus(status) {
+			case sharedmodels.TestStatusRunning:
+				return EventTestStarted
+			case sharedmodels.TestStatusPassed, sharedmodels.TestStatusFailed, sharedmodels.TestStatusCancelled:
+				return EventTestCompleted
+			}
+		}
+	case sharedmodels.EventResourceBuckets:
+		switch event.Type {
+		case sharedmodels.EventCreated:
+			return EventBucketCreated
+		case sharedmodels.EventDeleted:
+			return EventBucketDeleted
+		}
+	}
+	return ""
+}
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	// ID is the delivery's ID; retries send the same one
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Time       time.Time              `json:"time"`
+	Project    string                 `json:"project"`
+	Resource   string                 `json:"resource,omitempty"`
+	ResourceID string                 `json:"resource_id,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	// EventID is the ID of the event on GET /api/v1/events
+	EventID uint64 `json:"event_id,omitempty"`
+}
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusRetrying  = "retrying"
+	StatusSucceeded = "succeeded"
+	// StatusDead deliveries ran out of attempts and are kept in the dead letters
+	StatusDead = "dead"
+	// StatusCancelled deliveries lost their subscription, or it was disabled
+	StatusCancelled = "cancelled"
+)
+
+// Delivery is the sending of one event to one subscription, with every attempt
+type Delivery struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscription_id"`
+	Project        string    `json:"project"`
+	EventType      string    `json:"event_type"`
+	Status         string    `json:"status"`
+	Attempts       []Attempt `json:"attempts"`
+	// NextAttemptAt is set while the delivery waits for a retry
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	// RedeliveryOf is the dead delivery this one sends again
+	RedeliveryOf string     `json:"redelivery_of,omitempty"`
+	Payload      Payload    `json:"payload"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+
+	// body is the signed payload; retries send it unchanged
+	body []byte
+}
+
+// Attempt is one POST of a delivery
+type Attempt struct {
+	At time.Time `json:"at"`
+	// StatusCode is the receiver's answer; 0 when there was none
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// copy returns a copy that does not share the attempts
+func (d *Delivery) copy() Delivery {
+	c := *d
+	c.Attempts = append([]Attempt{}, d.Attempts...)
+	c.body = nil
+	return c
+}
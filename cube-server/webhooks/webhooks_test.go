@@ -0,0 +1,378 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/cube-server/events"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"go.uber.org/zap"
+)
+
+// receiver is a webhook endpoint that checks signatures and answers the next of
+// statuses (then 200)
+type receiver struct {
+	*httptest.Server
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	got      chan Payload
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{t: t, statuses: statuses, got: make(chan Payload, 16)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		secret := r.secret
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mu.Unlock()
+		if err := Verify(secret, req.Header, body, 0); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+		var payload Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("payload: %v", err)
+		}
+		if req.Header.Get(EventHeader) != payload.Type || req.Header.Get(DeliveryHeader) != payload.ID {
+			t.Errorf("headers %v do not match payload %+v", req.Header, payload)
+		}
+		w.WriteHeader(status)
+		r.got <- payload
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) wait(t *testing.T) Payload {
+	t.Helper()
+	select {
+	case payload := <-r.got:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery received")
+		return Payload{}
+	}
+}
+
+func newTestDispatcher(t *testing.T, cfg Config) *Dispatcher {
+	d := NewDispatcher(cfg, zap.NewNop())
+	t.Cleanup(d.Close)
+	return d
+}
+
+func subscribe(t *testing.T, d *Dispatcher, r *receiver, eventTypes ...string) Subscription {
+	t.Helper()
+	sub, err := d.Subscribe(Subscription{Project: sharedmodels.DefaultProject, URL: r.URL, Events: eventTypes})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	r.mu.Lock()
+	r.secret = sub.Secret
+	r.mu.Unlock()
+	return sub
+}
+
+// waitFor polls the deliveries of sub until one has status, the one with id unless
+// id is empty
+func waitFor(t *testing.T, d *Dispatcher, sub Subscription, id, status string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, delivery := range d.Deliveries(sub.Project, sub.ID) {
+			if delivery.Status == status && (id == "" || delivery.ID == id) {
+				return delivery
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no %s delivery in %+v", status, d.Deliveries(sub.Project, sub.ID))
+	return Delivery{}
+}
+
+func TestDispatcherDeliversMatchingEvents(t *testing.T) {
+	r := newReceiver(t)
+	d := newTestDispatcher(t, Config{})
+	sub := subscribe(t, d, r, "test.*", EventClusterDeleted)
+	if sub.Secret == "" || sub.ResourceVersion != 1 {
+		t.Fatalf("Subscribe = %+v, want a generated secret and version 1", sub)
+	}
+	if got, _ := d.Subscription(sub.Project, sub.ID); got.Secret != "" {
+		t.Error("Subscription answered the secret")
+	}
+
+	broker := events.NewBroker(0)
+	go d.Run(broker)
+	// Run subscribes asynchronously, so announce deleted clusters until one arrives
+	deleted := sharedmodels.Event{Resource: sharedmodels.EventResourceClusters, ResourceID: "c-0", Type: sharedmodels.EventDeleted}
+	for subscribed := false; !subscribed; {
+		broker.Publish(deleted)
+		select {
+		case payload := <-r.got:
+			subscribed = payload.Type == EventClusterDeleted
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	broker.Publish(sharedmodels.Event{Resource: sharedmodels.EventResourceClusters, ResourceID: "c-1", Type: sharedmodels.EventCreated})
+	broker.Publish(sharedmodels.Event{Resource: sharedmodels.EventResourceTests, ResourceID: "t-1", Type: sharedmodels.EventStatus,
+		Data: map[string]interface{}{"status": "passed"}})
+	broker.Publish(sharedmodels.Event{Project: "other", Resource: sharedmodels.EventResourceTests, ResourceID: "t-2", Type: sharedmodels.EventCreated})
+
+	payload := r.wait(t)
+	for payload.Type == EventClusterDeleted {
+		payload = r.wait(t)
+	}
+	if payload.Type != EventTestCompleted || payload.ResourceID != "t-1" || payload.Data["status"] != "passed" || payload.EventID == 0 {
+		t.Errorf("payload = %+v, want test.completed of t-1", payload)
+	}
+	delivery := waitFor(t, d, sub, payload.ID, StatusSucceeded)
+	if len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusOK {
+		t.Errorf("delivery attempts = %+v", delivery.Attempts)
+	}
+	select {
+	case extra := <-r.got:
+		if extra.Type != EventClusterDeleted {
+			t.Errorf("unexpected delivery %+v", extra)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatcherRetriesAndDeadLetters(t *testing.T) {
+	r := newReceiver(t, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusBadGateway)
+	d := newTestDispatcher(t, Config{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})
+	sub := subscribe(t, d, r, "*")
+
+	d.Publish(sharedmodels.Event{Resource: sharedmodels.EventResourceBuckets, ResourceID: "b-1", Type: sharedmodels.EventDeleted})
+	first := r.wait(t)
+	for i := 0; i < 2; i++ {
+		if retry := r.wait(t); retry.ID != first.ID {
+			t.Errorf("retry sent delivery %s, want %s", retry.ID, first.ID)
+		}
+	}
+	dead := waitFor(t, d, sub, first.ID, StatusDead)
+	if len(dead.Attempts) != 3 || dead.Attempts[2].StatusCode != http.StatusBadGateway || dead.Attempts[2].Error == "" {
+		t.Errorf("dead delivery attempts = %+v", dead.Attempts)
+	}
+	letters := d.DeadLetters(sub.Project)
+	if len(letters) != 1 || letters[0].ID != first.ID || letters[0].EventType != EventBucketDeleted {
+		t.Fatalf("DeadLetters = %+v", letters)
+	}
+
+	redelivery, err := d.Redeliver(sub.Project, first.ID)
+	if err != nil || redelivery.RedeliveryOf != first.ID || redelivery.ID == first.ID {
+		t.Fatalf("Redeliver = %+v, %v", redelivery, err)
+	}
+	if again := r.wait(t); again.ID != redelivery.ID || again.ResourceID != "b-1" {
+		t.Errorf("redelivered payload = %+v", again)
+	}
+	waitFor(t, d, sub, redelivery.ID, StatusSucceeded)
+	if letters := d.DeadLetters(sub.Project); len(letters) != 0 {
+		t.Errorf("dead letters after redelivery = %+v", letters)
+	}
+	if _, err := d.Redeliver(sub.Project, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Redeliver: %v, want ErrNotFound", err)
+	}
+}
+
+func TestDispatcherCancelsRetriesOfDeletedSubscriptions(t *testing.T) {
+	failures := make([]int, DefaultMaxAttempts)
+	for i := range failures {
+		failures[i] = http.StatusInternalServerError
+	}
+	r := newReceiver(t, failures...)
+	d := newTestDispatcher(t, Config{Backoff: 20 * time.Millisecond})
+	sub := subscribe(t, d, r, "bucket.*")
+	if _, err := d.Ping(sub.Project, sub.ID); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if ping := r.wait(t); ping.Type != EventPing {
+		t.Errorf("ping payload = %+v", ping)
+	}
+	waitFor(t, d, sub, "", StatusRetrying)
+	if err := d.Unsubscribe(sub.Project, sub.ID); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	waitFor(t, d, sub, "", StatusCancelled)
+	if err := d.Unsubscribe(sub.Project, sub.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Unsubscribe: %v, want ErrNotFound", err)
+	}
+}
+
+func TestDispatcherQueueFull(t *testing.T) {
+	started, release := make(chan struct{}, 4), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	// runs before srv.Close, which waits for the blocked handlers
+	t.Cleanup(unblock)
+	d := newTestDispatcher(t, Config{Workers: 1, QueueSize: 1})
+	sub, err := d.Subscribe(Subscription{Project: sharedmodels.DefaultProject, URL: srv.URL, Events: []string{"*"}})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	publish := func(id string) {
+		d.Publish(sharedmodels.Event{Resource: sharedmodels.EventResourceBuckets, ResourceID: id, Type: sharedmodels.EventCreated})
+	}
+
+	// The only worker is busy with b-1 and b-2 fills the queue, so b-3 is dead at once
+	publish("b-1")
+	<-started
+	publish("b-2")
+	publish("b-3")
+	letters := d.DeadLetters(sub.Project)
+	if len(letters) != 1 || letters[0].Payload.ResourceID != "b-3" || letters[0].Attempts[0].Error != "delivery queue full" {
+		t.Fatalf("DeadLetters = %+v", letters)
+	}
+	unblock()
+	deadline := time.Now().Add(5 * time.Second)
+	for succeeded := 0; succeeded != 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries = %+v, want b-1 and b-2 succeeded", d.Deliveries(sub.Project, sub.ID))
+		}
+		time.Sleep(5 * time.Millisecond)
+		succeeded = 0
+		for _, delivery := range d.Deliveries(sub.Project, sub.ID) {
+			if delivery.Status == StatusSucceeded {
+				succeeded++
+			}
+		}
+	}
+}
+
+func TestSubscriptionValidation(t *testing.T) {
+	d := newTestDispatcher(t, Config{})
+	for _, sub := range []Subscription{
+		{URL: "ftp://example.com/hook", Events: []string{"*"}},
+		{URL: "/hook", Events: []string{"*"}},
+		{URL: "https://example.com/hook"},
+		{URL: "https://example.com/hook", Events: []string{"cluster.exploded"}},
+	} {
+		if _, err := d.Subscribe(sub); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Subscribe(%+v) = %v, want ErrInvalid", sub, err)
+		}
+	}
+
+	sub, err := d.Subscribe(Subscription{Project: "p", URL: "https://example.com/hook", Events: []string{"cluster.running"}, Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if _, err := d.Update("other", sub.ID, sub); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update in another project: %v, want ErrNotFound", err)
+	}
+	updated, err := d.Update("p", sub.ID, Subscription{URL: "https://example.com/v2", Events: []string{"cluster.*"}})
+	if err != nil || updated.ResourceVersion != 2 || updated.URL != "https://example.com/v2" {
+		t.Fatalf("Update = %+v, %v", updated, err)
+	}
+	d.mu.Lock()
+	secret := d.subs[sub.ID].Secret
+	d.mu.Unlock()
+	if secret != "s3cret" {
+		t.Errorf("Update without a secret changed it to %q", secret)
+	}
+}
+
+func TestEventType(t *testing.T) {
+	for _, tc := range []struct {
+		event sharedmodels.Event
+		want  string
+	}{
+		{sharedmodels.Event{Resource: sharedmodels.EventResourceClusters, Type: sharedmodels.EventUpdated}, EventClusterUpdated},
+		{sharedmodels.Event{Resource: sharedmodels.EventResourceSimulatedClusters, Type: sharedmodels.EventStatus,
+			Data: map[string]interface{}{"status": "running"}}, "cluster.running"},
+		{sharedmodels.Event{Resource: sharedmodels.EventResourceTests, Type: sharedmodels.EventStatus,
+			Data: map[string]interface{}{"status": "running"}}, EventTestStarted},
+		{sharedmodels.Event{Resource: sharedmodels.EventResourceTests, Type: sharedmodels.EventStatus,
+			Data: map[string]interface{}{"status": "cancelled"}}, EventTestCompleted},
+		{sharedmodels.Event{Resource: sharedmodels.EventResourceTests, Type: sharedmodels.EventProgress}, ""},
+		{sharedmodels.Event{Resource: sharedmodels.EventResourceBuckets, Type: sharedmodels.EventCreated}, EventBucketCreated},
+	} {
+		if got := EventType(tc.event); got != tc.want {
+			t.Errorf("EventType(%+v) = %q, want %q", tc.event, got, tc.want)
+		}
+	}
+
+	sub := Subscription{Events: []string{"test.*", "cluster.deleted"}}
+	for eventType, want := range map[string]bool{EventTestStarted: true, EventClusterDeleted: true, EventClusterCreated: false, EventBucketCreated: false} {
+		if got := sub.Matches(eventType); got != want {
+			t.Errorf("Matches(%s) = %v, want %v", eventType, got, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"ping"}`)
+	now := time.Now().Unix()
+	header := func(secret string, timestamp int64) http.Header {
+		h := http.Header{}
+		h.Set(SignatureHeader, Sign(secret, timestamp, body))
+		h.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		return h
+	}
+	if err := Verify("secret", header("secret", now), body, 0); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+	if err := Verify("secret", header("other", now), body, 0); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify with another secret = %v, want ErrBadSignature", err)
+	}
+	if err := Verify("secret", header("secret", now), []byte(`{}`), 0); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify of another body = %v, want ErrBadSignature", err)
+	}
+	if err := Verify("secret", header("secret", now-3600), body, time.Minute); !errors.Is(err, ErrStaleTimestamp) {
+		t.Errorf("Verify of an old delivery = %v, want ErrStaleTimestamp", err)
+	}
+	if err := Verify("secret", http.Header{}, body, 0); !errors.Is(err, ErrNoSignature) {
+		t.Errorf("Verify without a signature = %v, want ErrNoSignature", err)
+	}
+}
+
+func TestDispatcherRedeliverWithFullDeadLetters(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	d := newTestDispatcher(t, Config{Workers: 1, QueueSize: 1, HistorySize: 2})
+	sub, err := d.Subscribe(Subscription{Project: sharedmodels.DefaultProject, URL: srv.URL, Events: []string{"*"}})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	for _, id := range []string{"b-1", "b-2", "b-3", "b-4"} {
+		d.Publish(sharedmodels.Event{Resource: sharedmodels.EventResourceBuckets, ResourceID: id, Type: sharedmodels.EventCreated})
+		if id == "b-1" {
+			<-started
+		}
+	}
+
+	// The queue is still full, so the redelivery is dead at once and takes the place of
+	// the dead letter it redelivers in the full list
+	letters := d.DeadLetters(sub.Project)
+	if len(letters) != 2 {
+		t.Fatalf("DeadLetters = %+v, want b-3 and b-4", letters)
+	}
+	redelivery, err := d.Redeliver(sub.Project, letters[1].ID)
+	if err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	letters = d.DeadLetters(sub.Project)
+	if len(letters) != 2 || letters[0].Payload.ResourceID != "b-3" || letters[1].ID != redelivery.ID || letters[1].Payload.ResourceID != "b-4" {
+		t.Errorf("DeadLetters after redelivery = %+v", letters)
+	}
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -206,6 +208,48 @@ Examples:
 	},
 }
 
+// testWatchCmd follows a test through the cube-server event stream
+var testWatchCmd = &cobra.Command{
+	Use:   "watch [test-id]",
+	Short: "Follow a running test until it finishes",
+	Long: `Stream status changes, progress and log lines of a test from cube-server.
+The stream reconnects on its own and resumes where it left off.
+
+Examples:
+  multitool test watch 0b6c... --server http://localhost:8080`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := requireServerClient("watch tests")
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		final, err := apiClient.WatchTest(ctx, args[0], func(event sharedmodels.Event) {
+			switch event.Type {
+			case sharedmodels.EventCreated, sharedmodels.EventStatus:
+				fmt.Printf("%s  status   %v %s\n", event.Time.Local().Format("15:04:05"), event.Data["status"], event.Message)
+			case sharedmodels.EventProgress:
+				fmt.Printf("%s  progress %v%%\n", event.Time.Local().Format("15:04:05"), event.Data["progress_percent"])
+			case sharedmodels.EventLog:
+				fmt.Printf("%s  log      %s\n", event.Time.Local().Format("15:04:05"), event.Message)
+			}
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if final.Data["status"] != string(sharedmodels.TestStatusPassed) {
+			return fmt.Errorf("test %s %v", args[0], final.Data["status"])
+		}
+		output.FormatSuccess(fmt.Sprintf("test %s passed in %v", args[0], final.Data["duration"]))
+		return nil
+	},
+}
+
 // Helper functions
 
 func isValidProvider(provider sharedmodels.CloudProvider) bool {
@@ -240,6 +284,7 @@ func init() {
 	testCmd.AddCommand(testRunCmd)
 	testCmd.AddCommand(testListCmd)
 	testCmd.AddCommand(testGetCmd)
+	testCmd.AddCommand(testWatchCmd)
 
 	// Global flags
 	clusterCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format (table, json, yaml)")
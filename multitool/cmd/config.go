@@ -7,6 +7,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/tronicum/punchbag-cube-testsuite/multitool/pkg/output"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
 	"gopkg.in/yaml.v2"
 )
 
@@ -21,13 +22,15 @@ type Config struct {
 
 // Profile represents a configuration profile for different environments
 type Profile struct {
-	ServerURL     string            `json:"server_url" yaml:"server_url"`
-	Provider      string            `json:"provider" yaml:"provider"`
-	Region        string            `json:"region" yaml:"region"`
-	ResourceGroup string            `json:"resource_group,omitempty" yaml:"resource_group,omitempty"`
-	ProjectID     string            `json:"project_id,omitempty" yaml:"project_id,omitempty"`
-	Location      string            `json:"location,omitempty" yaml:"location,omitempty"`
-	Tags          map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	ServerURL     string `json:"server_url" yaml:"server_url"`
+	Provider      string `json:"provider" yaml:"provider"`
+	Region        string `json:"region" yaml:"region"`
+	ResourceGroup string `json:"resource_group,omitempty" yaml:"resource_group,omitempty"`
+	ProjectID     string `json:"project_id,omitempty" yaml:"project_id,omitempty"`
+	// Project is the cube-server project (tenant) requests to the server act on
+	Project  string            `json:"project,omitempty" yaml:"project,omitempty"`
+	Location string            `json:"location,omitempty" yaml:"location,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
 }
 
 var (
@@ -155,7 +158,8 @@ var configSetCmd = &cobra.Command{
 Examples:
   multitool config set server_url http://localhost:8080
   multitool config set --profile development provider aws
-  multitool config set --profile production region us-west-2`,
+  multitool config set --profile production region us-west-2
+  multitool config set --profile default project team-a`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		key := args[0]
@@ -203,6 +207,12 @@ Examples:
 				profile.ResourceGroup = value
 			case "project_id":
 				profile.ProjectID = value
+			case "project":
+				if err := sharedmodels.ValidateProjectName(value); err != nil {
+					output.FormatError(err)
+					return
+				}
+				profile.Project = value
 			case "location":
 				profile.Location = value
 			default:
@@ -253,6 +263,7 @@ var configListProfilesCmd = &cobra.Command{
 				"Region":        profile.Region,
 				"ResourceGroup": profile.ResourceGroup,
 				"ProjectID":     profile.ProjectID,
+				"Project":       profile.Project,
 			})
 		}
 
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tronicum/punchbag-cube-testsuite/multitool/pkg/client"
+	"github.com/tronicum/punchbag-cube-testsuite/multitool/pkg/output"
+)
+
+// sessionFile keeps the cube-server session of mt login
+var sessionFile = filepath.Join(configDir, "session.json")
+
+// savedSession is a session token and the server that issued it
+type savedSession struct {
+	Server string `json:"server"`
+	client.Session
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in to cube-server and keep a session token",
+	Long: `Exchange an API token or OIDC JWT for a cube-server session token. The session
+is saved in ~/.multitool/session.json and used (and refreshed) by every command
+run with the same --server until mt logout.
+
+Examples:
+  mt login --server http://localhost:8080 --token cube_...
+  CUBE_SERVER_TOKEN=$(get-oidc-token) mt login --server https://cube.example.com`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient := client.NewAPIClient(proxyServer)
+		if apiClient == nil {
+			return errors.New("--server is required to log in")
+		}
+		credential := serverCredential()
+		if credential == "" {
+			return errors.New("a token or OIDC JWT is required (--token or CUBE_SERVER_TOKEN)")
+		}
+		session, err := apiClient.Login(credential)
+		if err != nil {
+			return err
+		}
+		if err := saveSession(session); err != nil {
+			return err
+		}
+		output.FormatSuccess(fmt.Sprintf("logged in to %s as %s (%s), session expires %s",
+			proxyServer, session.Subject, session.Role, session.ExpiresAt.Local().Format("2006-01-02 15:04")))
+		return nil
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Forget the cube-server session of mt login",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := os.Remove(sessionFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		output.FormatSuccess("logged out")
+		return nil
+	},
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the cube-server user and role of the current credentials",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := requireServerClient("whoami")
+		if err != nil {
+			return err
+		}
+		me, err := apiClient.WhoAmI()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("subject: %s\nrole:    %s\nmethod:  %s\n", me.Subject, me.Role, me.Method)
+		if me.ExpiresAt != nil {
+			fmt.Printf("expires: %s\n", me.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd, logoutCmd, whoamiCmd)
+}
+
+// serverCredential returns --token or CUBE_SERVER_TOKEN
+func serverCredential() string {
+	if serverToken != "" {
+		return serverToken
+	}
+	return os.Getenv("CUBE_SERVER_TOKEN")
+}
+
+// serverProjectName returns --server-project, CUBE_SERVER_PROJECT or the project
+// pinned in the default profile; empty means the server's default project
+func serverProjectName() string {
+	if serverProject != "" {
+		return serverProject
+	}
+	if project := os.Getenv("CUBE_SERVER_PROJECT"); project != "" {
+		return project
+	}
+	if profile, err := GetProfile(""); err == nil {
+		return profile.Project
+	}
+	return ""
+}
+
+// serverClient returns the cube-server client for --server (nil without it) in the
+// project of serverProjectName, authenticated with --token, CUBE_SERVER_TOKEN or the
+// mt login session of that server
+func serverClient() *client.APIClient {
+	apiClient := client.NewAPIClient(proxyServer)
+	if apiClient == nil {
+		return nil
+	}
+	apiClient.SetProject(serverProjectName())
+	if credential := serverCredential(); credential != "" {
+		apiClient.SetToken(credential)
+		return apiClient
+	}
+	if saved, err := loadSession(); err == nil && saved.Server == strings.TrimSuffix(proxyServer, "/") {
+		apiClient.SetSession(&saved.Session, func(s *client.Session) {
+			if err := saveSession(s); err != nil {
+				output.FormatWarning(fmt.Sprintf("could not save refreshed session: %v", err))
+			}
+		})
+	}
+	return apiClient
+}
+
+// requireServerClient is serverClient failing without --server
+func requireServerClient(action string) (*client.APIClient, error) {
+	apiClient := serverClient()
+	if apiClient == nil {
+		return nil, fmt.Errorf("--server is required to %s", action)
+	}
+	return apiClient, nil
+}
+
+func loadSession() (*savedSession, error) {
+	data, err := os.ReadFile(sessionFile)
+	if err != nil {
+		return nil, err
+	}
+	var saved savedSession
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("invalid session file %s: %w", sessionFile, err)
+	}
+	return &saved, nil
+}
+
+// saveSession writes the session of --server, readable by the user only
+func saveSession(s *client.Session) error {
+	data, err := json.MarshalIndent(savedSession{Server: strings.TrimSuffix(proxyServer, "/"), Session: *s}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return os.WriteFile(sessionFile, data, 0o600)
+}
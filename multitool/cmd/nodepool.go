@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/tronicum/punchbag-cube-testsuite/multitool/pkg/client"
+	"github.com/tronicum/punchbag-cube-testsuite/multitool/pkg/output"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// nodePoolCmd manages the node pools of cube-server clusters
+var nodePoolCmd = &cobra.Command{
+	Use:   "nodepool",
+	Short: "Manage the node pools of a cluster",
+	Long: `List, add, scale and remove the node pools of a cluster on cube-server.
+
+Examples:
+  mt --server http://localhost:8080 k8s-manage nodepool list hz-1
+  mt --server http://localhost:8080 k8s-manage nodepool add hz-1 workers --nodes 3 --min 1 --max 5 --instance-type cx31
+  mt --server http://localhost:8080 k8s-manage nodepool scale hz-1 <pool-id> --nodes 5
+  mt --server http://localhost:8080 k8s-manage nodepool remove hz-1 <pool-id>`,
+}
+
+var nodePoolListCmd = &cobra.Command{
+	Use:   "list [cluster-id]",
+	Short: "List the node pools of a cluster",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := nodePoolClient()
+		if err != nil {
+			return err
+		}
+		pools, err := apiClient.ListNodePools(args[0])
+		if err != nil {
+			return err
+		}
+		return printNodePools(cmd, pools)
+	},
+}
+
+var nodePoolAddCmd = &cobra.Command{
+	Use:   "add [cluster-id] [name]",
+	Short: "Add a node pool to a cluster",
+	Long: `Add a node pool to a cluster. --min and --max default to --nodes; the instance
+type defaults to the smallest one of the cluster's provider.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := nodePoolClient()
+		if err != nil {
+			return err
+		}
+		pool := &sharedmodels.NodePool{Name: args[1]}
+		pool.NodeCount, _ = cmd.Flags().GetInt("nodes")
+		pool.MinNodes, _ = cmd.Flags().GetInt("min")
+		pool.MaxNodes, _ = cmd.Flags().GetInt("max")
+		pool.AutoScaling, _ = cmd.Flags().GetBool("autoscaling")
+		pool.InstanceType, _ = cmd.Flags().GetString("instance-type")
+		pool.OSType, _ = cmd.Flags().GetString("os")
+		created, err := apiClient.CreateNodePool(args[0], pool)
+		if err != nil {
+			return err
+		}
+		output.FormatSuccess(fmt.Sprintf("node pool %s added to cluster %s with ID %s", created.Name, args[0], created.ID))
+		return printNodePools(cmd, []sharedmodels.NodePool{*created})
+	},
+}
+
+var nodePoolScaleCmd = &cobra.Command{
+	Use:   "scale [cluster-id] [pool-id]",
+	Short: "Change the node counts or autoscaling of a node pool",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := nodePoolClient()
+		if err != nil {
+			return err
+		}
+		var scale client.NodePoolScale
+		if cmd.Flags().Changed("nodes") {
+			nodes, _ := cmd.Flags().GetInt("nodes")
+			scale.NodeCount = &nodes
+		}
+		if cmd.Flags().Changed("min") {
+			minNodes, _ := cmd.Flags().GetInt("min")
+			scale.MinNodes = &minNodes
+		}
+		if cmd.Flags().Changed("max") {
+			maxNodes, _ := cmd.Flags().GetInt("max")
+			scale.MaxNodes = &maxNodes
+		}
+		if cmd.Flags().Changed("autoscaling") {
+			autoScaling, _ := cmd.Flags().GetBool("autoscaling")
+			scale.AutoScaling = &autoScaling
+		}
+		if scale == (client.NodePoolScale{}) {
+			return errors.New("nothing to change: set --nodes, --min, --max or --autoscaling")
+		}
+		updated, err := apiClient.ScaleNodePool(args[0], args[1], scale)
+		if err != nil {
+			return err
+		}
+		output.FormatSuccess(fmt.Sprintf("node pool %s scaled to %d nodes", updated.Name, updated.NodeCount))
+		return printNodePools(cmd, []sharedmodels.NodePool{*updated})
+	},
+}
+
+var nodePoolRemoveCmd = &cobra.Command{
+	Use:   "remove [cluster-id] [pool-id]",
+	Short: "Remove a node pool from a cluster",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := nodePoolClient()
+		if err != nil {
+			return err
+		}
+		if err := apiClient.DeleteNodePool(args[0], args[1]); err != nil {
+			return err
+		}
+		output.FormatSuccess(fmt.Sprintf("node pool %s removed from cluster %s", args[1], args[0]))
+		return nil
+	},
+}
+
+func nodePoolClient() (*client.APIClient, error) {
+	return requireServerClient("manage node pools")
+}
+
+// printNodePools prints pools as a table, or as JSON/YAML with --output
+func printNodePools(cmd *cobra.Command, pools []sharedmodels.NodePool) error {
+	format, _ := cmd.Flags().GetString("output")
+	if format != "" && format != string(output.FormatTable) {
+		return output.NewFormatter(output.Format(format)).FormatOutput(pools)
+	}
+	if len(pools) == 0 {
+		fmt.Println("No node pools found")
+		return nil
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer writer.Flush()
+	fmt.Fprintln(writer, "ID\tName\tNodes\tMin\tMax\tAutoscaling\tInstance Type\tOS")
+	fmt.Fprintln(writer, "---\t----\t-----\t---\t---\t-----------\t-------------\t--")
+	for _, pool := range pools {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%d\t%t\t%s\t%s\n",
+			pool.ID, pool.Name, pool.NodeCount, pool.MinNodes, pool.MaxNodes, pool.AutoScaling, pool.InstanceType, pool.OSType)
+	}
+	return nil
+}
+
+func init() {
+	nodePoolCmd.AddCommand(nodePoolListCmd)
+	nodePoolCmd.AddCommand(nodePoolAddCmd)
+	nodePoolCmd.AddCommand(nodePoolScaleCmd)
+	nodePoolCmd.AddCommand(nodePoolRemoveCmd)
+	k8sManageCmd.AddCommand(nodePoolCmd)
+
+	nodePoolCmd.PersistentFlags().StringP("output", "o", "table", "Output format (table, json, yaml)")
+
+	nodePoolAddCmd.Flags().Int("nodes", 1, "Number of nodes")
+	nodePoolAddCmd.Flags().Int("min", 0, "Minimum number of nodes (default: --nodes)")
+	nodePoolAddCmd.Flags().Int("max", 0, "Maximum number of nodes (default: --nodes)")
+	nodePoolAddCmd.Flags().Bool("autoscaling", false, "Let the provider scale between --min and --max")
+	nodePoolAddCmd.Flags().String("instance-type", "", "Instance type (default: the provider's smallest)")
+	nodePoolAddCmd.Flags().String("os", "", "Operating system (Linux, Windows; default: Linux)")
+
+	nodePoolScaleCmd.Flags().Int("nodes", 0, "Number of nodes")
+	nodePoolScaleCmd.Flags().Int("min", 0, "Minimum number of nodes")
+	nodePoolScaleCmd.Flags().Int("max", 0, "Maximum number of nodes")
+	nodePoolScaleCmd.Flags().Bool("autoscaling", false, "Let the provider scale between min and max")
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/spf13/cobra"
 	configloader "github.com/tronicum/punchbag-cube-testsuite/multitool/pkg"
+	"github.com/tronicum/punchbag-cube-testsuite/multitool/pkg/client"
 	sharederrors "github.com/tronicum/punchbag-cube-testsuite/shared/errors"
 	"github.com/tronicum/punchbag-cube-testsuite/shared/log"
 	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
@@ -177,36 +178,18 @@ var listBucketsCmd = &cobra.Command{
 		mode, _ := cmd.Flags().GetString("mode")
 		var buckets []models.ObjectStorageBucket
 		var err error
-		if mode == "proxy" && proxyServer != "" {
-			url := fmt.Sprintf("%s/api/v1/proxy/providers/%s/buckets", proxyServer, provider)
-			resp, err := http.Get(url)
-			if err != nil {
-				fmt.Println("Proxy request failed:", err)
-				os.Exit(1)
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				fmt.Println("Proxy server error:", resp.Status)
-				os.Exit(1)
-			}
-			if err := json.NewDecoder(resp.Body).Decode(&buckets); err != nil {
-				fmt.Println("Failed to decode proxy response:", err)
-				os.Exit(1)
+		if (mode == "proxy" || mode == "simulate") && proxyServer != "" {
+			sortBy, _ := cmd.Flags().GetString("sort-by")
+			order, _ := cmd.Flags().GetString("order")
+			opts := client.ListOptions{SortBy: sortBy, Order: order}
+			apiClient := client.NewAPIClient(proxyServer)
+			if mode == "proxy" {
+				buckets, err = apiClient.ListProxyBuckets(provider, opts)
+			} else {
+				buckets, err = apiClient.ListSimulatedBuckets(provider, opts)
 			}
-		} else if mode == "simulate" && proxyServer != "" {
-			url := fmt.Sprintf("%s/api/v1/simulate/providers/%s/buckets", proxyServer, provider)
-			resp, err := http.Get(url)
 			if err != nil {
-				fmt.Println("Simulation request failed:", err)
-				os.Exit(1)
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				fmt.Println("Simulation server error:", resp.Status)
-				os.Exit(1)
-			}
-			if err := json.NewDecoder(resp.Body).Decode(&buckets); err != nil {
-				fmt.Println("Failed to decode simulation response:", err)
+				fmt.Println("Listing buckets failed:", err)
 				os.Exit(1)
 			}
 		} else {
@@ -439,6 +422,8 @@ func init() {
 	createBucketCmd.Flags().BoolVar(&versioning, "versioning", false, "Enable versioning")
 	createBucketCmd.Flags().StringVar(&lifecycleFile, "lifecycle", "", "Path to lifecycle rules JSON file")
 	listBucketsCmd.Flags().StringVarP(&objectStorageOutputFormat, "output", "o", "table", "Output format: json or table")
+	listBucketsCmd.Flags().String("sort-by", "", "Sort field for proxy and simulate mode: name, region or created_at")
+	listBucketsCmd.Flags().String("order", "", "Sort order for proxy and simulate mode: asc or desc")
 	deleteBucketCmd.Flags().BoolVarP(&forceDelete, "force", "f", false, "Force deletion without confirmation")
 
 	// ENV override for debug
@@ -27,6 +27,12 @@ func Execute() {
 
 var proxyServer string
 
+// serverToken authenticates requests to --server (see login.go)
+var serverToken string
+
+// serverProject is the cube-server project requests to --server act on (see login.go)
+var serverProject string
+
 // Initialize commands
 func init() {
 	awsCmd.Annotations = map[string]string{"group": "Cloud Management Commands"}
@@ -35,6 +41,8 @@ func init() {
 	hetznerCmd.Annotations = map[string]string{"group": "Cloud Management Commands"}
 	objectStorageCmd.Annotations = map[string]string{"group": "Cloud ObjectStorage (S3) Commands"}
 	rootCmd.PersistentFlags().StringVar(&proxyServer, "server", "", "If set, forward all resource management requests to this cube-server URL (proxy/simulation mode)")
+	rootCmd.PersistentFlags().StringVar(&serverToken, "token", "", "API token or OIDC JWT for --server (default $CUBE_SERVER_TOKEN, else the mt login session)")
+	rootCmd.PersistentFlags().StringVar(&serverProject, "server-project", "", "cube-server project for --server (default $CUBE_SERVER_PROJECT, else the default profile's project)")
 	rootCmd.PersistentFlags().String("provider", "aws", "Object storage provider (aws, hetzner)")
 
 	// Register only the correct top-level commands, matching the new CLI tree structure
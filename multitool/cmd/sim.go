@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/tronicum/punchbag-cube-testsuite/multitool/pkg/client"
+	"github.com/tronicum/punchbag-cube-testsuite/multitool/pkg/output"
+)
+
+// simCmd snapshots and restores the state of a cube-server
+var simCmd = &cobra.Command{
+	Use:   "sim",
+	Short: "Snapshot and restore the state of cube-server",
+	Long: `Export the whole state of cube-server (clusters, node pools, tests, Azure
+resources, simulated buckets, objects and clusters, the simulated clock) into one
+archive and load it back. Named fixtures are archives kept on the server, so test
+suites can start from them and reset between cases. Needs an admin token when the
+server has authentication enabled.
+
+Examples:
+  mt --server http://localhost:8080 sim snapshot -f before.json
+  mt --server http://localhost:8080 sim restore before.json
+  mt --server http://localhost:8080 sim snapshot --fixture baseline
+  mt --server http://localhost:8080 sim restore --fixture baseline
+  mt --server http://localhost:8080 sim fixture put baseline testdata/baseline.json
+  mt --server http://localhost:8080 sim fixture list`,
+}
+
+var simSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Write the archive of the server state to a file or stdout, or keep it as a fixture",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := simClient()
+		if err != nil {
+			return err
+		}
+		if fixture, _ := cmd.Flags().GetString("fixture"); fixture != "" {
+			summary, err := apiClient.SaveFixture(fixture, nil)
+			if err != nil {
+				return err
+			}
+			output.FormatSuccess("fixture " + fixture + " saved: " + describeSnapshot(summary))
+			return nil
+		}
+		archive, err := apiClient.Snapshot()
+		if err != nil {
+			return err
+		}
+		file, _ := cmd.Flags().GetString("file")
+		if file == "" || file == "-" {
+			_, err = os.Stdout.Write(append(archive, '\n'))
+			return err
+		}
+		if err := os.WriteFile(file, archive, 0o600); err != nil {
+			return err
+		}
+		output.FormatSuccess("snapshot written to " + file)
+		return nil
+	},
+}
+
+var simRestoreCmd = &cobra.Command{
+	Use:   "restore [file]",
+	Short: "Replace the server state with an archive (file or - for stdin) or a fixture",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fixture, _ := cmd.Flags().GetString("fixture")
+		if (fixture == "") == (len(args) == 0) {
+			return errors.New("give either an archive file or --fixture")
+		}
+		apiClient, err := simClient()
+		if err != nil {
+			return err
+		}
+		var summary *client.SnapshotSummary
+		if fixture != "" {
+			summary, err = apiClient.RestoreFixture(fixture)
+		} else {
+			var archive json.RawMessage
+			if archive, err = readArchive(args[0]); err != nil {
+				return err
+			}
+			summary, err = apiClient.Restore(archive)
+		}
+		if err != nil {
+			return err
+		}
+		output.FormatSuccess("state restored: " + describeSnapshot(summary))
+		return nil
+	},
+}
+
+// simFixtureCmd manages the fixtures kept on the server
+var simFixtureCmd = &cobra.Command{
+	Use:   "fixture",
+	Short: "Manage the fixtures kept on cube-server",
+}
+
+var simFixtureListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the fixtures",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := simClient()
+		if err != nil {
+			return err
+		}
+		fixtures, err := apiClient.ListFixtures()
+		if err != nil {
+			return err
+		}
+		format, _ := cmd.Flags().GetString("output")
+		if format != "" && format != string(output.FormatTable) {
+			return output.NewFormatter(output.Format(format)).FormatOutput(fixtures)
+		}
+		if len(fixtures) == 0 {
+			fmt.Println("No fixtures found")
+			return nil
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer writer.Flush()
+		fmt.Fprintln(writer, "Name\tSize\tSaved")
+		fmt.Fprintln(writer, "----\t----\t-----")
+		for _, f := range fixtures {
+			fmt.Fprintf(writer, "%s\t%d\t%s\n", f.Name, f.Size, f.SavedAt.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	},
+}
+
+var simFixturePutCmd = &cobra.Command{
+	Use:   "put [name] [file]",
+	Short: "Keep an archive (file or - for stdin) as a fixture",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		archive, err := readArchive(args[1])
+		if err != nil {
+			return err
+		}
+		apiClient, err := simClient()
+		if err != nil {
+			return err
+		}
+		summary, err := apiClient.SaveFixture(args[0], archive)
+		if err != nil {
+			return err
+		}
+		output.FormatSuccess("fixture " + args[0] + " saved: " + describeSnapshot(summary))
+		return nil
+	},
+}
+
+var simFixtureGetCmd = &cobra.Command{
+	Use:   "get [name]",
+	Short: "Write the archive of a fixture to stdout",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := simClient()
+		if err != nil {
+			return err
+		}
+		archive, err := apiClient.GetFixture(args[0])
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(archive, '\n'))
+		return err
+	},
+}
+
+var simFixtureDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a fixture",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := simClient()
+		if err != nil {
+			return err
+		}
+		if err := apiClient.DeleteFixture(args[0]); err != nil {
+			return err
+		}
+		output.FormatSuccess("fixture " + args[0] + " deleted")
+		return nil
+	},
+}
+
+func simClient() (*client.APIClient, error) {
+	return requireServerClient("snapshot or restore the server state")
+}
+
+// readArchive reads an archive from file, or from stdin for "-"
+func readArchive(file string) (json.RawMessage, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("%s is not a JSON snapshot archive", file)
+	}
+	return data, nil
+}
+
+func describeSnapshot(s *client.SnapshotSummary) string {
+	return fmt.Sprintf("%d projects, %d clusters, %d node pools, %d test results, %d Azure resources, %d buckets, %d objects, %d simulated clusters",
+		s.Projects, s.Clusters, s.NodePools, s.TestResults, s.AzureResources, s.Buckets, s.Objects, s.SimulatedClusters)
+}
+
+func init() {
+	simCmd.AddCommand(simSnapshotCmd)
+	simCmd.AddCommand(simRestoreCmd)
+	simFixtureCmd.AddCommand(simFixtureListCmd)
+	simFixtureCmd.AddCommand(simFixturePutCmd)
+	simFixtureCmd.AddCommand(simFixtureGetCmd)
+	simFixtureCmd.AddCommand(simFixtureDeleteCmd)
+	simCmd.AddCommand(simFixtureCmd)
+	rootCmd.AddCommand(simCmd)
+
+	simSnapshotCmd.Flags().StringP("file", "f", "", "File to write the archive to (default: stdout)")
+	simSnapshotCmd.Flags().String("fixture", "", "Keep the state on the server as this fixture instead")
+	simRestoreCmd.Flags().String("fixture", "", "Restore this fixture instead of an archive file")
+	simFixtureListCmd.Flags().StringP("output", "o", "table", "Output format (table, json, yaml)")
+}
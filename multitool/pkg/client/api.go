@@ -7,10 +7,21 @@ import (
 	"time"
 )
 
+// ProjectHeader selects the cube-server project (tenant) of a request
+const ProjectHeader = "X-Cube-Project"
+
 // APIClient represents a minimal client for interacting with the cube-server API (health, login, SSO, etc).
 type APIClient struct {
 	baseURL    string
 	httpClient *http.Client
+	// plainClient sends requests without the bearer token transport (session refresh)
+	plainClient *http.Client
+	auth        bearerAuth
+	// project is sent as X-Cube-Project; empty means the default project
+	project string
+	// retries and retryDelay govern resending failed requests (see retry.go)
+	retries    int
+	retryDelay time.Duration
 }
 
 // NewAPIClient creates a new API client. Returns nil if baseURL is empty.
@@ -18,12 +29,25 @@ func NewAPIClient(baseURL string) *APIClient {
 	if strings.TrimSpace(baseURL) == "" {
 		return nil
 	}
-	return &APIClient{
-		baseURL: strings.TrimSuffix(baseURL, "/"),
-		httpClient: &http.Client{
+	c := &APIClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		retries:    DefaultRetries,
+		retryDelay: 250 * time.Millisecond,
+		plainClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
+	c.httpClient = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &authTransport{client: c, base: http.DefaultTransport},
+	}
+	return c
+}
+
+// SetProject makes every request act on a cube-server project instead of the
+// default one.
+func (c *APIClient) SetProject(project string) {
+	c.project = project
 }
 
 // buildURL helps construct endpoint URLs.
@@ -48,7 +72,7 @@ func (c *APIClient) Ping() error {
 // TODO (medium priority):
 // - Only keep endpoints in this client for:
 //   - Health checks (Ping)
-//   - Authentication (login, logout, SSO, token refresh)
+//   - Authentication (login, logout, SSO, token refresh) -- login, refresh and whoami done (auth.go); SSO browser flow open
 //   - User/session info (current user, session diagnostics)
 //   - Server info/config (version, capabilities, status)
 // - All cloud resource logic (clusters, tests, etc.) must be handled by the shared Go library, not by this client.
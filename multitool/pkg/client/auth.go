@@ -0,0 +1,128 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// refreshBefore is how long before expiry a session token is refreshed.
+const refreshBefore = 2 * time.Minute
+
+// Session is a cube-server session token from Login or Refresh.
+type Session struct {
+	Token     string    `json:"token"`
+	Subject   string    `json:"subject"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Principal is the caller as cube-server sees it (GET /api/v1/auth/whoami).
+type Principal struct {
+	Subject   string     `json:"subject"`
+	Role      string     `json:"role"`
+	Method    string     `json:"method"`
+	TokenID   string     `json:"token_id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// bearerAuth holds the bearer token of a client and refreshes session tokens.
+type bearerAuth struct {
+	mu      sync.Mutex
+	token   string
+	session *Session
+	// onRefresh is called with every refreshed session, so it can be saved
+	onRefresh func(*Session)
+}
+
+// SetToken sends token (an API token or an OIDC JWT) as bearer token with every request.
+func (c *APIClient) SetToken(token string) {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	c.auth.token, c.auth.session = token, nil
+}
+
+// SetSession uses a session token from Login, refreshing it shortly before it
+// expires; onRefresh (may be nil) receives each new session.
+func (c *APIClient) SetSession(s *Session, onRefresh func(*Session)) {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	c.auth.token, c.auth.session, c.auth.onRefresh = s.Token, s, onRefresh
+}
+
+// Login exchanges credential (an API token or an OIDC JWT) for a session token,
+// which the client uses from then on.
+func (c *APIClient) Login(credential string) (*Session, error) {
+	c.SetToken(credential)
+	var s Session
+	if err := c.doJSON(http.MethodPost, "/api/v1/auth/login", nil, http.StatusOK, &s); err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	c.SetSession(&s, nil)
+	return &s, nil
+}
+
+// Refresh replaces the current session token by a new one.
+func (c *APIClient) Refresh() (*Session, error) {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	return c.refreshLocked()
+}
+
+func (c *APIClient) refreshLocked() (*Session, error) {
+	req, err := http.NewRequest(http.MethodPost, c.buildURL("/api/v1/auth/refresh"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.auth.token)
+	var s Session
+	if err := c.send(c.plainClient, req, http.StatusOK, &s); err != nil {
+		return nil, fmt.Errorf("refresh session: %w", err)
+	}
+	c.auth.token, c.auth.session = s.Token, &s
+	if c.auth.onRefresh != nil {
+		c.auth.onRefresh(&s)
+	}
+	return &s, nil
+}
+
+// WhoAmI returns the subject and role of the client's token.
+func (c *APIClient) WhoAmI() (*Principal, error) {
+	var p Principal
+	if err := c.doJSON(http.MethodGet, "/api/v1/auth/whoami", nil, http.StatusOK, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// bearerToken returns the token to send, refreshing a session about to expire.
+func (c *APIClient) bearerToken() string {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	if s := c.auth.session; s != nil && time.Until(s.ExpiresAt) < refreshBefore && time.Now().Before(s.ExpiresAt) {
+		// On failure the old token is sent and the server's answer tells why
+		_, _ = c.refreshLocked()
+	}
+	return c.auth.token
+}
+
+// authTransport adds the client's bearer token and project to every request without them.
+type authTransport struct {
+	client *APIClient
+	base   http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") == "" {
+		if token := t.client.bearerToken(); token != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	if project := t.client.project; project != "" && req.Header.Get(ProjectHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(ProjectHeader, project)
+	}
+	return t.base.RoundTrip(req)
+}
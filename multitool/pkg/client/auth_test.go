@@ -0,0 +1,79 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoginAndRefresh(t *testing.T) {
+	var refreshes int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/api/v1/auth/login":
+			if token != "Bearer api-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid or expired token"})
+				return
+			}
+			// Expires within refreshBefore, so the next request refreshes it
+			json.NewEncoder(w).Encode(Session{Token: "session-1", Subject: "ci", Role: "operator", ExpiresAt: time.Now().Add(time.Minute)})
+		case "/api/v1/auth/refresh":
+			refreshes++
+			json.NewEncoder(w).Encode(Session{Token: "session-2", Subject: "ci", Role: "operator", ExpiresAt: time.Now().Add(time.Hour)})
+		case "/api/v1/auth/whoami":
+			if token != "Bearer session-2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(Principal{Subject: "ci", Role: "operator", Method: "issued"})
+		}
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient(srv.URL)
+	if _, err := c.Login("wrong"); err == nil {
+		t.Error("login with a wrong token succeeded")
+	}
+	session, err := c.Login("api-token")
+	if err != nil || session.Token != "session-1" {
+		t.Fatalf("login: %+v, %v", session, err)
+	}
+	var saved *Session
+	c.SetSession(session, func(s *Session) { saved = s })
+	me, err := c.WhoAmI()
+	if err != nil || me.Subject != "ci" {
+		t.Fatalf("whoami: %+v, %v", me, err)
+	}
+	if refreshes != 1 || saved == nil || saved.Token != "session-2" {
+		t.Errorf("refreshes = %d, saved session %+v", refreshes, saved)
+	}
+	if _, err := c.WhoAmI(); err != nil || refreshes != 1 {
+		t.Errorf("second request: %v, refreshes = %d", err, refreshes)
+	}
+}
+
+func TestProjectHeader(t *testing.T) {
+	var projects []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		projects = append(projects, r.Header.Get(ProjectHeader))
+		json.NewEncoder(w).Encode(Principal{Subject: "ci", Role: "viewer", Method: "static"})
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient(srv.URL)
+	c.SetToken("api-token")
+	if _, err := c.WhoAmI(); err != nil {
+		t.Fatal(err)
+	}
+	c.SetProject("team-a")
+	if _, err := c.WhoAmI(); err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 2 || projects[0] != "" || projects[1] != "team-a" {
+		t.Errorf("%s headers = %q", ProjectHeader, projects)
+	}
+}
@@ -19,7 +19,7 @@ func NewLogAnalyticsClient(client *APIClient) *LogAnalyticsClient {
 }
 
 func (c *LogAnalyticsClient) Create(workspace *sharedmodels.LogAnalyticsWorkspace) (*sharedmodels.LogAnalyticsWorkspace, error) {
-	url := fmt.Sprintf("%s/api/azure/loganalytics", c.client.baseURL)
+	url := fmt.Sprintf("%s/api/v1/azure/loganalytics", c.client.baseURL)
 	data, err := json.Marshal(workspace)
 	if err != nil {
 		return nil, err
@@ -40,7 +40,7 @@ func (c *LogAnalyticsClient) Create(workspace *sharedmodels.LogAnalyticsWorkspac
 }
 
 func (c *LogAnalyticsClient) Get(id string) (*sharedmodels.LogAnalyticsWorkspace, error) {
-	url := fmt.Sprintf("%s/api/azure/loganalytics/%s", c.client.baseURL, id)
+	url := fmt.Sprintf("%s/api/v1/azure/loganalytics/%s", c.client.baseURL, id)
 	resp, err := c.client.httpClient.Get(url)
 	if err != nil {
 		return nil, err
@@ -60,7 +60,7 @@ func (c *LogAnalyticsClient) Get(id string) (*sharedmodels.LogAnalyticsWorkspace
 }
 
 func (c *LogAnalyticsClient) List() ([]*sharedmodels.LogAnalyticsWorkspace, error) {
-	url := fmt.Sprintf("%s/api/azure/loganalytics", c.client.baseURL)
+	url := fmt.Sprintf("%s/api/v1/azure/loganalytics", c.client.baseURL)
 	resp, err := c.client.httpClient.Get(url)
 	if err != nil {
 		return nil, err
@@ -69,15 +69,17 @@ func (c *LogAnalyticsClient) List() ([]*sharedmodels.LogAnalyticsWorkspace, erro
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
 	}
-	var results []*sharedmodels.LogAnalyticsWorkspace
+	var results struct {
+		Items []*sharedmodels.LogAnalyticsWorkspace `json:"workspaces"`
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
 		return nil, err
 	}
-	return results, nil
+	return results.Items, nil
 }
 
 func (c *LogAnalyticsClient) Delete(id string) error {
-	url := fmt.Sprintf("%s/api/azure/loganalytics/%s", c.client.baseURL, id)
+	url := fmt.Sprintf("%s/api/v1/azure/loganalytics/%s", c.client.baseURL, id)
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		return err
@@ -106,7 +108,7 @@ func NewAzureBudgetClient(client *APIClient) *AzureBudgetClient {
 }
 
 func (c *AzureBudgetClient) Create(budget *sharedmodels.AzureBudget) (*sharedmodels.AzureBudget, error) {
-	url := fmt.Sprintf("%s/api/azure/budget", c.client.baseURL)
+	url := fmt.Sprintf("%s/api/v1/azure/budget", c.client.baseURL)
 	data, err := json.Marshal(budget)
 	if err != nil {
 		return nil, err
@@ -127,7 +129,7 @@ func (c *AzureBudgetClient) Create(budget *sharedmodels.AzureBudget) (*sharedmod
 }
 
 func (c *AzureBudgetClient) Get(id string) (*sharedmodels.AzureBudget, error) {
-	url := fmt.Sprintf("%s/api/azure/budget/%s", c.client.baseURL, id)
+	url := fmt.Sprintf("%s/api/v1/azure/budget/%s", c.client.baseURL, id)
 	resp, err := c.client.httpClient.Get(url)
 	if err != nil {
 		return nil, err
@@ -147,7 +149,7 @@ func (c *AzureBudgetClient) Get(id string) (*sharedmodels.AzureBudget, error) {
 }
 
 func (c *AzureBudgetClient) List() ([]*sharedmodels.AzureBudget, error) {
-	url := fmt.Sprintf("%s/api/azure/budget", c.client.baseURL)
+	url := fmt.Sprintf("%s/api/v1/azure/budget", c.client.baseURL)
 	resp, err := c.client.httpClient.Get(url)
 	if err != nil {
 		return nil, err
@@ -156,15 +158,17 @@ func (c *AzureBudgetClient) List() ([]*sharedmodels.AzureBudget, error) {
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
 	}
-	var results []*sharedmodels.AzureBudget
+	var results struct {
+		Items []*sharedmodels.AzureBudget `json:"budgets"`
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
 		return nil, err
 	}
-	return results, nil
+	return results.Items, nil
 }
 
 func (c *AzureBudgetClient) Delete(id string) error {
-	url := fmt.Sprintf("%s/api/azure/budget/%s", c.client.baseURL, id)
+	url := fmt.Sprintf("%s/api/v1/azure/budget/%s", c.client.baseURL, id)
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		return err
@@ -193,7 +197,7 @@ func NewAppInsightsClient(client *APIClient) *AppInsightsClient {
 }
 
 func (c *AppInsightsClient) Create(app *sharedmodels.AppInsightsResource) (*sharedmodels.AppInsightsResource, error) {
-	url := fmt.Sprintf("%s/api/azure/appinsights", c.client.baseURL)
+	url := fmt.Sprintf("%s/api/v1/azure/appinsights", c.client.baseURL)
 	data, err := json.Marshal(app)
 	if err != nil {
 		return nil, err
@@ -214,7 +218,7 @@ func (c *AppInsightsClient) Create(app *sharedmodels.AppInsightsResource) (*shar
 }
 
 func (c *AppInsightsClient) Get(id string) (*sharedmodels.AppInsightsResource, error) {
-	url := fmt.Sprintf("%s/api/azure/appinsights/%s", c.client.baseURL, id)
+	url := fmt.Sprintf("%s/api/v1/azure/appinsights/%s", c.client.baseURL, id)
 	resp, err := c.client.httpClient.Get(url)
 	if err != nil {
 		return nil, err
@@ -234,7 +238,7 @@ func (c *AppInsightsClient) Get(id string) (*sharedmodels.AppInsightsResource, e
 }
 
 func (c *AppInsightsClient) List() ([]*sharedmodels.AppInsightsResource, error) {
-	url := fmt.Sprintf("%s/api/azure/appinsights", c.client.baseURL)
+	url := fmt.Sprintf("%s/api/v1/azure/appinsights", c.client.baseURL)
 	resp, err := c.client.httpClient.Get(url)
 	if err != nil {
 		return nil, err
@@ -243,15 +247,17 @@ func (c *AppInsightsClient) List() ([]*sharedmodels.AppInsightsResource, error)
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
 	}
-	var results []*sharedmodels.AppInsightsResource
+	var results struct {
+		Items []*sharedmodels.AppInsightsResource `json:"app_insights"`
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
 		return nil, err
 	}
-	return results, nil
+	return results.Items, nil
 }
 
 func (c *AppInsightsClient) Delete(id string) error {
-	url := fmt.Sprintf("%s/api/azure/appinsights/%s", c.client.baseURL, id)
+	url := fmt.Sprintf("%s/api/v1/azure/appinsights/%s", c.client.baseURL, id)
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		return err
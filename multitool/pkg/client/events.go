@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/events"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// SubscribeEvents streams cube-server events (GET /api/v1/events) for a resource type
+// and optional resource ID to handle. The stream reconnects on its own and resumes
+// from the last delivered event; it ends when ctx is done or handle returns an error
+// (events.ErrStop ends it without one). The returned ID resumes a later subscription.
+func (c *APIClient) SubscribeEvents(ctx context.Context, resource, id, lastEventID string, handle func(sharedmodels.Event) error) (string, error) {
+	return events.Subscribe(ctx, c.httpClient, c.baseURL, events.Options{
+		Resource:    resource,
+		ID:          id,
+		LastEventID: lastEventID,
+	}, handle)
+}
+
+// WatchTest streams the events of a test, starting with those the server still keeps,
+// until a status event reports that the test finished. It returns that final event.
+func (c *APIClient) WatchTest(ctx context.Context, id string, onEvent func(sharedmodels.Event)) (sharedmodels.Event, error) {
+	var last sharedmodels.Event
+	_, err := c.SubscribeEvents(ctx, sharedmodels.EventResourceTests, id, "0", func(event sharedmodels.Event) error {
+		last = event
+		if onEvent != nil {
+			onEvent(event)
+		}
+		if status, _ := event.Data["status"].(string); event.Type == sharedmodels.EventStatus && sharedmodels.TestStatus(status).Finished() {
+			return events.ErrStop
+		}
+		return nil
+	})
+	return last, err
+}
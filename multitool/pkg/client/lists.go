@@ -0,0 +1,103 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// ListOptions filters and sorts list requests; zero fields are left to the server.
+// The list methods follow next_page_token until the last page, so callers get every
+// matching item whatever the page size.
+type ListOptions struct {
+	// Provider, Status and Region take comma-separated values, any of which matches
+	Provider string
+	Status   string
+	Region   string
+	// LabelSelector is e.g. env=prod,tier!=db,team,!legacy
+	LabelSelector string
+	CreatedAfter  time.Time
+	SortBy        string
+	// Order is asc or desc
+	Order string
+	// PageSize is the limit of each request (server default: 100)
+	PageSize int
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	for name, value := range map[string]string{
+		"provider": o.Provider, "status": o.Status, "region": o.Region,
+		"label_selector": o.LabelSelector, "sort_by": o.SortBy, "order": o.Order,
+	} {
+		if value != "" {
+			q.Set(name, value)
+		}
+	}
+	if !o.CreatedAfter.IsZero() {
+		q.Set("created_after", o.CreatedAfter.UTC().Format(time.RFC3339))
+	}
+	if o.PageSize > 0 {
+		q.Set("limit", strconv.Itoa(o.PageSize))
+	}
+	return q
+}
+
+// listAll gets every page of the list at path and decodes the items under key
+func listAll[T any](c *APIClient, path, key string, opts ListOptions) ([]T, error) {
+	items := []T{}
+	q := opts.query()
+	for {
+		var page map[string]json.RawMessage
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		if err := c.doJSON(http.MethodGet, path+sep+q.Encode(), nil, http.StatusOK, &page); err != nil {
+			return nil, err
+		}
+		var batch []T
+		if raw := page[key]; raw != nil {
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return nil, fmt.Errorf("error decoding response: %w", err)
+			}
+		}
+		items = append(items, batch...)
+		var next string
+		if raw := page["next_page_token"]; raw != nil {
+			if err := json.Unmarshal(raw, &next); err != nil {
+				return nil, fmt.Errorf("error decoding response: %w", err)
+			}
+		}
+		if next == "" {
+			return items, nil
+		}
+		q.Set("page_token", next)
+	}
+}
+
+// ListClusters lists the clusters of the project (GET /api/v1/clusters)
+func (c *APIClient) ListClusters(opts ListOptions) ([]sharedmodels.Cluster, error) {
+	return listAll[sharedmodels.Cluster](c, "/api/v1/clusters", "clusters", opts)
+}
+
+// ListTestResults lists the test results of a cluster, oldest first by default
+func (c *APIClient) ListTestResults(clusterID string, opts ListOptions) ([]sharedmodels.TestResult, error) {
+	return listAll[sharedmodels.TestResult](c, "/api/v1/clusters/"+url.PathEscape(clusterID)+"/tests", "test_results", opts)
+}
+
+// ListSimulatedBuckets lists the buckets of a simulated provider
+func (c *APIClient) ListSimulatedBuckets(provider string, opts ListOptions) ([]sharedmodels.ObjectStorageBucket, error) {
+	return listAll[sharedmodels.ObjectStorageBucket](c, "/api/v1/simulate/providers/"+url.PathEscape(provider)+"/buckets", "buckets", opts)
+}
+
+// ListProxyBuckets lists the buckets of a real provider through the server's proxy
+func (c *APIClient) ListProxyBuckets(provider string, opts ListOptions) ([]sharedmodels.ObjectStorageBucket, error) {
+	return listAll[sharedmodels.ObjectStorageBucket](c, "/api/v1/proxy/providers/"+url.PathEscape(provider)+"/buckets", "buckets", opts)
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+func TestListClustersFollowsPageTokens(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/clusters" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		q := r.URL.Query()
+		switch q.Get("page_token") {
+		case "":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"clusters":        []sharedmodels.Cluster{{ID: "c-1"}, {ID: "c-2"}},
+				"next_page_token": "p2",
+			})
+		case "p2":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"clusters":        []sharedmodels.Cluster{{ID: "c-3"}},
+				"next_page_token": "",
+			})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid page_token"})
+		}
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient(srv.URL)
+	clusters, err := c.ListClusters(ListOptions{
+		Provider:      "hetzner",
+		LabelSelector: "env=prod",
+		CreatedAfter:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)),
+		SortBy:        "created_at",
+		Order:         "desc",
+		PageSize:      2,
+	})
+	if err != nil || len(clusters) != 3 || clusters[0].ID != "c-1" || clusters[2].ID != "c-3" {
+		t.Fatalf("clusters = %+v, %v", clusters, err)
+	}
+	want := []string{
+		"created_after=2026-01-02T02%3A04%3A05Z&label_selector=env%3Dprod&limit=2&order=desc&provider=hetzner&sort_by=created_at",
+		"created_after=2026-01-02T02%3A04%3A05Z&label_selector=env%3Dprod&limit=2&order=desc&page_token=p2&provider=hetzner&sort_by=created_at",
+	}
+	if len(queries) != len(want) || queries[0] != want[0] || queries[1] != want[1] {
+		t.Errorf("queries = %q", queries)
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// NodePoolScale holds the sizes to change on a node pool; nil fields are kept
+type NodePoolScale struct {
+	NodeCount   *int  `json:"node_count,omitempty"`
+	MinNodes    *int  `json:"min_nodes,omitempty"`
+	MaxNodes    *int  `json:"max_nodes,omitempty"`
+	AutoScaling *bool `json:"auto_scaling,omitempty"`
+}
+
+// ListNodePools lists the node pools of a cluster (GET /api/v1/clusters/:id/nodepools)
+// by name
+func (c *APIClient) ListNodePools(clusterID string) ([]sharedmodels.NodePool, error) {
+	return listAll[sharedmodels.NodePool](c, nodePoolsPath(clusterID), "node_pools", ListOptions{})
+}
+
+// CreateNodePool adds a node pool to a cluster; the server fills in the defaults
+func (c *APIClient) CreateNodePool(clusterID string, pool *sharedmodels.NodePool) (*sharedmodels.NodePool, error) {
+	var created sharedmodels.NodePool
+	if err := c.doJSON(http.MethodPost, nodePoolsPath(clusterID), pool, http.StatusCreated, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// ScaleNodePool changes the node counts or autoscaling of a node pool
+func (c *APIClient) ScaleNodePool(clusterID, poolID string, scale NodePoolScale) (*sharedmodels.NodePool, error) {
+	var updated sharedmodels.NodePool
+	if err := c.doJSON(http.MethodPut, nodePoolsPath(clusterID)+"/"+url.PathEscape(poolID), scale, http.StatusOK, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteNodePool removes a node pool from a cluster
+func (c *APIClient) DeleteNodePool(clusterID, poolID string) error {
+	return c.doJSON(http.MethodDelete, nodePoolsPath(clusterID)+"/"+url.PathEscape(poolID), nil, http.StatusNoContent, nil)
+}
+
+func nodePoolsPath(clusterID string) string {
+	return "/api/v1/clusters/" + url.PathEscape(clusterID) + "/nodepools"
+}
+
+// doJSON sends body as JSON and decodes the answer into out (may be nil). Answers
+// other than want are returned as errors carrying the server's error message. Failed
+// requests are sent again as retryable allows, POSTs with the same Idempotency-Key.
+func (c *APIClient) doJSON(method, path string, body interface{}, want int, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("error marshaling request body: %w", err)
+		}
+	}
+	var key string
+	if method == http.MethodPost {
+		key = newIdempotencyKey()
+	}
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			time.Sleep(c.retryWait(retry))
+		}
+		var reader io.Reader
+		if data != nil {
+			reader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequest(method, c.buildURL(path), reader)
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if key != "" {
+			req.Header.Set(IdempotencyHeader, key)
+		}
+		err = c.send(c.httpClient, req, want, out)
+		if err == nil || retry >= c.retries || !retryable(method, err) {
+			return err
+		}
+	}
+}
+
+// send does req with httpClient and decodes the answer as doJSON does.
+func (c *APIClient) send(httpClient *http.Client, req *http.Request, want int, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return &statusError{resp.StatusCode, fmt.Sprintf("%s (status %d)", apiErr.Error, resp.StatusCode)}
+		}
+		return &statusError{resp.StatusCode, fmt.Sprintf("server returned status %d", resp.StatusCode)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
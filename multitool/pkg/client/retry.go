@@ -0,0 +1,64 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// IdempotencyHeader carries the key that makes cube-server answer a POST only once
+const IdempotencyHeader = "Idempotency-Key"
+
+// DefaultRetries is how often a failed request is sent again unless SetRetries says
+// otherwise
+const DefaultRetries = 2
+
+// statusError is an answer other than the wanted status
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e *statusError) Error() string { return e.message }
+
+// SetRetries sets how often requests are sent again after a failed connection or a
+// 502, 503 or 504 answer; 0 sends every request once.
+func (c *APIClient) SetRetries(retries int) {
+	c.retries = retries
+}
+
+// retryable reports whether a request that failed with err may be sent again. POSTs
+// carry an Idempotency-Key, so the server answers a repeat with the first answer; a
+// DELETE is only sent again after a 503, which cube-server answers before acting.
+func retryable(method string, err error) bool {
+	var answer *statusError
+	if !errors.As(err, &answer) {
+		// No answer at all
+		return method != http.MethodDelete
+	}
+	switch answer.status {
+	case http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method != http.MethodDelete
+	}
+	return false
+}
+
+// retryWait is the pause before the given retry (1, 2, ...): retryDelay, doubled
+// each time
+func (c *APIClient) retryWait(retry int) time.Duration {
+	return c.retryDelay << (retry - 1)
+}
+
+// newIdempotencyKey returns a random key for a POST and its retries
+func newIdempotencyKey() string {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		// Without a key the POST is just not deduplicated
+		return ""
+	}
+	return hex.EncodeToString(key)
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+func TestRetriesKeepTheIdempotencyKey(t *testing.T) {
+	var keys []string
+	deletes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			keys = append(keys, r.Header.Get(IdempotencyHeader))
+			if len(keys) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(sharedmodels.NodePool{ID: "np-1", ResourceVersion: 1})
+		case http.MethodDelete:
+			deletes++
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient(srv.URL)
+	c.retryDelay = time.Millisecond
+	pool, err := c.CreateNodePool("c-1", &sharedmodels.NodePool{Name: "workers"})
+	if err != nil || pool.ID != "np-1" {
+		t.Fatalf("create = %+v, %v", pool, err)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("idempotency keys = %q", keys)
+	}
+	if _, err := c.CreateNodePool("c-1", &sharedmodels.NodePool{Name: "workers"}); err != nil || keys[3] == keys[0] {
+		t.Errorf("second create: %v, keys %q", err, keys)
+	}
+
+	// A DELETE may have been done behind a failing gateway
+	if err := c.DeleteNodePool("c-1", "np-1"); err == nil || deletes != 1 {
+		t.Errorf("delete: %v after %d requests", err, deletes)
+	}
+
+	c.SetRetries(0)
+	keys = nil
+	if _, err := c.CreateNodePool("c-1", &sharedmodels.NodePool{Name: "workers"}); err == nil || len(keys) != 1 {
+		t.Errorf("create without retries: %v after %d requests", err, len(keys))
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SnapshotSummary tells what a snapshot archive holds; restores and saved fixtures
+// answer with one
+type SnapshotSummary struct {
+	Name              string    `json:"name,omitempty"`
+	Version           int       `json:"version"`
+	CreatedAt         time.Time `json:"created_at"`
+	SimulationTime    time.Time `json:"simulation_time,omitempty"`
+	Projects          int       `json:"projects"`
+	Clusters          int       `json:"clusters"`
+	NodePools         int       `json:"node_pools"`
+	TestResults       int       `json:"test_results"`
+	AzureResources    int       `json:"azure_resources"`
+	Buckets           int       `json:"buckets"`
+	Objects           int       `json:"objects"`
+	SimulatedClusters int       `json:"simulated_clusters"`
+}
+
+// Fixture is a snapshot archive kept on the server under a name
+type Fixture struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// Snapshot returns the archive of the whole server state (GET
+// /api/v1/simulate/admin/snapshot). The archive is kept as the server wrote it, so
+// it can be stored and restored as is.
+func (c *APIClient) Snapshot() (json.RawMessage, error) {
+	var archive json.RawMessage
+	err := c.doJSON(http.MethodGet, "/api/v1/simulate/admin/snapshot", nil, http.StatusOK, &archive)
+	return archive, err
+}
+
+// Restore replaces the whole server state with a snapshot archive
+func (c *APIClient) Restore(archive json.RawMessage) (*SnapshotSummary, error) {
+	var summary SnapshotSummary
+	if err := c.doJSON(http.MethodPost, "/api/v1/simulate/admin/restore", archive, http.StatusOK, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// ListFixtures lists the fixtures kept on the server
+func (c *APIClient) ListFixtures() ([]Fixture, error) {
+	return listAll[Fixture](c, "/api/v1/simulate/admin/fixtures", "fixtures", ListOptions{})
+}
+
+// SaveFixture keeps the current server state as the named fixture. With an archive,
+// that archive is kept instead.
+func (c *APIClient) SaveFixture(name string, archive json.RawMessage) (*SnapshotSummary, error) {
+	method := http.MethodPost
+	var body interface{}
+	if archive != nil {
+		method, body = http.MethodPut, archive
+	}
+	var summary SnapshotSummary
+	if err := c.doJSON(method, fixturePath(name), body, http.StatusCreated, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// GetFixture returns the archive of a fixture
+func (c *APIClient) GetFixture(name string) (json.RawMessage, error) {
+	var archive json.RawMessage
+	err := c.doJSON(http.MethodGet, fixturePath(name), nil, http.StatusOK, &archive)
+	return archive, err
+}
+
+// RestoreFixture replaces the whole server state with a fixture
+func (c *APIClient) RestoreFixture(name string) (*SnapshotSummary, error) {
+	var summary SnapshotSummary
+	if err := c.doJSON(http.MethodPost, fixturePath(name)+"/restore", nil, http.StatusOK, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// DeleteFixture removes a fixture from the server
+func (c *APIClient) DeleteFixture(name string) error {
+	return c.doJSON(http.MethodDelete, fixturePath(name), nil, http.StatusNoContent, nil)
+}
+
+func fixturePath(name string) string {
+	return "/api/v1/simulate/admin/fixtures/" + url.PathEscape(name)
+}
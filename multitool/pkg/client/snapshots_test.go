@@ -0,0 +1,51 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSnapshotAndFixtures(t *testing.T) {
+	archive := json.RawMessage(`{"version":1,"created_at":"2026-01-02T03:04:05Z","store":{"projects":[]}}`)
+	var restored, kept []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/simulate/admin/snapshot":
+			w.Write(archive)
+		case "POST /api/v1/simulate/admin/restore":
+			restored, _ = io.ReadAll(r.Body)
+			json.NewEncoder(w).Encode(SnapshotSummary{Version: 1, Clusters: 2})
+		case "PUT /api/v1/simulate/admin/fixtures/base":
+			kept, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(SnapshotSummary{Name: "base", Version: 1})
+		case "POST /api/v1/simulate/admin/fixtures/missing/restore":
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "fixture not found: missing"})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient(srv.URL)
+	got, err := c.Snapshot()
+	if err != nil || !bytes.Equal(got, archive) {
+		t.Fatalf("snapshot = %s, %v", got, err)
+	}
+	summary, err := c.Restore(got)
+	if err != nil || summary.Clusters != 2 || !bytes.Equal(restored, archive) {
+		t.Errorf("restore: %+v, %v, body %s", summary, err, restored)
+	}
+	if summary, err := c.SaveFixture("base", got); err != nil || summary.Name != "base" || !bytes.Equal(kept, archive) {
+		t.Errorf("save fixture: %+v, %v, body %s", summary, err, kept)
+	}
+	if _, err := c.RestoreFixture("missing"); err == nil || err.Error() != "fixture not found: missing (status 404)" {
+		t.Errorf("restore of a missing fixture: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+// Package events consumes the cube-server event stream (GET /api/v1/events). The
+// stream is Server-Sent Events; Subscribe reconnects after a dropped connection and
+// resumes from the last event it delivered, so no event is seen twice or missed.
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// DefaultRetryDelay is the wait before reconnecting when the server did not send a retry hint
+const DefaultRetryDelay = 2 * time.Second
+
+// ErrStop can be returned by a handler to end Subscribe without an error.
+var ErrStop = errors.New("stop subscription")
+
+// Options select the events of a subscription.
+type Options struct {
+	// Resource limits the stream to one resource type, e.g. models.EventResourceTests
+	Resource string
+	// ID limits the stream to one resource; requires Resource
+	ID string
+	// LastEventID resumes after this event ID; events kept by the server are replayed
+	LastEventID string
+}
+
+// Subscribe streams events from the cube-server at baseURL to handle until ctx is
+// done or handle returns an error. Dropped connections are re-established with the
+// Last-Event-ID header. It returns the ID of the last event passed to handle.
+func Subscribe(ctx context.Context, client *http.Client, baseURL string, opts Options, handle func(models.Event) error) (string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	// The stream stays open indefinitely, so a client-wide timeout would cut it off
+	streaming := *client
+	streaming.Timeout = 0
+
+	query := url.Values{}
+	if opts.Resource != "" {
+		query.Set("resource", opts.Resource)
+	}
+	if opts.ID != "" {
+		query.Set("id", opts.ID)
+	}
+	endpoint := strings.TrimSuffix(baseURL, "/") + "/api/v1/events"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	lastID, retry := opts.LastEventID, DefaultRetryDelay
+	for {
+		err := stream(ctx, &streaming, endpoint, &lastID, &retry, handle)
+		switch {
+		case errors.Is(err, ErrStop):
+			return lastID, nil
+		case ctx.Err() != nil:
+			return lastID, ctx.Err()
+		case err != nil && !isTransient(err):
+			return lastID, err
+		}
+		select {
+		case <-ctx.Done():
+			return lastID, ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+// statusError is a non-200 answer to the subscription request.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("event stream: server returned status %d", e.code)
+}
+
+// isTransient reports whether reconnecting may help: dropped connections and server errors.
+func isTransient(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code >= http.StatusInternalServerError
+	}
+	var handlerErr *handlerError
+	return !errors.As(err, &handlerErr)
+}
+
+// handlerError wraps an error returned by the subscriber's handler.
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string { return e.err.Error() }
+func (e *handlerError) Unwrap() error { return e.err }
+
+// stream reads one connection until it ends, updating lastID and retry as events arrive.
+func stream(ctx context.Context, client *http.Client, endpoint string, lastID *string, retry *time.Duration, handle func(models.Event) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return &handlerError{err}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{code: resp.StatusCode}
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var id, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			// A blank line dispatches the event collected so far
+			if data != "" {
+				var event models.Event
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					return &handlerError{fmt.Errorf("event stream: decode event %s: %w", id, err)}
+				}
+				if err := handle(event); err != nil {
+					if errors.Is(err, ErrStop) {
+						*lastID = id
+						return ErrStop
+					}
+					return &handlerError{err}
+				}
+			}
+			if id != "" {
+				*lastID = id
+			}
+			id, data = "", ""
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id = value
+		case "data":
+			if data != "" {
+				data += "\n"
+			}
+			data += value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				*retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+		// "event" duplicates Event.Type and lines starting with ':' are keep-alive comments
+	}
+}
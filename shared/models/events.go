@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Event is a change of a cube-server resource, as streamed by GET /api/v1/events
+type Event struct {
+	// ID increases with every event; clients resume a stream from the last ID they saw
+	ID         uint64                 `json:"id"`
+	Resource   string                 `json:"resource"`
+	ResourceID string                 `json:"resource_id"`
+	Type       string                 `json:"type"`
+	Time       time.Time              `json:"time"`
+	Message    string                 `json:"message,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	// Project is the project of the resource; subscribers only see their own project
+	Project string `json:"project,omitempty"`
+}
+
+// Event resources
+const (
+	EventResourceTests    = "tests"
+	EventResourceClusters = "clusters"
+	// EventResourceBuckets and EventResourceSimulatedClusters are the buckets and
+	// clusters of the simulation service
+	EventResourceBuckets           = "buckets"
+	EventResourceSimulatedClusters = "simulated_clusters"
+)
+
+// Event types
+const (
+	// EventCreated announces a new resource
+	EventCreated = "created"
+	// EventStatus is a status transition; Data["status"] holds the new status
+	EventStatus = "status"
+	// EventProgress carries partial metrics of a running test in Data
+	EventProgress = "progress"
+	// EventLog is a log line of a running test in Message
+	EventLog = "log"
+	// EventUpdated reports a changed resource
+	EventUpdated = "updated"
+	// EventDeleted reports a removed resource
+	EventDeleted = "deleted"
+)
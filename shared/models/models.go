@@ -46,8 +46,15 @@ const (
 	TestStatusRunning TestStatus = "running"
 	TestStatusPassed  TestStatus = "passed"
 	TestStatusFailed  TestStatus = "failed"
+	// TestStatusCancelled marks a test stopped through DELETE /tests/:id
+	TestStatusCancelled TestStatus = "cancelled"
 )
 
+// Finished reports whether a test with this status has ended and will not change again
+func (s TestStatus) Finished() bool {
+	return s == TestStatusPassed || s == TestStatusFailed || s == TestStatusCancelled
+}
+
 // Cluster represents a Kubernetes cluster across different cloud providers
 type Cluster struct {
 	ID             string                 `json:"id"`
@@ -60,21 +67,27 @@ type Cluster struct {
 	ResourceGroup  string                 `json:"resource_group,omitempty"`
 	Location       string                 `json:"location,omitempty"`
 	Region         string                 `json:"region,omitempty"`
-	CreatedAt      time.Time              `json:"created_at"`
-	UpdatedAt      time.Time              `json:"updated_at"`
+	// Labels are free-form key/value pairs for selecting clusters in lists
+	Labels map[string]string `json:"labels,omitempty"`
+	// ResourceVersion is set by the store: 1 on create, one more on every update.
+	// The API serves it as the ETag and checks If-Match against it.
+	ResourceVersion int64     `json:"resource_version,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 // TestResult represents the result of a cluster test
 type TestResult struct {
-	ID          string                 `json:"id"`
-	ClusterID   string                 `json:"cluster_id"`
-	TestType    string                 `json:"test_type"`
-	Status      TestStatus             `json:"status"`
-	Duration    time.Duration          `json:"duration"`
-	Details     map[string]interface{} `json:"details,omitempty"`
-	ErrorMsg    string                 `json:"error_message,omitempty"`
-	StartedAt   time.Time              `json:"started_at"`
-	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+	ID              string                 `json:"id"`
+	ClusterID       string                 `json:"cluster_id"`
+	TestType        string                 `json:"test_type"`
+	Status          TestStatus             `json:"status"`
+	Duration        time.Duration          `json:"duration"`
+	Details         map[string]interface{} `json:"details,omitempty"`
+	ErrorMsg        string                 `json:"error_message,omitempty"`
+	StartedAt       time.Time              `json:"started_at"`
+	CompletedAt     *time.Time             `json:"completed_at,omitempty"`
+	ResourceVersion int64                  `json:"resource_version,omitempty"`
 }
 
 // TestRequest represents a request to run a test on a cluster
@@ -86,17 +99,18 @@ type TestRequest struct {
 
 // NodePool represents a node pool within a cluster
 type NodePool struct {
-	ID           string    `json:"id"`
-	ClusterID    string    `json:"cluster_id"`
-	Name         string    `json:"name"`
-	NodeCount    int       `json:"node_count"`
-	MinNodes     int       `json:"min_nodes"`
-	MaxNodes     int       `json:"max_nodes"`
-	AutoScaling  bool      `json:"auto_scaling"`
-	InstanceType string    `json:"instance_type"`
-	OSType       string    `json:"os_type"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID              string    `json:"id"`
+	ClusterID       string    `json:"cluster_id"`
+	Name            string    `json:"name"`
+	NodeCount       int       `json:"node_count"`
+	MinNodes        int       `json:"min_nodes"`
+	MaxNodes        int       `json:"max_nodes"`
+	AutoScaling     bool      `json:"auto_scaling"`
+	InstanceType    string    `json:"instance_type"`
+	OSType          string    `json:"os_type"`
+	ResourceVersion int64     `json:"resource_version,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 // AKSCluster represents an Azure Kubernetes Service cluster (for backward compatibility)
@@ -116,21 +130,23 @@ type AKSCluster struct {
 // Azure-specific models
 
 type AzureMonitoring struct {
-	ID          string                 `json:"id"`
-	Name        string                 `json:"name"`
-	Description string                 `json:"description"`
-	Config      map[string]interface{} `json:"config"`
-	CreatedAt   time.Time              `json:"created_at"`
-	UpdatedAt   time.Time              `json:"updated_at"`
+	ID              string                 `json:"id"`
+	Name            string                 `json:"name"`
+	Description     string                 `json:"description"`
+	Config          map[string]interface{} `json:"config"`
+	ResourceVersion int64                  `json:"resource_version,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
 }
 
 type AzureKubernetes struct {
-	ID          string                 `json:"id"`
-	Name        string                 `json:"name"`
-	ClusterSize int                    `json:"cluster_size"`
-	Config      map[string]interface{} `json:"config"`
-	CreatedAt   time.Time              `json:"created_at"`
-	UpdatedAt   time.Time              `json:"updated_at"`
+	ID              string                 `json:"id"`
+	Name            string                 `json:"name"`
+	ClusterSize     int                    `json:"cluster_size"`
+	Config          map[string]interface{} `json:"config"`
+	ResourceVersion int64                  `json:"resource_version,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
 }
 
 // LogAnalyticsWorkspace, AppInsightsResource, and AzureBudget are defined in azure.go
@@ -186,6 +202,7 @@ type ObjectStoragePolicy struct {
 }
 
 type ObjectStorageStatement struct {
+	Sid       string                 `json:"sid,omitempty"`
 	Effect    string                 `json:"effect"`
 	Action    []string               `json:"action"`
 	Resource  []string               `json:"resource"`
@@ -195,7 +212,46 @@ type ObjectStorageStatement struct {
 
 // ObjectStorageRule represents a lifecycle rule for a bucket
 type ObjectStorageRule struct {
-	ID     string `json:"id"`
-	Status string `json:"status"`
-	// Add more fields as needed for expiration, transitions, etc.
+	ID                             string                                       `json:"id"`
+	Status                         string                                       `json:"status"` // Enabled or Disabled
+	Filter                         *ObjectStorageRuleFilter                     `json:"filter,omitempty"`
+	Expiration                     *ObjectStorageExpiration                     `json:"expiration,omitempty"`
+	Transitions                    []ObjectStorageTransition                    `json:"transitions,omitempty"`
+	NoncurrentVersionExpiration    *ObjectStorageNoncurrentVersionExpiration    `json:"noncurrent_version_expiration,omitempty"`
+	AbortIncompleteMultipartUpload *ObjectStorageAbortIncompleteMultipartUpload `json:"abort_incomplete_multipart_upload,omitempty"`
+}
+
+// ObjectStorageRuleFilter selects the objects a lifecycle rule applies to.
+// An object matches when its key starts with Prefix and it carries all Tags.
+type ObjectStorageRuleFilter struct {
+	Prefix string            `json:"prefix,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+// ObjectStorageExpiration expires current object versions after Days or on Date.
+// ExpiredObjectDeleteMarker removes delete markers that no longer hide any version.
+type ObjectStorageExpiration struct {
+	Days                      int        `json:"days,omitempty"`
+	Date                      *time.Time `json:"date,omitempty"`
+	ExpiredObjectDeleteMarker bool       `json:"expired_object_delete_marker,omitempty"`
+}
+
+// ObjectStorageTransition moves current object versions to another storage class.
+type ObjectStorageTransition struct {
+	Days         int        `json:"days,omitempty"`
+	Date         *time.Time `json:"date,omitempty"`
+	StorageClass string     `json:"storage_class"`
+}
+
+// ObjectStorageNoncurrentVersionExpiration permanently deletes versions NoncurrentDays
+// after they became noncurrent, keeping the NewerNoncurrentVersions most recent ones.
+type ObjectStorageNoncurrentVersionExpiration struct {
+	NoncurrentDays          int `json:"noncurrent_days"`
+	NewerNoncurrentVersions int `json:"newer_noncurrent_versions,omitempty"`
+}
+
+// ObjectStorageAbortIncompleteMultipartUpload aborts multipart uploads that are still
+// in progress DaysAfterInitiation days after they were started.
+type ObjectStorageAbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `json:"days_after_initiation"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultProject is the project of requests that select none. It always exists and
+// holds everything created before projects were introduced.
+const DefaultProject = "default"
+
+// projectNamePattern keeps project names usable in URLs, file names and bolt bucket names
+var projectNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidateProjectName checks that name is 1-63 lowercase letters, digits and dashes,
+// starting and ending with a letter or digit.
+func ValidateProjectName(name string) error {
+	if !projectNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid project name %q: use 1-63 lowercase letters, digits and dashes", name)
+	}
+	return nil
+}
+
+// Project is a tenant of cube-server: its clusters, test results, node pools, Azure
+// resources and simulated buckets are invisible to other projects.
+type Project struct {
+	Name            string       `json:"name"`
+	Description     string       `json:"description,omitempty"`
+	Quota           ProjectQuota `json:"quota"`
+	ResourceVersion int64        `json:"resource_version,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// ProjectQuota limits the resources of a project; 0 means unlimited.
+type ProjectQuota struct {
+	Clusters  int `json:"clusters,omitempty"`
+	NodePools int `json:"node_pools,omitempty"`
+	// Buckets counts the simulated buckets of all providers
+	Buckets int `json:"buckets,omitempty"`
+}
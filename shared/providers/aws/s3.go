@@ -6,6 +6,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
@@ -29,6 +30,40 @@ func NewS3Client(ctx context.Context, region string) (*S3Client, error) {
 	return &S3Client{client: s3.NewFromConfig(cfg), region: region}, nil
 }
 
+// S3Options configures an S3Client explicitly instead of through the default AWS config chain.
+type S3Options struct {
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Endpoint replaces the AWS endpoint, e.g. with an S3-compatible service or the cube-server
+	// S3 simulation; such endpoints are addressed path-style
+	Endpoint string
+}
+
+// NewS3ClientWithOptions creates an S3 client with static credentials (when AccessKey is set)
+// and an optional endpoint override.
+func NewS3ClientWithOptions(ctx context.Context, opts S3Options) (*S3Client, error) {
+	loadOpts := []func(*config.LoadOptions) error{config.WithRegion(opts.Region)}
+	if opts.AccessKey != "" {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(opts.AccessKey, opts.SecretKey, "")))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	c := &S3Client{region: opts.Region}
+	c.client = s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	if opts.Endpoint != "" {
+		c.endpoints = map[string]string{"s3": opts.Endpoint}
+	}
+	return c, nil
+}
+
 func (c *S3Client) GetName() string                        { return "aws" }
 func (c *S3Client) SimulationMode() bool                   { return c.simulation }
 func (c *S3Client) SetSimulationMode(enabled bool)         { c.simulation = enabled }
@@ -57,12 +92,18 @@ func (c *S3Client) GetEndpoint(service string) string {
 }
 
 func (c *S3Client) CreateBucket(ctx context.Context, bucket *models.ObjectStorageBucket) error {
-	_, err := c.client.CreateBucket(ctx, &s3.CreateBucketInput{
-		Bucket: aws.String(bucket.Name),
-		CreateBucketConfiguration: &types.CreateBucketConfiguration{
-			LocationConstraint: types.BucketLocationConstraint(bucket.Region),
-		},
-	})
+	region := bucket.Region
+	if region == "" {
+		region = c.region
+	}
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucket.Name)}
+	// us-east-1 is the default location and must not be sent as a constraint
+	if region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	_, err := c.client.CreateBucket(ctx, input)
 	return err
 }
 
@@ -73,7 +114,14 @@ func (c *S3Client) ListBuckets(ctx context.Context) ([]models.ObjectStorageBucke
 	}
 	var buckets []models.ObjectStorageBucket
 	for _, b := range resp.Buckets {
-		buckets = append(buckets, models.ObjectStorageBucket{Name: *b.Name, Provider: models.CloudProviderAWS})
+		bucket := models.ObjectStorageBucket{Name: aws.ToString(b.Name), Provider: models.CloudProviderAWS, Region: aws.ToString(b.BucketRegion)}
+		if bucket.Region == "" {
+			bucket.Region = c.region
+		}
+		if b.CreationDate != nil {
+			bucket.CreatedAt = *b.CreationDate
+		}
+		buckets = append(buckets, bucket)
 	}
 	return buckets, nil
 }
@@ -37,6 +37,8 @@ type HetznerS3Client struct {
 	accessKey string
 	secretKey string
 	region    string
+	// endpoint overrides the Hetzner endpoint of the region (see SetEndpoint)
+	endpoint string
 	// Simulation persistence
 	simBuckets     []models.ObjectStorageBucket
 	simMu          sync.Mutex
@@ -110,6 +112,29 @@ func NewHetznerS3ClientWithKeys(accessKey, secretKey, region string) *HetznerS3C
 	return &HetznerS3Client{accessKey: accessKey, secretKey: secretKey, region: region}
 }
 
+// SetEndpoint points the S3 calls at another S3-compatible endpoint (addressed path-style),
+// e.g. the cube-server S3 simulation. An empty endpoint restores the Hetzner one.
+func (c *HetznerS3Client) SetEndpoint(endpoint string) { c.endpoint = endpoint }
+
+// s3API returns an S3 client for the configured credentials, region and endpoint.
+func (c *HetznerS3Client) s3API(ctx context.Context) (*s3.Client, error) {
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = getHetznerS3Endpoint(c.region)
+	}
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(c.region),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(c.accessKey, c.secretKey, "")),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = c.endpoint != ""
+	}), nil
+}
+
 func (c *HetznerS3Client) CreateBucket(ctx context.Context, bucket *models.ObjectStorageBucket) error {
 	if c.simEnabled {
 		c.simMu.Lock()
@@ -135,20 +160,10 @@ func (c *HetznerS3Client) CreateBucket(ctx context.Context, bucket *models.Objec
 		return nil
 	}
 	// Normal (real) mode
-	endpoint := getHetznerS3Endpoint(c.region)
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(c.region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(c.accessKey, c.secretKey, "")),
-		config.WithEndpointResolver(
-			aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
-				return aws.Endpoint{URL: endpoint, SigningRegion: c.region}, nil
-			}),
-		),
-	)
+	s3Client, err := c.s3API(ctx)
 	if err != nil {
 		return err
 	}
-	s3Client := s3.NewFromConfig(cfg)
 	_, err = s3Client.CreateBucket(ctx, &s3.CreateBucketInput{
 		Bucket: &bucket.Name,
 	})
@@ -167,22 +182,11 @@ func (c *HetznerS3Client) ListBuckets(ctx context.Context) ([]models.ObjectStora
 	// If S3 credentials are present, use S3 API
 	if c.accessKey != "" && c.secretKey != "" {
 		fmt.Printf("[DEBUG] Using Hetzner S3 credentials: accessKey=%s secretKey=%s region=%s\n", maskToken(c.accessKey), maskToken(c.secretKey), c.region)
-		endpoint := getHetznerS3Endpoint(c.region)
-		fmt.Printf("[DEBUG] S3 endpoint: %s\n", endpoint)
-		cfg, err := config.LoadDefaultConfig(ctx,
-			config.WithRegion(c.region),
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(c.accessKey, c.secretKey, "")),
-			config.WithEndpointResolver(
-				aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
-					return aws.Endpoint{URL: endpoint, SigningRegion: c.region}, nil
-				}),
-			),
-		)
+		s3Client, err := c.s3API(ctx)
 		if err != nil {
 			fmt.Printf("[DEBUG] AWS config error: %v\n", err)
 			return nil, err
 		}
-		s3Client := s3.NewFromConfig(cfg)
 		resp, err := s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
 		if err != nil {
 			fmt.Printf("[DEBUG] S3 ListBuckets error: %v\n", err)
@@ -268,20 +272,10 @@ func (c *HetznerS3Client) DeleteBucket(ctx context.Context, bucketName string) e
 		c.simMu.Unlock()
 		return nil
 	}
-	endpoint := "https://" + c.region + ".your-objectstorage.com"
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(c.region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(c.accessKey, c.secretKey, "")),
-		config.WithEndpointResolver(
-			aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
-				return aws.Endpoint{URL: endpoint, SigningRegion: c.region}, nil
-			}),
-		),
-	)
+	s3Client, err := c.s3API(ctx)
 	if err != nil {
 		return err
 	}
-	s3Client := s3.NewFromConfig(cfg)
 	_, err = s3Client.DeleteBucket(ctx, &s3.DeleteBucketInput{
 		Bucket: &bucketName,
 	})
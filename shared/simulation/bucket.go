@@ -1,10 +1,12 @@
 package simulation
 
 import (
-	   "encoding/json"
-	   "fmt"
-	   "os"
-	   "sync"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
 )
 
 // BucketStore handles bucket simulation state and persistence
@@ -12,19 +14,42 @@ import (
 
 type BucketStore struct {
 	mu         sync.Mutex
-	buckets    map[string]map[string]interface{} // provider -> bucketName -> bucketInfo
+	buckets    map[string]map[string]interface{}          // provider -> bucketName -> bucketInfo
+	objects    map[string]map[string]map[string][]*Object // provider -> bucketName -> key -> versions (memory only)
+	uploads    map[string]*MultipartUploads               // provider -> in-progress multipart uploads (memory only)
+	versionSeq int64
+	// ids draws the upload and version IDs; the service shares its seeded source
+	ids         *rand.Rand
+	clock       *Clock
 	persistPath string
+	// notify reports created and deleted buckets (see changes.go)
+	notify func(Change)
 }
 
 func NewBucketStore(persistPath string) *BucketStore {
+	return newBucketStore(persistPath, NewClock(), rand.New(newLockedSource(time.Now().UnixNano())))
+}
+
+// newBucketStore returns a bucket store on clock drawing IDs from ids; project stores
+// share both with the default project's
+func newBucketStore(persistPath string, clock *Clock, ids *rand.Rand) *BucketStore {
 	bs := &BucketStore{
-		buckets: make(map[string]map[string]interface{}),
+		buckets:     make(map[string]map[string]interface{}),
+		objects:     make(map[string]map[string]map[string][]*Object),
+		uploads:     make(map[string]*MultipartUploads),
+		ids:         ids,
+		clock:       clock,
 		persistPath: persistPath,
 	}
 	bs.Load()
 	return bs
 }
 
+// Clock returns the simulation clock used for object timestamps and lifecycle rules.
+func (bs *BucketStore) Clock() *Clock {
+	return bs.clock
+}
+
 func (bs *BucketStore) Load() {
 	bs.mu.Lock()
 	defer bs.mu.Unlock()
@@ -37,17 +62,17 @@ func (bs *BucketStore) Load() {
 }
 
 func (bs *BucketStore) Save() {
-	   f, err := os.Create(bs.persistPath)
-	   if err != nil {
-			   fmt.Fprintf(os.Stderr, "[BUCKET DEBUG] Failed to write persist file: %s: %v\n", bs.persistPath, err)
-			   return
-	   }
-	   defer f.Close()
-	   if err := json.NewEncoder(f).Encode(bs.buckets); err != nil {
-			   fmt.Fprintf(os.Stderr, "[BUCKET DEBUG] Failed to encode buckets to persist file: %s: %v\n", bs.persistPath, err)
-	   } else {
-			   fmt.Fprintf(os.Stderr, "[BUCKET DEBUG] Persisted buckets to file: %s\n", bs.persistPath)
-	   }
+	f, err := os.Create(bs.persistPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[BUCKET DEBUG] Failed to write persist file: %s: %v\n", bs.persistPath, err)
+		return
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(bs.buckets); err != nil {
+		fmt.Fprintf(os.Stderr, "[BUCKET DEBUG] Failed to encode buckets to persist file: %s: %v\n", bs.persistPath, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "[BUCKET DEBUG] Persisted buckets to file: %s\n", bs.persistPath)
+	}
 }
 
 func (bs *BucketStore) Create(provider, name, region string) map[string]interface{} {
@@ -57,27 +82,51 @@ func (bs *BucketStore) Create(provider, name, region string) map[string]interfac
 		bs.buckets[provider] = make(map[string]interface{})
 	}
 	bucket := map[string]interface{}{
-		"bucket":   name,
-		"provider": provider,
-		"region":   region,
-		"status":   "created",
+		"bucket":     name,
+		"provider":   provider,
+		"region":     region,
+		"status":     "created",
+		"created_at": bs.clock.Now().Format(time.RFC3339),
 	}
 	bs.buckets[provider][name] = bucket
 	bs.Save()
+	bs.report(ChangeCreated, provider, name, bucket)
 	return bucket
 }
 
+// Get returns the bucket info for a single bucket and whether it exists.
+func (bs *BucketStore) Get(provider, name string) (map[string]interface{}, bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bucket, ok := bs.buckets[provider][name].(map[string]interface{})
+	return bucket, ok
+}
+
 func (bs *BucketStore) Delete(provider, name string) (bool, map[string]interface{}) {
 	bs.mu.Lock()
 	defer bs.mu.Unlock()
+	return bs.deleteLocked(provider, name)
+}
+
+// deleteLocked removes a bucket with its objects and uploads; the caller holds bs.mu
+func (bs *BucketStore) deleteLocked(provider, name string) (bool, map[string]interface{}) {
 	if bs.buckets[provider] == nil {
 		return false, map[string]interface{}{"error": "provider not found"}
 	}
-	if _, ok := bs.buckets[provider][name]; !ok {
+	existing, ok := bs.buckets[provider][name]
+	if !ok {
 		return false, map[string]interface{}{"error": "bucket not found"}
 	}
 	delete(bs.buckets[provider], name)
+	if bs.objects[provider] != nil {
+		delete(bs.objects[provider], name)
+	}
+	if bs.uploads[provider] != nil {
+		bs.uploads[provider].AbortBucket(name)
+	}
 	bs.Save()
+	bucket, _ := existing.(map[string]interface{})
+	bs.report(ChangeDeleted, provider, name, bucket)
 	return true, map[string]interface{}{"bucket": name, "status": "deleted"}
 }
 
@@ -0,0 +1,137 @@
+package simulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Cassettes. A recording cassette writes every simulated operation and its result to
+// a JSON file; a replaying cassette answers operations from such a file instead of
+// simulating them, so a run can be reproduced exactly, timestamps included.
+
+// Cassette modes
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// Interaction is one recorded operation
+type Interaction struct {
+	Request SimulationRequest `json:"request"`
+	Result  SimulationResult  `json:"result"`
+}
+
+// Cassette records or replays the operations of a SimulationService
+type Cassette struct {
+	mu           sync.Mutex
+	mode         string
+	path         string
+	interactions []Interaction
+	keys         []string
+	played       []bool
+}
+
+// NewRecordingCassette starts an empty recording in path, replacing the file
+func NewRecordingCassette(path string) (*Cassette, error) {
+	c := &Cassette{mode: CassetteRecord, path: path}
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadCassette opens the recording in path for replay
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{mode: CassetteReplay, path: path}
+	if err := json.Unmarshal(data, &c.interactions); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	for _, interaction := range c.interactions {
+		c.keys = append(c.keys, interactionKey(&interaction.Request))
+	}
+	c.played = make([]bool, len(c.interactions))
+	return c, nil
+}
+
+// Mode returns CassetteRecord or CassetteReplay
+func (c *Cassette) Mode() string { return c.mode }
+
+// Path returns the cassette file
+func (c *Cassette) Path() string { return c.path }
+
+// Interactions returns the recorded operations
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// Record appends an operation and rewrites the cassette file
+func (c *Cassette) Record(req *SimulationRequest, result *SimulationResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, Interaction{Request: *req, Result: *result})
+	return c.save()
+}
+
+// Replay answers req with the first recorded interaction for the same provider,
+// operation and parameters that was not replayed yet. Requests that were not
+// recorded, or were asked more often than recorded, fail.
+func (c *Cassette) Replay(req *SimulationRequest) *SimulationResult {
+	key := interactionKey(req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, k := range c.keys {
+		if k == key && !c.played[i] {
+			c.played[i] = true
+			result := c.interactions[i].Result
+			return &result
+		}
+	}
+	return &SimulationResult{
+		Provider:  req.Provider,
+		Operation: req.Operation,
+		Success:   false,
+		Error:     fmt.Sprintf("cassette %s has no recorded %s/%s with these parameters", c.path, req.Provider, req.Operation),
+	}
+}
+
+// save writes the cassette; the caller holds c.mu (or owns c)
+func (c *Cassette) save() error {
+	interactions := c.interactions
+	if interactions == nil {
+		interactions = []Interaction{}
+	}
+	data, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o644)
+}
+
+// interactionKey identifies a request independently of how its parameters were
+// decoded: both sides go through JSON, which orders map keys and prints 2 and 2.0 alike
+func interactionKey(req *SimulationRequest) string {
+	params := []byte("{}")
+	if len(req.Parameters) > 0 {
+		params, _ = json.Marshal(req.Parameters)
+	}
+	return req.Provider + "\x00" + req.Operation + "\x00" + string(params)
+}
+
+// SetCassette records to or replays from c; nil simulates normally. Every project
+// shares the cassette.
+func (s *SimulationService) SetCassette(c *Cassette) {
+	s.rootService().cassette = c
+}
+
+// Cassette returns the active cassette, if any
+func (s *SimulationService) Cassette() *Cassette {
+	return s.rootService().cassette
+}
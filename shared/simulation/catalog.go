@@ -0,0 +1,37 @@
+package simulation
+
+// instanceTypes are the node sizes the simulated providers offer for their managed
+// Kubernetes service. IONOS sizes nodes by CPU family and core count instead, so it
+// has no entry.
+var instanceTypes = map[string][]string{
+	"azure":   {"Standard_D2s_v3", "Standard_D4s_v3", "Standard_B2s"},
+	"aws":     {"t3.medium", "t3.large", "m5.large", "m5.xlarge"},
+	"gcp":     {"e2-medium", "e2-standard-4", "n1-standard-2"},
+	"hetzner": {"cx11", "cx21", "cx31", "cx41"},
+	"stackit": {"c1.2", "c1.3", "c1.4", "c1.5"},
+}
+
+// InstanceTypes returns the node sizes of provider, or nil when the provider has no
+// instance type catalog
+func InstanceTypes(provider string) []string {
+	types := instanceTypes[provider]
+	if types == nil {
+		return nil
+	}
+	return append([]string(nil), types...)
+}
+
+// ValidInstanceType reports whether provider offers instanceType; every type is valid
+// for providers without a catalog
+func ValidInstanceType(provider, instanceType string) bool {
+	types, ok := instanceTypes[provider]
+	if !ok {
+		return true
+	}
+	for _, t := range types {
+		if t == instanceType {
+			return true
+		}
+	}
+	return false
+}
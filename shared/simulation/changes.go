@@ -0,0 +1,111 @@
+package simulation
+
+// Changes. The observer of a service (SetObserver) hears of every bucket created or
+// deleted and every simulated cluster created, changing status or gone, in every
+// project. Cluster transitions complete when the registry is next used or advanced
+// (AdvanceClusters), so observers that want them on time advance it periodically.
+
+// Kinds of changed resources
+const (
+	ChangeBucket  = "bucket"
+	ChangeCluster = "cluster"
+)
+
+// Change actions
+const (
+	ChangeCreated = "created"
+	ChangeDeleted = "deleted"
+	// ChangeStatus is a cluster reaching Status
+	ChangeStatus = "status"
+)
+
+// Change is a simulated resource that was created, changed status or removed
+type Change struct {
+	Project  string
+	Kind     string
+	Action   string
+	Provider string
+	// ID is the bucket name or cluster ID
+	ID     string
+	Name   string
+	Status string
+	Region string
+}
+
+// Observer is told about changes. It is called with the changed store locked and
+// must not call back into the simulation.
+type Observer func(Change)
+
+// SetObserver makes observer hear of the changes in every project of the service;
+// nil stops the reports.
+func (s *SimulationService) SetObserver(observer Observer) {
+	root := s.rootService()
+	root.observerMu.Lock()
+	defer root.observerMu.Unlock()
+	root.observer = observer
+}
+
+// notifier returns the report function of a project's stores
+func (s *SimulationService) notifier(project string) func(Change) {
+	root := s.rootService()
+	return func(change Change) {
+		root.observerMu.RLock()
+		observer := root.observer
+		root.observerMu.RUnlock()
+		if observer != nil {
+			change.Project = project
+			observer(change)
+		}
+	}
+}
+
+// AdvanceClusters completes the cluster transitions that are due in every project
+func (s *SimulationService) AdvanceClusters() {
+	root := s.rootService()
+	services := []*SimulationService{root}
+	root.projectsMu.Lock()
+	for _, p := range root.projects {
+		services = append(services, p)
+	}
+	root.projectsMu.Unlock()
+	for _, p := range services {
+		p.clusters.Advance()
+	}
+}
+
+// Advance completes the transitions that are due
+func (r *ClusterRegistry) Advance() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.advance(r.clock.Now())
+}
+
+// report tells the registry's observer about a cluster; the caller holds r.mu
+func (r *ClusterRegistry) report(action string, c *SimulatedCluster) {
+	if r.notify == nil {
+		return
+	}
+	region, _ := c.Attributes["region"].(string)
+	if region == "" {
+		region, _ = c.Attributes["location"].(string)
+	}
+	r.notify(Change{
+		Kind: ChangeCluster, Action: action, Provider: c.Provider, ID: c.ID, Name: c.Name,
+		Status: string(c.Status), Region: region,
+	})
+}
+
+// report tells the store's observer about a bucket; the caller holds bs.mu
+func (bs *BucketStore) report(action, provider, name string, bucket map[string]interface{}) {
+	if bs.notify == nil {
+		return
+	}
+	change := Change{Kind: ChangeBucket, Action: action, Provider: provider, ID: name, Name: name}
+	change.Region, _ = bucket["region"].(string)
+	if action == ChangeDeleted {
+		change.Status = ChangeDeleted
+	} else {
+		change.Status, _ = bucket["status"].(string)
+	}
+	bs.notify(change)
+}
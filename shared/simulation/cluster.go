@@ -0,0 +1,266 @@
+package simulation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// Simulated clusters. Every cluster lives in the ClusterRegistry from create_cluster
+// until its deletion finished. Clusters move through creating -> running on create and
+// deleting -> gone on delete; each step completes a fixed time after it started on
+// the simulation clock, so advancing the clock moves them along as well.
+
+var (
+	ErrClusterNotFound = errors.New("cluster not found")
+	// ErrInvalidClusterTransition is returned for actions the cluster's status does not allow
+	ErrInvalidClusterTransition = errors.New("invalid cluster transition")
+)
+
+// Default durations of the creating and deleting steps; fast-simulate divides them
+// by FastClusterTimeScale
+const (
+	DefaultClusterCreateTime = 30 * time.Second
+	DefaultClusterDeleteTime = 15 * time.Second
+	FastClusterTimeScale     = 100
+)
+
+// Cluster actions and the statuses they are allowed in
+const (
+	ClusterActionScale  = "scale"
+	ClusterActionDelete = "delete"
+)
+
+var clusterActionAllowed = map[string][]models.ClusterStatus{
+	ClusterActionScale:  {models.ClusterStatusRunning},
+	ClusterActionDelete: {models.ClusterStatusCreating, models.ClusterStatusRunning, models.ClusterStatusStopped, models.ClusterStatusFailed},
+}
+
+// SimulatedCluster is a cluster held by the ClusterRegistry
+type SimulatedCluster struct {
+	ID        string               `json:"cluster_id"`
+	Name      string               `json:"name"`
+	Provider  string               `json:"provider"`
+	Status    models.ClusterStatus `json:"status"`
+	NodeCount int                  `json:"node_count"`
+	// Attributes are the provider-specific fields (region, resource_group, ...)
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+	// NextStatus is reached at TransitionAt; a deleting cluster is removed instead
+	NextStatus   models.ClusterStatus `json:"next_status,omitempty"`
+	TransitionAt *time.Time           `json:"transition_at,omitempty"`
+}
+
+// Summary returns the cluster as returned by the simulated operations
+func (c *SimulatedCluster) Summary() map[string]interface{} {
+	summary := make(map[string]interface{}, len(c.Attributes)+8)
+	for k, v := range c.Attributes {
+		summary[k] = v
+	}
+	summary["cluster_id"] = c.ID
+	summary["name"] = c.Name
+	summary["provider"] = c.Provider
+	summary["status"] = string(c.Status)
+	summary["node_count"] = c.NodeCount
+	summary["created_at"] = c.CreatedAt.Format(time.RFC3339)
+	summary["updated_at"] = c.UpdatedAt.Format(time.RFC3339)
+	if c.TransitionAt != nil {
+		summary["next_status"] = string(c.NextStatus)
+		summary["transition_at"] = c.TransitionAt.Format(time.RFC3339Nano)
+	}
+	return summary
+}
+
+// ClusterRegistry holds the simulated clusters per provider and persists them
+type ClusterRegistry struct {
+	mu          sync.Mutex
+	clusters    map[string]map[string]*SimulatedCluster // provider -> cluster ID -> cluster
+	clock       *Clock
+	createTime  time.Duration
+	deleteTime  time.Duration
+	persistPath string
+	// notify reports created clusters, status changes and removals (see changes.go)
+	notify func(Change)
+}
+
+// NewClusterRegistry loads the registry persisted in persistPath (if any); with
+// fastSimulate the transitions take FastClusterTimeScale times less time
+func NewClusterRegistry(persistPath string, clock *Clock, fastSimulate bool) *ClusterRegistry {
+	r := &ClusterRegistry{
+		clusters:    make(map[string]map[string]*SimulatedCluster),
+		clock:       clock,
+		createTime:  DefaultClusterCreateTime,
+		deleteTime:  DefaultClusterDeleteTime,
+		persistPath: persistPath,
+	}
+	if fastSimulate {
+		r.createTime /= FastClusterTimeScale
+		r.deleteTime /= FastClusterTimeScale
+	}
+	if data, err := os.ReadFile(persistPath); err == nil {
+		_ = json.Unmarshal(data, &r.clusters)
+	}
+	return r
+}
+
+// clusterPersistPath places the cluster registry next to the bucket persistence file
+func clusterPersistPath(bucketPath string) string {
+	ext := filepath.Ext(bucketPath)
+	return strings.TrimSuffix(bucketPath, ext) + ".clusters" + ext
+}
+
+// Create registers a creating cluster. newID is called until it returns an unused ID.
+func (r *ClusterRegistry) Create(provider, name string, nodeCount int, attributes map[string]interface{}, newID func() string) SimulatedCluster {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	r.advance(now)
+	if r.clusters[provider] == nil {
+		r.clusters[provider] = make(map[string]*SimulatedCluster)
+	}
+	id := newID()
+	for r.clusters[provider][id] != nil {
+		id = newID()
+	}
+	c := &SimulatedCluster{
+		ID:         id,
+		Name:       name,
+		Provider:   provider,
+		Status:     models.ClusterStatusCreating,
+		NodeCount:  nodeCount,
+		Attributes: attributes,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	c.schedule(models.ClusterStatusRunning, now.Add(r.createTime))
+	r.clusters[provider][id] = c
+	r.save()
+	r.report(ChangeCreated, c)
+	return *c
+}
+
+// Get returns a cluster of provider
+func (r *ClusterRegistry) Get(provider, id string) (SimulatedCluster, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.advance(r.clock.Now())
+	c, ok := r.clusters[provider][id]
+	if !ok {
+		return SimulatedCluster{}, fmt.Errorf("%w: %s", ErrClusterNotFound, id)
+	}
+	return *c, nil
+}
+
+// List returns the clusters of provider, oldest first
+func (r *ClusterRegistry) List(provider string) []SimulatedCluster {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.advance(r.clock.Now())
+	clusters := make([]SimulatedCluster, 0, len(r.clusters[provider]))
+	for _, c := range r.clusters[provider] {
+		clusters = append(clusters, *c)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if !clusters[i].CreatedAt.Equal(clusters[j].CreatedAt) {
+			return clusters[i].CreatedAt.Before(clusters[j].CreatedAt)
+		}
+		return clusters[i].ID < clusters[j].ID
+	})
+	return clusters
+}
+
+// Delete starts deleting a cluster; it disappears once the deletion finished
+func (r *ClusterRegistry) Delete(provider, id string) (SimulatedCluster, error) {
+	return r.update(provider, id, ClusterActionDelete, func(c *SimulatedCluster, now time.Time) {
+		c.Status = models.ClusterStatusDeleting
+		c.schedule("", now.Add(r.deleteTime))
+	})
+}
+
+// Scale sets the node count of a running cluster
+func (r *ClusterRegistry) Scale(provider, id string, nodeCount int) (SimulatedCluster, error) {
+	return r.update(provider, id, ClusterActionScale, func(c *SimulatedCluster, now time.Time) {
+		c.NodeCount = nodeCount
+	})
+}
+
+// update applies action to a cluster after checking its status allows it
+func (r *ClusterRegistry) update(provider, id, action string, apply func(*SimulatedCluster, time.Time)) (SimulatedCluster, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	r.advance(now)
+	c, ok := r.clusters[provider][id]
+	if !ok {
+		return SimulatedCluster{}, fmt.Errorf("%w: %s", ErrClusterNotFound, id)
+	}
+	if !clusterActionAllowedIn(action, c.Status) {
+		return *c, fmt.Errorf("%w: cannot %s cluster %s while it is %s", ErrInvalidClusterTransition, action, id, c.Status)
+	}
+	status := c.Status
+	apply(c, now)
+	c.UpdatedAt = now
+	r.save()
+	if c.Status != status {
+		r.report(ChangeStatus, c)
+	}
+	return *c, nil
+}
+
+func clusterActionAllowedIn(action string, status models.ClusterStatus) bool {
+	for _, allowed := range clusterActionAllowed[action] {
+		if allowed == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *SimulatedCluster) schedule(next models.ClusterStatus, at time.Time) {
+	c.NextStatus = next
+	c.TransitionAt = &at
+}
+
+// advance completes the transitions due at now; the caller holds r.mu
+func (r *ClusterRegistry) advance(now time.Time) {
+	changed := false
+	for _, clusters := range r.clusters {
+		for id, c := range clusters {
+			if c.TransitionAt == nil || now.Before(*c.TransitionAt) {
+				continue
+			}
+			changed = true
+			if c.Status == models.ClusterStatusDeleting {
+				delete(clusters, id)
+				r.report(ChangeDeleted, c)
+				continue
+			}
+			c.Status, c.UpdatedAt = c.NextStatus, *c.TransitionAt
+			c.NextStatus, c.TransitionAt = "", nil
+			r.report(ChangeStatus, c)
+		}
+	}
+	if changed {
+		r.save()
+	}
+}
+
+// save persists the registry; the caller holds r.mu
+func (r *ClusterRegistry) save() {
+	data, err := json.Marshal(r.clusters)
+	if err == nil {
+		err = os.WriteFile(r.persistPath, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[SIM] Failed to persist clusters to %s: %v\n", r.persistPath, err)
+	}
+}
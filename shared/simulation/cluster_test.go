@@ -0,0 +1,178 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// sequentialIDs returns an ID generator handing out ids in turn
+func sequentialIDs(ids ...string) func() string {
+	next := 0
+	return func() string {
+		id := ids[next%len(ids)]
+		next++
+		return id
+	}
+}
+
+func newTestClusterRegistry(t *testing.T, fastSimulate bool) (*ClusterRegistry, *Clock, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "buckets.clusters.json")
+	clock := NewClock()
+	return NewClusterRegistry(path, clock, fastSimulate), clock, path
+}
+
+func TestClusterRegistryLifecycle(t *testing.T) {
+	type step struct {
+		// advance moves the clock before the action
+		advance time.Duration
+		// action is "get", "scale" or "delete"
+		action     string
+		wantStatus models.ClusterStatus
+		wantErr    error
+	}
+	tests := []struct {
+		name  string
+		fast  bool
+		steps []step
+	}{
+		{"created and running", false, []step{
+			{0, "get", models.ClusterStatusCreating, nil},
+			{DefaultClusterCreateTime - time.Second, "get", models.ClusterStatusCreating, nil},
+			{2 * time.Second, "get", models.ClusterStatusRunning, nil},
+		}},
+		{"fast simulate", true, []step{
+			{0, "get", models.ClusterStatusCreating, nil},
+			{DefaultClusterCreateTime/FastClusterTimeScale + time.Second, "get", models.ClusterStatusRunning, nil},
+		}},
+		{"scaling needs a running cluster", false, []step{
+			{0, "scale", models.ClusterStatusCreating, ErrInvalidClusterTransition},
+			{DefaultClusterCreateTime + time.Second, "scale", models.ClusterStatusRunning, nil},
+		}},
+		{"deleted while creating", false, []step{
+			{0, "delete", models.ClusterStatusDeleting, nil},
+			{0, "scale", models.ClusterStatusDeleting, ErrInvalidClusterTransition},
+			{0, "delete", models.ClusterStatusDeleting, ErrInvalidClusterTransition},
+			{DefaultClusterDeleteTime + time.Second, "get", "", ErrClusterNotFound},
+		}},
+		{"deleted while running", false, []step{
+			{DefaultClusterCreateTime + time.Second, "delete", models.ClusterStatusDeleting, nil},
+			{DefaultClusterDeleteTime - time.Second, "get", models.ClusterStatusDeleting, nil},
+			{2 * time.Second, "get", "", ErrClusterNotFound},
+			{0, "delete", "", ErrClusterNotFound},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, clock, _ := newTestClusterRegistry(t, tt.fast)
+			created := r.Create("aws", "primary", 3, map[string]interface{}{"region": "eu-central-1"}, sequentialIDs("c-1"))
+			for i, s := range tt.steps {
+				if _, err := clock.Advance(s.advance); err != nil {
+					t.Fatal(err)
+				}
+				var got SimulatedCluster
+				var err error
+				switch s.action {
+				case "get":
+					got, err = r.Get("aws", created.ID)
+				case "scale":
+					got, err = r.Scale("aws", created.ID, 5)
+				case "delete":
+					got, err = r.Delete("aws", created.ID)
+				}
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d (%s): expected %v, got %v", i, s.action, s.wantErr, err)
+				}
+				if got.Status != s.wantStatus {
+					t.Fatalf("step %d (%s): status = %q, want %q", i, s.action, got.Status, s.wantStatus)
+				}
+				if s.action == "scale" && err == nil && got.NodeCount != 5 {
+					t.Errorf("step %d: node count = %d after scaling", i, got.NodeCount)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterRegistryCreate(t *testing.T) {
+	r, clock, _ := newTestClusterRegistry(t, false)
+	first := r.Create("aws", "primary", 3, map[string]interface{}{"region": "eu-central-1"}, sequentialIDs("c-1"))
+	if _, err := clock.Advance(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// The generator's first ID is taken, so it is asked again
+	second := r.Create("aws", "secondary", 1, nil, sequentialIDs("c-1", "c-2"))
+	other := r.Create("azure", "primary", 2, nil, sequentialIDs("c-1"))
+
+	tests := []struct {
+		name    string
+		cluster SimulatedCluster
+		wantID  string
+	}{
+		{"first", first, "c-1"},
+		{"taken ID", second, "c-2"},
+		{"other provider", other, "c-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cluster.ID != tt.wantID || tt.cluster.Status != models.ClusterStatusCreating || tt.cluster.TransitionAt == nil {
+				t.Errorf("Create = %+v, want creating cluster %s", tt.cluster, tt.wantID)
+			}
+		})
+	}
+
+	list := r.List("aws")
+	if len(list) != 2 || list[0].ID != "c-1" || list[1].ID != "c-2" {
+		t.Errorf("List(aws) = %+v, want c-1 and c-2 oldest first", list)
+	}
+	if list := r.List("gcp"); len(list) != 0 {
+		t.Errorf("List(gcp) = %+v, want none", list)
+	}
+	if _, err := r.Get("azure", "c-2"); !errors.Is(err, ErrClusterNotFound) {
+		t.Errorf("Get of another provider's cluster: expected ErrClusterNotFound, got %v", err)
+	}
+	// The first cluster finished creating while the clock moved on
+	if summary := list[0].Summary(); summary["region"] != "eu-central-1" || summary["status"] != "running" || summary["next_status"] != nil || summary["node_count"] != 3 {
+		t.Errorf("Summary = %v", summary)
+	}
+	if summary := list[1].Summary(); summary["status"] != "creating" || summary["next_status"] != "running" {
+		t.Errorf("Summary = %v", summary)
+	}
+}
+
+func TestClusterRegistryPersistence(t *testing.T) {
+	r, clock, path := newTestClusterRegistry(t, false)
+	ids := make([]string, 3)
+	for i := range ids {
+		ids[i] = r.Create("aws", fmt.Sprintf("cluster-%d", i), 1, nil, sequentialIDs(fmt.Sprintf("c-%d", i))).ID
+	}
+	if _, err := clock.Advance(DefaultClusterCreateTime + time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Delete("aws", ids[2]); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	reloaded := NewClusterRegistry(path, clock, false)
+	tests := []struct {
+		id         string
+		wantStatus models.ClusterStatus
+	}{
+		{ids[0], models.ClusterStatusRunning},
+		{ids[1], models.ClusterStatusRunning},
+		{ids[2], models.ClusterStatusDeleting},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := reloaded.Get("aws", tt.id)
+			if err != nil || got.Status != tt.wantStatus {
+				t.Errorf("Get = %+v, %v, want %s", got, err, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+// Fault injection. A FaultProfile adds latency and failures to the simulated
+// operations it matches, so clients can exercise their retry and backoff handling.
+// Profiles are evaluated in order and the first enabled match applies.
+
+// Fault codes with a default HTTP status; any other code can be used with an explicit status
+const (
+	FaultThrottling         = "Throttling"         // 429
+	FaultQuotaExceeded      = "QuotaExceeded"      // 403
+	FaultServiceUnavailable = "ServiceUnavailable" // 503
+	FaultInternalError      = "InternalError"      // 500
+	FaultRequestTimeout     = "RequestTimeout"     // 504, used by timeout profiles
+)
+
+var faultStatus = map[string]int{
+	FaultThrottling:         http.StatusTooManyRequests,
+	FaultQuotaExceeded:      http.StatusForbidden,
+	FaultServiceUnavailable: http.StatusServiceUnavailable,
+	FaultInternalError:      http.StatusInternalServerError,
+	FaultRequestTimeout:     http.StatusGatewayTimeout,
+}
+
+// Latency distributions
+const (
+	LatencyFixed       = "fixed"
+	LatencyUniform     = "uniform"
+	LatencyNormal      = "normal"
+	LatencyExponential = "exponential"
+)
+
+// Duration is a time.Duration written as a string ("250ms", "2s") in YAML and JSON
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) { return []byte(time.Duration(d).String()), nil }
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Latency describes the delay added to an operation
+type Latency struct {
+	// Distribution is fixed (default, Value), uniform (Min-Max), normal (Mean, StdDev)
+	// or exponential (Mean); Max also caps normal and exponential samples
+	Distribution string   `yaml:"distribution" json:"distribution,omitempty"`
+	Value        Duration `yaml:"value" json:"value,omitempty"`
+	Min          Duration `yaml:"min" json:"min,omitempty"`
+	Max          Duration `yaml:"max" json:"max,omitempty"`
+	Mean         Duration `yaml:"mean" json:"mean,omitempty"`
+	StdDev       Duration `yaml:"stddev" json:"stddev,omitempty"`
+}
+
+// FaultError is the failure a profile injects
+type FaultError struct {
+	Code string `yaml:"code" json:"code"`
+	// Status defaults to the status of a known code, otherwise 500
+	Status  int    `yaml:"status" json:"status,omitempty"`
+	Message string `yaml:"message" json:"message,omitempty"`
+	// RetryAfter is suggested to the client (Retry-After header)
+	RetryAfter Duration `yaml:"retry_after" json:"retry_after,omitempty"`
+}
+
+// FaultProfile selects operations by provider, operation and resource name (path.Match
+// patterns, empty matches everything) and describes what happens to them.
+type FaultProfile struct {
+	Name      string `yaml:"name" json:"name"`
+	Provider  string `yaml:"provider" json:"provider,omitempty"`
+	Operation string `yaml:"operation" json:"operation,omitempty"`
+	Resource  string `yaml:"resource" json:"resource,omitempty"`
+	Disabled  bool   `yaml:"disabled" json:"disabled,omitempty"`
+
+	Latency *Latency `yaml:"latency" json:"latency,omitempty"`
+	// Timeout holds the operation this long and then fails it with RequestTimeout
+	Timeout Duration `yaml:"timeout" json:"timeout,omitempty"`
+	// ErrorRate (0-1) fails this share of the matching calls at random
+	ErrorRate float64 `yaml:"error_rate" json:"error_rate,omitempty"`
+	// FailCalls fails the listed matching calls (1 is the first)
+	FailCalls []int `yaml:"fail_calls" json:"fail_calls,omitempty"`
+	// FailEvery fails every Nth matching call
+	FailEvery int `yaml:"fail_every" json:"fail_every,omitempty"`
+	// Error is the failure injected by ErrorRate, FailCalls and FailEvery (default InternalError)
+	Error *FaultError `yaml:"error" json:"error,omitempty"`
+}
+
+// FaultProfileStatus is a profile with the number of calls it matched and failed
+type FaultProfileStatus struct {
+	FaultProfile
+	Calls    int `json:"calls"`
+	Failures int `json:"failures"`
+}
+
+// Fault is an injected failure
+type Fault struct {
+	Profile    string   `json:"profile"`
+	Code       string   `json:"code"`
+	Status     int      `json:"status"`
+	Message    string   `json:"message"`
+	RetryAfter Duration `json:"retry_after,omitempty"`
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("%s: %s (fault profile %s)", f.Code, f.Message, f.Profile)
+}
+
+// FaultInjector holds the active fault profiles and their call counters
+type FaultInjector struct {
+	mu       sync.Mutex
+	rand     *rand.Rand
+	profiles []FaultProfile
+	calls    []int
+	failures []int
+}
+
+// NewFaultInjector creates an injector without profiles, drawing from a source seeded
+// with seed
+func NewFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{rand: rand.New(rand.NewSource(seed))}
+}
+
+// Seed restarts the injector's random source, making error_rate failures and latency
+// samples repeatable
+func (f *FaultInjector) Seed(seed int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rand.Seed(seed)
+}
+
+// Validate reports the first problem of a profile
+func (p FaultProfile) Validate() error {
+	for _, pattern := range []string{p.Provider, p.Operation, p.Resource} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("fault profile %q: invalid pattern %q", p.Name, pattern)
+		}
+	}
+	if p.ErrorRate < 0 || p.ErrorRate > 1 {
+		return fmt.Errorf("fault profile %q: error_rate must be between 0 and 1", p.Name)
+	}
+	if p.FailEvery < 0 || p.Timeout < 0 {
+		return fmt.Errorf("fault profile %q: fail_every and timeout must not be negative", p.Name)
+	}
+	for _, n := range p.FailCalls {
+		if n < 1 {
+			return fmt.Errorf("fault profile %q: fail_calls are counted from 1", p.Name)
+		}
+	}
+	if e := p.Error; e != nil && e.Status != 0 && (e.Status < 400 || e.Status > 599) {
+		return fmt.Errorf("fault profile %q: error status must be between 400 and 599", p.Name)
+	}
+	if l := p.Latency; l != nil {
+		switch l.Distribution {
+		case "", LatencyFixed, LatencyNormal, LatencyExponential:
+		case LatencyUniform:
+			if l.Max < l.Min {
+				return fmt.Errorf("fault profile %q: latency max is below min", p.Name)
+			}
+		default:
+			return fmt.Errorf("fault profile %q: unknown latency distribution %q", p.Name, l.Distribution)
+		}
+	}
+	return nil
+}
+
+// SetProfiles replaces the active profiles and resets their counters
+func (f *FaultInjector) SetProfiles(profiles []FaultProfile) error {
+	profiles = append([]FaultProfile(nil), profiles...)
+	for i, p := range profiles {
+		if p.Name == "" {
+			profiles[i].Name = fmt.Sprintf("profile-%d", i+1)
+		}
+		if err := profiles[i].Validate(); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.profiles = profiles
+	f.calls = make([]int, len(profiles))
+	f.failures = make([]int, len(profiles))
+	return nil
+}
+
+// Profiles returns the active profiles with their counters
+func (f *FaultInjector) Profiles() []FaultProfileStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := make([]FaultProfileStatus, len(f.profiles))
+	for i, p := range f.profiles {
+		status[i] = FaultProfileStatus{FaultProfile: p, Calls: f.calls[i], Failures: f.failures[i]}
+	}
+	return status
+}
+
+// Inject applies the first profile matching the call: it waits for the profile's
+// latency or timeout (returning early when ctx is done) and returns a *Fault when the
+// call is to fail. delayed reports whether the profile set the call's latency, in
+// which case the simulator adds no delay of its own.
+func (f *FaultInjector) Inject(ctx context.Context, provider, operation, resource string) (delayed bool, err error) {
+	f.mu.Lock()
+	idx := -1
+	for i, p := range f.profiles {
+		if !p.Disabled && faultMatches(p.Provider, provider) && faultMatches(p.Operation, operation) && faultMatches(p.Resource, resource) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		f.mu.Unlock()
+		return false, nil
+	}
+	p := f.profiles[idx]
+	f.calls[idx]++
+	call := f.calls[idx]
+	var wait time.Duration
+	if p.Latency != nil {
+		wait = f.sampleLatency(p.Latency)
+	}
+	var fault *Fault
+	switch {
+	case p.Timeout > 0:
+		wait += time.Duration(p.Timeout)
+		fault = &Fault{Profile: p.Name, Code: FaultRequestTimeout, Status: faultStatus[FaultRequestTimeout],
+			Message: fmt.Sprintf("operation timed out after %s", time.Duration(p.Timeout))}
+	case failsCall(p, call) || (p.ErrorRate > 0 && f.rand.Float64() < p.ErrorRate):
+		fault = newFault(p, call)
+	}
+	if fault != nil {
+		f.failures[idx]++
+	}
+	f.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+	delayed = p.Latency != nil || p.Timeout > 0
+	if fault != nil {
+		return delayed, fault
+	}
+	return delayed, nil
+}
+
+// faultMatches matches a profile pattern; an empty pattern matches everything
+func faultMatches(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+func failsCall(p FaultProfile, call int) bool {
+	if p.FailEvery > 0 && call%p.FailEvery == 0 {
+		return true
+	}
+	for _, n := range p.FailCalls {
+		if n == call {
+			return true
+		}
+	}
+	return false
+}
+
+func newFault(p FaultProfile, call int) *Fault {
+	spec := FaultError{Code: FaultInternalError}
+	if p.Error != nil {
+		spec = *p.Error
+	}
+	fault := &Fault{Profile: p.Name, Code: spec.Code, Status: spec.Status, Message: spec.Message, RetryAfter: spec.RetryAfter}
+	if fault.Status == 0 {
+		fault.Status = http.StatusInternalServerError
+		if status, ok := faultStatus[spec.Code]; ok {
+			fault.Status = status
+		}
+	}
+	if fault.Message == "" {
+		fault.Message = fmt.Sprintf("injected %s on call %d", spec.Code, call)
+	}
+	return fault
+}
+
+// sampleLatency draws a delay from l; the caller holds f.mu for f.rand
+func (f *FaultInjector) sampleLatency(l *Latency) time.Duration {
+	var d float64
+	switch l.Distribution {
+	case LatencyUniform:
+		d = float64(l.Min) + f.rand.Float64()*float64(l.Max-l.Min)
+	case LatencyNormal:
+		d = float64(l.Mean) + f.rand.NormFloat64()*float64(l.StdDev)
+	case LatencyExponential:
+		d = f.rand.ExpFloat64() * float64(l.Mean)
+	default:
+		d = float64(l.Value)
+	}
+	if l.Max > 0 && l.Distribution != LatencyUniform {
+		d = math.Min(d, float64(l.Max))
+	}
+	return time.Duration(math.Max(d, 0))
+}
@@ -0,0 +1,134 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFaultProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile FaultProfile
+		wantErr bool
+	}{
+		{"empty", FaultProfile{Name: "p"}, false},
+		{"patterns", FaultProfile{Name: "p", Provider: "aws", Operation: "s3:*", Resource: "logs-?"}, false},
+		{"invalid pattern", FaultProfile{Name: "p", Resource: "[logs"}, true},
+		{"error rate above 1", FaultProfile{Name: "p", ErrorRate: 1.5}, true},
+		{"negative error rate", FaultProfile{Name: "p", ErrorRate: -0.1}, true},
+		{"negative fail_every", FaultProfile{Name: "p", FailEvery: -1}, true},
+		{"negative timeout", FaultProfile{Name: "p", Timeout: Duration(-time.Second)}, true},
+		{"fail_calls from 0", FaultProfile{Name: "p", FailCalls: []int{0, 2}}, true},
+		{"known code without a status", FaultProfile{Name: "p", Error: &FaultError{Code: FaultThrottling}}, false},
+		{"client error status", FaultProfile{Name: "p", Error: &FaultError{Code: "NoSuchKey", Status: http.StatusNotFound}}, false},
+		{"server error status", FaultProfile{Name: "p", Error: &FaultError{Code: "Bandwidth", Status: 599}}, false},
+		{"success status", FaultProfile{Name: "p", Error: &FaultError{Code: "OK", Status: http.StatusOK}}, true},
+		{"redirect status", FaultProfile{Name: "p", Error: &FaultError{Code: "Moved", Status: http.StatusMovedPermanently}}, true},
+		{"status beyond 599", FaultProfile{Name: "p", Error: &FaultError{Code: "Odd", Status: 600}}, true},
+		{"negative status", FaultProfile{Name: "p", Error: &FaultError{Code: "Odd", Status: -500}}, true},
+		{"uniform latency", FaultProfile{Name: "p", Latency: &Latency{Distribution: LatencyUniform, Min: Duration(time.Millisecond), Max: Duration(2 * time.Millisecond)}}, false},
+		{"uniform latency with max below min", FaultProfile{Name: "p", Latency: &Latency{Distribution: LatencyUniform, Min: Duration(2 * time.Millisecond), Max: Duration(time.Millisecond)}}, true},
+		{"unknown latency distribution", FaultProfile{Name: "p", Latency: &Latency{Distribution: "pareto"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.profile.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFaultInjectorFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile FaultProfile
+		// failing lists the calls (1-based) of the first six that fail
+		failing    []int
+		wantCode   string
+		wantStatus int
+	}{
+		{"fail_calls", FaultProfile{FailCalls: []int{2, 5}}, []int{2, 5}, FaultInternalError, http.StatusInternalServerError},
+		{"fail_every", FaultProfile{FailEvery: 3}, []int{3, 6}, FaultInternalError, http.StatusInternalServerError},
+		{"error rate 1", FaultProfile{ErrorRate: 1}, []int{1, 2, 3, 4, 5, 6}, FaultInternalError, http.StatusInternalServerError},
+		{"known code", FaultProfile{FailEvery: 2, Error: &FaultError{Code: FaultThrottling}}, []int{2, 4, 6}, FaultThrottling, http.StatusTooManyRequests},
+		{"explicit status", FaultProfile{FailCalls: []int{1}, Error: &FaultError{Code: "SlowDown", Status: http.StatusServiceUnavailable}}, []int{1}, "SlowDown", http.StatusServiceUnavailable},
+		{"other operation", FaultProfile{Operation: "s3:PutObject", ErrorRate: 1}, nil, "", 0},
+		{"disabled", FaultProfile{Disabled: true, ErrorRate: 1}, nil, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFaultInjector(1)
+			if err := f.SetProfiles([]FaultProfile{tt.profile}); err != nil {
+				t.Fatalf("SetProfiles: %v", err)
+			}
+			var failing []int
+			for call := 1; call <= 6; call++ {
+				_, err := f.Inject(context.Background(), "aws", "s3:GetObject", "logs")
+				if err == nil {
+					continue
+				}
+				var fault *Fault
+				if !errors.As(err, &fault) {
+					t.Fatalf("call %d: expected a *Fault, got %v", call, err)
+				}
+				if fault.Code != tt.wantCode || fault.Status != tt.wantStatus || fault.Profile != "profile-1" {
+					t.Errorf("call %d: fault = %+v, want %s %d", call, fault, tt.wantCode, tt.wantStatus)
+				}
+				failing = append(failing, call)
+			}
+			if len(failing) != len(tt.failing) {
+				t.Fatalf("failing calls = %v, want %v", failing, tt.failing)
+			}
+			for i := range failing {
+				if failing[i] != tt.failing[i] {
+					t.Fatalf("failing calls = %v, want %v", failing, tt.failing)
+				}
+			}
+			if status := f.Profiles()[0]; status.Failures != len(tt.failing) {
+				t.Errorf("Failures = %d, want %d", status.Failures, len(tt.failing))
+			}
+		})
+	}
+}
+
+func TestFaultInjectorTimeout(t *testing.T) {
+	f := NewFaultInjector(1)
+	if err := f.SetProfiles([]FaultProfile{{Name: "hang", Timeout: Duration(time.Hour)}}); err != nil {
+		t.Fatalf("SetProfiles: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	delayed, err := f.Inject(ctx, "aws", "s3:GetObject", "logs")
+	if !delayed || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Inject = %v, %v, want the context's error once it is done", delayed, err)
+	}
+}
+
+func TestSampleLatency(t *testing.T) {
+	ms := func(n int) Duration { return Duration(time.Duration(n) * time.Millisecond) }
+	tests := []struct {
+		name     string
+		latency  Latency
+		min, max time.Duration
+	}{
+		{"fixed", Latency{Value: ms(5)}, 5 * time.Millisecond, 5 * time.Millisecond},
+		{"uniform", Latency{Distribution: LatencyUniform, Min: ms(10), Max: ms(20)}, 10 * time.Millisecond, 20 * time.Millisecond},
+		{"uniform without a range", Latency{Distribution: LatencyUniform, Min: ms(10), Max: ms(10)}, 10 * time.Millisecond, 10 * time.Millisecond},
+		{"normal capped by max", Latency{Distribution: LatencyNormal, Mean: ms(50), StdDev: ms(40), Max: ms(60)}, 0, 60 * time.Millisecond},
+		{"exponential capped by max", Latency{Distribution: LatencyExponential, Mean: ms(30), Max: ms(45)}, 0, 45 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFaultInjector(1)
+			for i := 0; i < 200; i++ {
+				if d := f.sampleLatency(&tt.latency); d < tt.min || d > tt.max {
+					t.Fatalf("sample %d = %v, want between %v and %v", i, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package simulation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// Lifecycle rule evaluation. Rules are stored with the bucket metadata and applied by
+// ApplyLifecycle against the simulation clock, so tests can advance the clock and
+// check what expired instead of waiting for real days to pass.
+
+const (
+	LifecycleRuleEnabled  = "Enabled"
+	LifecycleRuleDisabled = "Disabled"
+
+	maxLifecycleRules = 1000
+)
+
+// Lifecycle actions reported by ApplyLifecycle
+const (
+	LifecycleActionExpiration                     = "expiration"
+	LifecycleActionTransition                     = "transition"
+	LifecycleActionNoncurrentVersionExpiration    = "noncurrent_version_expiration"
+	LifecycleActionExpiredObjectDeleteMarker      = "expired_object_delete_marker"
+	LifecycleActionAbortIncompleteMultipartUpload = "abort_incomplete_multipart_upload"
+)
+
+var (
+	ErrNoSuchLifecycleConfiguration = errors.New("the lifecycle configuration does not exist")
+	ErrInvalidLifecycleRule         = errors.New("invalid lifecycle rule")
+)
+
+// LifecycleAction describes one change made by the lifecycle engine.
+type LifecycleAction struct {
+	// Project is set by SimulationService.ApplyLifecycle
+	Project      string `json:"project,omitempty"`
+	Provider     string `json:"provider"`
+	Bucket       string `json:"bucket"`
+	RuleID       string `json:"rule_id"`
+	Action       string `json:"action"`
+	Key          string `json:"key"`
+	VersionID    string `json:"version_id,omitempty"`
+	UploadID     string `json:"upload_id,omitempty"`
+	StorageClass string `json:"storage_class,omitempty"`
+}
+
+// lifecycleRules returns the rules stored in the bucket info. Rules loaded from the
+// persist file are decoded from their generic JSON form on first use.
+func lifecycleRules(info map[string]interface{}) []models.ObjectStorageRule {
+	switch v := info["lifecycle"].(type) {
+	case nil:
+		return nil
+	case []models.ObjectStorageRule:
+		return v
+	default:
+		raw, _ := json.Marshal(v)
+		var rules []models.ObjectStorageRule
+		_ = json.Unmarshal(raw, &rules)
+		info["lifecycle"] = rules
+		return rules
+	}
+}
+
+// Lifecycle returns the lifecycle rules of a bucket.
+func (bs *BucketStore) Lifecycle(provider, bucket string) ([]models.ObjectStorageRule, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	info, ok := bs.buckets[provider][bucket].(map[string]interface{})
+	if !ok {
+		return nil, ErrNoSuchBucket
+	}
+	rules := lifecycleRules(info)
+	if len(rules) == 0 {
+		return nil, ErrNoSuchLifecycleConfiguration
+	}
+	return rules, nil
+}
+
+// SetLifecycle validates and stores the lifecycle rules of a bucket, replacing any
+// existing configuration.
+func (bs *BucketStore) SetLifecycle(provider, bucket string, rules []models.ObjectStorageRule) error {
+	if err := ValidateLifecycleRules(rules); err != nil {
+		return err
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	info, ok := bs.buckets[provider][bucket].(map[string]interface{})
+	if !ok {
+		return ErrNoSuchBucket
+	}
+	info["lifecycle"] = rules
+	bs.Save()
+	return nil
+}
+
+// DeleteLifecycle removes the lifecycle configuration of a bucket.
+func (bs *BucketStore) DeleteLifecycle(provider, bucket string) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	info, ok := bs.buckets[provider][bucket].(map[string]interface{})
+	if !ok {
+		return ErrNoSuchBucket
+	}
+	delete(info, "lifecycle")
+	bs.Save()
+	return nil
+}
+
+// ValidateLifecycleRules applies the S3 lifecycle configuration constraints.
+func ValidateLifecycleRules(rules []models.ObjectStorageRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("%w: at least one rule is required", ErrInvalidLifecycleRule)
+	}
+	if len(rules) > maxLifecycleRules {
+		return fmt.Errorf("%w: at most %d rules are allowed", ErrInvalidLifecycleRule, maxLifecycleRules)
+	}
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		invalid := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: rule %q: %s", ErrInvalidLifecycleRule, rule.ID, fmt.Sprintf(format, args...))
+		}
+		if len(rule.ID) > 255 {
+			return invalid("ID must be at most 255 characters")
+		}
+		if rule.ID != "" && seen[rule.ID] {
+			return invalid("duplicate rule ID")
+		}
+		seen[rule.ID] = true
+		if rule.Status != LifecycleRuleEnabled && rule.Status != LifecycleRuleDisabled {
+			return invalid("status must be %s or %s", LifecycleRuleEnabled, LifecycleRuleDisabled)
+		}
+		hasTags := rule.Filter != nil && len(rule.Filter.Tags) > 0
+		if rule.Expiration == nil && len(rule.Transitions) == 0 && rule.NoncurrentVersionExpiration == nil && rule.AbortIncompleteMultipartUpload == nil {
+			return invalid("at least one action must be specified")
+		}
+		if exp := rule.Expiration; exp != nil {
+			set := 0
+			if exp.Days != 0 {
+				set++
+			}
+			if exp.Date != nil {
+				set++
+			}
+			if exp.ExpiredObjectDeleteMarker {
+				set++
+			}
+			if set != 1 {
+				return invalid("expiration needs exactly one of days, date or expired_object_delete_marker")
+			}
+			if exp.Days < 0 {
+				return invalid("expiration days must be a positive integer")
+			}
+			if exp.ExpiredObjectDeleteMarker && hasTags {
+				return invalid("expired_object_delete_marker cannot be combined with a tag filter")
+			}
+		}
+		for _, tr := range rule.Transitions {
+			if tr.StorageClass == "" {
+				return invalid("transition storage class is required")
+			}
+			if tr.Days < 0 || (tr.Days != 0 && tr.Date != nil) {
+				return invalid("transition needs either days or a date")
+			}
+		}
+		if nve := rule.NoncurrentVersionExpiration; nve != nil && (nve.NoncurrentDays <= 0 || nve.NewerNoncurrentVersions < 0) {
+			return invalid("noncurrent version expiration days must be a positive integer")
+		}
+		if abort := rule.AbortIncompleteMultipartUpload; abort != nil {
+			if abort.DaysAfterInitiation <= 0 {
+				return invalid("days after initiation must be a positive integer")
+			}
+			if hasTags {
+				return invalid("abort incomplete multipart upload cannot be combined with a tag filter")
+			}
+		}
+	}
+	return nil
+}
+
+// ParseLifecycleRules decodes lifecycle rules from a simulation operation parameter:
+// a JSON string or value holding either a list of rules or {"rules": [...]}.
+func ParseLifecycleRules(v interface{}) ([]models.ObjectStorageRule, error) {
+	raw, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		raw = string(b)
+	}
+	raw = strings.TrimSpace(raw)
+	var rules []models.ObjectStorageRule
+	if strings.HasPrefix(raw, "[") {
+		if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidLifecycleRule, err)
+		}
+		return rules, nil
+	}
+	var cfg struct {
+		Rules []models.ObjectStorageRule `json:"rules"`
+	}
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLifecycleRule, err)
+	}
+	return cfg.Rules, nil
+}
+
+// ApplyLifecycle runs every enabled lifecycle rule against the current simulation
+// time and returns what it changed.
+func (bs *BucketStore) ApplyLifecycle() []LifecycleAction {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	now := bs.clock.Now()
+	actions := make([]LifecycleAction, 0)
+	for _, provider := range sortedKeys(bs.buckets) {
+		for _, bucket := range sortedKeys(bs.buckets[provider]) {
+			info, _ := bs.buckets[provider][bucket].(map[string]interface{})
+			for _, rule := range lifecycleRules(info) {
+				if rule.Status == LifecycleRuleEnabled {
+					actions = append(actions, bs.applyLifecycleRule(provider, bucket, rule, now)...)
+				}
+			}
+		}
+	}
+	return actions
+}
+
+// ApplyLifecycle runs the lifecycle rules of every project against the simulation
+// time, which the projects share, and returns what it changed. Projects not used since
+// the service started have no objects or uploads in memory, so they are skipped.
+func (s *SimulationService) ApplyLifecycle() []LifecycleAction {
+	root := s.rootService()
+	actions := make([]LifecycleAction, 0)
+	for _, name := range root.Projects() {
+		for _, action := range root.Project(name).buckets.ApplyLifecycle() {
+			action.Project = name
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// applyLifecycleRule applies one rule to a bucket. The caller must hold bs.mu.
+func (bs *BucketStore) applyLifecycleRule(provider, bucket string, rule models.ObjectStorageRule, now time.Time) []LifecycleAction {
+	var actions []LifecycleAction
+	record := func(action, key, versionID string) *LifecycleAction {
+		actions = append(actions, LifecycleAction{Provider: provider, Bucket: bucket, RuleID: rule.ID, Action: action, Key: key, VersionID: versionID})
+		return &actions[len(actions)-1]
+	}
+	prefix := ""
+	if rule.Filter != nil {
+		prefix = rule.Filter.Prefix
+	}
+	versioned := bs.versioning(provider, bucket) != VersioningUnversioned
+	objects := bs.bucketObjects(provider, bucket)
+
+	for _, key := range sortedKeys(objects) {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		// Noncurrent versions, newest first. A version became noncurrent when its
+		// successor was written.
+		if nve := rule.NoncurrentVersionExpiration; nve != nil && len(objects[key]) > 1 {
+			versions := objects[key]
+			kept := []*Object{versions[len(versions)-1]}
+			for i, newer := len(versions)-2, 0; i >= 0; i, newer = i-1, newer+1 {
+				v := versions[i]
+				if newer >= nve.NewerNoncurrentVersions && lifecycleTagsMatch(rule, v) && lifecycleDaysDue(versions[i+1].LastModified, nve.NoncurrentDays, now) {
+					record(LifecycleActionNoncurrentVersionExpiration, key, v.VersionID)
+					continue
+				}
+				kept = append([]*Object{v}, kept...)
+			}
+			objects[key] = kept
+		}
+
+		current := objects[key][len(objects[key])-1]
+		if exp := rule.Expiration; exp != nil && !current.IsDeleteMarker && lifecycleTagsMatch(rule, current) &&
+			(lifecycleDateDue(exp.Date, now) || lifecycleDaysDue(current.LastModified, exp.Days, now)) {
+			if versioned {
+				marker := &Object{Key: key, IsDeleteMarker: true, LastModified: now}
+				bs.addVersion(provider, bucket, marker)
+				record(LifecycleActionExpiration, key, marker.VersionID)
+			} else {
+				delete(objects, key)
+				record(LifecycleActionExpiration, key, current.VersionID)
+			}
+			continue
+		}
+
+		if !current.IsDeleteMarker && lifecycleTagsMatch(rule, current) {
+			// The due transition that fires last decides the storage class
+			target, targetDue := "", time.Time{}
+			for _, tr := range rule.Transitions {
+				due := lifecycleDue(current.LastModified, tr.Days)
+				if tr.Date != nil {
+					due = *tr.Date
+				}
+				if !now.Before(due) && (target == "" || due.After(targetDue)) {
+					target, targetDue = tr.StorageClass, due
+				}
+			}
+			if target != "" && current.StorageClass != target {
+				// Readers may hold the current version outside the lock, so it is
+				// replaced rather than changed
+				transitioned := *current
+				transitioned.StorageClass = target
+				objects[key][len(objects[key])-1] = &transitioned
+				record(LifecycleActionTransition, key, current.VersionID).StorageClass = target
+			}
+		}
+
+		if exp := rule.Expiration; exp != nil && exp.ExpiredObjectDeleteMarker {
+			if versions := objects[key]; len(versions) == 1 && versions[0].IsDeleteMarker {
+				delete(objects, key)
+				record(LifecycleActionExpiredObjectDeleteMarker, key, versions[0].VersionID)
+			}
+		}
+	}
+
+	if abort := rule.AbortIncompleteMultipartUpload; abort != nil && bs.uploads[provider] != nil {
+		for _, upload := range bs.uploads[provider].List(bucket, prefix) {
+			if lifecycleDaysDue(upload.Initiated, abort.DaysAfterInitiation, now) {
+				if err := bs.uploads[provider].Abort(bucket, upload.Key, upload.UploadID); err == nil {
+					record(LifecycleActionAbortIncompleteMultipartUpload, upload.Key, "").UploadID = upload.UploadID
+				}
+			}
+		}
+	}
+	return actions
+}
+
+// lifecycleDue returns when a rule with the given day count fires for something
+// created at t. Like S3, the result is rounded up to the next midnight UTC.
+func lifecycleDue(t time.Time, days int) time.Time {
+	due := t.UTC().AddDate(0, 0, days)
+	midnight := due.Truncate(24 * time.Hour)
+	if midnight.Before(due) {
+		midnight = midnight.Add(24 * time.Hour)
+	}
+	return midnight
+}
+
+func lifecycleDaysDue(t time.Time, days int, now time.Time) bool {
+	return days > 0 && !now.Before(lifecycleDue(t, days))
+}
+
+func lifecycleDateDue(date *time.Time, now time.Time) bool {
+	return date != nil && !now.Before(*date)
+}
+
+// lifecycleTagsMatch reports whether obj carries every tag of the rule filter.
+func lifecycleTagsMatch(rule models.ObjectStorageRule, obj *Object) bool {
+	if rule.Filter == nil {
+		return true
+	}
+	for k, v := range rule.Filter.Tags {
+		if obj.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package simulation
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+func TestLifecycleDue(t *testing.T) {
+	tests := []struct {
+		name    string
+		created time.Time
+		days    int
+		want    time.Time
+	}{
+		{"rounds up to midnight", time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC), 30, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"midnight stays", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 1, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"one nanosecond after midnight", time.Date(2026, 1, 1, 0, 0, 0, 1, time.UTC), 1, time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"other time zones count in UTC", time.Date(2026, 1, 1, 23, 0, 0, 0, time.FixedZone("CET", 3600)), 1, time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lifecycleDue(tt.created, tt.days); !got.Equal(tt.want) {
+				t.Errorf("lifecycleDue(%v, %d) = %v, want %v", tt.created, tt.days, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLifecycleTransitionTiming(t *testing.T) {
+	created := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	glacierDate := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	rules := []models.ObjectStorageRule{{
+		ID:     "archive",
+		Status: LifecycleRuleEnabled,
+		Transitions: []models.ObjectStorageTransition{
+			{Days: 30, StorageClass: "STANDARD_IA"},
+			{Days: 90, StorageClass: "GLACIER_IR"},
+			{Date: &glacierDate, StorageClass: "GLACIER"},
+		},
+	}}
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"before the first transition", time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC), DefaultStorageClass},
+		{"at the first transition", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), "STANDARD_IA"},
+		{"before the second transition", time.Date(2026, 4, 1, 23, 59, 59, 0, time.UTC), "STANDARD_IA"},
+		{"at the second transition", time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC), "GLACIER_IR"},
+		{"the transition due last wins", glacierDate, "GLACIER"},
+		{"skipped transitions", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), "GLACIER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs := NewBucketStore(filepath.Join(t.TempDir(), "buckets.json"))
+			bs.Create("aws", "logs", "eu-central-1")
+			if err := bs.SetLifecycle("aws", "logs", rules); err != nil {
+				t.Fatal(err)
+			}
+			bs.Clock().Set(created)
+			if _, err := bs.PutObject("aws", "logs", "app.log", []byte("line"), "", nil); err != nil {
+				t.Fatal(err)
+			}
+			before, _ := bs.GetObject("aws", "logs", "app.log")
+
+			bs.Clock().Set(tt.now)
+			actions := bs.ApplyLifecycle()
+			obj, err := bs.GetObject("aws", "logs", "app.log")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.StorageClass != tt.want {
+				t.Errorf("storage class = %s, want %s", obj.StorageClass, tt.want)
+			}
+			if transitioned := tt.want != DefaultStorageClass; transitioned != (len(actions) == 1) {
+				t.Errorf("actions = %+v", actions)
+			}
+			if before.StorageClass != DefaultStorageClass {
+				t.Errorf("a version read before the transition changed to %s", before.StorageClass)
+			}
+		})
+	}
+}
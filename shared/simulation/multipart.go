@@ -0,0 +1,367 @@
+package simulation
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3 multipart upload limits
+const (
+	MinPartNumber      = 1
+	MaxPartNumber      = 10000
+	DefaultMinPartSize = 5 * 1024 * 1024 // every part except the last must be at least 5 MiB
+)
+
+var (
+	ErrNoSuchUpload      = errors.New("upload not found")
+	ErrInvalidPart       = errors.New("invalid part")
+	ErrInvalidPartOrder  = errors.New("parts not in ascending order")
+	ErrEntityTooSmall    = errors.New("part smaller than the minimum allowed size")
+	ErrInvalidPartNumber = errors.New("part number must be an integer between 1 and 10000")
+)
+
+// Part is a single uploaded part of a multipart upload.
+type Part struct {
+	PartNumber   int       `json:"part_number"`
+	ETag         string    `json:"etag"`
+	Data         []byte    `json:"data"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Size returns the part size in bytes.
+func (p *Part) Size() int64 {
+	return int64(len(p.Data))
+}
+
+// CompletedPart identifies a part in a CompleteMultipartUpload request.
+type CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// MultipartUpload is an in-progress multipart upload.
+type MultipartUpload struct {
+	UploadID    string            `json:"upload_id"`
+	Bucket      string            `json:"bucket"`
+	Key         string            `json:"key"`
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Initiated   time.Time         `json:"initiated"`
+	Parts       map[int]*Part     `json:"parts"`
+}
+
+// SortedParts returns the uploaded parts ordered by part number.
+func (u *MultipartUpload) SortedParts() []*Part {
+	parts := make([]*Part, 0, len(u.Parts))
+	for _, p := range u.Parts {
+		parts = append(parts, p)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts
+}
+
+// MultipartUploads tracks in-progress multipart uploads independently of where the
+// completed object ends up. BucketStore and the Hetzner S3 mock both use it.
+type MultipartUploads struct {
+	mu      sync.Mutex
+	uploads map[string]*MultipartUpload // uploadID -> upload
+	seq     int64
+	// minPartSize is the minimum size of every part except the last (SetMinPartSize)
+	minPartSize int64
+	// Clock timestamps uploads and parts; nil uses the wall clock
+	Clock *Clock
+	// IDs is drawn into the upload IDs; nil derives them from the sequence alone
+	IDs *rand.Rand
+}
+
+// NewMultipartUploads creates an empty upload tracker using the S3 5 MiB part minimum.
+func NewMultipartUploads() *MultipartUploads {
+	return &MultipartUploads{
+		uploads:     make(map[string]*MultipartUpload),
+		minPartSize: DefaultMinPartSize,
+	}
+}
+
+// SetMinPartSize overrides the minimum size of every part except the last, so tests
+// can complete uploads with small payloads.
+func (m *MultipartUploads) SetMinPartSize(size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.minPartSize = size
+}
+
+// Create starts a new multipart upload and returns it.
+func (m *MultipartUploads) Create(bucket, key, contentType string, metadata map[string]string) *MultipartUpload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	var draw int64
+	if m.IDs != nil {
+		draw = m.IDs.Int63()
+	}
+	sum := md5.Sum([]byte(fmt.Sprintf("%s/%s/%d/%d", bucket, key, m.seq, draw)))
+	upload := &MultipartUpload{
+		UploadID:    hex.EncodeToString(sum[:]),
+		Bucket:      bucket,
+		Key:         key,
+		ContentType: contentType,
+		Metadata:    metadata,
+		Initiated:   m.Clock.Now(),
+		Parts:       make(map[int]*Part),
+	}
+	m.uploads[upload.UploadID] = upload
+	return upload
+}
+
+// lookup returns the upload for bucket/key/uploadID. The caller must hold m.mu.
+func (m *MultipartUploads) lookup(bucket, key, uploadID string) (*MultipartUpload, error) {
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.Bucket != bucket || upload.Key != key {
+		return nil, ErrNoSuchUpload
+	}
+	return upload, nil
+}
+
+// UploadPart stores (or replaces) a part of an upload.
+func (m *MultipartUploads) UploadPart(bucket, key, uploadID string, partNumber int, data []byte) (*Part, error) {
+	if partNumber < MinPartNumber || partNumber > MaxPartNumber {
+		return nil, ErrInvalidPartNumber
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.lookup(bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	part := &Part{
+		PartNumber:   partNumber,
+		ETag:         ComputeETag(data),
+		Data:         append([]byte(nil), data...),
+		LastModified: m.Clock.Now(),
+	}
+	upload.Parts[partNumber] = part
+	return part, nil
+}
+
+// Get returns a snapshot of an upload, e.g. for ListParts.
+func (m *MultipartUploads) Get(bucket, key, uploadID string) (*MultipartUpload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.lookup(bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := *upload
+	snapshot.Parts = make(map[int]*Part, len(upload.Parts))
+	for n, p := range upload.Parts {
+		snapshot.Parts[n] = p
+	}
+	return &snapshot, nil
+}
+
+// List returns the in-progress uploads of a bucket whose key starts with prefix,
+// ordered by key and then initiation time.
+func (m *MultipartUploads) List(bucket, prefix string) []*MultipartUpload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	uploads := make([]*MultipartUpload, 0)
+	for _, u := range m.uploads {
+		if u.Bucket == bucket && strings.HasPrefix(u.Key, prefix) {
+			uploads = append(uploads, u)
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		return uploads[i].Initiated.Before(uploads[j].Initiated)
+	})
+	return uploads
+}
+
+// Abort discards an upload and all of its parts.
+func (m *MultipartUploads) Abort(bucket, key, uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.lookup(bucket, key, uploadID); err != nil {
+		return err
+	}
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+// AbortBucket discards every upload of a bucket (used when the bucket is deleted).
+func (m *MultipartUploads) AbortBucket(bucket string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, u := range m.uploads {
+		if u.Bucket == bucket {
+			delete(m.uploads, id)
+		}
+	}
+}
+
+// Complete validates the part list the way S3 does and assembles the object data.
+// On success the upload is removed and the data and multipart ETag are returned.
+func (m *MultipartUploads) Complete(bucket, key, uploadID string, parts []CompletedPart) (*MultipartUpload, []byte, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.lookup(bucket, key, uploadID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if len(parts) == 0 {
+		return nil, nil, "", fmt.Errorf("%w: you must specify at least one part", ErrInvalidPart)
+	}
+
+	var (
+		data    bytes.Buffer
+		digests []byte
+	)
+	for i := 1; i < len(parts); i++ {
+		if parts[i].PartNumber <= parts[i-1].PartNumber {
+			return nil, nil, "", ErrInvalidPartOrder
+		}
+	}
+	for i, cp := range parts {
+		part, ok := upload.Parts[cp.PartNumber]
+		if !ok || strings.Trim(cp.ETag, `"`) != part.ETag {
+			return nil, nil, "", fmt.Errorf("%w: part %d could not be found or its ETag does not match", ErrInvalidPart, cp.PartNumber)
+		}
+		if i < len(parts)-1 && part.Size() < m.minPartSize {
+			return nil, nil, "", fmt.Errorf("%w: part %d is %d bytes", ErrEntityTooSmall, cp.PartNumber, part.Size())
+		}
+		digest, _ := hex.DecodeString(part.ETag)
+		digests = append(digests, digest...)
+		data.Write(part.Data)
+	}
+	delete(m.uploads, uploadID)
+	return upload, data.Bytes(), MultipartETag(digests, len(parts)), nil
+}
+
+// MultipartETag computes the S3 multipart ETag: hex(md5(concatenated part MD5s)) + "-" + part count.
+func MultipartETag(partDigests []byte, partCount int) string {
+	sum := md5.Sum(partDigests)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), partCount)
+}
+
+// Multipart uploads on the BucketStore. Uploads are tracked per provider and the
+// completed object is stored like any other object.
+
+// Uploads returns the multipart upload tracker for a provider.
+func (bs *BucketStore) Uploads(provider string) *MultipartUploads {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.providerUploads(provider)
+}
+
+// providerUploads returns the tracker for a provider. The caller must hold bs.mu.
+func (bs *BucketStore) providerUploads(provider string) *MultipartUploads {
+	if bs.uploads[provider] == nil {
+		bs.uploads[provider] = NewMultipartUploads()
+		bs.uploads[provider].Clock = bs.clock
+		bs.uploads[provider].IDs = bs.ids
+	}
+	return bs.uploads[provider]
+}
+
+// CreateMultipartUpload starts a multipart upload for bucket/key.
+func (bs *BucketStore) CreateMultipartUpload(provider, bucket, key, contentType string, metadata map[string]string) (*MultipartUpload, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return nil, ErrNoSuchBucket
+	}
+	return bs.providerUploads(provider).Create(bucket, key, contentType, metadata), nil
+}
+
+// UploadPart stores one part of a multipart upload.
+func (bs *BucketStore) UploadPart(provider, bucket, key, uploadID string, partNumber int, data []byte) (*Part, error) {
+	bs.mu.Lock()
+	if !bs.bucketExists(provider, bucket) {
+		bs.mu.Unlock()
+		return nil, ErrNoSuchBucket
+	}
+	uploads := bs.providerUploads(provider)
+	bs.mu.Unlock()
+	return uploads.UploadPart(bucket, key, uploadID, partNumber, data)
+}
+
+// UploadPartCopy stores a part copied from (a byte range of) an existing object.
+// start and end are inclusive offsets; end < 0 copies the whole source object.
+func (bs *BucketStore) UploadPartCopy(provider, bucket, key, uploadID string, partNumber int, srcBucket, srcKey string, start, end int64) (*Part, error) {
+	src, err := bs.GetObject(provider, srcBucket, srcKey)
+	if err != nil {
+		return nil, err
+	}
+	data := src.Data
+	if end >= 0 {
+		if start < 0 || start > end || end >= src.Size() {
+			return nil, fmt.Errorf("%w: copy range %d-%d outside source object of %d bytes", ErrInvalidPart, start, end, src.Size())
+		}
+		data = src.Data[start : end+1]
+	}
+	return bs.UploadPart(provider, bucket, key, uploadID, partNumber, data)
+}
+
+// CompleteMultipartUpload assembles the listed parts into the final object.
+func (bs *BucketStore) CompleteMultipartUpload(provider, bucket, key, uploadID string, parts []CompletedPart) (*Object, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return nil, ErrNoSuchBucket
+	}
+	upload, data, etag, err := bs.providerUploads(provider).Complete(bucket, key, uploadID, parts)
+	if err != nil {
+		return nil, err
+	}
+	return bs.storeObject(provider, bucket, key, data, etag, ObjectAttributes{ContentType: upload.ContentType, Metadata: upload.Metadata}), nil
+}
+
+// AbortMultipartUpload discards an in-progress upload.
+func (bs *BucketStore) AbortMultipartUpload(provider, bucket, key, uploadID string) error {
+	bs.mu.Lock()
+	if !bs.bucketExists(provider, bucket) {
+		bs.mu.Unlock()
+		return ErrNoSuchBucket
+	}
+	uploads := bs.providerUploads(provider)
+	bs.mu.Unlock()
+	return uploads.Abort(bucket, key, uploadID)
+}
+
+// ListParts returns the uploaded parts of an upload ordered by part number.
+func (bs *BucketStore) ListParts(provider, bucket, key, uploadID string) (*MultipartUpload, []*Part, error) {
+	bs.mu.Lock()
+	if !bs.bucketExists(provider, bucket) {
+		bs.mu.Unlock()
+		return nil, nil, ErrNoSuchBucket
+	}
+	uploads := bs.providerUploads(provider)
+	bs.mu.Unlock()
+	upload, err := uploads.Get(bucket, key, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return upload, upload.SortedParts(), nil
+}
+
+// ListMultipartUploads returns the in-progress uploads of a bucket.
+func (bs *BucketStore) ListMultipartUploads(provider, bucket, prefix string) ([]*MultipartUpload, error) {
+	bs.mu.Lock()
+	if !bs.bucketExists(provider, bucket) {
+		bs.mu.Unlock()
+		return nil, ErrNoSuchBucket
+	}
+	uploads := bs.providerUploads(provider)
+	bs.mu.Unlock()
+	return uploads.List(bucket, prefix), nil
+}
@@ -0,0 +1,185 @@
+package simulation
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoSuchBucket   = errors.New("bucket not found")
+	ErrNoSuchKey      = errors.New("object not found")
+	ErrBucketNotEmpty = errors.New("bucket not empty")
+)
+
+// Object is a single object version stored in a simulated bucket.
+// Objects are kept in memory only; bucket metadata is persisted as before.
+type Object struct {
+	Key            string            `json:"key"`
+	VersionID      string            `json:"version_id"`
+	IsDeleteMarker bool              `json:"is_delete_marker,omitempty"`
+	Data           []byte            `json:"data"`
+	ETag           string            `json:"etag"`
+	ContentType    string            `json:"content_type,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	StorageClass   string            `json:"storage_class,omitempty"`
+	LastModified   time.Time         `json:"last_modified"`
+}
+
+// ObjectAttributes are the optional attributes stored with an object.
+type ObjectAttributes struct {
+	ContentType  string
+	Metadata     map[string]string
+	Tags         map[string]string
+	StorageClass string
+}
+
+// DefaultStorageClass is the storage class of objects written without one.
+const DefaultStorageClass = "STANDARD"
+
+// Size returns the object size in bytes.
+func (o *Object) Size() int64 {
+	return int64(len(o.Data))
+}
+
+// ComputeETag returns the S3-style ETag (hex MD5, unquoted) for a single-part object.
+func ComputeETag(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// bucketObjects returns the key -> versions map for a bucket, creating it if needed.
+// Versions are ordered oldest first, so the last entry is the current version.
+// The caller must hold bs.mu and must have checked that the bucket exists.
+func (bs *BucketStore) bucketObjects(provider, bucket string) map[string][]*Object {
+	if bs.objects[provider] == nil {
+		bs.objects[provider] = make(map[string]map[string][]*Object)
+	}
+	if bs.objects[provider][bucket] == nil {
+		bs.objects[provider][bucket] = make(map[string][]*Object)
+	}
+	return bs.objects[provider][bucket]
+}
+
+func (bs *BucketStore) bucketExists(provider, bucket string) bool {
+	_, ok := bs.buckets[provider][bucket]
+	return ok
+}
+
+// latestVersion returns the current version of a key, which may be a delete marker.
+// The caller must hold bs.mu.
+func (bs *BucketStore) latestVersion(provider, bucket, key string) *Object {
+	versions := bs.objects[provider][bucket][key]
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[len(versions)-1]
+}
+
+// PutObject stores an object. Depending on the bucket versioning state it replaces
+// the existing object or adds a new version.
+func (bs *BucketStore) PutObject(provider, bucket, key string, data []byte, contentType string, metadata map[string]string) (*Object, error) {
+	return bs.PutObjectWithAttributes(provider, bucket, key, data, ObjectAttributes{ContentType: contentType, Metadata: metadata})
+}
+
+// PutObjectWithAttributes is PutObject with tags and a storage class.
+func (bs *BucketStore) PutObjectWithAttributes(provider, bucket, key string, data []byte, attrs ObjectAttributes) (*Object, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return nil, ErrNoSuchBucket
+	}
+	return bs.storeObject(provider, bucket, key, append([]byte(nil), data...), ComputeETag(data), attrs), nil
+}
+
+// storeObject writes an object with a precomputed ETag. The caller must hold bs.mu.
+func (bs *BucketStore) storeObject(provider, bucket, key string, data []byte, etag string, attrs ObjectAttributes) *Object {
+	storageClass := attrs.StorageClass
+	if storageClass == "" {
+		storageClass = DefaultStorageClass
+	}
+	obj := &Object{
+		Key:          key,
+		Data:         data,
+		ETag:         etag,
+		ContentType:  attrs.ContentType,
+		Metadata:     attrs.Metadata,
+		Tags:         attrs.Tags,
+		StorageClass: storageClass,
+		LastModified: bs.clock.Now(),
+	}
+	bs.addVersion(provider, bucket, obj)
+	return obj
+}
+
+// GetObject returns a copy of the current version of key. A key whose current version
+// is a delete marker does not exist.
+func (bs *BucketStore) GetObject(provider, bucket, key string) (*Object, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return nil, ErrNoSuchBucket
+	}
+	obj := bs.latestVersion(provider, bucket, key)
+	if obj == nil || obj.IsDeleteMarker {
+		return nil, ErrNoSuchKey
+	}
+	copied := *obj
+	return &copied, nil
+}
+
+// DeleteObject deletes key the way S3 does without a version ID: unversioned buckets
+// drop the object, versioned buckets get a delete marker, which is returned.
+// Like S3, deleting a missing key is not an error.
+func (bs *BucketStore) DeleteObject(provider, bucket, key string) (*Object, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return nil, ErrNoSuchBucket
+	}
+	if bs.versioning(provider, bucket) == VersioningUnversioned {
+		delete(bs.bucketObjects(provider, bucket), key)
+		return nil, nil
+	}
+	marker := &Object{Key: key, IsDeleteMarker: true, LastModified: bs.clock.Now()}
+	bs.addVersion(provider, bucket, marker)
+	return marker, nil
+}
+
+// ListObjects returns the current version of every key starting with prefix, sorted
+// by key. Keys whose current version is a delete marker are skipped.
+func (bs *BucketStore) ListObjects(provider, bucket, prefix string) ([]*Object, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return nil, ErrNoSuchBucket
+	}
+	objects := make([]*Object, 0)
+	for key := range bs.bucketObjects(provider, bucket) {
+		if obj := bs.latestVersion(provider, bucket, key); obj != nil && !obj.IsDeleteMarker && strings.HasPrefix(key, prefix) {
+			objects = append(objects, obj)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// DeleteIfEmpty deletes a bucket only if it holds no objects, as S3 DeleteBucket does.
+// Noncurrent versions and delete markers count as content.
+func (bs *BucketStore) DeleteIfEmpty(provider, bucket string) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return ErrNoSuchBucket
+	}
+	if len(bs.objects[provider][bucket]) > 0 {
+		return ErrBucketNotEmpty
+	}
+	// Still locked, so no object can be put between the check and the removal
+	bs.deleteLocked(provider, bucket)
+	return nil
+}
@@ -0,0 +1,577 @@
+package simulation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// Bucket policy evaluation. Policies are stored with the bucket metadata and checked
+// for every simulated S3 request: an explicit Deny wins over any Allow, and a request
+// that no statement allows is implicitly denied. The simulator has no IAM layer, so
+// once a bucket has a policy it is the only source of permissions for that bucket.
+
+const (
+	PolicyEffectAllow    = "Allow"
+	PolicyEffectDeny     = "Deny"
+	DefaultPolicyVersion = "2012-10-17"
+
+	s3ARNPrefix = "arn:aws:s3:::"
+)
+
+// Policy decisions reported by EvaluatePolicy
+const (
+	PolicyDecisionAllow        = "Allow"
+	PolicyDecisionExplicitDeny = "ExplicitDeny"
+	PolicyDecisionImplicitDeny = "ImplicitDeny"
+	PolicyDecisionNoPolicy     = "NoPolicy"
+)
+
+var (
+	ErrNoSuchBucketPolicy = errors.New("the bucket policy does not exist")
+	ErrMalformedPolicy    = errors.New("malformed bucket policy")
+)
+
+// policyConditionOperators are the condition operators the simulator evaluates. Each
+// one can also be used with the IfExists suffix.
+var policyConditionOperators = map[string]bool{
+	"StringEquals":              true,
+	"StringNotEquals":           true,
+	"StringEqualsIgnoreCase":    true,
+	"StringNotEqualsIgnoreCase": true,
+	"StringLike":                true,
+	"StringNotLike":             true,
+	"Bool":                      true,
+	"IpAddress":                 true,
+	"NotIpAddress":              true,
+}
+
+// PolicyRequest describes a request to authorize against a bucket policy.
+type PolicyRequest struct {
+	Action          string            `json:"action"` // e.g. "s3:GetObject"
+	Bucket          string            `json:"bucket"`
+	Key             string            `json:"key,omitempty"`       // empty for bucket-level actions
+	Principal       string            `json:"principal,omitempty"` // access key ID or ARN; empty is anonymous
+	SourceIP        string            `json:"source_ip,omitempty"`
+	SecureTransport bool              `json:"secure_transport"`
+	Context         map[string]string `json:"context,omitempty"` // further condition keys, e.g. "s3:prefix"
+}
+
+// Resource returns the ARN the request acts on.
+func (r PolicyRequest) Resource() string {
+	if r.Key == "" {
+		return s3ARNPrefix + r.Bucket
+	}
+	return s3ARNPrefix + r.Bucket + "/" + r.Key
+}
+
+// conditionValue returns the value of a condition key for the request.
+func (r PolicyRequest) conditionValue(key string) (string, bool) {
+	switch strings.ToLower(key) {
+	case "aws:sourceip":
+		return r.SourceIP, r.SourceIP != ""
+	case "aws:securetransport":
+		return strconv.FormatBool(r.SecureTransport), true
+	case "aws:username":
+		return r.Principal, r.Principal != ""
+	}
+	for k, v := range r.Context {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// StatementEvaluation records whether one statement matched a request and why not.
+type StatementEvaluation struct {
+	Index   int    `json:"index"`
+	Sid     string `json:"sid,omitempty"`
+	Effect  string `json:"effect"`
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// PolicyDecision is the outcome of evaluating a request against a policy.
+type PolicyDecision struct {
+	Allowed    bool                  `json:"allowed"`
+	Decision   string                `json:"decision"`
+	Statement  int                   `json:"statement"` // index of the deciding statement, -1 if none
+	Sid        string                `json:"sid,omitempty"`
+	Action     string                `json:"action"`
+	Resource   string                `json:"resource"`
+	Reason     string                `json:"reason"`
+	Statements []StatementEvaluation `json:"statements,omitempty"`
+}
+
+// EvaluatePolicy decides whether policy allows req. Like a bucket without a policy, a
+// nil or empty policy allows everything.
+func EvaluatePolicy(policy *models.ObjectStoragePolicy, req PolicyRequest) PolicyDecision {
+	d := PolicyDecision{Statement: -1, Action: req.Action, Resource: req.Resource()}
+	if policy == nil || len(policy.Statement) == 0 {
+		d.Allowed, d.Decision, d.Reason = true, PolicyDecisionNoPolicy, "the bucket has no policy"
+		return d
+	}
+	allow, deny := -1, -1
+	for i, st := range policy.Statement {
+		reason := statementMismatch(st, req)
+		d.Statements = append(d.Statements, StatementEvaluation{Index: i, Sid: st.Sid, Effect: st.Effect, Matched: reason == "", Reason: reason})
+		if reason != "" {
+			continue
+		}
+		if st.Effect == PolicyEffectDeny && deny < 0 {
+			deny = i
+		} else if st.Effect == PolicyEffectAllow && allow < 0 {
+			allow = i
+		}
+	}
+	switch {
+	case deny >= 0:
+		d.Decision, d.Statement = PolicyDecisionExplicitDeny, deny
+		d.Reason = "explicitly denied by " + statementName(policy, deny)
+	case allow >= 0:
+		d.Allowed, d.Decision, d.Statement = true, PolicyDecisionAllow, allow
+		d.Reason = "allowed by " + statementName(policy, allow)
+	default:
+		d.Decision = PolicyDecisionImplicitDeny
+		d.Reason = fmt.Sprintf("no statement allows %s on %s", req.Action, d.Resource)
+	}
+	if d.Statement >= 0 {
+		d.Sid = policy.Statement[d.Statement].Sid
+	}
+	return d
+}
+
+func statementName(policy *models.ObjectStoragePolicy, i int) string {
+	if sid := policy.Statement[i].Sid; sid != "" {
+		return fmt.Sprintf("statement %d (%s)", i, sid)
+	}
+	return fmt.Sprintf("statement %d", i)
+}
+
+// statementMismatch returns why st does not apply to req, or "" if it does.
+func statementMismatch(st models.ObjectStorageStatement, req PolicyRequest) string {
+	if !anyPatternMatches(st.Action, req.Action, true) {
+		return "action does not match"
+	}
+	if !anyPatternMatches(st.Resource, req.Resource(), false) {
+		return "resource does not match"
+	}
+	if !principalMatches(st.Principal, req.Principal) {
+		return "principal does not match"
+	}
+	for _, op := range sortedKeys(st.Condition) {
+		block := conditionBlock(st.Condition[op])
+		for _, key := range sortedKeys(block) {
+			actual, present := req.conditionValue(key)
+			if !conditionHolds(op, actual, present, stringList(block[key])) {
+				return fmt.Sprintf("condition %s %s is not satisfied", op, key)
+			}
+		}
+	}
+	return ""
+}
+
+func anyPatternMatches(patterns []string, value string, foldCase bool) bool {
+	for _, p := range patterns {
+		if foldCase && wildcardMatch(strings.ToLower(p), strings.ToLower(value)) {
+			return true
+		}
+		if !foldCase && wildcardMatch(p, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// principalMatches reports whether a statement principal covers the requester. "*"
+// matches everyone, including anonymous requests; other values match the access key
+// ID directly or an IAM user ARN ending in "user/<access key>".
+func principalMatches(principal map[string]interface{}, requester string) bool {
+	if len(principal) == 0 {
+		return true
+	}
+	for _, kind := range sortedKeys(principal) {
+		for _, p := range stringList(principal[kind]) {
+			switch {
+			case p == "*":
+				return true
+			case requester == "":
+			case wildcardMatch(p, requester), strings.HasSuffix(p, ":user/"+requester):
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func conditionHolds(op, actual string, present bool, values []string) bool {
+	op, ifExists := strings.CutSuffix(op, "IfExists")
+	if ifExists && !present {
+		return true
+	}
+	matchAny := func(match func(v string) bool) bool {
+		for _, v := range values {
+			if match(v) {
+				return true
+			}
+		}
+		return false
+	}
+	switch op {
+	case "StringEquals":
+		return present && matchAny(func(v string) bool { return v == actual })
+	case "StringNotEquals":
+		return !present || !matchAny(func(v string) bool { return v == actual })
+	case "StringEqualsIgnoreCase":
+		return present && matchAny(func(v string) bool { return strings.EqualFold(v, actual) })
+	case "StringNotEqualsIgnoreCase":
+		return !present || !matchAny(func(v string) bool { return strings.EqualFold(v, actual) })
+	case "StringLike":
+		return present && matchAny(func(v string) bool { return wildcardMatch(v, actual) })
+	case "StringNotLike":
+		return !present || !matchAny(func(v string) bool { return wildcardMatch(v, actual) })
+	case "Bool":
+		return present && matchAny(func(v string) bool { return strings.EqualFold(v, actual) })
+	case "IpAddress":
+		return present && matchAny(func(v string) bool { return ipMatches(v, actual) })
+	case "NotIpAddress":
+		return !present || !matchAny(func(v string) bool { return ipMatches(v, actual) })
+	}
+	return false
+}
+
+// ipMatches reports whether ip lies within cidr, which may also be a single address.
+func ipMatches(cidr, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	if !strings.Contains(cidr, "/") {
+		return addr.Equal(net.ParseIP(cidr))
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	return err == nil && network.Contains(addr)
+}
+
+// wildcardMatch matches s against a pattern where * matches any run of characters
+// and ? matches exactly one.
+func wildcardMatch(pattern, s string) bool {
+	p, i := 0, 0
+	star, mark := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case star >= 0:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// stringList flattens the single-value-or-list form policy documents use.
+func stringList(v interface{}) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func conditionBlock(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v
+	case map[string]string:
+		block := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			block[k] = s
+		}
+		return block
+	case map[string][]string:
+		block := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			block[k] = s
+		}
+		return block
+	}
+	return nil
+}
+
+// ValidatePolicy checks that a policy only uses what the simulator can evaluate.
+func ValidatePolicy(policy *models.ObjectStoragePolicy) error {
+	if policy == nil {
+		return fmt.Errorf("%w: policy is required", ErrMalformedPolicy)
+	}
+	for i, st := range policy.Statement {
+		invalid := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: statement %d: %s", ErrMalformedPolicy, i, fmt.Sprintf(format, args...))
+		}
+		if st.Effect != PolicyEffectAllow && st.Effect != PolicyEffectDeny {
+			return invalid("effect must be %s or %s", PolicyEffectAllow, PolicyEffectDeny)
+		}
+		if len(st.Principal) == 0 {
+			return invalid("a principal is required")
+		}
+		if len(st.Action) == 0 {
+			return invalid("at least one action is required")
+		}
+		for _, action := range st.Action {
+			if action != "*" && !strings.HasPrefix(strings.ToLower(action), "s3:") {
+				return invalid("action %q is not an s3 action", action)
+			}
+		}
+		if len(st.Resource) == 0 {
+			return invalid("at least one resource is required")
+		}
+		for _, resource := range st.Resource {
+			if resource != "*" && !strings.HasPrefix(resource, s3ARNPrefix) {
+				return invalid("resource %q is not an s3 ARN", resource)
+			}
+		}
+		for op, block := range st.Condition {
+			base, _ := strings.CutSuffix(op, "IfExists")
+			if !policyConditionOperators[base] {
+				return invalid("condition operator %q is not supported", op)
+			}
+			if len(conditionBlock(block)) == 0 {
+				return invalid("condition %q must map keys to values", op)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseBucketPolicy converts a policy given as a JSON document (string or bytes), a
+// decoded JSON value or a model into a models.ObjectStoragePolicy. Field names are
+// matched case-insensitively, so both AWS documents ("Statement", "Effect", ...) and
+// the model's own JSON form are accepted, as are single values in place of lists.
+func ParseBucketPolicy(v interface{}) (*models.ObjectStoragePolicy, error) {
+	switch p := v.(type) {
+	case *models.ObjectStoragePolicy:
+		return p, nil
+	case models.ObjectStoragePolicy:
+		return &p, nil
+	case string:
+		return ParseBucketPolicy([]byte(p))
+	case []byte:
+		var doc interface{}
+		if err := json.Unmarshal(p, &doc); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedPolicy, err)
+		}
+		return ParseBucketPolicy(doc)
+	case map[string]interface{}:
+		return policyFromDocument(p)
+	case nil:
+		return nil, fmt.Errorf("%w: policy is required", ErrMalformedPolicy)
+	}
+	return nil, fmt.Errorf("%w: unsupported policy type %T", ErrMalformedPolicy, v)
+}
+
+func policyFromDocument(doc map[string]interface{}) (*models.ObjectStoragePolicy, error) {
+	policy := &models.ObjectStoragePolicy{Version: DefaultPolicyVersion}
+	if version, ok := policyField(doc, "Version").(string); ok {
+		policy.Version = version
+	}
+	var statements []interface{}
+	switch s := policyField(doc, "Statement").(type) {
+	case []interface{}:
+		statements = s
+	case map[string]interface{}:
+		statements = []interface{}{s}
+	default:
+		return nil, fmt.Errorf("%w: Statement must be an object or a list of objects", ErrMalformedPolicy)
+	}
+	for i, raw := range statements {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: statement %d is not an object", ErrMalformedPolicy, i)
+		}
+		for _, unsupported := range []string{"NotAction", "NotResource", "NotPrincipal"} {
+			if policyField(m, unsupported) != nil {
+				return nil, fmt.Errorf("%w: statement %d: %s is not supported by the simulator", ErrMalformedPolicy, i, unsupported)
+			}
+		}
+		st := models.ObjectStorageStatement{
+			Action:   stringList(policyField(m, "Action")),
+			Resource: stringList(policyField(m, "Resource")),
+		}
+		st.Sid, _ = policyField(m, "Sid").(string)
+		st.Effect, _ = policyField(m, "Effect").(string)
+		switch principal := policyField(m, "Principal").(type) {
+		case string:
+			st.Principal = map[string]interface{}{"AWS": []string{principal}}
+		case map[string]interface{}:
+			st.Principal = make(map[string]interface{}, len(principal))
+			for kind, values := range principal {
+				st.Principal[kind] = stringList(values)
+			}
+		}
+		if cond, ok := policyField(m, "Condition").(map[string]interface{}); ok {
+			st.Condition = make(map[string]interface{}, len(cond))
+			for op, block := range cond {
+				keys := map[string]interface{}{}
+				for key, values := range conditionBlock(block) {
+					keys[key] = stringList(values)
+				}
+				st.Condition[op] = keys
+			}
+		}
+		policy.Statement = append(policy.Statement, st)
+	}
+	return policy, nil
+}
+
+func policyField(m map[string]interface{}, name string) interface{} {
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// bucketPolicy returns the policy stored in the bucket info, decoding it from its
+// generic JSON form after a reload from the persist file.
+func bucketPolicy(info map[string]interface{}) *models.ObjectStoragePolicy {
+	switch v := info["policy"].(type) {
+	case nil:
+		return nil
+	case *models.ObjectStoragePolicy:
+		return v
+	default:
+		raw, _ := json.Marshal(v)
+		var policy models.ObjectStoragePolicy
+		if json.Unmarshal(raw, &policy) != nil || len(policy.Statement) == 0 {
+			return nil
+		}
+		info["policy"] = &policy
+		return &policy
+	}
+}
+
+// Policy returns the policy of a bucket.
+func (bs *BucketStore) Policy(provider, bucket string) (*models.ObjectStoragePolicy, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	info, ok := bs.buckets[provider][bucket].(map[string]interface{})
+	if !ok {
+		return nil, ErrNoSuchBucket
+	}
+	policy := bucketPolicy(info)
+	if policy == nil {
+		return nil, ErrNoSuchBucketPolicy
+	}
+	return policy, nil
+}
+
+// SetPolicy validates and stores the policy of a bucket, replacing any existing one.
+// Every resource must refer to the bucket itself or objects in it. A policy without
+// statements neither allows nor denies anything and is stored as no policy.
+func (bs *BucketStore) SetPolicy(provider, bucket string, policy *models.ObjectStoragePolicy) error {
+	if err := ValidatePolicy(policy); err != nil {
+		return err
+	}
+	for i, st := range policy.Statement {
+		for _, resource := range st.Resource {
+			name, _, _ := strings.Cut(strings.TrimPrefix(resource, s3ARNPrefix), "/")
+			if resource != "*" && !wildcardMatch(name, bucket) {
+				return fmt.Errorf("%w: statement %d: policy has invalid resource %q", ErrMalformedPolicy, i, resource)
+			}
+		}
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	info, ok := bs.buckets[provider][bucket].(map[string]interface{})
+	if !ok {
+		return ErrNoSuchBucket
+	}
+	if len(policy.Statement) == 0 {
+		delete(info, "policy")
+	} else {
+		info["policy"] = policy
+	}
+	bs.Save()
+	return nil
+}
+
+// DeletePolicy removes the policy of a bucket.
+func (bs *BucketStore) DeletePolicy(provider, bucket string) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	info, ok := bs.buckets[provider][bucket].(map[string]interface{})
+	if !ok {
+		return ErrNoSuchBucket
+	}
+	delete(info, "policy")
+	bs.Save()
+	return nil
+}
+
+// AuthorizeRequest evaluates req against the stored policy of req.Bucket.
+func (bs *BucketStore) AuthorizeRequest(provider string, req PolicyRequest) (PolicyDecision, error) {
+	bs.mu.Lock()
+	info, ok := bs.buckets[provider][req.Bucket].(map[string]interface{})
+	var policy *models.ObjectStoragePolicy
+	if ok {
+		policy = bucketPolicy(info)
+	}
+	bs.mu.Unlock()
+	if !ok {
+		return PolicyDecision{}, ErrNoSuchBucket
+	}
+	return EvaluatePolicy(policy, req), nil
+}
+
+// ExplainPolicy evaluates the request described by params without performing it. The
+// policy comes from params["policy"] when given, so a policy can be tried out before
+// it is applied; otherwise the bucket's stored policy is used.
+func (s *SimulationService) ExplainPolicy(provider string, params map[string]interface{}) (PolicyDecision, error) {
+	var req PolicyRequest
+	raw, _ := json.Marshal(params)
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return PolicyDecision{}, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Action == "" || req.Bucket == "" {
+		return PolicyDecision{}, errors.New("action and bucket are required")
+	}
+	if doc, ok := params["policy"]; ok && doc != nil {
+		policy, err := ParseBucketPolicy(doc)
+		if err == nil {
+			err = ValidatePolicy(policy)
+		}
+		if err != nil {
+			return PolicyDecision{}, err
+		}
+		return EvaluatePolicy(policy, req), nil
+	}
+	return s.buckets.AuthorizeRequest(provider, req)
+}
@@ -0,0 +1,160 @@
+package simulation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"*", "anything/at/all", true},
+		{"?", "", false},
+		{"?", "a", true},
+		{"?", "ab", false},
+		{"s3:Get*", "s3:GetObject", true},
+		{"s3:Get*", "s3:PutObject", false},
+		{"s3:*Object", "s3:GetObject", true},
+		{"s3:*Object", "s3:GetObjectVersion", false},
+		{"s3:Get?bject", "s3:GetObject", true},
+		// The first * must give characters back to the rest of the pattern
+		{"*a*b", "xaxaxb", true},
+		{"*ab", "aab", true},
+		{"a*b*c", "abbbcbc", true},
+		{"a*b*c", "abbbcbd", false},
+		{"*?", "a", true},
+		{"*?*?", "a", false},
+		{"arn:aws:s3:::bucket/*/*.csv", "arn:aws:s3:::bucket/reports/q3.csv", true},
+		{"arn:aws:s3:::bucket/*/*.csv", "arn:aws:s3:::bucket/q3.csv", false},
+		{"**", "", true},
+		{"a**", "abc", true},
+	}
+	for _, tt := range tests {
+		if got := wildcardMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("wildcardMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestConditionHolds(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      string
+		actual  string
+		present bool
+		values  []string
+		want    bool
+	}{
+		{"StringEquals", "StringEquals", "public/", true, []string{"private/", "public/"}, true},
+		{"StringEquals with a missing key", "StringEquals", "", false, []string{""}, false},
+		{"StringEqualsIfExists with a missing key", "StringEqualsIfExists", "", false, []string{"public/"}, true},
+		{"StringEqualsIfExists with a present key", "StringEqualsIfExists", "private/", true, []string{"public/"}, false},
+		{"StringNotEquals with a missing key", "StringNotEquals", "", false, []string{"public/"}, true},
+		{"StringEqualsIgnoreCase", "StringEqualsIgnoreCase", "STANDARD", true, []string{"standard"}, true},
+		{"StringLike", "StringLike", "curl/8.0", true, []string{"curl/*"}, true},
+		{"StringNotLike", "StringNotLike", "curl/8.0", true, []string{"curl/*"}, false},
+		{"Bool", "Bool", "true", true, []string{"True"}, true},
+		{"Bool with a missing key", "Bool", "", false, []string{"false"}, false},
+		{"BoolIfExists with a missing key", "BoolIfExists", "", false, []string{"false"}, true},
+		{"IpAddress in range", "IpAddress", "10.1.2.3", true, []string{"10.0.0.0/8"}, true},
+		{"IpAddress single address", "IpAddress", "192.0.2.1", true, []string{"192.0.2.1"}, true},
+		{"IpAddress out of range", "IpAddress", "192.0.2.1", true, []string{"10.0.0.0/8"}, false},
+		{"IpAddress with a missing key", "IpAddress", "", false, []string{"10.0.0.0/8"}, false},
+		{"NotIpAddress in range", "NotIpAddress", "10.1.2.3", true, []string{"10.0.0.0/8"}, false},
+		{"NotIpAddress out of range", "NotIpAddress", "192.0.2.1", true, []string{"10.0.0.0/8"}, true},
+		{"NotIpAddress with a missing key", "NotIpAddress", "", false, []string{"10.0.0.0/8"}, true},
+		{"NotIpAddressIfExists with a missing key", "NotIpAddressIfExists", "", false, []string{"10.0.0.0/8"}, true},
+		{"unknown operator", "DateGreaterThan", "2026-01-01T00:00:00Z", true, []string{"2020-01-01T00:00:00Z"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conditionHolds(tt.op, tt.actual, tt.present, tt.values); got != tt.want {
+				t.Errorf("conditionHolds(%q, %q, %v, %v) = %v, want %v", tt.op, tt.actual, tt.present, tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrincipalMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal map[string]interface{}
+		requester string
+		want      bool
+	}{
+		{"no principal", nil, "", true},
+		{"everyone", map[string]interface{}{"AWS": "*"}, "", true},
+		{"access key", map[string]interface{}{"AWS": "AKIDREADER"}, "AKIDREADER", true},
+		{"other access key", map[string]interface{}{"AWS": "AKIDREADER"}, "AKIDWRITER", false},
+		{"anonymous against a key", map[string]interface{}{"AWS": "AKIDREADER"}, "", false},
+		{"anonymous against a wildcard key", map[string]interface{}{"AWS": "AKID*"}, "", false},
+		{"wildcard key", map[string]interface{}{"AWS": []interface{}{"AKIDANALYST*"}}, "AKIDANALYST01", true},
+		{"user ARN", map[string]interface{}{"AWS": "arn:aws:iam::123456789012:user/AKIDREADER"}, "AKIDREADER", true},
+		{"user ARN of a longer key", map[string]interface{}{"AWS": "arn:aws:iam::123456789012:user/AKIDREADER"}, "READER", false},
+		{"one of a list", map[string]interface{}{"AWS": []string{"AKIDA", "AKIDB"}}, "AKIDB", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := principalMatches(tt.principal, tt.requester); got != tt.want {
+				t.Errorf("principalMatches(%v, %q) = %v, want %v", tt.principal, tt.requester, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	valid := func() models.ObjectStorageStatement {
+		return models.ObjectStorageStatement{
+			Effect:    PolicyEffectAllow,
+			Principal: map[string]interface{}{"AWS": "*"},
+			Action:    []string{"s3:GetObject"},
+			Resource:  []string{"arn:aws:s3:::reports/*"},
+		}
+	}
+	tests := []struct {
+		name    string
+		change  func(st *models.ObjectStorageStatement)
+		wantErr bool
+	}{
+		{"valid", func(st *models.ObjectStorageStatement) {}, false},
+		{"any action and resource", func(st *models.ObjectStorageStatement) { st.Action, st.Resource = []string{"*"}, []string{"*"} }, false},
+		{"IfExists condition", func(st *models.ObjectStorageStatement) {
+			st.Condition = map[string]interface{}{"NotIpAddressIfExists": map[string]interface{}{"aws:SourceIp": "10.0.0.0/8"}}
+		}, false},
+		{"unknown effect", func(st *models.ObjectStorageStatement) { st.Effect = "Maybe" }, true},
+		{"no principal", func(st *models.ObjectStorageStatement) { st.Principal = nil }, true},
+		{"no action", func(st *models.ObjectStorageStatement) { st.Action = nil }, true},
+		{"not an s3 action", func(st *models.ObjectStorageStatement) { st.Action = []string{"ec2:RunInstances"} }, true},
+		{"no resource", func(st *models.ObjectStorageStatement) { st.Resource = nil }, true},
+		{"not an s3 resource", func(st *models.ObjectStorageStatement) { st.Resource = []string{"arn:aws:sqs:::queue"} }, true},
+		{"unsupported operator", func(st *models.ObjectStorageStatement) {
+			st.Condition = map[string]interface{}{"DateGreaterThan": map[string]interface{}{"aws:CurrentTime": "2020-01-01T00:00:00Z"}}
+		}, true},
+		{"empty condition block", func(st *models.ObjectStorageStatement) {
+			st.Condition = map[string]interface{}{"StringEquals": map[string]interface{}{}}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := valid()
+			tt.change(&st)
+			err := ValidatePolicy(&models.ObjectStoragePolicy{Statement: []models.ObjectStorageStatement{st}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePolicy() = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrMalformedPolicy) {
+				t.Errorf("ValidatePolicy() = %v, want ErrMalformedPolicy", err)
+			}
+		})
+	}
+	if err := ValidatePolicy(nil); !errors.Is(err, ErrMalformedPolicy) {
+		t.Errorf("ValidatePolicy(nil) = %v, want ErrMalformedPolicy", err)
+	}
+}
@@ -0,0 +1,88 @@
+package simulation
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// Projects. Every cube-server project simulates its own buckets, objects, multipart
+// uploads and clusters, persisted in files next to the default project's. The clock,
+// fault profiles, random source, cassette and operation counts are server-wide.
+
+func (s *SimulationService) rootService() *SimulationService {
+	if s.root != nil {
+		return s.root
+	}
+	return s
+}
+
+// Project returns the simulation of a project, loading its persisted state on first
+// use. The default project ("" or models.DefaultProject) is the root service itself.
+func (s *SimulationService) Project(name string) *SimulationService {
+	root := s.rootService()
+	if name == "" || name == models.DefaultProject {
+		return root
+	}
+	root.projectsMu.Lock()
+	defer root.projectsMu.Unlock()
+	if p, ok := root.projects[name]; ok {
+		return p
+	}
+	persistPath := projectPersistPath(root.persistPath, name)
+	p := &SimulationService{
+		source:       root.source,
+		rand:         root.rand,
+		faults:       root.faults,
+		operations:   root.operations,
+		persistPath:  persistPath,
+		fastSimulate: root.fastSimulate,
+		debug:        root.debug,
+		root:         root,
+	}
+	p.buckets = newBucketStore(persistPath, root.Clock(), root.rand)
+	p.clusters = NewClusterRegistry(clusterPersistPath(persistPath), root.Clock(), root.fastSimulate)
+	p.buckets.notify = root.notifier(name)
+	p.clusters.notify = p.buckets.notify
+	root.projects[name] = p
+	return p
+}
+
+// Projects returns the default project and the projects used since the service
+// started, by name.
+func (s *SimulationService) Projects() []string {
+	root := s.rootService()
+	root.projectsMu.Lock()
+	defer root.projectsMu.Unlock()
+	names := make([]string, 0, len(root.projects)+1)
+	for name := range root.projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{models.DefaultProject}, names...)
+}
+
+// DeleteProject discards the simulation state of a project and its persisted files.
+// The default project cannot be deleted.
+func (s *SimulationService) DeleteProject(name string) {
+	if name == "" || name == models.DefaultProject {
+		return
+	}
+	root := s.rootService()
+	root.projectsMu.Lock()
+	delete(root.projects, name)
+	root.projectsMu.Unlock()
+	persistPath := projectPersistPath(root.persistPath, name)
+	_ = os.Remove(persistPath)
+	_ = os.Remove(clusterPersistPath(persistPath))
+}
+
+// projectPersistPath is the bucket file of a project: the default project's file with
+// the project name before the extension.
+func projectPersistPath(bucketPath, project string) string {
+	ext := filepath.Ext(bucketPath)
+	return strings.TrimSuffix(bucketPath, ext) + ".project-" + project + ext
+}
@@ -0,0 +1,88 @@
+package simulation
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Seeding. The service draws IDs, test outcomes and metrics from one shared random
+// source; SetSeed makes that sequence repeatable. A request can also carry its own
+// seed (WithSeed), in which case it gets a fresh source and the same request with the
+// same seed always simulates the same answer, regardless of what ran before. The
+// bucket stores draw their multipart upload and object version IDs from the shared
+// source as well.
+
+type seedKey struct{}
+
+// WithSeed returns a context whose simulated operations use their own source seeded
+// with seed
+func WithSeed(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, seedKey{}, seed)
+}
+
+// SeedFromContext returns the seed set with WithSeed
+func SeedFromContext(ctx context.Context) (int64, bool) {
+	seed, ok := ctx.Value(seedKey{}).(int64)
+	return seed, ok
+}
+
+// lockedSource is a rand.Source safe for concurrent use, so the shared *rand.Rand
+// can serve parallel requests
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func newLockedSource(seed int64) *lockedSource {
+	return &lockedSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (l *lockedSource) Int63() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.src.Int63()
+}
+
+func (l *lockedSource) Uint64() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.src.Uint64()
+}
+
+func (l *lockedSource) Seed(seed int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.src.Seed(seed)
+}
+
+// SetSeed restarts the shared random source, and the fault injector's, from seed
+func (s *SimulationService) SetSeed(seed int64) {
+	s.source.Seed(seed)
+	s.faults.Seed(seed)
+}
+
+// randFor returns the random source for an operation run with ctx
+func (s *SimulationService) randFor(ctx context.Context) *rand.Rand {
+	if seed, ok := SeedFromContext(ctx); ok {
+		return rand.New(rand.NewSource(seed))
+	}
+	return s.rand
+}
+
+func newServiceRand(seed int64) (*lockedSource, *rand.Rand) {
+	source := newLockedSource(seed)
+	return source, rand.New(source)
+}
+
+// initialSeed is the seed of a new service: CUBE_SERVER_SIM_SEED when it is set, so
+// even the draws before ConfigureSimulation repeat, the wall clock otherwise
+func initialSeed() int64 {
+	if seed, err := strconv.ParseInt(os.Getenv("CUBE_SERVER_SIM_SEED"), 10, 64); err == nil {
+		return seed
+	}
+	return time.Now().UnixNano()
+}
@@ -1,62 +1,108 @@
-
 package simulation
 
 import (
-	   "fmt"
-	   "math/rand"
-	   "os"
-	   "time"
-	   "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
 )
 
 // BucketStore returns the bucket store for direct manipulation (e.g., for dummy/test buckets)
 func (s *SimulationService) BucketStore() *BucketStore {
-	   return s.buckets
+	return s.buckets
+}
+
+// Clock returns the simulation clock shared by all simulated resources
+func (s *SimulationService) Clock() *Clock {
+	return s.buckets.Clock()
+}
+
+// Clusters returns the registry of simulated clusters
+func (s *SimulationService) Clusters() *ClusterRegistry {
+	return s.clusters
+}
+
+// Faults returns the fault injector applied to every simulated operation
+func (s *SimulationService) Faults() *FaultInjector {
+	return s.faults
 }
 
 // SimulationService provides cloud provider simulation capabilities
 type SimulationService struct {
-	   rand         *rand.Rand
-	   buckets      *BucketStore
-	   persistPath  string
-	   fastSimulate bool
-	   debug        bool
+	source       *lockedSource
+	rand         *rand.Rand
+	cassette     *Cassette
+	buckets      *BucketStore
+	clusters     *ClusterRegistry
+	faults       *FaultInjector
+	operations   *operationCounter
+	persistPath  string
+	fastSimulate bool
+	debug        bool
+
+	// root is the service of the default project, shared by the project services
+	// (see project.go); it is nil for the root itself
+	root       *SimulationService
+	projectsMu sync.Mutex
+	projects   map[string]*SimulationService
+
+	// observer hears of the changes in every project (see changes.go)
+	observerMu sync.RWMutex
+	observer   Observer
 }
 
 // NewSimulationService creates a new simulation service
 func NewSimulationService() *SimulationService {
-		   persistPath := os.Getenv("CUBE_SERVER_SIM_PERSIST")
-		   if persistPath == "" {
-				   // Use /tmp for relative path safety - testdata/... is only valid from workspace root
-				   persistPath = "/tmp/cube_server_sim_buckets.json"
-		   }
-	   s := &SimulationService{
-			   rand: rand.New(rand.NewSource(time.Now().UnixNano())),
-			   persistPath: persistPath,
-			   fastSimulate: os.Getenv("FAST_SIMULATE") == "1",
-			   debug: os.Getenv("CUBE_SERVER_DEBUG") == "1",
-	   }
-	   s.buckets = NewBucketStore(persistPath)
-	   return s
+	persistPath := os.Getenv("CUBE_SERVER_SIM_PERSIST")
+	if persistPath == "" {
+		// Use /tmp for relative path safety - testdata/... is only valid from workspace root
+		persistPath = "/tmp/cube_server_sim_buckets.json"
+	}
+	s := &SimulationService{
+		persistPath:  persistPath,
+		fastSimulate: os.Getenv("FAST_SIMULATE") == "1",
+		debug:        os.Getenv("CUBE_SERVER_DEBUG") == "1",
+		operations:   &operationCounter{},
+		projects:     make(map[string]*SimulationService),
+	}
+	seed := initialSeed()
+	s.faults = NewFaultInjector(seed)
+	s.source, s.rand = newServiceRand(seed)
+	s.buckets = newBucketStore(persistPath, NewClock(), s.rand)
+	s.clusters = NewClusterRegistry(clusterPersistPath(persistPath), s.buckets.Clock(), s.fastSimulate)
+	s.buckets.notify = s.notifier(models.DefaultProject)
+	s.clusters.notify = s.buckets.notify
+	return s
 }
 
 // NewSimulationServiceWithOptions allows explicit config
 func NewSimulationServiceWithOptions(fastSimulate, debug bool) *SimulationService {
-	   persistPath := os.Getenv("CUBE_SERVER_SIM_PERSIST")
-	   if persistPath == "" {
-			   persistPath = "/tmp/cube_server_sim_buckets.json"
-	   }
-	   s := &SimulationService{
-			   rand: rand.New(rand.NewSource(time.Now().UnixNano())),
-			   persistPath: persistPath,
-			   fastSimulate: fastSimulate,
-			   debug: debug,
-	   }
-	   s.buckets = NewBucketStore(persistPath)
-	   return s
+	persistPath := os.Getenv("CUBE_SERVER_SIM_PERSIST")
+	if persistPath == "" {
+		persistPath = "/tmp/cube_server_sim_buckets.json"
+	}
+	s := &SimulationService{
+		persistPath:  persistPath,
+		fastSimulate: fastSimulate,
+		debug:        debug,
+		operations:   &operationCounter{},
+		projects:     make(map[string]*SimulationService),
+	}
+	seed := initialSeed()
+	s.faults = NewFaultInjector(seed)
+	s.source, s.rand = newServiceRand(seed)
+	s.buckets = newBucketStore(persistPath, NewClock(), s.rand)
+	s.clusters = NewClusterRegistry(clusterPersistPath(persistPath), s.buckets.Clock(), s.fastSimulate)
+	s.buckets.notify = s.notifier(models.DefaultProject)
+	s.clusters.notify = s.buckets.notify
+	return s
 }
 
-
 // ProviderValidationResult represents the result of provider validation
 type ProviderValidationResult struct {
 	Provider  string                 `json:"provider"`
@@ -82,8 +128,10 @@ type SimulationResult struct {
 	Success   bool                   `json:"success"`
 	Result    map[string]interface{} `json:"result,omitempty"`
 	Error     string                 `json:"error,omitempty"`
-	Timestamp string                 `json:"timestamp"`
-	Duration  time.Duration          `json:"duration"`
+	// Fault is set when a fault profile failed the operation
+	Fault     *Fault        `json:"fault,omitempty"`
+	Timestamp string        `json:"timestamp"`
+	Duration  time.Duration `json:"duration"`
 }
 
 // ValidateProvider simulates provider validation
@@ -104,7 +152,7 @@ func (s *SimulationService) ValidateProvider(provider string, credentials map[st
 			"aks": map[string]interface{}{
 				"available":           true,
 				"kubernetes_versions": []string{"1.28.0", "1.27.3", "1.26.6"},
-				"vm_sizes":            []string{"Standard_D2s_v3", "Standard_D4s_v3", "Standard_B2s"},
+				"vm_sizes":            InstanceTypes("azure"),
 			},
 			"monitoring": map[string]interface{}{
 				"available":            true,
@@ -120,7 +168,7 @@ func (s *SimulationService) ValidateProvider(provider string, credentials map[st
 			"eks": map[string]interface{}{
 				"available":           true,
 				"kubernetes_versions": []string{"1.28", "1.27", "1.26"},
-				"instance_types":      []string{"t3.medium", "t3.large", "m5.large", "m5.xlarge"},
+				"instance_types":      InstanceTypes("aws"),
 			},
 			"cloudwatch": map[string]interface{}{
 				"available": true,
@@ -136,7 +184,7 @@ func (s *SimulationService) ValidateProvider(provider string, credentials map[st
 			"gke": map[string]interface{}{
 				"available":           true,
 				"kubernetes_versions": []string{"1.28.3-gke.1286000", "1.27.7-gke.1056000"},
-				"machine_types":       []string{"e2-medium", "e2-standard-4", "n1-standard-2"},
+				"machine_types":       InstanceTypes("gcp"),
 			},
 			"stackdriver": map[string]interface{}{
 				"available":  true,
@@ -152,7 +200,7 @@ func (s *SimulationService) ValidateProvider(provider string, credentials map[st
 			"kubernetes": map[string]interface{}{
 				"available":           true,
 				"kubernetes_versions": []string{"1.28.0", "1.27.3", "1.26.6"},
-				"server_types":        []string{"cx11", "cx21", "cx31", "cx41"},
+				"server_types":        InstanceTypes("hetzner"),
 			},
 		}
 	case "ionos":
@@ -174,7 +222,7 @@ func (s *SimulationService) ValidateProvider(provider string, credentials map[st
 			"ske": map[string]interface{}{
 				"available":           true,
 				"kubernetes_versions": []string{"1.28.0", "1.27.3", "1.26.6"},
-				"machine_types":       []string{"c1.2", "c1.3", "c1.4", "c1.5"},
+				"machine_types":       InstanceTypes("stackit"),
 			},
 		}
 	default:
@@ -187,88 +235,258 @@ func (s *SimulationService) ValidateProvider(provider string, credentials map[st
 }
 
 // SimulateOperation simulates a cloud provider operation
-
 func (s *SimulationService) SimulateOperation(req *SimulationRequest) *SimulationResult {
-	   start := time.Now()
-
-	   if s.debug {
-			   fmt.Printf("[SIM DEBUG] SimulateOperation: provider=%s, op=%s, params=%#v\n", req.Provider, req.Operation, req.Parameters)
-	   }
-
-	   result := &SimulationResult{
-			   Provider:  req.Provider,
-			   Operation: req.Operation,
-			   Timestamp: start.Format(time.RFC3339),
-	   }
-
-	   // Simulate operation delay unless fastSimulate is enabled
-	   if !s.fastSimulate {
-			   delay := time.Duration(s.rand.Intn(3000)+500) * time.Millisecond
-			   time.Sleep(delay)
-	   }
-
-	   switch req.Operation {
-	   case "create_bucket":
-			   nameVal := s.getParamOrDefault(req.Parameters, "name", "")
-			   name, _ := nameVal.(string)
-			   if name == "" {
-				   // Generate a default name for testing if none provided
-				   name = "sim-bucket-" + s.generateRandomID()
-			   }
-			   // Use exact name like real S3 API - no modifications
-			   regionVal := s.getParamOrDefault(req.Parameters, "region", "us-west-2")
-			   region, _ := regionVal.(string)
-			   bucket := s.buckets.Create(req.Provider, name, region)
-			   result.Success = true
-			   result.Result = bucket
-	   case "delete_bucket":
-			   bucketName, _ := req.Parameters["bucket"].(string)
-			   result.Success, result.Result = s.buckets.Delete(req.Provider, bucketName)
-	   case "list_buckets":
-			   buckets := s.buckets.List(req.Provider)
-			   result.Success = true
-			   result.Result = map[string]interface{}{ "buckets": buckets, "total": len(buckets) }
+	return s.SimulateOperationContext(context.Background(), req)
+}
+
+// SimulateOperationContext simulates a cloud provider operation; injected latency and
+// timeouts end early when ctx is done. With a cassette set, the operation is recorded
+// or answered from the recording.
+func (s *SimulationService) SimulateOperationContext(ctx context.Context, req *SimulationRequest) *SimulationResult {
+	if s.debug {
+		fmt.Printf("[SIM DEBUG] SimulateOperation: provider=%s, op=%s, params=%#v\n", req.Provider, req.Operation, req.Parameters)
+	}
+	cassette := s.Cassette()
+	if cassette != nil && cassette.Mode() == CassetteReplay {
+		result := cassette.Replay(req)
+		s.operations.add(result)
+		return result
+	}
+	result := s.simulateOperation(ctx, req)
+	s.operations.add(result)
+	if cassette != nil {
+		if err := cassette.Record(req, result); err != nil {
+			fmt.Fprintf(os.Stderr, "[SIM] Failed to record to cassette %s: %v\n", cassette.Path(), err)
+		}
+	}
+	return result
+}
+
+func (s *SimulationService) simulateOperation(ctx context.Context, req *SimulationRequest) *SimulationResult {
+	start := time.Now()
+	rng := s.randFor(ctx)
+
+	result := &SimulationResult{
+		Provider:  req.Provider,
+		Operation: req.Operation,
+		Timestamp: start.Format(time.RFC3339),
+	}
+
+	delayed, err := s.faults.Inject(ctx, req.Provider, req.Operation, faultResource(req.Parameters))
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		if fault, ok := err.(*Fault); ok {
+			result.Fault = fault
+		}
+		result.Duration = time.Since(start)
+		return result
+	}
+	// Simulate operation delay unless fastSimulate is enabled or a fault profile set it
+	if !s.fastSimulate && !delayed {
+		delay := time.Duration(rng.Intn(3000)+500) * time.Millisecond
+		time.Sleep(delay)
+	}
+
+	switch req.Operation {
+	case "create_bucket":
+		nameVal := s.getParamOrDefault(req.Parameters, "name", "")
+		name, _ := nameVal.(string)
+		if name == "" {
+			// Generate a default name for testing if none provided
+			name = "sim-bucket-" + generateRandomID(rng)
+		}
+		// Use exact name like real S3 API - no modifications
+		regionVal := s.getParamOrDefault(req.Parameters, "region", "us-west-2")
+		region, _ := regionVal.(string)
+		bucket := s.buckets.Create(req.Provider, name, region)
+		result.Success = true
+		result.Result = bucket
+	case "delete_bucket":
+		bucketName, _ := req.Parameters["bucket"].(string)
+		result.Success, result.Result = s.buckets.Delete(req.Provider, bucketName)
+	case "list_buckets":
+		buckets := s.buckets.List(req.Provider)
+		result.Success = true
+		result.Result = map[string]interface{}{"buckets": buckets, "total": len(buckets)}
 	// ...existing code for clusters and tests...
 	case "create_cluster":
+		if err := validateCreateCluster(req.Parameters); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
 		result.Success = true
-		result.Result = s.simulateCreateCluster(req.Provider, req.Parameters)
+		result.Result = s.simulateCreateCluster(rng, req.Provider, req.Parameters)
+	case "create_budget":
+		budget, err := s.simulateCreateBudget(req.Provider, req.Parameters)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
+		result.Success = true
+		result.Result = budget
 	case "delete_cluster":
+		clusterID, _ := req.Parameters["cluster_id"].(string)
+		cluster, err := s.clusters.Delete(req.Provider, clusterID)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
 		result.Success = true
-		result.Result = map[string]interface{}{
-			"cluster_id": req.Parameters["cluster_id"],
-			"status":     "deleting",
-			"message":    "Cluster deletion initiated",
+		result.Result = cluster.Summary()
+		result.Result["message"] = "Cluster deletion initiated"
+	case "scale_cluster":
+		clusterID, _ := req.Parameters["cluster_id"].(string)
+		nodeCount, err := nodeCountParam(req.Parameters, 0)
+		if err == nil && nodeCount == 0 {
+			err = fmt.Errorf("node_count is required")
+		}
+		var cluster SimulatedCluster
+		if err == nil {
+			cluster, err = s.clusters.Scale(req.Provider, clusterID, nodeCount)
+		}
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
 		}
+		result.Success = true
+		result.Result = cluster.Summary()
 	case "list_clusters":
+		clusters := s.clusters.List(req.Provider)
+		summaries := make([]map[string]interface{}, 0, len(clusters))
+		for _, c := range clusters {
+			summaries = append(summaries, c.Summary())
+		}
 		result.Success = true
-		result.Result = s.simulateListClusters(req.Provider)
+		result.Result = map[string]interface{}{"clusters": summaries, "total": len(summaries)}
 	case "get_cluster":
+		clusterID, _ := req.Parameters["cluster_id"].(string)
+		cluster, err := s.clusters.Get(req.Provider, clusterID)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
 		result.Success = true
-		result.Result = s.simulateGetCluster(req.Provider, req.Parameters)
+		result.Result = cluster.Summary()
 	case "run_test":
 		result.Success = true
-		result.Result = s.simulateRunTest(req.Parameters)
+		result.Result = s.simulateRunTest(rng, req.Parameters)
 	case "set_bucket_policy":
+		bucketName, _ := req.Parameters["bucket"].(string)
+		policy, err := ParseBucketPolicy(req.Parameters["policy"])
+		if err == nil {
+			err = s.buckets.SetPolicy(req.Provider, bucketName, policy)
+		}
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
 		result.Success = true
 		result.Result = map[string]interface{}{
-			"bucket": req.Parameters["bucket"],
-			"policy": req.Parameters["policy"],
+			"bucket": bucketName,
+			"policy": policy,
 			"status": "policy_set",
 		}
+	case "get_bucket_policy":
+		bucketName, _ := req.Parameters["bucket"].(string)
+		policy, err := s.buckets.Policy(req.Provider, bucketName)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
+		result.Success = true
+		result.Result = map[string]interface{}{"bucket": bucketName, "policy": policy}
+	case "delete_bucket_policy":
+		bucketName, _ := req.Parameters["bucket"].(string)
+		if err := s.buckets.DeletePolicy(req.Provider, bucketName); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
+		result.Success = true
+		result.Result = map[string]interface{}{"bucket": bucketName, "status": "policy_deleted"}
+	case "explain_bucket_policy":
+		decision, err := s.ExplainPolicy(req.Provider, req.Parameters)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
+		result.Success = true
+		result.Result = map[string]interface{}{"bucket": req.Parameters["bucket"], "allowed": decision.Allowed, "explanation": decision}
 	case "set_bucket_versioning":
+		bucketName, _ := req.Parameters["bucket"].(string)
+		status := versioningStatusParam(req.Parameters)
+		if err := s.buckets.SetVersioning(req.Provider, bucketName, status); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
 		result.Success = true
 		result.Result = map[string]interface{}{
-			"bucket":     req.Parameters["bucket"],
-			"versioning": req.Parameters["enabled"],
+			"bucket":     bucketName,
+			"versioning": status,
 			"status":     "versioning_set",
 		}
+	case "get_bucket_versioning":
+		bucketName, _ := req.Parameters["bucket"].(string)
+		status, err := s.buckets.Versioning(req.Provider, bucketName)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
+		result.Success = true
+		result.Result = map[string]interface{}{"bucket": bucketName, "versioning": status}
+	case "list_object_versions":
+		bucketName, _ := req.Parameters["bucket"].(string)
+		prefix, _ := req.Parameters["prefix"].(string)
+		versions, err := s.buckets.ListObjectVersions(req.Provider, bucketName, prefix)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
+		result.Success = true
+		result.Result = map[string]interface{}{"bucket": bucketName, "versions": versionSummaries(versions), "total": len(versions)}
 	case "set_bucket_lifecycle":
+		bucketName, _ := req.Parameters["bucket"].(string)
+		rules, err := ParseLifecycleRules(req.Parameters["lifecycle"])
+		if err == nil {
+			err = s.buckets.SetLifecycle(req.Provider, bucketName, rules)
+		}
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
 		result.Success = true
 		result.Result = map[string]interface{}{
-			"bucket":    req.Parameters["bucket"],
-			"lifecycle": req.Parameters["lifecycle"],
+			"bucket":    bucketName,
+			"lifecycle": rules,
 			"status":    "lifecycle_set",
 		}
+	case "get_bucket_lifecycle":
+		bucketName, _ := req.Parameters["bucket"].(string)
+		rules, err := s.buckets.Lifecycle(req.Provider, bucketName)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			break
+		}
+		result.Success = true
+		result.Result = map[string]interface{}{"bucket": bucketName, "lifecycle": rules}
+	case "apply_lifecycle":
+		actions := s.buckets.ApplyLifecycle()
+		result.Success = true
+		result.Result = map[string]interface{}{"actions": actions, "total": len(actions), "now": s.Clock().Now().Format(time.RFC3339)}
 	default:
 		result.Success = false
 		result.Error = fmt.Sprintf("unsupported operation: %s", req.Operation)
@@ -277,12 +495,21 @@ func (s *SimulationService) SimulateOperation(req *SimulationRequest) *Simulatio
 	return result
 }
 
+// faultResource names the resource of an operation for fault profile matching
+func faultResource(params map[string]interface{}) string {
+	for _, key := range []string{"name", "bucket", "cluster_id"} {
+		if v, ok := params[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
 
 // simulateCreateBucket simulates S3/object storage bucket creation
-func (s *SimulationService) simulateCreateBucket(provider string, params map[string]interface{}) map[string]interface{} {
+func (s *SimulationService) simulateCreateBucket(rng *rand.Rand, provider string, params map[string]interface{}) map[string]interface{} {
 	nameVal := s.getParamOrDefault(params, "name", "sim-bucket-")
 	name, _ := nameVal.(string)
-	bucketName := name + s.generateRandomID()
+	bucketName := name + generateRandomID(rng)
 	regionVal := s.getParamOrDefault(params, "region", "us-west-2")
 	region, _ := regionVal.(string)
 	return map[string]interface{}{
@@ -293,90 +520,102 @@ func (s *SimulationService) simulateCreateBucket(provider string, params map[str
 	}
 }
 
-// simulateCreateCluster simulates cluster creation
-func (s *SimulationService) simulateCreateCluster(provider string, params map[string]interface{}) map[string]interface{} {
-	clusterID := fmt.Sprintf("sim-%s-%d", provider, s.rand.Intn(10000))
-
-	result := map[string]interface{}{
-		"cluster_id": clusterID,
-		"name":       params["name"],
-		"provider":   provider,
-		"status":     "creating",
-		"node_count": s.getParamOrDefault(params, "node_count", 3),
-		"created_at": time.Now().Format(time.RFC3339),
+// simulateCreateCluster registers a new cluster, which starts out creating
+func (s *SimulationService) simulateCreateCluster(rng *rand.Rand, provider string, params map[string]interface{}) map[string]interface{} {
+	name, _ := params["name"].(string)
+	nodeCount, _ := nodeCountParam(params, 3)
+	attributes := map[string]interface{}{
+		"kubernetes_version": s.getParamOrDefault(params, "kubernetes_version", "1.28.0"),
 	}
 
 	// Add provider-specific fields
 	switch provider {
 	case "azure":
-		result["resource_group"] = s.getParamOrDefault(params, "resource_group", "rg-"+clusterID)
-		result["location"] = s.getParamOrDefault(params, "location", "eastus")
-		result["vm_size"] = s.getParamOrDefault(params, "vm_size", "Standard_D2s_v3")
+		attributes["resource_group"] = s.getParamOrDefault(params, "resource_group", "rg-"+name)
+		attributes["location"] = s.getParamOrDefault(params, "location", "eastus")
+		attributes["vm_size"] = s.getParamOrDefault(params, "vm_size", "Standard_D2s_v3")
 	case "aws":
-		result["region"] = s.getParamOrDefault(params, "region", "us-west-2")
-		result["instance_type"] = s.getParamOrDefault(params, "instance_type", "t3.medium")
-		result["vpc_id"] = "vpc-" + s.generateRandomID()
+		attributes["region"] = s.getParamOrDefault(params, "region", "us-west-2")
+		attributes["instance_type"] = s.getParamOrDefault(params, "instance_type", "t3.medium")
+		attributes["vpc_id"] = "vpc-" + generateRandomID(rng)
 	case "gcp":
-		result["project_id"] = s.getParamOrDefault(params, "project_id", "project-"+s.generateRandomID())
-		result["region"] = s.getParamOrDefault(params, "region", "us-central1")
-		result["machine_type"] = s.getParamOrDefault(params, "machine_type", "e2-medium")
+		attributes["project_id"] = s.getParamOrDefault(params, "project_id", "project-"+generateRandomID(rng))
+		attributes["region"] = s.getParamOrDefault(params, "region", "us-central1")
+		attributes["machine_type"] = s.getParamOrDefault(params, "machine_type", "e2-medium")
 	}
 
-	return result
+	cluster := s.clusters.Create(provider, name, nodeCount, attributes, func() string {
+		return fmt.Sprintf("sim-%s-%d", provider, rng.Intn(10000))
+	})
+	return cluster.Summary()
 }
 
-// simulateListClusters simulates listing clusters
-func (s *SimulationService) simulateListClusters(provider string) map[string]interface{} {
-	clusters := make([]map[string]interface{}, 0)
-
-	// Generate 2-4 sample clusters
-	numClusters := s.rand.Intn(3) + 2
-	for i := 0; i < numClusters; i++ {
-		clusterID := fmt.Sprintf("sim-%s-%d", provider, s.rand.Intn(10000))
-		cluster := map[string]interface{}{
-			"cluster_id": clusterID,
-			"name":       fmt.Sprintf("%s-cluster-%d", provider, i+1),
-			"provider":   provider,
-			"status":     []string{"running", "creating", "stopped"}[s.rand.Intn(3)],
-			"node_count": s.rand.Intn(5) + 1,
-			"created_at": time.Now().Add(-time.Duration(s.rand.Intn(168)) * time.Hour).Format(time.RFC3339),
-		}
-		clusters = append(clusters, cluster)
+// validateCreateCluster rejects the parameters a provider would refuse: a cluster needs a
+// name and at least one node
+func validateCreateCluster(params map[string]interface{}) error {
+	if name, _ := params["name"].(string); name == "" {
+		return fmt.Errorf("name is required")
 	}
+	_, err := nodeCountParam(params, 0)
+	return err
+}
 
-	return map[string]interface{}{
-		"clusters": clusters,
-		"total":    len(clusters),
+// nodeCountParam reads node_count, which must be a positive integer when given
+func nodeCountParam(params map[string]interface{}, defaultCount int) (int, error) {
+	v, ok := params["node_count"]
+	if !ok {
+		return defaultCount, nil
 	}
+	var n float64
+	switch v := v.(type) {
+	case int:
+		n = float64(v)
+	case float64: // JSON numbers
+		n = v
+	}
+	if n < 1 || n != math.Trunc(n) {
+		return 0, fmt.Errorf("node_count must be a positive integer, got %v", v)
+	}
+	return int(n), nil
 }
 
-// simulateGetCluster simulates getting cluster details
-func (s *SimulationService) simulateGetCluster(provider string, params map[string]interface{}) map[string]interface{} {
-	clusterID := s.getParamOrDefault(params, "cluster_id", "sim-"+provider+"-1234").(string)
-
-	return map[string]interface{}{
-		"cluster_id":         clusterID,
-		"name":               "sample-cluster",
-		"provider":           provider,
-		"status":             "running",
-		"node_count":         3,
-		"kubernetes_version": "1.28.0",
-		"created_at":         time.Now().Add(-time.Duration(s.rand.Intn(168)) * time.Hour).Format(time.RFC3339),
-		"endpoints": map[string]interface{}{
-			"api_server": "https://api-" + clusterID + ".example.com",
-			"dashboard":  "https://dashboard-" + clusterID + ".example.com",
-		},
+// simulateCreateBudget simulates creating a cost budget (Azure Consumption budgets)
+func (s *SimulationService) simulateCreateBudget(provider string, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	amount, _ := params["amount"].(float64)
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be a positive number, got %v", params["amount"])
 	}
+	timeGrain, _ := s.getParamOrDefault(params, "time_grain", "Monthly").(string)
+	switch timeGrain {
+	case "Monthly", "Quarterly", "Annually":
+	default:
+		return nil, fmt.Errorf("time_grain must be Monthly, Quarterly or Annually, got %q", timeGrain)
+	}
+	resourceGroup, _ := s.getParamOrDefault(params, "resource_group", "rg-"+name).(string)
+	return map[string]interface{}{
+		"budget_id":      fmt.Sprintf("budget-%s-%s", resourceGroup, name),
+		"name":           name,
+		"provider":       provider,
+		"amount":         amount,
+		"time_grain":     timeGrain,
+		"resource_group": resourceGroup,
+		"status":         "created",
+		"created_at":     time.Now().Format(time.RFC3339),
+	}, nil
 }
 
 // simulateRunTest simulates running a test
-func (s *SimulationService) simulateRunTest(params map[string]interface{}) map[string]interface{} {
-	testID := fmt.Sprintf("test-%d", s.rand.Intn(10000))
+func (s *SimulationService) simulateRunTest(rng *rand.Rand, params map[string]interface{}) map[string]interface{} {
+	testID := fmt.Sprintf("test-%d", rng.Intn(10000))
 	testType := s.getParamOrDefault(params, "test_type", "connectivity").(string)
 
 	// 90% success rate
 	status := "passed"
-	if s.rand.Float32() < 0.1 {
+	if rng.Float32() < 0.1 {
 		status = "failed"
 	}
 
@@ -386,25 +625,25 @@ func (s *SimulationService) simulateRunTest(params map[string]interface{}) map[s
 		"test_type":  testType,
 		"status":     status,
 		"started_at": time.Now().Format(time.RFC3339),
-		"duration":   fmt.Sprintf("%ds", s.rand.Intn(300)+30),
+		"duration":   fmt.Sprintf("%ds", rng.Intn(300)+30),
 	}
 
 	// Add test-specific results
 	switch testType {
 	case "connectivity":
-		result["endpoints_tested"] = s.rand.Intn(10) + 5
-		result["successful_connections"] = s.rand.Intn(15) + 10
-		result["avg_response_time_ms"] = s.rand.Intn(100) + 20
+		result["endpoints_tested"] = rng.Intn(10) + 5
+		result["successful_connections"] = rng.Intn(15) + 10
+		result["avg_response_time_ms"] = rng.Intn(100) + 20
 	case "performance":
-		result["cpu_usage_percent"] = s.rand.Intn(40) + 30
-		result["memory_usage_percent"] = s.rand.Intn(50) + 25
-		result["requests_per_second"] = s.rand.Intn(1000) + 500
+		result["cpu_usage_percent"] = rng.Intn(40) + 30
+		result["memory_usage_percent"] = rng.Intn(50) + 25
+		result["requests_per_second"] = rng.Intn(1000) + 500
 	case "security":
-		result["vulnerabilities_found"] = s.rand.Intn(3)
-		result["security_score"] = s.rand.Intn(30) + 70
+		result["vulnerabilities_found"] = rng.Intn(3)
+		result["security_score"] = rng.Intn(30) + 70
 	case "compliance":
-		result["policies_checked"] = s.rand.Intn(50) + 25
-		result["compliance_score"] = s.rand.Intn(25) + 75
+		result["policies_checked"] = rng.Intn(50) + 25
+		result["compliance_score"] = rng.Intn(25) + 75
 	}
 
 	return result
@@ -412,8 +651,15 @@ func (s *SimulationService) simulateRunTest(params map[string]interface{}) map[s
 
 // GenerateClusterFromSimulation converts simulation result to Cluster model
 func (s *SimulationService) GenerateClusterFromSimulation(provider string, name string, config map[string]interface{}) *models.Cluster {
+	return s.GenerateClusterFromSimulationContext(context.Background(), provider, name, config)
+}
+
+// GenerateClusterFromSimulationContext is GenerateClusterFromSimulation honouring a
+// seed set with WithSeed
+func (s *SimulationService) GenerateClusterFromSimulationContext(ctx context.Context, provider string, name string, config map[string]interface{}) *models.Cluster {
+	rng := s.randFor(ctx)
 	now := time.Now()
-	clusterID := fmt.Sprintf("sim-%s-%d", provider, s.rand.Intn(10000))
+	clusterID := fmt.Sprintf("sim-%s-%d", provider, rng.Intn(10000))
 
 	cluster := &models.Cluster{
 		ID:        clusterID,
@@ -421,7 +667,7 @@ func (s *SimulationService) GenerateClusterFromSimulation(provider string, name
 		Provider:  models.CloudProvider(provider),
 		Status:    models.ClusterStatusRunning,
 		Config:    make(map[string]interface{}),
-		CreatedAt: now.Add(-time.Duration(s.rand.Intn(24)) * time.Hour),
+		CreatedAt: now.Add(-time.Duration(rng.Intn(24)) * time.Hour),
 		UpdatedAt: now,
 	}
 
@@ -450,12 +696,12 @@ func (s *SimulationService) GenerateClusterFromSimulation(provider string, name
 		cluster.Region = s.getParamOrDefault(config, "region", "us-west-2").(string)
 		cluster.ProviderConfig = map[string]interface{}{
 			"instance_type":    "t3.medium",
-			"vpc_id":           "vpc-" + s.generateRandomID(),
-			"subnet_ids":       []string{"subnet-" + s.generateRandomID(), "subnet-" + s.generateRandomID()},
+			"vpc_id":           "vpc-" + generateRandomID(rng),
+			"subnet_ids":       []string{"subnet-" + generateRandomID(rng), "subnet-" + generateRandomID(rng)},
 			"endpoint_private": false,
 		}
 	case models.GCP:
-		cluster.ProjectID = s.getParamOrDefault(config, "project_id", "project-"+s.generateRandomID()).(string)
+		cluster.ProjectID = s.getParamOrDefault(config, "project_id", "project-"+generateRandomID(rng)).(string)
 		cluster.Region = s.getParamOrDefault(config, "region", "us-central1").(string)
 		cluster.ProviderConfig = map[string]interface{}{
 			"machine_type":     "e2-medium",
@@ -470,39 +716,46 @@ func (s *SimulationService) GenerateClusterFromSimulation(provider string, name
 
 // GenerateTestResultFromSimulation converts simulation result to TestResult model
 func (s *SimulationService) GenerateTestResultFromSimulation(clusterID, testType string) *models.TestResult {
+	return s.GenerateTestResultFromSimulationContext(context.Background(), clusterID, testType)
+}
+
+// GenerateTestResultFromSimulationContext is GenerateTestResultFromSimulation honouring
+// a seed set with WithSeed
+func (s *SimulationService) GenerateTestResultFromSimulationContext(ctx context.Context, clusterID, testType string) *models.TestResult {
+	rng := s.randFor(ctx)
 	now := time.Now()
-	testID := fmt.Sprintf("test-%d", s.rand.Intn(10000))
+	testID := fmt.Sprintf("test-%d", rng.Intn(10000))
 
 	// 90% success rate
 	status := models.TestStatusPassed
 	var errorMsg string
-	if s.rand.Float32() < 0.1 {
+	if rng.Float32() < 0.1 {
 		status = models.TestStatusFailed
 		errorMsg = "Simulated test failure"
 	}
 
-	duration := time.Duration(s.rand.Intn(300)+30) * time.Second
+	duration := time.Duration(rng.Intn(300)+30) * time.Second
 	details := make(map[string]interface{})
 
 	// Add test-specific details
 	switch testType {
 	case "connectivity":
-		details["endpoints_tested"] = s.rand.Intn(10) + 5
-		details["successful_connections"] = s.rand.Intn(15) + 10
-		details["avg_response_time_ms"] = s.rand.Intn(100) + 20
+		details["endpoints_tested"] = rng.Intn(10) + 5
+		details["successful_connections"] = rng.Intn(15) + 10
+		details["avg_response_time_ms"] = rng.Intn(100) + 20
 	case "performance":
-		details["cpu_usage_percent"] = s.rand.Intn(40) + 30
-		details["memory_usage_percent"] = s.rand.Intn(50) + 25
-		details["requests_per_second"] = s.rand.Intn(1000) + 500
-		details["p95_latency_ms"] = s.rand.Intn(200) + 50
+		details["cpu_usage_percent"] = rng.Intn(40) + 30
+		details["memory_usage_percent"] = rng.Intn(50) + 25
+		details["requests_per_second"] = rng.Intn(1000) + 500
+		details["p95_latency_ms"] = rng.Intn(200) + 50
 	case "security":
-		details["vulnerabilities_found"] = s.rand.Intn(3)
-		details["security_score"] = s.rand.Intn(30) + 70
-		details["compliant_policies"] = s.rand.Intn(20) + 15
+		details["vulnerabilities_found"] = rng.Intn(3)
+		details["security_score"] = rng.Intn(30) + 70
+		details["compliant_policies"] = rng.Intn(20) + 15
 	case "compliance":
-		details["policies_checked"] = s.rand.Intn(50) + 25
-		details["compliant_policies"] = s.rand.Intn(45) + 20
-		details["compliance_score"] = s.rand.Intn(25) + 75
+		details["policies_checked"] = rng.Intn(50) + 25
+		details["compliant_policies"] = rng.Intn(45) + 20
+		details["compliance_score"] = rng.Intn(25) + 75
 	}
 
 	completedAt := now
@@ -521,6 +774,35 @@ func (s *SimulationService) GenerateTestResultFromSimulation(clusterID, testType
 
 // Helper functions
 
+// versioningStatusParam reads the requested versioning state from either a "status"
+// string ("Enabled"/"Suspended") or the legacy "enabled" boolean.
+func versioningStatusParam(params map[string]interface{}) string {
+	if status, ok := params["status"].(string); ok {
+		return status
+	}
+	if enabled, ok := params["enabled"].(bool); ok && !enabled {
+		return VersioningSuspended
+	}
+	return VersioningEnabled
+}
+
+// versionSummaries converts object versions to JSON-friendly maps without object data.
+func versionSummaries(versions []ObjectVersion) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(versions))
+	for _, v := range versions {
+		out = append(out, map[string]interface{}{
+			"key":              v.Key,
+			"version_id":       v.VersionID,
+			"is_latest":        v.IsLatest,
+			"is_delete_marker": v.IsDeleteMarker,
+			"etag":             v.ETag,
+			"size":             v.Size(),
+			"last_modified":    v.LastModified.Format(time.RFC3339),
+		})
+	}
+	return out
+}
+
 func (s *SimulationService) getParamOrDefault(params map[string]interface{}, key string, defaultValue interface{}) interface{} {
 	if val, exists := params[key]; exists {
 		return val
@@ -528,11 +810,11 @@ func (s *SimulationService) getParamOrDefault(params map[string]interface{}, key
 	return defaultValue
 }
 
-func (s *SimulationService) generateRandomID() string {
+func generateRandomID(rng *rand.Rand) string {
 	chars := "abcdefghijklmnopqrstuvwxyz0123456789"
 	result := make([]byte, 8)
 	for i := range result {
-		result[i] = chars[s.rand.Intn(len(chars))]
+		result[i] = chars[rng.Intn(len(chars))]
 	}
 	return string(result)
 }
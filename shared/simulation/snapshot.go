@@ -0,0 +1,235 @@
+package simulation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// Snapshots. A snapshot holds the simulated time and the buckets, objects, multipart
+// uploads and clusters of every project, so a test suite can put the simulation back
+// into a known state. Fault profiles, the random source, the cassette and operation
+// counts are configuration or statistics and not part of it.
+
+// ErrInvalidSnapshot is returned by Restore for snapshots that cannot be loaded; the
+// simulation is left unchanged
+var ErrInvalidSnapshot = errors.New("invalid simulation snapshot")
+
+// Snapshot is the state of a simulation service. Projects are keyed by name, the
+// default project under models.DefaultProject.
+type Snapshot struct {
+	Now      time.Time                   `json:"now"`
+	Projects map[string]*ProjectSnapshot `json:"projects"`
+}
+
+// ProjectSnapshot is the state of one project, by provider
+type ProjectSnapshot struct {
+	// Buckets holds the bucket info (region, versioning, policy, lifecycle, ...) by name
+	Buckets map[string]map[string]map[string]interface{} `json:"buckets,omitempty"`
+	// Objects holds the versions of every key by bucket, oldest first
+	Objects map[string]map[string]map[string][]*Object `json:"objects,omitempty"`
+	// Uploads are the multipart uploads in progress
+	Uploads map[string][]*MultipartUpload `json:"uploads,omitempty"`
+	// Clusters holds the simulated clusters by ID
+	Clusters map[string]map[string]*SimulatedCluster `json:"clusters,omitempty"`
+}
+
+// Snapshot returns the state of the simulation. The snapshot shares nothing with the
+// service; operations running meanwhile may or may not be part of it.
+func (s *SimulationService) Snapshot() (*Snapshot, error) {
+	root := s.rootService()
+	snapshot := &Snapshot{Now: root.Clock().Now(), Projects: map[string]*ProjectSnapshot{}}
+	for _, name := range root.Projects() {
+		p := root.Project(name)
+		project := new(ProjectSnapshot)
+		if err := p.buckets.snapshot(project); err != nil {
+			return nil, err
+		}
+		if err := p.clusters.snapshot(project); err != nil {
+			return nil, err
+		}
+		snapshot.Projects[name] = project
+	}
+	return snapshot, nil
+}
+
+// Restore replaces the state of the simulation with a snapshot, the simulated time
+// included. Projects missing from the snapshot are deleted, the default project is
+// emptied. The snapshot is checked first, so an invalid one changes nothing; callers
+// that need the restore to be atomic for their clients hold back other operations
+// meanwhile. The service keeps the snapshot's objects, so it must not be used after.
+func (s *SimulationService) Restore(snapshot *Snapshot) error {
+	if err := snapshot.Validate(); err != nil {
+		return err
+	}
+	projects := make(map[string]*ProjectSnapshot, len(snapshot.Projects)+1)
+	for name, project := range snapshot.Projects {
+		if name == "" {
+			name = models.DefaultProject
+		}
+		if project == nil {
+			project = new(ProjectSnapshot)
+		}
+		projects[name] = project
+	}
+	if projects[models.DefaultProject] == nil {
+		projects[models.DefaultProject] = new(ProjectSnapshot)
+	}
+
+	root := s.rootService()
+	if snapshot.Now.IsZero() {
+		root.Clock().Reset()
+	} else {
+		root.Clock().Set(snapshot.Now)
+	}
+	for _, name := range root.Projects() {
+		if projects[name] == nil {
+			root.DeleteProject(name)
+		}
+	}
+	for name, project := range projects {
+		p := root.Project(name)
+		p.buckets.restore(project)
+		p.clusters.restore(project)
+	}
+	return nil
+}
+
+// Validate checks that objects and uploads belong to buckets of the snapshot and that
+// objects, uploads and clusters are filed under their own key, ID and provider.
+func (snapshot *Snapshot) Validate() error {
+	for name, project := range snapshot.Projects {
+		if project == nil {
+			continue
+		}
+		for provider, buckets := range project.Objects {
+			for bucket, keys := range buckets {
+				if project.Buckets[provider][bucket] == nil {
+					return fmt.Errorf("%w: project %q: objects of unknown bucket %s/%s", ErrInvalidSnapshot, name, provider, bucket)
+				}
+				for key, versions := range keys {
+					for _, obj := range versions {
+						if obj == nil || obj.Key != key || obj.VersionID == "" {
+							return fmt.Errorf("%w: project %q: invalid version of %s/%s/%s", ErrInvalidSnapshot, name, provider, bucket, key)
+						}
+					}
+				}
+			}
+		}
+		for provider, uploads := range project.Uploads {
+			for _, upload := range uploads {
+				if upload == nil || upload.UploadID == "" || project.Buckets[provider][upload.Bucket] == nil {
+					return fmt.Errorf("%w: project %q: upload without an ID or of an unknown bucket in %s", ErrInvalidSnapshot, name, provider)
+				}
+			}
+		}
+		for provider, clusters := range project.Clusters {
+			for id, c := range clusters {
+				if c == nil || c.ID != id || c.Provider != provider {
+					return fmt.Errorf("%w: project %q: cluster %s/%s does not match its ID or provider", ErrInvalidSnapshot, name, provider, id)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// snapshotCopy copies v into out through JSON, so the snapshot shares nothing
+func snapshotCopy(v, out interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// snapshot adds the buckets, objects and uploads of the store to project
+func (bs *BucketStore) snapshot(project *ProjectSnapshot) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	uploads := make(map[string][]*MultipartUpload, len(bs.uploads))
+	for provider, tracker := range bs.uploads {
+		if list := tracker.all(); len(list) > 0 {
+			uploads[provider] = list
+		}
+	}
+	err := snapshotCopy(bs.buckets, &project.Buckets)
+	if err == nil {
+		err = snapshotCopy(bs.objects, &project.Objects)
+	}
+	if err == nil {
+		err = snapshotCopy(uploads, &project.Uploads)
+	}
+	return err
+}
+
+// restore replaces the buckets, objects and uploads of the store with those of
+// project and persists the bucket info. The upload trackers are kept, so their
+// settings (minimum part size) stay as they are.
+func (bs *BucketStore) restore(project *ProjectSnapshot) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.buckets = make(map[string]map[string]interface{}, len(project.Buckets))
+	for provider, buckets := range project.Buckets {
+		bs.buckets[provider] = make(map[string]interface{}, len(buckets))
+		for name, info := range buckets {
+			bs.buckets[provider][name] = info
+		}
+	}
+	bs.objects = project.Objects
+	if bs.objects == nil {
+		bs.objects = make(map[string]map[string]map[string][]*Object)
+	}
+	for _, tracker := range bs.uploads {
+		tracker.replace(nil)
+	}
+	for provider, uploads := range project.Uploads {
+		bs.providerUploads(provider).replace(uploads)
+	}
+	bs.Save()
+}
+
+// all returns the uploads in progress
+func (m *MultipartUploads) all() []*MultipartUpload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	uploads := make([]*MultipartUpload, 0, len(m.uploads))
+	for _, u := range m.uploads {
+		uploads = append(uploads, u)
+	}
+	return uploads
+}
+
+// replace discards the uploads in progress and tracks uploads instead
+func (m *MultipartUploads) replace(uploads []*MultipartUpload) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads = make(map[string]*MultipartUpload, len(uploads))
+	for _, u := range uploads {
+		if u.Parts == nil {
+			u.Parts = make(map[int]*Part)
+		}
+		m.uploads[u.UploadID] = u
+	}
+}
+
+// snapshot adds the clusters of the registry to project, pending transitions included
+func (r *ClusterRegistry) snapshot(project *ProjectSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return snapshotCopy(r.clusters, &project.Clusters)
+}
+
+// restore replaces the clusters of the registry with those of project and persists them
+func (r *ClusterRegistry) restore(project *ProjectSnapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clusters = project.Clusters
+	if r.clusters == nil {
+		r.clusters = make(map[string]map[string]*SimulatedCluster)
+	}
+	r.save()
+}
@@ -0,0 +1,133 @@
+package simulation
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// Outcomes of a simulated operation, as counted by OperationCounts
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeFault   = "fault"
+)
+
+// OperationCount is the number of simulated operations of one provider, operation
+// and outcome since the service started.
+type OperationCount struct {
+	Provider  string
+	Operation string
+	Outcome   string
+	Count     int64
+}
+
+// OperationOther is counted in place of providers and operations the simulator does not
+// know, so requests cannot add a label value for every name they make up
+const OperationOther = "other"
+
+var countedProviders = map[string]bool{
+	string(models.Azure): true, string(models.AWS): true, string(models.GCP): true,
+	string(models.Hetzner): true, string(models.IONOS): true, string(models.StackIT): true,
+}
+
+// countedOperations are the operations simulateOperation handles
+var countedOperations = map[string]bool{
+	"create_bucket": true, "delete_bucket": true, "list_buckets": true,
+	"create_cluster": true, "delete_cluster": true, "scale_cluster": true, "list_clusters": true, "get_cluster": true,
+	"create_budget": true, "run_test": true,
+	"set_bucket_policy": true, "get_bucket_policy": true, "delete_bucket_policy": true, "explain_bucket_policy": true,
+	"set_bucket_versioning": true, "get_bucket_versioning": true, "list_object_versions": true,
+	"set_bucket_lifecycle": true, "get_bucket_lifecycle": true, "apply_lifecycle": true,
+}
+
+type operationKey struct {
+	provider, operation, outcome string
+}
+
+// operationCounter counts simulated operations by provider, operation and outcome.
+type operationCounter struct {
+	mu     sync.Mutex
+	counts map[operationKey]int64
+}
+
+func (c *operationCounter) add(result *SimulationResult) {
+	outcome := OutcomeSuccess
+	switch {
+	case result.Fault != nil:
+		outcome = OutcomeFault
+	case !result.Success:
+		outcome = OutcomeFailure
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[operationKey]int64)
+	}
+	provider, operation := result.Provider, result.Operation
+	if !countedProviders[provider] {
+		provider = OperationOther
+	}
+	if !countedOperations[operation] {
+		operation = OperationOther
+	}
+	c.counts[operationKey{provider, operation, outcome}]++
+}
+
+// OperationCounts returns the operations simulated so far (replayed ones included),
+// ordered by provider, operation and outcome. Unknown providers and operations are
+// counted as OperationOther.
+func (s *SimulationService) OperationCounts() []OperationCount {
+	s.operations.mu.Lock()
+	counts := make([]OperationCount, 0, len(s.operations.counts))
+	for key, n := range s.operations.counts {
+		counts = append(counts, OperationCount{Provider: key.provider, Operation: key.operation, Outcome: key.outcome, Count: n})
+	}
+	s.operations.mu.Unlock()
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Operation != b.Operation {
+			return a.Operation < b.Operation
+		}
+		return a.Outcome < b.Outcome
+	})
+	return counts
+}
+
+// BucketStats summarises the simulated object storage of one provider.
+type BucketStats struct {
+	Provider string
+	Buckets  int
+	// Objects counts the keys whose current version is not a delete marker
+	Objects int
+	// Bytes is the size of every stored version, noncurrent ones included, as
+	// a provider would bill it
+	Bytes int64
+}
+
+// Stats returns the bucket, object and byte totals per provider, ordered by provider.
+func (bs *BucketStore) Stats() []BucketStats {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	stats := make([]BucketStats, 0, len(bs.buckets))
+	for provider, buckets := range bs.buckets {
+		st := BucketStats{Provider: provider, Buckets: len(buckets)}
+		for bucket := range buckets {
+			for _, versions := range bs.objects[provider][bucket] {
+				if len(versions) > 0 && !versions[len(versions)-1].IsDeleteMarker {
+					st.Objects++
+				}
+				for _, v := range versions {
+					st.Bytes += v.Size()
+				}
+			}
+		}
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+	return stats
+}
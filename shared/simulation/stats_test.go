@@ -0,0 +1,33 @@
+package simulation
+
+import (
+	"testing"
+)
+
+func TestOperationCounterLabels(t *testing.T) {
+	tests := []struct {
+		name                        string
+		result                      SimulationResult
+		wantProvider, wantOperation string
+		wantOutcome                 string
+	}{
+		{"known", SimulationResult{Provider: "aws", Operation: "create_bucket", Success: true}, "aws", "create_bucket", OutcomeSuccess},
+		{"failed", SimulationResult{Provider: "hetzner", Operation: "scale_cluster"}, "hetzner", "scale_cluster", OutcomeFailure},
+		{"fault", SimulationResult{Provider: "stackit", Operation: "list_buckets", Fault: &Fault{}}, "stackit", "list_buckets", OutcomeFault},
+		{"unknown provider", SimulationResult{Provider: "provider-4711", Operation: "create_bucket", Success: true}, OperationOther, "create_bucket", OutcomeSuccess},
+		{"unknown operation", SimulationResult{Provider: "gcp", Operation: "mine_bitcoin"}, "gcp", OperationOther, OutcomeFailure},
+		{"empty", SimulationResult{}, OperationOther, OperationOther, OutcomeFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SimulationService{operations: &operationCounter{}}
+			s.operations.add(&tt.result)
+			s.operations.add(&tt.result)
+			counts := s.OperationCounts()
+			want := OperationCount{Provider: tt.wantProvider, Operation: tt.wantOperation, Outcome: tt.wantOutcome, Count: 2}
+			if len(counts) != 1 || counts[0] != want {
+				t.Errorf("OperationCounts() = %+v, want [%+v]", counts, want)
+			}
+		})
+	}
+}
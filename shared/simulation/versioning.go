@@ -0,0 +1,186 @@
+package simulation
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Bucket versioning states. A bucket starts Unversioned; once versioning has been
+// Enabled it can only be Suspended, never returned to Unversioned (as in S3).
+const (
+	VersioningUnversioned = "Unversioned"
+	VersioningEnabled     = "Enabled"
+	VersioningSuspended   = "Suspended"
+
+	// NullVersionID is the version ID of objects written while a bucket is
+	// unversioned or suspended.
+	NullVersionID = "null"
+)
+
+var (
+	ErrNoSuchVersion              = errors.New("version not found")
+	ErrVersionIsDeleteMarker      = errors.New("version is a delete marker")
+	ErrInvalidVersioningStatus    = errors.New("versioning status must be Enabled or Suspended")
+	ErrVersioningCannotBeDisabled = errors.New("versioning cannot be returned to Unversioned once enabled")
+)
+
+// ObjectVersion is an entry of a ListObjectVersions result.
+type ObjectVersion struct {
+	*Object
+	IsLatest bool `json:"is_latest"`
+}
+
+// versioning returns the versioning state stored in the bucket info.
+// The caller must hold bs.mu.
+func (bs *BucketStore) versioning(provider, bucket string) string {
+	info, _ := bs.buckets[provider][bucket].(map[string]interface{})
+	if status, ok := info["versioning"].(string); ok && status != "" {
+		return status
+	}
+	return VersioningUnversioned
+}
+
+// Versioning returns the versioning state of a bucket.
+func (bs *BucketStore) Versioning(provider, bucket string) (string, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return "", ErrNoSuchBucket
+	}
+	return bs.versioning(provider, bucket), nil
+}
+
+// SetVersioning changes the versioning state of a bucket. The state is persisted
+// with the bucket metadata.
+func (bs *BucketStore) SetVersioning(provider, bucket, status string) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	info, ok := bs.buckets[provider][bucket].(map[string]interface{})
+	if !ok {
+		return ErrNoSuchBucket
+	}
+	switch status {
+	case VersioningEnabled, VersioningSuspended:
+	case VersioningUnversioned:
+		if bs.versioning(provider, bucket) != VersioningUnversioned {
+			return ErrVersioningCannotBeDisabled
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidVersioningStatus, status)
+	}
+	info["versioning"] = status
+	bs.Save()
+	return nil
+}
+
+// newVersionID returns a unique, opaque version ID. The caller must hold bs.mu.
+func (bs *BucketStore) newVersionID(provider, bucket, key string) string {
+	bs.versionSeq++
+	sum := md5.Sum([]byte(fmt.Sprintf("%s/%s/%s/%d/%d", provider, bucket, key, bs.versionSeq, bs.ids.Int63())))
+	return hex.EncodeToString(sum[:])
+}
+
+// addVersion makes obj the current version of its key according to the bucket
+// versioning state:
+//   - Unversioned: obj replaces the object (version "null")
+//   - Enabled: obj is appended with a fresh version ID
+//   - Suspended: obj replaces any existing "null" version, other versions are kept
+//
+// The caller must hold bs.mu.
+func (bs *BucketStore) addVersion(provider, bucket string, obj *Object) {
+	objects := bs.bucketObjects(provider, bucket)
+	switch bs.versioning(provider, bucket) {
+	case VersioningEnabled:
+		obj.VersionID = bs.newVersionID(provider, bucket, obj.Key)
+		objects[obj.Key] = append(objects[obj.Key], obj)
+	case VersioningSuspended:
+		obj.VersionID = NullVersionID
+		objects[obj.Key] = append(withoutVersion(objects[obj.Key], NullVersionID), obj)
+	default:
+		obj.VersionID = NullVersionID
+		objects[obj.Key] = []*Object{obj}
+	}
+}
+
+func withoutVersion(versions []*Object, versionID string) []*Object {
+	kept := make([]*Object, 0, len(versions))
+	for _, v := range versions {
+		if v.VersionID != versionID {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// GetObjectVersion returns a copy of a specific version of key. Requesting a delete
+// marker returns the marker together with ErrVersionIsDeleteMarker.
+func (bs *BucketStore) GetObjectVersion(provider, bucket, key, versionID string) (*Object, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return nil, ErrNoSuchBucket
+	}
+	for _, v := range bs.bucketObjects(provider, bucket)[key] {
+		if v.VersionID == versionID {
+			copied := *v
+			if v.IsDeleteMarker {
+				return &copied, ErrVersionIsDeleteMarker
+			}
+			return &copied, nil
+		}
+	}
+	return nil, ErrNoSuchVersion
+}
+
+// DeleteObjectVersion permanently removes one version (or delete marker) of key and
+// returns it. Removing the current delete marker makes the previous version current again.
+func (bs *BucketStore) DeleteObjectVersion(provider, bucket, key, versionID string) (*Object, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return nil, ErrNoSuchBucket
+	}
+	objects := bs.bucketObjects(provider, bucket)
+	for _, v := range objects[key] {
+		if v.VersionID == versionID {
+			if remaining := withoutVersion(objects[key], versionID); len(remaining) > 0 {
+				objects[key] = remaining
+			} else {
+				delete(objects, key)
+			}
+			return v, nil
+		}
+	}
+	return nil, ErrNoSuchVersion
+}
+
+// ListObjectVersions returns every version and delete marker of the keys starting
+// with prefix, ordered by key and then newest first.
+func (bs *BucketStore) ListObjectVersions(provider, bucket, prefix string) ([]ObjectVersion, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !bs.bucketExists(provider, bucket) {
+		return nil, ErrNoSuchBucket
+	}
+	objects := bs.bucketObjects(provider, bucket)
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	versions := make([]ObjectVersion, 0)
+	for _, key := range keys {
+		history := objects[key]
+		for i := len(history) - 1; i >= 0; i-- {
+			versions = append(versions, ObjectVersion{Object: history[i], IsLatest: i == len(history)-1})
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,440 @@
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// AzureStore holds the Azure monitoring, Kubernetes and budget resources. Every
+// backend keeps one collection per resource type; IDs are assigned on create when
+// empty.
+type AzureStore interface {
+	CreateLogAnalyticsWorkspace(workspace *sharedmodels.LogAnalyticsWorkspace) (*sharedmodels.LogAnalyticsWorkspace, error)
+	GetLogAnalyticsWorkspace(id string) (*sharedmodels.LogAnalyticsWorkspace, error)
+	UpdateLogAnalyticsWorkspace(id string, workspace *sharedmodels.LogAnalyticsWorkspace) (*sharedmodels.LogAnalyticsWorkspace, error)
+	DeleteLogAnalyticsWorkspace(id string) error
+	ListLogAnalyticsWorkspaces() ([]*sharedmodels.LogAnalyticsWorkspace, error)
+
+	CreateAppInsights(app *sharedmodels.AppInsightsResource) (*sharedmodels.AppInsightsResource, error)
+	GetAppInsights(id string) (*sharedmodels.AppInsightsResource, error)
+	UpdateAppInsights(id string, app *sharedmodels.AppInsightsResource) (*sharedmodels.AppInsightsResource, error)
+	DeleteAppInsights(id string) error
+	ListAppInsights() ([]*sharedmodels.AppInsightsResource, error)
+
+	CreateAzureBudget(budget *sharedmodels.AzureBudget) (*sharedmodels.AzureBudget, error)
+	GetAzureBudget(id string) (*sharedmodels.AzureBudget, error)
+	UpdateAzureBudget(id string, budget *sharedmodels.AzureBudget) (*sharedmodels.AzureBudget, error)
+	DeleteAzureBudget(id string) error
+	ListAzureBudgets() ([]*sharedmodels.AzureBudget, error)
+
+	CreateAzureMonitoring(monitoring *sharedmodels.AzureMonitoring) (*sharedmodels.AzureMonitoring, error)
+	GetAzureMonitoring(id string) (*sharedmodels.AzureMonitoring, error)
+	UpdateAzureMonitoring(id string, monitoring *sharedmodels.AzureMonitoring) (*sharedmodels.AzureMonitoring, error)
+	DeleteAzureMonitoring(id string) error
+	ListAzureMonitorings() ([]*sharedmodels.AzureMonitoring, error)
+
+	CreateAzureKubernetes(kubernetes *sharedmodels.AzureKubernetes) (*sharedmodels.AzureKubernetes, error)
+	GetAzureKubernetes(id string) (*sharedmodels.AzureKubernetes, error)
+	UpdateAzureKubernetes(id string, kubernetes *sharedmodels.AzureKubernetes) (*sharedmodels.AzureKubernetes, error)
+	DeleteAzureKubernetes(id string) error
+	ListAzureKubernetes() ([]*sharedmodels.AzureKubernetes, error)
+}
+
+// azureKind describes the collection of one Azure resource type: its bolt bucket
+// (whose name also keys the memory store) and the fields every resource carries.
+type azureKind[T any] struct {
+	bucket []byte
+	fields func(*T) (id *string, version *int64, createdAt, updatedAt *time.Time)
+}
+
+var (
+	logAnalyticsKind = azureKind[sharedmodels.LogAnalyticsWorkspace]{
+		bucket: []byte("azure_log_analytics"),
+		fields: func(w *sharedmodels.LogAnalyticsWorkspace) (*string, *int64, *time.Time, *time.Time) {
+			return &w.ID, &w.ResourceVersion, &w.CreatedAt, &w.UpdatedAt
+		},
+	}
+	appInsightsKind = azureKind[sharedmodels.AppInsightsResource]{
+		bucket: []byte("azure_app_insights"),
+		fields: func(a *sharedmodels.AppInsightsResource) (*string, *int64, *time.Time, *time.Time) {
+			return &a.ID, &a.ResourceVersion, &a.CreatedAt, &a.UpdatedAt
+		},
+	}
+	budgetKind = azureKind[sharedmodels.AzureBudget]{
+		bucket: []byte("azure_budgets"),
+		fields: func(b *sharedmodels.AzureBudget) (*string, *int64, *time.Time, *time.Time) {
+			return &b.ID, &b.ResourceVersion, &b.CreatedAt, &b.UpdatedAt
+		},
+	}
+	monitoringKind = azureKind[sharedmodels.AzureMonitoring]{
+		bucket: []byte("azure_monitorings"),
+		fields: func(m *sharedmodels.AzureMonitoring) (*string, *int64, *time.Time, *time.Time) {
+			return &m.ID, &m.ResourceVersion, &m.CreatedAt, &m.UpdatedAt
+		},
+	}
+	kubernetesKind = azureKind[sharedmodels.AzureKubernetes]{
+		bucket: []byte("azure_kubernetes"),
+		fields: func(k *sharedmodels.AzureKubernetes) (*string, *int64, *time.Time, *time.Time) {
+			return &k.ID, &k.ResourceVersion, &k.CreatedAt, &k.UpdatedAt
+		},
+	}
+
+	azureBuckets = [][]byte{logAnalyticsKind.bucket, appInsightsKind.bucket, budgetKind.bucket, monitoringKind.bucket, kubernetesKind.bucket}
+)
+
+// MemoryStore: the collections live in s.azure, keyed by bucket name and ID
+
+func memoryCreate[T any](s *MemoryStore, kind azureKind[T], v *T) (*T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.azure[string(kind.bucket)]
+	id, version, createdAt, updatedAt := kind.fields(v)
+	if *id == "" {
+		*id = uuid.New().String()
+	} else if _, exists := items[*id]; exists {
+		return nil, ErrAlreadyExists
+	}
+
+	*version = 1
+	*createdAt = time.Now()
+	*updatedAt = time.Now()
+	items[*id] = v
+	return v, nil
+}
+
+func memoryGet[T any](s *MemoryStore, kind azureKind[T], id string) (*T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, exists := s.azure[string(kind.bucket)][id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return v.(*T), nil
+}
+
+func memoryUpdate[T any](s *MemoryStore, kind azureKind[T], id string, v *T) (*T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.azure[string(kind.bucket)]
+	existing, exists := items[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	existingID, existingVersion, existingCreatedAt, _ := kind.fields(existing.(*T))
+	newID, version, createdAt, updatedAt := kind.fields(v)
+	*newID = *existingID
+	*version = *existingVersion + 1
+	*createdAt = *existingCreatedAt
+	*updatedAt = time.Now()
+	items[id] = v
+	return v, nil
+}
+
+func memoryDelete[T any](s *MemoryStore, kind azureKind[T], id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.azure[string(kind.bucket)]
+	if _, exists := items[id]; !exists {
+		return ErrNotFound
+	}
+	delete(items, id)
+	return nil
+}
+
+func memoryList[T any](s *MemoryStore, kind azureKind[T]) ([]*T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := []*T{}
+	for _, v := range s.azure[string(kind.bucket)] {
+		list = append(list, v.(*T))
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) CreateLogAnalyticsWorkspace(workspace *sharedmodels.LogAnalyticsWorkspace) (*sharedmodels.LogAnalyticsWorkspace, error) {
+	return memoryCreate(s, logAnalyticsKind, workspace)
+}
+
+func (s *MemoryStore) GetLogAnalyticsWorkspace(id string) (*sharedmodels.LogAnalyticsWorkspace, error) {
+	return memoryGet(s, logAnalyticsKind, id)
+}
+
+func (s *MemoryStore) UpdateLogAnalyticsWorkspace(id string, workspace *sharedmodels.LogAnalyticsWorkspace) (*sharedmodels.LogAnalyticsWorkspace, error) {
+	return memoryUpdate(s, logAnalyticsKind, id, workspace)
+}
+
+func (s *MemoryStore) DeleteLogAnalyticsWorkspace(id string) error {
+	return memoryDelete(s, logAnalyticsKind, id)
+}
+
+func (s *MemoryStore) ListLogAnalyticsWorkspaces() ([]*sharedmodels.LogAnalyticsWorkspace, error) {
+	return memoryList(s, logAnalyticsKind)
+}
+
+func (s *MemoryStore) CreateAppInsights(app *sharedmodels.AppInsightsResource) (*sharedmodels.AppInsightsResource, error) {
+	return memoryCreate(s, appInsightsKind, app)
+}
+
+func (s *MemoryStore) GetAppInsights(id string) (*sharedmodels.AppInsightsResource, error) {
+	return memoryGet(s, appInsightsKind, id)
+}
+
+func (s *MemoryStore) UpdateAppInsights(id string, app *sharedmodels.AppInsightsResource) (*sharedmodels.AppInsightsResource, error) {
+	return memoryUpdate(s, appInsightsKind, id, app)
+}
+
+func (s *MemoryStore) DeleteAppInsights(id string) error {
+	return memoryDelete(s, appInsightsKind, id)
+}
+
+func (s *MemoryStore) ListAppInsights() ([]*sharedmodels.AppInsightsResource, error) {
+	return memoryList(s, appInsightsKind)
+}
+
+func (s *MemoryStore) CreateAzureBudget(budget *sharedmodels.AzureBudget) (*sharedmodels.AzureBudget, error) {
+	return memoryCreate(s, budgetKind, budget)
+}
+
+func (s *MemoryStore) GetAzureBudget(id string) (*sharedmodels.AzureBudget, error) {
+	return memoryGet(s, budgetKind, id)
+}
+
+func (s *MemoryStore) UpdateAzureBudget(id string, budget *sharedmodels.AzureBudget) (*sharedmodels.AzureBudget, error) {
+	return memoryUpdate(s, budgetKind, id, budget)
+}
+
+func (s *MemoryStore) DeleteAzureBudget(id string) error {
+	return memoryDelete(s, budgetKind, id)
+}
+
+func (s *MemoryStore) ListAzureBudgets() ([]*sharedmodels.AzureBudget, error) {
+	return memoryList(s, budgetKind)
+}
+
+func (s *MemoryStore) CreateAzureMonitoring(monitoring *sharedmodels.AzureMonitoring) (*sharedmodels.AzureMonitoring, error) {
+	return memoryCreate(s, monitoringKind, monitoring)
+}
+
+func (s *MemoryStore) GetAzureMonitoring(id string) (*sharedmodels.AzureMonitoring, error) {
+	return memoryGet(s, monitoringKind, id)
+}
+
+func (s *MemoryStore) UpdateAzureMonitoring(id string, monitoring *sharedmodels.AzureMonitoring) (*sharedmodels.AzureMonitoring, error) {
+	return memoryUpdate(s, monitoringKind, id, monitoring)
+}
+
+func (s *MemoryStore) DeleteAzureMonitoring(id string) error {
+	return memoryDelete(s, monitoringKind, id)
+}
+
+func (s *MemoryStore) ListAzureMonitorings() ([]*sharedmodels.AzureMonitoring, error) {
+	return memoryList(s, monitoringKind)
+}
+
+func (s *MemoryStore) CreateAzureKubernetes(kubernetes *sharedmodels.AzureKubernetes) (*sharedmodels.AzureKubernetes, error) {
+	return memoryCreate(s, kubernetesKind, kubernetes)
+}
+
+func (s *MemoryStore) GetAzureKubernetes(id string) (*sharedmodels.AzureKubernetes, error) {
+	return memoryGet(s, kubernetesKind, id)
+}
+
+func (s *MemoryStore) UpdateAzureKubernetes(id string, kubernetes *sharedmodels.AzureKubernetes) (*sharedmodels.AzureKubernetes, error) {
+	return memoryUpdate(s, kubernetesKind, id, kubernetes)
+}
+
+func (s *MemoryStore) DeleteAzureKubernetes(id string) error {
+	return memoryDelete(s, kubernetesKind, id)
+}
+
+func (s *MemoryStore) ListAzureKubernetes() ([]*sharedmodels.AzureKubernetes, error) {
+	return memoryList(s, kubernetesKind)
+}
+
+// BoltStore: one bucket per resource type, created by the 2 -> 3 migration (nested
+// in the project's bucket for projects other than the default one)
+
+func boltCreate[T any](s *BoltStore, kind azureKind[T], v *T) (*T, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.writeBucket(tx, kind.bucket)
+		if err != nil {
+			return err
+		}
+		id, version, createdAt, updatedAt := kind.fields(v)
+		if *id == "" {
+			*id = uuid.New().String()
+		} else if b.Get([]byte(*id)) != nil {
+			return ErrAlreadyExists
+		}
+		*version = 1
+		*createdAt = time.Now()
+		*updatedAt = time.Now()
+		return boltPut(b, *id, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func boltGetOne[T any](s *BoltStore, kind azureKind[T], id string) (*T, error) {
+	v := new(T)
+	if err := s.db.View(func(tx *bolt.Tx) error { return boltGet(s.readBucket(tx, kind.bucket), id, v) }); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func boltUpdate[T any](s *BoltStore, kind azureKind[T], id string, v *T) (*T, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.writeBucket(tx, kind.bucket)
+		if err != nil {
+			return err
+		}
+		existing := new(T)
+		if err := boltGet(b, id, existing); err != nil {
+			return err
+		}
+		existingID, existingVersion, existingCreatedAt, _ := kind.fields(existing)
+		newID, version, createdAt, updatedAt := kind.fields(v)
+		*newID = *existingID
+		*version = *existingVersion + 1
+		*createdAt = *existingCreatedAt
+		*updatedAt = time.Now()
+		return boltPut(b, id, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func boltDelete(s *BoltStore, bucket []byte, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.writeBucket(tx, bucket)
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+func boltListAll[T any](s *BoltStore, kind azureKind[T]) ([]*T, error) {
+	list, err := boltList[T](s, kind.bucket, nil)
+	if list == nil && err == nil {
+		list = []*T{}
+	}
+	return list, err
+}
+
+func (s *BoltStore) CreateLogAnalyticsWorkspace(workspace *sharedmodels.LogAnalyticsWorkspace) (*sharedmodels.LogAnalyticsWorkspace, error) {
+	return boltCreate(s, logAnalyticsKind, workspace)
+}
+
+func (s *BoltStore) GetLogAnalyticsWorkspace(id string) (*sharedmodels.LogAnalyticsWorkspace, error) {
+	return boltGetOne(s, logAnalyticsKind, id)
+}
+
+func (s *BoltStore) UpdateLogAnalyticsWorkspace(id string, workspace *sharedmodels.LogAnalyticsWorkspace) (*sharedmodels.LogAnalyticsWorkspace, error) {
+	return boltUpdate(s, logAnalyticsKind, id, workspace)
+}
+
+func (s *BoltStore) DeleteLogAnalyticsWorkspace(id string) error {
+	return boltDelete(s, logAnalyticsKind.bucket, id)
+}
+
+func (s *BoltStore) ListLogAnalyticsWorkspaces() ([]*sharedmodels.LogAnalyticsWorkspace, error) {
+	return boltListAll(s, logAnalyticsKind)
+}
+
+func (s *BoltStore) CreateAppInsights(app *sharedmodels.AppInsightsResource) (*sharedmodels.AppInsightsResource, error) {
+	return boltCreate(s, appInsightsKind, app)
+}
+
+func (s *BoltStore) GetAppInsights(id string) (*sharedmodels.AppInsightsResource, error) {
+	return boltGetOne(s, appInsightsKind, id)
+}
+
+func (s *BoltStore) UpdateAppInsights(id string, app *sharedmodels.AppInsightsResource) (*sharedmodels.AppInsightsResource, error) {
+	return boltUpdate(s, appInsightsKind, id, app)
+}
+
+func (s *BoltStore) DeleteAppInsights(id string) error {
+	return boltDelete(s, appInsightsKind.bucket, id)
+}
+
+func (s *BoltStore) ListAppInsights() ([]*sharedmodels.AppInsightsResource, error) {
+	return boltListAll(s, appInsightsKind)
+}
+
+func (s *BoltStore) CreateAzureBudget(budget *sharedmodels.AzureBudget) (*sharedmodels.AzureBudget, error) {
+	return boltCreate(s, budgetKind, budget)
+}
+
+func (s *BoltStore) GetAzureBudget(id string) (*sharedmodels.AzureBudget, error) {
+	return boltGetOne(s, budgetKind, id)
+}
+
+func (s *BoltStore) UpdateAzureBudget(id string, budget *sharedmodels.AzureBudget) (*sharedmodels.AzureBudget, error) {
+	return boltUpdate(s, budgetKind, id, budget)
+}
+
+func (s *BoltStore) DeleteAzureBudget(id string) error {
+	return boltDelete(s, budgetKind.bucket, id)
+}
+
+func (s *BoltStore) ListAzureBudgets() ([]*sharedmodels.AzureBudget, error) {
+	return boltListAll(s, budgetKind)
+}
+
+func (s *BoltStore) CreateAzureMonitoring(monitoring *sharedmodels.AzureMonitoring) (*sharedmodels.AzureMonitoring, error) {
+	return boltCreate(s, monitoringKind, monitoring)
+}
+
+func (s *BoltStore) GetAzureMonitoring(id string) (*sharedmodels.AzureMonitoring, error) {
+	return boltGetOne(s, monitoringKind, id)
+}
+
+func (s *BoltStore) UpdateAzureMonitoring(id string, monitoring *sharedmodels.AzureMonitoring) (*sharedmodels.AzureMonitoring, error) {
+	return boltUpdate(s, monitoringKind, id, monitoring)
+}
+
+func (s *BoltStore) DeleteAzureMonitoring(id string) error {
+	return boltDelete(s, monitoringKind.bucket, id)
+}
+
+func (s *BoltStore) ListAzureMonitorings() ([]*sharedmodels.AzureMonitoring, error) {
+	return boltListAll(s, monitoringKind)
+}
+
+func (s *BoltStore) CreateAzureKubernetes(kubernetes *sharedmodels.AzureKubernetes) (*sharedmodels.AzureKubernetes, error) {
+	return boltCreate(s, kubernetesKind, kubernetes)
+}
+
+func (s *BoltStore) GetAzureKubernetes(id string) (*sharedmodels.AzureKubernetes, error) {
+	return boltGetOne(s, kubernetesKind, id)
+}
+
+func (s *BoltStore) UpdateAzureKubernetes(id string, kubernetes *sharedmodels.AzureKubernetes) (*sharedmodels.AzureKubernetes, error) {
+	return boltUpdate(s, kubernetesKind, id, kubernetes)
+}
+
+func (s *BoltStore) DeleteAzureKubernetes(id string) error {
+	return boltDelete(s, kubernetesKind.bucket, id)
+}
+
+func (s *BoltStore) ListAzureKubernetes() ([]*sharedmodels.AzureKubernetes, error) {
+	return boltListAll(s, kubernetesKind)
+}
@@ -0,0 +1,474 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// SchemaVersion is the layout version written to new bolt databases. Opening a
+// database with an older version runs the missing migrations; a newer version is
+// refused so an old binary cannot corrupt data written by a newer one.
+const SchemaVersion = 5
+
+var (
+	ErrSchemaTooNew = fmt.Errorf("store schema is newer than this binary supports")
+
+	metaBucket        = []byte("meta")
+	clustersBucket    = []byte("clusters")
+	testResultsBucket = []byte("test_results")
+	nodePoolsBucket   = []byte("node_pools")
+	projectsBucket    = []byte("projects")
+	projectDataBucket = []byte("project_data")
+	schemaVersionKey  = []byte("schema_version")
+)
+
+// migrations[i] upgrades a database from schema version i to i+1.
+var migrations = []func(tx *bolt.Tx) error{
+	// 0 -> 1: one bucket per resource type, values are JSON documents keyed by ID
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{clustersBucket, testResultsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 1 -> 2: node pools
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(nodePoolsBucket)
+		return err
+	},
+	// 2 -> 3: Azure resources, one bucket per type (see azure.go)
+	func(tx *bolt.Tx) error {
+		for _, name := range azureBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 3 -> 4: project records, and the resources of every project but the default one
+	// in a nested bucket per project (see project.go)
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{projectsBucket, projectDataBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 4 -> 5: resource versions; the records written before have none and start at 1,
+	// in the default project and in every other one
+	func(tx *bolt.Tx) error {
+		resources := append([][]byte{clustersBucket, testResultsBucket, nodePoolsBucket}, azureBuckets...)
+		if err := setResourceVersions(tx.Bucket(projectsBucket)); err != nil {
+			return err
+		}
+		for _, name := range resources {
+			if err := setResourceVersions(tx.Bucket(name)); err != nil {
+				return err
+			}
+		}
+		projects := tx.Bucket(projectDataBucket)
+		return projects.ForEach(func(project, _ []byte) error {
+			data := projects.Bucket(project)
+			if data == nil {
+				return nil
+			}
+			for _, name := range resources {
+				if err := setResourceVersions(data.Bucket(name)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	},
+}
+
+// BoltStore implements the Store interface on an embedded bbolt database file, so
+// clusters, test results and Azure resources survive a restart.
+type BoltStore struct {
+	db *bolt.DB
+	// project is empty for the default project, whose resources use the top-level buckets
+	project string
+}
+
+// NewBoltStore opens (or creates) the database at path and migrates it to SchemaVersion.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt store %s: %w", path, err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func migrate(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		version := 0
+		if raw := meta.Get(schemaVersionKey); len(raw) == 8 {
+			version = int(binary.BigEndian.Uint64(raw))
+		}
+		if version > SchemaVersion {
+			return fmt.Errorf("%w: database has version %d, supported up to %d", ErrSchemaTooNew, version, SchemaVersion)
+		}
+		for ; version < SchemaVersion; version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("migrate store schema %d -> %d: %w", version, version+1, err)
+			}
+		}
+		return meta.Put(schemaVersionKey, binary.BigEndian.AppendUint64(nil, uint64(version)))
+	})
+}
+
+// setResourceVersions sets resource_version to 1 on the JSON documents of b that
+// have none; b may be nil
+func setResourceVersions(b *bolt.Bucket) error {
+	if b == nil {
+		return nil
+	}
+	updates := make(map[string][]byte)
+	err := b.ForEach(func(k, v []byte) error {
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(v, &doc); err != nil {
+			return fmt.Errorf("record %s: %w", k, err)
+		}
+		if _, ok := doc["resource_version"]; ok {
+			return nil
+		}
+		doc["resource_version"] = json.RawMessage("1")
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		updates[string(k)] = raw
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// the bucket must not be modified while ForEach walks it
+	for k, v := range updates {
+		if err := b.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the schema version recorded in the database.
+func (s *BoltStore) SchemaVersion() (int, error) {
+	version := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if raw := tx.Bucket(metaBucket).Get(schemaVersionKey); len(raw) == 8 {
+			version = int(binary.BigEndian.Uint64(raw))
+		}
+		return nil
+	})
+	return version, err
+}
+
+// Close releases the database file, which every project view of the store shares.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// readBucket returns the bucket of a resource type in the store's project, or nil
+// when the project has not stored any resource of the type yet.
+func (s *BoltStore) readBucket(tx *bolt.Tx, name []byte) *bolt.Bucket {
+	if s.project == "" {
+		return tx.Bucket(name)
+	}
+	project := tx.Bucket(projectDataBucket).Bucket([]byte(s.project))
+	if project == nil {
+		return nil
+	}
+	return project.Bucket(name)
+}
+
+// writeBucket returns the bucket of a resource type in the store's project. The
+// buckets of projects other than the default one are created on first use.
+func (s *BoltStore) writeBucket(tx *bolt.Tx, name []byte) (*bolt.Bucket, error) {
+	if s.project == "" {
+		return tx.Bucket(name), nil
+	}
+	project, err := tx.Bucket(projectDataBucket).CreateBucketIfNotExists([]byte(s.project))
+	if err != nil {
+		return nil, err
+	}
+	return project.CreateBucketIfNotExists(name)
+}
+
+// boltGet decodes the value of id; a nil bucket holds nothing.
+func boltGet(b *bolt.Bucket, id string, v interface{}) error {
+	if b == nil {
+		return ErrNotFound
+	}
+	raw := b.Get([]byte(id))
+	if raw == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func boltPut(b *bolt.Bucket, id string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(id), raw)
+}
+
+// boltList decodes every value of a bucket of the store's project, keeping those
+// accepted by keep (nil keeps all).
+func boltList[T any](s *BoltStore, bucket []byte, keep func(*T) bool) ([]*T, error) {
+	var items []*T
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := s.readBucket(tx, bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, raw []byte) error {
+			item := new(T)
+			if err := json.Unmarshal(raw, item); err != nil {
+				return err
+			}
+			if keep == nil || keep(item) {
+				items = append(items, item)
+			}
+			return nil
+		})
+	})
+	return items, err
+}
+
+// Cluster operations
+func (s *BoltStore) CreateCluster(cluster *sharedmodels.Cluster) (*sharedmodels.Cluster, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.writeBucket(tx, clustersBucket)
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(cluster.ID)) != nil {
+			return ErrAlreadyExists
+		}
+		cluster.ResourceVersion = 1
+		cluster.CreatedAt = time.Now()
+		cluster.UpdatedAt = time.Now()
+		return boltPut(b, cluster.ID, cluster)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func (s *BoltStore) GetCluster(id string) (*sharedmodels.Cluster, error) {
+	var cluster sharedmodels.Cluster
+	if err := s.db.View(func(tx *bolt.Tx) error { return boltGet(s.readBucket(tx, clustersBucket), id, &cluster) }); err != nil {
+		return nil, err
+	}
+	return &cluster, nil
+}
+
+func (s *BoltStore) UpdateCluster(id string, cluster *sharedmodels.Cluster) (*sharedmodels.Cluster, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.writeBucket(tx, clustersBucket)
+		if err != nil {
+			return err
+		}
+		var existing sharedmodels.Cluster
+		if err := boltGet(b, id, &existing); err != nil {
+			return err
+		}
+		cluster.ID = existing.ID
+		cluster.ResourceVersion = existing.ResourceVersion + 1
+		cluster.CreatedAt = existing.CreatedAt
+		cluster.UpdatedAt = time.Now()
+		return boltPut(b, id, cluster)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func (s *BoltStore) DeleteCluster(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.writeBucket(tx, clustersBucket)
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		if err := b.Delete([]byte(id)); err != nil {
+			return err
+		}
+		// Cascade to the cluster's node pools; keys are collected first because a
+		// bucket must not be modified while ForEach walks it
+		pools, err := s.writeBucket(tx, nodePoolsBucket)
+		if err != nil {
+			return err
+		}
+		var poolIDs [][]byte
+		err = pools.ForEach(func(key, raw []byte) error {
+			var pool sharedmodels.NodePool
+			if err := json.Unmarshal(raw, &pool); err != nil {
+				return err
+			}
+			if pool.ClusterID == id {
+				poolIDs = append(poolIDs, append([]byte(nil), key...))
+			}
+			return nil
+		})
+		for _, key := range poolIDs {
+			if err == nil {
+				err = pools.Delete(key)
+			}
+		}
+		return err
+	})
+}
+
+func (s *BoltStore) ListClusters() ([]*sharedmodels.Cluster, error) {
+	clusters, err := boltList[sharedmodels.Cluster](s, clustersBucket, nil)
+	if clusters == nil && err == nil {
+		clusters = []*sharedmodels.Cluster{}
+	}
+	return clusters, err
+}
+
+func (s *BoltStore) ListClustersByProvider(provider sharedmodels.CloudProvider) ([]*sharedmodels.Cluster, error) {
+	return boltList(s, clustersBucket, func(c *sharedmodels.Cluster) bool { return c.Provider == provider })
+}
+
+// Test result operations
+func (s *BoltStore) CreateTestResult(result *sharedmodels.TestResult) (*sharedmodels.TestResult, error) {
+	result.ID = uuid.New().String()
+	result.ResourceVersion = 1
+	result.StartedAt = time.Now()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.writeBucket(tx, testResultsBucket)
+		if err != nil {
+			return err
+		}
+		return boltPut(b, result.ID, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *BoltStore) GetTestResult(id string) (*sharedmodels.TestResult, error) {
+	var result sharedmodels.TestResult
+	if err := s.db.View(func(tx *bolt.Tx) error { return boltGet(s.readBucket(tx, testResultsBucket), id, &result) }); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *BoltStore) UpdateTestResult(id string, result *sharedmodels.TestResult) (*sharedmodels.TestResult, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.writeBucket(tx, testResultsBucket)
+		if err != nil {
+			return err
+		}
+		var existing sharedmodels.TestResult
+		if err := boltGet(b, id, &existing); err != nil {
+			return err
+		}
+		result.ID = existing.ID
+		result.ResourceVersion = existing.ResourceVersion + 1
+		result.StartedAt = existing.StartedAt
+		return boltPut(b, id, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *BoltStore) ListTestResults(clusterID string) ([]*sharedmodels.TestResult, error) {
+	return boltList(s, testResultsBucket, func(r *sharedmodels.TestResult) bool { return r.ClusterID == clusterID })
+}
+
+// Node pool operations
+func (s *BoltStore) CreateNodePool(nodePool *sharedmodels.NodePool) (*sharedmodels.NodePool, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		// Checked in the same transaction, so a pool cannot outlive a concurrent DeleteCluster
+		if clusters := s.readBucket(tx, clustersBucket); clusters == nil || clusters.Get([]byte(nodePool.ClusterID)) == nil {
+			return ErrNotFound
+		}
+		b, err := s.writeBucket(tx, nodePoolsBucket)
+		if err != nil {
+			return err
+		}
+		if nodePool.ID == "" {
+			nodePool.ID = uuid.New().String()
+		} else if b.Get([]byte(nodePool.ID)) != nil {
+			return ErrAlreadyExists
+		}
+		nodePool.ResourceVersion = 1
+		nodePool.CreatedAt = time.Now()
+		nodePool.UpdatedAt = time.Now()
+		return boltPut(b, nodePool.ID, nodePool)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nodePool, nil
+}
+
+func (s *BoltStore) GetNodePool(id string) (*sharedmodels.NodePool, error) {
+	var nodePool sharedmodels.NodePool
+	if err := s.db.View(func(tx *bolt.Tx) error { return boltGet(s.readBucket(tx, nodePoolsBucket), id, &nodePool) }); err != nil {
+		return nil, err
+	}
+	return &nodePool, nil
+}
+
+func (s *BoltStore) UpdateNodePool(id string, nodePool *sharedmodels.NodePool) (*sharedmodels.NodePool, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.writeBucket(tx, nodePoolsBucket)
+		if err != nil {
+			return err
+		}
+		var existing sharedmodels.NodePool
+		if err := boltGet(b, id, &existing); err != nil {
+			return err
+		}
+		nodePool.ID = existing.ID
+		nodePool.ClusterID = existing.ClusterID
+		nodePool.ResourceVersion = existing.ResourceVersion + 1
+		nodePool.CreatedAt = existing.CreatedAt
+		nodePool.UpdatedAt = time.Now()
+		return boltPut(b, id, nodePool)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nodePool, nil
+}
+
+func (s *BoltStore) DeleteNodePool(id string) error {
+	return boltDelete(s, nodePoolsBucket, id)
+}
+
+func (s *BoltStore) ListNodePools(clusterID string) ([]*sharedmodels.NodePool, error) {
+	return boltList(s, nodePoolsBucket, func(p *sharedmodels.NodePool) bool { return p.ClusterID == clusterID })
+}
@@ -7,4 +7,7 @@ toolchain go1.24.4
 require (
 	github.com/google/uuid v1.6.0
 	github.com/tronicum/punchbag-cube-testsuite/shared v0.1.2
+	go.etcd.io/bbolt v1.4.3
 )
+
+require golang.org/x/sys v0.32.0 // indirect
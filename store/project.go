@@ -0,0 +1,220 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ProjectStore keeps the projects (tenants) and hands out a view of the store per
+// project. Project records are shared by all views; resources are not, so a
+// cluster, test result, node pool or Azure resource is only visible through the
+// view of the project it was created in, and IDs may repeat across projects.
+type ProjectStore interface {
+	CreateProject(project *sharedmodels.Project) (*sharedmodels.Project, error)
+	GetProject(name string) (*sharedmodels.Project, error)
+	UpdateProject(name string, project *sharedmodels.Project) (*sharedmodels.Project, error)
+	// DeleteProject deletes the record and the resources of a project; the
+	// resources of the default project are kept
+	DeleteProject(name string) error
+	ListProjects() ([]*sharedmodels.Project, error)
+
+	// Project returns the view of a project's resources. The default project ("" or
+	// sharedmodels.DefaultProject) is the store itself, so resources stored before
+	// projects existed belong to it. The view exists whether or not the project
+	// has a record; callers check that first.
+	Project(name string) Store
+}
+
+func isDefaultProject(name string) bool {
+	return name == "" || name == sharedmodels.DefaultProject
+}
+
+// MemoryStore: the root store keeps the project records and one MemoryStore per
+// project other than the default one
+
+func (s *MemoryStore) rootStore() *MemoryStore {
+	if s.root != nil {
+		return s.root
+	}
+	return s
+}
+
+func (s *MemoryStore) CreateProject(project *sharedmodels.Project) (*sharedmodels.Project, error) {
+	root := s.rootStore()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	if _, exists := root.projects[project.Name]; exists {
+		return nil, ErrAlreadyExists
+	}
+
+	project.ResourceVersion = 1
+	project.CreatedAt = time.Now()
+	project.UpdatedAt = time.Now()
+	root.projects[project.Name] = project
+	return project, nil
+}
+
+func (s *MemoryStore) GetProject(name string) (*sharedmodels.Project, error) {
+	root := s.rootStore()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+
+	project, exists := root.projects[name]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return project, nil
+}
+
+func (s *MemoryStore) UpdateProject(name string, project *sharedmodels.Project) (*sharedmodels.Project, error) {
+	root := s.rootStore()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	existing, exists := root.projects[name]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	project.Name = existing.Name
+	project.ResourceVersion = existing.ResourceVersion + 1
+	project.CreatedAt = existing.CreatedAt
+	project.UpdatedAt = time.Now()
+	root.projects[name] = project
+	return project, nil
+}
+
+func (s *MemoryStore) DeleteProject(name string) error {
+	root := s.rootStore()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	if _, exists := root.projects[name]; !exists {
+		return ErrNotFound
+	}
+	delete(root.projects, name)
+	delete(root.scoped, name)
+	return nil
+}
+
+func (s *MemoryStore) ListProjects() ([]*sharedmodels.Project, error) {
+	root := s.rootStore()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+
+	projects := make([]*sharedmodels.Project, 0, len(root.projects))
+	for _, project := range root.projects {
+		projects = append(projects, project)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+func (s *MemoryStore) Project(name string) Store {
+	root := s.rootStore()
+	if isDefaultProject(name) {
+		return root
+	}
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	scoped, exists := root.scoped[name]
+	if !exists {
+		scoped = NewMemoryStore()
+		scoped.root = root
+		root.scoped[name] = scoped
+	}
+	return scoped
+}
+
+// BoltStore: project records in the projects bucket, the resources of a project in
+// project_data/<name>, both created by the 3 -> 4 migration
+
+func (s *BoltStore) CreateProject(project *sharedmodels.Project) (*sharedmodels.Project, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(projectsBucket)
+		if b.Get([]byte(project.Name)) != nil {
+			return ErrAlreadyExists
+		}
+		project.ResourceVersion = 1
+		project.CreatedAt = time.Now()
+		project.UpdatedAt = time.Now()
+		return boltPut(b, project.Name, project)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func (s *BoltStore) GetProject(name string) (*sharedmodels.Project, error) {
+	var project sharedmodels.Project
+	if err := s.db.View(func(tx *bolt.Tx) error { return boltGet(tx.Bucket(projectsBucket), name, &project) }); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (s *BoltStore) UpdateProject(name string, project *sharedmodels.Project) (*sharedmodels.Project, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(projectsBucket)
+		var existing sharedmodels.Project
+		if err := boltGet(b, name, &existing); err != nil {
+			return err
+		}
+		project.Name = existing.Name
+		project.ResourceVersion = existing.ResourceVersion + 1
+		project.CreatedAt = existing.CreatedAt
+		project.UpdatedAt = time.Now()
+		return boltPut(b, name, project)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func (s *BoltStore) DeleteProject(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(projectsBucket)
+		if b.Get([]byte(name)) == nil {
+			return ErrNotFound
+		}
+		if err := b.Delete([]byte(name)); err != nil {
+			return err
+		}
+		data := tx.Bucket(projectDataBucket)
+		if isDefaultProject(name) || data.Bucket([]byte(name)) == nil {
+			return nil
+		}
+		return data.DeleteBucket([]byte(name))
+	})
+}
+
+func (s *BoltStore) ListProjects() ([]*sharedmodels.Project, error) {
+	projects := []*sharedmodels.Project{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// bolt iterates keys in byte order, so the list is sorted by name
+		return tx.Bucket(projectsBucket).ForEach(func(_, raw []byte) error {
+			project := new(sharedmodels.Project)
+			if err := json.Unmarshal(raw, project); err != nil {
+				return err
+			}
+			projects = append(projects, project)
+			return nil
+		})
+	})
+	return projects, err
+}
+
+func (s *BoltStore) Project(name string) Store {
+	if isDefaultProject(name) {
+		name = ""
+	}
+	return &BoltStore{db: s.db, project: name}
+}
@@ -0,0 +1,396 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrInvalidSnapshot is returned by Restore for snapshots that cannot be loaded; the
+// store is left unchanged
+var ErrInvalidSnapshot = errors.New("invalid store snapshot")
+
+// SnapshotStore exports and replaces the whole content of a store, so a server can be
+// reset to a known state. Both operations cover every project whichever project view
+// they are called on.
+type SnapshotStore interface {
+	// Snapshot returns the project records and the resources of every project. The
+	// snapshot shares nothing with the store.
+	Snapshot() (*Snapshot, error)
+	// Restore replaces the content of the store with a snapshot, all or nothing
+	Restore(snapshot *Snapshot) error
+}
+
+// Snapshot is the content of a store. Resources are keyed by project name, the default
+// project's under sharedmodels.DefaultProject; timestamps are kept as they are.
+type Snapshot struct {
+	Projects  []*sharedmodels.Project      `json:"projects"`
+	Resources map[string]*ProjectResources `json:"resources"`
+}
+
+// ProjectResources are the resources of one project, each collection ordered by ID
+type ProjectResources struct {
+	Clusters               []*sharedmodels.Cluster               `json:"clusters,omitempty"`
+	TestResults            []*sharedmodels.TestResult            `json:"test_results,omitempty"`
+	NodePools              []*sharedmodels.NodePool              `json:"node_pools,omitempty"`
+	LogAnalyticsWorkspaces []*sharedmodels.LogAnalyticsWorkspace `json:"log_analytics_workspaces,omitempty"`
+	AppInsights            []*sharedmodels.AppInsightsResource   `json:"app_insights,omitempty"`
+	AzureBudgets           []*sharedmodels.AzureBudget           `json:"azure_budgets,omitempty"`
+	AzureMonitorings       []*sharedmodels.AzureMonitoring       `json:"azure_monitorings,omitempty"`
+	AzureKubernetes        []*sharedmodels.AzureKubernetes       `json:"azure_kubernetes,omitempty"`
+}
+
+// collections pairs every collection with its bolt bucket, which the bolt store reads
+// and writes as JSON documents keyed by their "id"
+func (r *ProjectResources) collections() []struct {
+	bucket []byte
+	items  interface{}
+} {
+	return []struct {
+		bucket []byte
+		items  interface{}
+	}{
+		{clustersBucket, &r.Clusters},
+		{testResultsBucket, &r.TestResults},
+		{nodePoolsBucket, &r.NodePools},
+		{logAnalyticsKind.bucket, &r.LogAnalyticsWorkspaces},
+		{appInsightsKind.bucket, &r.AppInsights},
+		{budgetKind.bucket, &r.AzureBudgets},
+		{monitoringKind.bucket, &r.AzureMonitorings},
+		{kubernetesKind.bucket, &r.AzureKubernetes},
+	}
+}
+
+// Validate checks that every resource has an ID that is unique in its collection and
+// project, that node pools and test results refer to clusters of their project, and
+// that resources belong to the default project or one with a record.
+func (snapshot *Snapshot) Validate() error {
+	projects := make(map[string]bool, len(snapshot.Projects))
+	for _, project := range snapshot.Projects {
+		if project == nil || project.Name == "" {
+			return fmt.Errorf("%w: project without a name", ErrInvalidSnapshot)
+		}
+		if projects[project.Name] {
+			return fmt.Errorf("%w: duplicate project %q", ErrInvalidSnapshot, project.Name)
+		}
+		projects[project.Name] = true
+	}
+	for name, resources := range snapshot.Resources {
+		if !isDefaultProject(name) && !projects[name] {
+			return fmt.Errorf("%w: resources of unknown project %q", ErrInvalidSnapshot, name)
+		}
+		if resources == nil {
+			continue
+		}
+		for _, collection := range resources.collections() {
+			raw, err := json.Marshal(collection.items)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+			}
+			if _, err := documentIDs(raw); err != nil {
+				return fmt.Errorf("%w: project %q, %s: %v", ErrInvalidSnapshot, name, collection.bucket, err)
+			}
+		}
+		clusters := make(map[string]bool, len(resources.Clusters))
+		for _, cluster := range resources.Clusters {
+			clusters[cluster.ID] = true
+		}
+		for _, pool := range resources.NodePools {
+			if !clusters[pool.ClusterID] {
+				return fmt.Errorf("%w: project %q: node pool %q of unknown cluster %q", ErrInvalidSnapshot, name, pool.ID, pool.ClusterID)
+			}
+		}
+		for _, result := range resources.TestResults {
+			if result.ClusterID != "" && !clusters[result.ClusterID] {
+				return fmt.Errorf("%w: project %q: test result %q of unknown cluster %q", ErrInvalidSnapshot, name, result.ID, result.ClusterID)
+			}
+		}
+	}
+	return nil
+}
+
+// documentIDs splits a JSON array of documents and returns them by their "id", which
+// must be set and unique
+func documentIDs(raw []byte) (map[string]json.RawMessage, error) {
+	var documents []json.RawMessage
+	if err := json.Unmarshal(raw, &documents); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]json.RawMessage, len(documents))
+	for _, document := range documents {
+		var key struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(document, &key); err != nil {
+			return nil, err
+		}
+		if key.ID == "" {
+			return nil, errors.New("resource without an id")
+		}
+		if _, exists := byID[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate id %q", key.ID)
+		}
+		byID[key.ID] = document
+	}
+	return byID, nil
+}
+
+// copyJSON returns a deep copy of v, so callers may change either
+func copyJSON[T any](v *T) (*T, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	copied := new(T)
+	return copied, json.Unmarshal(raw, copied)
+}
+
+// MemoryStore
+
+func (s *MemoryStore) Snapshot() (*Snapshot, error) {
+	root := s.rootStore()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+
+	snapshot := &Snapshot{Projects: make([]*sharedmodels.Project, 0, len(root.projects)), Resources: map[string]*ProjectResources{}}
+	for _, project := range root.projects {
+		snapshot.Projects = append(snapshot.Projects, project)
+	}
+	sort.Slice(snapshot.Projects, func(i, j int) bool { return snapshot.Projects[i].Name < snapshot.Projects[j].Name })
+	snapshot, err := copyJSON(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Resources[sharedmodels.DefaultProject], err = copyJSON(root.resources()); err != nil {
+		return nil, err
+	}
+	for name, scoped := range root.scoped {
+		// Every project view locks its own resources
+		scoped.mu.RLock()
+		snapshot.Resources[name], err = copyJSON(scoped.resources())
+		scoped.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// resources lists the resources of the store; the caller holds s.mu
+func (s *MemoryStore) resources() *ProjectResources {
+	return &ProjectResources{
+		Clusters:               memoryValues(s.clusters, func(c *sharedmodels.Cluster) string { return c.ID }),
+		TestResults:            memoryValues(s.testResults, func(r *sharedmodels.TestResult) string { return r.ID }),
+		NodePools:              memoryValues(s.nodePools, func(p *sharedmodels.NodePool) string { return p.ID }),
+		LogAnalyticsWorkspaces: memoryAzureValues(s, logAnalyticsKind),
+		AppInsights:            memoryAzureValues(s, appInsightsKind),
+		AzureBudgets:           memoryAzureValues(s, budgetKind),
+		AzureMonitorings:       memoryAzureValues(s, monitoringKind),
+		AzureKubernetes:        memoryAzureValues(s, kubernetesKind),
+	}
+}
+
+func memoryValues[T any](items map[string]*T, id func(*T) string) []*T {
+	list := make([]*T, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return id(list[i]) < id(list[j]) })
+	return list
+}
+
+func memoryAzureValues[T any](s *MemoryStore, kind azureKind[T]) []*T {
+	items := make(map[string]*T, len(s.azure[string(kind.bucket)]))
+	for id, item := range s.azure[string(kind.bucket)] {
+		items[id] = item.(*T)
+	}
+	return memoryValues(items, func(item *T) string {
+		id, _, _, _ := kind.fields(item)
+		return *id
+	})
+}
+
+func (s *MemoryStore) Restore(snapshot *Snapshot) error {
+	if err := snapshot.Validate(); err != nil {
+		return err
+	}
+	snapshot, err := copyJSON(snapshot)
+	if err != nil {
+		return err
+	}
+	// Build the new content first, so the swap below cannot fail halfway
+	stores := make(map[string]*MemoryStore, len(snapshot.Resources))
+	for name, resources := range snapshot.Resources {
+		if isDefaultProject(name) {
+			name = sharedmodels.DefaultProject
+		}
+		scoped := NewMemoryStore()
+		if resources != nil {
+			scoped.load(resources)
+		}
+		stores[name] = scoped
+	}
+	projects := make(map[string]*sharedmodels.Project, len(snapshot.Projects))
+	for _, project := range snapshot.Projects {
+		projects[project.Name] = project
+	}
+
+	root := s.rootStore()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	content := stores[sharedmodels.DefaultProject]
+	delete(stores, sharedmodels.DefaultProject)
+	if content == nil {
+		content = NewMemoryStore()
+	}
+	root.clusters, root.testResults, root.nodePools, root.azure = content.clusters, content.testResults, content.nodePools, content.azure
+	for _, scoped := range stores {
+		scoped.root = root
+	}
+	root.projects, root.scoped = projects, stores
+	return nil
+}
+
+// load fills an empty store with resources
+func (s *MemoryStore) load(resources *ProjectResources) {
+	for _, cluster := range resources.Clusters {
+		s.clusters[cluster.ID] = cluster
+	}
+	for _, result := range resources.TestResults {
+		s.testResults[result.ID] = result
+	}
+	for _, pool := range resources.NodePools {
+		s.nodePools[pool.ID] = pool
+	}
+	memoryAzureLoad(s, logAnalyticsKind, resources.LogAnalyticsWorkspaces)
+	memoryAzureLoad(s, appInsightsKind, resources.AppInsights)
+	memoryAzureLoad(s, budgetKind, resources.AzureBudgets)
+	memoryAzureLoad(s, monitoringKind, resources.AzureMonitorings)
+	memoryAzureLoad(s, kubernetesKind, resources.AzureKubernetes)
+}
+
+func memoryAzureLoad[T any](s *MemoryStore, kind azureKind[T], items []*T) {
+	for _, item := range items {
+		id, _, _, _ := kind.fields(item)
+		s.azure[string(kind.bucket)][*id] = item
+	}
+}
+
+// BoltStore: a snapshot is read in one transaction and restored in one, which
+// replaces the resource, project and project data buckets
+
+func (s *BoltStore) Snapshot() (*Snapshot, error) {
+	snapshot := &Snapshot{Projects: []*sharedmodels.Project{}, Resources: map[string]*ProjectResources{}}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(projectsBucket).ForEach(func(_, raw []byte) error {
+			project := new(sharedmodels.Project)
+			snapshot.Projects = append(snapshot.Projects, project)
+			return json.Unmarshal(raw, project)
+		})
+		if err != nil {
+			return err
+		}
+		if snapshot.Resources[sharedmodels.DefaultProject], err = boltResources(tx.Bucket); err != nil {
+			return err
+		}
+		return tx.Bucket(projectDataBucket).ForEach(func(name, _ []byte) error {
+			data := tx.Bucket(projectDataBucket).Bucket(name)
+			if data == nil {
+				return nil
+			}
+			resources, err := boltResources(data.Bucket)
+			snapshot.Resources[string(name)] = resources
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// boltResources reads the resource buckets returned by bucket, which are nil for
+// resource types a project has not stored yet
+func boltResources(bucket func(name []byte) *bolt.Bucket) (*ProjectResources, error) {
+	resources := new(ProjectResources)
+	for _, collection := range resources.collections() {
+		documents := []json.RawMessage{}
+		if b := bucket(collection.bucket); b != nil {
+			err := b.ForEach(func(_, raw []byte) error {
+				documents = append(documents, append(json.RawMessage(nil), raw...))
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		raw, err := json.Marshal(documents)
+		if err == nil {
+			err = json.Unmarshal(raw, collection.items)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+func (s *BoltStore) Restore(snapshot *Snapshot) error {
+	if err := snapshot.Validate(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		replaced := append([][]byte{clustersBucket, testResultsBucket, nodePoolsBucket, projectsBucket, projectDataBucket}, azureBuckets...)
+		for _, name := range replaced {
+			if err := tx.DeleteBucket(name); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		projects := tx.Bucket(projectsBucket)
+		for _, project := range snapshot.Projects {
+			if err := boltPut(projects, project.Name, project); err != nil {
+				return err
+			}
+		}
+		for name, resources := range snapshot.Resources {
+			if resources == nil {
+				continue
+			}
+			bucket := func(collection []byte) (*bolt.Bucket, error) { return tx.Bucket(collection), nil }
+			if !isDefaultProject(name) {
+				data, err := tx.Bucket(projectDataBucket).CreateBucket([]byte(name))
+				if err != nil {
+					return err
+				}
+				bucket = data.CreateBucketIfNotExists
+			}
+			for _, collection := range resources.collections() {
+				raw, err := json.Marshal(collection.items)
+				if err != nil {
+					return err
+				}
+				documents, err := documentIDs(raw)
+				if err != nil {
+					return err
+				}
+				b, err := bucket(collection.bucket)
+				if err != nil {
+					return err
+				}
+				for id, document := range documents {
+					if err := b.Put([]byte(id), document); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
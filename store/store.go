@@ -14,7 +14,7 @@ var (
 	ErrAlreadyExists = fmt.Errorf("resource already exists")
 )
 
-// Store defines the interface for cluster and test result storage
+// Store defines the interface for cluster, test result and Azure resource storage
 type Store interface {
 	// Cluster operations
 	CreateCluster(cluster *sharedmodels.Cluster) (*sharedmodels.Cluster, error)
@@ -29,6 +29,23 @@ type Store interface {
 	GetTestResult(id string) (*sharedmodels.TestResult, error)
 	UpdateTestResult(id string, result *sharedmodels.TestResult) (*sharedmodels.TestResult, error)
 	ListTestResults(clusterID string) ([]*sharedmodels.TestResult, error)
+
+	// Node pool operations; a node pool is only created for an existing cluster
+	// (ErrNotFound otherwise) and deleting a cluster deletes its node pools
+	CreateNodePool(nodePool *sharedmodels.NodePool) (*sharedmodels.NodePool, error)
+	GetNodePool(id string) (*sharedmodels.NodePool, error)
+	UpdateNodePool(id string, nodePool *sharedmodels.NodePool) (*sharedmodels.NodePool, error)
+	DeleteNodePool(id string) error
+	ListNodePools(clusterID string) ([]*sharedmodels.NodePool, error)
+
+	// Azure monitoring, Kubernetes and budget resources (see azure.go)
+	AzureStore
+
+	// Projects and the project views of the store (see project.go)
+	ProjectStore
+
+	// Export and replacement of the whole store (see snapshot.go)
+	SnapshotStore
 }
 
 // MemoryStore implements the Store interface using in-memory storage
@@ -36,14 +53,52 @@ type MemoryStore struct {
 	mu          sync.RWMutex
 	clusters    map[string]*sharedmodels.Cluster
 	testResults map[string]*sharedmodels.TestResult
+	nodePools   map[string]*sharedmodels.NodePool
+	// azure holds the Azure resources by bucket name and ID (see azure.go)
+	azure map[string]map[string]interface{}
+
+	// root is the default project's store, which holds the project records and the
+	// stores of the other projects; it is nil for the root itself (see project.go)
+	root     *MemoryStore
+	projects map[string]*sharedmodels.Project
+	scoped   map[string]*MemoryStore
 }
 
 // NewMemoryStore creates a new in-memory store
 func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{
+	s := &MemoryStore{
 		clusters:    make(map[string]*sharedmodels.Cluster),
 		testResults: make(map[string]*sharedmodels.TestResult),
+		nodePools:   make(map[string]*sharedmodels.NodePool),
+		azure:       make(map[string]map[string]interface{}, len(azureBuckets)),
+		projects:    make(map[string]*sharedmodels.Project),
+		scoped:      make(map[string]*MemoryStore),
 	}
+	for _, bucket := range azureBuckets {
+		s.azure[string(bucket)] = make(map[string]interface{})
+	}
+	return s
+}
+
+// Store backends accepted by Open
+const (
+	BackendMemory = "memory"
+	BackendBolt   = "bolt"
+)
+
+// Open returns the store for a backend name; path is the database file of the bolt
+// backend. An empty backend selects the memory store.
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case "", BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendBolt:
+		if path == "" {
+			return nil, fmt.Errorf("the %s store backend needs a database path", BackendBolt)
+		}
+		return NewBoltStore(path)
+	}
+	return nil, fmt.Errorf("unknown store backend %q (want %s or %s)", backend, BackendMemory, BackendBolt)
 }
 
 // Cluster operations
@@ -55,6 +110,7 @@ func (s *MemoryStore) CreateCluster(cluster *sharedmodels.Cluster) (*sharedmodel
 		return nil, ErrAlreadyExists
 	}
 
+	cluster.ResourceVersion = 1
 	cluster.CreatedAt = time.Now()
 	cluster.UpdatedAt = time.Now()
 	s.clusters[cluster.ID] = cluster
@@ -83,6 +139,7 @@ func (s *MemoryStore) UpdateCluster(id string, cluster *sharedmodels.Cluster) (*
 	}
 
 	cluster.ID = existing.ID
+	cluster.ResourceVersion = existing.ResourceVersion + 1
 	cluster.CreatedAt = existing.CreatedAt
 	cluster.UpdatedAt = time.Now()
 
@@ -99,6 +156,11 @@ func (s *MemoryStore) DeleteCluster(id string) error {
 	}
 
 	delete(s.clusters, id)
+	for poolID, pool := range s.nodePools {
+		if pool.ClusterID == id {
+			delete(s.nodePools, poolID)
+		}
+	}
 	return nil
 }
 
@@ -134,6 +196,7 @@ func (s *MemoryStore) CreateTestResult(result *sharedmodels.TestResult) (*shared
 	defer s.mu.Unlock()
 
 	result.ID = uuid.New().String()
+	result.ResourceVersion = 1
 	result.StartedAt = time.Now()
 
 	s.testResults[result.ID] = result
@@ -162,8 +225,8 @@ func (s *MemoryStore) UpdateTestResult(id string, result *sharedmodels.TestResul
 	}
 
 	result.ID = existing.ID
+	result.ResourceVersion = existing.ResourceVersion + 1
 	result.StartedAt = existing.StartedAt
-	result.CompletedAt = result.CompletedAt
 
 	s.testResults[id] = result
 	return result, nil
@@ -181,3 +244,80 @@ func (s *MemoryStore) ListTestResults(clusterID string) ([]*sharedmodels.TestRes
 	}
 	return results, nil
 }
+
+// Node pool operations
+func (s *MemoryStore) CreateNodePool(nodePool *sharedmodels.NodePool) (*sharedmodels.NodePool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.clusters[nodePool.ClusterID]; !exists {
+		return nil, ErrNotFound
+	}
+	if nodePool.ID == "" {
+		nodePool.ID = uuid.New().String()
+	} else if _, exists := s.nodePools[nodePool.ID]; exists {
+		return nil, ErrAlreadyExists
+	}
+
+	nodePool.ResourceVersion = 1
+	nodePool.CreatedAt = time.Now()
+	nodePool.UpdatedAt = time.Now()
+	s.nodePools[nodePool.ID] = nodePool
+	return nodePool, nil
+}
+
+func (s *MemoryStore) GetNodePool(id string) (*sharedmodels.NodePool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nodePool, exists := s.nodePools[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	return nodePool, nil
+}
+
+func (s *MemoryStore) UpdateNodePool(id string, nodePool *sharedmodels.NodePool) (*sharedmodels.NodePool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.nodePools[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	nodePool.ID = existing.ID
+	nodePool.ClusterID = existing.ClusterID
+	nodePool.ResourceVersion = existing.ResourceVersion + 1
+	nodePool.CreatedAt = existing.CreatedAt
+	nodePool.UpdatedAt = time.Now()
+
+	s.nodePools[id] = nodePool
+	return nodePool, nil
+}
+
+func (s *MemoryStore) DeleteNodePool(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.nodePools[id]; !exists {
+		return ErrNotFound
+	}
+
+	delete(s.nodePools, id)
+	return nil
+}
+
+func (s *MemoryStore) ListNodePools(clusterID string) ([]*sharedmodels.NodePool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var nodePools []*sharedmodels.NodePool
+	for _, nodePool := range s.nodePools {
+		if nodePool.ClusterID == clusterID {
+			nodePools = append(nodePools, nodePool)
+		}
+	}
+	return nodePools, nil
+}
@@ -0,0 +1,211 @@
+package store_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+	"github.com/tronicum/punchbag-cube-testsuite/store/storetest"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newBoltStore(t *testing.T, path string) *store.BoltStore {
+	t.Helper()
+	s, err := store.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	return s
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store { return store.NewMemoryStore() })
+}
+
+func TestBoltStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s := newBoltStore(t, filepath.Join(t.TempDir(), "cube.db"))
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestBoltStorePersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cube.db")
+	s := newBoltStore(t, path)
+	if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "durable", Provider: sharedmodels.Hetzner}); err != nil {
+		t.Fatalf("CreateCluster: %v", err)
+	}
+	result, err := s.CreateTestResult(&sharedmodels.TestResult{ClusterID: "c-1", TestType: "load"})
+	if err != nil {
+		t.Fatalf("CreateTestResult: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s = newBoltStore(t, path)
+	defer s.Close()
+	if c, err := s.GetCluster("c-1"); err != nil || c.Name != "durable" {
+		t.Errorf("GetCluster after reopen = %+v, %v", c, err)
+	}
+	if r, err := s.GetTestResult(result.ID); err != nil || r.ClusterID != "c-1" {
+		t.Errorf("GetTestResult after reopen = %+v, %v", r, err)
+	}
+}
+
+func TestBoltStoreSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cube.db")
+	s := newBoltStore(t, path)
+	if v, err := s.SchemaVersion(); err != nil || v != store.SchemaVersion {
+		t.Errorf("SchemaVersion = %d, %v; want %d", v, err, store.SchemaVersion)
+	}
+	s.Close()
+
+	// A database written by a newer binary must be refused
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatalf("bolt.Open: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("meta")).Put([]byte("schema_version"), binary.BigEndian.AppendUint64(nil, store.SchemaVersion+1))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("bump schema version: %v", err)
+	}
+	if _, err := store.NewBoltStore(path); !errors.Is(err, store.ErrSchemaTooNew) {
+		t.Errorf("NewBoltStore on a newer schema: expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestBoltStoreMigratesVersion1(t *testing.T) {
+	// A version 1 database has neither the node pool nor the Azure buckets
+	path := filepath.Join(t.TempDir(), "cube.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatalf("bolt.Open: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{"meta", "clusters", "test_results"} {
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket([]byte("meta")).Put([]byte("schema_version"), binary.BigEndian.AppendUint64(nil, 1))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("write version 1 database: %v", err)
+	}
+
+	s := newBoltStore(t, path)
+	defer s.Close()
+	if v, err := s.SchemaVersion(); err != nil || v != store.SchemaVersion {
+		t.Errorf("SchemaVersion after migration = %d, %v; want %d", v, err, store.SchemaVersion)
+	}
+	if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "primary"}); err != nil {
+		t.Fatalf("CreateCluster after migration: %v", err)
+	}
+	if _, err := s.CreateNodePool(&sharedmodels.NodePool{ClusterID: "c-1", Name: "system"}); err != nil {
+		t.Errorf("CreateNodePool after migration: %v", err)
+	}
+	if _, err := s.CreateAzureBudget(&sharedmodels.AzureBudget{Name: "team"}); err != nil {
+		t.Errorf("CreateAzureBudget after migration: %v", err)
+	}
+	if _, err := s.CreateProject(&sharedmodels.Project{Name: "team-a"}); err != nil {
+		t.Errorf("CreateProject after migration: %v", err)
+	}
+	if _, err := s.Project("team-a").CreateCluster(&sharedmodels.Cluster{ID: "c-1"}); err != nil {
+		t.Errorf("CreateCluster in a project after migration: %v", err)
+	}
+}
+
+func TestBoltStoreMigratesVersion4(t *testing.T) {
+	// Version 4 records have no resource version
+	path := filepath.Join(t.TempDir(), "cube.db")
+	newBoltStore(t, path).Close()
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatalf("bolt.Open: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, record := range []struct{ bucket, key, doc string }{
+			{"clusters", "c-1", `{"id":"c-1","name":"old"}`},
+			{"clusters", "c-2", `{"id":"c-2","resource_version":3}`},
+			{"test_results", "r-1", `{"id":"r-1","cluster_id":"c-1"}`},
+			{"node_pools", "p-1", `{"id":"p-1","cluster_id":"c-1","name":"system"}`},
+			{"azure_budgets", "b-1", `{"id":"b-1","name":"team"}`},
+			{"projects", "team-a", `{"name":"team-a"}`},
+		} {
+			if err := tx.Bucket([]byte(record.bucket)).Put([]byte(record.key), []byte(record.doc)); err != nil {
+				return err
+			}
+		}
+		teamA, err := tx.Bucket([]byte("project_data")).CreateBucket([]byte("team-a"))
+		if err != nil {
+			return err
+		}
+		clusters, err := teamA.CreateBucket([]byte("clusters"))
+		if err != nil {
+			return err
+		}
+		if err := clusters.Put([]byte("c-3"), []byte(`{"id":"c-3"}`)); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("meta")).Put([]byte("schema_version"), binary.BigEndian.AppendUint64(nil, 4))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("write version 4 database: %v", err)
+	}
+
+	s := newBoltStore(t, path)
+	defer s.Close()
+	if v, err := s.SchemaVersion(); err != nil || v != store.SchemaVersion {
+		t.Errorf("SchemaVersion after migration = %d, %v; want %d", v, err, store.SchemaVersion)
+	}
+	if c, err := s.GetCluster("c-1"); err != nil || c.ResourceVersion != 1 || c.Name != "old" {
+		t.Errorf("GetCluster after migration = %+v, %v", c, err)
+	}
+	if c, err := s.GetCluster("c-2"); err != nil || c.ResourceVersion != 3 {
+		t.Errorf("GetCluster with a version after migration = %+v, %v", c, err)
+	}
+	if r, err := s.GetTestResult("r-1"); err != nil || r.ResourceVersion != 1 {
+		t.Errorf("GetTestResult after migration = %+v, %v", r, err)
+	}
+	if p, err := s.GetNodePool("p-1"); err != nil || p.ResourceVersion != 1 {
+		t.Errorf("GetNodePool after migration = %+v, %v", p, err)
+	}
+	if b, err := s.GetAzureBudget("b-1"); err != nil || b.ResourceVersion != 1 {
+		t.Errorf("GetAzureBudget after migration = %+v, %v", b, err)
+	}
+	if p, err := s.GetProject("team-a"); err != nil || p.ResourceVersion != 1 {
+		t.Errorf("GetProject after migration = %+v, %v", p, err)
+	}
+	if c, err := s.Project("team-a").GetCluster("c-3"); err != nil || c.ResourceVersion != 1 {
+		t.Errorf("GetCluster in a project after migration = %+v, %v", c, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if s, err := store.Open("", ""); err != nil {
+		t.Errorf("Open default: %v", err)
+	} else if _, ok := s.(*store.MemoryStore); !ok {
+		t.Errorf("Open default = %T, want *store.MemoryStore", s)
+	}
+	s, err := store.Open(store.BackendBolt, filepath.Join(t.TempDir(), "cube.db"))
+	if err != nil {
+		t.Fatalf("Open bolt: %v", err)
+	}
+	s.(*store.BoltStore).Close()
+	if _, err := store.Open(store.BackendBolt, ""); err == nil {
+		t.Error("Open bolt without a path succeeded")
+	}
+	if _, err := store.Open("postgres", ""); err == nil {
+		t.Error("Open with an unknown backend succeeded")
+	}
+}
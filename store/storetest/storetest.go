@@ -0,0 +1,655 @@
+// Package storetest is a conformance suite for store.Store implementations. Every
+// backend runs the same CRUD and error checks, so they stay interchangeable.
+package storetest
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+	"github.com/tronicum/punchbag-cube-testsuite/store"
+)
+
+// Run runs the conformance suite. newStore must return an empty store for every call.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	t.Run("Clusters", func(t *testing.T) { testClusters(t, newStore(t)) })
+	t.Run("ClusterNotFound", func(t *testing.T) { testClusterNotFound(t, newStore(t)) })
+	t.Run("ListClustersByProvider", func(t *testing.T) { testListClustersByProvider(t, newStore(t)) })
+	t.Run("TestResults", func(t *testing.T) { testTestResults(t, newStore(t)) })
+	t.Run("TestResultNotFound", func(t *testing.T) { testTestResultNotFound(t, newStore(t)) })
+	t.Run("NodePools", func(t *testing.T) { testNodePools(t, newStore(t)) })
+	t.Run("NodePoolNotFound", func(t *testing.T) { testNodePoolNotFound(t, newStore(t)) })
+	t.Run("DeleteClusterCascadesToNodePools", func(t *testing.T) { testDeleteClusterCascade(t, newStore(t)) })
+	t.Run("AzureBudgets", func(t *testing.T) { testAzureBudgets(t, newStore(t)) })
+	t.Run("AzureResources", func(t *testing.T) { testAzureResources(t, newStore(t)) })
+	t.Run("Projects", func(t *testing.T) { testProjects(t, newStore(t)) })
+	t.Run("ProjectIsolation", func(t *testing.T) { testProjectIsolation(t, newStore(t)) })
+	t.Run("SnapshotRestore", func(t *testing.T) { testSnapshotRestore(t, newStore(t)) })
+	t.Run("ResourceVersions", func(t *testing.T) { testResourceVersions(t, newStore(t)) })
+}
+
+func testClusters(t *testing.T, s store.Store) {
+	if clusters, err := s.ListClusters(); err != nil || len(clusters) != 0 {
+		t.Fatalf("ListClusters on an empty store = %v, %v", clusters, err)
+	}
+	created, err := s.CreateCluster(&sharedmodels.Cluster{
+		ID:       "c-1",
+		Name:     "primary",
+		Provider: sharedmodels.Azure,
+		Status:   sharedmodels.ClusterStatusCreating,
+		Config:   map[string]interface{}{"node_count": "3"},
+		Location: "westeurope",
+	})
+	if err != nil {
+		t.Fatalf("CreateCluster: %v", err)
+	}
+	if created.CreatedAt.IsZero() || created.UpdatedAt.IsZero() {
+		t.Errorf("CreateCluster did not set timestamps: %+v", created)
+	}
+	if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "duplicate"}); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("CreateCluster with an existing ID: expected ErrAlreadyExists, got %v", err)
+	}
+
+	got, err := s.GetCluster("c-1")
+	if err != nil {
+		t.Fatalf("GetCluster: %v", err)
+	}
+	if got.Name != "primary" || got.Provider != sharedmodels.Azure || got.Location != "westeurope" || got.Config["node_count"] != "3" {
+		t.Errorf("GetCluster = %+v", got)
+	}
+	if !got.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("GetCluster CreatedAt = %v, want %v", got.CreatedAt, created.CreatedAt)
+	}
+
+	time.Sleep(time.Millisecond)
+	updated, err := s.UpdateCluster("c-1", &sharedmodels.Cluster{ID: "ignored", Name: "renamed", Provider: sharedmodels.Azure, Status: sharedmodels.ClusterStatusRunning})
+	if err != nil {
+		t.Fatalf("UpdateCluster: %v", err)
+	}
+	if updated.ID != "c-1" || !updated.CreatedAt.Equal(created.CreatedAt) || !updated.UpdatedAt.After(created.CreatedAt) {
+		t.Errorf("UpdateCluster must keep ID and CreatedAt and bump UpdatedAt: %+v", updated)
+	}
+	if got, _ := s.GetCluster("c-1"); got == nil || got.Name != "renamed" || got.Status != sharedmodels.ClusterStatusRunning {
+		t.Errorf("GetCluster after update = %+v", got)
+	}
+
+	if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-2", Name: "secondary", Provider: sharedmodels.AWS}); err != nil {
+		t.Fatalf("CreateCluster(c-2): %v", err)
+	}
+	if ids := clusterIDs(t, s); len(ids) != 2 || ids[0] != "c-1" || ids[1] != "c-2" {
+		t.Errorf("ListClusters IDs = %v", ids)
+	}
+
+	if err := s.DeleteCluster("c-1"); err != nil {
+		t.Fatalf("DeleteCluster: %v", err)
+	}
+	if _, err := s.GetCluster("c-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetCluster after delete: expected ErrNotFound, got %v", err)
+	}
+	if ids := clusterIDs(t, s); len(ids) != 1 || ids[0] != "c-2" {
+		t.Errorf("ListClusters after delete = %v", ids)
+	}
+}
+
+func testClusterNotFound(t *testing.T, s store.Store) {
+	if _, err := s.GetCluster("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetCluster: expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.UpdateCluster("missing", &sharedmodels.Cluster{Name: "x"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateCluster: expected ErrNotFound, got %v", err)
+	}
+	if err := s.DeleteCluster("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteCluster: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testListClustersByProvider(t *testing.T, s store.Store) {
+	for i, provider := range []sharedmodels.CloudProvider{sharedmodels.AWS, sharedmodels.GCP, sharedmodels.AWS, sharedmodels.Hetzner} {
+		id := string(rune('a' + i))
+		if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: id, Name: id, Provider: provider}); err != nil {
+			t.Fatalf("CreateCluster(%s): %v", id, err)
+		}
+	}
+	aws, err := s.ListClustersByProvider(sharedmodels.AWS)
+	if err != nil {
+		t.Fatalf("ListClustersByProvider: %v", err)
+	}
+	ids := make([]string, 0, len(aws))
+	for _, c := range aws {
+		ids = append(ids, c.ID)
+	}
+	sort.Strings(ids)
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "c" {
+		t.Errorf("ListClustersByProvider(aws) = %v, want [a c]", ids)
+	}
+	if azure, err := s.ListClustersByProvider(sharedmodels.Azure); err != nil || len(azure) != 0 {
+		t.Errorf("ListClustersByProvider(azure) = %v, %v", azure, err)
+	}
+}
+
+func testTestResults(t *testing.T, s store.Store) {
+	created, err := s.CreateTestResult(&sharedmodels.TestResult{
+		ClusterID: "c-1",
+		TestType:  "load",
+		Status:    sharedmodels.TestStatusRunning,
+		Details:   map[string]interface{}{"rps": "100"},
+	})
+	if err != nil {
+		t.Fatalf("CreateTestResult: %v", err)
+	}
+	if created.ID == "" || created.StartedAt.IsZero() {
+		t.Fatalf("CreateTestResult must assign an ID and StartedAt: %+v", created)
+	}
+	second, err := s.CreateTestResult(&sharedmodels.TestResult{ClusterID: "c-1", TestType: "performance"})
+	if err != nil {
+		t.Fatalf("CreateTestResult(second): %v", err)
+	}
+	if second.ID == created.ID {
+		t.Errorf("CreateTestResult reused ID %s", created.ID)
+	}
+	if _, err := s.CreateTestResult(&sharedmodels.TestResult{ClusterID: "c-2", TestType: "load"}); err != nil {
+		t.Fatalf("CreateTestResult(c-2): %v", err)
+	}
+
+	got, err := s.GetTestResult(created.ID)
+	if err != nil {
+		t.Fatalf("GetTestResult: %v", err)
+	}
+	if got.TestType != "load" || got.Status != sharedmodels.TestStatusRunning || got.Details["rps"] != "100" || !got.StartedAt.Equal(created.StartedAt) {
+		t.Errorf("GetTestResult = %+v", got)
+	}
+
+	completed := time.Now().Add(time.Minute).Truncate(time.Second)
+	updated, err := s.UpdateTestResult(created.ID, &sharedmodels.TestResult{
+		ClusterID:   "c-1",
+		TestType:    "load",
+		Status:      sharedmodels.TestStatusPassed,
+		Duration:    90 * time.Second,
+		CompletedAt: &completed,
+	})
+	if err != nil {
+		t.Fatalf("UpdateTestResult: %v", err)
+	}
+	if updated.ID != created.ID || !updated.StartedAt.Equal(created.StartedAt) {
+		t.Errorf("UpdateTestResult must keep ID and StartedAt: %+v", updated)
+	}
+	got, _ = s.GetTestResult(created.ID)
+	if got == nil || got.Status != sharedmodels.TestStatusPassed || got.Duration != 90*time.Second || got.CompletedAt == nil || !got.CompletedAt.Equal(completed) {
+		t.Errorf("GetTestResult after update = %+v", got)
+	}
+
+	results, err := s.ListTestResults("c-1")
+	if err != nil || len(results) != 2 {
+		t.Errorf("ListTestResults(c-1) = %d results, %v; want 2", len(results), err)
+	}
+	if results, err := s.ListTestResults("missing"); err != nil || len(results) != 0 {
+		t.Errorf("ListTestResults(missing) = %v, %v", results, err)
+	}
+}
+
+func testTestResultNotFound(t *testing.T, s store.Store) {
+	if _, err := s.GetTestResult("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetTestResult: expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.UpdateTestResult("missing", &sharedmodels.TestResult{}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateTestResult: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testNodePools(t *testing.T, s store.Store) {
+	for _, id := range []string{"c-1", "c-2"} {
+		if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: id, Name: id}); err != nil {
+			t.Fatalf("CreateCluster(%s): %v", id, err)
+		}
+	}
+	created, err := s.CreateNodePool(&sharedmodels.NodePool{
+		ClusterID:    "c-1",
+		Name:         "system",
+		NodeCount:    3,
+		MinNodes:     1,
+		MaxNodes:     5,
+		AutoScaling:  true,
+		InstanceType: "Standard_D2s_v3",
+	})
+	if err != nil {
+		t.Fatalf("CreateNodePool: %v", err)
+	}
+	if created.ID == "" || created.CreatedAt.IsZero() || created.UpdatedAt.IsZero() {
+		t.Fatalf("CreateNodePool must assign an ID and timestamps: %+v", created)
+	}
+	if _, err := s.CreateNodePool(&sharedmodels.NodePool{ID: created.ID, ClusterID: "c-1", Name: "duplicate"}); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("CreateNodePool with an existing ID: expected ErrAlreadyExists, got %v", err)
+	}
+	if _, err := s.CreateNodePool(&sharedmodels.NodePool{ClusterID: "c-1", Name: "user"}); err != nil {
+		t.Fatalf("CreateNodePool(user): %v", err)
+	}
+	if _, err := s.CreateNodePool(&sharedmodels.NodePool{ClusterID: "c-2", Name: "other"}); err != nil {
+		t.Fatalf("CreateNodePool(c-2): %v", err)
+	}
+
+	got, err := s.GetNodePool(created.ID)
+	if err != nil {
+		t.Fatalf("GetNodePool: %v", err)
+	}
+	if got.Name != "system" || got.NodeCount != 3 || got.MaxNodes != 5 || !got.AutoScaling || !got.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("GetNodePool = %+v", got)
+	}
+
+	time.Sleep(time.Millisecond)
+	updated, err := s.UpdateNodePool(created.ID, &sharedmodels.NodePool{ID: "ignored", ClusterID: "ignored", Name: "system", NodeCount: 4, MinNodes: 1, MaxNodes: 5})
+	if err != nil {
+		t.Fatalf("UpdateNodePool: %v", err)
+	}
+	if updated.ID != created.ID || updated.ClusterID != "c-1" || !updated.CreatedAt.Equal(created.CreatedAt) || !updated.UpdatedAt.After(created.CreatedAt) {
+		t.Errorf("UpdateNodePool must keep ID, ClusterID and CreatedAt and bump UpdatedAt: %+v", updated)
+	}
+	if got, _ := s.GetNodePool(created.ID); got == nil || got.NodeCount != 4 {
+		t.Errorf("GetNodePool after update = %+v", got)
+	}
+
+	if pools, err := s.ListNodePools("c-1"); err != nil || len(pools) != 2 {
+		t.Errorf("ListNodePools(c-1) = %d pools, %v; want 2", len(pools), err)
+	}
+	if err := s.DeleteNodePool(created.ID); err != nil {
+		t.Fatalf("DeleteNodePool: %v", err)
+	}
+	if pools, err := s.ListNodePools("c-1"); err != nil || len(pools) != 1 || pools[0].Name != "user" {
+		t.Errorf("ListNodePools(c-1) after delete = %v, %v", pools, err)
+	}
+}
+
+func testNodePoolNotFound(t *testing.T, s store.Store) {
+	if _, err := s.CreateNodePool(&sharedmodels.NodePool{ClusterID: "missing", Name: "system"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("CreateNodePool of a missing cluster: expected ErrNotFound, got %v", err)
+	}
+	if pools, err := s.ListNodePools("missing"); err != nil || len(pools) != 0 {
+		t.Errorf("ListNodePools(missing) = %v, %v; want none", pools, err)
+	}
+	if _, err := s.GetNodePool("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetNodePool: expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.UpdateNodePool("missing", &sharedmodels.NodePool{}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateNodePool: expected ErrNotFound, got %v", err)
+	}
+	if err := s.DeleteNodePool("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteNodePool: expected ErrNotFound, got %v", err)
+	}
+	if pools, err := s.ListNodePools("missing"); err != nil || len(pools) != 0 {
+		t.Errorf("ListNodePools(missing) = %v, %v", pools, err)
+	}
+}
+
+func testDeleteClusterCascade(t *testing.T, s store.Store) {
+	for _, id := range []string{"c-1", "c-2"} {
+		if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: id, Name: id}); err != nil {
+			t.Fatalf("CreateCluster(%s): %v", id, err)
+		}
+		for _, name := range []string{"a", "b"} {
+			if _, err := s.CreateNodePool(&sharedmodels.NodePool{ClusterID: id, Name: name}); err != nil {
+				t.Fatalf("CreateNodePool(%s/%s): %v", id, name, err)
+			}
+		}
+	}
+	if err := s.DeleteCluster("c-1"); err != nil {
+		t.Fatalf("DeleteCluster: %v", err)
+	}
+	if pools, err := s.ListNodePools("c-1"); err != nil || len(pools) != 0 {
+		t.Errorf("ListNodePools of a deleted cluster = %v, %v", pools, err)
+	}
+	if pools, err := s.ListNodePools("c-2"); err != nil || len(pools) != 2 {
+		t.Errorf("ListNodePools(c-2) = %d pools, %v; want 2", len(pools), err)
+	}
+}
+
+func clusterIDs(t *testing.T, s store.Store) []string {
+	t.Helper()
+	clusters, err := s.ListClusters()
+	if err != nil {
+		t.Fatalf("ListClusters: %v", err)
+	}
+	ids := make([]string, 0, len(clusters))
+	for _, c := range clusters {
+		ids = append(ids, c.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func testAzureBudgets(t *testing.T, s store.Store) {
+	if budgets, err := s.ListAzureBudgets(); err != nil || len(budgets) != 0 {
+		t.Fatalf("ListAzureBudgets on an empty store = %v, %v", budgets, err)
+	}
+	created, err := s.CreateAzureBudget(&sharedmodels.AzureBudget{Name: "team", ResourceGroup: "rg-1", Amount: 500, TimeGrain: "Monthly"})
+	if err != nil {
+		t.Fatalf("CreateAzureBudget: %v", err)
+	}
+	if created.ID == "" || created.CreatedAt.IsZero() {
+		t.Errorf("CreateAzureBudget did not assign an ID and timestamps: %+v", created)
+	}
+	if _, err := s.CreateAzureBudget(&sharedmodels.AzureBudget{ID: created.ID, Name: "duplicate"}); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("CreateAzureBudget with an existing ID: expected ErrAlreadyExists, got %v", err)
+	}
+
+	time.Sleep(time.Millisecond)
+	updated, err := s.UpdateAzureBudget(created.ID, &sharedmodels.AzureBudget{ID: "ignored", Name: "team", ResourceGroup: "rg-1", Amount: 750, TimeGrain: "Monthly"})
+	if err != nil {
+		t.Fatalf("UpdateAzureBudget: %v", err)
+	}
+	if updated.ID != created.ID || !updated.CreatedAt.Equal(created.CreatedAt) || !updated.UpdatedAt.After(created.CreatedAt) {
+		t.Errorf("UpdateAzureBudget = %+v, created %+v", updated, created)
+	}
+	if got, err := s.GetAzureBudget(created.ID); err != nil || got.Amount != 750 {
+		t.Errorf("GetAzureBudget after update = %+v, %v", got, err)
+	}
+
+	if err := s.DeleteAzureBudget(created.ID); err != nil {
+		t.Fatalf("DeleteAzureBudget: %v", err)
+	}
+	if _, err := s.GetAzureBudget(created.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAzureBudget after delete: expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.UpdateAzureBudget(created.ID, &sharedmodels.AzureBudget{}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateAzureBudget of a deleted budget: expected ErrNotFound, got %v", err)
+	}
+	if err := s.DeleteAzureBudget(created.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteAzureBudget twice: expected ErrNotFound, got %v", err)
+	}
+}
+
+// testAzureResources checks the other Azure collections are separate and round-trip
+func testAzureResources(t *testing.T, s store.Store) {
+	workspace, err := s.CreateLogAnalyticsWorkspace(&sharedmodels.LogAnalyticsWorkspace{ID: "shared-id", Name: "logs", RetentionDays: 30})
+	if err != nil {
+		t.Fatalf("CreateLogAnalyticsWorkspace: %v", err)
+	}
+	if _, err := s.CreateAppInsights(&sharedmodels.AppInsightsResource{ID: "shared-id", Name: "web", AppType: "web"}); err != nil {
+		t.Errorf("CreateAppInsights with the ID of a workspace: %v", err)
+	}
+	if _, err := s.CreateAzureMonitoring(&sharedmodels.AzureMonitoring{ID: "shared-id", Name: "alerts", Config: map[string]interface{}{"severity": "2"}}); err != nil {
+		t.Errorf("CreateAzureMonitoring: %v", err)
+	}
+	if _, err := s.CreateAzureKubernetes(&sharedmodels.AzureKubernetes{ID: "shared-id", Name: "aks", ClusterSize: 3}); err != nil {
+		t.Errorf("CreateAzureKubernetes: %v", err)
+	}
+
+	if got, err := s.GetLogAnalyticsWorkspace(workspace.ID); err != nil || got.Name != "logs" || got.RetentionDays != 30 {
+		t.Errorf("GetLogAnalyticsWorkspace = %+v, %v", got, err)
+	}
+	if got, err := s.GetAppInsights("shared-id"); err != nil || got.AppType != "web" {
+		t.Errorf("GetAppInsights = %+v, %v", got, err)
+	}
+	if got, err := s.GetAzureMonitoring("shared-id"); err != nil || got.Config["severity"] != "2" {
+		t.Errorf("GetAzureMonitoring = %+v, %v", got, err)
+	}
+	if got, err := s.GetAzureKubernetes("shared-id"); err != nil || got.ClusterSize != 3 {
+		t.Errorf("GetAzureKubernetes = %+v, %v", got, err)
+	}
+
+	if err := s.DeleteLogAnalyticsWorkspace("shared-id"); err != nil {
+		t.Fatalf("DeleteLogAnalyticsWorkspace: %v", err)
+	}
+	if workspaces, err := s.ListLogAnalyticsWorkspaces(); err != nil || len(workspaces) != 0 {
+		t.Errorf("ListLogAnalyticsWorkspaces after delete = %v, %v", workspaces, err)
+	}
+	apps, _ := s.ListAppInsights()
+	monitorings, _ := s.ListAzureMonitorings()
+	kubernetes, _ := s.ListAzureKubernetes()
+	if len(apps) != 1 || len(monitorings) != 1 || len(kubernetes) != 1 {
+		t.Errorf("deleting a workspace touched other collections: %d app insights, %d monitorings, %d kubernetes",
+			len(apps), len(monitorings), len(kubernetes))
+	}
+}
+
+func testProjects(t *testing.T, s store.Store) {
+	if projects, err := s.ListProjects(); err != nil || len(projects) != 0 {
+		t.Fatalf("ListProjects on an empty store = %v, %v", projects, err)
+	}
+	created, err := s.CreateProject(&sharedmodels.Project{Name: "team-b", Quota: sharedmodels.ProjectQuota{Clusters: 2}})
+	if err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if created.CreatedAt.IsZero() {
+		t.Errorf("CreateProject did not set timestamps: %+v", created)
+	}
+	if _, err := s.CreateProject(&sharedmodels.Project{Name: "team-b"}); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("CreateProject with an existing name: expected ErrAlreadyExists, got %v", err)
+	}
+	if _, err := s.CreateProject(&sharedmodels.Project{Name: "team-a"}); err != nil {
+		t.Fatalf("CreateProject(team-a): %v", err)
+	}
+
+	time.Sleep(time.Millisecond)
+	updated, err := s.UpdateProject("team-b", &sharedmodels.Project{Name: "ignored", Description: "load tests", Quota: sharedmodels.ProjectQuota{Clusters: 5}})
+	if err != nil {
+		t.Fatalf("UpdateProject: %v", err)
+	}
+	if updated.Name != "team-b" || !updated.CreatedAt.Equal(created.CreatedAt) || !updated.UpdatedAt.After(created.CreatedAt) {
+		t.Errorf("UpdateProject must keep the name and CreatedAt and bump UpdatedAt: %+v", updated)
+	}
+	// Project records are the same in every view
+	if got, err := s.Project("team-a").GetProject("team-b"); err != nil || got.Quota.Clusters != 5 || got.Description != "load tests" {
+		t.Errorf("GetProject through a project view = %+v, %v", got, err)
+	}
+
+	projects, err := s.ListProjects()
+	if err != nil || len(projects) != 2 || projects[0].Name != "team-a" || projects[1].Name != "team-b" {
+		t.Errorf("ListProjects = %v, %v; want team-a and team-b by name", projects, err)
+	}
+
+	if err := s.DeleteProject("team-b"); err != nil {
+		t.Fatalf("DeleteProject: %v", err)
+	}
+	if _, err := s.GetProject("team-b"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetProject after delete: expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.UpdateProject("team-b", &sharedmodels.Project{}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateProject of a deleted project: expected ErrNotFound, got %v", err)
+	}
+	if err := s.DeleteProject("team-b"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteProject twice: expected ErrNotFound, got %v", err)
+	}
+}
+
+// testProjectIsolation checks the resources of one project are invisible to others
+func testProjectIsolation(t *testing.T, s store.Store) {
+	if s.Project(sharedmodels.DefaultProject) == nil || s.Project("") == nil {
+		t.Fatal("Project returned no view of the default project")
+	}
+	if _, err := s.CreateProject(&sharedmodels.Project{Name: "team-a"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	teamA := s.Project("team-a")
+	if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "default-cluster"}); err != nil {
+		t.Fatalf("CreateCluster in the default project: %v", err)
+	}
+	if _, err := teamA.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "team-cluster"}); err != nil {
+		t.Fatalf("CreateCluster with the same ID in another project: %v", err)
+	}
+	if _, err := teamA.CreateNodePool(&sharedmodels.NodePool{ID: "p-1", ClusterID: "c-1", Name: "system"}); err != nil {
+		t.Fatalf("CreateNodePool: %v", err)
+	}
+	result, err := teamA.CreateTestResult(&sharedmodels.TestResult{ClusterID: "c-1", TestType: "load"})
+	if err != nil {
+		t.Fatalf("CreateTestResult: %v", err)
+	}
+	if _, err := teamA.CreateAzureBudget(&sharedmodels.AzureBudget{ID: "b-1", Name: "team"}); err != nil {
+		t.Fatalf("CreateAzureBudget: %v", err)
+	}
+
+	if got, err := s.GetCluster("c-1"); err != nil || got.Name != "default-cluster" {
+		t.Errorf("GetCluster in the default project = %+v, %v", got, err)
+	}
+	if got, err := s.Project(sharedmodels.DefaultProject).GetCluster("c-1"); err != nil || got.Name != "default-cluster" {
+		t.Errorf("GetCluster through the default project's view = %+v, %v", got, err)
+	}
+	if got, err := teamA.GetCluster("c-1"); err != nil || got.Name != "team-cluster" {
+		t.Errorf("GetCluster in team-a = %+v, %v", got, err)
+	}
+	if _, err := s.GetNodePool("p-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetNodePool of another project: expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.GetTestResult(result.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetTestResult of another project: expected ErrNotFound, got %v", err)
+	}
+	if budgets, err := s.ListAzureBudgets(); err != nil || len(budgets) != 0 {
+		t.Errorf("ListAzureBudgets in the default project = %v, %v", budgets, err)
+	}
+	if clusters, err := s.Project("team-b").ListClusters(); err != nil || len(clusters) != 0 {
+		t.Errorf("ListClusters in an empty project = %v, %v", clusters, err)
+	}
+
+	// Deleting a project deletes its resources, but not those of other projects
+	if err := s.DeleteProject("team-a"); err != nil {
+		t.Fatalf("DeleteProject: %v", err)
+	}
+	if clusters, err := s.Project("team-a").ListClusters(); err != nil || len(clusters) != 0 {
+		t.Errorf("ListClusters of a deleted project = %v, %v", clusters, err)
+	}
+	if _, err := s.Project("team-a").GetAzureBudget("b-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAzureBudget of a deleted project: expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.GetCluster("c-1"); err != nil {
+		t.Errorf("GetCluster in the default project after deleting team-a: %v", err)
+	}
+}
+
+// testSnapshotRestore checks a snapshot brings back every project's resources as they
+// were, and an invalid snapshot changes nothing
+func testSnapshotRestore(t *testing.T, s store.Store) {
+	if _, err := s.CreateProject(&sharedmodels.Project{Name: "team-a", Quota: sharedmodels.ProjectQuota{Clusters: 3}}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	teamA := s.Project("team-a")
+	created, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "default-cluster", Provider: sharedmodels.Hetzner})
+	if err != nil {
+		t.Fatalf("CreateCluster: %v", err)
+	}
+	if _, err := s.CreateNodePool(&sharedmodels.NodePool{ID: "p-1", ClusterID: "c-1", Name: "system"}); err != nil {
+		t.Fatalf("CreateNodePool: %v", err)
+	}
+	result, err := s.CreateTestResult(&sharedmodels.TestResult{ClusterID: "c-1", TestType: "load"})
+	if err != nil {
+		t.Fatalf("CreateTestResult: %v", err)
+	}
+	if _, err := teamA.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "team-cluster"}); err != nil {
+		t.Fatalf("CreateCluster in team-a: %v", err)
+	}
+	if _, err := teamA.CreateAzureBudget(&sharedmodels.AzureBudget{ID: "b-1", Name: "team"}); err != nil {
+		t.Fatalf("CreateAzureBudget: %v", err)
+	}
+
+	snapshot, err := teamA.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(snapshot.Projects) != 1 || snapshot.Projects[0].Name != "team-a" || len(snapshot.Resources[sharedmodels.DefaultProject].Clusters) != 1 ||
+		len(snapshot.Resources["team-a"].AzureBudgets) != 1 {
+		t.Fatalf("Snapshot = %+v", snapshot)
+	}
+
+	// Changes after the snapshot are undone by restoring it
+	if err := s.DeleteCluster("c-1"); err != nil {
+		t.Fatalf("DeleteCluster: %v", err)
+	}
+	if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-2", Name: "later"}); err != nil {
+		t.Fatalf("CreateCluster(c-2): %v", err)
+	}
+	if err := s.DeleteProject("team-a"); err != nil {
+		t.Fatalf("DeleteProject: %v", err)
+	}
+	if _, err := s.CreateProject(&sharedmodels.Project{Name: "team-b"}); err != nil {
+		t.Fatalf("CreateProject(team-b): %v", err)
+	}
+	if err := s.Restore(snapshot); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got, err := s.GetCluster("c-1"); err != nil || got.Name != "default-cluster" || !got.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("GetCluster after restore = %+v, %v", got, err)
+	}
+	if _, err := s.GetCluster("c-2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetCluster of a cluster created after the snapshot: expected ErrNotFound, got %v", err)
+	}
+	if pools, err := s.ListNodePools("c-1"); err != nil || len(pools) != 1 {
+		t.Errorf("ListNodePools after restore = %v, %v", pools, err)
+	}
+	if got, err := s.GetTestResult(result.ID); err != nil || got.TestType != "load" {
+		t.Errorf("GetTestResult after restore = %+v, %v", got, err)
+	}
+	if projects, err := s.ListProjects(); err != nil || len(projects) != 1 || projects[0].Name != "team-a" || projects[0].Quota.Clusters != 3 {
+		t.Errorf("ListProjects after restore = %v, %v", projects, err)
+	}
+	if got, err := s.Project("team-a").GetCluster("c-1"); err != nil || got.Name != "team-cluster" {
+		t.Errorf("GetCluster in team-a after restore = %+v, %v", got, err)
+	}
+	if got, err := s.Project("team-a").GetAzureBudget("b-1"); err != nil || got.Name != "team" {
+		t.Errorf("GetAzureBudget in team-a after restore = %+v, %v", got, err)
+	}
+
+	// A node pool of a missing cluster is refused and the store is left as it was
+	invalid := &store.Snapshot{Resources: map[string]*store.ProjectResources{
+		sharedmodels.DefaultProject: {NodePools: []*sharedmodels.NodePool{{ID: "p-9", ClusterID: "missing"}}},
+	}}
+	if err := s.Restore(invalid); !errors.Is(err, store.ErrInvalidSnapshot) {
+		t.Errorf("Restore of an invalid snapshot: expected ErrInvalidSnapshot, got %v", err)
+	}
+	if _, err := s.GetCluster("c-1"); err != nil {
+		t.Errorf("GetCluster after a refused restore: %v", err)
+	}
+	if err := s.Restore(&store.Snapshot{}); err != nil {
+		t.Fatalf("Restore of an empty snapshot: %v", err)
+	}
+	if clusters, err := s.ListClusters(); err != nil || len(clusters) != 0 {
+		t.Errorf("ListClusters after restoring an empty snapshot = %v, %v", clusters, err)
+	}
+	if _, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-3", Name: "fresh"}); err != nil {
+		t.Errorf("CreateCluster after restoring an empty snapshot: %v", err)
+	}
+}
+
+func testResourceVersions(t *testing.T, s store.Store) {
+	cluster, err := s.CreateCluster(&sharedmodels.Cluster{ID: "c-1", Name: "versioned", ResourceVersion: 7})
+	if err != nil || cluster.ResourceVersion != 1 {
+		t.Fatalf("CreateCluster = %+v, %v; want version 1", cluster, err)
+	}
+	for want := int64(2); want <= 3; want++ {
+		// The version of the update is ignored; the store counts on from the stored one
+		updated, err := s.UpdateCluster("c-1", &sharedmodels.Cluster{Name: "versioned", ResourceVersion: 42})
+		if err != nil || updated.ResourceVersion != want {
+			t.Fatalf("UpdateCluster = %+v, %v; want version %d", updated, err, want)
+		}
+	}
+	if got, err := s.GetCluster("c-1"); err != nil || got.ResourceVersion != 3 {
+		t.Errorf("GetCluster = %+v, %v; want version 3", got, err)
+	}
+
+	pool, err := s.CreateNodePool(&sharedmodels.NodePool{ClusterID: "c-1", Name: "pool"})
+	if err != nil || pool.ResourceVersion != 1 {
+		t.Fatalf("CreateNodePool = %+v, %v", pool, err)
+	}
+	if pool, err = s.UpdateNodePool(pool.ID, &sharedmodels.NodePool{Name: "pool", NodeCount: 2}); err != nil || pool.ResourceVersion != 2 {
+		t.Errorf("UpdateNodePool = %+v, %v; want version 2", pool, err)
+	}
+	result, err := s.CreateTestResult(&sharedmodels.TestResult{ClusterID: "c-1", TestType: "load"})
+	if err != nil || result.ResourceVersion != 1 {
+		t.Fatalf("CreateTestResult = %+v, %v", result, err)
+	}
+	if result, err = s.UpdateTestResult(result.ID, &sharedmodels.TestResult{ClusterID: "c-1", TestType: "load"}); err != nil || result.ResourceVersion != 2 {
+		t.Errorf("UpdateTestResult = %+v, %v; want version 2", result, err)
+	}
+	budget, err := s.CreateAzureBudget(&sharedmodels.AzureBudget{Name: "budget", Amount: 10})
+	if err != nil || budget.ResourceVersion != 1 {
+		t.Fatalf("CreateAzureBudget = %+v, %v", budget, err)
+	}
+	if budget, err = s.UpdateAzureBudget(budget.ID, &sharedmodels.AzureBudget{Name: "budget", Amount: 20}); err != nil || budget.ResourceVersion != 2 {
+		t.Errorf("UpdateAzureBudget = %+v, %v; want version 2", budget, err)
+	}
+	project, err := s.CreateProject(&sharedmodels.Project{Name: "team-a"})
+	if err != nil || project.ResourceVersion != 1 {
+		t.Fatalf("CreateProject = %+v, %v", project, err)
+	}
+	if project, err = s.UpdateProject("team-a", &sharedmodels.Project{Description: "A"}); err != nil || project.ResourceVersion != 2 {
+		t.Errorf("UpdateProject = %+v, %v; want version 2", project, err)
+	}
+}
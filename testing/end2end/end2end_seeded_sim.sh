@@ -0,0 +1,73 @@
+#!/bin/bash
+# End-to-end test for deterministic simulation: seeded runs and cassette record/replay
+set -euo pipefail
+
+# Always run from the repo root for robust path handling
+REPO_ROOT="$(git rev-parse --show-toplevel)"
+cd "$REPO_ROOT"
+echo "[E2E] Running from repo root: $(pwd)"
+
+PORT=18081
+BASE_URL="http://localhost:$PORT/api/v1/simulate/providers/aws/operations"
+WORK_DIR="$(mktemp -d /tmp/cube_server_seeded_e2e_XXXXXX)"
+CASSETTE="$WORK_DIR/cassette.json"
+export FAST_SIMULATE=1
+trap 'scripts/cube_server_control.sh stop "$PORT" >/dev/null 2>&1 || true; rm -rf "$WORK_DIR"' EXIT
+
+# run_scenario <output file> [curl args...]: the same operations for every server mode
+run_scenario() {
+  local out="$1"
+  shift
+  : > "$out"
+  for body in \
+    '{"provider":"aws","operation":"create_cluster","parameters":{"name":"eks-e2e","node_count":3}}' \
+    '{"provider":"aws","operation":"run_test","parameters":{"cluster_id":"eks-e2e","test_type":"performance"}}' \
+    '{"provider":"aws","operation":"list_clusters"}'; do
+    op=$(echo "$body" | sed -E 's/.*"operation":"([^"]*)".*/\1/')
+    curl -sf -X POST "$BASE_URL/$op" -H 'Content-Type: application/json' "$@" -d "$body" >> "$out"
+    echo >> "$out"
+  done
+}
+
+# start_server <name> [VAR=value...]: every run starts with empty simulation state, so
+# the clusters created by one run do not show up in the next
+start_server() {
+  local name="$1"
+  shift
+  env CUBE_SERVER_SIM_PERSIST="$WORK_DIR/$name-buckets.json" "$@" scripts/cube_server_control.sh start "$PORT"
+}
+
+# strip_clock removes the fields that follow the wall clock
+strip_clock() {
+  sed -E 's/"(timestamp|created_at|updated_at|started_at|transition_at)":"[^"]*"//g; s/"duration":[0-9]+//g' "$1"
+}
+
+echo "[E2E] Building all binaries..."
+make build
+
+echo "[E2E] Seeded run: the same X-Cube-Sim-Seed must give the same answers..."
+for run in seeded1 seeded2; do
+  start_server "$run"
+  run_scenario "$WORK_DIR/$run.out" -H 'X-Cube-Sim-Seed: 42'
+  scripts/cube_server_control.sh stop "$PORT"
+done
+if ! diff <(strip_clock "$WORK_DIR/seeded1.out") <(strip_clock "$WORK_DIR/seeded2.out"); then
+  echo "[E2E] ERROR: seeded runs differ."
+  exit 1
+fi
+
+echo "[E2E] Recording run (CUBE_SERVER_SIM_SEED=42) to $CASSETTE ..."
+start_server recorded CUBE_SERVER_SIM_SEED=42 CUBE_SERVER_SIM_RECORD="$CASSETTE"
+run_scenario "$WORK_DIR/recorded.out"
+scripts/cube_server_control.sh stop "$PORT"
+
+echo "[E2E] Replaying $CASSETTE ..."
+start_server replayed CUBE_SERVER_SIM_REPLAY="$CASSETTE"
+run_scenario "$WORK_DIR/replayed.out"
+scripts/cube_server_control.sh stop "$PORT"
+if ! diff "$WORK_DIR/recorded.out" "$WORK_DIR/replayed.out"; then
+  echo "[E2E] ERROR: replayed answers differ from the recording."
+  exit 1
+fi
+
+echo "[E2E] Seeded simulation test completed successfully."
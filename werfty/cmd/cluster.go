@@ -1,15 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 
 	"punchbag-cube-testsuite/client/pkg/api"
 	"punchbag-cube-testsuite/client/pkg/output"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/tronicum/punchbag-cube-testsuite/shared/events"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
 )
 
 // clusterCmd represents the cluster command
@@ -254,10 +258,42 @@ var clusterTestCmd = &cobra.Command{
 		}
 
 		fmt.Printf("Test started with ID: %s\n", result.ID)
+		if watch, _ := cmd.Flags().GetBool("watch"); watch {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			if result, err = client.WatchTest(ctx, result.ID, printTestEvent); err != nil {
+				fmt.Fprintf(os.Stderr, "Error watching test: %v\n", err)
+				os.Exit(1)
+			}
+		}
 		output.PrintTestResult(result, viper.GetString("format"))
 	},
 }
 
+var clusterWatchCmd = &cobra.Command{
+	Use:   "watch [cluster-id]",
+	Short: "Stream changes of a cluster",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := api.NewClient(viper.GetString("server"))
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Watching cluster %s (press Ctrl+C to stop)...\n", args[0])
+		_, err := client.SubscribeEvents(ctx, sharedmodels.EventResourceClusters, args[0], "", func(event sharedmodels.Event) error {
+			fmt.Printf("%s  %-8s %v\n", event.Time.Local().Format("15:04:05"), event.Type, event.Data["status"])
+			if event.Type == sharedmodels.EventDeleted {
+				return events.ErrStop
+			}
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Error watching cluster: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(clusterCmd)
 	clusterCmd.AddCommand(clusterListCmd)
@@ -265,6 +301,7 @@ func init() {
 	clusterCmd.AddCommand(clusterCreateCmd)
 	clusterCmd.AddCommand(clusterDeleteCmd)
 	clusterCmd.AddCommand(clusterTestCmd)
+	clusterCmd.AddCommand(clusterWatchCmd)
 
 	// List cluster flags
 	clusterListCmd.Flags().String("provider", "", "Filter by cloud provider (azure, schwarz-stackit, hetzner-hcloud, united-ionos, aws, gcp)")
@@ -303,4 +340,5 @@ func init() {
 	// Test cluster flags
 	clusterTestCmd.Flags().String("type", "", "Test type (load_test, performance_test, stress_test)")
 	clusterTestCmd.Flags().String("config", "", "Test configuration file (JSON)")
+	clusterTestCmd.Flags().Bool("watch", false, "Follow the test until it finishes")
 }
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"punchbag-cube-testsuite/client/pkg/api"
+	"punchbag-cube-testsuite/client/pkg/output"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// nodePoolCmd represents the cluster nodepool command
+var nodePoolCmd = &cobra.Command{
+	Use:   "nodepool",
+	Short: "Manage the node pools of a cluster",
+	Long:  `Commands for listing, adding, scaling and removing the node pools of a cluster.`,
+}
+
+var nodePoolListCmd = &cobra.Command{
+	Use:   "list [cluster-id]",
+	Short: "List the node pools of a cluster",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := api.NewClient(viper.GetString("server"))
+		pools, err := client.ListNodePools(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing node pools: %v\n", err)
+			os.Exit(1)
+		}
+
+		output.PrintNodePools(pools, viper.GetString("format"))
+	},
+}
+
+var nodePoolAddCmd = &cobra.Command{
+	Use:   "add [cluster-id]",
+	Short: "Add a node pool to a cluster",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		if name == "" {
+			fmt.Fprintf(os.Stderr, "Error: name is required\n")
+			os.Exit(1)
+		}
+
+		pool := &sharedmodels.NodePool{Name: name}
+		pool.NodeCount, _ = cmd.Flags().GetInt("node-count")
+		pool.MinNodes, _ = cmd.Flags().GetInt("min-nodes")
+		pool.MaxNodes, _ = cmd.Flags().GetInt("max-nodes")
+		pool.AutoScaling, _ = cmd.Flags().GetBool("auto-scaling")
+		pool.InstanceType, _ = cmd.Flags().GetString("instance-type")
+		pool.OSType, _ = cmd.Flags().GetString("os-type")
+
+		client := api.NewClient(viper.GetString("server"))
+		created, err := client.CreateNodePool(args[0], pool)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error adding node pool: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Node pool created successfully with ID: %s\n", created.ID)
+		output.PrintNodePools([]*sharedmodels.NodePool{created}, viper.GetString("format"))
+	},
+}
+
+var nodePoolScaleCmd = &cobra.Command{
+	Use:   "scale [cluster-id] [pool-id]",
+	Short: "Scale a node pool",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		scale := &api.NodePoolScale{}
+		if cmd.Flags().Changed("node-count") {
+			nodeCount, _ := cmd.Flags().GetInt("node-count")
+			scale.NodeCount = &nodeCount
+		}
+		if cmd.Flags().Changed("min-nodes") {
+			minNodes, _ := cmd.Flags().GetInt("min-nodes")
+			scale.MinNodes = &minNodes
+		}
+		if cmd.Flags().Changed("max-nodes") {
+			maxNodes, _ := cmd.Flags().GetInt("max-nodes")
+			scale.MaxNodes = &maxNodes
+		}
+		if cmd.Flags().Changed("auto-scaling") {
+			autoScaling, _ := cmd.Flags().GetBool("auto-scaling")
+			scale.AutoScaling = &autoScaling
+		}
+		if *scale == (api.NodePoolScale{}) {
+			fmt.Fprintf(os.Stderr, "Error: one of node-count, min-nodes, max-nodes or auto-scaling is required\n")
+			os.Exit(1)
+		}
+
+		client := api.NewClient(viper.GetString("server"))
+		updated, err := client.ScaleNodePool(args[0], args[1], scale)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error scaling node pool: %v\n", err)
+			os.Exit(1)
+		}
+
+		output.PrintNodePools([]*sharedmodels.NodePool{updated}, viper.GetString("format"))
+	},
+}
+
+var nodePoolRemoveCmd = &cobra.Command{
+	Use:   "remove [cluster-id] [pool-id]",
+	Short: "Remove a node pool from a cluster",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		confirm, _ := cmd.Flags().GetBool("confirm")
+		if !confirm {
+			fmt.Print("Are you sure you want to remove this node pool? (y/N): ")
+			var response string
+			fmt.Scanln(&response)
+			if response != "y" && response != "Y" {
+				fmt.Println("Operation cancelled")
+				return
+			}
+		}
+
+		client := api.NewClient(viper.GetString("server"))
+		if err := client.DeleteNodePool(args[0], args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing node pool: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Node pool %s removed successfully\n", args[1])
+	},
+}
+
+func init() {
+	clusterCmd.AddCommand(nodePoolCmd)
+	nodePoolCmd.AddCommand(nodePoolListCmd)
+	nodePoolCmd.AddCommand(nodePoolAddCmd)
+	nodePoolCmd.AddCommand(nodePoolScaleCmd)
+	nodePoolCmd.AddCommand(nodePoolRemoveCmd)
+
+	// Add node pool flags
+	nodePoolAddCmd.Flags().String("name", "", "Node pool name (required)")
+	nodePoolAddCmd.Flags().Int("node-count", 1, "Number of nodes")
+	nodePoolAddCmd.Flags().Int("min-nodes", 0, "Minimum number of nodes (default: node-count)")
+	nodePoolAddCmd.Flags().Int("max-nodes", 0, "Maximum number of nodes (default: node-count)")
+	nodePoolAddCmd.Flags().Bool("auto-scaling", false, "Enable autoscaling between min-nodes and max-nodes")
+	nodePoolAddCmd.Flags().String("instance-type", "", "Instance type (default: the provider's smallest)")
+	nodePoolAddCmd.Flags().String("os-type", "", "Operating system: Linux or Windows (default: Linux)")
+
+	// Scale node pool flags
+	nodePoolScaleCmd.Flags().Int("node-count", 0, "Number of nodes")
+	nodePoolScaleCmd.Flags().Int("min-nodes", 0, "Minimum number of nodes")
+	nodePoolScaleCmd.Flags().Int("max-nodes", 0, "Maximum number of nodes")
+	nodePoolScaleCmd.Flags().Bool("auto-scaling", false, "Enable autoscaling between min-nodes and max-nodes")
+
+	// Remove node pool flags
+	nodePoolRemoveCmd.Flags().Bool("confirm", false, "Skip confirmation prompt")
+}
@@ -1,14 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 
 	"punchbag-cube-testsuite/client/pkg/api"
 	"punchbag-cube-testsuite/client/pkg/output"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
 )
 
 // testCmd represents the test command
@@ -56,26 +59,55 @@ var testWatchCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		client := api.NewClient(viper.GetString("server"))
-		
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
 		fmt.Printf("Watching test %s (press Ctrl+C to stop)...\n", args[0])
-		
-		for {
-			result, err := client.GetTestResult(args[0])
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error getting test result: %v\n", err)
-				os.Exit(1)
-			}
 
-			fmt.Printf("\rStatus: %s", result.Status)
-			if result.Status == "completed" || result.Status == "failed" {
-				fmt.Println()
-				output.PrintTestResult(result, viper.GetString("format"))
-				break
+		result, err := client.WatchTest(ctx, args[0], printTestEvent)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
 			}
-			
-			// Sleep for 2 seconds before checking again
-			cmd.Context().Done()
+			fmt.Fprintf(os.Stderr, "Error watching test: %v\n", err)
+			os.Exit(1)
+		}
+		output.PrintTestResult(result, viper.GetString("format"))
+	},
+}
+
+// printTestEvent prints one line per event of a watched test
+func printTestEvent(event sharedmodels.Event) {
+	timestamp := event.Time.Local().Format("15:04:05")
+	switch event.Type {
+	case sharedmodels.EventStatus, sharedmodels.EventCreated:
+		fmt.Printf("%s  status   %v", timestamp, event.Data["status"])
+		if event.Message != "" {
+			fmt.Printf(" (%s)", event.Message)
+		}
+		fmt.Println()
+	case sharedmodels.EventProgress:
+		if percent, ok := event.Data["progress_percent"]; ok {
+			fmt.Printf("%s  progress %v%%\n", timestamp, percent)
 		}
+	case sharedmodels.EventLog:
+		fmt.Printf("%s  log      %s\n", timestamp, event.Message)
+	}
+}
+
+var testCancelCmd = &cobra.Command{
+	Use:   "cancel [test-id]",
+	Short: "Cancel a pending or running test",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := api.NewClient(viper.GetString("server"))
+		result, err := client.CancelTest(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error cancelling test: %v\n", err)
+			os.Exit(1)
+		}
+
+		output.PrintTestResult(result, viper.GetString("format"))
 	},
 }
 
@@ -84,4 +116,5 @@ func init() {
 	testCmd.AddCommand(testGetCmd)
 	testCmd.AddCommand(testListCmd)
 	testCmd.AddCommand(testWatchCmd)
+	testCmd.AddCommand(testCancelCmd)
 }
@@ -15,6 +15,10 @@ import (
 type Werfty struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// Retries is how often a failed request is sent again (see retry.go)
+	Retries int
+	// RetryDelay is the pause before the first retry, doubled for each further one
+	RetryDelay time.Duration
 }
 
 // NewWerfty creates a new API werfty
@@ -24,72 +28,74 @@ func NewWerfty(baseURL string) *Werfty {
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		Retries:    DefaultRetries,
+		RetryDelay: 250 * time.Millisecond,
 	}
 }
 
 // doRequest performs an HTTP request
 func (c *Werfty) doRequest(method, path string, body interface{}) (*http.Response, error) {
+	return c.doRequestWith(method, path, body, nil)
+}
+
+// doRequestWith performs an HTTP request with extra headers. Failed requests are
+// sent again as retryable allows, POSTs with the same Idempotency-Key.
+func (c *Werfty) doRequestWith(method, path string, body interface{}, header http.Header) (*http.Response, error) {
 	url := c.BaseURL + path
 
-	var bodyReader io.Reader
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
-		if err != nil {
+		var err error
+		if jsonBody, err = json.Marshal(body); err != nil {
 			return nil, fmt.Errorf("error marshaling request body: %w", err)
 		}
-		bodyReader = bytes.NewBuffer(jsonBody)
 	}
-
-	req, err := http.NewRequest(method, url, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+	var key string
+	if method == http.MethodPost {
+		key = newIdempotencyKey()
 	}
 
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			time.Sleep(c.RetryDelay << (retry - 1))
+		}
+		var bodyReader io.Reader
+		if jsonBody != nil {
+			bodyReader = bytes.NewReader(jsonBody)
+		}
+		req, err := http.NewRequest(method, url, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
 
-	return c.HTTPClient.Do(req)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if key != "" {
+			req.Header.Set(IdempotencyHeader, key)
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		if retry >= c.Retries || !retryable(method, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
 }
 
 // ListClusters lists all clusters (multi-cloud)
 func (c *Werfty) ListClusters() ([]*sharedmodels.Cluster, error) {
-	resp, err := c.doRequest("GET", "/api/v1/clusters", nil)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
-	}
-
-	var response sharedmodels.ClustersResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-
-	return response.Clusters, nil
+	return c.ListClustersWith(ListOptions{})
 }
 
 // ListClustersByProvider lists clusters filtered by provider
 func (c *Werfty) ListClustersByProvider(provider string) ([]*sharedmodels.Cluster, error) {
-	resp, err := c.doRequest("GET", "/api/v1/clusters?provider="+provider, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
-	}
-
-	var response sharedmodels.ClustersResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-
-	return response.Clusters, nil
+	return c.ListClustersWith(ListOptions{Provider: provider})
 }
 
 // ListAKSClusters lists all AKS clusters (backward compatibility)
@@ -435,6 +441,32 @@ func (c *Werfty) GetTestResult(id string) (*sharedmodels.TestResult, error) {
 	return &result, nil
 }
 
+// CancelTest cancels a pending or running test and returns its cancelled result
+func (c *Werfty) CancelTest(id string) (*sharedmodels.TestResult, error) {
+	resp, err := c.doRequest("DELETE", "/api/v1/tests/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("test result not found")
+	case http.StatusConflict:
+		return nil, fmt.Errorf("test has already finished")
+	default:
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var result sharedmodels.TestResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &result, nil
+}
+
 // GetAKSTestResult gets an AKS test result by ID (backward compatibility)
 func (c *Werfty) GetAKSTestResult(id string) (*sharedmodels.AKSTestResult, error) {
 	result, err := c.GetTestResult(id)
@@ -460,22 +492,7 @@ func (c *Werfty) GetAKSTestResult(id string) (*sharedmodels.AKSTestResult, error
 
 // ListTestResults lists test results for a cluster (multi-cloud)
 func (c *Werfty) ListTestResults(clusterID string) ([]*sharedmodels.TestResult, error) {
-	resp, err := c.doRequest("GET", "/api/v1/clusters/"+clusterID+"/tests", nil)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
-	}
-
-	var response sharedmodels.TestResultsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-
-	return response.TestResults, nil
+	return c.ListTestResultsWith(clusterID, ListOptions{})
 }
 
 // ListAKSTestResults lists test results for an AKS cluster (backward compatibility)
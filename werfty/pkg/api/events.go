@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tronicum/punchbag-cube-testsuite/shared/events"
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// SubscribeEvents streams server events for a resource type ("tests", "clusters") and
+// optionally a single resource ID to handle until ctx is done or handle returns an
+// error (events.ErrStop ends it cleanly). Pass the returned event ID as lastEventID to
+// resume later without missing events.
+func (c *Werfty) SubscribeEvents(ctx context.Context, resource, id, lastEventID string, handle func(sharedmodels.Event) error) (string, error) {
+	return events.Subscribe(ctx, c.HTTPClient, c.BaseURL, events.Options{
+		Resource:    resource,
+		ID:          id,
+		LastEventID: lastEventID,
+	}, handle)
+}
+
+// WatchTest follows a test until it has finished and returns its final result.
+// onEvent (may be nil) is called for every event of the test, including those that
+// happened before the watch started.
+func (c *Werfty) WatchTest(ctx context.Context, id string, onEvent func(sharedmodels.Event)) (*sharedmodels.TestResult, error) {
+	result, err := c.GetTestResult(id)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status.Finished() {
+		return result, nil
+	}
+	// Last-Event-ID 0 replays the events kept for the test, so a transition between
+	// the GET above and the subscription is not lost
+	_, err = c.SubscribeEvents(ctx, sharedmodels.EventResourceTests, id, "0", func(event sharedmodels.Event) error {
+		if onEvent != nil {
+			onEvent(event)
+		}
+		if status, _ := event.Data["status"].(string); event.Type == sharedmodels.EventStatus && sharedmodels.TestStatus(status).Finished() {
+			return events.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("watching test %s: %w", id, err)
+	}
+	return c.GetTestResult(id)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// ListOptions filters and sorts list requests; zero fields are left to the server.
+// The list methods follow next_page_token until the last page, so callers get every
+// matching item whatever the page size.
+type ListOptions struct {
+	// Provider, Status and Region take comma-separated values, any of which matches
+	Provider string
+	Status   string
+	Region   string
+	// LabelSelector is e.g. env=prod,tier!=db,team,!legacy
+	LabelSelector string
+	CreatedAfter  time.Time
+	SortBy        string
+	// Order is asc or desc
+	Order string
+	// PageSize is the limit of each request (server default: 100)
+	PageSize int
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	for name, value := range map[string]string{
+		"provider": o.Provider, "status": o.Status, "region": o.Region,
+		"label_selector": o.LabelSelector, "sort_by": o.SortBy, "order": o.Order,
+	} {
+		if value != "" {
+			q.Set(name, value)
+		}
+	}
+	if !o.CreatedAfter.IsZero() {
+		q.Set("created_after", o.CreatedAfter.UTC().Format(time.RFC3339))
+	}
+	if o.PageSize > 0 {
+		q.Set("limit", strconv.Itoa(o.PageSize))
+	}
+	return q
+}
+
+// listAll gets every page of the list at path and decodes the items under key.
+// notFound, if set, is the error for a 404 answer.
+func listAll[T any](c *Werfty, path, key string, opts ListOptions, notFound error) ([]T, error) {
+	items := []T{}
+	q := opts.query()
+	for {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		resp, err := c.doRequest("GET", path+sep+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound && notFound != nil {
+			resp.Body.Close()
+			return nil, notFound
+		}
+		if resp.StatusCode != http.StatusOK {
+			// A refused filter or page token carries the reason in the body
+			err := nodePoolError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var page map[string]json.RawMessage
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding response: %w", err)
+		}
+		var batch []T
+		if raw := page[key]; raw != nil {
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return nil, fmt.Errorf("error decoding response: %w", err)
+			}
+		}
+		items = append(items, batch...)
+		var next string
+		if raw := page["next_page_token"]; raw != nil {
+			if err := json.Unmarshal(raw, &next); err != nil {
+				return nil, fmt.Errorf("error decoding response: %w", err)
+			}
+		}
+		if next == "" {
+			return items, nil
+		}
+		q.Set("page_token", next)
+	}
+}
+
+// ListClustersWith lists the clusters matching opts
+func (c *Werfty) ListClustersWith(opts ListOptions) ([]*sharedmodels.Cluster, error) {
+	return listAll[*sharedmodels.Cluster](c, "/api/v1/clusters", "clusters", opts, nil)
+}
+
+// ListTestResultsWith lists the test results of a cluster matching opts
+func (c *Werfty) ListTestResultsWith(clusterID string, opts ListOptions) ([]*sharedmodels.TestResult, error) {
+	return listAll[*sharedmodels.TestResult](c, "/api/v1/clusters/"+url.PathEscape(clusterID)+"/tests", "test_results", opts, nil)
+}
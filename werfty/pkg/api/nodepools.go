@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// NodePoolScale holds the node pool sizes to change; nil fields keep their value
+type NodePoolScale struct {
+	NodeCount   *int  `json:"node_count,omitempty"`
+	MinNodes    *int  `json:"min_nodes,omitempty"`
+	MaxNodes    *int  `json:"max_nodes,omitempty"`
+	AutoScaling *bool `json:"auto_scaling,omitempty"`
+}
+
+// ListNodePools lists the node pools of a cluster
+func (c *Werfty) ListNodePools(clusterID string) ([]*sharedmodels.NodePool, error) {
+	return listAll[*sharedmodels.NodePool](c, "/api/v1/clusters/"+clusterID+"/nodepools", "node_pools", ListOptions{}, fmt.Errorf("cluster not found"))
+}
+
+// CreateNodePool adds a node pool to a cluster
+func (c *Werfty) CreateNodePool(clusterID string, pool *sharedmodels.NodePool) (*sharedmodels.NodePool, error) {
+	resp, err := c.doRequest("POST", "/api/v1/clusters/"+clusterID+"/nodepools", pool)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, nodePoolError(resp)
+	}
+
+	var created sharedmodels.NodePool
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &created, nil
+}
+
+// ScaleNodePool changes the node counts or autoscaling of a node pool
+func (c *Werfty) ScaleNodePool(clusterID, poolID string, scale *NodePoolScale) (*sharedmodels.NodePool, error) {
+	resp, err := c.doRequest("PUT", "/api/v1/clusters/"+clusterID+"/nodepools/"+poolID, scale)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nodePoolError(resp)
+	}
+
+	var updated sharedmodels.NodePool
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &updated, nil
+}
+
+// DeleteNodePool removes a node pool from a cluster
+func (c *Werfty) DeleteNodePool(clusterID, poolID string) error {
+	resp, err := c.doRequest("DELETE", "/api/v1/clusters/"+clusterID+"/nodepools/"+poolID, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return nodePoolError(resp)
+	}
+
+	return nil
+}
+
+// nodePoolError turns an error answer into an error; node pool validation failures
+// carry the reason in the body
+func nodePoolError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.Error != "" {
+		return fmt.Errorf("%s", body.Error)
+	}
+	return fmt.Errorf("server returned status %d", resp.StatusCode)
+}
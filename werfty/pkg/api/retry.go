@@ -0,0 +1,104 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
+)
+
+// IdempotencyHeader carries the key that makes cube-server answer a POST only once
+const IdempotencyHeader = "Idempotency-Key"
+
+// DefaultRetries is the Retries of NewWerfty
+const DefaultRetries = 2
+
+// ErrVersionMismatch is returned by conditional changes of resources that were
+// changed since they were read
+var ErrVersionMismatch = errors.New("resource was changed since it was read")
+
+// retryable reports whether a request may be sent again after resp or err. POSTs
+// carry an Idempotency-Key, so the server answers a repeat with the first answer; a
+// DELETE is only sent again after a 503, which cube-server answers before acting.
+func retryable(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		return method != http.MethodDelete
+	}
+	switch resp.StatusCode {
+	case http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method != http.MethodDelete
+	}
+	return false
+}
+
+// newIdempotencyKey returns a random key for a POST and its retries
+func newIdempotencyKey() string {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		// Without a key the POST is just not deduplicated
+		return ""
+	}
+	return hex.EncodeToString(key)
+}
+
+// ifMatch is the If-Match header for a resource version; 0 (unknown) sends none
+func ifMatch(version int64) http.Header {
+	if version == 0 {
+		return nil
+	}
+	return http.Header{"If-Match": {`"` + strconv.FormatInt(version, 10) + `"`}}
+}
+
+// UpdateMultiCloudCluster replaces a cluster if it is still at the ResourceVersion
+// it was read with, and returns ErrVersionMismatch otherwise. A cluster without a
+// ResourceVersion is replaced unconditionally.
+func (c *Werfty) UpdateMultiCloudCluster(cluster *sharedmodels.Cluster) (*sharedmodels.Cluster, error) {
+	resp, err := c.doRequestWith("PUT", "/api/v1/clusters/"+cluster.ID, cluster, ifMatch(cluster.ResourceVersion))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("cluster not found")
+	case http.StatusPreconditionFailed:
+		return nil, ErrVersionMismatch
+	default:
+		return nil, nodePoolError(resp)
+	}
+
+	var updated sharedmodels.Cluster
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeleteClusterVersion deletes a cluster if it is still at version, and returns
+// ErrVersionMismatch otherwise
+func (c *Werfty) DeleteClusterVersion(id string, version int64) error {
+	resp, err := c.doRequestWith("DELETE", "/api/v1/clusters/"+id, nil, ifMatch(version))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("cluster not found")
+	case http.StatusPreconditionFailed:
+		return ErrVersionMismatch
+	}
+	return nodePoolError(resp)
+}
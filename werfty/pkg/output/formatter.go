@@ -8,6 +8,7 @@ import (
 
 	"punchbag-cube-testsuite/client/pkg/api"
 
+	sharedmodels "github.com/tronicum/punchbag-cube-testsuite/shared/models"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,6 +24,18 @@ func PrintClusters(clusters []*api.Cluster, format string) {
 	}
 }
 
+// PrintNodePools prints the node pools of a cluster in the specified format
+func PrintNodePools(pools []*sharedmodels.NodePool, format string) {
+	switch format {
+	case "json":
+		printJSON(pools)
+	case "yaml":
+		printYAML(pools)
+	default:
+		printNodePoolsTable(pools)
+	}
+}
+
 // PrintAKSClusters prints a list of AKS clusters in the specified format (backward compatibility)
 func PrintAKSClusters(clusters []*api.AKSCluster, format string) {
 	switch format {
@@ -185,6 +198,26 @@ func printClustersTable(clusters []*api.Cluster) {
 	w.Flush()
 }
 
+func printNodePoolsTable(pools []*sharedmodels.NodePool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tNODES\tMIN\tMAX\tAUTOSCALING\tINSTANCE TYPE\tOS")
+
+	for _, pool := range pools {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%t\t%s\t%s\n",
+			pool.ID,
+			pool.Name,
+			pool.NodeCount,
+			pool.MinNodes,
+			pool.MaxNodes,
+			pool.AutoScaling,
+			pool.InstanceType,
+			pool.OSType,
+		)
+	}
+
+	w.Flush()
+}
+
 func printClusterTable(cluster *api.Cluster) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
 